@@ -4,7 +4,14 @@
 package actions
 
 import (
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/names"
+	"gopkg.in/juju/charm.v4"
+
 	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/api/watcher"
 	"github.com/juju/juju/apiserver/params"
 )
 
@@ -12,6 +19,10 @@ import (
 type Client struct {
 	base.ClientFacade
 	facade base.FacadeCaller
+
+	// schema, if not nil, holds the charm actions schema that Actions
+	// are validated against before they are queued.
+	schema *charm.Actions
 }
 
 // NewClient returns a new actions client.
@@ -20,40 +31,288 @@ func NewClient(st base.APICallCloser) *Client {
 	return &Client{ClientFacade: frontend, facade: backend}
 }
 
+// NewValidatingClient returns a new actions client that validates the
+// Actions it is asked to queue against the given charm actions schema,
+// as returned by BulkSpecs, before sending them to the server. See
+// ValidateAgainst.
+func NewValidatingClient(st base.APICallCloser, schema charm.Actions) *Client {
+	client := NewClient(st)
+	client.schema = &schema
+	return client
+}
+
+// requireV1 returns an error satisfying errors.IsNotImplemented if the
+// API server does not implement version 1 of the Actions facade, which
+// introduced the named method.
+func (c *Client) requireV1(method string) error {
+	if c.BestAPIVersion() < 1 {
+		return errors.NotImplementedf("%s() (need V1+)", method)
+	}
+	return nil
+}
+
 // Enqueue takes a list of Actions and queues them up to be executed by
 // the designated ActionReceiver, returning the params.Action for each
 // queued Action, or an error if there was a problem queueing up the
-// Action.
+// Action. If the client validates Actions, none are queued if any of
+// them is invalid, and the *ValidationError for the first of those is
+// returned.
 func (c *Client) Enqueue(arg params.Actions) (params.ActionResults, error) {
+	if c.schema != nil {
+		for _, action := range arg.Actions {
+			if err := ValidateAgainst(*c.schema, action); err != nil {
+				return params.ActionResults{}, err
+			}
+		}
+	}
+	return c.enqueue(arg)
+}
+
+// enqueue queues up the given Actions without validating them.
+func (c *Client) enqueue(arg params.Actions) (params.ActionResults, error) {
 	results := params.ActionResults{}
 	err := c.facade.FacadeCall("Enqueue", arg, &results)
 	return results, err
 }
 
+// EnqueueWithSlot is like Enqueue, but limits the given Actions by the
+// named slot: at most maxConcurrent Actions queued with the slot run at
+// once across the environment, and the rest wait, with the status
+// params.ActionWaitingForSlot, until the slot has room.
+func (c *Client) EnqueueWithSlot(arg params.Actions, slotName string, maxConcurrent int) (params.ActionResults, error) {
+	if err := c.requireV1("EnqueueWithSlot"); err != nil {
+		return params.ActionResults{}, err
+	}
+	slotted := params.Actions{Actions: make([]params.Action, len(arg.Actions))}
+	for i, action := range arg.Actions {
+		action.Slot = &params.ActionSlot{Name: slotName, MaxConcurrent: maxConcurrent}
+		slotted.Actions[i] = action
+	}
+	return c.Enqueue(slotted)
+}
+
+// EnqueueOutcome describes what became of one of the Actions given to
+// EnqueueBatch.
+type EnqueueOutcome struct {
+	// Action is the Action as it was given to EnqueueBatch.
+	Action params.Action
+
+	// Tag identifies the queued Action. It is only set if Error is
+	// nil.
+	Tag names.ActionTag
+
+	// Error, if not nil, holds the reason the Action could not be
+	// queued.
+	Error error
+}
+
+// EnqueueBatch queues up the given Actions, returning the outcome for
+// each of them in the same order. An Action that cannot be queued, for
+// instance because its receiver does not exist, does not prevent the
+// others from being queued; the reason is recorded in its outcome
+// instead. If the client validates Actions, the outcome of an invalid
+// Action holds its *ValidationError, and the Action is not sent. The
+// returned error is only non-nil if the request as a whole failed, in
+// which case no outcomes are returned.
+func (c *Client) EnqueueBatch(actions []params.Action) ([]EnqueueOutcome, error) {
+	outcomes := make([]EnqueueOutcome, len(actions))
+	var valid params.Actions
+	var sent []int
+	for i, action := range actions {
+		outcomes[i].Action = action
+		if c.schema != nil {
+			if err := ValidateAgainst(*c.schema, action); err != nil {
+				outcomes[i].Error = err
+				continue
+			}
+		}
+		valid.Actions = append(valid.Actions, action)
+		sent = append(sent, i)
+	}
+	if len(sent) == 0 {
+		return outcomes, nil
+	}
+	results, err := c.enqueue(valid)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != len(sent) {
+		return nil, errors.Errorf("expected %d results, got %d", len(sent), len(results.Results))
+	}
+	for i, result := range results.Results {
+		outcome := &outcomes[sent[i]]
+		switch {
+		case result.Error != nil:
+			outcome.Error = result.Error
+		case result.Action == nil:
+			outcome.Error = errors.New("action was not queued")
+		default:
+			outcome.Tag = result.Action.Tag
+		}
+	}
+	return outcomes, nil
+}
+
+// RunAndWait enqueues the given Action, waits for it to finish, and
+// returns its result. If the Action has not finished within timeout,
+// RunAndWait tries to cancel it and returns an error; an Action that
+// is already running when the timeout expires cannot be cancelled,
+// and is left to finish.
+func (c *Client) RunAndWait(action params.Action, timeout time.Duration) (params.ActionResult, error) {
+	if err := c.requireV1("RunAndWait"); err != nil {
+		return params.ActionResult{}, err
+	}
+	results, err := c.Enqueue(params.Actions{Actions: []params.Action{action}})
+	if err != nil {
+		return params.ActionResult{}, err
+	}
+	if len(results.Results) != 1 {
+		return params.ActionResult{}, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	if results.Results[0].Error != nil {
+		return params.ActionResult{}, results.Results[0].Error
+	}
+	tag := results.Results[0].Action.Tag
+	w, err := c.WatchAction(tag)
+	if err != nil {
+		return params.ActionResult{}, err
+	}
+	defer w.Stop()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case _, ok := <-w.Changes():
+			result, done, err := c.completedResult(action.Receiver, tag)
+			if err != nil || done {
+				return result, err
+			}
+			if !ok {
+				if err := w.Err(); err != nil && !params.IsCodeStopped(err) {
+					return params.ActionResult{}, err
+				}
+				return params.ActionResult{}, errors.Errorf("action %s stopped without a result", tag.Id())
+			}
+		case <-deadline:
+			// The Action may be pending still, in which case it need
+			// not run at all.
+			err := errors.Errorf("timed out after %v waiting for action %s", timeout, tag.Id())
+			var cancelled params.ActionResults
+			if cerr := c.facade.FacadeCall("Cancel", params.ActionTags{Actions: []names.ActionTag{tag}}, &cancelled); cerr != nil {
+				return params.ActionResult{}, errors.Annotatef(cerr, "%v; cannot cancel action", err)
+			}
+			if len(cancelled.Results) != 1 {
+				return params.ActionResult{}, errors.Errorf("%v; cannot cancel action: expected 1 result, got %d", err, len(cancelled.Results))
+			}
+			if cerr := cancelled.Results[0].Error; cerr != nil {
+				return params.ActionResult{}, errors.Annotatef(cerr, "%v; cannot cancel action", err)
+			}
+			return params.ActionResult{}, err
+		}
+	}
+}
+
+// completedResult returns the result of the Action with the given tag
+// on the given ActionReceiver, and whether the Action has completed.
+func (c *Client) completedResult(receiver names.Tag, tag names.ActionTag) (params.ActionResult, bool, error) {
+	found, err := c.ListCompleted(params.Tags{Tags: []names.Tag{receiver}})
+	if err != nil {
+		return params.ActionResult{}, false, err
+	}
+	if len(found.Actions) != 1 {
+		return params.ActionResult{}, false, errors.Errorf("expected 1 result, got %d", len(found.Actions))
+	}
+	if found.Actions[0].Error != nil {
+		return params.ActionResult{}, false, found.Actions[0].Error
+	}
+	for _, result := range found.Actions[0].Actions {
+		if result.Action != nil && result.Action.Tag == tag {
+			return result, true, nil
+		}
+	}
+	return params.ActionResult{}, false, nil
+}
+
 // ListAll takes a list of Tags representing ActionReceivers and returns
 // all of the Actions that have been queued or run by each of those
 // Entities.
 func (c *Client) ListAll(arg params.Tags) (params.ActionsByReceivers, error) {
-	results := params.ActionsByReceivers{}
-	err := c.facade.FacadeCall("ListAll", arg, &results)
-	return results, err
+	if c.BestAPIVersion() < 1 {
+		return c.list("ListAll", arg)
+	}
+	return c.ListFiltered(params.ActionsFilter{Receivers: arg.Tags})
 }
 
 // ListPending takes a list of Tags representing ActionReceivers
 // and returns all of the Actions that are queued for each of those
 // Entities.
 func (c *Client) ListPending(arg params.Tags) (params.ActionsByReceivers, error) {
-	results := params.ActionsByReceivers{}
-	err := c.facade.FacadeCall("ListPending", arg, &results)
-	return results, err
+	if c.BestAPIVersion() < 1 {
+		return c.list("ListPending", arg)
+	}
+	return c.ListFiltered(params.ActionsFilter{
+		Receivers: arg.Tags,
+		Statuses:  []string{params.ActionPending, params.ActionWaitingForSlot},
+	})
 }
 
 // ListCompleted takes a list of Tags representing ActionReceivers
 // and returns all of the Actions that have been run on each of those
 // Entities.
 func (c *Client) ListCompleted(arg params.Tags) (params.ActionsByReceivers, error) {
+	if c.BestAPIVersion() < 1 {
+		return c.list("ListCompleted", arg)
+	}
+	return c.ListFiltered(params.ActionsFilter{
+		Receivers: arg.Tags,
+		Statuses: []string{
+			params.ActionCompleted,
+			params.ActionFailed,
+			params.ActionCancelled,
+			params.ActionAborted,
+		},
+	})
+}
+
+// list makes the given listing request of version 0 of the Actions
+// facade, which cannot filter the Actions it returns.
+func (c *Client) list(request string, arg params.Tags) (params.ActionsByReceivers, error) {
+	results := params.ActionsByReceivers{}
+	err := c.facade.FacadeCall(request, arg, &results)
+	return results, err
+}
+
+// ListFiltered returns the Actions of each of the ActionReceivers
+// named in arg.Receivers that match the rest of the filter: those
+// with arg.Name, if it is not empty, and one of arg.Statuses, if any,
+// and, for completed Actions, that completed within the window given
+// by arg.CompletedAfter and arg.CompletedBefore. The Actions of each
+// receiver are returned a page at a time, as given by arg.Offset and
+// arg.Limit.
+func (c *Client) ListFiltered(arg params.ActionsFilter) (params.ActionsByReceivers, error) {
+	if err := c.requireV1("ListFiltered"); err != nil {
+		return params.ActionsByReceivers{}, err
+	}
 	results := params.ActionsByReceivers{}
-	err := c.facade.FacadeCall("ListCompleted", arg, &results)
+	err := c.facade.FacadeCall("ListFiltered", arg, &results)
+	return results, err
+}
+
+// FindByName returns the Actions with the given name on every
+// ActionReceiver, grouped by receiver. If status is not empty, only
+// Actions with that status are returned, and if since is non-zero,
+// only those queued or completed within that duration. Receivers
+// with no such Actions are omitted.
+func (c *Client) FindByName(name string, status params.ActionStatus, since time.Duration) (params.ActionsByReceivers, error) {
+	if err := c.requireV1("FindByName"); err != nil {
+		return params.ActionsByReceivers{}, err
+	}
+	results := params.ActionsByReceivers{}
+	args := params.FindActionsByName{
+		Name:   name,
+		Status: status,
+		Since:  since,
+	}
+	err := c.facade.FacadeCall("FindByName", args, &results)
 	return results, err
 }
 
@@ -64,3 +323,371 @@ func (c *Client) Cancel(arg params.Actions) (params.ActionResults, error) {
 	err := c.facade.FacadeCall("Cancel", arg, &results)
 	return results, err
 }
+
+// CancelMatching cancels every pending Action in the environment that
+// matches the given filter, and returns the outcome for each matching
+// Action. Matching Actions that are running or have completed are
+// reported with an error, as they cannot be cancelled.
+func (c *Client) CancelMatching(filter params.ActionsFilter) (params.ActionResults, error) {
+	if err := c.requireV1("CancelMatching"); err != nil {
+		return params.ActionResults{}, err
+	}
+	results := params.ActionResults{}
+	err := c.facade.FacadeCall("CancelMatching", filter, &results)
+	return results, err
+}
+
+// AbortAllRunning stops every Action in the environment, aborting
+// those that are running and cancelling those that are pending, and
+// returns the outcome for each. Only the environment owner may do
+// this.
+func (c *Client) AbortAllRunning() (params.ActionResults, error) {
+	if err := c.requireV1("AbortAllRunning"); err != nil {
+		return params.ActionResults{}, err
+	}
+	results := params.ActionResults{}
+	err := c.facade.FacadeCall("AbortAllRunning", nil, &results)
+	return results, err
+}
+
+// QueuePosition returns the zero-based position of the given pending
+// Action in the queue of its ActionReceiver, or an error if the Action
+// has already been run or cancelled.
+func (c *Client) QueuePosition(tag names.ActionTag) (int, error) {
+	if err := c.requireV1("QueuePosition"); err != nil {
+		return 0, err
+	}
+	args := params.ActionTags{Actions: []names.ActionTag{tag}}
+	results := params.ActionQueuePositionResults{}
+	err := c.facade.FacadeCall("QueuePositions", args, &results)
+	if err != nil {
+		return 0, err
+	}
+	if len(results.Results) != 1 {
+		return 0, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.Position, nil
+}
+
+// ServiceOutput returns the most recent result of the named Action for
+// each unit of the given service, keyed by unit name. If since is
+// non-zero, only results completed within that duration are returned.
+func (c *Client) ServiceOutput(serviceTag names.ServiceTag, actionName string, since time.Duration) (map[string]params.ActionResult, error) {
+	if err := c.requireV1("ServiceOutput"); err != nil {
+		return nil, err
+	}
+	args := params.ServiceActionOutputs{
+		Outputs: []params.ServiceActionOutput{{
+			ServiceTag: serviceTag,
+			Name:       actionName,
+			Since:      since,
+		}},
+	}
+	results := params.ServiceActionOutputResults{}
+	err := c.facade.FacadeCall("ServiceOutputs", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return result.Results, nil
+}
+
+// ResourceUsage returns the processor time and peak memory the given
+// Action consumed while it ran, as captured by the unit agent. It is
+// an error if the Action has not finished, or if its usage was not
+// captured.
+func (c *Client) ResourceUsage(tag names.ActionTag) (params.ActionResourceUsage, error) {
+	if err := c.requireV1("ResourceUsage"); err != nil {
+		return params.ActionResourceUsage{}, err
+	}
+	args := params.ActionTags{Actions: []names.ActionTag{tag}}
+	results := params.ActionResourceUsageResults{}
+	err := c.facade.FacadeCall("ResourceUsage", args, &results)
+	if err != nil {
+		return params.ActionResourceUsage{}, err
+	}
+	if len(results.Results) != 1 {
+		return params.ActionResourceUsage{}, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return params.ActionResourceUsage{}, result.Error
+	}
+	return result.Usage, nil
+}
+
+// LatestResult returns the most recent completed result of the named
+// Action on the given ActionReceiver; failed and cancelled runs are
+// ignored. If the Action has never completed there, the error
+// satisfies params.IsCodeNotFound.
+func (c *Client) LatestResult(receiver names.Tag, actionName string) (params.ActionResult, error) {
+	if err := c.requireV1("LatestResult"); err != nil {
+		return params.ActionResult{}, err
+	}
+	args := params.LatestActionResultArgs{
+		Receivers: []names.Tag{receiver},
+		Name:      actionName,
+	}
+	results := params.ActionResults{}
+	err := c.facade.FacadeCall("LatestResults", args, &results)
+	if err != nil {
+		return params.ActionResult{}, err
+	}
+	if len(results.Results) != 1 {
+		return params.ActionResult{}, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return params.ActionResult{}, result.Error
+	}
+	return result, nil
+}
+
+// MissingFor returns the units of the given service that have no
+// completed run of the named Action.
+func (c *Client) MissingFor(serviceTag names.ServiceTag, actionName string) (params.Tags, error) {
+	if err := c.requireV1("MissingFor"); err != nil {
+		return params.Tags{}, err
+	}
+	args := params.MissingActionArgs{
+		Services: []names.ServiceTag{serviceTag},
+		Name:     actionName,
+	}
+	results := params.MissingActionResults{}
+	err := c.facade.FacadeCall("MissingFor", args, &results)
+	if err != nil {
+		return params.Tags{}, err
+	}
+	if len(results.Results) != 1 {
+		return params.Tags{}, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return params.Tags{}, result.Error
+	}
+	return result.Units, nil
+}
+
+// BulkSpecs returns the action specs declared by the charm of each of
+// the given services, keyed by service name. A service whose charm
+// cannot be read has the error recorded in its entry.
+func (c *Client) BulkSpecs(serviceTags params.Tags) (map[string]params.ActionSpecs, error) {
+	args := params.ServiceTags{}
+	for _, tag := range serviceTags.Tags {
+		serviceTag, ok := tag.(names.ServiceTag)
+		if !ok {
+			return nil, errors.Errorf("%q is not a service tag", tag)
+		}
+		args.ServiceTags = append(args.ServiceTags, serviceTag)
+	}
+	results := params.ServicesCharmActionsResults{}
+	err := c.facade.FacadeCall("ServicesCharmActions", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != len(args.ServiceTags) {
+		return nil, errors.Errorf("expected %d results, got %d", len(args.ServiceTags), len(results.Results))
+	}
+	specs := make(map[string]params.ActionSpecs)
+	for i, result := range results.Results {
+		spec := params.ActionSpecs{Error: result.Error}
+		if result.Actions != nil {
+			spec.Specs = result.Actions.ActionSpecs
+		}
+		specs[args.ServiceTags[i].Id()] = spec
+	}
+	return specs, nil
+}
+
+// RawActionsYAML returns the content of the actions.yaml file in the
+// given service's charm, exactly as it appears in the charm archive,
+// including any comments. If the charm has no actions.yaml, the error
+// satisfies params.IsCodeNotFound.
+func (c *Client) RawActionsYAML(serviceTag names.ServiceTag) ([]byte, error) {
+	if err := c.requireV1("RawActionsYAML"); err != nil {
+		return nil, err
+	}
+	args := params.ServiceTags{ServiceTags: []names.ServiceTag{serviceTag}}
+	var results params.ServicesActionsYAMLResults
+	err := c.facade.FacadeCall("ServicesActionsYAML", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return result.YAML, nil
+}
+
+// Durations returns, for each of the given ActionReceivers, how long
+// each completed run of the named Action took to run.
+func (c *Client) Durations(arg params.Tags, actionName string) ([]params.ActionDuration, error) {
+	if err := c.requireV1("Durations"); err != nil {
+		return nil, err
+	}
+	args := params.ActionDurationArgs{Receivers: arg.Tags, Name: actionName}
+	results := params.ActionDurationResults{}
+	err := c.facade.FacadeCall("Durations", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results.Results, nil
+}
+
+// EstimateDrain returns an estimate of how long the Actions currently
+// queued for the given ActionReceiver will take to run, based on how
+// long previous runs of the same Actions took. The estimate is zero if
+// no Actions are queued.
+func (c *Client) EstimateDrain(receiver names.Tag) (time.Duration, error) {
+	if err := c.requireV1("EstimateDrain"); err != nil {
+		return 0, err
+	}
+	args := params.Tags{Tags: []names.Tag{receiver}}
+	results := params.ActionDrainEstimates{}
+	err := c.facade.FacadeCall("EstimateDrains", args, &results)
+	if err != nil {
+		return 0, err
+	}
+	if len(results.Results) != 1 {
+		return 0, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.Duration, nil
+}
+
+// DefaultTimeout returns the timeout given to Actions enqueued without
+// one of their own, as configured for the environment. It is zero if
+// such Actions may run for as long as they need.
+func (c *Client) DefaultTimeout() (time.Duration, error) {
+	if err := c.requireV1("DefaultTimeout"); err != nil {
+		return 0, err
+	}
+	var result params.ActionDefaultTimeout
+	err := c.facade.FacadeCall("DefaultTimeout", nil, &result)
+	return result.Timeout, err
+}
+
+// FacadeCapabilities returns the version of the actions facade
+// provided by the API server, and the names of the methods it
+// supports. For servers that predate this method it returns an error
+// satisfying errors.IsNotImplemented, in which case only the methods
+// of the initial version of the facade can be relied upon.
+func (c *Client) FacadeCapabilities() (params.ActionFacadeCaps, error) {
+	if err := c.requireV1("FacadeCapabilities"); err != nil {
+		return params.ActionFacadeCaps{}, err
+	}
+	var caps params.ActionFacadeCaps
+	err := c.facade.FacadeCall("Capabilities", nil, &caps)
+	return caps, err
+}
+
+// EffectiveParams returns the parameters the given Action is or was
+// run with by the unit agent: those it was enqueued with, with any
+// defaults declared by the charm's action spec filled in.
+func (c *Client) EffectiveParams(tag names.ActionTag) (map[string]interface{}, error) {
+	if err := c.requireV1("EffectiveParams"); err != nil {
+		return nil, err
+	}
+	args := params.ActionTags{Actions: []names.ActionTag{tag}}
+	var results params.ActionResults
+	err := c.facade.FacadeCall("EffectiveParams", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return result.Action.Parameters, nil
+}
+
+// WatchAction returns a NotifyWatcher that notifies when the given
+// Action begins running, and when it finishes or is cancelled. Once
+// the Action has finished or been cancelled, the watcher stops and
+// its Changes channel is closed; Err then returns an error satisfying
+// params.IsCodeStopped.
+func (c *Client) WatchAction(tag names.ActionTag) (watcher.NotifyWatcher, error) {
+	if err := c.requireV1("WatchAction"); err != nil {
+		return nil, err
+	}
+	args := params.ActionTags{Actions: []names.ActionTag{tag}}
+	var results params.NotifyWatchResults
+	err := c.facade.FacadeCall("WatchActions", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	w := watcher.NewNotifyWatcher(c.facade.RawAPICaller(), result)
+	return w, nil
+}
+
+// WatchActions returns a StringsWatcher that notifies with the ids of
+// the Actions of the given ActionReceiver as they are queued, begin
+// running, and finish or are cancelled. The first event holds the ids
+// of every Action the receiver already has. The watcher stops, and
+// Err reports why, if the server fails or the client is closed.
+func (c *Client) WatchActions(receiver names.Tag) (watcher.StringsWatcher, error) {
+	if err := c.requireV1("WatchActions"); err != nil {
+		return nil, err
+	}
+	args := params.Entities{Entities: []params.Entity{{Tag: receiver.String()}}}
+	var results params.StringsWatchResults
+	err := c.facade.FacadeCall("WatchReceiverActions", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, errors.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	w := watcher.NewStringsWatcher(c.facade.RawAPICaller(), result)
+	return w, nil
+}
+
+// WatchAllActions returns an ActionsWatcher that notifies on the
+// lifecycle of every Action in the environment, regardless of its
+// ActionReceiver.
+func (c *Client) WatchAllActions() (watcher.ActionsWatcher, error) {
+	if err := c.requireV1("WatchAllActions"); err != nil {
+		return nil, err
+	}
+	var result params.ActionsWatchResult
+	err := c.facade.FacadeCall("WatchAllActions", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	w := watcher.NewActionsWatcher(c.facade.RawAPICaller(), result)
+	return w, nil
+}
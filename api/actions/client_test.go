@@ -0,0 +1,1165 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/names"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	charmtesting "gopkg.in/juju/charm.v4/testing"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/juju/juju/api/actions"
+	"github.com/juju/juju/apiserver/common"
+	"github.com/juju/juju/apiserver/params"
+	jujutesting "github.com/juju/juju/juju/testing"
+	"github.com/juju/juju/rpc/rpcreflect"
+	"github.com/juju/juju/state"
+	statetesting "github.com/juju/juju/state/testing"
+	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/testing/factory"
+)
+
+type actionsSuite struct {
+	jujutesting.JujuConnSuite
+
+	client  *actions.Client
+	service *state.Service
+	unit    *state.Unit
+}
+
+var _ = gc.Suite(&actionsSuite{})
+
+func (s *actionsSuite) SetUpTest(c *gc.C) {
+	s.JujuConnSuite.SetUpTest(c)
+	s.client = actions.NewClient(s.APIState)
+	c.Assert(s.client, gc.NotNil)
+
+	f := factory.NewFactory(s.State)
+	s.service = f.MakeService(c, &factory.ServiceParams{
+		Name:    "wordpress",
+		Charm:   f.MakeCharm(c, &factory.CharmParams{Name: "wordpress"}),
+		Creator: s.AdminUserTag(c),
+	})
+	s.unit = f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+}
+
+func (s *actionsSuite) enqueue(c *gc.C, names ...string) []params.ActionResult {
+	arg := params.Actions{}
+	for _, name := range names {
+		arg.Actions = append(arg.Actions, params.Action{Receiver: s.unit.Tag(), Name: name})
+	}
+	results, err := s.client.Enqueue(arg)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, len(names))
+	for _, result := range results.Results {
+		c.Assert(result.Error, gc.IsNil)
+	}
+	return results.Results
+}
+
+func (s *actionsSuite) TestEnqueueBatch(c *gc.C) {
+	batch := []params.Action{
+		{Receiver: s.unit.Tag(), Name: "backup"},
+		{Receiver: names.NewUnitTag("wordpress/99"), Name: "backup"},
+		{Receiver: s.unit.Tag(), Name: "restore"},
+	}
+	outcomes, err := s.client.EnqueueBatch(batch)
+	c.Assert(err, gc.IsNil)
+	c.Assert(outcomes, gc.HasLen, 3)
+	for i, outcome := range outcomes {
+		c.Check(outcome.Action, gc.DeepEquals, batch[i])
+	}
+	c.Check(outcomes[1].Error, gc.ErrorMatches, common.ErrBadId.Error())
+	c.Check(outcomes[1].Tag, gc.Equals, names.ActionTag{})
+
+	c.Check(outcomes[0].Error, gc.IsNil)
+	c.Check(outcomes[2].Error, gc.IsNil)
+	actions, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	var queued []names.ActionTag
+	for _, action := range actions {
+		queued = append(queued, action.ActionTag())
+	}
+	c.Assert(queued, jc.SameContents, []names.ActionTag{outcomes[0].Tag, outcomes[2].Tag})
+}
+
+func (s *actionsSuite) TestValidatingClient(c *gc.C) {
+	client := actions.NewValidatingClient(s.APIState, backupSchema)
+	_, err := client.Enqueue(params.Actions{Actions: []params.Action{{
+		Receiver:   s.unit.Tag(),
+		Name:       "backup",
+		Parameters: map[string]interface{}{"dest": "/srv/backups"},
+	}, {
+		Receiver: s.unit.Tag(),
+		Name:     "bakup",
+	}}})
+	c.Assert(err, gc.ErrorMatches, `action "bakup" is not defined`)
+	c.Assert(err, gc.FitsTypeOf, &actions.ValidationError{})
+	queued, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(queued, gc.HasLen, 0)
+}
+
+func (s *actionsSuite) TestValidatingClientEnqueueBatch(c *gc.C) {
+	client := actions.NewValidatingClient(s.APIState, backupSchema)
+	outcomes, err := client.EnqueueBatch([]params.Action{{
+		Receiver: s.unit.Tag(),
+		Name:     "backup",
+	}, {
+		Receiver:   s.unit.Tag(),
+		Name:       "backup",
+		Parameters: map[string]interface{}{"dest": "/srv/backups"},
+	}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(outcomes, gc.HasLen, 2)
+	c.Check(outcomes[0].Error, gc.ErrorMatches, `invalid parameters for action "backup": missing parameters dest`)
+	c.Check(outcomes[1].Error, gc.IsNil)
+	queued, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(queued, gc.HasLen, 1)
+	c.Assert(queued[0].ActionTag(), gc.Equals, outcomes[1].Tag)
+}
+
+func (s *actionsSuite) TestEnqueueWithRetry(c *gc.C) {
+	results, err := s.client.Enqueue(params.Actions{Actions: []params.Action{{
+		Receiver: s.unit.Tag(),
+		Name:     "sync",
+		Retry:    &params.ActionRetryPolicy{MaxAttempts: 3, Backoff: time.Second},
+	}}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 1)
+	c.Assert(results.Results[0].Error, gc.IsNil)
+	c.Assert(results.Results[0].Attempt, gc.Equals, 1)
+	tag := results.Results[0].Action.Tag
+
+	pending := func() params.ActionResult {
+		found, err := s.client.ListPending(params.Tags{Tags: []names.Tag{s.unit.Tag()}})
+		c.Assert(err, gc.IsNil)
+		c.Assert(found.Actions, gc.HasLen, 1)
+		c.Assert(found.Actions[0].Actions, gc.HasLen, 1)
+		return found.Actions[0].Actions[0]
+	}
+
+	// Stand in for the unit agent, failing the first two attempts.
+	for attempt := 1; attempt <= 2; attempt++ {
+		current := pending()
+		c.Assert(current.Attempt, gc.Equals, attempt)
+		c.Assert(current.Action.Retry, gc.DeepEquals, &params.ActionRetryPolicy{MaxAttempts: 3, Backoff: time.Second})
+		action, err := s.State.ActionByTag(tag)
+		c.Assert(err, gc.IsNil)
+		result, err := action.Finish(state.ActionResults{
+			Status:  state.ActionFailed,
+			Message: fmt.Sprintf("rate limited on attempt %d", attempt),
+		})
+		c.Assert(err, gc.IsNil)
+		c.Assert(result, gc.IsNil)
+	}
+	current := pending()
+	c.Assert(current.Attempt, gc.Equals, 3)
+	c.Assert(current.NotBefore, gc.NotNil)
+	c.Assert(current.Attempts, gc.HasLen, 2)
+
+	action, err := s.State.ActionByTag(tag)
+	c.Assert(err, gc.IsNil)
+	_, err = action.Finish(state.ActionResults{
+		Status:  state.ActionCompleted,
+		Results: map[string]interface{}{"synced": "yes"},
+	})
+	c.Assert(err, gc.IsNil)
+
+	completed, err := s.client.ListCompleted(params.Tags{Tags: []names.Tag{s.unit.Tag()}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(completed.Actions, gc.HasLen, 1)
+	c.Assert(completed.Actions[0].Actions, gc.HasLen, 1)
+	result := completed.Actions[0].Actions[0]
+	c.Assert(result.Status, gc.Equals, params.ActionCompleted)
+	c.Assert(result.Output, gc.DeepEquals, map[string]interface{}{"synced": "yes"})
+	c.Assert(result.Attempt, gc.Equals, 3)
+	c.Assert(result.Attempts, gc.HasLen, 2)
+	for i, attempt := range result.Attempts {
+		c.Check(attempt.Message, gc.Equals, fmt.Sprintf("rate limited on attempt %d", i+1))
+	}
+}
+
+func (s *actionsSuite) TestEnqueueWithSlot(c *gc.C) {
+	arg := params.Actions{}
+	for _, name := range []string{"restore-a", "restore-b", "restore-c"} {
+		arg.Actions = append(arg.Actions, params.Action{Receiver: s.unit.Tag(), Name: name})
+	}
+	results, err := s.client.EnqueueWithSlot(arg, "db-restore", 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 3)
+	var tags []names.ActionTag
+	for _, result := range results.Results {
+		c.Assert(result.Error, gc.IsNil)
+		c.Assert(result.Status, gc.Equals, params.ActionPending)
+		c.Assert(result.Action.Slot, gc.DeepEquals, &params.ActionSlot{Name: "db-restore", MaxConcurrent: 1})
+		tags = append(tags, result.Action.Tag)
+	}
+
+	statuses := func() []string {
+		found, err := s.client.ListPending(params.Tags{Tags: []names.Tag{s.unit.Tag()}})
+		c.Assert(err, gc.IsNil)
+		c.Assert(found.Actions, gc.HasLen, 1)
+		var statuses []string
+		for _, action := range found.Actions[0].Actions {
+			statuses = append(statuses, action.Status)
+		}
+		return statuses
+	}
+	begin := func(tag names.ActionTag) error {
+		action, err := s.State.ActionByTag(tag)
+		c.Assert(err, gc.IsNil)
+		_, err = action.Begin()
+		return err
+	}
+	finish := func(tag names.ActionTag) {
+		action, err := s.State.ActionByTag(tag)
+		c.Assert(err, gc.IsNil)
+		_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+		c.Assert(err, gc.IsNil)
+	}
+
+	// Only one action may hold the slot at a time; the others wait.
+	c.Assert(begin(tags[0]), gc.IsNil)
+	c.Assert(statuses(), gc.DeepEquals, []string{
+		params.ActionPending, params.ActionWaitingForSlot, params.ActionWaitingForSlot,
+	})
+	c.Assert(begin(tags[1]), gc.Equals, state.ErrActionSlotFull)
+
+	finish(tags[0])
+	c.Assert(statuses(), gc.DeepEquals, []string{params.ActionPending, params.ActionPending})
+	c.Assert(begin(tags[1]), gc.IsNil)
+	c.Assert(statuses(), gc.DeepEquals, []string{params.ActionPending, params.ActionWaitingForSlot})
+	c.Assert(begin(tags[2]), gc.Equals, state.ErrActionSlotFull)
+
+	finish(tags[1])
+	c.Assert(begin(tags[2]), gc.IsNil)
+	finish(tags[2])
+	c.Assert(statuses(), gc.HasLen, 0)
+
+	// A further action enqueued while the slot is full is reported as
+	// waiting straight away.
+	results, err = s.client.EnqueueWithSlot(arg, "db-restore", 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(begin(results.Results[0].Action.Tag), gc.IsNil)
+	results, err = s.client.EnqueueWithSlot(params.Actions{Actions: arg.Actions[:1]}, "db-restore", 1)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results[0].Status, gc.Equals, params.ActionWaitingForSlot)
+}
+
+func (s *actionsSuite) TestDefaultTimeout(c *gc.C) {
+	timeout, err := s.client.DefaultTimeout()
+	c.Assert(err, gc.IsNil)
+	c.Assert(timeout, gc.Equals, time.Duration(0))
+
+	err = s.State.UpdateEnvironConfig(map[string]interface{}{"default-action-timeout": 1800}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	timeout, err = s.client.DefaultTimeout()
+	c.Assert(err, gc.IsNil)
+	c.Assert(timeout, gc.Equals, 30*time.Minute)
+}
+
+func (s *actionsSuite) TestEnqueueInheritsDefaultTimeout(c *gc.C) {
+	err := s.State.UpdateEnvironConfig(map[string]interface{}{"default-action-timeout": 1800}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	results, err := s.client.Enqueue(params.Actions{Actions: []params.Action{
+		{Receiver: s.unit.Tag(), Name: "backup"},
+		{Receiver: s.unit.Tag(), Name: "restore", Timeout: time.Hour},
+	}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 2)
+	for _, result := range results.Results {
+		c.Assert(result.Error, gc.IsNil)
+	}
+	// An action without its own timeout is given the default.
+	c.Assert(results.Results[0].Action.Timeout, gc.Equals, 30*time.Minute)
+	c.Assert(results.Results[1].Action.Timeout, gc.Equals, time.Hour)
+
+	for i, expected := range []time.Duration{30 * time.Minute, time.Hour} {
+		action, err := s.State.ActionByTag(results.Results[i].Action.Tag)
+		c.Assert(err, gc.IsNil)
+		c.Assert(action.Timeout(), gc.Equals, expected)
+	}
+	found, err := s.client.ListPending(params.Tags{Tags: []names.Tag{s.unit.Tag()}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 1)
+	c.Assert(found.Actions[0].Actions, gc.HasLen, 2)
+	c.Assert(found.Actions[0].Actions[0].Action.Timeout, gc.Equals, 30*time.Minute)
+}
+
+func (s *actionsSuite) TestQueuePosition(c *gc.C) {
+	queued := s.enqueue(c, "one", "two", "three")
+	for i, result := range queued {
+		position, err := s.client.QueuePosition(result.Action.Tag)
+		c.Assert(err, gc.IsNil)
+		c.Check(position, gc.Equals, i)
+	}
+
+	// Once the head of the queue has run, the others move up.
+	action, err := s.State.ActionByTag(queued[0].Action.Tag)
+	c.Assert(err, gc.IsNil)
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.client.QueuePosition(queued[0].Action.Tag)
+	c.Assert(err, gc.ErrorMatches, `action ".*" is not pending`)
+	position, err := s.client.QueuePosition(queued[2].Action.Tag)
+	c.Assert(err, gc.IsNil)
+	c.Assert(position, gc.Equals, 1)
+}
+
+func (s *actionsSuite) TestWatchAllActions(c *gc.C) {
+	w, err := s.client.WatchAllActions()
+	c.Assert(err, gc.IsNil)
+	defer func() {
+		c.Assert(w.Stop(), gc.IsNil)
+	}()
+	nextEvents := func() []params.ActionEvent {
+		s.BackingState.StartSync()
+		select {
+		case events, ok := <-w.Changes():
+			c.Assert(ok, jc.IsTrue)
+			return events
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("watcher did not send change")
+		}
+		panic("unreachable")
+	}
+	c.Assert(nextEvents(), gc.HasLen, 0)
+
+	queued := s.enqueue(c, "one")
+	c.Assert(nextEvents(), gc.DeepEquals, []params.ActionEvent{{
+		Tag:      queued[0].Action.Tag,
+		Receiver: s.unit.Tag(),
+		Name:     "one",
+		Status:   params.ActionPending,
+	}})
+}
+
+func (s *actionsSuite) TestWatchAction(c *gc.C) {
+	action, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	w, err := s.client.WatchAction(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	wc := statetesting.NewNotifyWatcherC(c, s.BackingState, w)
+
+	// Pending.
+	wc.AssertOneChange()
+
+	// Running.
+	action, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+
+	// Completed; the watcher then stops.
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	s.BackingState.StartSync()
+	for _, expectOpen := range []bool{true, false} {
+		select {
+		case _, ok := <-w.Changes():
+			c.Assert(ok, gc.Equals, expectOpen)
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("watcher did not send change")
+		}
+	}
+	c.Assert(w.Err(), jc.Satisfies, params.IsCodeStopped)
+}
+
+func (s *actionsSuite) TestWatchActions(c *gc.C) {
+	existing, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = existing.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	w, err := s.client.WatchActions(s.unit.Tag())
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewStringsWatcherC(c, s.BackingState, w)
+	wc.AssertChange(existing.Id())
+	wc.AssertNoChange()
+
+	// Queued.
+	action, err := s.unit.AddAction("restore", nil)
+	c.Assert(err, gc.IsNil)
+	id := action.Id()
+	wc.AssertChange(id)
+	wc.AssertNoChange()
+
+	// Running.
+	action, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+	wc.AssertChange(id)
+	wc.AssertNoChange()
+
+	// Completed.
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	wc.AssertChange(id)
+	wc.AssertNoChange()
+}
+
+func (s *actionsSuite) TestWatchActionsStopsWhenClientCloses(c *gc.C) {
+	st := s.OpenAPIAs(c, s.AdminUserTag(c), jujutesting.AdminSecret)
+	client := actions.NewClient(st)
+	w, err := client.WatchActions(s.unit.Tag())
+	c.Assert(err, gc.IsNil)
+	select {
+	case _, ok := <-w.Changes():
+		c.Assert(ok, jc.IsTrue)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("watcher did not send initial event")
+	}
+
+	c.Assert(client.Close(), gc.IsNil)
+	select {
+	case _, ok := <-w.Changes():
+		c.Assert(ok, jc.IsFalse)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("watcher did not stop")
+	}
+	c.Assert(w.Err(), gc.NotNil)
+}
+
+func (s *actionsSuite) TestWatchActionsNotReceiver(c *gc.C) {
+	_, err := s.client.WatchActions(names.NewServiceTag("wordpress"))
+	c.Assert(err, gc.ErrorMatches, common.ErrBadId.Error())
+}
+
+func (s *actionsSuite) TestRunAndWait(c *gc.C) {
+	type outcome struct {
+		result params.ActionResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := s.client.RunAndWait(params.Action{Receiver: s.unit.Tag(), Name: "backup"}, coretesting.LongWait)
+		done <- outcome{result, err}
+	}()
+
+	// Run the Action once it has been queued.
+	var action *state.Action
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		actions, err := s.unit.Actions()
+		c.Assert(err, gc.IsNil)
+		if len(actions) == 1 {
+			action = actions[0]
+			break
+		}
+	}
+	c.Assert(action, gc.NotNil)
+	output := map[string]interface{}{"outfile": "foo.bz2"}
+	_, err := action.Finish(state.ActionResults{Status: state.ActionCompleted, Results: output})
+	c.Assert(err, gc.IsNil)
+
+	timeout := time.After(coretesting.LongWait)
+	for {
+		s.BackingState.StartSync()
+		select {
+		case got := <-done:
+			c.Assert(got.err, gc.IsNil)
+			c.Assert(got.result.Action, gc.NotNil)
+			c.Assert(got.result.Action.Tag, gc.Equals, action.ActionTag())
+			c.Assert(got.result.Status, gc.Equals, params.ActionCompleted)
+			c.Assert(got.result.Output, gc.DeepEquals, output)
+			return
+		case <-time.After(coretesting.ShortWait):
+		case <-timeout:
+			c.Fatalf("RunAndWait did not return")
+		}
+	}
+}
+
+func (s *actionsSuite) TestRunAndWaitTimesOut(c *gc.C) {
+	_, err := s.client.RunAndWait(params.Action{Receiver: s.unit.Tag(), Name: "backup"}, coretesting.ShortWait)
+	c.Assert(err, gc.ErrorMatches, "timed out after .* waiting for action .*")
+
+	// The Action had not started, so it was cancelled.
+	actions, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(actions, gc.HasLen, 0)
+	results, err := s.unit.ActionResults()
+	c.Assert(err, gc.IsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Assert(results[0].Status(), gc.Equals, state.ActionCancelled)
+}
+
+func (s *actionsSuite) TestRunAndWaitTimesOutCancelFails(c *gc.C) {
+	actions.PatchFacadeCall(s, s.client, "Cancel", func(args, response interface{}) error {
+		return fmt.Errorf("boom")
+	})
+	_, err := s.client.RunAndWait(params.Action{Receiver: s.unit.Tag(), Name: "backup"}, coretesting.ShortWait)
+	c.Assert(err, gc.ErrorMatches, "timed out after .* waiting for action .*; cannot cancel action: boom")
+}
+
+func (s *actionsSuite) TestRunAndWaitTimesOutCancelResultFails(c *gc.C) {
+	actions.PatchFacadeCall(s, s.client, "Cancel", func(args, response interface{}) error {
+		*(response.(*params.ActionResults)) = params.ActionResults{
+			Results: []params.ActionResult{{Error: common.ServerError(fmt.Errorf("boom"))}},
+		}
+		return nil
+	})
+	_, err := s.client.RunAndWait(params.Action{Receiver: s.unit.Tag(), Name: "backup"}, coretesting.ShortWait)
+	c.Assert(err, gc.ErrorMatches, "timed out after .* waiting for action .*; cannot cancel action: boom")
+}
+
+func (s *actionsSuite) TestEffectiveParams(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	dummy := f.MakeService(c, &factory.ServiceParams{
+		Name:    "dummy",
+		Charm:   f.MakeCharm(c, &factory.CharmParams{Name: "dummy"}),
+		Creator: s.AdminUserTag(c),
+	})
+	unit := f.MakeUnit(c, &factory.UnitParams{Service: dummy})
+
+	// The snapshot action's outfile param defaults to "foo.bz2",
+	// so what runs differs from what was submitted.
+	submitted := map[string]interface{}{"quality": "high"}
+	action, err := unit.AddAction("snapshot", submitted)
+	c.Assert(err, gc.IsNil)
+	expected := map[string]interface{}{"outfile": "foo.bz2", "quality": "high"}
+
+	effective, err := s.client.EffectiveParams(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(effective, jc.DeepEquals, expected)
+	c.Assert(effective, gc.Not(jc.DeepEquals), submitted)
+
+	// The effective params are still available once the action has run.
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	effective, err = s.client.EffectiveParams(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(effective, jc.DeepEquals, expected)
+}
+
+func (s *actionsSuite) TestEffectiveParamsNotFound(c *gc.C) {
+	_, err := s.client.EffectiveParams(names.JoinActionTag(s.unit.Name(), 99))
+	c.Assert(err, gc.ErrorMatches, `action ".*" not found`)
+}
+
+func (s *actionsSuite) TestRawActionsYAML(c *gc.C) {
+	s.AddTestingService(c, "dummy", s.AddTestingCharm(c, "dummy"))
+	expected, err := ioutil.ReadFile(filepath.Join(charmtesting.Charms.CharmDir("dummy").Path, "actions.yaml"))
+	c.Assert(err, gc.IsNil)
+
+	data, err := s.client.RawActionsYAML(names.NewServiceTag("dummy"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, string(expected))
+}
+
+func (s *actionsSuite) TestRawActionsYAMLNoActions(c *gc.C) {
+	// The wordpress charm has no actions.yaml.
+	s.AddTestingService(c, "wp", s.AddTestingCharm(c, "wordpress"))
+	_, err := s.client.RawActionsYAML(names.NewServiceTag("wp"))
+	c.Assert(err, gc.ErrorMatches, `actions.yaml in charm ".*" not found`)
+	c.Assert(err, jc.Satisfies, params.IsCodeNotFound)
+}
+
+func (s *actionsSuite) TestRawActionsYAMLUnknownService(c *gc.C) {
+	_, err := s.client.RawActionsYAML(names.NewServiceTag("nonsense"))
+	c.Assert(err, gc.ErrorMatches, `service "nonsense" not found`)
+	c.Assert(err, jc.Satisfies, params.IsCodeNotFound)
+}
+
+func (s *actionsSuite) TestWatchActionNotFound(c *gc.C) {
+	_, err := s.client.WatchAction(names.JoinActionTag(s.unit.Name(), 99))
+	c.Assert(err, gc.ErrorMatches, `action ".*" not found`)
+	c.Assert(err, jc.Satisfies, params.IsCodeNotFound)
+}
+
+func (s *actionsSuite) runAction(c *gc.C, unit *state.Unit, name string, output map[string]interface{}) *state.ActionResult {
+	action, err := unit.AddAction(name, nil)
+	c.Assert(err, gc.IsNil)
+	result, err := action.Finish(state.ActionResults{Status: state.ActionCompleted, Results: output})
+	c.Assert(err, gc.IsNil)
+	return result
+}
+
+func (s *actionsSuite) TestServiceOutput(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	other := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+
+	s.runAction(c, s.unit, "backup", map[string]interface{}{"run": "first"})
+	latest := s.runAction(c, s.unit, "backup", map[string]interface{}{"run": "second"})
+	s.runAction(c, s.unit, "restore", nil)
+	otherLatest := s.runAction(c, other, "backup", map[string]interface{}{"run": "other"})
+
+	outputs, err := s.client.ServiceOutput(names.NewServiceTag(s.service.Name()), "backup", 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(outputs, gc.HasLen, 2)
+
+	result := outputs[s.unit.Name()]
+	c.Assert(result.Action, gc.NotNil)
+	c.Check(result.Action.Tag, gc.Equals, latest.ActionTag())
+	c.Check(result.Action.Name, gc.Equals, "backup")
+	c.Check(result.Status, gc.Equals, params.ActionCompleted)
+	c.Check(result.Output, gc.DeepEquals, map[string]interface{}{"run": "second"})
+
+	result = outputs[other.Name()]
+	c.Assert(result.Action, gc.NotNil)
+	c.Check(result.Action.Tag, gc.Equals, otherLatest.ActionTag())
+	c.Check(result.Output, gc.DeepEquals, map[string]interface{}{"run": "other"})
+}
+
+func (s *actionsSuite) TestServiceOutputNotRun(c *gc.C) {
+	s.runAction(c, s.unit, "backup", nil)
+	outputs, err := s.client.ServiceOutput(names.NewServiceTag(s.service.Name()), "restore", time.Hour)
+	c.Assert(err, gc.IsNil)
+	c.Assert(outputs, gc.HasLen, 0)
+}
+
+func (s *actionsSuite) failAction(c *gc.C, unit *state.Unit, name string) *state.ActionResult {
+	action, err := unit.AddAction(name, nil)
+	c.Assert(err, gc.IsNil)
+	result, err := action.Finish(state.ActionResults{Status: state.ActionFailed, Message: "oops"})
+	c.Assert(err, gc.IsNil)
+	return result
+}
+
+// actionTagsByReceiver returns the tags of the actions found for each
+// receiver.
+func actionTagsByReceiver(c *gc.C, found params.ActionsByReceivers) map[names.Tag][]names.ActionTag {
+	tags := make(map[names.Tag][]names.ActionTag)
+	for _, receiver := range found.Actions {
+		c.Assert(receiver.Error, gc.IsNil)
+		for _, result := range receiver.Actions {
+			c.Assert(result.Action, gc.NotNil)
+			c.Check(result.Action.Receiver, gc.Equals, receiver.Receiver)
+			tags[receiver.Receiver] = append(tags[receiver.Receiver], result.Action.Tag)
+		}
+	}
+	return tags
+}
+
+func (s *actionsSuite) TestFindByName(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	other := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+	mysql := f.MakeUnit(c, &factory.UnitParams{
+		Service: f.MakeService(c, &factory.ServiceParams{
+			Name:    "mysql",
+			Charm:   f.MakeCharm(c, &factory.CharmParams{Name: "mysql"}),
+			Creator: s.AdminUserTag(c),
+		}),
+	})
+
+	failed := s.failAction(c, s.unit, "backup")
+	completed := s.runAction(c, s.unit, "backup", nil)
+	s.failAction(c, s.unit, "restore")
+	pending, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	otherFailed := s.failAction(c, other, "backup")
+	s.failAction(c, mysql, "restore")
+
+	found, err := s.client.FindByName("backup", params.ActionStatus(params.ActionFailed), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 2)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {failed.ActionTag()},
+		other.Tag():  {otherFailed.ActionTag()},
+	})
+	for _, receiver := range found.Actions {
+		c.Check(receiver.Actions[0].Status, gc.Equals, params.ActionFailed)
+		c.Check(receiver.Actions[0].Message, gc.Equals, "oops")
+	}
+
+	found, err = s.client.FindByName("backup", "", time.Hour)
+	c.Assert(err, gc.IsNil)
+	tags := actionTagsByReceiver(c, found)
+	c.Assert(tags, gc.HasLen, 2)
+	c.Assert(tags[s.unit.Tag()], jc.SameContents, []names.ActionTag{
+		pending.ActionTag(), failed.ActionTag(), completed.ActionTag(),
+	})
+	c.Assert(tags[other.Tag()], gc.DeepEquals, []names.ActionTag{otherFailed.ActionTag()})
+
+	found, err = s.client.FindByName("backup", params.ActionStatus(params.ActionPending), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {pending.ActionTag()},
+	})
+
+	found, err = s.client.FindByName("restore", params.ActionStatus(params.ActionCompleted), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 0)
+}
+
+func (s *actionsSuite) TestFindByNameWaitingForSlot(c *gc.C) {
+	slot := state.ActionSlot{Name: "db", MaxConcurrent: 1}
+	running, err := s.unit.AddActionWithOptions("backup", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	waiting, err := s.unit.AddActionWithOptions("backup", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	_, err = running.Begin()
+	c.Assert(err, gc.IsNil)
+
+	found, err := s.client.FindByName("backup", params.ActionStatus(params.ActionWaitingForSlot), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {waiting.ActionTag()},
+	})
+	c.Assert(found.Actions[0].Actions[0].Status, gc.Equals, params.ActionWaitingForSlot)
+
+	found, err = s.client.FindByName("backup", params.ActionStatus(params.ActionPending), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {running.ActionTag()},
+	})
+
+	found, err = s.client.FindByName("backup", "", 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 1)
+	statuses := make(map[names.ActionTag]string)
+	for _, result := range found.Actions[0].Actions {
+		statuses[result.Action.Tag] = result.Status
+	}
+	c.Assert(statuses, gc.DeepEquals, map[names.ActionTag]string{
+		running.ActionTag(): params.ActionPending,
+		waiting.ActionTag(): params.ActionWaitingForSlot,
+	})
+}
+
+func (s *actionsSuite) TestFindByNameSince(c *gc.C) {
+	s.failAction(c, s.unit, "backup")
+	found, err := s.client.FindByName("backup", "", time.Nanosecond)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 0)
+}
+
+func (s *actionsSuite) TestFindByNameUnknownStatus(c *gc.C) {
+	_, err := s.client.FindByName("backup", "exploded", 0)
+	c.Assert(err, gc.ErrorMatches, `unknown action status "exploded"`)
+}
+
+func (s *actionsSuite) TestListFiltered(c *gc.C) {
+	failed := s.failAction(c, s.unit, "backup")
+	completed := s.runAction(c, s.unit, "restore", nil)
+	pending, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+
+	receivers := []names.Tag{s.unit.Tag()}
+	found, err := s.client.ListFiltered(params.ActionsFilter{
+		Receivers: receivers,
+		Statuses:  []string{params.ActionFailed, params.ActionPending},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {pending.ActionTag(), failed.ActionTag()},
+	})
+
+	found, err = s.client.ListFiltered(params.ActionsFilter{Receivers: receivers, Name: "restore"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {completed.ActionTag()},
+	})
+
+	// The completion window leaves out every completed action, but
+	// not the queued one.
+	after := completed.Completed().Add(time.Second)
+	found, err = s.client.ListFiltered(params.ActionsFilter{Receivers: receivers, CompletedAfter: &after})
+	c.Assert(err, gc.IsNil)
+	c.Assert(actionTagsByReceiver(c, found), gc.DeepEquals, map[names.Tag][]names.ActionTag{
+		s.unit.Tag(): {pending.ActionTag()},
+	})
+}
+
+func (s *actionsSuite) TestListFilteredPages(c *gc.C) {
+	var tags []names.ActionTag
+	for i := 0; i < 5; i++ {
+		tags = append(tags, s.runAction(c, s.unit, "backup", nil).ActionTag())
+	}
+	var paged []names.ActionTag
+	for offset := 0; offset < len(tags); offset += 2 {
+		found, err := s.client.ListFiltered(params.ActionsFilter{
+			Receivers: []names.Tag{s.unit.Tag()},
+			Offset:    offset,
+			Limit:     2,
+		})
+		c.Assert(err, gc.IsNil)
+		c.Assert(found.Actions, gc.HasLen, 1)
+		for _, result := range found.Actions[0].Actions {
+			paged = append(paged, result.Action.Tag)
+		}
+	}
+	c.Assert(paged, jc.SameContents, tags)
+}
+
+func (s *actionsSuite) TestListFilteredUnknownStatus(c *gc.C) {
+	_, err := s.client.ListFiltered(params.ActionsFilter{Statuses: []string{"exploded"}})
+	c.Assert(err, gc.ErrorMatches, `unknown action status "exploded"`)
+}
+
+func (s *actionsSuite) beginAction(c *gc.C, unit *state.Unit, name string) *state.Action {
+	action, err := unit.AddAction(name, nil)
+	c.Assert(err, gc.IsNil)
+	action, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+	return action
+}
+
+func (s *actionsSuite) TestAbortAllRunning(c *gc.C) {
+	other := factory.NewFactory(s.State).MakeUnit(c, &factory.UnitParams{Service: s.service})
+	running := []*state.Action{
+		s.beginAction(c, s.unit, "backup"),
+		s.beginAction(c, s.unit, "restore"),
+		s.beginAction(c, other, "backup"),
+	}
+	pending, err := other.AddAction("restore", nil)
+	c.Assert(err, gc.IsNil)
+	completed := s.runAction(c, s.unit, "snapshot", nil)
+
+	results, err := s.client.AbortAllRunning()
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 4)
+	statuses := make(map[names.ActionTag]string)
+	for _, result := range results.Results {
+		c.Assert(result.Error, gc.IsNil)
+		c.Assert(result.Action, gc.NotNil)
+		statuses[result.Action.Tag] = result.Status
+	}
+	c.Assert(statuses, gc.DeepEquals, map[names.ActionTag]string{
+		running[0].ActionTag(): params.ActionAborted,
+		running[1].ActionTag(): params.ActionAborted,
+		running[2].ActionTag(): params.ActionAborted,
+		pending.ActionTag():    params.ActionCancelled,
+	})
+
+	for _, action := range running {
+		result, err := s.State.ActionResultByTag(action.ActionTag())
+		c.Assert(err, gc.IsNil)
+		c.Check(result.Status(), gc.Equals, state.ActionAborted)
+		_, message := result.Results()
+		c.Check(message, gc.Equals, "aborted by administrator")
+	}
+	for _, unit := range []*state.Unit{s.unit, other} {
+		actions, err := unit.Actions()
+		c.Assert(err, gc.IsNil)
+		c.Check(actions, gc.HasLen, 0)
+	}
+	result, err := s.State.ActionResultByTag(completed.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Check(result.Status(), gc.Equals, state.ActionCompleted)
+}
+
+func (s *actionsSuite) TestCancelMatching(c *gc.C) {
+	other := factory.NewFactory(s.State).MakeUnit(c, &factory.UnitParams{Service: s.service})
+	pending, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	otherPending, err := other.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	unmatched, err := s.unit.AddAction("restore", nil)
+	c.Assert(err, gc.IsNil)
+	running := s.beginAction(c, other, "backup")
+	completed := s.runAction(c, s.unit, "backup", nil)
+
+	results, err := s.client.CancelMatching(params.ActionsFilter{Name: "backup"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 4)
+	outcomes := make(map[names.ActionTag]params.ActionResult)
+	for _, result := range results.Results {
+		c.Assert(result.Action, gc.NotNil)
+		c.Check(result.Action.Name, gc.Equals, "backup")
+		outcomes[result.Action.Tag] = result
+	}
+	for _, tag := range []names.ActionTag{pending.ActionTag(), otherPending.ActionTag()} {
+		c.Check(outcomes[tag].Error, gc.IsNil)
+		c.Check(outcomes[tag].Status, gc.Equals, params.ActionCancelled)
+	}
+	c.Check(outcomes[running.ActionTag()].Error, gc.ErrorMatches, "action .* is running and cannot be cancelled")
+	c.Check(outcomes[completed.ActionTag()].Error, gc.ErrorMatches, "action .* has completed and cannot be cancelled")
+	c.Check(outcomes[completed.ActionTag()].Status, gc.Equals, params.ActionCompleted)
+
+	// Only the matching pending actions were cancelled.
+	actions, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(actions, gc.HasLen, 1)
+	c.Assert(actions[0].ActionTag(), gc.Equals, unmatched.ActionTag())
+	actions, err = other.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(actions, gc.HasLen, 1)
+	c.Assert(actions[0].ActionTag(), gc.Equals, running.ActionTag())
+}
+
+func (s *actionsSuite) TestCancelMatchingOlderThan(c *gc.C) {
+	_, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	results, err := s.client.CancelMatching(params.ActionsFilter{OlderThan: time.Hour})
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 0)
+	actions, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(actions, gc.HasLen, 1)
+}
+
+func (s *actionsSuite) TestCancelMatchingEmptyFilter(c *gc.C) {
+	_, err := s.client.CancelMatching(params.ActionsFilter{})
+	c.Assert(err, gc.ErrorMatches, "action filter must specify a name or an age")
+}
+
+func (s *actionsSuite) TestLatestResult(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	other := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+
+	s.runAction(c, s.unit, "health-check", map[string]interface{}{"run": "first"})
+	latest := s.runAction(c, s.unit, "health-check", map[string]interface{}{"run": "second"})
+	// Later runs that did not complete are ignored.
+	s.failAction(c, s.unit, "health-check")
+	s.runAction(c, s.unit, "backup", nil)
+	s.runAction(c, other, "health-check", map[string]interface{}{"run": "other"})
+
+	result, err := s.client.LatestResult(s.unit.Tag(), "health-check")
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Action, gc.NotNil)
+	c.Check(result.Action.Tag, gc.Equals, latest.ActionTag())
+	c.Check(result.Action.Receiver, gc.Equals, s.unit.Tag())
+	c.Check(result.Action.Name, gc.Equals, "health-check")
+	c.Check(result.Status, gc.Equals, params.ActionCompleted)
+	c.Check(result.Output, gc.DeepEquals, map[string]interface{}{"run": "second"})
+}
+
+func (s *actionsSuite) TestLatestResultNeverRun(c *gc.C) {
+	s.runAction(c, s.unit, "backup", nil)
+	// A failed run does not count, and a pending action has no result.
+	s.failAction(c, s.unit, "health-check")
+	_, err := s.unit.AddAction("health-check", nil)
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.client.LatestResult(s.unit.Tag(), "health-check")
+	c.Assert(err, gc.ErrorMatches, `result of action "health-check" on unit-wordpress-0 not found`)
+	c.Assert(params.IsCodeNotFound(err), jc.IsTrue)
+}
+
+func (s *actionsSuite) TestMissingFor(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	ran := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+	failed := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+	pending := f.MakeUnit(c, &factory.UnitParams{Service: s.service})
+
+	s.runAction(c, s.unit, "migrate", nil)
+	s.runAction(c, ran, "backup", nil)
+	s.failAction(c, ran, "migrate")
+	s.runAction(c, ran, "migrate", nil)
+	s.failAction(c, failed, "migrate")
+	_, err := pending.AddAction("migrate", nil)
+	c.Assert(err, gc.IsNil)
+
+	missing, err := s.client.MissingFor(names.NewServiceTag(s.service.Name()), "migrate")
+	c.Assert(err, gc.IsNil)
+	c.Assert(missing, jc.DeepEquals, params.Tags{
+		Tags: []names.Tag{failed.Tag(), pending.Tag()},
+	})
+
+	missing, err = s.client.MissingFor(names.NewServiceTag(s.service.Name()), "backup")
+	c.Assert(err, gc.IsNil)
+	c.Assert(missing, jc.DeepEquals, params.Tags{
+		Tags: []names.Tag{s.unit.Tag(), failed.Tag(), pending.Tag()},
+	})
+}
+
+func (s *actionsSuite) TestResourceUsage(c *gc.C) {
+	// Stand in for the unit agent reporting the action's usage.
+	action, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = action.Finish(state.ActionResults{
+		Status: state.ActionCompleted,
+		Usage:  &state.ActionUsage{CPUTime: 2500 * time.Millisecond, PeakMemory: 300 << 20},
+	})
+	c.Assert(err, gc.IsNil)
+
+	usage, err := s.client.ResourceUsage(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(usage, gc.DeepEquals, params.ActionResourceUsage{
+		CPUTime:    2500 * time.Millisecond,
+		PeakMemory: 300 << 20,
+	})
+}
+
+func (s *actionsSuite) TestResourceUsagePending(c *gc.C) {
+	action, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = s.client.ResourceUsage(action.ActionTag())
+	c.Assert(err, gc.ErrorMatches, `action ".*" has not finished`)
+}
+
+func (s *actionsSuite) TestResourceUsageNotCaptured(c *gc.C) {
+	result := s.runAction(c, s.unit, "backup", nil)
+	_, err := s.client.ResourceUsage(result.ActionTag())
+	c.Assert(err, gc.ErrorMatches, `resource usage of action ".*" not found`)
+	c.Assert(params.IsCodeNotFound(err), jc.IsTrue)
+}
+
+func (s *actionsSuite) TestDurations(c *gc.C) {
+	// An action that was never begun has no known duration.
+	s.runAction(c, s.unit, "backup", nil)
+
+	action, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	action, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+	result, err := action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	expected, ok := result.Duration()
+	c.Assert(ok, jc.IsTrue)
+
+	durations, err := s.client.Durations(params.Tags{Tags: []names.Tag{s.unit.Tag()}}, "backup")
+	c.Assert(err, gc.IsNil)
+	c.Assert(durations, gc.DeepEquals, []params.ActionDuration{{
+		Receiver:  s.unit.Tag(),
+		Durations: []time.Duration{expected},
+	}})
+}
+
+func (s *actionsSuite) TestEstimateDrain(c *gc.C) {
+	action, err := s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	action, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+	result, err := action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	// Record the run as having taken a fixed time.
+	backup := 3 * time.Second
+	results := s.State.MongoSession().DB("juju").C("actionresults")
+	err = results.UpdateId(
+		s.State.EnvironTag().Id()+":"+result.Id(),
+		bson.D{{"$set", bson.D{{"started", result.Completed().Add(-backup)}}}},
+	)
+	c.Assert(err, gc.IsNil)
+
+	// Nothing is queued.
+	estimate, err := s.client.EstimateDrain(s.unit.Tag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(estimate, gc.Equals, time.Duration(0))
+
+	_, err = s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = s.unit.AddAction("backup", nil)
+	c.Assert(err, gc.IsNil)
+	estimate, err = s.client.EstimateDrain(s.unit.Tag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(estimate, gc.Equals, 2*backup)
+}
+
+func (s *actionsSuite) TestEstimateDrainUnknownReceiver(c *gc.C) {
+	_, err := s.client.EstimateDrain(names.NewUnitTag("wordpress/99"))
+	c.Assert(err, gc.ErrorMatches, "id not found")
+}
+
+func (s *actionsSuite) TestBulkSpecs(c *gc.C) {
+	f := factory.NewFactory(s.State)
+	f.MakeService(c, &factory.ServiceParams{
+		Name:    "dummy",
+		Charm:   f.MakeCharm(c, &factory.CharmParams{Name: "dummy"}),
+		Creator: s.AdminUserTag(c),
+	})
+
+	specs, err := s.client.BulkSpecs(params.Tags{Tags: []names.Tag{
+		names.NewServiceTag("dummy"),
+		s.service.Tag(),
+		names.NewServiceTag("nonsense"),
+	}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(specs, gc.HasLen, 3)
+
+	c.Check(specs["dummy"].Error, gc.IsNil)
+	c.Check(specs["dummy"].Specs, gc.HasLen, 1)
+	c.Check(specs["dummy"].Specs["snapshot"].Description, gc.Equals, "Take a snapshot of the database.")
+
+	c.Check(specs["wordpress"].Error, gc.IsNil)
+	c.Check(specs["wordpress"].Specs, gc.HasLen, 0)
+
+	c.Check(specs["nonsense"].Specs, gc.HasLen, 0)
+	c.Check(specs["nonsense"].Error, gc.ErrorMatches, `service "nonsense" not found`)
+}
+
+func (s *actionsSuite) TestBulkSpecsNotServiceTag(c *gc.C) {
+	_, err := s.client.BulkSpecs(params.Tags{Tags: []names.Tag{s.unit.Tag()}})
+	c.Assert(err, gc.ErrorMatches, `"unit-wordpress-0" is not a service tag`)
+}
+
+func (s *actionsSuite) TestFacadeCapabilities(c *gc.C) {
+	c.Assert(s.client.BestAPIVersion(), gc.Equals, 1)
+	facadeType, err := common.Facades.GetType("Actions", s.client.BestAPIVersion())
+	c.Assert(err, gc.IsNil)
+
+	caps, err := s.client.FacadeCapabilities()
+	c.Assert(err, gc.IsNil)
+	c.Assert(caps.Version, gc.Equals, s.client.BestAPIVersion())
+	c.Assert(caps.Methods, gc.DeepEquals, rpcreflect.ObjTypeOf(facadeType).MethodNames())
+	c.Assert(caps.Methods, jc.SameContents, []string{
+		"AbortAllRunning",
+		"Cancel",
+		"CancelMatching",
+		"Capabilities",
+		"DefaultTimeout",
+		"Durations",
+		"EffectiveParams",
+		"Enqueue",
+		"EstimateDrains",
+		"FindByName",
+		"LatestResults",
+		"ListAll",
+		"ListCompleted",
+		"ListFiltered",
+		"ListPending",
+		"MissingFor",
+		"QueuePositions",
+		"ResourceUsage",
+		"ServiceOutputs",
+		"ServicesActionsYAML",
+		"ServicesCharmActions",
+		"WatchActions",
+		"WatchAllActions",
+		"WatchReceiverActions",
+	})
+}
+
+func (s *actionsSuite) TestV1MethodsNotImplementedV0(c *gc.C) {
+	client := actions.NewClientV0(s.APIState)
+	c.Assert(client.BestAPIVersion(), gc.Equals, 0)
+
+	_, err := client.ListFiltered(params.ActionsFilter{})
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+	c.Check(err.Error(), gc.Equals, "ListFiltered() (need V1+) not implemented")
+	_, err = client.FindByName("backup", "", 0)
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+	_, err = client.CancelMatching(params.ActionsFilter{})
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+	_, err = client.AbortAllRunning()
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+	_, err = client.FacadeCapabilities()
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+
+	// Nothing is queued by an Action RunAndWait cannot wait for.
+	_, err = client.RunAndWait(params.Action{Receiver: s.unit.Tag(), Name: "backup"}, coretesting.ShortWait)
+	c.Check(err, jc.Satisfies, errors.IsNotImplemented)
+	pending, err := s.unit.Actions()
+	c.Assert(err, gc.IsNil)
+	c.Assert(pending, gc.HasLen, 0)
+}
+
+func (s *actionsSuite) TestListV0(c *gc.C) {
+	client := actions.NewClientV0(s.APIState)
+	queued := s.enqueue(c, "backup")
+
+	arg := params.Tags{Tags: []names.Tag{s.unit.Tag()}}
+	for _, list := range []func(params.Tags) (params.ActionsByReceivers, error){
+		client.ListAll,
+		client.ListPending,
+	} {
+		found, err := list(arg)
+		c.Assert(err, gc.IsNil)
+		c.Assert(found.Actions, gc.HasLen, 1)
+		c.Assert(found.Actions[0].Actions, gc.HasLen, 1)
+		c.Assert(found.Actions[0].Actions[0].Action.Tag, gc.Equals, queued[0].Action.Tag)
+	}
+	found, err := client.ListCompleted(arg)
+	c.Assert(err, gc.IsNil)
+	c.Assert(found.Actions, gc.HasLen, 1)
+	c.Assert(found.Actions[0].Actions, gc.HasLen, 0)
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions
+
+import (
+	"reflect"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+// resultKey identifies the result of an Action on a receiver.
+type resultKey struct {
+	receiver string
+	name     string
+}
+
+// DiffActionResults compares two sets of ActionResults, such as the
+// results of running the same Actions before and after a change, and
+// reports the results that were added, removed, or whose status or
+// output changed. Results are matched by receiver and Action name; if
+// a set holds several results with the same receiver and name, they
+// are matched in the order in which they appear. Results without an
+// Action, such as those reporting an error, are ignored.
+func DiffActionResults(before, after params.ActionResults) params.ActionResultsDiff {
+	var diff params.ActionResultsDiff
+	unmatched := make(map[resultKey][]params.ActionResult)
+	for _, result := range before.Results {
+		if result.Action == nil {
+			continue
+		}
+		key := keyOf(result)
+		unmatched[key] = append(unmatched[key], result)
+	}
+	matched := make(map[resultKey]int)
+	for _, result := range after.Results {
+		if result.Action == nil {
+			continue
+		}
+		key := keyOf(result)
+		previous := unmatched[key]
+		if len(previous) == 0 {
+			diff.Added = append(diff.Added, result)
+			continue
+		}
+		unmatched[key] = previous[1:]
+		matched[key]++
+		if changed, ok := diffActionResult(previous[0], result); ok {
+			diff.Changed = append(diff.Changed, changed)
+		}
+	}
+	// Earlier results are matched first, so any results left over
+	// for a key are the last ones with that key in before.
+	seen := make(map[resultKey]int)
+	for _, result := range before.Results {
+		if result.Action == nil {
+			continue
+		}
+		key := keyOf(result)
+		if seen[key] >= matched[key] {
+			diff.Removed = append(diff.Removed, result)
+		}
+		seen[key]++
+	}
+	return diff
+}
+
+func keyOf(result params.ActionResult) resultKey {
+	var receiver string
+	if result.Action.Receiver != nil {
+		receiver = result.Action.Receiver.String()
+	}
+	return resultKey{receiver: receiver, name: result.Action.Name}
+}
+
+// diffActionResult returns the differences between two results of
+// the same Action, and whether there were any.
+func diffActionResult(before, after params.ActionResult) (params.ActionResultDiff, bool) {
+	diff := params.ActionResultDiff{
+		Receiver: after.Action.Receiver,
+		Name:     after.Action.Name,
+	}
+	changed := false
+	if before.Status != after.Status {
+		diff.BeforeStatus = before.Status
+		diff.AfterStatus = after.Status
+		changed = true
+	}
+	output := make(map[string]params.ActionOutputDiff)
+	for key, value := range before.Output {
+		if afterValue, ok := after.Output[key]; !ok || !reflect.DeepEqual(value, afterValue) {
+			output[key] = params.ActionOutputDiff{Before: value, After: afterValue}
+		}
+	}
+	for key, value := range after.Output {
+		if _, ok := before.Output[key]; !ok {
+			output[key] = params.ActionOutputDiff{After: value}
+		}
+	}
+	if len(output) > 0 {
+		diff.Output = output
+		changed = true
+	}
+	return diff, changed
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions_test
+
+import (
+	"github.com/juju/names"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/api/actions"
+	"github.com/juju/juju/apiserver/params"
+)
+
+type diffSuite struct{}
+
+var _ = gc.Suite(&diffSuite{})
+
+func makeResult(unit, name, status string, output map[string]interface{}) params.ActionResult {
+	return params.ActionResult{
+		Action: &params.Action{
+			Receiver: names.NewUnitTag(unit),
+			Name:     name,
+		},
+		Status: status,
+		Output: output,
+	}
+}
+
+func (*diffSuite) TestDiffActionResultsUnchanged(c *gc.C) {
+	results := params.ActionResults{Results: []params.ActionResult{
+		makeResult("mysql/0", "backup", params.ActionCompleted, map[string]interface{}{"size": 10}),
+		makeResult("mysql/1", "backup", params.ActionFailed, nil),
+	}}
+	diff := actions.DiffActionResults(results, results)
+	c.Assert(diff, jc.DeepEquals, params.ActionResultsDiff{})
+}
+
+func (*diffSuite) TestDiffActionResults(c *gc.C) {
+	before := params.ActionResults{Results: []params.ActionResult{
+		makeResult("mysql/0", "backup", params.ActionCompleted, map[string]interface{}{
+			"size": 10,
+			"path": "/srv/backup",
+			"host": "db0",
+		}),
+		makeResult("mysql/1", "backup", params.ActionCompleted, nil),
+		makeResult("mysql/2", "backup", params.ActionCompleted, nil),
+		{Error: &params.Error{Message: "boom"}},
+	}}
+	after := params.ActionResults{Results: []params.ActionResult{
+		{Error: &params.Error{Message: "bang"}},
+		makeResult("mysql/3", "backup", params.ActionCompleted, nil),
+		makeResult("mysql/1", "backup", params.ActionFailed, nil),
+		makeResult("mysql/0", "backup", params.ActionCompleted, map[string]interface{}{
+			"size":   12,
+			"path":   "/srv/backup",
+			"digest": "abc",
+		}),
+	}}
+	diff := actions.DiffActionResults(before, after)
+	c.Assert(diff.Added, jc.DeepEquals, []params.ActionResult{after.Results[1]})
+	c.Assert(diff.Removed, jc.DeepEquals, []params.ActionResult{before.Results[2]})
+	c.Assert(diff.Changed, jc.DeepEquals, []params.ActionResultDiff{{
+		Receiver:     names.NewUnitTag("mysql/1"),
+		Name:         "backup",
+		BeforeStatus: params.ActionCompleted,
+		AfterStatus:  params.ActionFailed,
+	}, {
+		Receiver: names.NewUnitTag("mysql/0"),
+		Name:     "backup",
+		Output: map[string]params.ActionOutputDiff{
+			"size":   {Before: 10, After: 12},
+			"host":   {Before: "db0"},
+			"digest": {After: "abc"},
+		},
+	}})
+}
+
+func (*diffSuite) TestDiffActionResultsRepeatedActions(c *gc.C) {
+	// Repeated runs of an Action on the same receiver are matched
+	// in order.
+	before := params.ActionResults{Results: []params.ActionResult{
+		makeResult("mysql/0", "backup", params.ActionCompleted, nil),
+		makeResult("mysql/0", "backup", params.ActionFailed, nil),
+		makeResult("mysql/0", "snapshot", params.ActionCompleted, nil),
+		makeResult("mysql/0", "backup", params.ActionPending, nil),
+	}}
+	after := params.ActionResults{Results: []params.ActionResult{
+		makeResult("mysql/0", "backup", params.ActionCompleted, nil),
+		makeResult("mysql/0", "backup", params.ActionCompleted, nil),
+	}}
+	diff := actions.DiffActionResults(before, after)
+	c.Assert(diff.Added, gc.HasLen, 0)
+	c.Assert(diff.Removed, jc.DeepEquals, []params.ActionResult{
+		before.Results[2],
+		before.Results[3],
+	})
+	c.Assert(diff.Changed, jc.DeepEquals, []params.ActionResultDiff{{
+		Receiver:     names.NewUnitTag("mysql/0"),
+		Name:         "backup",
+		BeforeStatus: params.ActionFailed,
+		AfterStatus:  params.ActionCompleted,
+	}})
+}
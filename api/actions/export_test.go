@@ -0,0 +1,38 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions
+
+import (
+	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/api/base/testing"
+)
+
+// PatchFacadeCall patches the Client's facade such that FacadeCall
+// method calls for the given request are diverted to the provided
+// function; all other requests are made as usual.
+func PatchFacadeCall(p testing.Patcher, client *Client, request string, f func(params, response interface{}) error) {
+	caller := client.facade
+	testing.PatchFacadeCall(p, &client.facade, func(req string, params, response interface{}) error {
+		if req == request {
+			return f(params, response)
+		}
+		return caller.FacadeCall(req, params, response)
+	})
+}
+
+// callerV0 is an APICallCloser that only uses version 0 of every
+// facade.
+type callerV0 struct {
+	base.APICallCloser
+}
+
+func (callerV0) BestFacadeVersion(string) int {
+	return 0
+}
+
+// NewClientV0 creates a new actions client pinned to version 0 of the
+// Actions facade.
+func NewClientV0(st base.APICallCloser) *Client {
+	return NewClient(callerV0{st})
+}
@@ -0,0 +1,205 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gopkg.in/juju/charm.v4"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+// ParamTypeMismatch describes a parameter given to an Action whose
+// value is not of the type declared for it.
+type ParamTypeMismatch struct {
+	Param    string
+	Expected []string
+	Actual   string
+}
+
+// ValidationError describes the ways in which an Action does not
+// conform to the actions schema of a charm.
+type ValidationError struct {
+	// Action holds the name of the Action.
+	Action string
+
+	// UnknownAction is true if the charm declares no Action with
+	// that name, in which case the parameters are not checked.
+	UnknownAction bool
+
+	// UnknownParams holds the names of the parameters given that the
+	// charm does not declare.
+	UnknownParams []string
+
+	// MissingParams holds the names of the required parameters that
+	// were not given.
+	MissingParams []string
+
+	// TypeMismatches describes the parameters whose values are of the
+	// wrong type.
+	TypeMismatches []ParamTypeMismatch
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	if e.UnknownAction {
+		return fmt.Sprintf("action %q is not defined", e.Action)
+	}
+	var problems []string
+	if len(e.UnknownParams) > 0 {
+		problems = append(problems, fmt.Sprintf("unknown parameters %s", strings.Join(e.UnknownParams, ", ")))
+	}
+	if len(e.MissingParams) > 0 {
+		problems = append(problems, fmt.Sprintf("missing parameters %s", strings.Join(e.MissingParams, ", ")))
+	}
+	for _, mismatch := range e.TypeMismatches {
+		problems = append(problems, fmt.Sprintf(
+			"parameter %q must be %s, not %s",
+			mismatch.Param, strings.Join(mismatch.Expected, " or "), mismatch.Actual,
+		))
+	}
+	return fmt.Sprintf("invalid parameters for action %q: %s", e.Action, strings.Join(problems, "; "))
+}
+
+// ValidateAgainst checks that the given Action is declared by the
+// given charm actions schema, and that its parameters conform to the
+// parameters declared for it. It returns a *ValidationError
+// describing every problem found, or nil if there are none.
+//
+// Only the parts of the JSON schema describing each parameter's
+// presence and type are checked; the server may still reject an
+// Action that passes.
+func ValidateAgainst(schema charm.Actions, action params.Action) error {
+	spec, ok := schema.ActionSpecs[action.Name]
+	if !ok {
+		return &ValidationError{Action: action.Name, UnknownAction: true}
+	}
+	// Parameters are declared under "properties", as in a JSON
+	// schema. As in JSON schema, parameters that are not declared are
+	// allowed unless "additionalProperties" is false; if nothing is
+	// declared, no parameter is unknown.
+	declared, hasDeclared := spec.Params["properties"].(map[string]interface{})
+	additional, ok := spec.Params["additionalProperties"].(bool)
+	if !ok {
+		additional = true
+	}
+
+	verr := &ValidationError{Action: action.Name}
+	for name, value := range action.Parameters {
+		paramSchema, ok := declared[name]
+		if !ok {
+			if hasDeclared && !additional {
+				verr.UnknownParams = append(verr.UnknownParams, name)
+			}
+			continue
+		}
+		expected := declaredTypes(paramSchema)
+		if len(expected) == 0 {
+			continue
+		}
+		actual := jsonType(value)
+		if !typeMatches(actual, expected) {
+			verr.TypeMismatches = append(verr.TypeMismatches, ParamTypeMismatch{
+				Param:    name,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+	if required, ok := spec.Params["required"].([]interface{}); ok {
+		for _, name := range required {
+			name, ok := name.(string)
+			if !ok {
+				continue
+			}
+			if _, ok := action.Parameters[name]; !ok {
+				verr.MissingParams = append(verr.MissingParams, name)
+			}
+		}
+	}
+	if len(verr.UnknownParams) == 0 && len(verr.MissingParams) == 0 && len(verr.TypeMismatches) == 0 {
+		return nil
+	}
+	sort.Strings(verr.UnknownParams)
+	sort.Strings(verr.MissingParams)
+	sort.Sort(byParam(verr.TypeMismatches))
+	return verr
+}
+
+// declaredTypes returns the JSON types allowed for a parameter by its
+// schema, which may give a single type or a list of them.
+func declaredTypes(paramSchema interface{}) []string {
+	schema, ok := paramSchema.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch declared := schema["type"].(type) {
+	case string:
+		return []string{declared}
+	case []interface{}:
+		var types []string
+		for _, t := range declared {
+			if t, ok := t.(string); ok {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType returns the name of the JSON type of the given value, as
+// used in a JSON schema.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32:
+		return floatType(float64(value))
+	case float64:
+		return floatType(value)
+	case []interface{}, []string:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// floatType returns "integer" if the given number is whole, as it
+// would be decoded from JSON, and "number" otherwise.
+func floatType(value float64) string {
+	if value == math.Trunc(value) {
+		return "integer"
+	}
+	return "number"
+}
+
+// typeMatches reports whether a value of the actual JSON type is
+// allowed by the expected types. Integers are numbers.
+func typeMatches(actual string, expected []string) bool {
+	for _, t := range expected {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// byParam sorts ParamTypeMismatches by parameter name.
+type byParam []ParamTypeMismatch
+
+func (s byParam) Len() int           { return len(s) }
+func (s byParam) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byParam) Less(i, j int) bool { return s[i].Param < s[j].Param }
@@ -0,0 +1,112 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/juju/charm.v4"
+
+	"github.com/juju/juju/api/actions"
+	"github.com/juju/juju/apiserver/params"
+)
+
+type validateSuite struct{}
+
+var _ = gc.Suite(&validateSuite{})
+
+var backupSchema = charm.Actions{ActionSpecs: map[string]charm.ActionSpec{
+	"backup": {
+		Description: "Back up the database.",
+		Params: map[string]interface{}{
+			"type":                 "object",
+			"required":             []interface{}{"dest"},
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"dest":     map[string]interface{}{"type": "string"},
+				"copies":   map[string]interface{}{"type": "integer"},
+				"ratio":    map[string]interface{}{"type": "number"},
+				"compress": map[string]interface{}{"type": []interface{}{"boolean", "string"}},
+			},
+		},
+	},
+}}
+
+func (*validateSuite) TestValidateAgainst(c *gc.C) {
+	err := actions.ValidateAgainst(backupSchema, params.Action{
+		Name: "backup",
+		Parameters: map[string]interface{}{
+			"dest":     "/srv/backups",
+			"copies":   float64(3),
+			"ratio":    2,
+			"compress": "gzip",
+		},
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (*validateSuite) TestValidateAgainstUnknownAction(c *gc.C) {
+	err := actions.ValidateAgainst(backupSchema, params.Action{Name: "bakup"})
+	c.Assert(err, gc.ErrorMatches, `action "bakup" is not defined`)
+	c.Assert(err, jc.DeepEquals, &actions.ValidationError{Action: "bakup", UnknownAction: true})
+}
+
+func (*validateSuite) TestValidateAgainstInvalidParams(c *gc.C) {
+	err := actions.ValidateAgainst(backupSchema, params.Action{
+		Name: "backup",
+		Parameters: map[string]interface{}{
+			"copies":   1.5,
+			"compress": 9,
+			"dset":     "/srv/backups",
+		},
+	})
+	c.Assert(err, gc.ErrorMatches, `invalid parameters for action "backup": `+
+		`unknown parameters dset; missing parameters dest; `+
+		`parameter "compress" must be boolean or string, not integer; `+
+		`parameter "copies" must be integer, not number`)
+	c.Assert(err, jc.DeepEquals, &actions.ValidationError{
+		Action:        "backup",
+		UnknownParams: []string{"dset"},
+		MissingParams: []string{"dest"},
+		TypeMismatches: []actions.ParamTypeMismatch{
+			{Param: "compress", Expected: []string{"boolean", "string"}, Actual: "integer"},
+			{Param: "copies", Expected: []string{"integer"}, Actual: "number"},
+		},
+	})
+}
+
+func (*validateSuite) TestValidateAgainstAdditionalParams(c *gc.C) {
+	// Parameters that are not declared are allowed unless the schema
+	// says otherwise.
+	schema := charm.Actions{ActionSpecs: map[string]charm.ActionSpec{
+		"snapshot": {Params: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{"type": "string"},
+			},
+		}},
+	}}
+	err := actions.ValidateAgainst(schema, params.Action{
+		Name:       "snapshot",
+		Parameters: map[string]interface{}{"name": "daily", "keep": 7},
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (*validateSuite) TestValidateAgainstNoProperties(c *gc.C) {
+	// Schema keywords are not taken for declared parameters, and with
+	// none declared no parameter is unknown.
+	schema := charm.Actions{ActionSpecs: map[string]charm.ActionSpec{
+		"snapshot": {Params: map[string]interface{}{
+			"type":                 "object",
+			"description":          "Take a snapshot.",
+			"additionalProperties": false,
+		}},
+	}}
+	err := actions.ValidateAgainst(schema, params.Action{
+		Name:       "snapshot",
+		Parameters: map[string]interface{}{"name": "daily", "description": 3},
+	})
+	c.Assert(err, gc.IsNil)
+}
@@ -14,7 +14,6 @@ import (
 	"code.google.com/p/go.net/websocket"
 	"github.com/juju/loggo"
 	"github.com/juju/names"
-	"github.com/juju/utils"
 	"github.com/juju/utils/parallel"
 
 	"github.com/juju/juju/apiserver/params"
@@ -69,6 +68,16 @@ type State struct {
 	// certPool holds the cert pool that is used to authenticate the tls
 	// connections to the API.
 	certPool *x509.CertPool
+
+	// resumeToken holds the token sent with Login and, once logged
+	// in, the token issued by the server, if any.
+	resumeToken string
+
+	// resumable records whether Login asks for a resumable session.
+	resumable bool
+
+	// resumed records whether Login resumed an earlier session.
+	resumed bool
 }
 
 // Info encapsulates information about a server holding juju state and
@@ -97,6 +106,18 @@ type Info struct {
 	// EnvironTag holds the environ tag for the environment we are
 	// trying to connect to.
 	EnvironTag names.EnvironTag
+
+	// ResumeToken holds the token issued to an agent at an earlier
+	// login, which allows it to resume that session and keep using
+	// its watchers, if the session has not expired.
+	ResumeToken string `yaml:",omitempty"`
+
+	// Resumable asks for the agent's session to be kept by the server
+	// when the connection closes, so that a later login may resume it
+	// with the token returned by State.ResumeToken. Only a client that
+	// carries on using its existing watcher ids after resuming, rather
+	// than calling Watch again, should ask for this.
+	Resumable bool `yaml:",omitempty"`
 }
 
 // DialOpts holds configuration parameters that control the
@@ -111,8 +132,23 @@ type DialOpts struct {
 	Timeout time.Duration
 
 	// RetryDelay is the amount of time to wait between
-	// unsucssful connection attempts.
+	// unsucssful connection attempts. It is the initial delay
+	// when RetryBackoffFactor is set.
 	RetryDelay time.Duration
+
+	// RetryBackoffFactor, if greater than 1, is the factor by which
+	// the delay between connection attempts grows after each
+	// unsuccessful attempt. Otherwise the delay is always RetryDelay.
+	RetryBackoffFactor float64
+
+	// MaxRetryDelay, if non-zero, is the longest delay between
+	// connection attempts when RetryBackoffFactor is set.
+	MaxRetryDelay time.Duration
+
+	// Compress asks the state server to compress the messages
+	// sent over the connection with gzip. Messages are exchanged
+	// uncompressed if the state server does not support it.
+	Compress bool
 }
 
 // DefaultDialOpts returns a DialOpts representing the default
@@ -174,7 +210,13 @@ func Open(info *Info, opts DialOpts) (*State, error) {
 	conn := result.(*websocket.Conn)
 	logger.Infof("connection established to %q", conn.RemoteAddr())
 
-	client := rpc.NewConn(jsoncodec.NewWebsocket(conn), nil)
+	codec := jsoncodec.NewWebsocket(conn)
+	if opts.Compress {
+		// Only compress once the server has shown that it
+		// understands compressed messages by sending one.
+		codec = jsoncodec.NewCompressedWebsocket(conn, false)
+	}
+	client := rpc.NewConn(codec, nil)
 	client.Start()
 	st := &State{
 		client:     client,
@@ -183,9 +225,11 @@ func Open(info *Info, opts DialOpts) (*State, error) {
 		serverRoot: "https://" + conn.Config().Location.Host,
 		// why are the contents of the tag (username and password) written into the
 		// state structure BEFORE login ?!?
-		tag:      toString(info.Tag),
-		password: info.Password,
-		certPool: pool,
+		tag:         toString(info.Tag),
+		password:    info.Password,
+		certPool:    pool,
+		resumeToken: info.ResumeToken,
+		resumable:   info.Resumable,
 	}
 	if info.Tag != nil || info.Password != "" {
 		if err := st.Login(info.Tag.String(), info.Password, info.Nonce); err != nil {
@@ -212,6 +256,9 @@ func dialWebsocket(addr, environUUID string, opts DialOpts, rootCAs *x509.CertPo
 	if err != nil {
 		return err
 	}
+	if opts.Compress {
+		cfg.Header.Set(jsoncodec.CompressionHeader, "gzip")
+	}
 	return try.Start(newWebsocketDialer(cfg, opts))
 }
 
@@ -238,33 +285,56 @@ func setUpWebsocket(addr, environUUID string, rootCAs *x509.CertPool) (*websocke
 // newWebsocketDialer returns a function that
 // can be passed to utils/parallel.Try.Start.
 func newWebsocketDialer(cfg *websocket.Config, opts DialOpts) func(<-chan struct{}) (io.Closer, error) {
-	openAttempt := utils.AttemptStrategy{
-		Total: opts.Timeout,
-		Delay: opts.RetryDelay,
-	}
 	return func(stop <-chan struct{}) (io.Closer, error) {
-		for a := openAttempt.Start(); a.Next(); {
+		deadline := time.Now().Add(opts.Timeout)
+		delay := opts.RetryDelay
+		for {
 			select {
 			case <-stop:
 				return nil, parallel.ErrStopped
 			default:
 			}
 			logger.Infof("dialing %q", cfg.Location)
-			conn, err := websocket.DialConfig(cfg)
+			conn, err := dialConfig(cfg)
 			if err == nil {
 				return conn, nil
 			}
-			if a.HasNext() {
-				logger.Debugf("error dialing %q, will retry: %v", cfg.Location, err)
-			} else {
+			if time.Now().Add(delay).After(deadline) {
 				logger.Infof("error dialing %q: %v", cfg.Location, err)
 				return nil, fmt.Errorf("unable to connect to %q", cfg.Location)
 			}
+			logger.Debugf("error dialing %q, will retry in %v: %v", cfg.Location, delay, err)
+			select {
+			case <-stop:
+				return nil, parallel.ErrStopped
+			case <-retryAfter(delay):
+			}
+			delay = opts.nextRetryDelay(delay)
 		}
-		panic("unreachable")
 	}
 }
 
+// nextRetryDelay returns the delay to wait before the connection
+// attempt following one that was preceded by the given delay.
+func (opts DialOpts) nextRetryDelay(delay time.Duration) time.Duration {
+	if opts.RetryBackoffFactor <= 1 {
+		return delay
+	}
+	delay = time.Duration(float64(delay) * opts.RetryBackoffFactor)
+	if opts.MaxRetryDelay > 0 && delay > opts.MaxRetryDelay {
+		delay = opts.MaxRetryDelay
+	}
+	return delay
+}
+
+// dialConfig and retryAfter are called by the websocket dialer instead
+// of websocket.DialConfig and time.After so we can override them in
+// tests.
+var (
+	dialConfig = websocket.DialConfig
+	retryAfter = time.After
+)
+
 func (s *State) heartbeatMonitor() {
 	for {
 		if err := s.Ping(); err != nil {
@@ -325,6 +395,19 @@ func (s *State) Addr() string {
 	return s.addr
 }
 
+// ResumeToken returns the token issued by the server at login, which
+// may be passed in Info.ResumeToken when reconnecting to resume the
+// session. It is empty if no token was issued.
+func (s *State) ResumeToken() string {
+	return s.resumeToken
+}
+
+// Resumed reports whether the login resumed an earlier session. If
+// it did not, any watchers from that session must be started again.
+func (s *State) Resumed() bool {
+	return s.resumed
+}
+
 // EnvironTag returns the tag of the environment we are connected to.
 func (s *State) EnvironTag() (names.EnvironTag, error) {
 	return names.ParseEnvironTag(s.environTag)
@@ -8,7 +8,9 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"time"
 
+	"code.google.com/p/go.net/websocket"
 	"github.com/juju/names"
 	"github.com/juju/utils/parallel"
 	gc "gopkg.in/check.v1"
@@ -154,6 +156,20 @@ func (s *apiclientSuite) TestOpenPassesEnvironTag(c *gc.C) {
 	st.Close()
 }
 
+func (s *apiclientSuite) TestOpenCompressed(c *gc.C) {
+	st, err := api.Open(s.APIInfo(c), api.DialOpts{Compress: true})
+	c.Assert(err, gc.IsNil)
+	defer st.Close()
+
+	// Login has already made one round trip; make some more now
+	// that messages are compressed in both directions.
+	err = st.Ping()
+	c.Assert(err, gc.IsNil)
+	config, err := st.Client().EnvironmentGet()
+	c.Assert(err, gc.IsNil)
+	c.Assert(config["name"], gc.Equals, "dummyenv")
+}
+
 func (s *apiclientSuite) TestDialWebsocketStopped(c *gc.C) {
 	stopped := make(chan struct{})
 	f := api.NewWebsocketDialer(nil, api.DialOpts{})
@@ -163,6 +179,42 @@ func (s *apiclientSuite) TestDialWebsocketStopped(c *gc.C) {
 	c.Assert(result, gc.IsNil)
 }
 
+func (s *websocketSuite) TestDialWebsocketBackoff(c *gc.C) {
+	stopped := make(chan struct{})
+	attempts := 0
+	s.PatchValue(api.DialConfig, func(*websocket.Config) (*websocket.Conn, error) {
+		attempts++
+		if attempts == 5 {
+			close(stopped)
+		}
+		return nil, fmt.Errorf("connection refused")
+	})
+	var delays []time.Duration
+	s.PatchValue(api.RetryAfter, func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ready := make(chan time.Time, 1)
+		ready <- time.Now()
+		return ready
+	})
+	cfg, err := api.SetUpWebsocket("0.1.2.3:1234", "", nil)
+	c.Assert(err, gc.IsNil)
+	f := api.NewWebsocketDialer(cfg, api.DialOpts{
+		Timeout:            time.Minute,
+		RetryDelay:         10 * time.Millisecond,
+		RetryBackoffFactor: 2,
+		MaxRetryDelay:      40 * time.Millisecond,
+	})
+	_, err = f(stopped)
+	c.Assert(err, gc.Equals, parallel.ErrStopped)
+	c.Assert(attempts, gc.Equals, 5)
+	c.Assert(delays[:4], gc.DeepEquals, []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		40 * time.Millisecond,
+	})
+}
+
 func (*websocketSuite) TestSetUpWebsocketConfig(c *gc.C) {
 	conf, err := api.SetUpWebsocket("0.1.2.3:1234", "", nil)
 	c.Assert(err, gc.IsNil)
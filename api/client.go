@@ -581,6 +581,37 @@ func (c *Client) EnvironmentGet() (map[string]interface{}, error) {
 	return result.Config, err
 }
 
+// LastEnvironConfigError returns the reason the provisioner last
+// rejected the environment configuration, or an empty string if it
+// last accepted it.
+func (c *Client) LastEnvironConfigError() (string, error) {
+	var result params.StringResult
+	if err := c.facade.FacadeCall("LastEnvironConfigError", nil, &result); err != nil {
+		return "", err
+	}
+	return result.Result, nil
+}
+
+// SupportedBases returns the series and architecture combinations
+// for which the environment has tools, and so on which machines can
+// be provisioned.
+func (c *Client) SupportedBases() (params.SupportedBases, error) {
+	var result params.SupportedBases
+	err := c.facade.FacadeCall("SupportedBases", nil, &result)
+	return result, err
+}
+
+// AgentPresenceHistory returns the times at which the agent of the
+// given machine or unit connected to and disconnected from the API
+// server. If since is non-zero, only events that occurred within that
+// duration are returned.
+func (c *Client) AgentPresenceHistory(tag names.Tag, since time.Duration) (params.PresenceEvents, error) {
+	args := params.AgentPresenceHistory{Tag: tag.String(), Since: since}
+	var result params.PresenceEvents
+	err := c.facade.FacadeCall("AgentPresenceHistory", args, &result)
+	return result, err
+}
+
 // EnvironmentSet sets the given key-value pairs in the environment.
 func (c *Client) EnvironmentSet(config map[string]interface{}) error {
 	args := params.EnvironmentSet{Config: config}
@@ -11,6 +11,8 @@ import (
 var (
 	NewWebsocketDialer  = newWebsocketDialer
 	WebsocketDialConfig = &websocketDialConfig
+	DialConfig          = &dialConfig
+	RetryAfter          = &retryAfter
 	SetUpWebsocket      = setUpWebsocket
 	SlideAddressToFront = slideAddressToFront
 	BestVersion         = bestVersion
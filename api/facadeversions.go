@@ -23,7 +23,7 @@ var facadeVersions = map[string]int{
 	"Logger":               0,
 	"MetricsManager":       0,
 	"Pinger":               0,
-	"Provisioner":          0,
+	"Provisioner":          1,
 	"Reboot":               1,
 	"RelationUnitsWatcher": 0,
 	"UserManager":          0,
@@ -33,8 +33,9 @@ var facadeVersions = map[string]int{
 	"Upgrader":             0,
 	"Firewaller":           1,
 	"Rsyslog":              0,
-	"Uniter":               1,
-	"Actions":              0,
+	"Uniter":               2,
+	"Actions":              1,
+	"ActionsWatcher":       0,
 }
 
 // bestVersion tries to find the newest version in the version list that we can
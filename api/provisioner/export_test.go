@@ -4,7 +4,9 @@
 package provisioner
 
 import (
+	"github.com/juju/juju/api/base"
 	"github.com/juju/juju/api/base/testing"
+	"github.com/juju/juju/api/common"
 )
 
 // PatchFacadeCall patches the State's facade such that
@@ -13,3 +15,13 @@ import (
 func PatchFacadeCall(p testing.Patcher, st *State, f func(request string, params, response interface{}) error) {
 	testing.PatchFacadeCall(p, &st.facade, f)
 }
+
+// NewStateV0 creates a new client-side Provisioner facade pinned to
+// version 0 of the API.
+func NewStateV0(caller base.APICaller) *State {
+	facadeCaller := base.NewFacadeCallerForVersion(caller, provisionerFacade, 0)
+	return &State{
+		EnvironWatcher: common.NewEnvironWatcher(facadeCaller),
+		APIAddresser:   common.NewAPIAddresser(facadeCaller),
+		facade:         facadeCaller}
+}
@@ -4,6 +4,7 @@
 package provisioner
 
 import (
+	"github.com/juju/errors"
 	"github.com/juju/names"
 
 	"github.com/juju/juju/api/base"
@@ -33,6 +34,12 @@ func NewState(caller base.APICaller) *State {
 		facade:         facadeCaller}
 }
 
+// BestAPIVersion returns the API version that we were able to
+// determine is supported by both the client and the API Server.
+func (st *State) BestAPIVersion() int {
+	return st.facade.BestAPIVersion()
+}
+
 // machineLife requests the lifecycle of the given machine from the server.
 func (st *State) machineLife(tag names.MachineTag) (params.Life, error) {
 	return common.Life(st.facade, tag)
@@ -80,6 +87,18 @@ func (st *State) WatchMachineErrorRetry() (watcher.NotifyWatcher, error) {
 	return w, nil
 }
 
+// SetEnvironConfigError records why the environment configuration
+// could not be used, so that clients can report it. An empty reason
+// records that the configuration is usable.
+func (st *State) SetEnvironConfigError(reason string) error {
+	if st.BestAPIVersion() < 1 {
+		// SetEnvironConfigError() was introduced in ProvisionerAPIV1.
+		return errors.NotImplementedf("SetEnvironConfigError() (need V1+)")
+	}
+	args := params.SetEnvironConfigError{Reason: reason}
+	return st.facade.FacadeCall("SetEnvironConfigError", args, nil)
+}
+
 // StateAddresses returns the list of addresses used to connect to the state.
 func (st *State) StateAddresses() ([]string, error) {
 	var result params.StringsResult
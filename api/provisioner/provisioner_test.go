@@ -649,6 +649,27 @@ func (s *provisionerSuite) TestSupportsNoContainers(c *gc.C) {
 	c.Assert(containers, gc.DeepEquals, []instance.ContainerType{})
 }
 
+func (s *provisionerSuite) TestSetEnvironConfigError(c *gc.C) {
+	err := s.provisioner.SetEnvironConfigError(`no registered provider for "unknown"`)
+	c.Assert(err, gc.IsNil)
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, `no registered provider for "unknown"`)
+
+	err = s.provisioner.SetEnvironConfigError("")
+	c.Assert(err, gc.IsNil)
+	err = env.Refresh()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, "")
+}
+
+func (s *provisionerSuite) TestSetEnvironConfigErrorV0NotImplemented(c *gc.C) {
+	st := provisioner.NewStateV0(s.st)
+	err := st.SetEnvironConfigError("boom")
+	c.Assert(err, jc.Satisfies, errors.IsNotImplemented)
+	c.Assert(err.Error(), gc.Equals, "SetEnvironConfigError() (need V1+) not implemented")
+}
+
 func (s *provisionerSuite) TestFindToolsNoArch(c *gc.C) {
 	s.testFindTools(c, false, nil, nil)
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/juju/errors"
 	"github.com/juju/names"
+	"github.com/juju/utils"
 
 	"github.com/juju/juju/api/agent"
 	"github.com/juju/juju/api/base"
@@ -54,6 +55,8 @@ func (st *State) loginV1(tag, password, nonce string) error {
 			AuthTag:     tag,
 			Credentials: password,
 			Nonce:       nonce,
+			ResumeToken: st.resumeToken,
+			Resumable:   st.resumable,
 		},
 		// TODO (cmars): remove once we can drop 1.18 login compatibility
 		Creds: params.Creds{
@@ -87,6 +90,8 @@ func (st *State) loginV1(tag, password, nonce string) error {
 	if err != nil {
 		return err
 	}
+	st.resumeToken = result.LoginResultV1.ResumeToken
+	st.resumed = result.LoginResultV1.Resumed
 	return nil
 }
 
@@ -127,6 +132,9 @@ func (st *State) loginV0(tag, password, nonce string) error {
 	if err = st.setLoginResult(tag, result.EnvironTag, result.Servers, result.Facades); err != nil {
 		return err
 	}
+	// Sessions cannot be resumed with the v0 Login.
+	st.resumeToken = ""
+	st.resumed = false
 	return nil
 }
 
@@ -213,7 +221,8 @@ func (st *State) Uniter() (*uniter.State, error) {
 		logger.Warningf("ignoring invalid environ tag: %v", err)
 	}
 	charmsURL := uniter.CharmsURL(st.Addr(), envTag)
-	return uniter.NewState(st, unitTag, charmsURL), nil
+	charmsHeader := utils.BasicAuthHeader(st.tag, st.password)
+	return uniter.NewState(st, unitTag, charmsURL, charmsHeader), nil
 }
 
 // Firewaller returns a version of the state that provides functionality
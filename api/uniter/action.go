@@ -3,10 +3,16 @@
 
 package uniter
 
+import "time"
+
 // Action represents a single instance of an Action call, by name and params.
 type Action struct {
-	name   string
-	params map[string]interface{}
+	name      string
+	params    map[string]interface{}
+	attempt   int
+	notBefore time.Time
+	slot      string
+	timeout   time.Duration
 }
 
 // NewAction makes a new Action with specified name and params map.
@@ -23,3 +29,30 @@ func (a *Action) Name() string {
 func (a *Action) Params() map[string]interface{} {
 	return a.params
 }
+
+// Attempt returns the number of the current attempt to run the Action,
+// starting at 1, or zero if the state server did not report it.
+func (a *Action) Attempt() int {
+	return a.attempt
+}
+
+// NotBefore returns the time before which the Action should not be run
+// again after a failed attempt, or the zero time.
+func (a *Action) NotBefore() time.Time {
+	return a.notBefore
+}
+
+// Slot returns the name of the slot limiting how many Actions like
+// this one may run at once, or the empty string if it has none. An
+// Action with a slot must be begun before it is run, and is refused
+// with an error satisfying params.IsCodeActionSlotFull while the slot
+// is full.
+func (a *Action) Slot() string {
+	return a.slot
+}
+
+// Timeout returns how long the Action may run before it is stopped,
+// or zero if it may run for as long as it needs.
+func (a *Action) Timeout() time.Duration {
+	return a.timeout
+}
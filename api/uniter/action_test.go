@@ -4,12 +4,17 @@
 package uniter_test
 
 import (
+	"time"
+
+	"github.com/juju/errors"
 	"github.com/juju/names"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/juju/api/uniter"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/state"
+	statetesting "github.com/juju/juju/state/testing"
 )
 
 type actionSuite struct {
@@ -77,6 +82,29 @@ func (s *actionSuite) TestNewActionAndAccessors(c *gc.C) {
 	c.Assert(testParams, gc.DeepEquals, basicParams)
 }
 
+func (s *actionSuite) TestActionBegin(c *gc.C) {
+	action, err := s.uniterSuite.wordpressUnit.AddAction("gabloxi", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(action.Started().IsZero(), jc.IsTrue)
+
+	err = s.uniter.ActionBegin(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+
+	action, err = s.State.ActionByTag(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(action.Started().IsZero(), jc.IsFalse)
+}
+
+func (s *actionSuite) TestActionBeginV1NotImplemented(c *gc.C) {
+	s.patchNewState(c, uniter.NewStateV1)
+
+	action, err := s.uniterSuite.wordpressUnit.AddAction("gabloxi", nil)
+	c.Assert(err, gc.IsNil)
+	err = s.uniter.ActionBegin(action.ActionTag())
+	c.Assert(err, jc.Satisfies, errors.IsNotImplemented)
+	c.Assert(err.Error(), gc.Equals, "ActionBegin() (need V2+) not implemented")
+}
+
 func (s *actionSuite) TestActionComplete(c *gc.C) {
 	results, err := s.uniterSuite.wordpressUnit.ActionResults()
 	c.Assert(err, gc.IsNil)
@@ -99,6 +127,21 @@ func (s *actionSuite) TestActionComplete(c *gc.C) {
 	c.Assert(results[0].Name(), gc.Equals, "gabloxi")
 }
 
+func (s *actionSuite) TestActionCompleteWithUsage(c *gc.C) {
+	action, err := s.uniterSuite.wordpressUnit.AddAction("gabloxi", nil)
+	c.Assert(err, gc.IsNil)
+
+	usage := &params.ActionResourceUsage{CPUTime: 3 * time.Second, PeakMemory: 1 << 30}
+	err = s.uniter.ActionFinishWithUsage(action.ActionTag(), params.ActionCompleted, nil, "", usage)
+	c.Assert(err, gc.IsNil)
+
+	result, err := s.State.ActionResultByTag(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	got, ok := result.Usage()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(got, gc.Equals, state.ActionUsage{CPUTime: 3 * time.Second, PeakMemory: 1 << 30})
+}
+
 func (s *actionSuite) TestActionFail(c *gc.C) {
 	results, err := s.uniterSuite.wordpressUnit.ActionResults()
 	c.Assert(err, gc.IsNil)
@@ -120,3 +163,88 @@ func (s *actionSuite) TestActionFail(c *gc.C) {
 	c.Assert(res, gc.DeepEquals, map[string]interface{}{})
 	c.Assert(results[0].Name(), gc.Equals, "beebz")
 }
+
+func (s *actionSuite) TestActionFailRetried(c *gc.C) {
+	action, err := s.uniterSuite.wordpressUnit.AddActionWithOptions("beebz", nil, state.ActionOptions{
+		Retry: &state.ActionRetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     time.Minute,
+		},
+	})
+	c.Assert(err, gc.IsNil)
+
+	retrieved, err := s.uniter.Action(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(retrieved.Attempt(), gc.Equals, 1)
+	c.Assert(retrieved.NotBefore().IsZero(), jc.IsTrue)
+
+	err = s.uniter.ActionFinish(action.ActionTag(), params.ActionFailed, nil, "it failed!")
+	c.Assert(err, gc.IsNil)
+
+	// The action is queued to be run again after the backoff.
+	results, err := s.uniterSuite.wordpressUnit.ActionResults()
+	c.Assert(err, gc.IsNil)
+	c.Assert(results, gc.HasLen, 0)
+	retrieved, err = s.uniter.Action(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(retrieved.Attempt(), gc.Equals, 2)
+	c.Assert(retrieved.NotBefore().After(time.Now().Add(50*time.Second)), jc.IsTrue)
+}
+
+func (s *actionSuite) TestActionBeginSlotFull(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	first, err := s.uniterSuite.wordpressUnit.AddActionWithOptions("beebz", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	second, err := s.uniterSuite.wordpressUnit.AddActionWithOptions("beebz", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+
+	retrieved, err := s.uniter.Action(second.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(retrieved.Slot(), gc.Equals, "restore")
+
+	err = s.uniter.ActionBegin(first.ActionTag())
+	c.Assert(err, gc.IsNil)
+	err = s.uniter.ActionBegin(second.ActionTag())
+	c.Assert(err, jc.Satisfies, params.IsCodeActionSlotFull)
+
+	err = s.uniter.ActionFinish(first.ActionTag(), params.ActionCompleted, nil, "")
+	c.Assert(err, gc.IsNil)
+	err = s.uniter.ActionBegin(second.ActionTag())
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *actionSuite) TestWatchActionSlot(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	first, err := s.uniterSuite.wordpressUnit.AddActionWithOptions("beebz", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	second, err := s.uniterSuite.wordpressUnit.AddActionWithOptions("beebz", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	err = s.uniter.ActionBegin(first.ActionTag())
+	c.Assert(err, gc.IsNil)
+
+	w, err := s.uniter.WatchActionSlot(second.ActionTag())
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewNotifyWatcherC(c, s.BackingState, w)
+
+	// Initial event.
+	wc.AssertOneChange()
+
+	// The slot is released when the first action finishes.
+	err = s.uniter.ActionFinish(first.ActionTag(), params.ActionCompleted, nil, "")
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+
+	statetesting.AssertStop(c, w)
+	wc.AssertClosed()
+}
+
+func (s *actionSuite) TestWatchActionSlotV1NotImplemented(c *gc.C) {
+	s.patchNewState(c, uniter.NewStateV1)
+
+	action, err := s.uniterSuite.wordpressUnit.AddAction("gabloxi", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = s.uniter.WatchActionSlot(action.ActionTag())
+	c.Assert(err, jc.Satisfies, errors.IsNotImplemented)
+	c.Assert(err.Error(), gc.Equals, "WatchActionSlot() (need V2+) not implemented")
+}
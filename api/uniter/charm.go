@@ -5,6 +5,7 @@ package uniter
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 
@@ -63,6 +64,13 @@ func (c *Charm) ArchiveURL() *url.URL {
 	return &archiveURL
 }
 
+// ArchiveHeader returns the HTTP header, holding the unit's
+// credentials, that must be sent when fetching the charm archive
+// from ArchiveURL.
+func (c *Charm) ArchiveHeader() http.Header {
+	return c.st.charmsHeader
+}
+
 // ArchiveSha256 returns the SHA256 digest of the charm archive
 // (bundle) bytes.
 //
@@ -62,6 +62,11 @@ func (s *charmSuite) TestArchiveURL(c *gc.C) {
 	c.Assert(archiveURL, gc.DeepEquals, url)
 }
 
+func (s *charmSuite) TestArchiveHeader(c *gc.C) {
+	header := s.apiCharm.ArchiveHeader()
+	c.Assert(header.Get("Authorization"), gc.Matches, "Basic .+")
+}
+
 func (s *charmSuite) TestArchiveSha256(c *gc.C) {
 	archiveSha256, err := s.apiCharm.ArchiveSha256()
 	c.Assert(err, gc.IsNil)
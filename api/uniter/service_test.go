@@ -4,6 +4,7 @@
 package uniter_test
 
 import (
+	"net/http"
 	"net/url"
 
 	"github.com/juju/names"
@@ -138,7 +139,7 @@ func (s *serviceSuite) TestOwnerTagV1(c *gc.C) {
 
 func (s *serviceSuite) patchNewState(
 	c *gc.C,
-	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL) *uniter.State,
+	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL, _ http.Header) *uniter.State,
 ) {
 	s.uniterSuite.patchNewState(c, patchFunc)
 	var err error
@@ -5,6 +5,7 @@ package uniter_test
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -676,7 +677,7 @@ func (s *unitSuite) TestWatchMeterStatus(c *gc.C) {
 
 func (s *unitSuite) patchNewState(
 	c *gc.C,
-	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL) *uniter.State,
+	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL, _ http.Header) *uniter.State,
 ) {
 	s.uniterSuite.patchNewState(c, patchFunc)
 	var err error
@@ -5,6 +5,7 @@ package uniter
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/juju/errors"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/juju/juju/api/base"
 	"github.com/juju/juju/api/common"
+	"github.com/juju/juju/api/watcher"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/network"
 )
@@ -30,6 +32,10 @@ type State struct {
 
 	// charmsURL is the root URL used to fetch charm archives.
 	charmsURL *url.URL
+
+	// charmsHeader holds the HTTP header, including the unit's
+	// credentials, to send when fetching charm archives.
+	charmsHeader http.Header
 }
 
 // newStateForVersion creates a new client-side Uniter facade for the
@@ -38,6 +44,7 @@ func newStateForVersion(
 	caller base.APICaller,
 	authTag names.UnitTag,
 	charmsURL *url.URL,
+	charmsHeader http.Header,
 	version int,
 ) *State {
 	facadeCaller := base.NewFacadeCallerForVersion(
@@ -51,22 +58,28 @@ func newStateForVersion(
 		facade:         facadeCaller,
 		unitTag:        authTag,
 		charmsURL:      charmsURL,
+		charmsHeader:   charmsHeader,
 	}
 }
 
 // newStateV0 creates a new client-side Uniter facade, version 0.
-func newStateV0(caller base.APICaller, authTag names.UnitTag, charmsURL *url.URL) *State {
-	return newStateForVersion(caller, authTag, charmsURL, 0)
+func newStateV0(caller base.APICaller, authTag names.UnitTag, charmsURL *url.URL, charmsHeader http.Header) *State {
+	return newStateForVersion(caller, authTag, charmsURL, charmsHeader, 0)
 }
 
 // newStateV1 creates a new client-side Uniter facade, version 1.
-func newStateV1(caller base.APICaller, authTag names.UnitTag, charmsURL *url.URL) *State {
-	return newStateForVersion(caller, authTag, charmsURL, 1)
+func newStateV1(caller base.APICaller, authTag names.UnitTag, charmsURL *url.URL, charmsHeader http.Header) *State {
+	return newStateForVersion(caller, authTag, charmsURL, charmsHeader, 1)
+}
+
+// newStateV2 creates a new client-side Uniter facade, version 2.
+func newStateV2(caller base.APICaller, authTag names.UnitTag, charmsURL *url.URL, charmsHeader http.Header) *State {
+	return newStateForVersion(caller, authTag, charmsURL, charmsHeader, 2)
 }
 
 // NewState creates a new client-side Uniter facade.
 // Defined like this to allow patching during tests.
-var NewState = newStateV1
+var NewState = newStateV2
 
 // BestAPIVersion returns the API version that we were able to
 // determine is supported by both the client and the API Server.
@@ -204,14 +217,81 @@ func (st *State) Action(tag names.ActionTag) (*Action, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Action{
-		name:   result.Action.Action.Name,
-		params: result.Action.Action.Parameters,
-	}, nil
+	action := &Action{
+		name:    result.Action.Action.Name,
+		params:  result.Action.Action.Parameters,
+		attempt: result.Action.Attempt,
+		timeout: result.Action.Action.Timeout,
+	}
+	if result.Action.NotBefore != nil {
+		action.notBefore = *result.Action.NotBefore
+	}
+	if slot := result.Action.Action.Slot; slot != nil {
+		action.slot = slot.Name
+	}
+	return action, nil
+}
+
+// ActionBegin marks an action as having started running.
+func (st *State) ActionBegin(tag names.ActionTag) error {
+	if st.BestAPIVersion() < 2 {
+		// ActionBegin() was introduced in UniterAPIV2.
+		return errors.NotImplementedf("ActionBegin() (need V2+)")
+	}
+	var outcome params.ErrorResults
+	args := params.Entities{
+		Entities: []params.Entity{
+			{Tag: tag.String()},
+		},
+	}
+	err := st.facade.FacadeCall("BeginActions", args, &outcome)
+	if err != nil {
+		return err
+	}
+	if len(outcome.Results) != 1 {
+		return fmt.Errorf("expected 1 result, got %d", len(outcome.Results))
+	}
+	result := outcome.Results[0]
+	if err := result.Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// WatchActionSlot returns a watcher that notifies when the actions
+// holding the slot of the action with the given tag change.
+func (st *State) WatchActionSlot(tag names.ActionTag) (watcher.NotifyWatcher, error) {
+	if st.BestAPIVersion() < 2 {
+		// WatchActionSlot() was introduced in UniterAPIV2.
+		return nil, errors.NotImplementedf("WatchActionSlot() (need V2+)")
+	}
+	var results params.NotifyWatchResults
+	args := params.Entities{
+		Entities: []params.Entity{{Tag: tag.String()}},
+	}
+	err := st.facade.FacadeCall("WatchActionSlots", args, &results)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Results) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(results.Results))
+	}
+	result := results.Results[0]
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	w := watcher.NewNotifyWatcher(st.facade.RawAPICaller(), result)
+	return w, nil
 }
 
 // ActionFinish captures the structured output of an action.
 func (st *State) ActionFinish(tag names.ActionTag, status string, results map[string]interface{}, message string) error {
+	return st.ActionFinishWithUsage(tag, status, results, message, nil)
+}
+
+// ActionFinishWithUsage captures the structured output of an action,
+// together with the resources it consumed, if usage is not nil.
+func (st *State) ActionFinishWithUsage(tag names.ActionTag, status string, results map[string]interface{}, message string, usage *params.ActionResourceUsage) error {
 	var outcome params.ErrorResults
 
 	args := params.ActionExecutionResults{
@@ -221,6 +301,7 @@ func (st *State) ActionFinish(tag names.ActionTag, status string, results map[st
 				Status:    status,
 				Results:   results,
 				Message:   message,
+				Usage:     usage,
 			},
 		},
 	}
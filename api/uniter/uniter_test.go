@@ -4,6 +4,7 @@
 package uniter_test
 
 import (
+	"net/http"
 	"net/url"
 
 	"github.com/juju/names"
@@ -101,7 +102,7 @@ func (s *uniterSuite) assertInScope(c *gc.C, relUnit *state.RelationUnit, inScop
 
 func (s *uniterSuite) patchNewState(
 	c *gc.C,
-	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL) *uniter.State,
+	patchFunc func(_ base.APICaller, _ names.UnitTag, _ *url.URL, _ http.Header) *uniter.State,
 ) {
 	s.PatchValue(&uniter.NewState, patchFunc)
 	var err error
@@ -30,3 +30,11 @@ type RelationUnitsWatcher interface {
 	Stop() error
 	Err() error
 }
+
+// ActionsWatcher will send events when the lifecycle of an Action
+// changes. The content for the changes is a list of params.ActionEvent.
+type ActionsWatcher interface {
+	Changes() <-chan []params.ActionEvent
+	Stop() error
+	Err() error
+}
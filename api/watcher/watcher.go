@@ -290,3 +290,57 @@ func (w *relationUnitsWatcher) loop(initialChanges params.RelationUnitsChange) e
 func (w *relationUnitsWatcher) Changes() <-chan params.RelationUnitsChange {
 	return w.out
 }
+
+// actionsWatcher will send notifications of Actions being queued and
+// finishing, across all ActionReceivers in the environment.
+type actionsWatcher struct {
+	commonWatcher
+	caller           base.APICaller
+	actionsWatcherId string
+	out              chan []params.ActionEvent
+}
+
+func NewActionsWatcher(caller base.APICaller, result params.ActionsWatchResult) ActionsWatcher {
+	w := &actionsWatcher{
+		caller:           caller,
+		actionsWatcherId: result.ActionsWatcherId,
+		out:              make(chan []params.ActionEvent),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop(result.Changes))
+	}()
+	return w
+}
+
+func (w *actionsWatcher) loop(initialChanges []params.ActionEvent) error {
+	changes := initialChanges
+	w.newResult = func() interface{} { return new(params.ActionsWatchResult) }
+	w.call = makeWatcherAPICaller(w.caller, "ActionsWatcher", w.actionsWatcherId)
+	w.commonWatcher.init()
+	go w.commonLoop()
+
+	for {
+		select {
+		// Send the initial event or subsequent change.
+		case w.out <- changes:
+		case <-w.tomb.Dying():
+			return nil
+		}
+		// Read the next change.
+		data, ok := <-w.in
+		if !ok {
+			// The tomb is already killed with the correct error
+			// at this point, so just return.
+			return nil
+		}
+		changes = data.(*params.ActionsWatchResult).Changes
+	}
+}
+
+// Changes returns a channel that receives the lifecycle events of
+// the watched Actions.
+func (w *actionsWatcher) Changes() <-chan []params.ActionEvent {
+	return w.out
+}
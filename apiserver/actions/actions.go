@@ -4,18 +4,33 @@
 package actions
 
 import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/names"
 
 	"github.com/juju/juju/apiserver/common"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/rpc/rpcreflect"
 	"github.com/juju/juju/state"
+	"github.com/juju/juju/state/watcher"
 )
 
 var logger = loggo.GetLogger("juju.apiserver.actions")
 
+// actionsFacadeVersion is the latest version of the Actions facade,
+// implemented by ActionsAPIV1.
+const actionsFacadeVersion = 1
+
 func init() {
 	common.RegisterStandardFacade("Actions", 0, NewActionsAPI)
+	common.RegisterStandardFacade("Actions", actionsFacadeVersion, NewActionsAPIV1)
 }
 
 // ActionsAPI implements the client API for interacting with Actions
@@ -38,12 +53,35 @@ func NewActionsAPI(st *state.State, resources *common.Resources, authorizer comm
 	}, nil
 }
 
+// ActionsAPIV1 implements version 1 of the Actions facade. It adds
+// filtering, bulk cancellation, watching, scheduling and reporting of
+// Actions to version 0.
+type ActionsAPIV1 struct {
+	ActionsAPI
+}
+
+// NewActionsAPIV1 returns an initialized ActionsAPIV1.
+func NewActionsAPIV1(st *state.State, resources *common.Resources, authorizer common.Authorizer) (*ActionsAPIV1, error) {
+	apiV0, err := NewActionsAPI(st, resources, authorizer)
+	if err != nil {
+		return nil, err
+	}
+	return &ActionsAPIV1{
+		ActionsAPI: *apiV0,
+	}, nil
+}
+
 // Enqueue takes a list of Actions and queues them up to be executed by
 // the designated ActionReceiver, returning the params.Action for each
 // queued Action, or an error if there was a problem queueing up the
 // Action.
 func (a *ActionsAPI) Enqueue(arg params.Actions) (params.ActionResults, error) {
 	response := params.ActionResults{Results: make([]params.ActionResult, len(arg.Actions))}
+	cfg, err := a.state.EnvironConfig()
+	if err != nil {
+		return response, err
+	}
+	defaultTimeout := cfg.DefaultActionTimeout()
 	// TODO(jcw4) authorization checks
 	for i, action := range arg.Actions {
 		current := &response.Results[i]
@@ -59,7 +97,23 @@ func (a *ActionsAPI) Enqueue(arg params.Actions) (params.ActionResults, error) {
 			continue
 		}
 
-		queued, err := receiver.AddAction(action.Name, action.Parameters)
+		opts := state.ActionOptions{Timeout: action.Timeout}
+		if opts.Timeout == 0 {
+			opts.Timeout = defaultTimeout
+		}
+		if action.Retry != nil {
+			opts.Retry = &state.ActionRetryPolicy{
+				MaxAttempts: action.Retry.MaxAttempts,
+				Backoff:     action.Retry.Backoff,
+			}
+		}
+		if action.Slot != nil {
+			opts.Slot = &state.ActionSlot{
+				Name:          action.Slot.Name,
+				MaxConcurrent: action.Slot.MaxConcurrent,
+			}
+		}
+		queued, err := receiver.AddActionWithOptions(action.Name, action.Parameters, opts)
 		if err != nil {
 			current.Error = common.ServerError(err)
 			continue
@@ -69,8 +123,16 @@ func (a *ActionsAPI) Enqueue(arg params.Actions) (params.ActionResults, error) {
 			Tag:        queued.ActionTag(),
 			Name:       queued.Name(),
 			Parameters: queued.Parameters(),
+			Retry:      action.Retry,
+			Slot:       action.Slot,
+			Timeout:    queued.Timeout(),
 		}
-		current.Status = string(state.ActionPending)
+		current.Status, err = pendingStatus(queued)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Attempt = queued.Attempt()
 	}
 	return response, nil
 }
@@ -96,6 +158,85 @@ func (a *ActionsAPI) ListCompleted(arg params.Tags) (params.ActionsByReceivers,
 	return a.internalList(arg, actionReceiverToActionResults)
 }
 
+// ListFiltered returns the Actions of each of the ActionReceivers
+// named in the filter that match its name, statuses and completion
+// window, a page at a time as given by its offset and limit.
+func (a *ActionsAPIV1) ListFiltered(arg params.ActionsFilter) (params.ActionsByReceivers, error) {
+	// TODO(jcw4) authorization checks
+	statuses := make(map[string]bool)
+	for _, status := range arg.Statuses {
+		switch status {
+		case params.ActionPending, params.ActionWaitingForSlot, params.ActionCompleted,
+			params.ActionFailed, params.ActionCancelled, params.ActionAborted:
+			statuses[status] = true
+		default:
+			return params.ActionsByReceivers{}, errors.Errorf("unknown action status %q", status)
+		}
+	}
+	if arg.Offset < 0 || arg.Limit < 0 {
+		return params.ActionsByReceivers{}, errors.Errorf("offset and limit must not be negative")
+	}
+	return a.internalList(params.Tags{Tags: arg.Receivers}, func(ar state.ActionReceiver) ([]params.ActionResult, error) {
+		items, err := listFiltered(ar, arg, statuses)
+		if err != nil {
+			return nil, err
+		}
+		return page(items, arg.Offset, arg.Limit), nil
+	})
+}
+
+// listFiltered returns the Actions of the ActionReceiver that match the
+// filter, queued Actions first. If statuses is not empty, only Actions
+// with one of the statuses in it match.
+func listFiltered(ar state.ActionReceiver, filter params.ActionsFilter, statuses map[string]bool) ([]params.ActionResult, error) {
+	matches := func(name, status string) bool {
+		return (filter.Name == "" || name == filter.Name) && (len(statuses) == 0 || statuses[status])
+	}
+	items := []params.ActionResult{}
+	if len(statuses) == 0 || statuses[params.ActionPending] || statuses[params.ActionWaitingForSlot] {
+		queued, err := actionReceiverToActions(ar)
+		if err != nil {
+			return items, err
+		}
+		for _, item := range queued {
+			if matches(item.Action.Name, item.Status) {
+				items = append(items, item)
+			}
+		}
+	}
+	results, err := ar.ActionResults()
+	if err != nil {
+		return items, err
+	}
+	for _, result := range results {
+		if result == nil || !matches(result.Name(), string(result.Status())) {
+			continue
+		}
+		completed := result.Completed()
+		if filter.CompletedAfter != nil && !completed.After(*filter.CompletedAfter) {
+			continue
+		}
+		if filter.CompletedBefore != nil && !completed.Before(*filter.CompletedBefore) {
+			continue
+		}
+		items = append(items, actionResultToParams(ar, result))
+	}
+	return items, nil
+}
+
+// page returns at most limit of the items following the first offset
+// of them, or all of those following them if limit is zero.
+func page(items []params.ActionResult, offset, limit int) []params.ActionResult {
+	if offset >= len(items) {
+		return []params.ActionResult{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
 // Cancel attempts to cancel queued up Actions from running.
 func (a *ActionsAPI) Cancel(arg params.ActionTags) (params.ActionResults, error) {
 	response := params.ActionResults{Results: make([]params.ActionResult, len(arg.Actions))}
@@ -134,6 +275,325 @@ func (a *ActionsAPI) Cancel(arg params.ActionTags) (params.ActionResults, error)
 	return response, nil
 }
 
+// CancelMatching cancels every pending Action in the environment that
+// matches the given filter. The outcome for each matching Action is
+// returned; those that are running or have completed cannot be
+// cancelled, and are reported with an error.
+func (a *ActionsAPIV1) CancelMatching(arg params.ActionsFilter) (params.ActionResults, error) {
+	response := params.ActionResults{}
+	// TODO(jcw4) authorization checks
+	if arg.Name == "" && arg.OlderThan <= 0 {
+		return response, errors.Errorf("action filter must specify a name or an age")
+	}
+	var cutoff time.Time
+	if arg.OlderThan > 0 {
+		cutoff = time.Now().Add(-arg.OlderThan)
+	}
+	services, err := a.state.AllServices()
+	if err != nil {
+		return response, err
+	}
+	for _, svc := range services {
+		units, err := svc.AllUnits()
+		if err != nil {
+			return response, err
+		}
+		for _, unit := range units {
+			results, err := cancelMatching(unit, arg.Name, cutoff)
+			if err != nil {
+				return response, err
+			}
+			response.Results = append(response.Results, results...)
+		}
+	}
+	return response, nil
+}
+
+// cancelMatching cancels the pending Actions of the given
+// ActionReceiver that have the given name, if it is not empty, and
+// were queued before cutoff, if it is not zero. Running and completed
+// Actions that match are reported as not cancellable.
+func cancelMatching(receiver state.ActionReceiver, name string, cutoff time.Time) ([]params.ActionResult, error) {
+	matches := func(actionName string, enqueued time.Time) bool {
+		if name != "" && actionName != name {
+			return false
+		}
+		return cutoff.IsZero() || enqueued.Before(cutoff)
+	}
+	actions, err := receiver.Actions()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(bySequence(actions))
+	results := []params.ActionResult{}
+	for _, action := range actions {
+		if !matches(action.Name(), action.Enqueued()) {
+			continue
+		}
+		status, err := pendingStatus(action)
+		if err != nil {
+			return nil, err
+		}
+		current := actionToParams(receiver, action, status)
+		// The action may begin after it was read, so it is only
+		// cancelled if it is still pending when it is removed.
+		result, err := action.CancelPending()
+		if err == state.ErrActionRunning {
+			current.Error = common.ServerError(errors.Errorf("action %s is running and cannot be cancelled", action.ActionTag().Id()))
+		} else if err != nil {
+			current.Error = common.ServerError(err)
+		} else {
+			current = actionResultToParams(receiver, result)
+		}
+		results = append(results, current)
+	}
+	completed, err := receiver.ActionResults()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(resultsBySequence(completed))
+	for _, result := range completed {
+		if !matches(result.Name(), result.Enqueued()) {
+			continue
+		}
+		current := actionResultToParams(receiver, result)
+		current.Error = common.ServerError(errors.Errorf("action %s has completed and cannot be cancelled", result.ActionTag().Id()))
+		results = append(results, current)
+	}
+	return results, nil
+}
+
+// abortMessage is the message recorded for each running Action
+// stopped by AbortAllRunning.
+const abortMessage = "aborted by administrator"
+
+// AbortAllRunning stops every Action in the environment: each running
+// Action is marked aborted and each pending one cancelled. Only the
+// environment owner may do this.
+func (a *ActionsAPIV1) AbortAllRunning() (params.ActionResults, error) {
+	response := params.ActionResults{}
+	env, err := a.state.Environment()
+	if err != nil {
+		return response, errors.Trace(err)
+	}
+	// For gccgo interface comparisons, we need a Tag.
+	if a.authorizer.GetAuthTag() != names.Tag(env.Owner()) {
+		return response, common.ErrPerm
+	}
+	services, err := a.state.AllServices()
+	if err != nil {
+		return response, err
+	}
+	for _, svc := range services {
+		units, err := svc.AllUnits()
+		if err != nil {
+			return response, err
+		}
+		for _, unit := range units {
+			results, err := abortAll(unit)
+			if err != nil {
+				return response, err
+			}
+			response.Results = append(response.Results, results...)
+		}
+	}
+	return response, nil
+}
+
+// abortAll aborts the running Actions of the given ActionReceiver and
+// cancels its pending ones, returning the outcome for each. A running
+// Action is recorded as aborted at once; its unit is left to finish
+// running the hook, and discards the outcome when it finds the Action
+// has already been finished.
+func abortAll(receiver state.ActionReceiver) ([]params.ActionResult, error) {
+	actions, err := receiver.Actions()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(bySequence(actions))
+	results := make([]params.ActionResult, len(actions))
+	for i, action := range actions {
+		var result *state.ActionResult
+		if action.Started().IsZero() {
+			result, err = receiver.CancelAction(action)
+		} else {
+			result, err = action.Finish(state.ActionResults{
+				Status:  state.ActionAborted,
+				Message: abortMessage,
+			})
+		}
+		if err == nil {
+			results[i] = actionResultToParams(receiver, result)
+			continue
+		}
+		status, statusErr := pendingStatus(action)
+		if statusErr != nil {
+			return nil, statusErr
+		}
+		results[i] = actionToParams(receiver, action, status)
+		results[i].Error = common.ServerError(err)
+	}
+	return results, nil
+}
+
+// QueuePositions returns the zero-based position of each of the given
+// pending Actions in the queue of its ActionReceiver. An Action that
+// has already been run or cancelled results in an error.
+func (a *ActionsAPIV1) QueuePositions(arg params.ActionTags) (params.ActionQueuePositionResults, error) {
+	response := params.ActionQueuePositionResults{Results: make([]params.ActionQueuePositionResult, len(arg.Actions))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Actions {
+		current := &response.Results[i]
+		receiver, err := tagToActionReceiver(a.state, tag.PrefixTag())
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+
+		position, err := queuePosition(a.state, receiver, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Position = position
+	}
+	return response, nil
+}
+
+// queuePosition returns the position of the Action identified by tag
+// amongst the pending Actions of the given ActionReceiver, ordered by
+// the sequence in which they were queued.
+func queuePosition(st *state.State, receiver state.ActionReceiver, tag names.ActionTag) (int, error) {
+	pending, err := receiver.Actions()
+	if err != nil {
+		return 0, err
+	}
+	sort.Sort(bySequence(pending))
+	for i, action := range pending {
+		if action.ActionTag() == tag {
+			return i, nil
+		}
+	}
+	if _, err := st.ActionResultByTag(tag); err == nil {
+		return 0, errors.Errorf("action %q is not pending", tag.Id())
+	}
+	return 0, errors.NotFoundf("action %q", tag.Id())
+}
+
+// bySequence sorts Actions in the order they were queued.
+type bySequence []*state.Action
+
+func (s bySequence) Len() int           { return len(s) }
+func (s bySequence) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySequence) Less(i, j int) bool { return s[i].Sequence() < s[j].Sequence() }
+
+// WatchAllActions returns an ActionsWatcher that notifies on the
+// lifecycle of every Action in the environment, regardless of its
+// ActionReceiver.
+func (a *ActionsAPIV1) WatchAllActions() (params.ActionsWatchResult, error) {
+	watch := a.state.WatchAllActions()
+	// Consume the initial event and forward it to the result.
+	if changes, ok := <-watch.Changes(); ok {
+		return params.ActionsWatchResult{
+			ActionsWatcherId: a.resources.Register(watch),
+			Changes:          changes,
+		}, nil
+	}
+	return params.ActionsWatchResult{}, watcher.EnsureErr(watch)
+}
+
+// WatchActions returns, for each of the given Actions, a NotifyWatcher
+// that notifies when the Action begins running, and when it finishes
+// or is cancelled. Each watcher stops itself once its Action is no
+// longer queued.
+func (a *ActionsAPIV1) WatchActions(arg params.ActionTags) (params.NotifyWatchResults, error) {
+	response := params.NotifyWatchResults{Results: make([]params.NotifyWatchResult, len(arg.Actions))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Actions {
+		current := &response.Results[i]
+		watch, err := a.state.WatchAction(tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		// Consume the initial event.
+		if _, ok := <-watch.Changes(); ok {
+			current.NotifyWatcherId = a.resources.Register(watch)
+		} else {
+			current.Error = common.ServerError(watcher.EnsureErr(watch))
+		}
+	}
+	return response, nil
+}
+
+// WatchReceiverActions returns, for each of the given ActionReceivers,
+// a StringsWatcher that notifies with the ids of its Actions as they
+// are queued, begin running, and finish or are cancelled.
+func (a *ActionsAPIV1) WatchReceiverActions(arg params.Entities) (params.StringsWatchResults, error) {
+	response := params.StringsWatchResults{Results: make([]params.StringsWatchResult, len(arg.Entities))}
+	// TODO(jcw4) authorization checks
+	for i, entity := range arg.Entities {
+		current := &response.Results[i]
+		tag, err := names.ParseTag(entity.Tag)
+		if err != nil {
+			current.Error = common.ServerError(common.ErrBadId)
+			continue
+		}
+		receiver, err := tagToActionReceiver(a.state, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		watch := a.state.WatchReceiverActions(receiver)
+		// Consume the initial event and forward it to the result.
+		if changes, ok := <-watch.Changes(); ok {
+			current.StringsWatcherId = a.resources.Register(watch)
+			current.Changes = changes
+		} else {
+			current.Error = common.ServerError(watcher.EnsureErr(watch))
+		}
+	}
+	return response, nil
+}
+
+// EffectiveParams returns, for each of the given Actions, the
+// parameters it is or was run with: those it was enqueued with, with
+// any defaults declared by the charm's action spec filled in. The
+// parameters are returned in the Action of each result.
+func (a *ActionsAPIV1) EffectiveParams(arg params.ActionTags) (params.ActionResults, error) {
+	response := params.ActionResults{Results: make([]params.ActionResult, len(arg.Actions))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Actions {
+		current := &response.Results[i]
+		receiver, err := tagToActionReceiver(a.state, tag.PrefixTag())
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Action = &params.Action{Tag: tag, Receiver: receiver.Tag()}
+		if action, err := a.state.ActionByTag(tag); err == nil {
+			current.Action.Name = action.Name()
+			current.Action.Parameters = action.EffectiveParameters()
+			continue
+		} else if !errors.IsNotFound(err) {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		result, err := a.state.ActionResultByTag(tag)
+		if errors.IsNotFound(err) {
+			err = errors.NotFoundf("action %q", tag.Id())
+		}
+		if err != nil {
+			current.Action = nil
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Action.Name = result.Name()
+		current.Action.Parameters = result.EffectiveParameters()
+	}
+	return response, nil
+}
+
 // ServicesCharmActions returns a slice of charm Actions for a slice of services.
 func (a *ActionsAPI) ServicesCharmActions(args params.ServiceTags) (params.ServicesCharmActionsResults, error) {
 	result := params.ServicesCharmActionsResults{}
@@ -158,6 +618,530 @@ func (a *ActionsAPI) ServicesCharmActions(args params.ServiceTags) (params.Servi
 	return result, nil
 }
 
+// ServicesActionsYAML returns, for each of the given services, the
+// content of the actions.yaml file in the service's charm, exactly as
+// it appears in the stored charm archive.
+func (a *ActionsAPIV1) ServicesActionsYAML(args params.ServiceTags) (params.ServicesActionsYAMLResults, error) {
+	response := params.ServicesActionsYAMLResults{Results: make([]params.ServiceActionsYAMLResult, len(args.ServiceTags))}
+	// TODO(jcw4) authorization checks
+	for i, svcTag := range args.ServiceTags {
+		current := &response.Results[i]
+		current.ServiceTag = svcTag
+		svc, err := a.state.Service(svcTag.Id())
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		ch, _, err := svc.Charm()
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		data, err := readArchiveFile(a.state, ch, "actions.yaml")
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.YAML = data
+	}
+	return response, nil
+}
+
+// readArchiveFile returns the content of the named file in the stored
+// archive of the given charm. If the archive has no such file, the
+// error satisfies errors.IsNotFound.
+func readArchiveFile(st *state.State, ch *state.Charm, name string) ([]byte, error) {
+	reader, _, err := st.Storage().Get(ch.StoragePath())
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot get archive of charm %q", ch.URL())
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot read archive of charm %q", ch.URL())
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot read archive of charm %q", ch.URL())
+	}
+	for _, file := range zipReader.File {
+		if path.Clean(file.Name) != name {
+			continue
+		}
+		contents, err := file.Open()
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot read %s in charm %q", name, ch.URL())
+		}
+		defer contents.Close()
+		return ioutil.ReadAll(contents)
+	}
+	return nil, errors.NotFoundf("%s in charm %q", name, ch.URL())
+}
+
+// ServiceOutputs returns, for each of the given services, the most
+// recent result of the named Action on each of its units, keyed by
+// unit name. Units that have not run the Action are omitted.
+func (a *ActionsAPIV1) ServiceOutputs(arg params.ServiceActionOutputs) (params.ServiceActionOutputResults, error) {
+	response := params.ServiceActionOutputResults{Results: make([]params.ServiceActionOutputResult, len(arg.Outputs))}
+	// TODO(jcw4) authorization checks
+	for i, output := range arg.Outputs {
+		current := &response.Results[i]
+		svc, err := a.state.Service(output.ServiceTag.Id())
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		results, err := serviceOutput(svc, output.Name, output.Since)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Results = results
+	}
+	return response, nil
+}
+
+// serviceOutput returns the most recent result of the named Action for
+// each unit of the service. If since is non-zero, results completed
+// longer ago than since are ignored.
+func serviceOutput(svc *state.Service, name string, since time.Duration) (map[string]params.ActionResult, error) {
+	units, err := svc.AllUnits()
+	if err != nil {
+		return nil, err
+	}
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	}
+	outputs := make(map[string]params.ActionResult)
+	for _, unit := range units {
+		latest, err := latestResult(unit, name, cutoff, "")
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			continue
+		}
+		outputs[unit.Name()] = actionResultToParams(unit, latest)
+	}
+	return outputs, nil
+}
+
+// ResourceUsage returns the resources each of the given Actions
+// consumed while it ran, as captured by the unit agent. An Action that
+// has not finished, or whose usage was not captured, results in an
+// error.
+func (a *ActionsAPIV1) ResourceUsage(arg params.ActionTags) (params.ActionResourceUsageResults, error) {
+	response := params.ActionResourceUsageResults{Results: make([]params.ActionResourceUsageResult, len(arg.Actions))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Actions {
+		current := &response.Results[i]
+		usage, err := resourceUsage(a.state, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Usage = params.ActionResourceUsage{
+			CPUTime:    usage.CPUTime,
+			PeakMemory: usage.PeakMemory,
+		}
+	}
+	return response, nil
+}
+
+// resourceUsage returns the resources consumed by the given Action.
+func resourceUsage(st *state.State, tag names.ActionTag) (state.ActionUsage, error) {
+	result, err := st.ActionResultByTag(tag)
+	if errors.IsNotFound(err) {
+		if _, err := st.ActionByTag(tag); err == nil {
+			return state.ActionUsage{}, errors.Errorf("action %q has not finished", tag.Id())
+		}
+	}
+	if err != nil {
+		return state.ActionUsage{}, err
+	}
+	usage, ok := result.Usage()
+	if !ok {
+		return state.ActionUsage{}, errors.NotFoundf("resource usage of action %q", tag.Id())
+	}
+	return usage, nil
+}
+
+// LatestResults returns, for each of the given ActionReceivers, the
+// most recent completed result of the named Action. A receiver that has
+// never completed the Action results in a not found error.
+func (a *ActionsAPIV1) LatestResults(arg params.LatestActionResultArgs) (params.ActionResults, error) {
+	response := params.ActionResults{Results: make([]params.ActionResult, len(arg.Receivers))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Receivers {
+		current := &response.Results[i]
+		receiver, err := tagToActionReceiver(a.state, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		latest, err := latestResult(receiver, arg.Name, time.Time{}, state.ActionCompleted)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		if latest == nil {
+			current.Error = common.ServerError(errors.NotFoundf("result of action %q on %s", arg.Name, tag))
+			continue
+		}
+		*current = actionResultToParams(receiver, latest)
+	}
+	return response, nil
+}
+
+// MissingFor returns, for each of the given services, the units that
+// have no completed run of the named Action.
+func (a *ActionsAPIV1) MissingFor(arg params.MissingActionArgs) (params.MissingActionResults, error) {
+	response := params.MissingActionResults{Results: make([]params.MissingActionResult, len(arg.Services))}
+	// TODO(jcw4) authorization checks
+	for i, serviceTag := range arg.Services {
+		current := &response.Results[i]
+		svc, err := a.state.Service(serviceTag.Id())
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		missing, err := missingFor(svc, arg.Name)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		current.Units = params.Tags{Tags: missing}
+	}
+	return response, nil
+}
+
+// missingFor returns the tags of the units of the service that have
+// no completed run of the named Action.
+func missingFor(svc *state.Service, name string) ([]names.Tag, error) {
+	units, err := svc.AllUnits()
+	if err != nil {
+		return nil, err
+	}
+	missing := []names.Tag{}
+	for _, unit := range units {
+		completed, err := hasCompleted(unit, name)
+		if err != nil {
+			return nil, err
+		}
+		if !completed {
+			missing = append(missing, unit.Tag())
+		}
+	}
+	return missing, nil
+}
+
+// hasCompleted reports whether the named Action has completed
+// successfully on the given ActionReceiver.
+func hasCompleted(ar state.ActionReceiver, name string) (bool, error) {
+	results, err := ar.ActionResults()
+	if err != nil {
+		return false, err
+	}
+	for _, result := range results {
+		if result.Name() == name && result.Status() == state.ActionCompleted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// latestResult returns the most recent result of the named Action on
+// the given ActionReceiver, ignoring results completed before cutoff
+// and, if status is not empty, those with any other status. It returns
+// nil if there is none.
+func latestResult(ar state.ActionReceiver, name string, cutoff time.Time, status state.ActionStatus) (*state.ActionResult, error) {
+	results, err := ar.ActionResults()
+	if err != nil {
+		return nil, err
+	}
+	var latest *state.ActionResult
+	for _, result := range results {
+		if result.Name() != name || result.Completed().Before(cutoff) {
+			continue
+		}
+		if status != "" && result.Status() != status {
+			continue
+		}
+		if latest == nil || result.Sequence() > latest.Sequence() {
+			latest = result
+		}
+	}
+	return latest, nil
+}
+
+// actionResultToParams converts an ActionResult of the given
+// ActionReceiver to a params.ActionResult.
+func actionResultToParams(ar state.ActionReceiver, result *state.ActionResult) params.ActionResult {
+	output, message := result.Results()
+	return params.ActionResult{
+		Action: &params.Action{
+			Receiver:   ar.Tag(),
+			Tag:        result.ActionTag(),
+			Name:       result.Name(),
+			Parameters: result.Parameters(),
+		},
+		Status:   string(result.Status()),
+		Message:  message,
+		Output:   output,
+		Attempt:  result.Attempt(),
+		Attempts: attemptsToParams(result.Attempts()),
+	}
+}
+
+// FindByName returns the Actions with the given name on every
+// ActionReceiver in the environment, restricted to those with the
+// given status, if any, and to those queued or completed within the
+// given duration, if it is non-zero. Receivers with no such Actions
+// are omitted.
+func (a *ActionsAPIV1) FindByName(arg params.FindActionsByName) (params.ActionsByReceivers, error) {
+	response := params.ActionsByReceivers{}
+	// TODO(jcw4) authorization checks
+	switch string(arg.Status) {
+	case "", params.ActionPending, params.ActionWaitingForSlot, params.ActionCompleted,
+		params.ActionFailed, params.ActionCancelled, params.ActionAborted:
+	default:
+		return response, errors.Errorf("unknown action status %q", arg.Status)
+	}
+	var cutoff time.Time
+	if arg.Since > 0 {
+		cutoff = time.Now().Add(-arg.Since)
+	}
+	services, err := a.state.AllServices()
+	if err != nil {
+		return response, err
+	}
+	for _, svc := range services {
+		units, err := svc.AllUnits()
+		if err != nil {
+			return response, err
+		}
+		for _, unit := range units {
+			current := params.ActionsByReceiver{Receiver: unit.Tag()}
+			items, err := findByName(unit, arg.Name, string(arg.Status), cutoff)
+			if err != nil {
+				current.Error = common.ServerError(err)
+			} else if len(items) == 0 {
+				continue
+			}
+			current.Actions = items
+			response.Actions = append(response.Actions, current)
+		}
+	}
+	return response, nil
+}
+
+// findByName returns the Actions with the given name and, if it is
+// not empty, status that the ActionReceiver has queued since cutoff
+// or completed since cutoff.
+func findByName(ar state.ActionReceiver, name, status string, cutoff time.Time) ([]params.ActionResult, error) {
+	items := []params.ActionResult{}
+	queuedOnly := status == params.ActionPending || status == params.ActionWaitingForSlot
+	if status == "" || queuedOnly {
+		actions, err := ar.Actions()
+		if err != nil {
+			return items, err
+		}
+		for _, action := range actions {
+			if action == nil || action.Name() != name || action.Enqueued().Before(cutoff) {
+				continue
+			}
+			current, err := pendingStatus(action)
+			if err != nil {
+				return items, err
+			}
+			if status != "" && current != status {
+				continue
+			}
+			items = append(items, actionToParams(ar, action, current))
+		}
+		if queuedOnly {
+			return items, nil
+		}
+	}
+	results, err := ar.ActionResults()
+	if err != nil {
+		return items, err
+	}
+	for _, result := range results {
+		if result == nil || result.Name() != name || result.Completed().Before(cutoff) {
+			continue
+		}
+		if status != "" && string(result.Status()) != status {
+			continue
+		}
+		items = append(items, actionResultToParams(ar, result))
+	}
+	return items, nil
+}
+
+// attemptsToParams converts the failed attempts to run an Action to
+// params.ActionAttempts.
+func attemptsToParams(attempts []state.ActionAttempt) []params.ActionAttempt {
+	if len(attempts) == 0 {
+		return nil
+	}
+	converted := make([]params.ActionAttempt, len(attempts))
+	for i, attempt := range attempts {
+		converted[i] = params.ActionAttempt{
+			Started:   attempt.Started,
+			Completed: attempt.Completed,
+			Message:   attempt.Message,
+			Output:    attempt.Results,
+		}
+	}
+	return converted
+}
+
+// Durations returns, for each of the given ActionReceivers, how long
+// each completed run of the named Action took, from when it started
+// running to when it completed. Runs for which no start time was
+// recorded are omitted.
+func (a *ActionsAPIV1) Durations(arg params.ActionDurationArgs) (params.ActionDurationResults, error) {
+	response := params.ActionDurationResults{Results: make([]params.ActionDuration, len(arg.Receivers))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Receivers {
+		current := &response.Results[i]
+		current.Receiver = tag
+		receiver, err := tagToActionReceiver(a.state, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		results, err := receiver.ActionResults()
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		sort.Sort(resultsBySequence(results))
+		for _, result := range results {
+			if result.Name() != arg.Name || result.Status() != state.ActionCompleted {
+				continue
+			}
+			if duration, ok := result.Duration(); ok {
+				current.Durations = append(current.Durations, duration)
+			}
+		}
+	}
+	return response, nil
+}
+
+// EstimateDrains returns, for each of the given ActionReceivers, an
+// estimate of how long the Actions currently queued for it will take
+// to run, based on how long previous runs of the same Actions took.
+func (a *ActionsAPIV1) EstimateDrains(arg params.Tags) (params.ActionDrainEstimates, error) {
+	response := params.ActionDrainEstimates{Results: make([]params.ActionDrainEstimate, len(arg.Tags))}
+	// TODO(jcw4) authorization checks
+	for i, tag := range arg.Tags {
+		current := &response.Results[i]
+		current.Receiver = tag
+		receiver, err := tagToActionReceiver(a.state, tag)
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		actions, err := receiver.Actions()
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		results, err := receiver.ActionResults()
+		if err != nil {
+			current.Error = common.ServerError(err)
+			continue
+		}
+		var queued []string
+		for _, action := range actions {
+			queued = append(queued, action.Name())
+		}
+		history := make(map[string][]time.Duration)
+		for _, result := range results {
+			if result.Status() != state.ActionCompleted {
+				continue
+			}
+			if duration, ok := result.Duration(); ok {
+				history[result.Name()] = append(history[result.Name()], duration)
+			}
+		}
+		current.Duration = estimateDrain(queued, history)
+	}
+	return response, nil
+}
+
+// estimateDrain returns the expected time taken to run Actions with
+// the queued names, given the durations of previous runs of Actions
+// keyed by name. Each Action is expected to take the average duration
+// of its previous runs; an Action that has never run is expected to
+// take the average duration of all previous runs, or zero if there
+// are none.
+func estimateDrain(queued []string, history map[string][]time.Duration) time.Duration {
+	var total time.Duration
+	var count int
+	averages := make(map[string]time.Duration)
+	for name, durations := range history {
+		var sum time.Duration
+		for _, duration := range durations {
+			sum += duration
+		}
+		if len(durations) > 0 {
+			averages[name] = sum / time.Duration(len(durations))
+		}
+		total += sum
+		count += len(durations)
+	}
+	var overall time.Duration
+	if count > 0 {
+		overall = total / time.Duration(count)
+	}
+	var estimate time.Duration
+	for _, name := range queued {
+		if average, ok := averages[name]; ok {
+			estimate += average
+		} else {
+			estimate += overall
+		}
+	}
+	return estimate
+}
+
+// resultsBySequence sorts ActionResults in the order their Actions
+// were queued.
+type resultsBySequence []*state.ActionResult
+
+func (s resultsBySequence) Len() int           { return len(s) }
+func (s resultsBySequence) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s resultsBySequence) Less(i, j int) bool { return s[i].Sequence() < s[j].Sequence() }
+
+// DefaultTimeout returns the timeout Enqueue gives to Actions that do
+// not specify their own, as set by the environment's
+// default-action-timeout setting.
+func (a *ActionsAPIV1) DefaultTimeout() (params.ActionDefaultTimeout, error) {
+	cfg, err := a.state.EnvironConfig()
+	if err != nil {
+		return params.ActionDefaultTimeout{}, err
+	}
+	return params.ActionDefaultTimeout{Timeout: cfg.DefaultActionTimeout()}, nil
+}
+
+// Capabilities returns the version of the Actions facade and the
+// names of the methods it supports, so that clients can tell which
+// features are available before trying to use them.
+func (a *ActionsAPIV1) Capabilities() (params.ActionFacadeCaps, error) {
+	facadeType, err := common.Facades.GetType("Actions", actionsFacadeVersion)
+	if err != nil {
+		return params.ActionFacadeCaps{}, err
+	}
+	return params.ActionFacadeCaps{
+		Version: actionsFacadeVersion,
+		Methods: rpcreflect.ObjTypeOf(facadeType).MethodNames(),
+	}, nil
+}
+
 // internalList takes a list of Tags representing ActionReceivers and
 // returns all of the Actions the extractorFn can get out of the
 // ActionReceiver.
@@ -230,19 +1214,63 @@ func actionReceiverToActions(ar state.ActionReceiver) ([]params.ActionResult, er
 		if action == nil {
 			continue
 		}
-		items = append(items, params.ActionResult{
-			Action: &params.Action{
-				Receiver:   ar.Tag(),
-				Tag:        action.ActionTag(),
-				Name:       action.Name(),
-				Parameters: action.Parameters(),
-			},
-			Status: string(state.ActionPending),
-		})
+		status, err := pendingStatus(action)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, actionToParams(ar, action, status))
 	}
 	return items, nil
 }
 
+// actionToParams converts a queued Action of the given ActionReceiver,
+// whose status, as computed by pendingStatus, is given, to a
+// params.ActionResult.
+func actionToParams(ar state.ActionReceiver, action *state.Action, status string) params.ActionResult {
+	item := params.ActionResult{
+		Action: &params.Action{
+			Receiver:   ar.Tag(),
+			Tag:        action.ActionTag(),
+			Name:       action.Name(),
+			Parameters: action.Parameters(),
+			Timeout:    action.Timeout(),
+		},
+		Status:   status,
+		Attempt:  action.Attempt(),
+		Attempts: attemptsToParams(action.Attempts()),
+	}
+	if retry, ok := action.RetryPolicy(); ok {
+		item.Action.Retry = &params.ActionRetryPolicy{
+			MaxAttempts: retry.MaxAttempts,
+			Backoff:     retry.Backoff,
+		}
+	}
+	if slot, ok := action.Slot(); ok {
+		item.Action.Slot = &params.ActionSlot{
+			Name:          slot.Name,
+			MaxConcurrent: slot.MaxConcurrent,
+		}
+	}
+	if notBefore := action.NotBefore(); !notBefore.IsZero() {
+		item.NotBefore = &notBefore
+	}
+	return item
+}
+
+// pendingStatus returns the status of a queued Action: pending, or
+// waiting for slot if it cannot begin until other Actions give up its
+// slot.
+func pendingStatus(action *state.Action) (string, error) {
+	waiting, err := action.WaitingForSlot()
+	if err != nil {
+		return "", err
+	}
+	if waiting {
+		return params.ActionWaitingForSlot, nil
+	}
+	return string(state.ActionPending), nil
+}
+
 // actionReceiverToActionResults iterates through the ActionResults()
 // aqueued up for n ActionReceiver, and converts them to a slice of
 // aparams.Action.
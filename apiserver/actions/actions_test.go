@@ -29,7 +29,7 @@ func TestAll(t *testing.T) {
 type actionsSuite struct {
 	jujutesting.JujuConnSuite
 
-	actions    *actions.ActionsAPI
+	actions    *actions.ActionsAPIV1
 	authorizer apiservertesting.FakeAuthorizer
 	resources  *common.Resources
 
@@ -52,7 +52,7 @@ func (s *actionsSuite) SetUpTest(c *gc.C) {
 		Tag: s.AdminUserTag(c),
 	}
 	var err error
-	s.actions, err = actions.NewActionsAPI(s.State, nil, s.authorizer)
+	s.actions, err = actions.NewActionsAPIV1(s.State, nil, s.authorizer)
 	c.Assert(err, gc.IsNil)
 
 	factory := jujuFactory.NewFactory(s.State)
@@ -263,7 +263,7 @@ func (s *actionsSuite) TestListAll(c *gc.C) {
 					output := map[string]interface{}{"output": "blah, blah, blah"}
 					message := "success"
 
-					_, err = added.Finish(state.ActionResults{status, output, message})
+					_, err = added.Finish(state.ActionResults{Status: status, Results: output, Message: message})
 					c.Assert(err, gc.IsNil)
 
 					exp.Status = string(status)
@@ -326,7 +326,7 @@ func (s *actionsSuite) TestListPending(c *gc.C) {
 					output := map[string]interface{}{"output": "blah, blah, blah"}
 					message := "success"
 
-					_, err = added.Finish(state.ActionResults{status, output, message})
+					_, err = added.Finish(state.ActionResults{Status: status, Results: output, Message: message})
 					c.Assert(err, gc.IsNil)
 				} else {
 					// add expectation
@@ -396,7 +396,7 @@ func (s *actionsSuite) TestListCompleted(c *gc.C) {
 					output := map[string]interface{}{"output": "blah, blah, blah"}
 					message := "success"
 
-					_, err = added.Finish(state.ActionResults{status, output, message})
+					_, err = added.Finish(state.ActionResults{Status: status, Results: output, Message: message})
 					c.Assert(err, gc.IsNil)
 
 					// add expectation
@@ -491,6 +491,64 @@ func (s *actionsSuite) TestCancel(c *gc.C) {
 
 }
 
+func (s *actionsSuite) TestAbortAllRunningRequiresOwner(c *gc.C) {
+	action, err := s.wordpressUnit.AddAction("fakeaction", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = action.Begin()
+	c.Assert(err, gc.IsNil)
+
+	api, err := actions.NewActionsAPIV1(s.State, nil, apiservertesting.FakeAuthorizer{
+		Tag: names.NewUserTag("fred"),
+	})
+	c.Assert(err, gc.IsNil)
+	_, err = api.AbortAllRunning()
+	c.Assert(err, gc.Equals, common.ErrPerm)
+
+	// The running action is untouched.
+	_, err = s.State.ActionByTag(action.ActionTag())
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *actionsSuite) TestQueuePositions(c *gc.C) {
+	arg := params.Actions{
+		Actions: []params.Action{
+			{Receiver: s.wordpressUnit.Tag(), Name: "wp-one"},
+			{Receiver: s.wordpressUnit.Tag(), Name: "wp-two"},
+			{Receiver: s.mysqlUnit.Tag(), Name: "my-one"},
+			{Receiver: s.wordpressUnit.Tag(), Name: "wp-three"},
+		},
+	}
+	queued, err := s.actions.Enqueue(arg)
+	c.Assert(err, gc.IsNil)
+	c.Assert(queued.Results, gc.HasLen, 4)
+	for _, res := range queued.Results {
+		c.Assert(res.Error, gc.IsNil)
+	}
+
+	// Finish "wp-one" so it is no longer pending.
+	action, err := s.State.ActionByTag(queued.Results[0].Action.Tag)
+	c.Assert(err, gc.IsNil)
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+
+	tags := params.ActionTags{Actions: []names.ActionTag{
+		queued.Results[0].Action.Tag,
+		queued.Results[1].Action.Tag,
+		queued.Results[2].Action.Tag,
+		queued.Results[3].Action.Tag,
+		names.JoinActionTag("wordpress/0", 42),
+	}}
+	results, err := s.actions.QueuePositions(tags)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 5)
+
+	c.Assert(results.Results[0].Error, gc.ErrorMatches, `action ".*" is not pending`)
+	c.Assert(results.Results[1], gc.DeepEquals, params.ActionQueuePositionResult{Position: 0})
+	c.Assert(results.Results[2], gc.DeepEquals, params.ActionQueuePositionResult{Position: 0})
+	c.Assert(results.Results[3], gc.DeepEquals, params.ActionQueuePositionResult{Position: 1})
+	c.Assert(results.Results[4].Error, gc.ErrorMatches, `action ".*" not found`)
+}
+
 func (s *actionsSuite) TestServicesCharmActions(c *gc.C) {
 	actionSchemas := map[string]map[string]interface{}{
 		"outfile": map[string]interface{}{
@@ -0,0 +1,41 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package actions_test
+
+import (
+	"time"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/apiserver/actions"
+)
+
+type estimateSuite struct{}
+
+var _ = gc.Suite(&estimateSuite{})
+
+var history = map[string][]time.Duration{
+	"backup":  {10 * time.Second, 20 * time.Second, 30 * time.Second},
+	"restore": {60 * time.Second},
+}
+
+func (*estimateSuite) TestEstimateDrainEmptyQueue(c *gc.C) {
+	c.Assert(actions.EstimateDrain(nil, history), gc.Equals, time.Duration(0))
+}
+
+func (*estimateSuite) TestEstimateDrainNoHistory(c *gc.C) {
+	c.Assert(actions.EstimateDrain([]string{"backup"}, nil), gc.Equals, time.Duration(0))
+}
+
+func (*estimateSuite) TestEstimateDrain(c *gc.C) {
+	queued := []string{"backup", "restore", "backup"}
+	c.Assert(actions.EstimateDrain(queued, history), gc.Equals, 100*time.Second)
+}
+
+func (*estimateSuite) TestEstimateDrainUnknownAction(c *gc.C) {
+	// An Action that has never run is expected to take as long as
+	// the average of all previous runs.
+	queued := []string{"snapshot", "backup"}
+	c.Assert(actions.EstimateDrain(queued, history), gc.Equals, 50*time.Second)
+}
@@ -136,7 +136,14 @@ func (a *admin) doLogin(req params.LoginRequest) (params.LoginResultV1, error) {
 		isUser = true
 	}
 
+	if retryAfter, blocked := a.srv.authFailures.blocked(a.root.remoteAddr); blocked {
+		logger.Debugf("too many failed logins from %s; try again in %v", a.root.remoteAddr, retryAfter)
+		return fail, common.ErrTryAgain
+	}
 	entity, err := doCheckCreds(a.srv.state, req)
+	if err == common.ErrBadCreds {
+		a.srv.authFailures.failed(a.root.remoteAddr)
+	}
 	if err != nil {
 		if a.maintenanceInProgress() {
 			// An upgrade, restore or similar operation is in
@@ -150,6 +157,7 @@ func (a *admin) doLogin(req params.LoginRequest) (params.LoginResultV1, error) {
 		}
 		return fail, err
 	}
+	a.srv.authFailures.succeeded(a.root.remoteAddr)
 	a.root.entity = entity
 
 	if a.reqNotifier != nil {
@@ -160,6 +168,17 @@ func (a *admin) doLogin(req params.LoginRequest) (params.LoginResultV1, error) {
 	// to serve to them.
 	a.loggedIn = true
 
+	// Agents that ask for it may resume the session they had before
+	// reconnecting, in which case their watchers need not be
+	// registered again.
+	var resumed bool
+	if !isUser && req.Resumable && a.root.sessions != nil {
+		resumed, err = a.root.resumeSession(req.ResumeToken)
+		if err != nil {
+			return fail, err
+		}
+	}
+
 	if err := startPingerIfAgent(a.root, entity); err != nil {
 		return fail, err
 	}
@@ -189,10 +208,12 @@ func (a *admin) doLogin(req params.LoginRequest) (params.LoginResultV1, error) {
 	a.root.rpcConn.ServeFinder(authedApi, serverError)
 
 	return params.LoginResultV1{
-		Servers:    hostPorts,
-		EnvironTag: environ.Tag().String(),
-		Facades:    DescribeFacades(),
-		UserInfo:   maybeUserInfo,
+		Servers:     hostPorts,
+		EnvironTag:  environ.Tag().String(),
+		Facades:     DescribeFacades(),
+		UserInfo:    maybeUserInfo,
+		ResumeToken: a.root.resumeToken,
+		Resumed:     resumed,
 	}, nil
 }
 
@@ -279,11 +300,17 @@ func checkForValidMachineAgent(entity state.Entity, req params.LoginRequest) err
 // machinePinger wraps a presence.Pinger.
 type machinePinger struct {
 	*presence.Pinger
+	st  *state.State
+	tag names.Tag
 }
 
 // Stop implements Pinger.Stop() as Pinger.Kill(), needed at
 // connection closing time to properly stop the wrapped pinger.
+// The agent's disconnection is recorded in its presence history.
 func (p *machinePinger) Stop() error {
+	if err := p.st.RecordAgentPresence(p.tag, false); err != nil {
+		logger.Warningf("%v", err)
+	}
 	if err := p.Pinger.Stop(); err != nil {
 		return err
 	}
@@ -305,7 +332,11 @@ func startPingerIfAgent(root *apiHandler, entity state.Entity) error {
 		return err
 	}
 
-	root.getResources().Register(&machinePinger{pinger})
+	// Failing to record presence history is not fatal to the login.
+	if err := root.state.RecordAgentPresence(entity.Tag(), true); err != nil {
+		logger.Warningf("%v", err)
+	}
+	root.pingerId = root.getResources().Register(&machinePinger{pinger, root.state, entity.Tag()})
 	action := func() {
 		if err := root.getRpcConn().Close(); err != nil {
 			logger.Errorf("error closing the RPC connection: %v", err)
@@ -37,14 +37,29 @@ type Server struct {
 	wg                sync.WaitGroup
 	state             *state.State
 	addr              string
+	addrs             []string
 	dataDir           string
 	logDir            string
 	limiter           utils.Limiter
 	validator         LoginValidator
+	sessions          *SessionStore
+	uploads           *uploadLimiter
+	chunks            *chunkedUploads
+	maxCharmSize      int64
+	observer          RequestObserver
+	authFailures      *authFailureLimiter
 	adminApiFactories map[int]adminApiFactory
+	handlers          *handlerRegistry
 
 	mu          sync.Mutex // protects the fields that follow
 	environUUID string
+
+	// requests tracks the HTTP requests being handled, so that
+	// Shutdown can wait for them. No request is added to it once
+	// draining has been closed.
+	requestsMu sync.Mutex
+	requests   sync.WaitGroup
+	draining   chan struct{}
 }
 
 // LoginValidator functions are used to decide whether login requests
@@ -59,39 +74,106 @@ type ServerConfig struct {
 	DataDir   string
 	LogDir    string
 	Validator LoginValidator
+
+	// Sessions holds the sessions of agents whose connections have
+	// closed, so that they may resume them. It may be shared with
+	// later servers, and it is up to the caller to close it. If it
+	// is nil, sessions are not kept, and the resources of every
+	// connection are stopped when it closes.
+	Sessions *SessionStore
+
+	// MaxConcurrentUploads, if positive, limits the number of charm
+	// and tools uploads that are handled at once.
+	MaxConcurrentUploads int
+
+	// MaxCharmUploadSize is the largest charm archive, in bytes, that
+	// may be uploaded. If it is zero, defaultMaxCharmUploadSize applies.
+	MaxCharmUploadSize int64
+
+	// UploadQueueTimeout is how long an upload over the limit waits
+	// for another to finish. If it waits in vain, or if the timeout
+	// is zero, the upload is refused with 503 Service Unavailable
+	// and a Retry-After header.
+	UploadQueueTimeout time.Duration
+
+	// RequestObserver, if not nil, is informed of every RPC and
+	// HTTP request the server handles.
+	RequestObserver RequestObserver
+
+	// MaxAuthFailures, if positive, is the number of times a client
+	// address may fail to authenticate within AuthFailureWindow
+	// before its attempts are refused until the window has passed.
+	// HTTP requests are then refused with 429 Too Many Requests and
+	// a Retry-After header. Successful authentication forgets the
+	// address's failures.
+	MaxAuthFailures   int
+	AuthFailureWindow time.Duration
 }
 
 // NewServer serves the given state by accepting requests on the given
 // listener, using the given certificate and key (in PEM format) for
 // authentication.
 func NewServer(s *state.State, lis net.Listener, cfg ServerConfig) (*Server, error) {
-	logger.Infof("listening on %q", lis.Addr())
+	return NewServerMulti(s, []net.Listener{lis}, cfg)
+}
+
+// NewServerMulti is like NewServer, but accepts requests on all of the
+// given listeners, such as one for each of a controller's IPv4 and IPv6
+// addresses. The first listener is the server's primary one.
+func NewServerMulti(s *state.State, listeners []net.Listener, cfg ServerConfig) (*Server, error) {
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listeners specified")
+	}
 	tlsCert, err := tls.X509KeyPair(cfg.Cert, cfg.Key)
 	if err != nil {
 		return nil, err
 	}
-	_, listeningPort, err := net.SplitHostPort(lis.Addr().String())
+	var addrs []string
+	for _, lis := range listeners {
+		logger.Infof("listening on %q", lis.Addr())
+		addrs = append(addrs, lis.Addr().String())
+	}
+	_, listeningPort, err := net.SplitHostPort(addrs[0])
 	if err != nil {
 		return nil, err
 	}
 	srv := &Server{
-		state:     s,
-		addr:      net.JoinHostPort("localhost", listeningPort),
-		dataDir:   cfg.DataDir,
-		logDir:    cfg.LogDir,
-		limiter:   utils.NewLimiter(loginRateLimit),
-		validator: cfg.Validator,
+		state:        s,
+		addr:         net.JoinHostPort("localhost", listeningPort),
+		addrs:        addrs,
+		dataDir:      cfg.DataDir,
+		logDir:       cfg.LogDir,
+		limiter:      utils.NewLimiter(loginRateLimit),
+		validator:    cfg.Validator,
+		sessions:     cfg.Sessions,
+		uploads:      newUploadLimiter(cfg.MaxConcurrentUploads, cfg.UploadQueueTimeout),
+		chunks:       newChunkedUploads(),
+		maxCharmSize: cfg.MaxCharmUploadSize,
+		observer:     cfg.RequestObserver,
+		authFailures: newAuthFailureLimiter(cfg.MaxAuthFailures, cfg.AuthFailureWindow),
+		draining:     make(chan struct{}),
+		handlers:     newHandlerRegistry(),
 		adminApiFactories: map[int]adminApiFactory{
 			0: newAdminApiV0,
 			1: newAdminApiV1,
 		},
 	}
+	if srv.observer == nil {
+		srv.observer = NopRequestObserver{}
+	}
+	if srv.maxCharmSize == 0 {
+		srv.maxCharmSize = defaultMaxCharmUploadSize
+	}
 	// TODO(rog) check that *srvRoot is a valid type for using
 	// as an RPC server.
-	lis = tls.NewListener(lis, &tls.Config{
+	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{tlsCert},
-	})
-	go srv.run(lis)
+	}
+	tlsListeners := make([]net.Listener, len(listeners))
+	for i, lis := range listeners {
+		tlsListeners[i] = tls.NewListener(lis, tlsConfig)
+	}
+	go srv.run(tlsListeners)
 	return srv, nil
 }
 
@@ -107,6 +189,53 @@ func (srv *Server) Stop() error {
 	return srv.tomb.Wait()
 }
 
+// Shutdown stops the server gracefully. It stops accepting connections,
+// and lets the requests being handled finish, including the RPCs in
+// progress on each API connection, before closing them. Any requests
+// still unfinished after the given timeout are abandoned, as by Stop.
+func (srv *Server) Shutdown(timeout time.Duration) error {
+	srv.requestsMu.Lock()
+	select {
+	case <-srv.draining:
+	default:
+		close(srv.draining)
+	}
+	srv.requestsMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		srv.requests.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-srv.tomb.Dying():
+	case <-time.After(timeout):
+		logger.Warningf("API server requests unfinished after %v; stopping anyway", timeout)
+	}
+	return srv.Stop()
+}
+
+// trackRequests returns a handler that passes requests on to handler,
+// recording them in srv.requests. Once the server is draining, new
+// requests are refused.
+func (srv *Server) trackRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		srv.requestsMu.Lock()
+		select {
+		case <-srv.draining:
+			srv.requestsMu.Unlock()
+			http.Error(w, "API server is shutting down", http.StatusServiceUnavailable)
+			return
+		default:
+		}
+		srv.requests.Add(1)
+		srv.requestsMu.Unlock()
+		defer srv.requests.Done()
+		handler.ServeHTTP(w, req)
+	})
+}
+
 // Kill implements worker.Worker.Kill.
 func (srv *Server) Kill() {
 	srv.tomb.Kill(nil)
@@ -118,8 +247,9 @@ func (srv *Server) Wait() error {
 }
 
 type requestNotifier struct {
-	id    int64
-	start time.Time
+	id       int64
+	start    time.Time
+	observer RequestObserver
 
 	mu   sync.Mutex
 	tag_ string
@@ -127,11 +257,11 @@ type requestNotifier struct {
 
 var globalCounter int64
 
-func newRequestNotifier() *requestNotifier {
+func newRequestNotifier(observer RequestObserver) *requestNotifier {
 	return &requestNotifier{
-		id:    atomic.AddInt64(&globalCounter, 1),
-		tag_:  "<unknown>",
-		start: time.Now(),
+		id:       atomic.AddInt64(&globalCounter, 1),
+		start:    time.Now(),
+		observer: observer,
 	}
 }
 
@@ -141,26 +271,52 @@ func (n *requestNotifier) login(tag string) {
 	n.mu.Unlock()
 }
 
-func (n *requestNotifier) tag() (tag string) {
+// callerTag returns the tag of the entity that has logged in, or the
+// empty string if none has.
+func (n *requestNotifier) callerTag() (tag string) {
 	n.mu.Lock()
 	tag = n.tag_
 	n.mu.Unlock()
 	return
 }
 
+func (n *requestNotifier) tag() string {
+	if tag := n.callerTag(); tag != "" {
+		return tag
+	}
+	return "<unknown>"
+}
+
+// observed returns the description of the given RPC to pass to the
+// notifier's observer.
+func (n *requestNotifier) observed(req rpc.Request) ObservedRequest {
+	return ObservedRequest{
+		Facade:  req.Type,
+		Version: req.Version,
+		Method:  req.Action,
+		Caller:  n.callerTag(),
+	}
+}
+
 func (n *requestNotifier) ServerRequest(hdr *rpc.Header, body interface{}) {
+	n.observer.RequestStarted(n.observed(hdr.Request))
 	if hdr.Request.Type == "Pinger" && hdr.Request.Action == "Ping" {
 		return
 	}
-	// TODO(rog) 2013-10-11 remove secrets from some requests.
-	logger.Debugf("<- [%X] %s %s", n.id, n.tag(), jsoncodec.DumpRequest(hdr, body))
+	if logger.EffectiveLogLevel() <= loggo.DEBUG {
+		// TODO(rog) 2013-10-11 remove secrets from some requests.
+		logger.Debugf("<- [%X] %s %s", n.id, n.tag(), jsoncodec.DumpRequest(hdr, body))
+	}
 }
 
 func (n *requestNotifier) ServerReply(req rpc.Request, hdr *rpc.Header, body interface{}, timeSpent time.Duration) {
+	n.observer.RequestFinished(n.observed(req), timeSpent, hdr.ErrorCode)
 	if req.Type == "Pinger" && req.Action == "Ping" {
 		return
 	}
-	logger.Debugf("-> [%X] %s %s %s %s[%q].%s", n.id, n.tag(), timeSpent, jsoncodec.DumpRequest(hdr, body), req.Type, req.Id, req.Action)
+	if logger.EffectiveLogLevel() <= loggo.DEBUG {
+		logger.Debugf("-> [%X] %s %s %s %s[%q].%s", n.id, n.tag(), timeSpent, jsoncodec.DumpRequest(hdr, body), req.Type, req.Id, req.Action)
+	}
 }
 
 func (n *requestNotifier) join(req *http.Request) {
@@ -186,13 +342,22 @@ func handleAll(mux *pat.PatternServeMux, pattern string, handler http.Handler) {
 	mux.Options(pattern, handler)
 }
 
-func (srv *Server) run(lis net.Listener) {
+func (srv *Server) run(listeners []net.Listener) {
 	defer srv.tomb.Done()
+	defer func() {
+		// Nothing can complete the chunked uploads once we have gone.
+		srv.chunks.discardAll()
+	}()
 	defer srv.wg.Wait() // wait for any outstanding requests to complete.
 	srv.wg.Add(1)
 	go func() {
-		<-srv.tomb.Dying()
-		lis.Close()
+		select {
+		case <-srv.tomb.Dying():
+		case <-srv.draining:
+		}
+		for _, lis := range listeners {
+			lis.Close()
+		}
 		srv.wg.Done()
 	}()
 	srv.wg.Add(1)
@@ -208,57 +373,92 @@ func (srv *Server) run(lis net.Listener) {
 	// For backwards compatibility we register all the old paths
 	handleAll(mux, "/environment/:envuuid/log",
 		&debugLogHandler{
-			httpHandler: httpHandler{state: srv.state},
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
+			logDir:      srv.logDir},
+	)
+	handleAll(mux, "/environment/:envuuid/machine/:id/log",
+		&machineLogHandler{
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
 			logDir:      srv.logDir},
 	)
 	handleAll(mux, "/environment/:envuuid/charms",
 		&charmsHandler{
-			httpHandler: httpHandler{state: srv.state},
-			dataDir:     srv.dataDir},
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
+			dataDir:     srv.dataDir,
+			uploads:     srv.uploads,
+			chunks:      srv.chunks,
+			maxSize:     srv.maxCharmSize},
 	)
 	// TODO: We can switch from handleAll to mux.Post/Get/etc for entries
 	// where we only want to support specific request methods. However, our
 	// tests currently assert that errors come back as application/json and
 	// pat only does "text/plain" responses.
 	handleAll(mux, "/environment/:envuuid/tools",
-		&toolsUploadHandler{toolsHandler{
-			httpHandler{state: srv.state},
-		}},
+		&toolsUploadHandler{
+			toolsHandler: toolsHandler{httpHandler{state: srv.state, authFailures: srv.authFailures}},
+			uploads:      srv.uploads,
+		},
 	)
 	handleAll(mux, "/environment/:envuuid/tools/:version",
 		&toolsDownloadHandler{toolsHandler{
-			httpHandler{state: srv.state},
+			httpHandler{state: srv.state, authFailures: srv.authFailures},
 		}},
 	)
 	handleAll(mux, "/environment/:envuuid/api", http.HandlerFunc(srv.apiHandler))
 	// For backwards compatibility we register all the old paths
 	handleAll(mux, "/log",
 		&debugLogHandler{
-			httpHandler: httpHandler{state: srv.state},
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
+			logDir:      srv.logDir},
+	)
+	handleAll(mux, "/machine/:id/log",
+		&machineLogHandler{
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
 			logDir:      srv.logDir},
 	)
 	handleAll(mux, "/charms",
 		&charmsHandler{
-			httpHandler: httpHandler{state: srv.state},
-			dataDir:     srv.dataDir},
+			httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
+			dataDir:     srv.dataDir,
+			uploads:     srv.uploads,
+			chunks:      srv.chunks,
+			maxSize:     srv.maxCharmSize},
 	)
 	handleAll(mux, "/tools",
-		&toolsUploadHandler{toolsHandler{
-			httpHandler{state: srv.state},
-		}},
+		&toolsUploadHandler{
+			toolsHandler: toolsHandler{httpHandler{state: srv.state, authFailures: srv.authFailures}},
+			uploads:      srv.uploads,
+		},
 	)
 	handleAll(mux, "/tools/:version",
 		&toolsDownloadHandler{toolsHandler{
-			httpHandler{state: srv.state},
+			httpHandler{state: srv.state, authFailures: srv.authFailures},
 		}},
 	)
 	handleAll(mux, "/", http.HandlerFunc(srv.apiHandler))
-	// The error from http.Serve is not interesting.
-	http.Serve(lis, mux)
+	handler := srv.handlers.serve(mux)
+	if _, unobserved := srv.observer.(NopRequestObserver); !unobserved {
+		handler = observeHTTP(srv.observer, handler)
+	}
+	handler = srv.trackRequests(handler)
+	// The errors from http.Serve are not interesting. The primary
+	// listener is served until the server is stopped, and any others
+	// alongside it.
+	for _, lis := range listeners[1:] {
+		srv.wg.Add(1)
+		go func(lis net.Listener) {
+			http.Serve(lis, handler)
+			srv.wg.Done()
+		}(lis)
+	}
+	http.Serve(listeners[0], handler)
+	// The listeners are closed when the server starts draining, but
+	// it has not finished until it is stopped.
+	<-srv.tomb.Dying()
 }
 
 func (srv *Server) apiHandler(w http.ResponseWriter, req *http.Request) {
-	reqNotifier := newRequestNotifier()
+	reqNotifier := newRequestNotifier(srv.observer)
 	reqNotifier.join(req)
 	defer reqNotifier.leave()
 	wsServer := websocket.Server{
@@ -274,7 +474,10 @@ func (srv *Server) apiHandler(w http.ResponseWriter, req *http.Request) {
 			}
 			envUUID := req.URL.Query().Get(":envuuid")
 			logger.Tracef("got a request for env %q", envUUID)
-			if err := srv.serveConn(conn, reqNotifier, envUUID); err != nil {
+			// Compress messages only if the client asked for it, so
+			// that older clients are unaffected.
+			compress := req.Header.Get(jsoncodec.CompressionHeader) == "gzip"
+			if err := srv.serveConn(conn, reqNotifier, envUUID, compress, req.RemoteAddr); err != nil {
 				logger.Errorf("error serving RPCs: %v", err)
 			}
 		},
@@ -282,11 +485,20 @@ func (srv *Server) apiHandler(w http.ResponseWriter, req *http.Request) {
 	wsServer.ServeHTTP(w, req)
 }
 
-// Addr returns the address that the server is listening on.
+// Addr returns the address that the server's primary listener is
+// listening on, as localhost and the port.
 func (srv *Server) Addr() string {
 	return srv.addr
 }
 
+// Addrs returns the addresses that the server's listeners are
+// listening on, as reported by each listener, the primary one first.
+// Unlike Addr, they tell apart listeners that share a port, such as
+// those for a controller's IPv4 and IPv6 addresses.
+func (srv *Server) Addrs() []string {
+	return append([]string(nil), srv.addrs...)
+}
+
 func (srv *Server) validateEnvironUUID(envUUID string) error {
 	if envUUID == "" {
 		// We allow the environUUID to be empty for 2 cases
@@ -331,13 +543,17 @@ func (srv *Server) setEnvironUUID(uuid string) {
 	srv.environUUID = uuid
 }
 
-func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifier, envUUID string) error {
+func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifier, envUUID string, compress bool, remoteAddr string) error {
 	codec := jsoncodec.NewWebsocket(wsConn)
+	if compress {
+		codec = jsoncodec.NewCompressedWebsocket(wsConn, true)
+	}
 	if loggo.GetLogger("juju.rpc.jsoncodec").EffectiveLogLevel() <= loggo.TRACE {
 		codec.SetLogging(true)
 	}
 	var notifier rpc.RequestNotifier
-	if logger.EffectiveLogLevel() <= loggo.DEBUG {
+	_, unobserved := srv.observer.(NopRequestObserver)
+	if logger.EffectiveLogLevel() <= loggo.DEBUG || !unobserved {
 		// Incur request monitoring overhead only if we
 		// know we'll need it.
 		notifier = reqNotifier
@@ -349,6 +565,9 @@ func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifie
 	if err = srv.validateEnvironUUID(envUUID); err == nil {
 		h, err = newApiHandler(srv, conn, reqNotifier)
 	}
+	if err == nil {
+		h.remoteAddr = remoteAddr
+	}
 	if err != nil {
 		conn.Serve(&errRoot{err}, serverError)
 	} else {
@@ -362,6 +581,9 @@ func (srv *Server) serveConn(wsConn *websocket.Conn, reqNotifier *requestNotifie
 	select {
 	case <-conn.Dead():
 	case <-srv.tomb.Dying():
+	case <-srv.draining:
+		// Closing the connection lets the requests being served
+		// complete first.
 	}
 	return conn.Close()
 }
@@ -0,0 +1,175 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAuthFailures is the number of failed authentication
+	// attempts from a single address that the machine agent allows
+	// within DefaultAuthFailureWindow.
+	DefaultMaxAuthFailures = 10
+
+	// DefaultAuthFailureWindow is the period over which the machine
+	// agent counts failed authentication attempts.
+	DefaultAuthFailureWindow = time.Minute
+
+	// maxAuthFailureSources bounds the number of addresses whose
+	// failed authentication attempts are counted at once.
+	maxAuthFailureSources = 10000
+
+	// statusTooManyRequests is the HTTP status code for a client
+	// that has made too many requests (RFC 6585).
+	statusTooManyRequests = 429
+)
+
+// authFailures counts the failed authentication attempts from a single
+// address within the window beginning at start.
+type authFailures struct {
+	start time.Time
+	count int
+}
+
+// authFailureLimiter refuses authentication attempts from addresses
+// that have recently failed to authenticate too often. A nil
+// *authFailureLimiter imposes no limit.
+type authFailureLimiter struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*authFailures
+}
+
+// newAuthFailureLimiter returns an authFailureLimiter that refuses
+// attempts from an address once max attempts from it have failed
+// within window. If max is not positive, it returns nil.
+func newAuthFailureLimiter(max int, window time.Duration) *authFailureLimiter {
+	if max <= 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = DefaultAuthFailureWindow
+	}
+	return &authFailureLimiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string]*authFailures),
+	}
+}
+
+// blocked reports whether authentication attempts from the given remote
+// address should be refused and, if so, how long the client should wait
+// before trying again.
+func (l *authFailureLimiter) blocked(remoteAddr string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[remoteHost(remoteAddr)]
+	if !ok || f.count < l.max {
+		return 0, false
+	}
+	retryAfter := f.start.Add(l.window).Sub(time.Now())
+	if retryAfter <= 0 {
+		return 0, false
+	}
+	return retryAfter, true
+}
+
+// failed records a failed authentication attempt from the given remote
+// address.
+func (l *authFailureLimiter) failed(remoteAddr string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host := remoteHost(remoteAddr)
+	now := time.Now()
+	f, ok := l.failures[host]
+	if ok && now.Sub(f.start) >= l.window {
+		f.start, f.count = now, 0
+	}
+	if !ok {
+		l.makeRoom(now)
+		f = &authFailures{start: now}
+		l.failures[host] = f
+	}
+	f.count++
+}
+
+// succeeded records a successful authentication attempt from the given
+// remote address, forgetting its failures.
+func (l *authFailureLimiter) succeeded(remoteAddr string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, remoteHost(remoteAddr))
+}
+
+// makeRoom ensures that the failures of another address can be
+// counted without exceeding maxAuthFailureSources, first by forgetting
+// those whose windows have passed, and then those whose windows began
+// earliest. It must be called with l.mu held.
+func (l *authFailureLimiter) makeRoom(now time.Time) {
+	if len(l.failures) < maxAuthFailureSources {
+		return
+	}
+	var oldestHost string
+	var oldest time.Time
+	for host, f := range l.failures {
+		if now.Sub(f.start) >= l.window {
+			delete(l.failures, host)
+			continue
+		}
+		if oldestHost == "" || f.start.Before(oldest) {
+			oldestHost, oldest = host, f.start
+		}
+	}
+	if len(l.failures) >= maxAuthFailureSources {
+		delete(l.failures, oldestHost)
+	}
+}
+
+// remoteHost returns the host part of the given remote address, so
+// that failures are counted per client machine rather than per
+// connection.
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// authRateLimitedError is returned when an authentication attempt is
+// refused because too many have failed recently.
+type authRateLimitedError struct {
+	retryAfter time.Duration
+}
+
+// Error implements error.
+func (e *authRateLimitedError) Error() string {
+	return fmt.Sprintf("too many failed authentication attempts; try again in %v", e.retryAfter)
+}
+
+// sendAuthRateLimited tells the client that it has failed to
+// authenticate too often, and when to try again.
+func sendAuthRateLimited(w http.ResponseWriter, sender errorSender, err *authRateLimitedError) {
+	// Round up, so that the client does not retry too soon.
+	seconds := int((err.retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	sender.sendError(w, statusTooManyRequests, err.Error())
+}
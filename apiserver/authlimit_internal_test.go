@@ -0,0 +1,83 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// This is an internal package test.
+
+package apiserver
+
+import (
+	"fmt"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/testing"
+)
+
+type authLimitSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&authLimitSuite{})
+
+func (s *authLimitSuite) TestNilLimiterNeverBlocks(c *gc.C) {
+	l := newAuthFailureLimiter(0, time.Minute)
+	c.Assert(l, gc.IsNil)
+	for i := 0; i < 3; i++ {
+		l.failed("1.2.3.4:1234")
+	}
+	_, blocked := l.blocked("1.2.3.4:1234")
+	c.Assert(blocked, jc.IsFalse)
+}
+
+func (s *authLimitSuite) TestBlocksAfterMaxFailures(c *gc.C) {
+	l := newAuthFailureLimiter(2, time.Minute)
+	l.failed("1.2.3.4:1234")
+	_, blocked := l.blocked("1.2.3.4:1234")
+	c.Assert(blocked, jc.IsFalse)
+
+	// Failures are counted per host, whatever the port.
+	l.failed("1.2.3.4:5678")
+	retryAfter, blocked := l.blocked("1.2.3.4:9999")
+	c.Assert(blocked, jc.IsTrue)
+	c.Assert(retryAfter > 0 && retryAfter <= time.Minute, jc.IsTrue)
+
+	// Other hosts are unaffected.
+	_, blocked = l.blocked("5.6.7.8:1234")
+	c.Assert(blocked, jc.IsFalse)
+}
+
+func (s *authLimitSuite) TestSuccessForgetsFailures(c *gc.C) {
+	l := newAuthFailureLimiter(2, time.Minute)
+	l.failed("1.2.3.4:1234")
+	l.succeeded("1.2.3.4:1234")
+	l.failed("1.2.3.4:1234")
+	_, blocked := l.blocked("1.2.3.4:1234")
+	c.Assert(blocked, jc.IsFalse)
+}
+
+func (s *authLimitSuite) TestWindowExpires(c *gc.C) {
+	l := newAuthFailureLimiter(1, time.Millisecond)
+	l.failed("1.2.3.4:1234")
+	time.Sleep(2 * time.Millisecond)
+	_, blocked := l.blocked("1.2.3.4:1234")
+	c.Assert(blocked, jc.IsFalse)
+
+	// A failure after the window has passed starts a new one.
+	l.failed("1.2.3.4:1234")
+	_, blocked = l.blocked("1.2.3.4:1234")
+	c.Assert(blocked, jc.IsTrue)
+}
+
+func (s *authLimitSuite) TestBounded(c *gc.C) {
+	l := newAuthFailureLimiter(1, time.Minute)
+	for i := 0; i < maxAuthFailureSources+10; i++ {
+		l.failed(fmt.Sprintf("10.%d.%d.%d:1234", i>>16&255, i>>8&255, i&255))
+	}
+	c.Assert(l.failures, gc.HasLen, maxAuthFailureSources)
+
+	// The most recent failures are still counted.
+	_, blocked := l.blocked("10.0.39.25:1234")
+	c.Assert(blocked, jc.IsTrue)
+}
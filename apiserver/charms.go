@@ -8,11 +8,11 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path"
@@ -20,6 +20,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/juju/errors"
 	ziputil "github.com/juju/utils/zip"
@@ -33,6 +34,9 @@ import (
 type charmsHandler struct {
 	httpHandler
 	dataDir string
+	uploads *uploadLimiter
+	chunks  *chunkedUploads
+	maxSize int64
 }
 
 // bundleContentSenderFunc functions are responsible for sending a
@@ -47,22 +51,123 @@ func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "POST":
-		if err := h.authenticate(r); err != nil {
-			h.authError(w, h)
+		// Only users and environment managers may upload charms.
+		if err := h.authorize(r, isUserOrEnvironManager); err != nil {
+			h.authError(w, h, err)
+			return
+		}
+		if !h.uploads.acquire() {
+			sendUploadsBusy(w, h)
+			return
+		}
+		defer h.uploads.release()
+		if !h.limitBody(w, r) {
 			return
 		}
 		// Add a local charm to the store provider.
 		// Requires a "series" query specifying the series to use for the charm.
-		charmURL, err := h.processPost(r)
+		var closed <-chan bool
+		if notifier, ok := w.(http.CloseNotifier); ok {
+			closed = notifier.CloseNotify()
+		}
+		query := r.URL.Query()
+		if query.Get("chunked") == "true" {
+			// Begin an upload whose archive is sent in chunks with PUT.
+			id, err := h.chunks.start()
+			if err != nil {
+				h.sendError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			h.sendJSON(w, http.StatusOK, &params.CharmsResponse{UploadId: id})
+			return
+		}
+		if id := query.Get("upload"); id != "" {
+			// Complete an upload whose chunks have all been sent.
+			response, err := h.processChunkedPost(id, query.Get("series"))
+			if errors.IsNotFound(err) {
+				h.sendError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			if err != nil {
+				h.sendError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			h.sendUploadResponse(w, r, response)
+			return
+		}
+		if query.Get("batch") == "true" {
+			results, err := h.processBatchPost(r, closed)
+			if err == errUploadCanceled {
+				logger.Infof("charm batch upload from %s canceled", r.RemoteAddr)
+				return
+			}
+			if err != nil {
+				h.sendError(w, uploadErrorStatus(err), err.Error())
+				return
+			}
+			h.sendJSON(w, http.StatusOK, results)
+			return
+		}
+		response, err := h.processPost(r, closed)
+		if err == errUploadCanceled {
+			// The client has gone away, so there is nobody to respond to.
+			logger.Infof("charm upload from %s canceled", r.RemoteAddr)
+			return
+		}
 		if err != nil {
-			h.sendError(w, http.StatusBadRequest, err.Error())
+			h.sendError(w, uploadErrorStatus(err), err.Error())
+			return
+		}
+		h.sendUploadResponse(w, r, response)
+	case "PUT":
+		// Add a chunk to an upload begun with a chunked POST. Requires
+		// an "upload" query holding the upload id, and a Content-Range
+		// header locating the chunk in the archive.
+		if err := h.authorize(r, isUserOrEnvironManager); err != nil {
+			h.authError(w, h, err)
+			return
+		}
+		if !h.uploads.acquire() {
+			sendUploadsBusy(w, h)
 			return
 		}
-		h.sendJSON(w, http.StatusOK, &params.CharmsResponse{CharmURL: charmURL.String()})
+		defer h.uploads.release()
+		if !h.limitBody(w, r) {
+			return
+		}
+		var closed <-chan bool
+		if notifier, ok := w.(http.CloseNotifier); ok {
+			closed = notifier.CloseNotify()
+		}
+		id := r.URL.Query().Get("upload")
+		received, err := h.processPut(r, id, closed)
+		if err == errUploadCanceled {
+			logger.Infof("charm upload chunk from %s canceled", r.RemoteAddr)
+			return
+		}
+		response := &params.CharmsResponse{UploadId: id, Received: received}
+		statusCode := http.StatusOK
+		if err != nil {
+			response.Error = err.Error()
+			if err == errChunkOutOfOrder {
+				statusCode = http.StatusRequestedRangeNotSatisfiable
+			} else {
+				statusCode = uploadErrorStatus(err)
+			}
+		}
+		h.sendJSON(w, statusCode, response)
 	case "GET":
-		// Retrieve or list charm files.
-		// Requires "url" (charm URL) and an optional "file" (the path to the
-		// charm file) to be included in the query.
+		// Retrieve a charm archive, one of its files, or its file list.
+		// Requires "url" (charm URL) and an optional "file" (the path to
+		// the charm file) to be included in the query. Without "file",
+		// the whole archive is sent, unless "manifest=true" asks for the
+		// list of charm files instead. Users, environment managers and
+		// unit agents, which fetch the charms they deploy, may download
+		// charms.
+		if err := h.authorize(r, isUserEnvironManagerOrUnit); err != nil {
+			h.authError(w, h, err)
+			return
+		}
 		if charmArchivePath, filePath, err := h.processGet(r); err != nil {
 			// An error occurred retrieving the charm bundle.
 			if errors.IsNotFound(err) {
@@ -70,10 +175,10 @@ func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			} else {
 				h.sendError(w, http.StatusBadRequest, err.Error())
 			}
-		} else if filePath == "" {
+		} else if r.URL.Query().Get("manifest") == "true" {
 			// The client requested the list of charm files.
 			sendBundleContent(w, r, charmArchivePath, h.manifestSender)
-		} else if filePath == "*" {
+		} else if filePath == "" || filePath == "*" {
 			// The client requested the archive.
 			sendBundleContent(w, r, charmArchivePath, h.archiveSender)
 		} else {
@@ -85,16 +190,17 @@ func (h *charmsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// sendJSON sends a JSON-encoded response to the client.
-func (h *charmsHandler) sendJSON(w http.ResponseWriter, statusCode int, response *params.CharmsResponse) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	body, err := json.Marshal(response)
-	if err != nil {
-		return err
+// sendUploadResponse sends the response to a successful charm upload.
+// The response is JSON-encoded unless the client only accepts plain
+// text, in which case just the charm URL is sent.
+func (h *charmsHandler) sendUploadResponse(w http.ResponseWriter, r *http.Request, response *params.CharmsResponse) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Accept")); err == nil && mediaType == "text/plain" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, response.CharmURL)
+		return
 	}
-	w.Write(body)
-	return nil
+	h.sendJSON(w, http.StatusOK, response)
 }
 
 // sendBundleContent uses the given bundleContentSenderFunc to send a response
@@ -173,9 +279,26 @@ func (h *charmsHandler) archiveEntrySender(filePath string) bundleContentSenderF
 }
 
 // archiveSender is a bundleContentSenderFunc which is responsible for sending
-// the contents of the given charm bundle.
+// the contents of the given charm bundle. Range requests are honoured, so
+// that clients can resume an interrupted download from a given offset.
 func (h *charmsHandler) archiveSender(w http.ResponseWriter, r *http.Request, bundle *charm.CharmArchive) {
-	http.ServeFile(w, r, bundle.Path)
+	archive, err := os.Open(bundle.Path)
+	if err != nil {
+		http.Error(
+			w, fmt.Sprintf("unable to read archive in %q: %v", bundle.Path, err),
+			http.StatusInternalServerError)
+		return
+	}
+	defer archive.Close()
+	fileInfo, err := archive.Stat()
+	if err != nil {
+		http.Error(
+			w, fmt.Sprintf("unable to read archive in %q: %v", bundle.Path, err),
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), archive)
 }
 
 // sendError sends a JSON-encoded error response.
@@ -185,8 +308,118 @@ func (h *charmsHandler) sendError(w http.ResponseWriter, statusCode int, message
 	}
 }
 
+// errUploadCanceled is returned when a client disconnects before its
+// upload has been completely read.
+var errUploadCanceled = errors.New("upload canceled")
+
+// cancelableReader wraps a Reader, failing any read made after cancel
+// has been closed or has received a value.
+type cancelableReader struct {
+	io.Reader
+	cancel <-chan bool
+}
+
+// Read implements io.Reader.
+func (r *cancelableReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.cancel:
+		return 0, errUploadCanceled
+	default:
+	}
+	n, err := r.Reader.Read(p)
+	if _, ok := err.(*uploadTooLargeError); ok {
+		return n, err
+	}
+	if err != nil && err != io.EOF {
+		// A failed read of a request body means the client has
+		// disconnected, or is otherwise unable to complete its
+		// upload; either way, there is no point continuing.
+		logger.Debugf("charm upload read failed: %v", err)
+		return n, errUploadCanceled
+	}
+	return n, err
+}
+
+// defaultMaxCharmUploadSize is the largest charm archive, in bytes,
+// that may be uploaded unless the server is configured otherwise.
+const defaultMaxCharmUploadSize = 200 << 20
+
+// uploadTooLargeError is returned when an upload is larger than the
+// maximum allowed.
+type uploadTooLargeError struct {
+	max int64
+}
+
+// Error implements error.
+func (e *uploadTooLargeError) Error() string {
+	return fmt.Sprintf("charm upload exceeds the maximum size of %d bytes", e.max)
+}
+
+// isUploadTooLarge reports whether err is an *uploadTooLargeError.
+func isUploadTooLarge(err error) bool {
+	_, ok := err.(*uploadTooLargeError)
+	return ok
+}
+
+// uploadErrorStatus returns the HTTP status code with which to report
+// the given upload failure.
+func uploadErrorStatus(err error) int {
+	switch {
+	case isUploadTooLarge(err):
+		return http.StatusRequestEntityTooLarge
+	case errors.IsNotFound(err):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// sizeLimitedReader wraps a Reader, failing with an *uploadTooLargeError
+// when more than max bytes are read from it.
+type sizeLimitedReader struct {
+	io.ReadCloser
+	max       int64
+	remaining int64
+}
+
+// Read implements io.Reader.
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	// Read one byte more than remains, so that a body of exactly
+	// the maximum size is not mistaken for one that exceeds it.
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if int64(n) > r.remaining {
+		return int(r.remaining), &uploadTooLargeError{r.max}
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// limitBody restricts the request body to the handler's maximum upload
+// size. If the request declares a larger body, it responds with 413
+// Request Entity Too Large without reading it, and returns false.
+func (h *charmsHandler) limitBody(w http.ResponseWriter, r *http.Request) bool {
+	if r.ContentLength > h.maxSize {
+		err := &uploadTooLargeError{h.maxSize}
+		h.sendError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return false
+	}
+	// The http.MaxBytesReader stops the server reading any more of
+	// an oversized body than it needs to, and closes the connection
+	// once the response has been sent.
+	r.Body = &sizeLimitedReader{
+		ReadCloser: http.MaxBytesReader(w, r.Body, h.maxSize+1),
+		max:        h.maxSize,
+		remaining:  h.maxSize,
+	}
+	return true
+}
+
 // processPost handles a charm upload POST request after authentication.
-func (h *charmsHandler) processPost(r *http.Request) (*charm.URL, error) {
+// If closed is signalled before the uploaded archive has been completely
+// read, the upload is abandoned and errUploadCanceled is returned.
+func (h *charmsHandler) processPost(r *http.Request, closed <-chan bool) (*params.CharmsResponse, error) {
 	query := r.URL.Query()
 	series := query.Get("series")
 	if series == "" {
@@ -203,14 +436,143 @@ func (h *charmsHandler) processPost(r *http.Request) (*charm.URL, error) {
 	}
 	defer tempFile.Close()
 	defer os.Remove(tempFile.Name())
-	if _, err := io.Copy(tempFile, r.Body); err != nil {
+	body := &cancelableReader{Reader: r.Body, cancel: closed}
+	if _, err := io.Copy(tempFile, body); err == errUploadCanceled || isUploadTooLarge(err) {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("error processing file upload: %v", err)
 	}
-	err = h.processUploadedArchive(tempFile.Name())
+	return h.processArchive(tempFile.Name(), series)
+}
+
+// processPut handles a PUT request carrying a chunk of the upload with
+// the given id, after authentication. It returns the number of bytes
+// of the upload received so far.
+func (h *charmsHandler) processPut(r *http.Request, id string, closed <-chan bool) (int64, error) {
+	if id == "" {
+		return 0, fmt.Errorf("expected upload=UploadId argument")
+	}
+	start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, err
+	}
+	if total > h.maxSize {
+		return 0, &uploadTooLargeError{h.maxSize}
+	}
+	body := &cancelableReader{Reader: r.Body, cancel: closed}
+	return h.chunks.write(id, start, end, total, body)
+}
+
+// processChunkedPost completes the chunked upload with the given id,
+// storing its archive as a local charm for the given series.
+func (h *charmsHandler) processChunkedPost(id, series string) (*params.CharmsResponse, error) {
+	if series == "" {
+		return nil, fmt.Errorf("expected series=URL argument")
+	}
+	path, err := h.chunks.finish(id)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	return h.processArchive(path, series)
+}
+
+// maxBatchArchives holds the maximum number of archives from a single
+// batch upload that are processed at once. The whole batch counts as
+// one upload against the handler's upload limit.
+const maxBatchArchives = 4
+
+// processBatchPost handles a POST request carrying several charm
+// archives as the parts of a multipart/form-data body. Every archive
+// is read into a temporary file, and the archives are then validated
+// and stored, at most maxBatchArchives at once. A failure to store one archive is recorded
+// in its result, and does not affect the others; the results are
+// returned in the order the archives were uploaded.
+func (h *charmsHandler) processBatchPost(r *http.Request, closed <-chan bool) ([]params.CharmsResponse, error) {
+	series := r.URL.Query().Get("series")
+	if series == "" {
+		return nil, fmt.Errorf("expected series=URL argument")
+	}
+	mediaType, mediaParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, fmt.Errorf("expected Content-Type: multipart/form-data, got: %v", r.Header.Get("Content-Type"))
+	}
+	body := &cancelableReader{Reader: r.Body, cancel: closed}
+	reader := multipart.NewReader(body, mediaParams["boundary"])
+	var paths []string
+	defer func() {
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err == errUploadCanceled || isUploadTooLarge(err) {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("error processing file upload: %v", err)
+		}
+		path, err := saveUploadedPart(part)
+		if path != "" {
+			paths = append(paths, path)
+		}
+		if err == errUploadCanceled || isUploadTooLarge(err) {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("error processing file upload: %v", err)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("expected at least one uploaded file")
+	}
+	results := make([]params.CharmsResponse, len(paths))
+	slots := make(chan struct{}, maxBatchArchives)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			response, err := h.processArchive(path, series)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i] = *response
+		}(i, path)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// saveUploadedPart copies the contents of part into a new temporary
+// file, and returns the file's path. The path is returned whenever
+// the file was created, so that the caller can remove it.
+func saveUploadedPart(part *multipart.Part) (string, error) {
+	defer part.Close()
+	tempFile, err := ioutil.TempFile("", "charm")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temp file: %v", err)
+	}
+	defer tempFile.Close()
+	_, err = io.Copy(tempFile, part)
+	return tempFile.Name(), err
+}
+
+// processArchive validates the charm archive at path, which must have
+// been uploaded by an authenticated client, and stores it in the
+// environment as a local charm for the given series. The response
+// holds the charm's URL, and the SHA256 hash and size of the stored
+// archive.
+func (h *charmsHandler) processArchive(path, series string) (*params.CharmsResponse, error) {
+	err := h.processUploadedArchive(path)
 	if err != nil {
 		return nil, err
 	}
-	archive, err := charm.ReadCharmArchive(tempFile.Name())
+	archive, err := charm.ReadCharmArchive(path)
 	if err != nil {
 		return nil, fmt.Errorf("invalid charm archive: %v", err)
 	}
@@ -227,12 +589,16 @@ func (h *charmsHandler) processPost(r *http.Request) (*charm.URL, error) {
 	}
 	// Now we need to repackage it with the reserved URL, upload it to
 	// provider storage and update the state.
-	err = h.repackageAndUploadCharm(archive, preparedURL)
+	archiveSHA256, size, err := h.repackageAndUploadCharm(archive, preparedURL)
 	if err != nil {
 		return nil, err
 	}
 	// All done.
-	return preparedURL, nil
+	return &params.CharmsResponse{
+		CharmURL: preparedURL.String(),
+		Sha256:   archiveSHA256,
+		Size:     size,
+	}, nil
 }
 
 // processUploadedArchive opens the given charm archive from path,
@@ -324,12 +690,13 @@ func (d byDepth) Less(i, j int) bool { return depth(d[i]) < depth(d[j]) }
 
 // repackageAndUploadCharm expands the given charm archive to a
 // temporary directoy, repackages it with the given curl's revision,
-// then uploads it to storage, and finally updates the state.
-func (h *charmsHandler) repackageAndUploadCharm(archive *charm.CharmArchive, curl *charm.URL) error {
+// then uploads it to storage, and finally updates the state. It
+// returns the SHA256 hash and size of the stored archive.
+func (h *charmsHandler) repackageAndUploadCharm(archive *charm.CharmArchive, curl *charm.URL) (string, int64, error) {
 	// Create a temp dir to contain the extracted charm dir.
 	tempDir, err := ioutil.TempDir("", "charm-download")
 	if err != nil {
-		return errors.Annotate(err, "cannot create temp directory")
+		return "", 0, errors.Annotate(err, "cannot create temp directory")
 	}
 	defer os.RemoveAll(tempDir)
 	extractPath := filepath.Join(tempDir, "extracted")
@@ -337,11 +704,11 @@ func (h *charmsHandler) repackageAndUploadCharm(archive *charm.CharmArchive, cur
 	// Expand and repack it with the revision specified by curl.
 	archive.SetRevision(curl.Revision)
 	if err := archive.ExpandTo(extractPath); err != nil {
-		return errors.Annotate(err, "cannot extract uploaded charm")
+		return "", 0, errors.Annotate(err, "cannot extract uploaded charm")
 	}
 	charmDir, err := charm.ReadCharmDir(extractPath)
 	if err != nil {
-		return errors.Annotate(err, "cannot read extracted charm")
+		return "", 0, errors.Annotate(err, "cannot read extracted charm")
 	}
 
 	// Bundle the charm and calculate its sha256 hash at the same time.
@@ -349,19 +716,25 @@ func (h *charmsHandler) repackageAndUploadCharm(archive *charm.CharmArchive, cur
 	hash := sha256.New()
 	err = charmDir.ArchiveTo(io.MultiWriter(hash, &repackagedArchive))
 	if err != nil {
-		return errors.Annotate(err, "cannot repackage uploaded charm")
+		return "", 0, errors.Annotate(err, "cannot repackage uploaded charm")
 	}
 	bundleSHA256 := hex.EncodeToString(hash.Sum(nil))
 
+	size := int64(repackagedArchive.Len())
+
 	// Store the charm archive in environment storage.
-	return client.StoreCharmArchive(
+	err = client.StoreCharmArchive(
 		h.state,
 		curl,
 		archive,
 		&repackagedArchive,
-		int64(repackagedArchive.Len()),
+		size,
 		bundleSHA256,
 	)
+	if err != nil {
+		return "", 0, err
+	}
+	return bundleSHA256, size, nil
 }
 
 // processGet handles a charm file GET request after authentication.
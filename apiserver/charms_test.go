@@ -9,10 +9,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils"
@@ -20,9 +23,11 @@ import (
 	"gopkg.in/juju/charm.v4"
 	charmtesting "gopkg.in/juju/charm.v4/testing"
 
+	"github.com/juju/juju/apiserver"
 	"github.com/juju/juju/apiserver/params"
 	jujutesting "github.com/juju/juju/juju/testing"
 	"github.com/juju/juju/state"
+	coretesting "github.com/juju/juju/testing"
 	"github.com/juju/juju/testing/factory"
 )
 
@@ -110,21 +115,26 @@ func (s *charmsSuite) TestPOSTRequiresAuth(c *gc.C) {
 	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
 }
 
-func (s *charmsSuite) TestGETDoesNotRequireAuth(c *gc.C) {
+func (s *charmsSuite) TestGETRequiresAuth(c *gc.C) {
 	resp, err := s.sendRequest(c, "", "", "GET", s.charmsURI(c, ""), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected url=CharmURL query argument")
+	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
 }
 
-func (s *charmsSuite) TestRequiresPOSTorGET(c *gc.C) {
-	resp, err := s.authRequest(c, "PUT", s.charmsURI(c, ""), "", nil)
+func (s *charmsSuite) TestGETBadCredentials(c *gc.C) {
+	resp, err := s.sendRequest(c, s.userTag, "wrong", "GET", s.charmsURI(c, ""), "", nil)
 	c.Assert(err, gc.IsNil)
-	s.assertErrorResponse(c, resp, http.StatusMethodNotAllowed, `unsupported method: "PUT"`)
+	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
 }
 
-func (s *charmsSuite) TestAuthRequiresUser(c *gc.C) {
-	// Add a machine and try to login.
-	machine, err := s.State.AddMachine("quantal", state.JobHostUnits)
+func (s *charmsSuite) TestRequiresPOSTPUTorGET(c *gc.C) {
+	resp, err := s.authRequest(c, "DELETE", s.charmsURI(c, ""), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusMethodNotAllowed, `unsupported method: "DELETE"`)
+}
+
+func (s *authHttpSuite) addMachine(c *gc.C, job state.MachineJob) (*state.Machine, string) {
+	machine, err := s.State.AddMachine("quantal", job)
 	c.Assert(err, gc.IsNil)
 	err = machine.SetProvisioned("foo", "fake_nonce", nil)
 	c.Assert(err, gc.IsNil)
@@ -132,10 +142,21 @@ func (s *charmsSuite) TestAuthRequiresUser(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	err = machine.SetPassword(password)
 	c.Assert(err, gc.IsNil)
+	return machine, password
+}
 
-	resp, err := s.sendRequest(c, machine.Tag().String(), password, "POST", s.charmsURI(c, ""), "", nil)
+func (s *charmsSuite) TestPOSTBadCredentials(c *gc.C) {
+	resp, err := s.sendRequest(c, s.userTag, "wrong", "POST", s.charmsURI(c, ""), "", nil)
 	c.Assert(err, gc.IsNil)
 	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
+}
+
+func (s *charmsSuite) TestAuthRequiresUser(c *gc.C) {
+	// Add a machine without permission to upload charms and try to login.
+	machine, password := s.addMachine(c, state.JobHostUnits)
+	resp, err := s.sendRequest(c, machine.Tag().String(), password, "POST", s.charmsURI(c, ""), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusForbidden, "forbidden")
 
 	// Now try a user login.
 	resp, err = s.authRequest(c, "POST", s.charmsURI(c, ""), "", nil)
@@ -166,6 +187,337 @@ func (s *charmsSuite) TestUploadFailsWithInvalidZip(c *gc.C) {
 	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected Content-Type: application/zip, got: application/octet-stream")
 }
 
+func (s *charmsSuite) TestUploadCanceled(c *gc.C) {
+	// The upload is stored in a temp file while it is processed, so
+	// arrange for it to be created somewhere we can watch.
+	tempDir := c.MkDir()
+	s.PatchEnvironment("TMPDIR", tempDir)
+
+	body, bodyWriter := io.Pipe()
+	req, err := http.NewRequest("POST", s.charmsURI(c, "?series=quantal"), body)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Content-Type", s.archiveContentType)
+	done := make(chan error, 1)
+	go func() {
+		resp, err := utils.GetNonValidatingHTTPClient().Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	// Send the start of the upload, and wait for the server to begin
+	// storing it.
+	_, err = bodyWriter.Write(make([]byte, 64*1024))
+	c.Assert(err, gc.IsNil)
+	assertTempFileCount(c, tempDir, 1)
+
+	// Abandon the upload part way through; the server should stop
+	// reading it and remove the temp file promptly.
+	bodyWriter.CloseWithError(fmt.Errorf("upload abandoned"))
+	assertTempFileCount(c, tempDir, 0)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.ErrorMatches, ".*upload abandoned")
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for request to finish")
+	}
+}
+
+// assertTempFileCount waits for dir to contain count files, failing
+// if it does not do so in a reasonable time.
+func assertTempFileCount(c *gc.C, dir string, count int) {
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		infos, err := ioutil.ReadDir(dir)
+		c.Assert(err, gc.IsNil)
+		if len(infos) == count {
+			return
+		}
+	}
+	c.Fatalf("timed out waiting for %d files in %q", count, dir)
+}
+
+// startLimitedServer starts an API server that handles at most max
+// uploads at once, and returns the URI to which charms may be uploaded.
+func (s *charmsSuite) startLimitedServer(c *gc.C, max int, wait time.Duration) string {
+	_, uri := s.startServer(c, apiserver.ServerConfig{
+		MaxConcurrentUploads: max,
+		UploadQueueTimeout:   wait,
+	})
+	return uri
+}
+
+// startServer starts an API server with the given configuration, to
+// which a certificate, key and data directory are added, and returns
+// it with the URI to which charms may be uploaded.
+func (s *charmsSuite) startServer(c *gc.C, cfg apiserver.ServerConfig) (*apiserver.Server, string) {
+	cfg.Cert = []byte(coretesting.ServerCert)
+	cfg.Key = []byte(coretesting.ServerKey)
+	cfg.DataDir = c.MkDir()
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	srv, err := apiserver.NewServer(s.State, listener, cfg)
+	c.Assert(err, gc.IsNil)
+	s.AddCleanup(func(*gc.C) { srv.Stop() })
+	// We have to use 'localhost' because that is what the TLS cert says.
+	_, port, err := net.SplitHostPort(srv.Addr())
+	c.Assert(err, gc.IsNil)
+	return srv, fmt.Sprintf("https://localhost:%s/charms?series=quantal", port)
+}
+
+// startStalledUpload starts uploading a charm to uri, sending only the
+// start of it. The returned writer completes the request when closed,
+// and the returned channel receives the outcome.
+func (s *charmsSuite) startStalledUpload(c *gc.C, uri string) (*io.PipeWriter, <-chan error) {
+	body, bodyWriter := io.Pipe()
+	req, err := http.NewRequest("POST", uri, body)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Content-Type", s.archiveContentType)
+	done := make(chan error, 1)
+	go func() {
+		resp, err := utils.GetNonValidatingHTTPClient().Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	_, err = bodyWriter.Write(make([]byte, 64*1024))
+	c.Assert(err, gc.IsNil)
+	return bodyWriter, done
+}
+
+func (s *charmsSuite) TestConcurrentUploadsLimited(c *gc.C) {
+	tempDir := c.MkDir()
+	s.PatchEnvironment("TMPDIR", tempDir)
+	uri := s.startLimitedServer(c, 2, 0)
+
+	// Occupy both places with uploads that have yet to finish.
+	var writers []*io.PipeWriter
+	var dones []<-chan error
+	for i := 0; i < 2; i++ {
+		writer, done := s.startStalledUpload(c, uri)
+		writers = append(writers, writer)
+		dones = append(dones, done)
+	}
+	assertTempFileCount(c, tempDir, 2)
+
+	// Further uploads are refused.
+	for i := 0; i < 3; i++ {
+		resp, err := s.uploadRequest(c, uri, true, "")
+		c.Assert(err, gc.IsNil)
+		c.Check(resp.Header.Get("Retry-After"), gc.Equals, "10")
+		s.assertErrorResponse(c, resp, http.StatusServiceUnavailable, "too many concurrent uploads; try again later")
+	}
+
+	// Once an upload finishes, another is accepted.
+	writers[0].CloseWithError(fmt.Errorf("upload abandoned"))
+	select {
+	case <-dones[0]:
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for request to finish")
+	}
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		resp, err := s.uploadRequest(c, uri, true, "")
+		c.Assert(err, gc.IsNil)
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			resp.Body.Close()
+			continue
+		}
+		s.assertErrorResponse(c, resp, http.StatusBadRequest, "cannot open charm archive: zip: not a valid zip file")
+		break
+	}
+	writers[1].Close()
+}
+
+func (s *charmsSuite) TestConcurrentUploadsQueued(c *gc.C) {
+	uri := s.startLimitedServer(c, 1, coretesting.LongWait)
+	writer, done := s.startStalledUpload(c, uri)
+
+	// An upload over the limit waits until the first finishes.
+	queued := make(chan *http.Response, 1)
+	go func() {
+		resp, err := s.uploadRequest(c, uri, true, "")
+		c.Check(err, gc.IsNil)
+		queued <- resp
+	}()
+	select {
+	case <-queued:
+		c.Fatalf("upload over the limit was not queued")
+	case <-time.After(coretesting.ShortWait):
+	}
+	writer.CloseWithError(fmt.Errorf("upload abandoned"))
+	<-done
+	select {
+	case resp := <-queued:
+		c.Assert(resp, gc.NotNil)
+		c.Assert(resp.StatusCode, gc.Not(gc.Equals), http.StatusServiceUnavailable)
+		resp.Body.Close()
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("queued upload never handled")
+	}
+}
+
+func (s *charmsSuite) TestUploadTooLargeRejectedUpFront(c *gc.C) {
+	_, uri := s.startServer(c, apiserver.ServerConfig{MaxCharmUploadSize: 1024})
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	resp, err := s.uploadRequest(c, uri, true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusRequestEntityTooLarge, "charm upload exceeds the maximum size of 1024 bytes")
+}
+
+func (s *charmsSuite) TestUploadTooLargeRejectedWhileStreaming(c *gc.C) {
+	tempDir := c.MkDir()
+	s.PatchEnvironment("TMPDIR", tempDir)
+	_, uri := s.startServer(c, apiserver.ServerConfig{MaxCharmUploadSize: 1024})
+
+	// Send more than the limit without declaring the body's length,
+	// and without finishing the body.
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	req, err := http.NewRequest("POST", uri, body)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Content-Type", s.archiveContentType)
+	go bodyWriter.Write(make([]byte, 4096))
+
+	// The upload is refused without waiting for the rest of it, and
+	// what was received is discarded.
+	resp, err := utils.GetNonValidatingHTTPClient().Do(req)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusRequestEntityTooLarge, "charm upload exceeds the maximum size of 1024 bytes")
+	assertTempFileCount(c, tempDir, 0)
+}
+
+func (s *charmsSuite) TestShutdownWaitsForUploads(c *gc.C) {
+	tempDir := c.MkDir()
+	s.PatchEnvironment("TMPDIR", tempDir)
+	srv, uri := s.startServer(c, apiserver.ServerConfig{})
+	writer, done := s.startStalledUpload(c, uri)
+	assertTempFileCount(c, tempDir, 1)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown(coretesting.LongWait)
+	}()
+	select {
+	case <-shutdown:
+		c.Fatalf("shutdown did not wait for the upload")
+	case <-time.After(coretesting.ShortWait):
+	}
+
+	// No new uploads are accepted meanwhile.
+	resp, err := s.uploadRequest(c, uri, true, "")
+	if err == nil {
+		// The request went over a connection that was already open.
+		c.Check(resp.StatusCode, gc.Equals, http.StatusServiceUnavailable)
+		resp.Body.Close()
+	}
+
+	// Once the upload finishes, so does the shutdown.
+	writer.Close()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for upload to finish")
+	}
+	select {
+	case err := <-shutdown:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for shutdown")
+	}
+}
+
+func (s *charmsSuite) TestShutdownTimesOut(c *gc.C) {
+	srv, uri := s.startServer(c, apiserver.ServerConfig{})
+	writer, _ := s.startStalledUpload(c, uri)
+	defer writer.CloseWithError(fmt.Errorf("upload abandoned"))
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown(coretesting.ShortWait)
+	}()
+	select {
+	case err := <-shutdown:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("shutdown did not give up waiting for the upload")
+	}
+}
+
+func (s *charmsSuite) TestAuthFailuresRateLimited(c *gc.C) {
+	_, uri := s.startServer(c, apiserver.ServerConfig{
+		MaxAuthFailures:   2,
+		AuthFailureWindow: time.Hour,
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := s.sendRequest(c, s.userTag, "wrong", "POST", uri, "", nil)
+		c.Assert(err, gc.IsNil)
+		s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
+	}
+
+	// Further attempts are refused, even with the right password.
+	resp, err := s.authRequest(c, "POST", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Header.Get("Retry-After"), gc.Matches, "[0-9]+")
+	s.assertErrorResponse(c, resp, 429, "too many failed authentication attempts; try again in .*")
+}
+
+func (s *charmsSuite) TestAuthSuccessResetsFailures(c *gc.C) {
+	_, uri := s.startServer(c, apiserver.ServerConfig{
+		MaxAuthFailures:   2,
+		AuthFailureWindow: time.Hour,
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := s.sendRequest(c, s.userTag, "wrong", "POST", uri, "", nil)
+		c.Assert(err, gc.IsNil)
+		s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
+		resp, err = s.authRequest(c, "POST", uri, "", nil)
+		c.Assert(err, gc.IsNil)
+		s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected Content-Type: application/zip.*")
+	}
+}
+
+func (s *charmsSuite) TestGETAllowsEnvironManagerAndUnit(c *gc.C) {
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	_, err := s.uploadRequest(
+		c, s.charmsURI(c, "?series=quantal"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	uri := s.charmsURI(c, "?url=local:quantal/dummy-1&file=revision")
+
+	machine, password := s.addMachine(c, state.JobManageEnviron)
+	resp, err := s.sendRequest(c, machine.Tag().String(), password, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertGetFileResponse(c, resp, "1", "text/plain; charset=utf-8")
+
+	unit := s.Factory.MakeUnit(c, nil)
+	unitPassword, err := utils.RandomPassword()
+	c.Assert(err, gc.IsNil)
+	err = unit.SetPassword(unitPassword)
+	c.Assert(err, gc.IsNil)
+	resp, err = s.sendRequest(c, unit.Tag().String(), unitPassword, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertGetFileResponse(c, resp, "1", "text/plain; charset=utf-8")
+}
+
+func (s *charmsSuite) TestGETRejectsHostUnitsMachine(c *gc.C) {
+	machine, password := s.addMachine(c, state.JobHostUnits)
+	uri := s.charmsURI(c, "?url=local:quantal/dummy-1&file=revision")
+	resp, err := s.sendRequest(c, machine.Tag().String(), password, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusForbidden, "forbidden")
+}
+
+func (s *charmsSuite) TestAuthAllowsEnvironManager(c *gc.C) {
+	machine, password := s.addMachine(c, state.JobManageEnviron)
+	resp, err := s.sendRequest(c, machine.Tag().String(), password, "POST", s.charmsURI(c, ""), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected series=URL argument")
+}
+
 func (s *charmsSuite) TestUploadBumpsRevision(c *gc.C) {
 	// Add the dummy charm with revision 1.
 	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
@@ -191,6 +543,131 @@ func (s *charmsSuite) TestUploadBumpsRevision(c *gc.C) {
 	c.Assert(sch.BundleSha256(), gc.Not(gc.Equals), "")
 }
 
+func (s *charmsSuite) TestBatchUploadStoresEachCharm(c *gc.C) {
+	dummy := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	wordpress := charmtesting.Charms.CharmArchive(c.MkDir(), "wordpress")
+	invalid, err := ioutil.TempFile(c.MkDir(), "charm")
+	c.Assert(err, gc.IsNil)
+	invalid.Close()
+
+	resp, err := s.batchUploadRequest(c, s.charmsURI(c, "?series=quantal&batch=true"),
+		dummy.Path, invalid.Name(), wordpress.Path,
+	)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	var results []params.CharmsResponse
+	err = json.Unmarshal(body, &results)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results, gc.HasLen, 3)
+	c.Assert(results[0].CharmURL, gc.Equals, fmt.Sprintf("local:quantal/dummy-%d", dummy.Revision()))
+	c.Assert(results[1], gc.DeepEquals, params.CharmsResponse{
+		Error: "cannot open charm archive: zip: not a valid zip file",
+	})
+	c.Assert(results[2].CharmURL, gc.Equals, fmt.Sprintf("local:quantal/wordpress-%d", wordpress.Revision()))
+
+	// The failed upload did not prevent the others being stored.
+	for _, result := range []params.CharmsResponse{results[0], results[2]} {
+		c.Assert(result.Error, gc.Equals, "")
+		sch, err := s.State.Charm(charm.MustParseURL(result.CharmURL))
+		c.Assert(err, gc.IsNil)
+		c.Assert(sch.IsUploaded(), jc.IsTrue)
+		c.Assert(result.Sha256, gc.Equals, sch.BundleSha256())
+	}
+}
+
+func (s *charmsSuite) TestBatchUploadLargerThanLimit(c *gc.C) {
+	var paths []string
+	for i := 0; i < apiserver.MaxBatchArchives*2+1; i++ {
+		invalid, err := ioutil.TempFile(c.MkDir(), "charm")
+		c.Assert(err, gc.IsNil)
+		invalid.Close()
+		paths = append(paths, invalid.Name())
+	}
+	dummy := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	paths = append(paths, dummy.Path)
+
+	resp, err := s.batchUploadRequest(c, s.charmsURI(c, "?series=quantal&batch=true"), paths...)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	var results []params.CharmsResponse
+	err = json.Unmarshal(body, &results)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results, gc.HasLen, len(paths))
+	for _, result := range results[:len(paths)-1] {
+		c.Assert(result.Error, gc.Equals, "cannot open charm archive: zip: not a valid zip file")
+	}
+	c.Assert(results[len(paths)-1].CharmURL, gc.Equals, fmt.Sprintf("local:quantal/dummy-%d", dummy.Revision()))
+}
+
+func (s *charmsSuite) TestBatchUploadRequiresMultipart(c *gc.C) {
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	resp, err := s.uploadRequest(c, s.charmsURI(c, "?series=quantal&batch=true"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected Content-Type: multipart/form-data, got: application/zip")
+}
+
+func (s *charmsSuite) TestBatchUploadRequiresFiles(c *gc.C) {
+	resp, err := s.batchUploadRequest(c, s.charmsURI(c, "?series=quantal&batch=true"))
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, "expected at least one uploaded file")
+}
+
+func (s *charmsSuite) TestChunkedUpload(c *gc.C) {
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	data, err := ioutil.ReadFile(ch.Path)
+	c.Assert(err, gc.IsNil)
+	id := s.startChunkedUpload(c)
+	half := len(data) / 2
+
+	resp, err := s.chunkRequest(c, id, data, 0, half)
+	c.Assert(err, gc.IsNil)
+	s.assertChunkResponse(c, resp, http.StatusOK, id, half)
+
+	// Resending a chunk is refused, reporting where to resume from.
+	resp, err = s.chunkRequest(c, id, data, 0, half)
+	c.Assert(err, gc.IsNil)
+	s.assertChunkResponse(c, resp, http.StatusRequestedRangeNotSatisfiable, id, half)
+
+	// The upload cannot be completed before all its chunks arrive.
+	resp, err = s.authRequest(c, "POST", s.charmsURI(c, "?series=quantal&upload="+id), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest,
+		fmt.Sprintf(`upload %q is incomplete: received %d of %d bytes`, id, half, len(data)),
+	)
+
+	resp, err = s.chunkRequest(c, id, data, half, len(data))
+	c.Assert(err, gc.IsNil)
+	s.assertChunkResponse(c, resp, http.StatusOK, id, len(data))
+
+	resp, err = s.authRequest(c, "POST", s.charmsURI(c, "?series=quantal&upload="+id), "", nil)
+	c.Assert(err, gc.IsNil)
+	expectedURL := charm.MustParseURL(fmt.Sprintf("local:quantal/dummy-%d", ch.Revision()))
+	s.assertUploadResponse(c, resp, expectedURL.String())
+	sch, err := s.State.Charm(expectedURL)
+	c.Assert(err, gc.IsNil)
+	c.Assert(sch.IsUploaded(), jc.IsTrue)
+
+	// Once completed, the upload is gone.
+	resp, err = s.authRequest(c, "POST", s.charmsURI(c, "?series=quantal&upload="+id), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusNotFound, fmt.Sprintf(`upload %q not found`, id))
+}
+
+func (s *charmsSuite) TestChunkedUploadRequiresContentRange(c *gc.C) {
+	id := s.startChunkedUpload(c)
+	resp, err := s.authRequest(c, "PUT", s.charmsURI(c, "?upload="+id), "", bytes.NewReader([]byte("foo")))
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusBadRequest, `invalid Content-Range ""`)
+}
+
+func (s *charmsSuite) TestChunkedUploadExpires(c *gc.C) {
+	s.PatchValue(apiserver.ChunkedUploadTimeout, time.Duration(0))
+	id := s.startChunkedUpload(c)
+	resp, err := s.chunkRequest(c, id, []byte("foo"), 0, 3)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusNotFound, fmt.Sprintf(`upload %q not found`, id))
+}
+
 func (s *charmsSuite) TestUploadRespectsLocalRevision(c *gc.C) {
 	// Make a dummy charm dir with revision 123.
 	dir := charmtesting.Charms.ClonedDir(c.MkDir(), "dummy")
@@ -233,6 +710,43 @@ func (s *charmsSuite) TestUploadRespectsLocalRevision(c *gc.C) {
 	c.Assert(downloadedSHA256, gc.Equals, expectedSHA256)
 }
 
+func (s *charmsSuite) TestUploadReturnsArchiveSHA256AndSize(c *gc.C) {
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	resp, err := s.uploadRequest(c, s.charmsURI(c, "?series=quantal"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	charmResponse := jsonResponse(c, body)
+	c.Assert(charmResponse.Error, gc.Equals, "")
+
+	// The hash and size are those of the archive that was stored.
+	sch, err := s.State.Charm(charm.MustParseURL(charmResponse.CharmURL))
+	c.Assert(err, gc.IsNil)
+	c.Assert(charmResponse.Sha256, gc.Equals, sch.BundleSha256())
+	reader, _, err := s.State.Storage().Get(sch.StoragePath())
+	c.Assert(err, gc.IsNil)
+	defer reader.Close()
+	storedSHA256, storedSize, err := utils.ReadSHA256(reader)
+	c.Assert(err, gc.IsNil)
+	c.Assert(charmResponse.Sha256, gc.Equals, storedSHA256)
+	c.Assert(charmResponse.Size, gc.Equals, storedSize)
+}
+
+func (s *charmsSuite) TestUploadRespondsWithPlainTextWhenAccepted(c *gc.C) {
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	file, err := os.Open(ch.Path)
+	c.Assert(err, gc.IsNil)
+	defer file.Close()
+	req, err := http.NewRequest("POST", s.charmsURI(c, "?series=quantal"), file)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Content-Type", "application/zip")
+	req.Header.Set("Accept", "text/plain")
+	resp, err := utils.GetNonValidatingHTTPClient().Do(req)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "text/plain")
+	c.Assert(string(body), gc.Equals, fmt.Sprintf("local:quantal/dummy-%d\n", ch.Revision()))
+}
+
 func (s *charmsSuite) TestUploadAllowsTopLevelPath(c *gc.C) {
 	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
 	// Backwards compatibility check, that we can upload charms to
@@ -342,6 +856,16 @@ func (s *charmsSuite) TestGetFailsWithInvalidCharmURL(c *gc.C) {
 	)
 }
 
+func (s *charmsSuite) TestGetFailsWithMalformedCharmURL(c *gc.C) {
+	uri := s.charmsURI(c, "?url=local:precise/&file=*")
+	resp, err := s.authRequest(c, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(
+		c, resp, http.StatusBadRequest,
+		`cannot parse charm URL: .*`,
+	)
+}
+
 func (s *charmsSuite) TestGetReturnsNotFoundWhenMissing(c *gc.C) {
 	// Add the dummy charm.
 	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
@@ -425,6 +949,49 @@ func (s *charmsSuite) TestGetStarReturnsArchiveBytes(c *gc.C) {
 	s.assertGetFileResponse(c, resp, string(data), "application/zip")
 }
 
+func (s *charmsSuite) TestGetWithoutFileReturnsArchiveBytes(c *gc.C) {
+	// Add the dummy charm.
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	_, err := s.uploadRequest(
+		c, s.charmsURI(c, "?series=quantal"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+
+	data, err := ioutil.ReadFile(ch.Path)
+	c.Assert(err, gc.IsNil)
+
+	uri := s.charmsURI(c, "?url=local:quantal/dummy-1")
+	resp, err := s.authRequest(c, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertGetFileResponse(c, resp, string(data), "application/zip")
+}
+
+func (s *charmsSuite) TestGetStarHonoursRangeRequests(c *gc.C) {
+	// Add the dummy charm.
+	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
+	_, err := s.uploadRequest(
+		c, s.charmsURI(c, "?series=quantal"), true, ch.Path)
+	c.Assert(err, gc.IsNil)
+
+	// Retrieve the whole archive first.
+	uri := s.charmsURI(c, "?url=local:quantal/dummy-1&file=*")
+	resp, err := s.authRequest(c, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.Header.Get("Accept-Ranges"), gc.Equals, "bytes")
+	data := assertResponse(c, resp, http.StatusOK, "application/zip")
+	c.Assert(len(data) > 100, jc.IsTrue)
+
+	// Then resume from an offset.
+	req, err := http.NewRequest("GET", uri, nil)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Range", "bytes=10-99")
+	resp, err = utils.GetNonValidatingHTTPClient().Do(req)
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.Header.Get("Content-Range"), gc.Equals, fmt.Sprintf("bytes 10-99/%d", len(data)))
+	body := assertResponse(c, resp, http.StatusPartialContent, "application/zip")
+	c.Assert(body, gc.DeepEquals, data[10:100])
+}
+
 func (s *charmsSuite) TestGetAllowsTopLevelPath(c *gc.C) {
 	ch := charmtesting.Charms.CharmArchive(c.MkDir(), "dummy")
 	_, err := s.uploadRequest(
@@ -471,7 +1038,7 @@ func (s *charmsSuite) TestGetReturnsManifest(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 
 	// Ensure charm files are properly listed.
-	uri := s.charmsURI(c, "?url=local:quantal/dummy-1")
+	uri := s.charmsURI(c, "?url=local:quantal/dummy-1&manifest=true")
 	resp, err := s.authRequest(c, "GET", uri, "", nil)
 	c.Assert(err, gc.IsNil)
 	manifest, err := ch.Manifest()
@@ -523,6 +1090,57 @@ func (s *charmsSuite) charmsURI(c *gc.C, query string) string {
 	return s.charmsURL(c, query).String()
 }
 
+// batchUploadRequest uploads the archives at the given paths as the
+// parts of a single multipart/form-data request.
+func (s *charmsSuite) batchUploadRequest(c *gc.C, uri string, paths ...string) (*http.Response, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, path := range paths {
+		part, err := writer.CreateFormFile("charm", filepath.Base(path))
+		c.Assert(err, gc.IsNil)
+		file, err := os.Open(path)
+		c.Assert(err, gc.IsNil)
+		_, err = io.Copy(part, file)
+		file.Close()
+		c.Assert(err, gc.IsNil)
+	}
+	c.Assert(writer.Close(), gc.IsNil)
+	return s.authRequest(c, "POST", uri, writer.FormDataContentType(), &buf)
+}
+
+// startChunkedUpload begins a chunked upload, and returns its id.
+func (s *charmsSuite) startChunkedUpload(c *gc.C) string {
+	resp, err := s.authRequest(c, "POST", s.charmsURI(c, "?chunked=true"), "", nil)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "application/json")
+	charmResponse := jsonResponse(c, body)
+	c.Assert(charmResponse.Error, gc.Equals, "")
+	c.Assert(charmResponse.UploadId, gc.Not(gc.Equals), "")
+	return charmResponse.UploadId
+}
+
+// chunkRequest sends the bytes of data from start up to end as a chunk
+// of the upload with the given id.
+func (s *charmsSuite) chunkRequest(c *gc.C, id string, data []byte, start, end int) (*http.Response, error) {
+	req, err := http.NewRequest("PUT", s.charmsURI(c, "?upload="+id), bytes.NewReader(data[start:end]))
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth(s.userTag, s.password)
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+	return utils.GetNonValidatingHTTPClient().Do(req)
+}
+
+func (s *charmsSuite) assertChunkResponse(c *gc.C, resp *http.Response, expCode int, expId string, expReceived int) {
+	body := assertResponse(c, resp, expCode, "application/json")
+	charmResponse := jsonResponse(c, body)
+	c.Check(charmResponse.UploadId, gc.Equals, expId)
+	c.Check(charmResponse.Received, gc.Equals, int64(expReceived))
+	if expCode == http.StatusOK {
+		c.Check(charmResponse.Error, gc.Equals, "")
+	} else {
+		c.Check(charmResponse.Error, gc.Not(gc.Equals), "")
+	}
+}
+
 func (s *charmsSuite) assertUploadResponse(c *gc.C, resp *http.Response, expCharmURL string) {
 	body := assertResponse(c, resp, http.StatusOK, "application/json")
 	charmResponse := jsonResponse(c, body)
@@ -0,0 +1,204 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils"
+)
+
+// chunkedUploadTimeout is how long a chunked upload may go without
+// receiving a chunk before it is abandoned and its data discarded.
+var chunkedUploadTimeout = time.Hour
+
+// errChunkOutOfOrder is returned when a chunk does not start where the
+// data received so far ends.
+var errChunkOutOfOrder = errors.New("chunk does not continue the upload")
+
+// chunkedUpload holds the state of a single chunked upload.
+type chunkedUpload struct {
+	path     string
+	received int64
+	total    int64
+	touched  time.Time
+	busy     bool
+}
+
+// chunkedUploads holds the uploads that are being received in chunks,
+// each of which is identified by an upload id. Uploads that are not
+// touched for chunkedUploadTimeout are discarded.
+type chunkedUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*chunkedUpload
+}
+
+// newChunkedUploads returns a chunkedUploads holding no uploads.
+func newChunkedUploads() *chunkedUploads {
+	return &chunkedUploads{
+		uploads: make(map[string]*chunkedUpload),
+	}
+}
+
+// start begins a new upload, and returns its id.
+func (u *chunkedUploads) start() (string, error) {
+	uuid, err := utils.NewUUID()
+	if err != nil {
+		return "", errors.Annotate(err, "cannot create upload id")
+	}
+	tempFile, err := ioutil.TempFile("", "charm-chunked")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temp file: %v", err)
+	}
+	tempFile.Close()
+	id := uuid.String()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.expire()
+	u.uploads[id] = &chunkedUpload{
+		path:    tempFile.Name(),
+		touched: time.Now(),
+	}
+	return id, nil
+}
+
+// write appends the bytes from start to end inclusive, read from r, to
+// the upload with the given id, which is total bytes long. It returns
+// the number of bytes received for the upload so far. If start is not
+// the number of bytes already received, errChunkOutOfOrder is returned
+// with that number, so the client can resume from there.
+func (u *chunkedUploads) write(id string, start, end, total int64, r io.Reader) (int64, error) {
+	u.mu.Lock()
+	u.expire()
+	upload, ok := u.uploads[id]
+	if !ok {
+		u.mu.Unlock()
+		return 0, errors.NotFoundf("upload %q", id)
+	}
+	received := upload.received
+	if upload.busy {
+		u.mu.Unlock()
+		return received, fmt.Errorf("upload %q is already receiving a chunk", id)
+	}
+	if upload.total != 0 && upload.total != total {
+		u.mu.Unlock()
+		return received, fmt.Errorf("upload %q has length %d, not %d", id, upload.total, total)
+	}
+	if start != received {
+		u.mu.Unlock()
+		return received, errChunkOutOfOrder
+	}
+	upload.total = total
+	upload.busy = true
+	u.mu.Unlock()
+
+	n, err := writeChunk(upload.path, start, end-start+1, r)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	upload.busy = false
+	upload.touched = time.Now()
+	if err != nil {
+		// Drop whatever was written of the failed chunk, so that it
+		// can be sent again in its entirety.
+		if err := os.Truncate(upload.path, received); err != nil {
+			logger.Errorf("cannot discard partial chunk of upload %q: %v", id, err)
+		}
+		return received, err
+	}
+	upload.received += n
+	return upload.received, nil
+}
+
+// writeChunk writes size bytes read from r to the file at path,
+// starting at offset.
+func writeChunk(path string, offset, size int64, r io.Reader) (int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, errors.Annotate(err, "cannot open upload")
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, 0); err != nil {
+		return 0, errors.Annotate(err, "cannot open upload")
+	}
+	n, err := io.CopyN(file, r, size)
+	if err == io.EOF {
+		return n, fmt.Errorf("chunk is shorter than its range: got %d of %d bytes", n, size)
+	}
+	return n, err
+}
+
+// finish removes the complete upload with the given id, and returns
+// the path of the file holding its data. The caller is responsible
+// for removing the file.
+func (u *chunkedUploads) finish(id string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.expire()
+	upload, ok := u.uploads[id]
+	if !ok {
+		return "", errors.NotFoundf("upload %q", id)
+	}
+	if upload.busy {
+		return "", fmt.Errorf("upload %q is still receiving a chunk", id)
+	}
+	if upload.total == 0 || upload.received != upload.total {
+		return "", fmt.Errorf("upload %q is incomplete: received %d of %d bytes", id, upload.received, upload.total)
+	}
+	delete(u.uploads, id)
+	return upload.path, nil
+}
+
+// discardAll removes every upload and its data.
+func (u *chunkedUploads) discardAll() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id, upload := range u.uploads {
+		os.Remove(upload.path)
+		delete(u.uploads, id)
+	}
+}
+
+// expire discards the uploads that have not been touched within
+// chunkedUploadTimeout. It must be called with u.mu held.
+func (u *chunkedUploads) expire() {
+	now := time.Now()
+	for id, upload := range u.uploads {
+		if upload.busy || now.Sub(upload.touched) < chunkedUploadTimeout {
+			continue
+		}
+		logger.Infof("discarding abandoned upload %q", id)
+		os.Remove(upload.path)
+		delete(u.uploads, id)
+	}
+}
+
+// parseContentRange parses the value of a Content-Range header of the
+// form "bytes start-end/total", as sent with each chunk of an upload.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	spec := strings.TrimPrefix(header, "bytes ")
+	slash := strings.Index(spec, "/")
+	dash := strings.Index(spec, "-")
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	start, err1 := strconv.ParseInt(spec[:dash], 10, 64)
+	end, err2 := strconv.ParseInt(spec[dash+1:slash], 10, 64)
+	total, err3 := strconv.ParseInt(spec[slash+1:], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	return start, end, total, nil
+}
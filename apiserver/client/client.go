@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -25,6 +27,7 @@ import (
 	"github.com/juju/juju/juju"
 	"github.com/juju/juju/network"
 	"github.com/juju/juju/state"
+	"github.com/juju/juju/state/presence"
 	"github.com/juju/juju/version"
 )
 
@@ -892,6 +895,39 @@ func (c *Client) SetAnnotations(args params.SetAnnotations) error {
 	return entity.SetAnnotations(args.Pairs)
 }
 
+// AgentPresenceHistory returns the times at which the agent of the
+// given machine or unit connected to and disconnected from the API
+// server.
+func (c *Client) AgentPresenceHistory(args params.AgentPresenceHistory) (params.PresenceEvents, error) {
+	result := params.PresenceEvents{}
+	tag, err := names.ParseTag(args.Tag)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	entity, err := c.api.state.FindEntity(tag)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	if _, ok := entity.(presence.Presencer); !ok {
+		return result, common.NotSupportedError(tag, "agent presence history")
+	}
+	var since time.Time
+	if args.Since > 0 {
+		since = time.Now().Add(-args.Since)
+	}
+	events, err := c.api.state.AgentPresenceHistory(tag, since)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	for _, event := range events {
+		result.Events = append(result.Events, params.PresenceEvent{
+			Time:      event.Time,
+			Connected: event.Connected,
+		})
+	}
+	return result, nil
+}
+
 // parseSettingsCompatible parses setting strings in a way that is
 // compatible with the behavior before this CL based on the issue
 // http://pad.lv/1194945. Until then setting an option to an empty
@@ -943,6 +979,20 @@ func (c *Client) EnvironmentGet() (params.EnvironmentGetResults, error) {
 	return result, nil
 }
 
+// LastEnvironConfigError reports why the provisioner last found the
+// environment configuration unusable, such as when the provider type
+// is unknown. The result is empty if the provisioner last found it
+// usable, or has not yet looked at it.
+func (c *Client) LastEnvironConfigError() (params.StringResult, error) {
+	result := params.StringResult{}
+	env, err := c.api.state.Environment()
+	if err != nil {
+		return result, err
+	}
+	result.Result = env.ConfigError()
+	return result, nil
+}
+
 // EnvironmentSet implements the server-side part of the
 // set-environment CLI command.
 func (c *Client) EnvironmentSet(args params.EnvironmentSet) error {
@@ -987,6 +1037,58 @@ func (c *Client) FindTools(args params.FindToolsParams) (params.FindToolsResult,
 	return c.api.toolsFinder.FindTools(args)
 }
 
+// SupportedBases returns the series and architecture combinations for
+// which tools matching the environment's agent version are available,
+// and so on which new machines can be provisioned.
+func (c *Client) SupportedBases() (params.SupportedBases, error) {
+	result := params.SupportedBases{}
+	cfg, err := c.api.state.EnvironConfig()
+	if err != nil {
+		return result, err
+	}
+	agentVersion, ok := cfg.AgentVersion()
+	if !ok {
+		return result, errors.New("agent-version not set in environment configuration")
+	}
+	found, err := c.api.toolsFinder.FindTools(params.FindToolsParams{
+		Number:       agentVersion,
+		MajorVersion: -1,
+		MinorVersion: -1,
+	})
+	if err != nil {
+		return result, err
+	}
+	if found.Error != nil {
+		if params.IsCodeNotFound(found.Error) {
+			return result, nil
+		}
+		return result, found.Error
+	}
+	seen := make(map[params.SupportedBase]bool)
+	for _, tools := range found.List {
+		base := params.SupportedBase{Series: tools.Version.Series, Arch: tools.Version.Arch}
+		if !seen[base] {
+			seen[base] = true
+			result.Bases = append(result.Bases, base)
+		}
+	}
+	sort.Sort(basesBySeriesAndArch(result.Bases))
+	return result, nil
+}
+
+// basesBySeriesAndArch sorts SupportedBases by series, then by
+// architecture.
+type basesBySeriesAndArch []params.SupportedBase
+
+func (b basesBySeriesAndArch) Len() int      { return len(b) }
+func (b basesBySeriesAndArch) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b basesBySeriesAndArch) Less(i, j int) bool {
+	if b[i].Series != b[j].Series {
+		return b[i].Series < b[j].Series
+	}
+	return b[i].Arch < b[j].Arch
+}
+
 func destroyErr(desc string, ids, errs []string) error {
 	if len(errs) == 0 {
 		return nil
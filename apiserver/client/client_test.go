@@ -30,6 +30,7 @@ import (
 	"github.com/juju/juju/environs/manual"
 	toolstesting "github.com/juju/juju/environs/tools/testing"
 	"github.com/juju/juju/instance"
+	"github.com/juju/juju/mongo"
 	"github.com/juju/juju/network"
 	"github.com/juju/juju/provider/dummy"
 	"github.com/juju/juju/state"
@@ -1877,6 +1878,38 @@ func (s *clientSuite) TestClientEnvironmentGet(c *gc.C) {
 	}
 }
 
+func (s *clientSuite) TestLastEnvironConfigErrorNone(c *gc.C) {
+	reason, err := s.APIState.Client().LastEnvironConfigError()
+	c.Assert(err, gc.IsNil)
+	c.Assert(reason, gc.Equals, "")
+}
+
+func (s *clientSuite) TestLastEnvironConfigErrorRecorded(c *gc.C) {
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	err = env.SetConfigError(`no registered provider for "unknown"`)
+	c.Assert(err, gc.IsNil)
+
+	reason, err := s.APIState.Client().LastEnvironConfigError()
+	c.Assert(err, gc.IsNil)
+	c.Assert(reason, gc.Equals, `no registered provider for "unknown"`)
+}
+
+func (s *clientSuite) TestLastEnvironConfigErrorIgnoresCurrentConfig(c *gc.C) {
+	// The reported error is the one last recorded by the provisioner,
+	// not a fresh check of the configuration: an invalid config that
+	// the provisioner has not yet seen is not reported.
+	st, err := state.Open(s.MongoInfo(c), mongo.DefaultDialOpts(), state.Policy(nil))
+	c.Assert(err, gc.IsNil)
+	defer st.Close()
+	err = st.UpdateEnvironConfig(map[string]interface{}{"type": "unknown"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+
+	reason, err := s.APIState.Client().LastEnvironConfigError()
+	c.Assert(err, gc.IsNil)
+	c.Assert(reason, gc.Equals, "")
+}
+
 func (s *clientSuite) TestClientEnvironmentSet(c *gc.C) {
 	envConfig, err := s.State.EnvironConfig()
 	c.Assert(err, gc.IsNil)
@@ -1961,6 +1994,30 @@ func (s *clientSuite) TestClientFindTools(c *gc.C) {
 	c.Assert(result.List[0].URL, gc.Equals, url)
 }
 
+func (s *clientSuite) TestClientSupportedBases(c *gc.C) {
+	// Use an agent version for which there are no tools yet.
+	err := s.State.UpdateEnvironConfig(map[string]interface{}{"agent-version": "2.12.0"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	result, err := s.APIState.Client().SupportedBases()
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Bases, gc.HasLen, 0)
+
+	toolstesting.UploadToStorage(c, s.DefaultToolsStorage, "released",
+		version.MustParseBinary("2.12.0-trusty-i386"),
+		version.MustParseBinary("2.12.0-trusty-amd64"),
+		version.MustParseBinary("2.12.0-precise-amd64"),
+		// Tools for other versions are not usable by new machines.
+		version.MustParseBinary("2.13.0-utopic-amd64"),
+	)
+	result, err = s.APIState.Client().SupportedBases()
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Bases, gc.DeepEquals, []params.SupportedBase{
+		{Series: "precise", Arch: "amd64"},
+		{Series: "trusty", Arch: "amd64"},
+		{Series: "trusty", Arch: "i386"},
+	})
+}
+
 func (s *clientSuite) checkMachine(c *gc.C, id, series, cons string) {
 	// Ensure the machine was actually created.
 	machine, err := s.BackingState.Machine(id)
@@ -80,6 +80,7 @@ var singletonErrorCodes = map[error]string{
 	state.ErrCannotEnterScope:    params.CodeCannotEnterScope,
 	state.ErrUnitHasSubordinates: params.CodeUnitHasSubordinates,
 	state.ErrDead:                params.CodeDead,
+	state.ErrActionSlotFull:      params.CodeActionSlotFull,
 	txn.ErrExcessiveContention:   params.CodeExcessiveContention,
 	ErrBadId:                     params.CodeNotFound,
 	ErrBadCreds:                  params.CodeUnauthorized,
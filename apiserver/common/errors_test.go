@@ -108,6 +108,10 @@ var errorTransformTests = []struct {
 	err:        state.UpgradeInProgressError,
 	code:       params.CodeUpgradeInProgress,
 	helperFunc: params.IsCodeUpgradeInProgress,
+}, {
+	err:        state.ErrActionSlotFull,
+	code:       params.CodeActionSlotFull,
+	helperFunc: params.IsCodeActionSlotFull,
 }, {
 	err:  stderrors.New("an error"),
 	code: "",
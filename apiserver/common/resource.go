@@ -105,6 +105,33 @@ func (rs *Resources) StopAll() {
 	rs.resources = make(map[string]Resource)
 }
 
+// Adopt moves all the resources held by other into rs, keeping their
+// ids so that clients may continue to refer to them, and leaves other
+// empty. Where both hold a resource with the same id, the one in rs
+// is kept and the one in other is stopped.
+func (rs *Resources) Adopt(other *Resources) {
+	other.mu.Lock()
+	adopted := other.resources
+	maxId := other.maxId
+	other.resources = make(map[string]Resource)
+	other.mu.Unlock()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if maxId > rs.maxId {
+		rs.maxId = maxId
+	}
+	for id, r := range adopted {
+		if _, ok := rs.resources[id]; ok {
+			if err := r.Stop(); err != nil {
+				logger.Errorf("error stopping %T resource: %v", r, err)
+			}
+			continue
+		}
+		rs.resources[id] = r
+	}
+}
+
 // Count returns the number of resources currently held.
 func (rs *Resources) Count() int {
 	rs.mu.Lock()
@@ -148,6 +148,35 @@ func (resourceSuite) TestStopAll(c *gc.C) {
 	c.Assert(rs.Count(), gc.Equals, 0)
 }
 
+func (resourceSuite) TestAdopt(c *gc.C) {
+	old := common.NewResources()
+	r1 := &fakeResource{}
+	old.Register(r1)
+	r2 := &fakeResource{}
+	old.Register(r2)
+	oldNamed := &fakeResource{}
+	err := old.RegisterNamed("named", oldNamed)
+	c.Assert(err, gc.IsNil)
+
+	rs := common.NewResources()
+	named := &fakeResource{}
+	err = rs.RegisterNamed("named", named)
+	c.Assert(err, gc.IsNil)
+	rs.Adopt(old)
+
+	c.Assert(old.Count(), gc.Equals, 0)
+	c.Assert(rs.Count(), gc.Equals, 3)
+	c.Assert(rs.Get("1"), gc.Equals, r1)
+	c.Assert(rs.Get("2"), gc.Equals, r2)
+	c.Assert(rs.Get("named"), gc.Equals, named)
+	c.Assert(oldNamed.stopped, gc.Equals, true)
+	c.Assert(named.stopped, gc.Equals, false)
+
+	// New resources do not collide with adopted ones.
+	id := rs.Register(&fakeResource{})
+	c.Assert(id, gc.Equals, "3")
+}
+
 func (resourceSuite) TestStringResource(c *gc.C) {
 	rs := common.NewResources()
 	r1 := common.StringResource("foobar")
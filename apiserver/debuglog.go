@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -50,7 +51,7 @@ func (h *debugLogHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	server := websocket.Server{
 		Handler: func(socket *websocket.Conn) {
 			logger.Infof("debug log handler starting")
-			if err := h.authenticate(req); err != nil {
+			if err := h.authorize(req, isUser); err != nil {
 				h.sendError(socket, fmt.Errorf("auth failed: %v", err))
 				socket.Close()
 				return
@@ -91,6 +92,7 @@ func (h *debugLogHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			}
 
 			stream.start(logFile, socket)
+			go stream.stopOnClose(socket)
 			go func() {
 				defer stream.tomb.Done()
 				defer socket.Close()
@@ -133,14 +135,9 @@ func newLogStream(queryMap url.Values) (*logStream, error) {
 		backlog = uint(num)
 	}
 
-	level := loggo.UNSPECIFIED
-	if value := queryMap.Get("level"); value != "" {
-		var ok bool
-		level, ok = loggo.ParseLevel(value)
-		if !ok || level < loggo.TRACE || level > loggo.ERROR {
-			return nil, fmt.Errorf("level value %q is not one of %q, %q, %q, %q, %q",
-				value, loggo.TRACE, loggo.DEBUG, loggo.INFO, loggo.WARNING, loggo.ERROR)
-		}
+	level, err := parseLogLevel(queryMap.Get("level"))
+	if err != nil {
+		return nil, err
 	}
 
 	return &logStream{
@@ -155,6 +152,20 @@ func newLogStream(queryMap url.Values) (*logStream, error) {
 	}, nil
 }
 
+// parseLogLevel parses the value of a "level" query argument, returning
+// loggo.UNSPECIFIED if it is empty.
+func parseLogLevel(value string) (loggo.Level, error) {
+	if value == "" {
+		return loggo.UNSPECIFIED, nil
+	}
+	level, ok := loggo.ParseLevel(value)
+	if !ok || level < loggo.TRACE || level > loggo.ERROR {
+		return loggo.UNSPECIFIED, fmt.Errorf("level value %q is not one of %q, %q, %q, %q, %q",
+			value, loggo.TRACE, loggo.DEBUG, loggo.INFO, loggo.WARNING, loggo.ERROR)
+	}
+	return level, nil
+}
+
 // sendError sends a JSON-encoded error response.
 func (h *debugLogHandler) sendError(w io.Writer, err error) error {
 	response := &params.ErrorResult{}
@@ -237,6 +248,16 @@ func (stream *logStream) start(logFile io.ReadSeeker, writer io.Writer) {
 	stream.logTailer = tailer.NewTailer(logFile, writer, stream.countedFilterLine)
 }
 
+// stopOnClose stops the stream when reading from conn fails, as it
+// does once the client has closed the connection. Without it, a
+// stream of a quiet log would not notice the client had gone until
+// the next matching line was written. Clients send nothing on the
+// connection, so anything read is discarded.
+func (stream *logStream) stopOnClose(conn io.Reader) {
+	io.Copy(ioutil.Discard, conn)
+	stream.tomb.Kill(nil)
+}
+
 // loop starts the tailer with the log file and the web socket.
 func (stream *logStream) loop() error {
 	select {
@@ -7,6 +7,8 @@ package apiserver
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -296,6 +298,43 @@ line 5
 	s.testStreamInternal(c, false, 0, 3, expected, "")
 }
 
+func (s *debugInternalSuite) TestLogStreamStopsOnClose(c *gc.C) {
+	logPath := filepath.Join(c.MkDir(), "logfile.txt")
+	err := ioutil.WriteFile(logPath, []byte("line 1\n"), 0644)
+	c.Assert(err, gc.IsNil)
+	logFile, err := os.Open(logPath)
+	c.Assert(err, gc.IsNil)
+	defer logFile.Close()
+
+	stream := &logStream{}
+	err = stream.positionLogFile(logFile)
+	c.Assert(err, gc.IsNil)
+	writer := &chanWriter{make(chan []byte)}
+	stream.start(logFile, writer)
+	go func() {
+		defer stream.tomb.Done()
+		stream.tomb.Kill(stream.loop())
+	}()
+
+	// Nothing is written to the log, so only the client closing the
+	// connection stops the stream.
+	conn, client := io.Pipe()
+	go stream.stopOnClose(conn)
+	client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.tomb.Wait()
+	}()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(testing.LongWait):
+		c.Fatalf("stream not stopped after the connection was closed")
+	}
+	c.Assert(stream.logTailer.Wait(), gc.IsNil)
+}
+
 func assertStreamParams(c *gc.C, obtained, expected *logStream) {
 	c.Check(obtained.includeEntity, jc.DeepEquals, expected.includeEntity)
 	c.Check(obtained.includeModule, jc.DeepEquals, expected.includeModule)
@@ -19,9 +19,13 @@ var (
 	NewPingTimeout        = newPingTimeout
 	MaxClientPingInterval = &maxClientPingInterval
 	MongoPingInterval     = &mongoPingInterval
+	ChunkedUploadTimeout  = &chunkedUploadTimeout
 )
 
-const LoginRateLimit = loginRateLimit
+const (
+	LoginRateLimit   = loginRateLimit
+	MaxBatchArchives = maxBatchArchives
+)
 
 // DelayLogins changes how the Login code works so that logins won't proceed
 // until they get a message on the returned channel.
@@ -132,3 +136,15 @@ func TestingAboutToRestoreRoot(st *state.State) *aboutToRestoreRoot {
 	r := TestingApiRoot(st)
 	return newAboutToRestoreRoot(r)
 }
+
+// ParkedResourceCount returns the number of resources held by the
+// sessions parked in the given store.
+func ParkedResourceCount(s *SessionStore) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, sess := range s.sessions {
+		count += sess.resources.Count()
+	}
+	return count
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// environmentPrefix is the prefix of paths that address a particular
+// environment, as in /environment/:envuuid/charms.
+const environmentPrefix = "/environment/"
+
+// reservedPaths holds the paths served by the server itself, which
+// may not be registered with RegisterHandler. Paths beneath those
+// ending in a slash are reserved too.
+var reservedPaths = []string{
+	"/",
+	"/api",
+	"/log",
+	"/machine/",
+	"/charms",
+	"/tools",
+	"/tools/",
+}
+
+// handlerRegistry holds the HTTP handlers registered with the server
+// in addition to those it serves itself.
+type handlerRegistry struct {
+	mu       sync.Mutex
+	handlers map[string]http.Handler
+}
+
+func newHandlerRegistry() *handlerRegistry {
+	return &handlerRegistry{
+		handlers: make(map[string]http.Handler),
+	}
+}
+
+// register adds the handler for the given path, which must not be
+// reserved or already registered.
+func (reg *handlerRegistry) register(path string, h http.Handler) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path %q is not absolute", path)
+	}
+	if strings.HasPrefix(path, environmentPrefix) {
+		return fmt.Errorf("path %q must not name an environment", path)
+	}
+	for _, reserved := range reservedPaths {
+		if path == reserved || reserved != "/" && strings.HasSuffix(reserved, "/") && strings.HasPrefix(path, reserved) {
+			return fmt.Errorf("path %q is reserved", path)
+		}
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.handlers[path]; ok {
+		return fmt.Errorf("path %q is already registered", path)
+	}
+	reg.handlers[path] = h
+	return nil
+}
+
+// lookup returns the handler registered for the request's path, which
+// may be given beneath /environment/:envuuid, and the environment UUID
+// given, if any.
+func (reg *handlerRegistry) lookup(r *http.Request) (h http.Handler, envUUID string) {
+	path := r.URL.Path
+	if strings.HasPrefix(path, environmentPrefix) {
+		parts := strings.SplitN(path[len(environmentPrefix):], "/", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, ""
+		}
+		envUUID, path = parts[0], "/"+parts[1]
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.handlers[path], envUUID
+}
+
+// serve returns a handler that serves requests for registered paths
+// with their handlers, and all others with fallback.
+func (reg *handlerRegistry) serve(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, envUUID := reg.lookup(r)
+		if h == nil {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		if envUUID != "" {
+			// Make the environment UUID available to the handler
+			// as the pat mux does for the server's own handlers.
+			q := url.Values{":envuuid": {envUUID}}
+			r.URL.RawQuery = q.Encode() + "&" + r.URL.RawQuery
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authHandler serves requests made by users and environment managers
+// with another handler, in the same way as requests for charms.
+type authHandler struct {
+	httpHandler
+	handler http.Handler
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.validateEnvironUUID(r); err != nil {
+		h.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err := h.authorize(r, isUserOrEnvironManager); err != nil {
+		h.authError(w, h, err)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// RegisterHandler serves requests for the given path, and for the
+// same path beneath /environment/:envuuid, with the given handler.
+// Only users and environment managers are permitted to make them,
+// as for charms; other requests are refused without reaching h.
+// Paths served by the server itself, including that of the RPC API,
+// may not be registered.
+func (srv *Server) RegisterHandler(path string, h http.Handler) error {
+	return srv.handlers.register(path, &authHandler{
+		httpHandler: httpHandler{state: srv.state, authFailures: srv.authFailures},
+		handler:     h,
+	})
+}
+
+// RegisterUnauthenticatedHandler is like RegisterHandler, but h serves
+// every request for the path, leaving it to authenticate them and to
+// check any environment UUID given if necessary.
+func (srv *Server) RegisterUnauthenticatedHandler(path string, h http.Handler) error {
+	return srv.handlers.register(path, h)
+}
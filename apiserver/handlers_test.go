@@ -0,0 +1,177 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/apiserver"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/state"
+	coretesting "github.com/juju/juju/testing"
+)
+
+type handlersSuite struct {
+	authHttpSuite
+	srv *apiserver.Server
+}
+
+var _ = gc.Suite(&handlersSuite{})
+
+func (s *handlersSuite) SetUpTest(c *gc.C) {
+	s.authHttpSuite.SetUpTest(c)
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	s.srv, err = apiserver.NewServer(s.State, listener, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *handlersSuite) TearDownTest(c *gc.C) {
+	if s.srv != nil {
+		c.Check(s.srv.Stop(), gc.IsNil)
+	}
+	s.authHttpSuite.TearDownTest(c)
+}
+
+func (s *handlersSuite) url(path string) string {
+	return "https://" + s.srv.Addr() + path
+}
+
+// helloHandler responds to every request with "hello".
+var helloHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "hello")
+})
+
+func (s *handlersSuite) assertHello(c *gc.C, resp *http.Response) {
+	body := assertResponse(c, resp, http.StatusOK, "text/plain; charset=utf-8")
+	c.Assert(string(body), gc.Equals, "hello")
+}
+
+func (s *handlersSuite) assertErrorResponse(c *gc.C, resp *http.Response, expCode int, expError string) {
+	body := assertResponse(c, resp, expCode, "application/json")
+	var result params.ErrorResult
+	err := json.Unmarshal(body, &result)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Error, gc.NotNil)
+	c.Check(result.Error.Message, gc.Matches, expError)
+}
+
+func (s *handlersSuite) TestRegisterHandler(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	resp, err := s.authRequest(c, "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertHello(c, resp)
+
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	resp, err = s.authRequest(c, "GET", s.url("/environment/"+env.UUID()+"/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertHello(c, resp)
+}
+
+func (s *handlersSuite) TestRegisterHandlerRequiresAuth(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	resp, err := s.sendRequest(c, "", "", "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
+
+	resp, err = s.sendRequest(c, s.userTag, "wrong", "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusUnauthorized, "unauthorized")
+}
+
+func (s *handlersSuite) TestRegisterHandlerAuthRequiresUserOrEnvironManager(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	machine, password := s.addMachine(c, state.JobHostUnits)
+	resp, err := s.sendRequest(c, machine.Tag().String(), password, "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusForbidden, "forbidden")
+
+	machine, password = s.addMachine(c, state.JobManageEnviron)
+	resp, err = s.sendRequest(c, machine.Tag().String(), password, "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertHello(c, resp)
+}
+
+func (s *handlersSuite) TestRegisterHandlerUnknownEnvironment(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	resp, err := s.authRequest(c, "GET", s.url("/environment/dead-beef-123456/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertErrorResponse(c, resp, http.StatusNotFound, `unknown environment: "dead-beef-123456"`)
+}
+
+func (s *handlersSuite) TestRegisterUnauthenticatedHandler(c *gc.C) {
+	err := s.srv.RegisterUnauthenticatedHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	resp, err := s.sendRequest(c, "", "", "GET", s.url("/hello"), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertHello(c, resp)
+}
+
+func (s *handlersSuite) TestRegisterHandlerInvalidPaths(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	for i, test := range []struct {
+		path   string
+		expect string
+	}{{
+		path:   "hello",
+		expect: `path "hello" is not absolute`,
+	}, {
+		path:   "/",
+		expect: `path "/" is reserved`,
+	}, {
+		path:   "/api",
+		expect: `path "/api" is reserved`,
+	}, {
+		path:   "/environment/dead-beef-123456/api",
+		expect: `path "/environment/dead-beef-123456/api" must not name an environment`,
+	}, {
+		path:   "/charms",
+		expect: `path "/charms" is reserved`,
+	}, {
+		path:   "/tools/1.2.3-precise-amd64",
+		expect: `path "/tools/1.2.3-precise-amd64" is reserved`,
+	}, {
+		path:   "/machine/0/log",
+		expect: `path "/machine/0/log" is reserved`,
+	}, {
+		path:   "/hello",
+		expect: `path "/hello" is already registered`,
+	}} {
+		c.Logf("test %d: %s", i, test.path)
+		err := s.srv.RegisterHandler(test.path, helloHandler)
+		c.Check(err, gc.ErrorMatches, test.expect)
+		err = s.srv.RegisterUnauthenticatedHandler(test.path, helloHandler)
+		c.Check(err, gc.ErrorMatches, test.expect)
+	}
+}
+
+func (s *handlersSuite) TestUnregisteredPathsAreServedAsBefore(c *gc.C) {
+	err := s.srv.RegisterHandler("/hello", helloHandler)
+	c.Assert(err, gc.IsNil)
+
+	// The RPC API is still served at the root.
+	conn, err := dialWebsocket(c, s.srv.Addr(), "/")
+	c.Assert(err, gc.IsNil)
+	conn.Close()
+}
@@ -5,6 +5,7 @@ package apiserver
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -24,36 +25,111 @@ type errorSender interface {
 // httpHandler handles http requests through HTTPS in the API server.
 type httpHandler struct {
 	state *state.State
+
+	// authFailures, if not nil, refuses requests from clients that
+	// have recently failed to authenticate too often.
+	authFailures *authFailureLimiter
+}
+
+// sendJSON sends a JSON-encoded response to the client.
+func (h *httpHandler) sendJSON(w http.ResponseWriter, statusCode int, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	w.Write(body)
+	return nil
+}
+
+// sendError sends a JSON-encoded params.ErrorResult holding the given
+// message. Handlers whose responses report errors differently
+// override it.
+func (h *httpHandler) sendError(w http.ResponseWriter, statusCode int, message string) {
+	response := &params.ErrorResult{Error: &params.Error{Message: message}}
+	if err := h.sendJSON(w, statusCode, response); err != nil {
+		logger.Errorf("failed to send error: %v", err)
+	}
 }
 
-// authenticate parses HTTP basic authentication and authorizes the
-// request by looking up the provided tag and password against state.
-func (h *httpHandler) authenticate(r *http.Request) error {
+// authenticate parses HTTP basic authentication and checks the
+// provided tag and password against state, returning the
+// authenticated entity.
+func (h *httpHandler) authenticate(r *http.Request) (state.Entity, error) {
+	tag, password, err := parseBasicAuth(r)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure the credentials are correct.
+	return checkCreds(h.state, params.LoginRequest{
+		AuthTag:     tag,
+		Credentials: password,
+	})
+}
+
+// parseBasicAuth returns the tag and password given in the request's
+// HTTP basic authentication header, without checking them.
+func parseBasicAuth(r *http.Request) (tag, password string, err error) {
 	parts := strings.Fields(r.Header.Get("Authorization"))
 	if len(parts) != 2 || parts[0] != "Basic" {
 		// Invalid header format or no header provided.
-		return fmt.Errorf("invalid request format")
+		return "", "", fmt.Errorf("invalid request format")
 	}
 	// Challenge is a base64-encoded "tag:pass" string.
 	// See RFC 2617, Section 2.
 	challenge, err := base64.StdEncoding.DecodeString(parts[1])
 	if err != nil {
-		return fmt.Errorf("invalid request format")
+		return "", "", fmt.Errorf("invalid request format")
 	}
 	tagPass := strings.SplitN(string(challenge), ":", 2)
 	if len(tagPass) != 2 {
-		return fmt.Errorf("invalid request format")
+		return "", "", fmt.Errorf("invalid request format")
 	}
-	// Only allow users, not agents.
-	if _, err := names.ParseUserTag(tagPass[0]); err != nil {
-		return common.ErrBadCreds
+	return tagPass[0], tagPass[1], nil
+}
+
+// authorize authenticates the request and then checks whether the
+// authenticated entity is permitted to make it. If the entity was
+// authenticated but is not permitted, common.ErrPerm is returned.
+func (h *httpHandler) authorize(r *http.Request, permitted func(state.Entity) bool) error {
+	if retryAfter, blocked := h.authFailures.blocked(r.RemoteAddr); blocked {
+		return &authRateLimitedError{retryAfter}
 	}
-	// Ensure the credentials are correct.
-	_, err = checkCreds(h.state, params.LoginRequest{
-		AuthTag:     tagPass[0],
-		Credentials: tagPass[1],
-	})
-	return err
+	entity, err := h.authenticate(r)
+	if err == common.ErrBadCreds {
+		h.authFailures.failed(r.RemoteAddr)
+	}
+	if err != nil {
+		return err
+	}
+	h.authFailures.succeeded(r.RemoteAddr)
+	if !permitted(entity) {
+		logger.Debugf("%q is not permitted to %s %s", entity.Tag(), r.Method, r.URL.Path)
+		return common.ErrPerm
+	}
+	return nil
+}
+
+// isUser returns whether the given entity is a user.
+func isUser(entity state.Entity) bool {
+	_, ok := entity.Tag().(names.UserTag)
+	return ok
+}
+
+// isUserOrEnvironManager returns whether the given entity is a user or
+// a machine running the ManageEnviron job.
+func isUserOrEnvironManager(entity state.Entity) bool {
+	return isUser(entity) || isMachineWithJob(entity, state.JobManageEnviron)
+}
+
+// isUserEnvironManagerOrUnit returns whether the given entity is a
+// user, a machine running the ManageEnviron job, or a unit agent.
+func isUserEnvironManagerOrUnit(entity state.Entity) bool {
+	if _, ok := entity.Tag().(names.UnitTag); ok {
+		return true
+	}
+	return isUserOrEnvironManager(entity)
 }
 
 func (h *httpHandler) getEnvironUUID(r *http.Request) string {
@@ -82,8 +158,19 @@ func (h *httpHandler) validateEnvironUUID(r *http.Request) error {
 	return nil
 }
 
-// authError sends an unauthorized error.
-func (h *httpHandler) authError(w http.ResponseWriter, sender errorSender) {
+// authError sends a forbidden error if err is common.ErrPerm, meaning
+// the caller authenticated but is not permitted to make the request,
+// a too many requests error if the caller has failed to authenticate
+// too often, and an unauthorized error otherwise.
+func (h *httpHandler) authError(w http.ResponseWriter, sender errorSender, err error) {
+	if err, ok := err.(*authRateLimitedError); ok {
+		sendAuthRateLimited(w, sender, err)
+		return
+	}
+	if err == common.ErrPerm {
+		sender.sendError(w, http.StatusForbidden, "forbidden")
+		return
+	}
 	w.Header().Set("WWW-Authenticate", `Basic realm="juju"`)
 	sender.sendError(w, http.StatusUnauthorized, "unauthorized")
 }
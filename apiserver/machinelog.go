@@ -0,0 +1,179 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/names"
+)
+
+// machineLogHandler serves the agent log of a single machine, taken
+// from the log aggregated from all machines.
+type machineLogHandler struct {
+	httpHandler
+	logDir string
+}
+
+// ServeHTTP serves the log lines of the machine whose id is given in
+// the request path as plain text. Args for the HTTP request are as
+// follows:
+//   tail -> uint - only send the machine's last this many lines
+//      - lines of other machines, or below the level, are not counted
+//      - if not set, the whole log is sent
+//   level -> string one of [TRACE, DEBUG, INFO, WARNING, ERROR]
+//   follow -> string - one of [true, false], if true, keep sending new
+//      lines as they are logged until the client disconnects
+func (h *machineLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.validateEnvironUUID(r); err != nil {
+		h.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if r.Method != "GET" {
+		h.sendError(w, http.StatusMethodNotAllowed, fmt.Sprintf("unsupported method: %q", r.Method))
+		return
+	}
+	if err := h.authorize(r, isUser); err != nil {
+		h.authError(w, h, err)
+		return
+	}
+	query := r.URL.Query()
+	machineId := query.Get(":id")
+	if err := h.checkMachine(machineId); errors.IsNotFound(err) {
+		h.sendError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		h.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	stream, follow, err := newMachineLogStream(machineId, query)
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logFile, err := os.Open(filepath.Join(h.logDir, "all-machines.log"))
+	if err != nil {
+		h.sendError(w, http.StatusInternalServerError, fmt.Sprintf("cannot open log file: %v", err))
+		return
+	}
+	defer logFile.Close()
+	if err := stream.positionLogFile(logFile); err != nil {
+		h.sendError(w, http.StatusInternalServerError, fmt.Sprintf("cannot position log file: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if !follow {
+		if err := stream.copyLines(logFile, w); err != nil {
+			logger.Errorf("machine log handler error: %v", err)
+		}
+		return
+	}
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+	stream.start(logFile, &flushingWriter{w})
+	go func() {
+		defer stream.tomb.Done()
+		stream.tomb.Kill(stream.loop())
+	}()
+	select {
+	case <-closed:
+		stream.tomb.Kill(nil)
+	case <-stream.tomb.Dying():
+	}
+	if err := stream.tomb.Wait(); err != nil {
+		logger.Errorf("machine log handler error: %v", err)
+	}
+}
+
+// checkMachine returns an error satisfying errors.IsNotFound if there
+// is no machine with the given id. Only top-level machines can be
+// named in the request path, as container ids contain slashes.
+func (h *machineLogHandler) checkMachine(id string) error {
+	if !names.IsValidMachine(id) {
+		return errors.NotFoundf("machine %q", id)
+	}
+	_, err := h.state.Machine(id)
+	return err
+}
+
+// newMachineLogStream returns a logStream that includes only the lines
+// logged by the given machine's agent, and reports whether the stream
+// should follow the log.
+func newMachineLogStream(machineId string, queryMap url.Values) (*logStream, bool, error) {
+	stream := &logStream{
+		includeEntity: []string{names.NewMachineTag(machineId).String()},
+		fromTheStart:  true,
+	}
+	if value := queryMap.Get("tail"); value != "" {
+		num, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("tail value %q is not a valid unsigned number", value)
+		}
+		// As for debug-log's backlog, positionLogFile counts back
+		// only the lines that pass the stream's filters, so that
+		// the machine's last num lines at the level are sent.
+		stream.backlog = uint(num)
+		stream.fromTheStart = false
+	}
+	level, err := parseLogLevel(queryMap.Get("level"))
+	if err != nil {
+		return nil, false, err
+	}
+	stream.filterLevel = level
+
+	follow := false
+	if value := queryMap.Get("follow"); value != "" {
+		follow, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("follow value %q is not a valid boolean", value)
+		}
+	}
+	return stream, follow, nil
+}
+
+// copyLines writes the matching lines read from logFile to w, stopping
+// at the end of the file.
+func (stream *logStream) copyLines(logFile io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(logFile)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && stream.filterLine(line) {
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// flushingWriter flushes each write to the client, so that followed
+// log lines are sent as soon as they are logged.
+type flushingWriter struct {
+	w http.ResponseWriter
+}
+
+// Write implements io.Writer.
+func (fw *flushingWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
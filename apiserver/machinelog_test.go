@@ -0,0 +1,148 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/state"
+)
+
+type machineLogSuite struct {
+	authHttpSuite
+	machine *state.Machine
+}
+
+var _ = gc.Suite(&machineLogSuite{})
+
+func (s *machineLogSuite) SetUpTest(c *gc.C) {
+	s.authHttpSuite.SetUpTest(c)
+	s.machine = s.Factory.MakeMachine(c, nil)
+}
+
+// writeLog writes a log aggregated from the suite's machine and
+// another, returning the lines logged by the suite's machine.
+func (s *machineLogSuite) writeLog(c *gc.C) []string {
+	tag := s.machine.Tag().String()
+	lines := []string{
+		tag + ": 2014-03-24 22:34:25 INFO juju.cmd supercommand.go:297 running juju-1.17.7.1-trusty-amd64 [gc]",
+		"machine-99: 2014-03-24 22:34:25 INFO juju.cmd supercommand.go:297 running juju-1.17.7.1-trusty-amd64 [gc]",
+		tag + ": 2014-03-24 22:34:26 DEBUG juju.agent agent.go:384 read agent config, format \"1.18\"",
+		"machine-99: 2014-03-24 22:34:26 ERROR juju.worker runner.go:207 exited \"api\": cannot connect",
+		tag + ": 2014-03-24 22:34:27 ERROR juju.worker runner.go:207 exited \"uniter\": boom",
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	err := ioutil.WriteFile(filepath.Join(s.LogDir, "all-machines.log"), []byte(content), 0644)
+	c.Assert(err, gc.IsNil)
+	return []string{lines[0], lines[2], lines[4]}
+}
+
+func (s *machineLogSuite) machineLogURL(c *gc.C, id string, query url.Values) string {
+	uri := s.baseURL(c)
+	uri.Path = fmt.Sprintf("/machine/%s/log", id)
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
+
+func (s *machineLogSuite) getLog(c *gc.C, query url.Values) []string {
+	resp, err := s.authRequest(c, "GET", s.machineLogURL(c, s.machine.Id(), query), "", nil)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "text/plain; charset=utf-8")
+	if len(body) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+}
+
+func (s *machineLogSuite) assertError(c *gc.C, resp *http.Response, expCode int, expError string) {
+	body := assertResponse(c, resp, expCode, "application/json")
+	var result params.ErrorResult
+	err := json.Unmarshal(body, &result)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Error, gc.NotNil)
+	c.Check(result.Error.Message, gc.Matches, expError)
+}
+
+func (s *machineLogSuite) TestRequiresAuth(c *gc.C) {
+	resp, err := s.sendRequest(c, "", "", "GET", s.machineLogURL(c, s.machine.Id(), nil), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertError(c, resp, http.StatusUnauthorized, "unauthorized")
+}
+
+func (s *machineLogSuite) TestRequiresGET(c *gc.C) {
+	resp, err := s.authRequest(c, "POST", s.machineLogURL(c, s.machine.Id(), nil), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertError(c, resp, http.StatusMethodNotAllowed, `unsupported method: "POST"`)
+}
+
+func (s *machineLogSuite) TestServesMachineLog(c *gc.C) {
+	expected := s.writeLog(c)
+	c.Assert(s.getLog(c, nil), gc.DeepEquals, expected)
+}
+
+func (s *machineLogSuite) TestTail(c *gc.C) {
+	expected := s.writeLog(c)
+	c.Assert(s.getLog(c, url.Values{"tail": {"2"}}), gc.DeepEquals, expected[1:])
+}
+
+func (s *machineLogSuite) TestTailCountsMatchingLines(c *gc.C) {
+	// The machine's lines are followed by more lines from another
+	// machine, and below the level, than are asked for.
+	tag := s.machine.Tag().String()
+	lines := []string{
+		tag + ": 2014-03-24 22:34:25 ERROR juju.worker runner.go:207 exited \"uniter\": boom",
+		tag + ": 2014-03-24 22:34:26 ERROR juju.worker runner.go:207 exited \"uniter\": bang",
+	}
+	for i := 0; i < 5; i++ {
+		lines = append(lines,
+			"machine-99: 2014-03-24 22:34:27 ERROR juju.worker runner.go:207 exited \"api\": cannot connect",
+			tag+": 2014-03-24 22:34:27 DEBUG juju.agent agent.go:384 read agent config",
+		)
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	err := ioutil.WriteFile(filepath.Join(s.LogDir, "all-machines.log"), []byte(content), 0644)
+	c.Assert(err, gc.IsNil)
+	c.Assert(s.getLog(c, url.Values{"tail": {"2"}, "level": {"ERROR"}}), gc.DeepEquals, lines[:2])
+}
+
+func (s *machineLogSuite) TestLevel(c *gc.C) {
+	expected := s.writeLog(c)
+	c.Assert(s.getLog(c, url.Values{"level": {"ERROR"}}), gc.DeepEquals, expected[2:])
+}
+
+func (s *machineLogSuite) TestBadParams(c *gc.C) {
+	s.writeLog(c)
+	uri := s.machineLogURL(c, s.machine.Id(), url.Values{"tail": {"foo"}})
+	resp, err := s.authRequest(c, "GET", uri, "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertError(c, resp, http.StatusBadRequest, `tail value "foo" is not a valid unsigned number`)
+}
+
+func (s *machineLogSuite) TestUnknownMachine(c *gc.C) {
+	s.writeLog(c)
+	resp, err := s.authRequest(c, "GET", s.machineLogURL(c, "99", nil), "", nil)
+	c.Assert(err, gc.IsNil)
+	s.assertError(c, resp, http.StatusNotFound, `machine 99 not found`)
+}
+
+func (s *machineLogSuite) TestReadFromEnvUUIDPath(c *gc.C) {
+	expected := s.writeLog(c)
+	environ, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	uri := s.baseURL(c)
+	uri.Path = fmt.Sprintf("/environment/%s/machine/%s/log", environ.UUID(), s.machine.Id())
+	resp, err := s.authRequest(c, "GET", uri.String(), "", nil)
+	c.Assert(err, gc.IsNil)
+	body := assertResponse(c, resp, http.StatusOK, "text/plain; charset=utf-8")
+	c.Assert(string(body), gc.Equals, strings.Join(expected, "\n")+"\n")
+}
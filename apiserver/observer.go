@@ -0,0 +1,116 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ObservedRequest describes a request handled by the API server.
+type ObservedRequest struct {
+	// Facade, Version and Method identify an RPC made over an API
+	// connection. Facade is empty for HTTP requests.
+	Facade  string
+	Version int
+	Method  string
+
+	// HTTPMethod and Path identify an HTTP request, such as a charm
+	// upload. Both are empty for RPCs.
+	HTTPMethod string
+	Path       string
+
+	// Caller holds the tag of the entity making the request, if
+	// known. For HTTP requests it is the tag the client claims,
+	// which may not have been authenticated.
+	Caller string
+}
+
+// RequestObserver is informed of each request the API server handles,
+// so that metrics may be kept about them.
+type RequestObserver interface {
+	// RequestStarted is called before the request is handled.
+	RequestStarted(req ObservedRequest)
+
+	// RequestFinished is called once the request has been handled,
+	// with the time it took. If it failed, errorCode holds the
+	// error code of an RPC, or the status code of an HTTP response.
+	RequestFinished(req ObservedRequest, duration time.Duration, errorCode string)
+}
+
+// NopRequestObserver is a RequestObserver that does nothing. It is
+// used when the server is not configured with an observer.
+type NopRequestObserver struct{}
+
+// RequestStarted implements RequestObserver.
+func (NopRequestObserver) RequestStarted(req ObservedRequest) {}
+
+// RequestFinished implements RequestObserver.
+func (NopRequestObserver) RequestFinished(req ObservedRequest, duration time.Duration, errorCode string) {
+}
+
+// observeHTTP returns a handler that passes requests on to handler,
+// informing observer of each of them.
+func observeHTTP(observer RequestObserver, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := ObservedRequest{
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+		}
+		if tag, _, err := parseBasicAuth(r); err == nil {
+			req.Caller = tag
+		}
+		observer.RequestStarted(req)
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(sw, r)
+		var errorCode string
+		if sw.status >= http.StatusBadRequest {
+			errorCode = strconv.Itoa(sw.status)
+		}
+		observer.RequestFinished(req, time.Since(start), errorCode)
+	})
+}
+
+// statusResponseWriter wraps a ResponseWriter, recording the status
+// code of the response. It passes on the optional interfaces that the
+// server's handlers rely on.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// CloseNotify implements http.CloseNotifier.
+func (w *statusResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
+// Flush implements http.Flusher.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response cannot be hijacked")
+	}
+	return hijacker.Hijack()
+}
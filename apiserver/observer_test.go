@@ -0,0 +1,152 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver_test
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/names"
+	"github.com/juju/utils"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/api"
+	"github.com/juju/juju/apiserver"
+	jujutesting "github.com/juju/juju/juju/testing"
+	"github.com/juju/juju/state"
+	coretesting "github.com/juju/juju/testing"
+)
+
+type observerSuite struct {
+	jujutesting.JujuConnSuite
+}
+
+var _ = gc.Suite(&observerSuite{})
+
+// observedCall records a call made to a recordingObserver.
+type observedCall struct {
+	finished  bool
+	req       apiserver.ObservedRequest
+	errorCode string
+}
+
+// recordingObserver is a RequestObserver that records the calls made
+// to it.
+type recordingObserver struct {
+	mu    sync.Mutex
+	calls []observedCall
+}
+
+func (o *recordingObserver) RequestStarted(req apiserver.ObservedRequest) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, observedCall{req: req})
+}
+
+func (o *recordingObserver) RequestFinished(req apiserver.ObservedRequest, duration time.Duration, errorCode string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, observedCall{finished: true, req: req, errorCode: errorCode})
+}
+
+// observed returns the calls made to the observer about requests for
+// which match returns true.
+func (o *recordingObserver) observed(match func(apiserver.ObservedRequest) bool) []observedCall {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var calls []observedCall
+	for _, call := range o.calls {
+		if match(call.req) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (s *observerSuite) startServer(c *gc.C, observer apiserver.RequestObserver) *apiserver.Server {
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	srv, err := apiserver.NewServer(s.State, listener, apiserver.ServerConfig{
+		Cert:            []byte(coretesting.ServerCert),
+		Key:             []byte(coretesting.ServerKey),
+		DataDir:         c.MkDir(),
+		RequestObserver: observer,
+	})
+	c.Assert(err, gc.IsNil)
+	s.AddCleanup(func(*gc.C) { srv.Stop() })
+	return srv
+}
+
+func (s *observerSuite) TestObservesRPCs(c *gc.C) {
+	observer := &recordingObserver{}
+	srv := s.startServer(c, observer)
+
+	stm, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, gc.IsNil)
+	err = stm.SetProvisioned("foo", "fake_nonce", nil)
+	c.Assert(err, gc.IsNil)
+	password, err := utils.RandomPassword()
+	c.Assert(err, gc.IsNil)
+	err = stm.SetPassword(password)
+	c.Assert(err, gc.IsNil)
+	st, err := api.Open(&api.Info{
+		Tag:      stm.Tag(),
+		Password: password,
+		Nonce:    "fake_nonce",
+		Addrs:    []string{srv.Addr()},
+		CACert:   coretesting.CACert,
+	}, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	defer st.Close()
+	_, err = st.Machiner().Machine(stm.Tag().(names.MachineTag))
+	c.Assert(err, gc.IsNil)
+
+	calls := observer.observed(func(req apiserver.ObservedRequest) bool {
+		return req.Facade == "Machiner" && req.Method == "Life"
+	})
+	c.Assert(calls, gc.HasLen, 2)
+	c.Assert(calls[0].finished, gc.Equals, false)
+	c.Assert(calls[1].finished, gc.Equals, true)
+	c.Assert(calls[1].errorCode, gc.Equals, "")
+	for _, call := range calls {
+		c.Assert(call.req.Caller, gc.Equals, stm.Tag().String())
+		c.Assert(call.req.Version, gc.Equals, st.BestFacadeVersion("Machiner"))
+	}
+}
+
+func (s *observerSuite) TestObservesHTTPRequests(c *gc.C) {
+	observer := &recordingObserver{}
+	srv := s.startServer(c, observer)
+
+	req, err := http.NewRequest("GET", "https://"+srv.Addr()+"/charms", nil)
+	c.Assert(err, gc.IsNil)
+	req.SetBasicAuth("user-admin", "ignored")
+	resp, err := utils.GetNonValidatingHTTPClient().Do(req)
+	c.Assert(err, gc.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, gc.Equals, http.StatusUnauthorized)
+
+	// The observer learns of the end of the request only after the
+	// response has been sent.
+	var calls []observedCall
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		calls = observer.observed(func(req apiserver.ObservedRequest) bool {
+			return req.Path == "/charms"
+		})
+		if len(calls) == 2 {
+			break
+		}
+	}
+	expected := apiserver.ObservedRequest{
+		HTTPMethod: "GET",
+		Path:       "/charms",
+		Caller:     "user-admin",
+	}
+	c.Assert(calls, gc.DeepEquals, []observedCall{
+		{req: expected},
+		{finished: true, req: expected, errorCode: "401"},
+	})
+}
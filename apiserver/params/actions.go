@@ -4,6 +4,8 @@
 package params
 
 import (
+	"time"
+
 	"github.com/juju/names"
 	"gopkg.in/juju/charm.v4"
 )
@@ -24,8 +26,21 @@ const (
 	// ActionPending is the status of an Action that has been queued up
 	// but not executed yet.
 	ActionPending string = "pending"
+
+	// ActionAborted is the status of an Action that was stopped while
+	// it was running.
+	ActionAborted string = "aborted"
+
+	// ActionWaitingForSlot is the status of a queued Action that
+	// cannot run until other Actions give up the slot it is limited
+	// by.
+	ActionWaitingForSlot string = "waiting for slot"
 )
 
+// ActionStatus is the status of an Action, one of the Action*
+// status constants above.
+type ActionStatus string
+
 // Actions is a slice of Action for bulk requests.
 type Actions struct {
 	Actions []Action `json"actions,omitempty"`
@@ -37,6 +52,44 @@ type Action struct {
 	Receiver   names.Tag              `json:"receiver"`
 	Name       string                 `json:"name"`
 	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Retry, if not nil, is the policy under which the Action is run
+	// again when it fails.
+	Retry *ActionRetryPolicy `json:"retry,omitempty"`
+
+	// Slot, if not nil, limits how many Actions queued with the same
+	// slot may run at once across the environment.
+	Slot *ActionSlot `json:"slot,omitempty"`
+
+	// Timeout, if not zero, is how long the Action may run before it
+	// is stopped. An Action enqueued without one is given the
+	// environment's default action timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// ActionSlot names a limit on how many Actions may run at once.
+type ActionSlot struct {
+	Name          string `json:"name"`
+	MaxConcurrent int    `json:"max-concurrent"`
+}
+
+// ActionRetryPolicy describes how an Action that fails is run again.
+type ActionRetryPolicy struct {
+	// MaxAttempts is the most times the Action is run, including
+	// the first.
+	MaxAttempts int `json:"max-attempts"`
+
+	// Backoff is the time waited before the first retry; it doubles
+	// for each retry after that.
+	Backoff time.Duration `json:"backoff,omitempty"`
+}
+
+// ActionAttempt describes a failed attempt to run an Action.
+type ActionAttempt struct {
+	Started   time.Time              `json:"started"`
+	Completed time.Time              `json:"completed"`
+	Message   string                 `json:"message,omitempty"`
+	Output    map[string]interface{} `json:"output,omitempty"`
 }
 
 // ActionResults is a slice of ActionResult for bulk requests.
@@ -51,6 +104,50 @@ type ActionResult struct {
 	Message string                 `json:"message,omitempty"`
 	Output  map[string]interface{} `json:"output,omitempty"`
 	Error   *Error                 `json:"error,omitempty"`
+
+	// Attempt is the number of the current attempt to run a pending
+	// Action, or of the attempt that produced the result of a
+	// finished one, starting at 1. It is zero if unknown.
+	Attempt int `json:"attempt,omitempty"`
+
+	// Attempts holds the earlier, failed, attempts to run an Action
+	// with a retry policy.
+	Attempts []ActionAttempt `json:"attempts,omitempty"`
+
+	// NotBefore, if not nil, is the time before which a pending
+	// Action that failed should not be run again.
+	NotBefore *time.Time `json:"not-before,omitempty"`
+}
+
+// ActionResultsDiff describes the differences between two sets of
+// ActionResults, matching results by receiver and Action name.
+type ActionResultsDiff struct {
+	// Added holds the results present only in the second set.
+	Added []ActionResult `json:"added,omitempty"`
+
+	// Removed holds the results present only in the first set.
+	Removed []ActionResult `json:"removed,omitempty"`
+
+	// Changed holds the results present in both sets whose status
+	// or output differ.
+	Changed []ActionResultDiff `json:"changed,omitempty"`
+}
+
+// ActionResultDiff describes how the result of an Action on a
+// receiver differs between two runs.
+type ActionResultDiff struct {
+	Receiver     names.Tag                   `json:"receiver"`
+	Name         string                      `json:"name"`
+	BeforeStatus string                      `json:"beforestatus,omitempty"`
+	AfterStatus  string                      `json:"afterstatus,omitempty"`
+	Output       map[string]ActionOutputDiff `json:"output,omitempty"`
+}
+
+// ActionOutputDiff holds the differing values of an Action output
+// key. A value is nil if the key was absent from that run's output.
+type ActionOutputDiff struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
 }
 
 // Tags wrap a slice of names.Tag for API calls.
@@ -71,6 +168,44 @@ type ActionsByReceiver struct {
 	Error    *Error         `json:"error,omitempty"`
 }
 
+// FindActionsByName holds the criteria for a FindByName API call,
+// which finds the Actions with the given name on every ActionReceiver.
+// If Status is not empty, only Actions with that status are found,
+// and if Since is non-zero, only those queued or completed within
+// that duration.
+type FindActionsByName struct {
+	Name   string        `json:"name"`
+	Status ActionStatus  `json:"status,omitempty"`
+	Since  time.Duration `json:"since,omitempty"`
+}
+
+// ActionsFilter holds the criteria for a CancelMatching or
+// ListFiltered API call.
+//
+// CancelMatching cancels the pending Actions on every ActionReceiver
+// that match all of its criteria. If Name is not empty, only Actions
+// with that name match, and if OlderThan is non-zero, only those
+// queued longer ago than that. At least one criterion must be given.
+//
+// ListFiltered lists the Actions of each of the Receivers that match
+// Name, if it is not empty, and have one of the given Statuses, if
+// any. Completed Actions match only if they completed after
+// CompletedAfter and before CompletedBefore, where given; the window
+// does not apply to queued Actions. The matching Actions of each
+// receiver are skipped by Offset and, if Limit is positive, cut down
+// to at most Limit.
+type ActionsFilter struct {
+	Name      string        `json:"name,omitempty"`
+	OlderThan time.Duration `json:"older-than,omitempty"`
+
+	Receivers       []names.Tag `json:"receivers,omitempty"`
+	Statuses        []string    `json:"statuses,omitempty"`
+	CompletedAfter  *time.Time  `json:"completed-after,omitempty"`
+	CompletedBefore *time.Time  `json:"completed-before,omitempty"`
+	Offset          int         `json:"offset,omitempty"`
+	Limit           int         `json:"limit,omitempty"`
+}
+
 // ActionTags are an array of ActionTag for bulk API calls
 type ActionTags struct {
 	Actions []names.ActionTag `json:"actions,omitempty"`
@@ -102,6 +237,30 @@ type ActionExecutionResult struct {
 	Status    string                 `json:"status"`
 	Results   map[string]interface{} `json:"results,omitempty"`
 	Message   string                 `json:"message,omitempty"`
+	Usage     *ActionResourceUsage   `json:"usage,omitempty"`
+}
+
+// ActionResourceUsage describes the resources an Action consumed while
+// it ran, as captured by the unit agent.
+type ActionResourceUsage struct {
+	// CPUTime is the processor time, user and system, consumed.
+	CPUTime time.Duration `json:"cputime"`
+
+	// PeakMemory is the largest resident set size reached, in bytes.
+	PeakMemory uint64 `json:"peakmemory"`
+}
+
+// ActionResourceUsageResults holds a slice of ActionResourceUsageResult
+// for a bulk ResourceUsage API call.
+type ActionResourceUsageResults struct {
+	Results []ActionResourceUsageResult `json:"results,omitempty"`
+}
+
+// ActionResourceUsageResult holds the resource usage of an Action, or
+// an error if it is not known.
+type ActionResourceUsageResult struct {
+	Usage ActionResourceUsage `json:"usage"`
+	Error *Error              `json:"error,omitempty"`
 }
 
 // ServicesCharmActionsResults holds a slice of ServiceCharmActionsResult for
@@ -118,3 +277,158 @@ type ServiceCharmActionsResult struct {
 	Actions    *charm.Actions   `json:"actions,omitempty"`
 	Error      *Error           `json:"error,omitempty"`
 }
+
+// ServicesActionsYAMLResults holds a slice of ServiceActionsYAMLResult
+// for a bulk request for the actions.yaml of services' charms.
+type ServicesActionsYAMLResults struct {
+	Results []ServiceActionsYAMLResult `json:"results,omitempty"`
+}
+
+// ServiceActionsYAMLResult holds the raw content of the actions.yaml
+// file in a service's charm, or the error encountered reading it.
+type ServiceActionsYAMLResult struct {
+	ServiceTag names.ServiceTag `json:"servicetag,omitempty"`
+	YAML       []byte           `json:"yaml,omitempty"`
+	Error      *Error           `json:"error,omitempty"`
+}
+
+// ActionSpecs holds the action specs declared by a service's charm,
+// keyed by action name, or the error encountered reading them.
+type ActionSpecs struct {
+	Specs map[string]charm.ActionSpec `json:"specs,omitempty"`
+	Error *Error                      `json:"error,omitempty"`
+}
+
+// ActionQueuePositionResults holds a slice of ActionQueuePositionResult
+// for a bulk QueuePositions API call.
+type ActionQueuePositionResults struct {
+	Results []ActionQueuePositionResult `json:"results,omitempty"`
+}
+
+// ActionQueuePositionResult holds the zero-based position of a pending
+// Action in its receiver's queue, or an error if the Action is no
+// longer pending.
+type ActionQueuePositionResult struct {
+	Position int    `json:"position"`
+	Error    *Error `json:"error,omitempty"`
+}
+
+// ActionEvent describes a change in the lifecycle of an Action.
+type ActionEvent struct {
+	Tag      names.ActionTag `json:"tag"`
+	Receiver names.Tag       `json:"receiver"`
+	Name     string          `json:"name"`
+	Status   string          `json:"status"`
+}
+
+// ActionsWatchResult holds an ActionsWatcher id, changes and an error
+// (if any).
+type ActionsWatchResult struct {
+	ActionsWatcherId string        `json:"actionswatcherid"`
+	Changes          []ActionEvent `json:"changes,omitempty"`
+	Error            *Error        `json:"error,omitempty"`
+}
+
+// ServiceActionOutputs holds a slice of ServiceActionOutput for a bulk
+// ServiceOutputs API call.
+type ServiceActionOutputs struct {
+	Outputs []ServiceActionOutput `json:"outputs,omitempty"`
+}
+
+// ServiceActionOutput identifies the named Action whose most recent
+// result is wanted for every unit of a service. If Since is non-zero,
+// only results completed within that duration are considered.
+type ServiceActionOutput struct {
+	ServiceTag names.ServiceTag `json:"servicetag"`
+	Name       string           `json:"name"`
+	Since      time.Duration    `json:"since,omitempty"`
+}
+
+// ServiceActionOutputResults holds a slice of ServiceActionOutputResult
+// for a bulk ServiceOutputs API call.
+type ServiceActionOutputResults struct {
+	Results []ServiceActionOutputResult `json:"results,omitempty"`
+}
+
+// ServiceActionOutputResult holds the most recent result of an Action
+// for each unit of a service, keyed by unit name.
+type ServiceActionOutputResult struct {
+	Results map[string]ActionResult `json:"results,omitempty"`
+	Error   *Error                  `json:"error,omitempty"`
+}
+
+// LatestActionResultArgs holds the ActionReceivers and the name of the
+// Action for a bulk LatestResults API call.
+type LatestActionResultArgs struct {
+	Receivers []names.Tag `json:"receivers"`
+	Name      string      `json:"name"`
+}
+
+// MissingActionArgs holds the services and the name of the Action for
+// a bulk MissingFor API call.
+type MissingActionArgs struct {
+	Services []names.ServiceTag `json:"services"`
+	Name     string             `json:"name"`
+}
+
+// MissingActionResults holds a slice of MissingActionResult for a bulk
+// MissingFor API call.
+type MissingActionResults struct {
+	Results []MissingActionResult `json:"results,omitempty"`
+}
+
+// MissingActionResult holds the units of a service that have no
+// completed run of an Action.
+type MissingActionResult struct {
+	Units Tags   `json:"units"`
+	Error *Error `json:"error,omitempty"`
+}
+
+// ActionDurationArgs holds the ActionReceivers and the name of the
+// Action for a bulk Durations API call.
+type ActionDurationArgs struct {
+	Receivers []names.Tag `json:"receivers"`
+	Name      string      `json:"name"`
+}
+
+// ActionDurationResults holds a slice of ActionDuration for a bulk
+// Durations API call.
+type ActionDurationResults struct {
+	Results []ActionDuration `json:"results,omitempty"`
+}
+
+// ActionDuration holds how long each completed run of an Action on an
+// ActionReceiver took, in the order they were queued.
+type ActionDuration struct {
+	Receiver  names.Tag       `json:"receiver"`
+	Durations []time.Duration `json:"durations,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+}
+
+// ActionDrainEstimates holds a slice of ActionDrainEstimate for a bulk
+// EstimateDrains API call.
+type ActionDrainEstimates struct {
+	Results []ActionDrainEstimate `json:"results,omitempty"`
+}
+
+// ActionDrainEstimate holds an estimate of how long it will take to
+// run all the Actions currently queued for an ActionReceiver.
+type ActionDrainEstimate struct {
+	Receiver names.Tag     `json:"receiver"`
+	Duration time.Duration `json:"duration"`
+	Error    *Error        `json:"error,omitempty"`
+}
+
+// ActionDefaultTimeout holds the timeout given to Actions enqueued
+// without one of their own; zero means they may run for as long as
+// they need.
+type ActionDefaultTimeout struct {
+	Timeout time.Duration `json:"timeout"`
+}
+
+// ActionFacadeCaps describes the capabilities of the Actions facade
+// provided by an API server.
+type ActionFacadeCaps struct {
+	Version int      `json:"version"`
+	Methods []string `json:"methods"`
+}
@@ -49,6 +49,7 @@ const (
 	CodeNotImplemented      = rpc.CodeNotImplemented
 	CodeAlreadyExists       = "already exists"
 	CodeUpgradeInProgress   = "upgrade in progress"
+	CodeActionSlotFull      = "action slot full"
 )
 
 // ErrCode returns the error code associated with
@@ -150,3 +151,7 @@ func IsCodeAlreadyExists(err error) bool {
 func IsCodeUpgradeInProgress(err error) bool {
 	return ErrCode(err) == CodeUpgradeInProgress
 }
+
+func IsCodeActionSlotFull(err error) bool {
+	return ErrCode(err) == CodeActionSlotFull
+}
@@ -597,10 +597,17 @@ type RelationUnitsWatchResults struct {
 }
 
 // CharmsResponse is the server response to charm upload or GET requests.
+// Sha256 and Size describe the archive stored for an uploaded charm.
+// UploadId and Received describe the progress of an upload whose
+// archive is sent in chunks.
 type CharmsResponse struct {
 	Error    string   `json:",omitempty"`
 	CharmURL string   `json:",omitempty"`
 	Files    []string `json:",omitempty"`
+	Sha256   string   `json:",omitempty"`
+	Size     int64    `json:",omitempty"`
+	UploadId string   `json:",omitempty"`
+	Received int64    `json:",omitempty"`
 }
 
 // RunParams is used to provide the parameters to the Run method.
@@ -303,6 +303,16 @@ type LoginRequest struct {
 	AuthTag     string `json:"auth-tag"`
 	Credentials string `json:"credentials"`
 	Nonce       string `json:"nonce"`
+
+	// ResumeToken, if set, asks to resume the agent session that was
+	// issued the token at an earlier login.
+	ResumeToken string `json:"resume-token,omitempty"`
+
+	// Resumable asks for the agent's session to be kept when its
+	// connection closes, so that it may be resumed with the token
+	// issued in the result. It is ignored if the server does not
+	// keep sessions.
+	Resumable bool `json:"resumable,omitempty"`
 }
 
 // LoginRequestCompat holds credentials for identifying an entity to the Login v1
@@ -666,6 +676,27 @@ type ProvisioningScriptResult struct {
 	Script string
 }
 
+// AgentPresenceHistory holds the arguments for the AgentPresenceHistory
+// client API call. If Since is non-zero, only events that occurred
+// within that duration are returned.
+type AgentPresenceHistory struct {
+	Tag   string
+	Since time.Duration
+}
+
+// PresenceEvent records a machine or unit agent connecting to, or
+// disconnecting from, the API server.
+type PresenceEvent struct {
+	Time      time.Time
+	Connected bool
+}
+
+// PresenceEvents holds the result of an AgentPresenceHistory client
+// API call, oldest event first.
+type PresenceEvents struct {
+	Events []PresenceEvent
+}
+
 // EnvironmentGetResults contains the result of EnvironmentGet client
 // API call.
 type EnvironmentGetResults struct {
@@ -710,6 +741,14 @@ type SetEnvironAgentVersion struct {
 	Version version.Number
 }
 
+// SetEnvironConfigError contains the arguments for the
+// SetEnvironConfigError provisioner API call.
+type SetEnvironConfigError struct {
+	// Reason holds why the environment configuration could not be
+	// used, or is empty if it was found usable.
+	Reason string
+}
+
 // DeployerConnectionValues containers the result of deployer.ConnectionInfo
 // API call.
 type DeployerConnectionValues struct {
@@ -817,6 +856,15 @@ type LoginResultV1 struct {
 	// Facades describes all the available API facade versions to the
 	// authenticated client.
 	Facades []FacadeVersions `json:"facades"`
+
+	// ResumeToken is issued to agents that asked for a resumable
+	// session, which may pass it to a later login to resume the
+	// session, if it has not expired.
+	ResumeToken string `json:"resume-token,omitempty"`
+
+	// Resumed reports whether the login resumed an earlier session,
+	// in which case the agent's existing watchers remain valid.
+	Resumed bool `json:"resumed,omitempty"`
 }
 
 // StateServersSpec contains arguments for
@@ -888,6 +936,18 @@ type FindToolsResult struct {
 	Error *Error
 }
 
+// SupportedBase holds a series and architecture combination.
+type SupportedBase struct {
+	Series string `json:"series"`
+	Arch   string `json:"arch"`
+}
+
+// SupportedBases holds the series and architecture combinations
+// supported by an environment, as returned by Client.SupportedBases.
+type SupportedBases struct {
+	Bases []SupportedBase `json:"bases"`
+}
+
 // RebootActionResults holds a list of RebootActionResult and any error.
 type RebootActionResults struct {
 	Results []RebootActionResult `json:results,omitempty`
@@ -0,0 +1,46 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package provisioner
+
+import (
+	"github.com/juju/juju/apiserver/common"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/state"
+)
+
+func init() {
+	common.RegisterStandardFacade("Provisioner", 1, NewProvisionerAPIV1)
+}
+
+// ProvisionerAPIV1 implements the API facade version 1, used by the
+// provisioner worker. It adds SetEnvironConfigError to version 0.
+type ProvisionerAPIV1 struct {
+	ProvisionerAPI
+}
+
+// NewProvisionerAPIV1 creates a new instance of the Provisioner API,
+// version 1.
+func NewProvisionerAPIV1(st *state.State, resources *common.Resources, authorizer common.Authorizer) (*ProvisionerAPIV1, error) {
+	apiV0, err := NewProvisionerAPI(st, resources, authorizer)
+	if err != nil {
+		return nil, err
+	}
+	return &ProvisionerAPIV1{
+		ProvisionerAPI: *apiV0,
+	}, nil
+}
+
+// SetEnvironConfigError records why the provisioner last found the
+// environment configuration unusable, so that clients can report it.
+// An empty reason records that the configuration was usable.
+func (p *ProvisionerAPIV1) SetEnvironConfigError(args params.SetEnvironConfigError) error {
+	if !p.authorizer.AuthEnvironManager() {
+		return common.ErrPerm
+	}
+	env, err := p.st.Environment()
+	if err != nil {
+		return err
+	}
+	return env.SetConfigError(args.Reason)
+}
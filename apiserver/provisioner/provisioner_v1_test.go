@@ -0,0 +1,62 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package provisioner_test
+
+import (
+	"github.com/juju/names"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/apiserver/provisioner"
+)
+
+type provisionerV1Suite struct {
+	provisionerSuite
+
+	provisionerV1 *provisioner.ProvisionerAPIV1
+}
+
+var _ = gc.Suite(&provisionerV1Suite{})
+
+func (s *provisionerV1Suite) SetUpTest(c *gc.C) {
+	s.setUpTest(c, false)
+
+	provisionerAPIV1, err := provisioner.NewProvisionerAPIV1(
+		s.State,
+		s.resources,
+		s.authorizer,
+	)
+	c.Assert(err, gc.IsNil)
+	s.provisionerV1 = provisionerAPIV1
+}
+
+func (s *provisionerV1Suite) TestSetEnvironConfigError(c *gc.C) {
+	err := s.provisionerV1.SetEnvironConfigError(params.SetEnvironConfigError{
+		Reason: `no registered provider for "unknown"`,
+	})
+	c.Assert(err, gc.IsNil)
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, `no registered provider for "unknown"`)
+
+	err = s.provisionerV1.SetEnvironConfigError(params.SetEnvironConfigError{})
+	c.Assert(err, gc.IsNil)
+	err = env.Refresh()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, "")
+}
+
+func (s *provisionerV1Suite) TestSetEnvironConfigErrorNeedsEnvironManager(c *gc.C) {
+	anAuthorizer := s.authorizer
+	anAuthorizer.Tag = names.NewMachineTag("1")
+	anAuthorizer.EnvironManager = false
+	aProvisioner, err := provisioner.NewProvisionerAPIV1(s.State, s.resources, anAuthorizer)
+	c.Assert(err, gc.IsNil)
+
+	err = aProvisioner.SetEnvironConfigError(params.SetEnvironConfigError{Reason: "boom"})
+	c.Assert(err, gc.ErrorMatches, "permission denied")
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, "")
+}
@@ -46,6 +46,17 @@ type apiHandler struct {
 	rpcConn   *rpc.Conn
 	resources *common.Resources
 	entity    state.Entity
+
+	// sessions holds the server's resumable sessions, and
+	// resumeToken the token issued to the agent at login, if any.
+	sessions    *SessionStore
+	resumeToken string
+
+	// pingerId holds the id of the agent's presence pinger, if any.
+	pingerId string
+
+	// remoteAddr holds the address of the client.
+	remoteAddr string
 }
 
 var _ = (*apiHandler)(nil)
@@ -56,6 +67,7 @@ func newApiHandler(srv *Server, rpcConn *rpc.Conn, reqNotifier *requestNotifier)
 		state:     srv.state,
 		resources: common.NewResources(),
 		rpcConn:   rpcConn,
+		sessions:  srv.sessions,
 	}
 	if err := r.resources.RegisterNamed("dataDir", common.StringResource(srv.dataDir)); err != nil {
 		return nil, err
@@ -76,8 +88,41 @@ func (r *apiHandler) getRpcConn() *rpc.Conn {
 
 // Kill implements rpc.Killer.  It cleans up any resources that need
 // cleaning up to ensure that all outstanding requests return.
+// If an agent was issued a resume token, which happens only when
+// the server keeps sessions and the agent asked to be able to
+// resume, its resources are kept for it to resume instead, apart
+// from those that belong to the connection itself.
 func (r *apiHandler) Kill() {
-	r.resources.StopAll()
+	if r.resumeToken == "" {
+		r.resources.StopAll()
+		return
+	}
+	if r.pingerId != "" {
+		if err := r.resources.Stop(r.pingerId); err != nil {
+			logger.Errorf("error stopping pinger: %v", err)
+		}
+	}
+	r.resources.Stop("pingTimeout")
+	r.sessions.park(r.resumeToken, r.entity.Tag().String(), r.resources)
+}
+
+// resumeSession resumes the session held under the given token,
+// if the agent may do so, and issues the agent with a new token.
+// It reports whether the session was resumed.
+func (r *apiHandler) resumeSession(token string) (bool, error) {
+	var resumed bool
+	if token != "" {
+		if resources, ok := r.sessions.resume(token, r.entity.Tag().String()); ok {
+			r.resources.Adopt(resources)
+			resumed = true
+		}
+	}
+	newToken, err := newResumeToken()
+	if err != nil {
+		return false, err
+	}
+	r.resumeToken = newToken
+	return resumed, nil
 }
 
 // srvCaller is our implementation of the rpcreflect.MethodCaller interface.
@@ -133,6 +178,12 @@ func newApiRoot(srv *Server, resources *common.Resources, authorizer common.Auth
 }
 
 func (r *apiRoot) Kill() {
+	if h, ok := r.authorizer.(*apiHandler); ok {
+		// The connection's handler decides whether its
+		// resources outlive the connection.
+		h.Kill()
+		return
+	}
 	r.resources.StopAll()
 }
 
@@ -29,6 +29,7 @@ import (
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/state/presence"
 	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/testing/factory"
 )
 
 func TestAll(t *stdtesting.T) {
@@ -94,6 +95,350 @@ func (s *serverSuite) TestStop(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 }
 
+func (s *serverSuite) TestShutdown(c *gc.C) {
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	srv, err := apiserver.NewServer(s.State, listener, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	stm, password := s.addProvisionedMachine(c)
+	st := openAsMachine(c, srv, stm, password, "")
+	defer st.Close()
+	_, err = st.Machiner().Machine(stm.Tag().(names.MachineTag))
+	c.Assert(err, gc.IsNil)
+
+	// With no requests in progress, the connection is closed at once.
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown(coretesting.LongWait)
+	}()
+	select {
+	case err := <-shutdown:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for shutdown")
+	}
+	_, err = st.Machiner().Machine(stm.Tag().(names.MachineTag))
+	if err != rpc.ErrShutdown && err != io.ErrUnexpectedEOF {
+		c.Fatalf("unexpected error from request: %v", err)
+	}
+
+	// The server can still be stopped, and shut down again.
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+	err = srv.Shutdown(coretesting.LongWait)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *serverSuite) TestLoginFailuresRateLimited(c *gc.C) {
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	srv, err := apiserver.NewServer(s.State, listener, apiserver.ServerConfig{
+		Cert:              []byte(coretesting.ServerCert),
+		Key:               []byte(coretesting.ServerKey),
+		MaxAuthFailures:   1,
+		AuthFailureWindow: time.Hour,
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	stm, password := s.addProvisionedMachine(c)
+	info := &api.Info{
+		Tag:      stm.Tag(),
+		Password: "wrong",
+		Nonce:    "fake_nonce",
+		Addrs:    []string{srv.Addr()},
+		CACert:   coretesting.CACert,
+	}
+	_, err = api.Open(info, fastDialOpts)
+	c.Assert(err, gc.ErrorMatches, "invalid entity name or password")
+
+	// Once the failures are used up, even a good password is refused.
+	info.Password = password
+	_, err = api.Open(info, fastDialOpts)
+	c.Assert(err, gc.ErrorMatches, "try again")
+}
+
+func (s *serverSuite) TestStopMultipleListeners(c *gc.C) {
+	var listeners []net.Listener
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", ":0")
+		c.Assert(err, gc.IsNil)
+		listeners = append(listeners, listener)
+	}
+	srv, err := apiserver.NewServerMulti(s.State, listeners, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+	addrs := srv.Addrs()
+	c.Assert(addrs, gc.DeepEquals, []string{
+		listeners[0].Addr().String(),
+		listeners[1].Addr().String(),
+	})
+	_, port, err := net.SplitHostPort(addrs[0])
+	c.Assert(err, gc.IsNil)
+	c.Assert(srv.Addr(), gc.Equals, net.JoinHostPort("localhost", port))
+
+	// The server can be reached at each of its addresses.
+	stm, password := s.addProvisionedMachine(c)
+	for _, addr := range addrs {
+		st, err := api.Open(&api.Info{
+			Tag:      stm.Tag(),
+			Password: password,
+			Nonce:    "fake_nonce",
+			Addrs:    []string{addr},
+			CACert:   coretesting.CACert,
+		}, fastDialOpts)
+		c.Assert(err, gc.IsNil)
+		st.Close()
+	}
+
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+
+	// No listener accepts connections once the server has stopped.
+	for _, lis := range listeners {
+		_, err := net.Dial("tcp", lis.Addr().String())
+		c.Assert(err, gc.NotNil)
+	}
+
+	// Check it can be stopped twice.
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *serverSuite) TestAddrsDualStackSamePort(c *gc.C) {
+	ipv4, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	_, port, err := net.SplitHostPort(ipv4.Addr().String())
+	c.Assert(err, gc.IsNil)
+	ipv6, err := net.Listen("tcp", net.JoinHostPort("::1", port))
+	if err != nil {
+		ipv4.Close()
+		c.Skip(fmt.Sprintf("cannot listen on IPv6 loopback: %v", err))
+	}
+	srv, err := apiserver.NewServerMulti(s.State, []net.Listener{ipv4, ipv6}, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.IsNil)
+	defer srv.Stop()
+
+	c.Assert(srv.Addrs(), gc.DeepEquals, []string{
+		net.JoinHostPort("127.0.0.1", port),
+		net.JoinHostPort("::1", port),
+	})
+	c.Assert(srv.Addr(), gc.Equals, net.JoinHostPort("localhost", port))
+}
+
+func (s *serverSuite) TestNewServerMultiRequiresListener(c *gc.C) {
+	_, err := apiserver.NewServerMulti(s.State, nil, apiserver.ServerConfig{
+		Cert: []byte(coretesting.ServerCert),
+		Key:  []byte(coretesting.ServerKey),
+	})
+	c.Assert(err, gc.ErrorMatches, "no listeners specified")
+}
+
+// startSessionServer starts an API server that keeps agent
+// sessions in the given store.
+func (s *serverSuite) startSessionServer(c *gc.C, sessions *apiserver.SessionStore) *apiserver.Server {
+	listener, err := net.Listen("tcp", ":0")
+	c.Assert(err, gc.IsNil)
+	srv, err := apiserver.NewServer(s.State, listener, apiserver.ServerConfig{
+		Cert:     []byte(coretesting.ServerCert),
+		Key:      []byte(coretesting.ServerKey),
+		Sessions: sessions,
+	})
+	c.Assert(err, gc.IsNil)
+	return srv
+}
+
+// openAsMachine logs into the API as the given machine, asking for
+// a resumable session and passing the given resume token.
+func openAsMachine(c *gc.C, srv *apiserver.Server, m *state.Machine, password, resumeToken string) *api.State {
+	st := openAsMachineResumable(c, srv, m, password, resumeToken, true)
+	c.Assert(st.ResumeToken(), gc.Not(gc.Equals), "")
+	return st
+}
+
+// openAsMachineResumable logs into the API as the given machine,
+// passing the given resume token, and asking for a resumable session
+// if resumable is true.
+func openAsMachineResumable(c *gc.C, srv *apiserver.Server, m *state.Machine, password, resumeToken string, resumable bool) *api.State {
+	st, err := api.Open(&api.Info{
+		Tag:         m.Tag(),
+		Password:    password,
+		Nonce:       "fake_nonce",
+		Addrs:       []string{srv.Addr()},
+		CACert:      coretesting.CACert,
+		ResumeToken: resumeToken,
+		Resumable:   resumable,
+	}, fastDialOpts)
+	c.Assert(err, gc.IsNil)
+	return st
+}
+
+// watchMachine starts a watcher on the given machine and returns
+// its id.
+func watchMachine(c *gc.C, st *api.State, m *state.Machine) string {
+	var results params.NotifyWatchResults
+	err := st.APICall("Machiner", st.BestFacadeVersion("Machiner"), "", "Watch", params.Entities{
+		Entities: []params.Entity{{Tag: m.Tag().String()}},
+	}, &results)
+	c.Assert(err, gc.IsNil)
+	c.Assert(results.Results, gc.HasLen, 1)
+	c.Assert(results.Results[0].Error, gc.IsNil)
+	return results.Results[0].NotifyWatcherId
+}
+
+func (s *serverSuite) addProvisionedMachine(c *gc.C) (*state.Machine, string) {
+	stm, err := s.State.AddMachine("quantal", state.JobHostUnits)
+	c.Assert(err, gc.IsNil)
+	err = stm.SetProvisioned("foo", "fake_nonce", nil)
+	c.Assert(err, gc.IsNil)
+	password, err := utils.RandomPassword()
+	c.Assert(err, gc.IsNil)
+	err = stm.SetPassword(password)
+	c.Assert(err, gc.IsNil)
+	return stm, password
+}
+
+func (s *serverSuite) TestResumeSessionAfterRestart(c *gc.C) {
+	sessions := apiserver.NewSessionStore(coretesting.LongWait)
+	defer sessions.Close()
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, sessions)
+	st := openAsMachine(c, srv, stm, password, "")
+	c.Assert(st.Resumed(), jc.IsFalse)
+	watcherId := watchMachine(c, st, stm)
+	token := st.ResumeToken()
+	err := srv.Stop()
+	c.Assert(err, gc.IsNil)
+	st.Close()
+	parked := apiserver.ParkedResourceCount(sessions)
+	c.Assert(parked, jc.GreaterThan, 0)
+
+	srv = s.startSessionServer(c, sessions)
+	st = openAsMachine(c, srv, stm, password, token)
+	c.Assert(st.Resumed(), jc.IsTrue)
+	c.Assert(st.ResumeToken(), gc.Not(gc.Equals), token)
+	token = st.ResumeToken()
+
+	// The resumed agent carries on with the watcher from the earlier
+	// session, without calling Watch again, and sees changes made
+	// while it was away.
+	err = stm.SetMachineAddresses(network.NewAddress("0.1.2.3", network.ScopeUnknown))
+	c.Assert(err, gc.IsNil)
+	version := st.BestFacadeVersion("NotifyWatcher")
+	err = st.APICall("NotifyWatcher", version, watcherId, "Next", nil, nil)
+	c.Assert(err, gc.IsNil)
+
+	// Having registered nothing new, it leaves no more resources
+	// behind than it did the first time.
+	err = srv.Stop()
+	c.Assert(err, gc.IsNil)
+	st.Close()
+	c.Assert(apiserver.ParkedResourceCount(sessions), gc.Equals, parked)
+
+	srv = s.startSessionServer(c, sessions)
+	defer srv.Stop()
+	st = openAsMachine(c, srv, stm, password, token)
+	defer st.Close()
+	c.Assert(st.Resumed(), jc.IsTrue)
+	c.Assert(apiserver.ParkedResourceCount(sessions), gc.Equals, 0)
+	err = st.APICall("NotifyWatcher", version, watcherId, "Stop", nil, nil)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *serverSuite) TestResumeSessionUnknownToken(c *gc.C) {
+	sessions := apiserver.NewSessionStore(coretesting.LongWait)
+	defer sessions.Close()
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, sessions)
+	defer srv.Stop()
+	st := openAsMachine(c, srv, stm, password, "no-such-token")
+	defer st.Close()
+	c.Assert(st.Resumed(), jc.IsFalse)
+}
+
+func (s *serverSuite) TestResumeSessionExpired(c *gc.C) {
+	sessions := apiserver.NewSessionStore(time.Millisecond)
+	defer sessions.Close()
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, sessions)
+	defer srv.Stop()
+	st := openAsMachine(c, srv, stm, password, "")
+	watcherId := watchMachine(c, st, stm)
+	token := st.ResumeToken()
+	st.Close()
+	time.Sleep(coretesting.ShortWait)
+
+	// The agent must start its watchers again, since the old ones
+	// have gone.
+	st = openAsMachine(c, srv, stm, password, token)
+	defer st.Close()
+	c.Assert(st.Resumed(), jc.IsFalse)
+	err := st.APICall("NotifyWatcher", st.BestFacadeVersion("NotifyWatcher"), watcherId, "Stop", nil, nil)
+	c.Assert(err, gc.ErrorMatches, "unknown watcher id")
+}
+
+func (s *serverSuite) TestSessionNotKeptUnlessAsked(c *gc.C) {
+	sessions := apiserver.NewSessionStore(coretesting.LongWait)
+	defer sessions.Close()
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, sessions)
+	defer srv.Stop()
+	st := openAsMachineResumable(c, srv, stm, password, "", false)
+	c.Assert(st.ResumeToken(), gc.Equals, "")
+	watcherId := watchMachine(c, st, stm)
+	st.Close()
+
+	// The watcher was stopped along with the connection.
+	st = openAsMachine(c, srv, stm, password, "")
+	defer st.Close()
+	err := st.APICall("NotifyWatcher", st.BestFacadeVersion("NotifyWatcher"), watcherId, "Stop", nil, nil)
+	c.Assert(err, gc.ErrorMatches, "unknown watcher id")
+}
+
+func (s *serverSuite) TestSessionNotKeptWithoutSessionStore(c *gc.C) {
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, nil)
+	defer srv.Stop()
+	st := openAsMachineResumable(c, srv, stm, password, "", true)
+	defer st.Close()
+	c.Assert(st.ResumeToken(), gc.Equals, "")
+	c.Assert(st.Resumed(), jc.IsFalse)
+}
+
+func (s *serverSuite) TestSessionNotKeptAfterStoreClosed(c *gc.C) {
+	sessions := apiserver.NewSessionStore(coretesting.LongWait)
+	stm, password := s.addProvisionedMachine(c)
+
+	srv := s.startSessionServer(c, sessions)
+	defer srv.Stop()
+	st := openAsMachine(c, srv, stm, password, "")
+	token := st.ResumeToken()
+	err := sessions.Close()
+	c.Assert(err, gc.IsNil)
+	// The session is parked only once the store has been closed.
+	st.Close()
+
+	st = openAsMachineResumable(c, srv, stm, password, token, true)
+	defer st.Close()
+	c.Assert(st.Resumed(), jc.IsFalse)
+}
+
 func (s *serverSuite) TestAPIServerCanListenOnBothIPv4AndIPv6(c *gc.C) {
 	err := s.State.SetAPIHostPorts(nil)
 	c.Assert(err, gc.IsNil)
@@ -277,6 +622,42 @@ func (s *serverSuite) TestUnitLoginStartsPinger(c *gc.C) {
 	s.assertAlive(c, unit, false)
 }
 
+func (s *serverSuite) TestAgentPresenceHistory(c *gc.C) {
+	password, err := utils.RandomPassword()
+	c.Assert(err, gc.IsNil)
+	machine := s.Factory.MakeMachine(c, &factory.MachineParams{
+		Nonce:    "fake_nonce",
+		Password: password,
+	})
+
+	events, err := s.APIState.Client().AgentPresenceHistory(machine.Tag(), 0)
+	c.Assert(err, gc.IsNil)
+	c.Assert(events.Events, gc.HasLen, 0)
+
+	// Log in as the machine agent, then disconnect.
+	st := s.OpenAPIAsMachine(c, machine.Tag(), password, "fake_nonce")
+	c.Assert(st.Close(), gc.IsNil)
+
+	// The disconnection is recorded once the server has
+	// noticed that the connection was closed.
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		events, err = s.APIState.Client().AgentPresenceHistory(machine.Tag(), time.Hour)
+		c.Assert(err, gc.IsNil)
+		if len(events.Events) == 2 {
+			break
+		}
+	}
+	c.Assert(events.Events, gc.HasLen, 2)
+	c.Assert(events.Events[0].Connected, jc.IsTrue)
+	c.Assert(events.Events[1].Connected, jc.IsFalse)
+	c.Assert(events.Events[1].Time.Before(events.Events[0].Time), jc.IsFalse)
+}
+
+func (s *serverSuite) TestAgentPresenceHistoryNotAgent(c *gc.C) {
+	_, err := s.APIState.Client().AgentPresenceHistory(s.AdminUserTag(c), 0)
+	c.Assert(err, gc.ErrorMatches, `entity "user-.*" does not support agent presence history`)
+}
+
 func (s *serverSuite) assertAlive(c *gc.C, entity presence.Presencer, isAlive bool) {
 	s.State.StartSync()
 	alive, err := entity.AgentPresence()
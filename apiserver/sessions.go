@@ -0,0 +1,127 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/utils"
+
+	"github.com/juju/juju/apiserver/common"
+)
+
+// DefaultSessionWindow is a suitable length of time for which to keep
+// the sessions of agents whose connections have closed.
+const DefaultSessionWindow = time.Minute
+
+// SessionStore holds the resources of agent connections that have
+// closed, so that an agent that reconnects with the resume token it
+// was given at login can carry on using its existing watchers
+// rather than registering them all over again.
+//
+// A SessionStore may be shared between successive servers, which
+// lets agents resume their sessions across a server restart.
+type SessionStore struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	closed   bool
+}
+
+// session holds the resources of a single parked connection.
+type session struct {
+	tag       string
+	resources *common.Resources
+	expiry    *time.Timer
+}
+
+// NewSessionStore returns a SessionStore that keeps each session
+// for the given length of time before stopping its resources.
+func NewSessionStore(window time.Duration) *SessionStore {
+	return &SessionStore{
+		window:   window,
+		sessions: make(map[string]*session),
+	}
+}
+
+// newResumeToken returns a new unguessable resume token.
+func newResumeToken() (string, error) {
+	return utils.RandomPassword()
+}
+
+// park holds the given resources, which belong to the entity with
+// the given tag, under the given token. If the session is not
+// resumed within the store's window, or the store has been closed,
+// its resources are stopped.
+func (s *SessionStore) park(token, tag string, resources *common.Resources) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		resources.StopAll()
+		return
+	}
+	sess := &session{
+		tag:       tag,
+		resources: resources,
+	}
+	sess.expiry = time.AfterFunc(s.window, func() {
+		s.expire(token, sess)
+	})
+	if old, ok := s.sessions[token]; ok {
+		old.expiry.Stop()
+		old.resources.StopAll()
+	}
+	s.sessions[token] = sess
+}
+
+// expire stops the resources of the given session if it is still
+// held under the given token.
+func (s *SessionStore) expire(token string, sess *session) {
+	s.mu.Lock()
+	if s.sessions[token] != sess {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, token)
+	s.mu.Unlock()
+	logger.Debugf("session for %s expired", sess.tag)
+	sess.resources.StopAll()
+}
+
+// resume removes and returns the resources held under the given
+// token. It returns false if no session is held under the token or
+// if the session belongs to an entity other than the one with the
+// given tag.
+func (s *SessionStore) resume(token, tag string) (*common.Resources, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok || sess.tag != tag {
+		return nil, false
+	}
+	if !sess.expiry.Stop() {
+		// The session expired just as it was being resumed.
+		return nil, false
+	}
+	delete(s.sessions, token)
+	return sess.resources, true
+}
+
+// Close stops the resources of all the sessions held by the store,
+// and of any parked in it afterwards by connections that are still
+// closing.
+func (s *SessionStore) Close() error {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.closed = true
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		sess.expiry.Stop()
+		sess.resources.StopAll()
+	}
+	return nil
+}
@@ -35,6 +35,7 @@ type toolsHandler struct {
 // toolsHandler handles tool upload through HTTPS in the API server.
 type toolsUploadHandler struct {
 	toolsHandler
+	uploads *uploadLimiter
 }
 
 // toolsHandler handles tool download through HTTPS in the API server.
@@ -63,8 +64,8 @@ func (h *toolsDownloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *toolsUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := h.authenticate(r); err != nil {
-		h.authError(w, h)
+	if err := h.authorize(r, isUser); err != nil {
+		h.authError(w, h, err)
 		return
 	}
 
@@ -75,6 +76,11 @@ func (h *toolsUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "POST":
+		if !h.uploads.acquire() {
+			sendUploadsBusy(w, h)
+			return
+		}
+		defer h.uploads.release()
 		// Add tools to storage.
 		agentTools, err := h.processPost(r)
 		if err != nil {
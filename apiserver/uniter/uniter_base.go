@@ -728,7 +728,18 @@ func (u *uniterBaseAPI) Actions(args params.Entities) (params.ActionsQueryResult
 		}
 		results.Results[i].Action.Action = &params.Action{
 			Name:       action.Name(),
-			Parameters: action.Parameters(),
+			Parameters: action.EffectiveParameters(),
+			Timeout:    action.Timeout(),
+		}
+		if slot, ok := action.Slot(); ok {
+			results.Results[i].Action.Action.Slot = &params.ActionSlot{
+				Name:          slot.Name,
+				MaxConcurrent: slot.MaxConcurrent,
+			}
+		}
+		results.Results[i].Action.Attempt = action.Attempt()
+		if notBefore := action.NotBefore(); !notBefore.IsZero() {
+			results.Results[i].Action.NotBefore = &notBefore
 		}
 	}
 
@@ -784,10 +795,18 @@ func paramsActionExecutionResultsToStateActionResults(arg params.ActionExecution
 	default:
 		return state.ActionResults{}, errors.Errorf("unrecognized action status '%s'", arg.Status)
 	}
+	var usage *state.ActionUsage
+	if arg.Usage != nil {
+		usage = &state.ActionUsage{
+			CPUTime:    arg.Usage.CPUTime,
+			PeakMemory: arg.Usage.PeakMemory,
+		}
+	}
 	return state.ActionResults{
 		Status:  status,
 		Results: arg.Results,
 		Message: arg.Message,
+		Usage:   usage,
 	}, nil
 }
 
@@ -1321,6 +1340,17 @@ func (u *uniterBaseAPI) watchOneUnitActions(tag names.UnitTag) (params.StringsWa
 	return nothing, watcher.EnsureErr(watch)
 }
 
+func (u *uniterBaseAPI) watchOneActionSlot(action *state.Action) (string, error) {
+	watch, err := action.WatchSlot()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := <-watch.Changes(); ok {
+		return u.resources.Register(watch), nil
+	}
+	return "", watcher.EnsureErr(watch)
+}
+
 func (u *uniterBaseAPI) watchOneUnitAddresses(tag names.UnitTag) (string, error) {
 	unit, err := u.getUnit(tag)
 	if err != nil {
@@ -1199,6 +1199,76 @@ func (s *uniterBaseSuite) testActionsPermissionDenied(c *gc.C, facade actions) {
 	c.Assert(actions.Results[0].Error, jc.Satisfies, params.IsCodeUnauthorized)
 }
 
+type beginActions interface {
+	BeginActions(args params.Entities) (params.ErrorResults, error)
+}
+
+func (s *uniterBaseSuite) testBeginActions(c *gc.C, facade beginActions) {
+	good, err := s.wordpressUnit.AddAction("fakeaction", nil)
+	c.Assert(err, gc.IsNil)
+	bad, err := s.mysqlUnit.AddAction("fakeaction", nil)
+	c.Assert(err, gc.IsNil)
+
+	args := params.Entities{Entities: []params.Entity{
+		{Tag: good.Tag().String()},
+		{Tag: bad.Tag().String()},
+		{Tag: "invalid"},
+	}}
+	res, err := facade.BeginActions(args)
+	c.Assert(err, gc.IsNil)
+	c.Assert(res.Results, gc.HasLen, 3)
+	c.Assert(res.Results[0].Error, gc.IsNil)
+	c.Assert(res.Results[1].Error, jc.Satisfies, params.IsCodeUnauthorized)
+	c.Assert(res.Results[2].Error, gc.ErrorMatches, `"invalid" is not a valid( action)? tag`)
+
+	action, err := s.State.ActionByTag(good.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(action.Started().IsZero(), jc.IsFalse)
+	action, err = s.State.ActionByTag(bad.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(action.Started().IsZero(), jc.IsTrue)
+}
+
+type watchActionSlots interface {
+	WatchActionSlots(args params.Entities) (params.NotifyWatchResults, error)
+}
+
+func (s *uniterBaseSuite) testWatchActionSlots(c *gc.C, facade watchActionSlots) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	good, err := s.wordpressUnit.AddActionWithOptions("fakeaction", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	noSlot, err := s.wordpressUnit.AddAction("fakeaction", nil)
+	c.Assert(err, gc.IsNil)
+	bad, err := s.mysqlUnit.AddActionWithOptions("fakeaction", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(s.resources.Count(), gc.Equals, 0)
+	args := params.Entities{Entities: []params.Entity{
+		{Tag: good.Tag().String()},
+		{Tag: noSlot.Tag().String()},
+		{Tag: bad.Tag().String()},
+	}}
+	result, err := facade.WatchActionSlots(args)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Results, gc.HasLen, 3)
+	c.Assert(result.Results[0], gc.DeepEquals, params.NotifyWatchResult{NotifyWatcherId: "1"})
+	c.Assert(result.Results[1].Error, gc.ErrorMatches, `action ".*" has no slot`)
+	c.Assert(result.Results[2].Error, jc.Satisfies, params.IsCodeUnauthorized)
+
+	// Verify the resource was registered and stop when done.
+	c.Assert(s.resources.Count(), gc.Equals, 1)
+	resource := s.resources.Get("1")
+	defer statetesting.AssertStop(c, resource)
+
+	// The initial event was consumed by the Watch call; taking the
+	// slot is reported.
+	wc := statetesting.NewNotifyWatcherC(c, s.State, resource.(state.NotifyWatcher))
+	wc.AssertNoChange()
+	_, err = bad.Begin()
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+}
+
 type finishActions interface {
 	FinishActions(args params.ActionExecutionResults) (params.ErrorResults, error)
 }
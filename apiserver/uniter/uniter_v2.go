@@ -0,0 +1,98 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// The uniter package implements the API interface used by the uniter
+// worker. This file contains the API facade version 2.
+package uniter
+
+import (
+	"github.com/juju/names"
+
+	"github.com/juju/juju/apiserver/common"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/state"
+)
+
+func init() {
+	common.RegisterStandardFacade("Uniter", 2, NewUniterAPIV2)
+}
+
+// UniterAPIV2 implements the API facade version 2, used by the uniter
+// worker. It adds BeginActions and WatchActionSlots to version 1.
+type UniterAPIV2 struct {
+	UniterAPIV1
+}
+
+// NewUniterAPIV2 creates a new instance of the Uniter API, version 2.
+func NewUniterAPIV2(st *state.State, resources *common.Resources, authorizer common.Authorizer) (*UniterAPIV2, error) {
+	apiV1, err := NewUniterAPIV1(st, resources, authorizer)
+	if err != nil {
+		return nil, err
+	}
+	return &UniterAPIV2{
+		UniterAPIV1: *apiV1,
+	}, nil
+}
+
+// BeginActions marks the Actions with the given tags as having started
+// running.
+func (u *UniterAPIV2) BeginActions(args params.Entities) (params.ErrorResults, error) {
+	nothing := params.ErrorResults{}
+
+	actionFn, err := u.authAndActionFromTagFn()
+	if err != nil {
+		return nothing, err
+	}
+
+	results := params.ErrorResults{Results: make([]params.ErrorResult, len(args.Entities))}
+
+	for i, arg := range args.Entities {
+		actionTag, err := names.ParseActionTag(arg.Tag)
+		if err != nil {
+			results.Results[i].Error = common.ServerError(err)
+			continue
+		}
+
+		action, err := actionFn(actionTag)
+		if err != nil {
+			results.Results[i].Error = common.ServerError(err)
+			continue
+		}
+
+		_, err = action.Begin()
+		if err != nil {
+			results.Results[i].Error = common.ServerError(err)
+			continue
+		}
+	}
+
+	return results, nil
+}
+
+// WatchActionSlots returns a NotifyWatcher for each given action that
+// notifies when the actions holding its slot change, so that a unit
+// waiting for the slot knows when to try again to begin the action.
+func (u *UniterAPIV2) WatchActionSlots(args params.Entities) (params.NotifyWatchResults, error) {
+	nothing := params.NotifyWatchResults{}
+
+	actionFn, err := u.authAndActionFromTagFn()
+	if err != nil {
+		return nothing, err
+	}
+	result := params.NotifyWatchResults{
+		Results: make([]params.NotifyWatchResult, len(args.Entities)),
+	}
+	for i, entity := range args.Entities {
+		actionTag, err := names.ParseActionTag(entity.Tag)
+		if err != nil {
+			result.Results[i].Error = common.ServerError(err)
+			continue
+		}
+		action, err := actionFn(actionTag)
+		if err == nil {
+			result.Results[i].NotifyWatcherId, err = u.watchOneActionSlot(action)
+		}
+		result.Results[i].Error = common.ServerError(err)
+	}
+	return result, nil
+}
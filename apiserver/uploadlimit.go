@@ -0,0 +1,72 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package apiserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// uploadRetryAfter is the number of seconds a client refused an upload
+// because too many are in progress is asked to wait before retrying.
+const uploadRetryAfter = 10
+
+// uploadLimiter limits the number of uploads handled at once. A nil
+// *uploadLimiter imposes no limit.
+type uploadLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newUploadLimiter returns an uploadLimiter that allows at most max
+// uploads at once, with those over the limit waiting up to wait for
+// another to finish. If max is not positive, it returns nil.
+func newUploadLimiter(max int, wait time.Duration) *uploadLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &uploadLimiter{
+		slots: make(chan struct{}, max),
+		wait:  wait,
+	}
+}
+
+// acquire reserves a place for an upload, reporting whether it did so
+// within the limiter's waiting time. If it returns true, release must
+// be called once the upload is done.
+func (l *uploadLimiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-time.After(l.wait):
+		return false
+	}
+}
+
+// release gives up a place reserved by acquire.
+func (l *uploadLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// sendUploadsBusy tells the client that too many uploads are in
+// progress, and when to try again.
+func sendUploadsBusy(w http.ResponseWriter, sender errorSender) {
+	w.Header().Set("Retry-After", strconv.Itoa(uploadRetryAfter))
+	sender.sendError(w, http.StatusServiceUnavailable, "too many concurrent uploads; try again later")
+}
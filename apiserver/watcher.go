@@ -29,6 +29,10 @@ func init() {
 		"RelationUnitsWatcher", 0, newRelationUnitsWatcher,
 		reflect.TypeOf((*srvRelationUnitsWatcher)(nil)),
 	)
+	common.RegisterFacade(
+		"ActionsWatcher", 0, newActionsWatcher,
+		reflect.TypeOf((*srvActionsWatcher)(nil)),
+	)
 }
 
 func newClientAllWatcher(st *state.State, resources *common.Resources, auth common.Authorizer, id string) (interface{}, error) {
@@ -202,3 +206,47 @@ func (w *srvRelationUnitsWatcher) Next() (params.RelationUnitsWatchResult, error
 func (w *srvRelationUnitsWatcher) Stop() error {
 	return w.resources.Stop(w.id)
 }
+
+// srvActionsWatcher defines the API wrapping a state.ActionsWatcher.
+// It notifies about the lifecycle of Actions across the environment.
+type srvActionsWatcher struct {
+	watcher   state.ActionsWatcher
+	id        string
+	resources *common.Resources
+}
+
+func newActionsWatcher(st *state.State, resources *common.Resources, auth common.Authorizer, id string) (interface{}, error) {
+	if !auth.AuthClient() {
+		return nil, common.ErrPerm
+	}
+	watcher, ok := resources.Get(id).(state.ActionsWatcher)
+	if !ok {
+		return nil, common.ErrUnknownWatcher
+	}
+	return &srvActionsWatcher{
+		watcher:   watcher,
+		id:        id,
+		resources: resources,
+	}, nil
+}
+
+// Next returns when the lifecycle of one or more Actions has changed
+// since the most recent call to Next or the Watch call that created
+// the srvActionsWatcher.
+func (w *srvActionsWatcher) Next() (params.ActionsWatchResult, error) {
+	if changes, ok := <-w.watcher.Changes(); ok {
+		return params.ActionsWatchResult{
+			Changes: changes,
+		}, nil
+	}
+	err := w.watcher.Err()
+	if err == nil {
+		err = common.ErrStoppedWatcher
+	}
+	return params.ActionsWatchResult{}, err
+}
+
+// Stop stops the watcher.
+func (w *srvActionsWatcher) Stop() error {
+	return w.resources.Stop(w.id)
+}
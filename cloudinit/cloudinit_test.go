@@ -102,6 +102,20 @@ var ctests = []struct {
 			cfg.SetLocale("en_us")
 		},
 	},
+	{
+		"Hostname",
+		"hostname: juju-0\n",
+		func(cfg *cloudinit.Config) {
+			cfg.SetHostname("juju-0")
+		},
+	},
+	{
+		"Hostname with FQDN",
+		"fqdn: juju-0.example.com\nhostname: juju-0\n",
+		func(cfg *cloudinit.Config) {
+			cfg.SetHostname("juju-0.example.com")
+		},
+	},
 	{
 		"DisableRoot",
 		"disable_root: false\n",
@@ -6,6 +6,7 @@ package cloudinit
 import (
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"github.com/juju/utils"
 
@@ -247,6 +248,28 @@ func (cfg *Config) SetLocale(locale string) {
 	cfg.set("locale", locale != "", locale)
 }
 
+// SetHostname sets the hostname that will be applied to the machine.
+// If hostname is fully qualified, the first label becomes the hostname
+// and the whole name is used as the fqdn.
+func (cfg *Config) SetHostname(hostname string) {
+	short := hostname
+	if i := strings.Index(hostname, "."); i >= 0 {
+		short = hostname[:i]
+		cfg.set("fqdn", true, hostname)
+	} else {
+		cfg.set("fqdn", false, nil)
+	}
+	cfg.set("hostname", short != "", short)
+}
+
+// Hostname returns the hostname and fqdn set by SetHostname.
+// The fqdn is empty if the hostname was not fully qualified.
+func (cfg *Config) Hostname() (hostname, fqdn string) {
+	hostname, _ = cfg.attrs["hostname"].(string)
+	fqdn, _ = cfg.attrs["fqdn"].(string)
+	return hostname, fqdn
+}
+
 // AddMount adds a mount point. The given
 // arguments will be used as a line in /etc/fstab.
 func (cfg *Config) AddMount(args ...string) {
@@ -399,9 +422,7 @@ func shquote(p string) string {
 // scripts_per_instance
 // scripts_per_once
 // scripts_user
-// set_hostname
 // set_passwords
 // ssh_import_id
 // timezone
 // update_etc_hosts
-// update_hostname
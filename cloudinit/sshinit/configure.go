@@ -8,6 +8,7 @@ import (
 	"io"
 	"strings"
 
+	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/utils"
 
@@ -31,8 +32,22 @@ type ConfigureParams struct {
 	// ProgressWriter is an io.Writer to which progress will be written,
 	// for realtime feedback.
 	ProgressWriter io.Writer
+
+	// TailLog, if not empty, is the path of a log file on the host
+	// whose lines are written to ProgressWriter as they are logged
+	// while the script runs. The file need not exist beforehand.
+	TailLog string
+
+	// Cancel, if not nil, is closed to abandon the configuration. The
+	// SSH session running the script is killed, and ErrConfigureCancelled
+	// returned.
+	Cancel <-chan struct{}
 }
 
+// ErrConfigureCancelled is returned by RunConfigureScript when the
+// configuration is abandoned through ConfigureParams.Cancel.
+var ErrConfigureCancelled = errors.New("configuration cancelled")
+
 // Configure connects to the specified host over SSH,
 // and executes a script that carries out cloud-config.
 func Configure(params ConfigureParams) error {
@@ -49,14 +64,50 @@ func Configure(params ConfigureParams) error {
 // to have been returned by ConfigureScript.
 func RunConfigureScript(script string, params ConfigureParams) error {
 	logger.Tracef("Running script on %s: %s", params.Host, script)
+	if params.TailLog != "" {
+		script = tailLogScript(params.TailLog) + script
+	}
 	client := params.Client
 	if client == nil {
 		client = ssh.DefaultClient
 	}
-	cmd := ssh.Command(params.Host, []string{"sudo", "/bin/bash"}, nil)
+	cmd := client.Command(params.Host, []string{"sudo", "/bin/bash"}, nil)
 	cmd.Stdin = strings.NewReader(script)
 	cmd.Stderr = params.ProgressWriter
-	return cmd.Run()
+	if params.Cancel == nil {
+		return cmd.Run()
+	}
+	select {
+	case <-params.Cancel:
+		return ErrConfigureCancelled
+	default:
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-params.Cancel:
+		if err := cmd.Kill(); err != nil {
+			logger.Warningf("cannot kill configuration of %s: %v", params.Host, err)
+		}
+		<-done
+		return ErrConfigureCancelled
+	}
+}
+
+// tailLogScript returns a script that, when prepended to another,
+// copies the lines logged to the file at path to stderr while the
+// other runs. tail waits for the file to appear if it does not yet
+// exist, and exits once the shell running the script has exited, so
+// that the SSH session is not held open.
+func tailLogScript(path string) string {
+	return fmt.Sprintf("tail --pid=$$ --follow=name --retry --lines=0 %s >&2 2>/dev/null &\n", utils.ShQuote(path))
 }
 
 // ConfigureScript generates the bash script that applies
@@ -76,6 +127,10 @@ func ConfigureScript(cloudcfg *cloudinit.Config) (string, error) {
 	// above comment by removing the need to generate a
 	// script "by hand".
 
+	// The hostname is set first, so that everything
+	// that follows sees the final name of the machine.
+	hostnamecmds := hostnameCommands(cloudcfg)
+
 	// Bootcmds must be run before anything else,
 	// as they may affect package installation.
 	bootcmds, err := cmdlist(cloudcfg.BootCmds())
@@ -107,6 +162,7 @@ func ConfigureScript(cloudcfg *cloudinit.Config) (string, error) {
 	if stderr != "" {
 		script = append(script, "(")
 	}
+	script = append(script, hostnamecmds...)
 	script = append(script, bootcmds...)
 	script = append(script, pkgcmds...)
 	script = append(script, runcmds...)
@@ -119,6 +175,27 @@ func ConfigureScript(cloudcfg *cloudinit.Config) (string, error) {
 	return strings.Join(script, "\n"), nil
 }
 
+// hostnameCommands returns a slice of commands that, when run,
+// will set the hostname specified in the cloud-config, if any.
+func hostnameCommands(cfg *cloudinit.Config) []string {
+	hostname, fqdn := cfg.Hostname()
+	if hostname == "" {
+		return nil
+	}
+	cmds := []string{
+		cloudinit.LogProgressCmd("Setting hostname to %s", hostname),
+		"hostname " + utils.ShQuote(hostname),
+		fmt.Sprintf("printf '%%s\\n' %s > /etc/hostname", utils.ShQuote(hostname)),
+	}
+	if fqdn != "" {
+		cmds = append(cmds, fmt.Sprintf(
+			"printf '127.0.1.1 %%s %%s\\n' %s %s >> /etc/hosts",
+			utils.ShQuote(fqdn), utils.ShQuote(hostname),
+		))
+	}
+	return cmds
+}
+
 // The options specified are to prevent any kind of prompting.
 //  * --assume-yes answers yes to any yes/no question in apt-get;
 //  * the --force-confold option is passed to dpkg, and tells dpkg
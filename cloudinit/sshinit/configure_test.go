@@ -4,7 +4,14 @@
 package sshinit_test
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	gc "gopkg.in/check.v1"
 
@@ -18,6 +25,7 @@ import (
 	"github.com/juju/juju/environs/imagemetadata"
 	coretesting "github.com/juju/juju/testing"
 	"github.com/juju/juju/tools"
+	"github.com/juju/juju/utils/ssh"
 	"github.com/juju/juju/version"
 )
 
@@ -198,3 +206,83 @@ done`)
 	cfg.SetAptMirror("http://woat.com")
 	assertScriptMatches(c, cfg, aptMirrorRegexp, true)
 }
+
+func (s *configureSuite) TestHostname(c *gc.C) {
+	cfg := cloudinit.New()
+	cfg.AddBootCmd("echo boot")
+	hostnamePattern := "(.|\n)*hostname 'juju-0'(.|\n)*"
+	assertScriptMatches(c, cfg, hostnamePattern, false)
+
+	cfg.SetHostname("juju-0.example.com")
+	expectedCommands := regexp.QuoteMeta(`
+echo 'Setting hostname to juju-0' >&9
+hostname 'juju-0'
+printf '%s\n' 'juju-0' > /etc/hostname
+printf '127.0.1.1 %s %s\n' 'juju-0.example.com' 'juju-0' >> /etc/hosts
+echo boot
+`)
+	assertScriptMatches(c, cfg, "(.|\n)*"+expectedCommands+"(.|\n)*", true)
+}
+
+// runTailLogScript runs script locally after the script returned by
+// TailLogScript for the given log, and returns what was written to
+// stderr.
+func runTailLogScript(c *gc.C, logPath, script string) string {
+	var stderr bytes.Buffer
+	cmd := exec.Command("/bin/bash")
+	cmd.Stdin = strings.NewReader(sshinit.TailLogScript(logPath) + script)
+	cmd.Stderr = &stderr
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+	select {
+	case err := <-done:
+		c.Assert(err, gc.IsNil)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("script did not exit")
+	}
+	return stderr.String()
+}
+
+func (s *configureSuite) TestTailLogScript(c *gc.C) {
+	logPath := filepath.Join(c.MkDir(), "cloud-init-output.log")
+	output := runTailLogScript(c, logPath, fmt.Sprintf(`
+echo first > %[1]s
+echo second >> %[1]s
+`, logPath))
+	c.Assert(output, gc.Equals, "first\nsecond\n")
+}
+
+func (s *configureSuite) TestTailLogScriptNoLog(c *gc.C) {
+	logPath := filepath.Join(c.MkDir(), "cloud-init-output.log")
+	output := runTailLogScript(c, logPath, "true\n")
+	c.Assert(output, gc.Equals, "")
+}
+
+func (s *configureSuite) TestRunConfigureScriptCancelled(c *gc.C) {
+	// The fake ssh never finishes running the script.
+	testbin := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(testbin, "ssh"), []byte("#!/bin/sh\nexec sleep 60\n"), 0755)
+	c.Assert(err, gc.IsNil)
+	s.PatchEnvPathPrepend(testbin)
+	client, err := ssh.NewOpenSSHClient()
+	c.Assert(err, gc.IsNil)
+
+	cancel := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- sshinit.RunConfigureScript("true", sshinit.ConfigureParams{
+			Host:   "ubuntu@localhost",
+			Client: client,
+			Cancel: cancel,
+		})
+	}()
+	close(cancel)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, sshinit.ErrConfigureCancelled)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("configuration not cancelled")
+	}
+}
@@ -4,3 +4,5 @@
 package sshinit
 
 const Aptget = aptget
+
+var TailLogScript = tailLogScript
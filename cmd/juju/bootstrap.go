@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/juju/cmd"
@@ -46,6 +47,55 @@ following settings in your environments.yaml (all values represent number of sec
     # How often to refresh state server addresses from the API server.
     bootstrap-addresses-delay: 10 # default: 10 seconds
 
+On instances with several network interfaces, you can also control which
+address is used to connect to the state server while bootstrapping:
+
+    # How many distinct addresses must be reachable before one is chosen.
+    bootstrap-min-addresses: 2 # default: the first reachable address is used
+    # A network whose addresses are chosen in preference to any others.
+    # If bootstrap-min-addresses is not set, an address in this network is required.
+    bootstrap-preferred-cidr: 10.0.0.0/8 # default: none
+    # How many addresses are tried at once; the rest wait their turn.
+    bootstrap-ssh-concurrency: 4 # default: all addresses at once
+    # The user to log in to the bootstrap instance as, for images whose default
+    # user is not "ubuntu".
+    bootstrap-ssh-user: ec2-user # default: ubuntu
+
+Where the bootstrap instance cannot be reached directly, SSH connections to it
+may be made through a jump host:
+
+    bootstrap-ssh-jump-host: bastion.example.com:22 # default: none
+    bootstrap-ssh-jump-user: admin # default: as for ssh
+    bootstrap-ssh-jump-key: ~/.ssh/bastion # default: as for ssh
+
+Windows bootstrap instances are configured over WinRM (HTTPS, port 5986) rather
+than SSH, logging in with a password:
+
+    bootstrap-winrm-user: juju # default: Administrator
+    bootstrap-winrm-password: s3cret # default: none
+
+The password is used only during bootstrap and is not stored in the environment.
+The WinRM listener's certificate must be signed by a trusted authority unless
+its PEM-encoded certificate is passed to bootstrap as the instance's host key.
+
+To make sure the bootstrap instance runs a known version of cloud-init, set the
+expected major version; bootstrap fails if the instance differs:
+
+    bootstrap-cloudinit-version: "17" # default: any supported version
+
+Before configuring the bootstrap instance, bootstrap checks that it can reach the
+package mirror, failing early if it cannot. The URL checked may be changed, or the
+check skipped altogether:
+
+    bootstrap-mirror-check-url: http://mirror.internal/ubuntu/ # default: apt-mirror, or the Ubuntu archive
+    bootstrap-mirror-check: false # default: true
+
+Each milestone of the bootstrap (the instance starting, an address being found,
+SSH connecting, configuration finishing, or the bootstrap failing) may also be
+POSTed as JSON to a webhook, for example to update a CI dashboard, by passing
+its URL with --progress-webhook. A slow or failing webhook never holds up the
+bootstrap.
+
 Private clouds may need to specify their own custom image metadata, and possibly upload
 Juju tools to cloud storage if no outgoing Internet access is available. In this case,
 use the --metadata-source paramater to tell bootstrap a local directory from which to
@@ -68,6 +118,7 @@ type BootstrapCommand struct {
 	MetadataSource        string
 	Placement             string
 	KeepBrokenEnvironment bool
+	ProgressWebhook       string
 }
 
 func (c *BootstrapCommand) Info() *cmd.Info {
@@ -86,6 +137,7 @@ func (c *BootstrapCommand) SetFlags(f *gnuflag.FlagSet) {
 	f.StringVar(&c.MetadataSource, "metadata-source", "", "local path to use as tools and/or metadata source")
 	f.StringVar(&c.Placement, "to", "", "a placement directive indicating an instance to bootstrap")
 	f.BoolVar(&c.KeepBrokenEnvironment, "keep-broken", false, "do not destory the environment if bootstrap fails")
+	f.StringVar(&c.ProgressWebhook, "progress-webhook", "", "an HTTP(S) URL to POST bootstrap progress events to")
 }
 
 func (c *BootstrapCommand) Init(args []string) (err error) {
@@ -108,6 +160,12 @@ func (c *BootstrapCommand) Init(args []string) (err error) {
 			return fmt.Errorf("unsupported bootstrap placement directive %q", c.Placement)
 		}
 	}
+	if c.ProgressWebhook != "" {
+		u, err := url.Parse(c.ProgressWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid progress webhook URL %q", c.ProgressWebhook)
+		}
+	}
 	return cmd.CheckEmpty(args)
 }
 
@@ -236,7 +294,13 @@ func (c *BootstrapCommand) Run(ctx *cmd.Context) (resultErr error) {
 		c.UploadTools = true
 	}
 
-	err = bootstrapFuncs.Bootstrap(ctx, environ, bootstrap.BootstrapParams{
+	var bootstrapCtx environs.BootstrapContext = ctx
+	if c.ProgressWebhook != "" {
+		webhookCtx, stop := environs.WithProgressWebhook(ctx, c.ProgressWebhook)
+		defer stop()
+		bootstrapCtx = webhookCtx
+	}
+	err = bootstrapFuncs.Bootstrap(bootstrapCtx, environ, bootstrap.BootstrapParams{
 		Constraints: c.Constraints,
 		Placement:   c.Placement,
 		UploadTools: c.UploadTools,
@@ -234,6 +234,10 @@ var bootstrapTests = []bootstrapTest{{
 	info:       "keep broken",
 	args:       []string{"--keep-broken"},
 	keepBroken: true,
+}, {
+	info: "bad progress webhook",
+	args: []string{"--progress-webhook", "ftp://example.com/progress"},
+	err:  `invalid progress webhook URL "ftp://example.com/progress"`,
 }, {
 	info: "additional args",
 	args: []string{"anything", "else"},
@@ -488,6 +492,25 @@ func (s *BootstrapSuite) TestBootstrapCalledWithMetadataDir(c *gc.C) {
 	c.Assert(_bootstrap.args.MetadataDir, gc.Equals, sourceDir)
 }
 
+func (s *BootstrapSuite) TestBootstrapCalledWithProgressWebhook(c *gc.C) {
+	resetJujuHome(c, "devenv")
+	_bootstrap := &fakeBootstrapFuncs{}
+	s.PatchValue(&getBootstrapFuncs, func() BootstrapInterface {
+		return _bootstrap
+	})
+	s.PatchValue(&allInstances, func(environ environs.Environ) ([]instance.Instance, error) {
+		return []instance.Instance{&mockBootstrapInstance{}}, nil
+	})
+
+	_, err := coretesting.RunCommand(
+		c, envcmd.Wrap(&BootstrapCommand{}),
+		"--progress-webhook", "http://example.com/progress",
+	)
+	c.Assert(err, gc.IsNil)
+	_, ok := _bootstrap.ctx.(environs.ProgressBootstrapContext)
+	c.Assert(ok, jc.IsTrue)
+}
+
 func (s *BootstrapSuite) TestAutoSyncLocalSource(c *gc.C) {
 	sourceDir := createToolsSource(c, vAll)
 	s.PatchValue(&version.Current.Number, version.MustParse("1.2.0"))
@@ -702,6 +725,7 @@ func joinBinaryVersions(versions ...[]version.Binary) []version.Binary {
 // test scenarios. This could help improve some of the tests in this
 // file which execute large amounts of external functionality.
 type fakeBootstrapFuncs struct {
+	ctx  environs.BootstrapContext
 	args bootstrap.BootstrapParams
 }
 
@@ -710,6 +734,7 @@ func (fake *fakeBootstrapFuncs) EnsureNotBootstrapped(env environs.Environ) erro
 }
 
 func (fake *fakeBootstrapFuncs) Bootstrap(ctx environs.BootstrapContext, env environs.Environ, args bootstrap.BootstrapParams) error {
+	fake.ctx = ctx
 	fake.args = args
 	return nil
 }
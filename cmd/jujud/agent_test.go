@@ -178,6 +178,36 @@ func (s *apiOpenSuite) TestOpenAPIStateWaitsProvisionedGivesUp(c *gc.C) {
 	c.Assert(called, gc.Equals, checkProvisionedStrategy.Min+1)
 }
 
+type fakeMachineAPIOpenConfig struct {
+	fakeAPIOpenConfig
+}
+
+func (fakeMachineAPIOpenConfig) APIInfo() *api.Info {
+	return &api.Info{Tag: names.NewMachineTag("0")}
+}
+
+func (s *apiOpenSuite) TestOpenAPIStateDoesNotResumeSession(c *gc.C) {
+	// The agent's workers register all their watchers afresh on each
+	// connection, so the agent must not ask the server to keep or
+	// resume a session: the watchers of the old session would be
+	// left running alongside the new ones.
+	var infos []api.Info
+	s.PatchValue(&apiOpen, func(info *api.Info, opts api.DialOpts) (*api.State, error) {
+		infos = append(infos, *info)
+		if len(infos) == 1 {
+			return nil, &params.Error{Code: params.CodeUnauthorized}
+		}
+		return nil, fmt.Errorf("blah")
+	})
+	_, _, err := openAPIState(fakeMachineAPIOpenConfig{}, nil)
+	c.Assert(err, gc.ErrorMatches, "blah")
+	c.Assert(infos, gc.HasLen, 2)
+	for _, info := range infos {
+		c.Assert(info.Resumable, jc.IsFalse)
+		c.Assert(info.ResumeToken, gc.Equals, "")
+	}
+}
+
 type testPinger func() error
 
 func (f testPinger) Ping() error {
@@ -5,8 +5,10 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -27,6 +29,7 @@ import (
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/config"
+	envtools "github.com/juju/juju/environs/tools"
 	"github.com/juju/juju/instance"
 	"github.com/juju/juju/mongo"
 	"github.com/juju/juju/network"
@@ -312,7 +315,8 @@ func (c *BootstrapCommand) startMongo(addrs []network.Address, agentConfig agent
 }
 
 // populateTools stores uploaded tools in provider storage
-// and updates the tools metadata.
+// and updates the tools metadata. Prebaked tools, which have
+// no archive, are archived to be stored.
 func (c *BootstrapCommand) populateTools(st *state.State, env environs.Environ) error {
 	agentConfig := c.CurrentConfig()
 	dataDir := agentConfig.DataDir()
@@ -325,7 +329,18 @@ func (c *BootstrapCommand) populateTools(st *state.State, env environs.Environ)
 		agenttools.SharedToolsDir(dataDir, version.Current),
 		"tools.tar.gz",
 	))
-	if err != nil {
+	if os.IsNotExist(err) && tools.URL == "" {
+		// The tools were prebaked into the instance, so there is
+		// no archive; one is made from the unpacked tools, so that
+		// they can be given to other machines.
+		data, err = archivePrebakedTools(agenttools.SharedToolsDir(dataDir, version.Current))
+		if err != nil {
+			return errors.Annotatef(err, "cannot archive prebaked tools %v", tools.Version)
+		}
+		tools.Size = int64(len(data))
+		tools.SHA256 = fmt.Sprintf("%x", sha256.Sum256(data))
+		logger.Infof("archived prebaked tools %v", tools.Version)
+	} else if err != nil {
 		return err
 	}
 
@@ -363,6 +378,67 @@ func (c *BootstrapCommand) populateTools(st *state.State, env environs.Environ)
 	return nil
 }
 
+// archivePrebakedTools returns a tools archive holding the files that
+// prebaked tools are linked to from the given tools directory. The
+// links are followed, so that the archive holds the files themselves.
+func archivePrebakedTools(toolsDir string) ([]byte, error) {
+	entries, err := ioutil.ReadDir(toolsDir)
+	if err != nil {
+		return nil, err
+	}
+	archiveDir, err := ioutil.TempDir("", "juju-prebaked-tools")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(archiveDir)
+	foundJujud := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == "downloaded-tools.txt" {
+			// The tools' description is not part of the tools.
+			continue
+		}
+		if err := copyFollowingLinks(filepath.Join(archiveDir, name), filepath.Join(toolsDir, name)); err != nil {
+			return nil, err
+		}
+		foundJujud = foundJujud || name == "jujud"
+	}
+	if !foundJujud {
+		return nil, fmt.Errorf("no jujud found in %s", toolsDir)
+	}
+	var buf bytes.Buffer
+	if err := envtools.Archive(&buf, archiveDir); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyFollowingLinks copies the regular file at src, or to which src
+// links, to dst, keeping its permissions.
+func copyFollowingLinks(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // storeCustomImageMetadata reads the custom image metadata from disk,
 // and stores the files in environment storage with the same relative
 // paths.
@@ -4,6 +4,8 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -575,6 +577,73 @@ func (s *BootstrapSuite) TestUploadedToolsMetadata(c *gc.C) {
 	s.testToolsMetadata(c, true)
 }
 
+func (s *BootstrapSuite) writePrebakedTools(c *gc.C, files map[string]string) {
+	// The tools are linked into the tools directory from where they
+	// were baked into the image.
+	prebakedDir := c.MkDir()
+	toolsDir := filepath.FromSlash(agenttools.SharedToolsDir(s.dataDir, version.Current))
+	err := os.Remove(filepath.Join(toolsDir, "tools.tar.gz"))
+	c.Assert(err, gc.IsNil)
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(prebakedDir, name), []byte(content), 0755)
+		c.Assert(err, gc.IsNil)
+		err = os.Symlink(filepath.Join(prebakedDir, name), filepath.Join(toolsDir, name))
+		c.Assert(err, gc.IsNil)
+	}
+	s.writeDownloadedTools(c, &tools.Tools{Version: version.Current})
+}
+
+func (s *BootstrapSuite) TestPrebakedToolsStored(c *gc.C) {
+	s.writePrebakedTools(c, map[string]string{"jujud": "prebaked jujud"})
+	envtesting.RemoveFakeToolsMetadata(c, s.toolsStorage)
+
+	_, cmd, err := s.initBootstrapCommand(c, nil, "--env-config", s.b64yamlEnvcfg, "--instance-id", string(s.instanceId))
+	c.Assert(err, gc.IsNil)
+	err = cmd.Run(nil)
+	c.Assert(err, gc.IsNil)
+
+	// An archive of the prebaked tools is stored.
+	st, err := state.Open(&mongo.MongoInfo{
+		Info: mongo.Info{
+			Addrs:  []string{gitjujutesting.MgoServer.Addr()},
+			CACert: testing.CACert,
+		},
+		Password: testPasswordHash(),
+	}, mongo.DefaultDialOpts(), environs.NewStatePolicy())
+	c.Assert(err, gc.IsNil)
+	defer st.Close()
+	storage, err := st.ToolsStorage()
+	c.Assert(err, gc.IsNil)
+	defer storage.Close()
+	metadata, r, err := storage.Tools(version.Current)
+	c.Assert(err, gc.IsNil)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, gc.IsNil)
+	c.Assert(metadata.Size, gc.Equals, int64(len(data)))
+	c.Assert(metadata.SHA256, gc.Equals, fmt.Sprintf("%x", sha256.Sum256(data)))
+
+	extracted := c.MkDir()
+	err = agenttools.UnpackTools(extracted, &tools.Tools{
+		Version: version.Current,
+		Size:    metadata.Size,
+		SHA256:  metadata.SHA256,
+	}, bytes.NewReader(data))
+	c.Assert(err, gc.IsNil)
+	jujud, err := ioutil.ReadFile(filepath.Join(agenttools.SharedToolsDir(extracted, version.Current), "jujud"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(jujud), gc.Equals, "prebaked jujud")
+}
+
+func (s *BootstrapSuite) TestPrebakedToolsWithoutJujud(c *gc.C) {
+	s.writePrebakedTools(c, map[string]string{"juju-run": "not jujud"})
+
+	_, cmd, err := s.initBootstrapCommand(c, nil, "--env-config", s.b64yamlEnvcfg, "--instance-id", string(s.instanceId))
+	c.Assert(err, gc.IsNil)
+	err = cmd.Run(nil)
+	c.Assert(err, gc.ErrorMatches, "cannot archive prebaked tools .*: no jujud found in .*")
+}
+
 func (s *BootstrapSuite) testToolsMetadata(c *gc.C, exploded bool) {
 	envtesting.RemoveFakeToolsMetadata(c, s.toolsStorage)
 
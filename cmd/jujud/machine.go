@@ -628,11 +628,13 @@ func (a *MachineAgent) StateWorker() (worker.Worker, error) {
 					return nil, err
 				}
 				return apiserver.NewServer(st, listener, apiserver.ServerConfig{
-					Cert:      cert,
-					Key:       key,
-					DataDir:   dataDir,
-					LogDir:    logDir,
-					Validator: a.limitLoginsDuringUpgrade,
+					Cert:              cert,
+					Key:               key,
+					DataDir:           dataDir,
+					LogDir:            logDir,
+					Validator:         a.limitLoginsDuringUpgrade,
+					MaxAuthFailures:   apiserver.DefaultMaxAuthFailures,
+					AuthFailureWindow: apiserver.DefaultAuthFailureWindow,
 				})
 			})
 			a.startWorkerAfterUpgrade(singularRunner, "cleaner", func() (worker.Worker, error) {
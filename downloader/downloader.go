@@ -37,11 +37,17 @@ type Download struct {
 // os.TempDir(). If disableSSLHostnameVerification is true then a non-
 // validating http client will be used.
 func New(url, dir string, hostnameVerification utils.SSLHostnameVerification) *Download {
+	return NewWithHeader(url, nil, dir, hostnameVerification)
+}
+
+// NewWithHeader is like New, but sends the given HTTP header, such
+// as one holding credentials, with the download request.
+func NewWithHeader(url string, header http.Header, dir string, hostnameVerification utils.SSLHostnameVerification) *Download {
 	d := &Download{
 		done:                 make(chan Status),
 		hostnameVerification: hostnameVerification,
 	}
-	go d.run(url, dir)
+	go d.run(url, header, dir)
 	return d
 }
 
@@ -58,12 +64,12 @@ func (d *Download) Done() <-chan Status {
 	return d.done
 }
 
-func (d *Download) run(url, dir string) {
+func (d *Download) run(url string, header http.Header, dir string) {
 	defer d.tomb.Done()
 	// TODO(dimitern) 2013-10-03 bug #1234715
 	// Add a testing HTTPS storage to verify the
 	// disableSSLHostnameVerification behavior here.
-	file, err := download(url, dir, d.hostnameVerification)
+	file, err := download(url, header, dir, d.hostnameVerification)
 	if err != nil {
 		err = fmt.Errorf("cannot download %q: %v", url, err)
 	}
@@ -78,7 +84,7 @@ func (d *Download) run(url, dir string) {
 	}
 }
 
-func download(url, dir string, hostnameVerification utils.SSLHostnameVerification) (file *os.File, err error) {
+func download(url string, header http.Header, dir string, hostnameVerification utils.SSLHostnameVerification) (file *os.File, err error) {
 	if dir == "" {
 		dir = os.TempDir()
 	}
@@ -92,8 +98,15 @@ func download(url, dir string, hostnameVerification utils.SSLHostnameVerificatio
 		}
 	}()
 	// TODO(rog) make the download operation interruptible.
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
 	client := utils.GetHTTPClient(hostnameVerification)
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
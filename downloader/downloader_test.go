@@ -5,6 +5,7 @@ package downloader_test
 
 import (
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	stdtesting "testing"
@@ -80,6 +81,21 @@ func (s *suite) TestDownloadError(c *gc.C) {
 	c.Assert(status.Err, gc.ErrorMatches, `cannot download ".*": bad http response: 404 Not Found`)
 }
 
+func (s *suite) TestDownloadWithHeader(c *gc.C) {
+	gitjujutesting.Server.Response(200, nil, []byte("archive"))
+	header := http.Header{}
+	header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	d := downloader.NewWithHeader(s.URL("/archive.tgz"), header, c.MkDir(), utils.VerifySSLHostnames)
+	status := <-d.Done()
+	c.Assert(status.Err, gc.IsNil)
+	defer os.Remove(status.File.Name())
+	defer status.File.Close()
+	assertFileContents(c, status.File, "archive")
+
+	req := gitjujutesting.Server.WaitRequest()
+	c.Assert(req.Header.Get("Authorization"), gc.Equals, "Basic dXNlcjpwYXNz")
+}
+
 func (s *suite) TestStopDownload(c *gc.C) {
 	tmp := c.MkDir()
 	d := downloader.New(s.URL("/x.tgz"), tmp, utils.VerifySSLHostnames)
@@ -6,14 +6,23 @@ package bootstrap
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
+	"github.com/juju/names"
 	"github.com/juju/utils"
 
+	"github.com/juju/juju/api"
+	"github.com/juju/juju/apiserver/params"
+	coreCloudinit "github.com/juju/juju/cloudinit"
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/cloudinit"
 	"github.com/juju/juju/environs/imagemetadata"
 	"github.com/juju/juju/environs/simplestreams"
 	"github.com/juju/juju/environs/storage"
@@ -22,6 +31,7 @@ import (
 	"github.com/juju/juju/network"
 	coretools "github.com/juju/juju/tools"
 	"github.com/juju/juju/utils/ssh"
+	"github.com/juju/juju/utils/winrm"
 	"github.com/juju/juju/version"
 )
 
@@ -43,6 +53,10 @@ type BootstrapParams struct {
 	// directive used to choose the initial instance.
 	Placement string
 
+	// AvailabilityZone, if non-empty, names the availability zone in
+	// which to start the initial instance.
+	AvailabilityZone string
+
 	// UploadTools reports whether we should upload the local tools and
 	// override the environment's specified agent-version.
 	UploadTools bool
@@ -50,6 +64,114 @@ type BootstrapParams struct {
 	// MetadataDir is an optional path to a local directory containing
 	// tools and/or image metadata.
 	MetadataDir string
+
+	// Hostname, if non-empty, is the hostname to set on the bootstrap
+	// instance, optionally fully qualified. If empty, the hostname
+	// assigned by the provider is left untouched.
+	Hostname string
+
+	// EgressRules, if non-empty, restricts the outgoing traffic of
+	// the bootstrap instance to the destinations described by the
+	// rules. The restriction is applied before any packages are
+	// installed.
+	EgressRules []cloudinit.EgressRule
+
+	// HostEntries, if non-empty, holds static entries to add to the
+	// bootstrap instance's /etc/hosts before any packages are
+	// installed, for environments without reliable DNS.
+	HostEntries []cloudinit.HostEntry
+
+	// DiskLayouts, if non-empty, describes filesystems to create and
+	// mount on the bootstrap instance before anything is installed,
+	// such as a dedicated volume for the state server's database.
+	// The size of the root disk can be chosen with the root-disk
+	// constraint.
+	DiskLayouts []cloudinit.DiskLayout
+
+	// ExtraCloudConfig, if not nil, is called to add site-specific
+	// directives to the cloud-init configuration of the bootstrap
+	// instance, after Juju's own; see MachineConfig.ExtraCloudConfig.
+	ExtraCloudConfig func(*coreCloudinit.Config) error
+
+	// CloudInitOutputLog, if non-empty, is the absolute path on the
+	// bootstrap instance to which cloud-init output is logged,
+	// overriding the default location.
+	CloudInitOutputLog string
+
+	// DataDir, if non-empty, is the absolute path on the bootstrap
+	// instance of Juju's data directory, overriding the default for
+	// the instance's series. This allows images with a read-only root
+	// filesystem to keep Juju's data on a writable mount.
+	DataDir string
+
+	// InitialMachines, if non-empty, describes machines to add to the
+	// environment as soon as the bootstrap machine's API server is
+	// running, while the rest of its configuration completes, so that
+	// they are provisioned as soon as possible.
+	InitialMachines []MachineSpec
+
+	// HostKeys, if non-empty, maps addresses or the instance id of the
+	// bootstrap instance to the SSH host key, in authorized_keys
+	// format, that it is expected to present, or for a Windows
+	// instance to the PEM-encoded certificate of its WinRM listener.
+	// Bootstrap refuses to connect to an address at which any other
+	// key is presented. Where no key is known, an SSH host key is
+	// trusted on first use, while a WinRM certificate must be signed
+	// by a trusted authority.
+	HostKeys map[string]string
+
+	// Timeout, if non-zero, bounds the whole of bootstrap, from
+	// starting the bootstrap instance until it has been configured.
+	// If it passes first, bootstrap is aborted and the instance
+	// stopped.
+	Timeout time.Duration
+
+	// OSRefreshUpdate and OSUpgrade, if not nil, override the
+	// enable-os-refresh-update and enable-os-upgrade settings for the
+	// bootstrap instance only, so that an image known to be up to
+	// date need not be upgraded. The environment's settings still
+	// apply to every other machine.
+	OSRefreshUpdate *bool
+	OSUpgrade       *bool
+
+	// PrebakedToolsPath, if non-empty, is the absolute path of a
+	// directory on the bootstrap instance that already holds the
+	// unpacked agent tools for the client's version, such as one
+	// baked into the image used in an environment without access to
+	// a tools source. Tools are then neither looked up nor uploaded.
+	PrebakedToolsPath string
+}
+
+// MachineSpec describes a machine to add to the environment during
+// bootstrap.
+type MachineSpec struct {
+	// Series is the series of the machine. If empty, the
+	// environment's default series is used.
+	Series string
+
+	// Constraints are used to choose the machine's instance.
+	Constraints constraints.Value
+}
+
+// readOnlyDirs holds directories that are commonly mounted read-only,
+// and so are unlikely to be suitable locations for Juju's data
+// directory.
+var readOnlyDirs = []string{"/bin", "/boot", "/etc", "/lib", "/sbin", "/usr"}
+
+// validateDataDir returns an error if dataDir is not an absolute
+// path, and warns if it lies within a typically read-only directory.
+func validateDataDir(dataDir string) error {
+	if !path.IsAbs(dataDir) {
+		return errors.Errorf("data directory %q is not absolute", dataDir)
+	}
+	dataDir = path.Clean(dataDir)
+	for _, dir := range readOnlyDirs {
+		if dataDir == dir || strings.HasPrefix(dataDir, dir+"/") {
+			logger.Warningf("data directory %q is under %s, which is often read-only", dataDir, dir)
+			break
+		}
+	}
+	return nil
 }
 
 // Bootstrap bootstraps the given environment. The supplied constraints are
@@ -75,6 +197,43 @@ func Bootstrap(ctx environs.BootstrapContext, environ environs.Environ, args Boo
 	if _, hasCAKey := cfg.CAPrivateKey(); !hasCAKey {
 		return errors.Errorf("environment configuration has no ca-private-key")
 	}
+	if args.Hostname != "" && !cloudinit.IsValidHostname(args.Hostname) {
+		return errors.Errorf("invalid bootstrap hostname %q", args.Hostname)
+	}
+	for _, rule := range args.EgressRules {
+		if err := rule.Validate(); err != nil {
+			return errors.Annotate(err, "invalid bootstrap egress rules")
+		}
+	}
+	for _, entry := range args.HostEntries {
+		if err := entry.Validate(); err != nil {
+			return errors.Annotate(err, "invalid bootstrap host entries")
+		}
+	}
+	if err := cloudinit.ValidateDiskLayouts(args.DiskLayouts); err != nil {
+		return errors.Annotate(err, "invalid bootstrap disk layouts")
+	}
+	if args.CloudInitOutputLog != "" && !path.IsAbs(args.CloudInitOutputLog) {
+		return errors.Errorf("cloud-init output log path %q is not absolute", args.CloudInitOutputLog)
+	}
+	if args.DataDir != "" {
+		if err := validateDataDir(args.DataDir); err != nil {
+			return err
+		}
+	}
+	if args.PrebakedToolsPath != "" {
+		if err := cloudinit.ValidatePrebakedToolsPath(args.PrebakedToolsPath); err != nil {
+			return err
+		}
+	}
+	for host, key := range args.HostKeys {
+		if _, err := winrm.ParseCertificate(key); err == nil {
+			continue
+		}
+		if _, err := ssh.ParseAuthorisedKey(key); err != nil {
+			return errors.Annotatef(err, "invalid host key for %q", host)
+		}
+	}
 
 	// Set default tools metadata source, add image metadata source,
 	// then verify constraints. Providers may rely on image metadata
@@ -90,16 +249,27 @@ func Bootstrap(ctx environs.BootstrapContext, environ environs.Environ, args Boo
 	if err := validateConstraints(environ, args.Constraints); err != nil {
 		return err
 	}
+	for _, machine := range args.InitialMachines {
+		if err := validateConstraints(environ, machine.Constraints); err != nil {
+			return errors.Annotate(err, "invalid initial machine")
+		}
+	}
 
 	ctx.Infof("Bootstrapping environment %q", cfg.Name())
 	logger.Debugf("environment %q supports service/machine networks: %v", cfg.Name(), environ.SupportNetworks())
 	disableNetworkManagement, _ := cfg.DisableNetworkManagement()
 	logger.Debugf("network management by juju enabled: %v", disableNetworkManagement)
-	availableTools, err := findAvailableTools(environ, args.Constraints.Arch, args.UploadTools)
-	if errors.IsNotFound(err) {
-		return errors.New(noToolsMessage)
-	} else if err != nil {
-		return err
+	var availableTools coretools.List
+	var err error
+	if args.PrebakedToolsPath != "" {
+		availableTools = prebakedTools(args.Constraints.Arch)
+	} else {
+		availableTools, err = findAvailableTools(environ, args.Constraints.Arch, args.UploadTools)
+		if errors.IsNotFound(err) {
+			return errors.New(noToolsMessage)
+		} else if err != nil {
+			return err
+		}
 	}
 
 	// If we're uploading, we must override agent-version;
@@ -118,9 +288,13 @@ func Bootstrap(ctx environs.BootstrapContext, environ environs.Environ, args Boo
 
 	ctx.Infof("Starting new instance for initial state server")
 	arch, series, finalizer, err := environ.Bootstrap(ctx, environs.BootstrapParams{
-		Constraints:    args.Constraints,
-		Placement:      args.Placement,
-		AvailableTools: availableTools,
+		Constraints:      args.Constraints,
+		Placement:        args.Placement,
+		AvailabilityZone: args.AvailabilityZone,
+		AvailableTools:   availableTools,
+		OSRefreshUpdate:  args.OSRefreshUpdate,
+		OSUpgrade:        args.OSUpgrade,
+		Timeout:          args.Timeout,
 	})
 	if err != nil {
 		return err
@@ -137,7 +311,7 @@ func Bootstrap(ctx environs.BootstrapContext, environ environs.Environ, args Boo
 	if err != nil {
 		return err
 	}
-	if selectedTools.URL == "" {
+	if selectedTools.URL == "" && args.PrebakedToolsPath == "" {
 		if !args.UploadTools {
 			logger.Warningf("no prepackaged tools available")
 		}
@@ -159,14 +333,168 @@ func Bootstrap(ctx environs.BootstrapContext, environ environs.Environ, args Boo
 		return err
 	}
 	machineConfig.Tools = selectedTools
+	machineConfig.PrebakedToolsPath = args.PrebakedToolsPath
 	machineConfig.CustomImageMetadata = imageMetadata
+	machineConfig.Hostname = args.Hostname
+	machineConfig.EgressRules = args.EgressRules
+	machineConfig.HostEntries = args.HostEntries
+	machineConfig.DiskLayouts = args.DiskLayouts
+	machineConfig.ExtraCloudConfig = args.ExtraCloudConfig
+	machineConfig.BootstrapHostKeys = args.HostKeys
+	machineConfig.BootstrapSSHUser = cfg.BootstrapSSHUser()
+	if args.CloudInitOutputLog != "" {
+		machineConfig.CloudInitOutputLog = args.CloudInitOutputLog
+	}
+	if args.DataDir != "" {
+		machineConfig.DataDir = path.Clean(args.DataDir)
+	}
+	// The initial machines are added concurrently with finalizing the
+	// bootstrap machine, as soon as its API server is ready.
+	var initialMachines chan initialMachinesResult
+	stop := make(chan struct{})
+	if len(args.InitialMachines) > 0 {
+		initialMachines = make(chan initialMachinesResult, 1)
+		go func() {
+			ids, err := addInitialMachines(environ, args.InitialMachines, stop)
+			initialMachines <- initialMachinesResult{ids, err}
+		}()
+	}
 	if err := finalizer(ctx, machineConfig); err != nil {
+		close(stop)
+		if initialMachines != nil {
+			<-initialMachines
+		}
 		return err
 	}
+	if initialMachines != nil {
+		var result initialMachinesResult
+		select {
+		case result = <-initialMachines:
+		case <-time.After(initialMachinesTimeout):
+			close(stop)
+			result = <-initialMachines
+		}
+		if len(result.ids) > 0 {
+			ctx.Infof("Added initial machines: %s", strings.Join(result.ids, ", "))
+		}
+		if result.err != nil {
+			// The environment is up, so failing here would have it
+			// destroyed for want of machines that may yet be added
+			// with add-machine.
+			msg := fmt.Sprintf("cannot add initial machines: %v", result.err)
+			logger.Warningf("%s", msg)
+			fmt.Fprintf(ctx.GetStderr(), "WARNING: %s\n", msg)
+		}
+	}
 	ctx.Infof("Bootstrap complete")
 	return nil
 }
 
+// initialMachinesTimeout is how long bootstrap waits, once the
+// bootstrap machine has been configured, for the initial machines to
+// be added.
+var initialMachinesTimeout = 10 * time.Minute
+
+// apiRetryDelay is how long to wait between attempts to connect to the
+// bootstrap machine's API server.
+var apiRetryDelay = 5 * time.Second
+
+type initialMachinesResult struct {
+	ids []string
+	err error
+}
+
+// machineAdder is the part of the API client used to add the initial
+// machines.
+type machineAdder interface {
+	AddMachines([]params.AddMachineParams) ([]params.AddMachinesResult, error)
+	Close() error
+}
+
+// openAPI is called to connect to the API server of the environment's
+// bootstrap machine, as the admin user.
+var openAPI = func(environ environs.Environ) (machineAdder, error) {
+	info, err := environs.APIInfo(environ)
+	if err != nil {
+		return nil, err
+	}
+	info.Tag = names.NewUserTag("admin")
+	info.Password = environ.Config().AdminSecret()
+	st, err := api.Open(info, api.DialOpts{
+		DialAddressInterval: 50 * time.Millisecond,
+		Timeout:             apiRetryDelay,
+		RetryDelay:          time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st.Client(), nil
+}
+
+// addInitialMachines adds the given machines to the environment,
+// returning the ids of those added. It waits until the bootstrap
+// machine's API server accepts connections, so that no machine is
+// added before the bootstrap machine can be reached by its agent; if
+// stop is closed first, it gives up. If any machine cannot be added,
+// the others are still added, and the error names those that failed.
+func addInitialMachines(environ environs.Environ, machines []MachineSpec, stop <-chan struct{}) ([]string, error) {
+	var client machineAdder
+	for {
+		var err error
+		if client, err = openAPI(environ); err == nil {
+			break
+		}
+		logger.Debugf("API server not ready for initial machines: %v", err)
+		select {
+		case <-stop:
+			return nil, errors.Errorf("API server not ready: %v", err)
+		case <-time.After(apiRetryDelay):
+		}
+	}
+	defer client.Close()
+	machineParams := make([]params.AddMachineParams, len(machines))
+	for i, machine := range machines {
+		machineParams[i] = params.AddMachineParams{
+			Series:      machine.Series,
+			Constraints: machine.Constraints,
+			Jobs:        []params.MachineJob{params.JobHostUnits},
+		}
+	}
+	results, err := client.AddMachines(machineParams)
+	if err != nil {
+		return nil, err
+	}
+	var ids, failed []string
+	for i, result := range results {
+		if result.Error != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", describeMachine(i, machines[i]), result.Error))
+			continue
+		}
+		logger.Infof("added initial machine %s", result.Machine)
+		ids = append(ids, result.Machine)
+	}
+	if len(failed) > 0 {
+		return ids, errors.Errorf("%d of %d not added: %s", len(failed), len(machines), strings.Join(failed, "; "))
+	}
+	return ids, nil
+}
+
+// describeMachine returns a description of the ith of the initial
+// machines, which is given by machine.
+func describeMachine(i int, machine MachineSpec) string {
+	var attrs []string
+	if machine.Series != "" {
+		attrs = append(attrs, "series="+machine.Series)
+	}
+	if cons := machine.Constraints.String(); cons != "" {
+		attrs = append(attrs, cons)
+	}
+	if len(attrs) == 0 {
+		return fmt.Sprintf("machine #%d", i+1)
+	}
+	return fmt.Sprintf("machine #%d (%s)", i+1, strings.Join(attrs, " "))
+}
+
 // setBootstrapTools returns the newest tools from the given tools list,
 // and updates the agent-version configuration attribute.
 func setBootstrapTools(environ environs.Environ, possibleTools coretools.List) (*coretools.Tools, error) {
@@ -262,6 +590,11 @@ func setPrivateMetadataSources(env environs.Environ, metadataDir string) ([]*ima
 	return existingMetadata, nil
 }
 
+// validateConstraints checks the given constraints with env's
+// constraints validator before any instance is started, so that an
+// invalid value is reported clearly rather than by the cloud.
+// Attributes the provider does not support are only warned about,
+// as they are ignored when the instance is started.
 func validateConstraints(env environs.Environ, cons constraints.Value) error {
 	validator, err := env.ConstraintsValidator()
 	if err != nil {
@@ -269,7 +602,8 @@ func validateConstraints(env environs.Environ, cons constraints.Value) error {
 	}
 	unsupported, err := validator.Validate(cons)
 	if len(unsupported) > 0 {
-		logger.Warningf("unsupported constraints: %v", unsupported)
+		sort.Strings(unsupported)
+		logger.Warningf("unsupported constraints will be ignored: %s", strings.Join(unsupported, ", "))
 	}
 	return err
 }
@@ -6,10 +6,14 @@ package bootstrap_test
 import (
 	"fmt"
 	stdtesting "testing"
+	"time"
 
 	"github.com/juju/errors"
+	"github.com/juju/loggo"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
+	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/bootstrap"
@@ -25,6 +29,7 @@ import (
 	"github.com/juju/juju/provider/dummy"
 	coretesting "github.com/juju/juju/testing"
 	"github.com/juju/juju/tools"
+	sshtesting "github.com/juju/juju/utils/ssh/testing"
 	"github.com/juju/juju/version"
 )
 
@@ -87,6 +92,40 @@ func (s *bootstrapSuite) TestBootstrapNeedsSettings(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 }
 
+func (s *bootstrapSuite) TestBootstrapValidatesConstraints(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	env.validator = constraints.NewValidator()
+	env.validator.RegisterUnsupported([]string{constraints.CpuPower, constraints.Tags})
+	env.validator.RegisterVocabulary(constraints.Arch, []string{"amd64"})
+
+	// An invalid value is an error, and no instance is started.
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{
+		Constraints: constraints.MustParse("arch=ppc64el"),
+	})
+	c.Assert(err, gc.ErrorMatches, `invalid constraint value: arch=ppc64el\nvalid values are: \[amd64\]`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+
+	// Unsupported attributes are only warned about.
+	var tw loggo.TestWriter
+	c.Assert(loggo.RegisterWriter("bootstrap-tests", &tw, loggo.DEBUG), gc.IsNil)
+	defer loggo.RemoveWriter("bootstrap-tests")
+	cons := constraints.MustParse("tags=foo cpu-power=100 mem=4G")
+	err = bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{
+		Constraints: cons,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.bootstrapCount, gc.Equals, 1)
+	c.Assert(env.args.Constraints, gc.DeepEquals, cons)
+	var warned bool
+	for _, entry := range tw.Log() {
+		if entry.Level == loggo.WARNING && entry.Message == "unsupported constraints will be ignored: cpu-power, tags" {
+			warned = true
+		}
+	}
+	c.Assert(warned, jc.IsTrue)
+}
+
 func (s *bootstrapSuite) TestBootstrapEmptyConstraints(c *gc.C) {
 	env := newEnviron("foo", useDefaultKeys, nil)
 	s.setDummyStorage(c, env)
@@ -117,6 +156,333 @@ func (s *bootstrapSuite) TestBootstrapSpecifiedPlacement(c *gc.C) {
 	c.Assert(env.args.Placement, gc.DeepEquals, placement)
 }
 
+func (s *bootstrapSuite) TestBootstrapSpecifiedTimeout(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{Timeout: 20 * time.Minute})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.bootstrapCount, gc.Equals, 1)
+	c.Assert(env.args.Timeout, gc.Equals, 20*time.Minute)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedHostname(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{Hostname: "juju-0.example.com"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.Hostname, gc.Equals, "juju-0.example.com")
+}
+
+func (s *bootstrapSuite) TestBootstrapInvalidHostname(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{Hostname: "juju_0"})
+	c.Assert(err, gc.ErrorMatches, `invalid bootstrap hostname "juju_0"`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedEgressRules(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	rules := []cloudinit.EgressRule{{Protocol: "tcp", CIDR: "10.0.0.0/8", Port: 80}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{EgressRules: rules})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.EgressRules, gc.DeepEquals, rules)
+}
+
+func (s *bootstrapSuite) TestBootstrapInvalidEgressRules(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	rules := []cloudinit.EgressRule{{Protocol: "tcp", CIDR: "10.0.0.0", Port: 80}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{EgressRules: rules})
+	c.Assert(err, gc.ErrorMatches, `invalid bootstrap egress rules: egress rule "tcp:10.0.0.0:80" destination not valid`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedHostEntries(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	entries := []cloudinit.HostEntry{{Address: "10.0.0.5", Hostnames: []string{"archive.internal"}}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{HostEntries: entries})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.HostEntries, gc.DeepEquals, entries)
+}
+
+func (s *bootstrapSuite) TestBootstrapInvalidHostEntries(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	entries := []cloudinit.HostEntry{{Address: "archive.internal", Hostnames: []string{"archive"}}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{HostEntries: entries})
+	c.Assert(err, gc.ErrorMatches, `invalid bootstrap host entries: host entry "archive.internal archive" address not valid`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedDiskLayouts(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	layouts := []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/var/lib/juju/db"}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{DiskLayouts: layouts})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.DiskLayouts, gc.DeepEquals, layouts)
+}
+
+func (s *bootstrapSuite) TestBootstrapInvalidDiskLayouts(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	layouts := []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/"}}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{DiskLayouts: layouts})
+	c.Assert(err, gc.ErrorMatches, `invalid bootstrap disk layouts: disk layout mount point "/" for device "/dev/xvdb" not valid`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedCloudInitOutputLog(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	logPath := "/mnt/logs/cloud-init-output.log"
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{CloudInitOutputLog: logPath})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.CloudInitOutputLog, gc.Equals, logPath)
+}
+
+func (s *bootstrapSuite) TestBootstrapRelativeCloudInitOutputLog(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{CloudInitOutputLog: "logs/output.log"})
+	c.Assert(err, gc.ErrorMatches, `cloud-init output log path "logs/output.log" is not absolute`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapSpecifiedDataDir(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{DataDir: "/mnt/juju/"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.DataDir, gc.Equals, "/mnt/juju")
+	c.Assert(c.GetTestLog(), gc.Not(jc.Contains), "often read-only")
+}
+
+func (s *bootstrapSuite) TestBootstrapReadOnlyDataDir(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{DataDir: "/usr/lib/juju"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.machineConfig.DataDir, gc.Equals, "/usr/lib/juju")
+	c.Assert(c.GetTestLog(), jc.Contains, `data directory "/usr/lib/juju" is under /usr, which is often read-only`)
+}
+
+func (s *bootstrapSuite) TestBootstrapRelativeDataDir(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{DataDir: "var/lib/juju"})
+	c.Assert(err, gc.ErrorMatches, `data directory "var/lib/juju" is not absolute`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapPrebakedTools(c *gc.C) {
+	s.PatchValue(bootstrap.FindTools, func(environs.Environ, int, int, tools.Filter) (tools.List, error) {
+		c.Fatalf("tools looked up despite being prebaked")
+		return nil, nil
+	})
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{PrebakedToolsPath: "/opt/juju/tools"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.finalizerCount, gc.Equals, 1)
+	c.Assert(env.machineConfig.PrebakedToolsPath, gc.Equals, "/opt/juju/tools")
+	c.Assert(env.machineConfig.Tools.Version, gc.Equals, version.Current)
+	c.Assert(env.machineConfig.Tools.URL, gc.Equals, "")
+}
+
+func (s *bootstrapSuite) TestBootstrapRelativePrebakedTools(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{PrebakedToolsPath: "opt/juju/tools"})
+	c.Assert(err, gc.ErrorMatches, `prebaked tools path "opt/juju/tools" is not absolute`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+func (s *bootstrapSuite) TestBootstrapHostKeys(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	hostKeys := map[string]string{"10.0.0.1": sshtesting.ValidKeyOne.Key}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{HostKeys: hostKeys})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.machineConfig.BootstrapHostKeys, gc.DeepEquals, hostKeys)
+}
+
+func (s *bootstrapSuite) TestBootstrapHostCertificates(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	hostKeys := map[string]string{"i-bootstrap": coretesting.ServerCert}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{HostKeys: hostKeys})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.machineConfig.BootstrapHostKeys, gc.DeepEquals, hostKeys)
+}
+
+func (s *bootstrapSuite) TestBootstrapSSHUser(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, map[string]interface{}{
+		"bootstrap-ssh-user": "centos",
+	})
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.machineConfig.BootstrapSSHUser, gc.Equals, "centos")
+}
+
+func (s *bootstrapSuite) TestBootstrapInvalidHostKey(c *gc.C) {
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{
+		HostKeys: map[string]string{"i-bootstrap": "not a key"},
+	})
+	c.Assert(err, gc.ErrorMatches, `invalid host key for "i-bootstrap": .*`)
+	c.Assert(env.bootstrapCount, gc.Equals, 0)
+}
+
+// fakeMachineAdder records the machines it is asked to add, failing to
+// add those whose series is in fail.
+type fakeMachineAdder struct {
+	added  chan []params.AddMachineParams
+	fail   map[string]bool
+	closed bool
+}
+
+func (f *fakeMachineAdder) AddMachines(machineParams []params.AddMachineParams) ([]params.AddMachinesResult, error) {
+	results := make([]params.AddMachinesResult, len(machineParams))
+	for i, p := range machineParams {
+		if f.fail[p.Series] {
+			results[i].Error = &params.Error{Message: "no matching tools"}
+			continue
+		}
+		results[i].Machine = fmt.Sprint(i + 1)
+	}
+	f.added <- machineParams
+	return results, nil
+}
+
+func (f *fakeMachineAdder) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (s *bootstrapSuite) TestBootstrapInitialMachines(c *gc.C) {
+	s.PatchValue(bootstrap.APIRetryDelay, coretesting.ShortWait)
+	adder := &fakeMachineAdder{added: make(chan []params.AddMachineParams, 1)}
+	apiReady := make(chan struct{})
+	bootstrap.PatchOpenAPI(s, func(environs.Environ) (bootstrap.MachineAdder, error) {
+		select {
+		case <-apiReady:
+			return adder, nil
+		default:
+			return nil, fmt.Errorf("connection refused")
+		}
+	})
+
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	// The finalizer brings up the API server part-way through, and
+	// the machines must be added before it completes.
+	var added []params.AddMachineParams
+	env.finalize = func() error {
+		select {
+		case added = <-adder.added:
+			c.Fatalf("machines added before API server was ready")
+		case <-time.After(coretesting.ShortWait * 5):
+		}
+		close(apiReady)
+		select {
+		case added = <-adder.added:
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("machines not added while bootstrap machine configured")
+		}
+		return nil
+	}
+	cons := constraints.MustParse("mem=4G")
+	ctx := coretesting.Context(c)
+	err := bootstrap.Bootstrap(ctx, env, bootstrap.BootstrapParams{
+		InitialMachines: []bootstrap.MachineSpec{
+			{Series: "trusty", Constraints: cons},
+			{},
+		},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(added, gc.DeepEquals, []params.AddMachineParams{{
+		Series:      "trusty",
+		Constraints: cons,
+		Jobs:        []params.MachineJob{params.JobHostUnits},
+	}, {
+		Jobs: []params.MachineJob{params.JobHostUnits},
+	}})
+	c.Assert(adder.closed, jc.IsTrue)
+	c.Assert(coretesting.Stderr(ctx), jc.Contains, "Added initial machines: 1, 2\n")
+}
+
+func (s *bootstrapSuite) TestBootstrapInitialMachinesFail(c *gc.C) {
+	adder := &fakeMachineAdder{
+		added: make(chan []params.AddMachineParams, 1),
+		fail:  map[string]bool{"utopic": true},
+	}
+	bootstrap.PatchOpenAPI(s, func(environs.Environ) (bootstrap.MachineAdder, error) {
+		return adder, nil
+	})
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	ctx := coretesting.Context(c)
+	err := bootstrap.Bootstrap(ctx, env, bootstrap.BootstrapParams{
+		InitialMachines: []bootstrap.MachineSpec{
+			{Series: "trusty"},
+			{Series: "utopic", Constraints: constraints.MustParse("mem=4G")},
+		},
+	})
+	// The environment is up, so bootstrap succeeds, reporting the
+	// machines that could not be added.
+	c.Assert(err, gc.IsNil)
+	stderr := coretesting.Stderr(ctx)
+	c.Assert(stderr, jc.Contains, "Added initial machines: 1\n")
+	c.Assert(stderr, jc.Contains,
+		`WARNING: cannot add initial machines: 1 of 2 not added: machine #2 (series=utopic mem=4096M): no matching tools`+"\n")
+	c.Assert(stderr, jc.Contains, "Bootstrap complete\n")
+}
+
+func (s *bootstrapSuite) TestBootstrapInitialMachinesAPINeverReady(c *gc.C) {
+	s.PatchValue(bootstrap.APIRetryDelay, coretesting.ShortWait)
+	s.PatchValue(bootstrap.InitialMachinesTimeout, coretesting.ShortWait)
+	bootstrap.PatchOpenAPI(s, func(environs.Environ) (bootstrap.MachineAdder, error) {
+		return nil, fmt.Errorf("connection refused")
+	})
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	ctx := coretesting.Context(c)
+	err := bootstrap.Bootstrap(ctx, env, bootstrap.BootstrapParams{
+		InitialMachines: []bootstrap.MachineSpec{{}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(coretesting.Stderr(ctx), jc.Contains,
+		"WARNING: cannot add initial machines: API server not ready: connection refused\n")
+}
+
+func (s *bootstrapSuite) TestBootstrapInitialMachinesFinalizerFails(c *gc.C) {
+	s.PatchValue(bootstrap.APIRetryDelay, coretesting.ShortWait)
+	bootstrap.PatchOpenAPI(s, func(environs.Environ) (bootstrap.MachineAdder, error) {
+		return nil, fmt.Errorf("connection refused")
+	})
+	env := newEnviron("foo", useDefaultKeys, nil)
+	s.setDummyStorage(c, env)
+	env.finalize = func() error {
+		return fmt.Errorf("boom")
+	}
+	err := bootstrap.Bootstrap(coretesting.Context(c), env, bootstrap.BootstrapParams{
+		InitialMachines: []bootstrap.MachineSpec{{}},
+	})
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
 func (s *bootstrapSuite) TestBootstrapNoToolsNonReleaseStream(c *gc.C) {
 	s.PatchValue(&version.Current.Arch, "arm64")
 	s.PatchValue(&arch.HostArch, func() string {
@@ -280,6 +646,12 @@ type bootstrapEnviron struct {
 	cfg              *config.Config
 	environs.Environ // stub out all methods we don't care about.
 
+	// finalize, if non-nil, is called by the finalizer.
+	finalize func() error
+
+	// validator, if non-nil, is returned by ConstraintsValidator.
+	validator constraints.Validator
+
 	// The following fields are filled in when Bootstrap is called.
 	bootstrapCount              int
 	finalizerCount              int
@@ -323,6 +695,9 @@ func (e *bootstrapEnviron) Bootstrap(ctx environs.BootstrapContext, args environ
 	finalizer := func(_ environs.BootstrapContext, mcfg *cloudinit.MachineConfig) error {
 		e.finalizerCount++
 		e.machineConfig = mcfg
+		if e.finalize != nil {
+			return e.finalize()
+		}
 		return nil
 	}
 	return version.Current.Arch, version.Current.Series, finalizer, nil
@@ -351,5 +726,8 @@ func (e *bootstrapEnviron) SupportNetworks() bool {
 }
 
 func (e *bootstrapEnviron) ConstraintsValidator() (constraints.Validator, error) {
+	if e.validator != nil {
+		return e.validator, nil
+	}
 	return constraints.NewValidator(), nil
 }
@@ -3,10 +3,38 @@
 
 package bootstrap
 
+import (
+	"github.com/juju/juju/environs"
+)
+
 var (
-	ValidateUploadAllowed = validateUploadAllowed
-	SetBootstrapTools     = setBootstrapTools
-	FindTools             = &findTools
-	FindBootstrapTools    = findBootstrapTools
-	FindAvailableTools    = findAvailableTools
+	ValidateUploadAllowed  = validateUploadAllowed
+	SetBootstrapTools      = setBootstrapTools
+	FindTools              = &findTools
+	FindBootstrapTools     = findBootstrapTools
+	FindAvailableTools     = findAvailableTools
+	APIRetryDelay          = &apiRetryDelay
+	InitialMachinesTimeout = &initialMachinesTimeout
 )
+
+// MachineAdder is the part of the API client used to add the initial
+// machines during bootstrap.
+type MachineAdder interface {
+	machineAdder
+}
+
+type patcher interface {
+	PatchValue(dest, value interface{})
+}
+
+// PatchOpenAPI replaces the function used to connect to the bootstrap
+// machine's API server.
+func PatchOpenAPI(s patcher, f func(environs.Environ) (MachineAdder, error)) {
+	s.PatchValue(&openAPI, func(environ environs.Environ) (machineAdder, error) {
+		adder, err := f(environ)
+		if err != nil {
+			return nil, err
+		}
+		return adder, nil
+	})
+}
@@ -124,6 +124,29 @@ func locallyBuildableTools() (buildable coretools.List) {
 	return buildable
 }
 
+// prebakedTools returns a tools.List describing the tools for the
+// current version that may already be present on a bootstrap instance
+// of any Ubuntu series and, unless toolsArch is specified, any architecture. The
+// tools have no URL, since they need not be fetched.
+func prebakedTools(toolsArch *string) (prebaked coretools.List) {
+	arches := arch.AllSupportedArches
+	if toolsArch != nil {
+		arches = []string{*toolsArch}
+	}
+	for _, series := range version.SupportedSeries() {
+		if os, err := version.GetOSFromSeries(series); err != nil || os != version.Ubuntu {
+			continue
+		}
+		for _, a := range arches {
+			binary := version.Current
+			binary.Series = series
+			binary.Arch = a
+			prebaked = append(prebaked, &coretools.Tools{Version: binary})
+		}
+	}
+	return prebaked
+}
+
 // findBootstrapTools returns a tools.List containing only those tools with
 // which it would be reasonable to launch an environment's first machine,
 // given the supplied constraints. If a specific agent version is not requested,
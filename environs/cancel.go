@@ -0,0 +1,29 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+// WithCancel returns a CancelBootstrapContext that wraps ctx, whose
+// bootstrap is abandoned once cancel is closed.
+func WithCancel(ctx BootstrapContext, cancel <-chan struct{}) CancelBootstrapContext {
+	return &cancelContext{
+		BootstrapContext: ctx,
+		cancel:           cancel,
+	}
+}
+
+type cancelContext struct {
+	BootstrapContext
+	cancel <-chan struct{}
+}
+
+// Cancelled is part of the CancelBootstrapContext interface.
+func (ctx *cancelContext) Cancelled() <-chan struct{} {
+	return ctx.cancel
+}
+
+// WrappedBootstrapContext is part of the WrapperBootstrapContext
+// interface.
+func (ctx *cancelContext) WrappedBootstrapContext() BootstrapContext {
+	return ctx.BootstrapContext
+}
@@ -155,6 +155,7 @@ func FinishMachineConfig(mcfg *cloudinit.MachineConfig, cfg *config.Config) (err
 	); err != nil {
 		return err
 	}
+	mcfg.AptSecurityMirror = cfg.AptSecurityMirror()
 
 	// The following settings are only appropriate at bootstrap time. At the
 	// moment, the only state server is the bootstrap node, but this
@@ -190,6 +191,9 @@ func FinishMachineConfig(mcfg *cloudinit.MachineConfig, cfg *config.Config) (err
 		PrivateKey: string(key),
 	}
 	mcfg.StateServingInfo = &srvInfo
+	// The WinRM password is only needed by the client, so it is kept
+	// out of the configuration pushed to the bootstrap machine.
+	mcfg.BootstrapWinRMUser, mcfg.BootstrapWinRMPassword = cfg.BootstrapWinRMCredentials()
 	if mcfg.Config, err = BootstrapConfig(cfg); err != nil {
 		return err
 	}
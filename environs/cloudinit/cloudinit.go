@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"net"
 	"path"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/names"
@@ -77,6 +79,13 @@ type MachineConfig struct {
 	// Tools is juju tools to be used on the new machine.
 	Tools *coretools.Tools
 
+	// PrebakedToolsPath, if non-empty, is the absolute path of a
+	// directory on the new machine that already holds the unpacked
+	// agent tools described by Tools, such as one baked into its
+	// image. The tools are then not downloaded, and Tools.URL may be
+	// empty. It is only honoured when bootstrapping.
+	PrebakedToolsPath string
+
 	// DataDir holds the directory that juju state will be put in the new
 	// machine.
 	DataDir string
@@ -143,6 +152,10 @@ type MachineConfig struct {
 	// override the default APT sources.
 	AptMirror string
 
+	// AptSecurityMirror defines an APT mirror location which, if
+	// specified, replaces the Ubuntu security archive in the APT sources.
+	AptSecurityMirror string
+
 	// PreferIPv6 mirrors the value of prefer-ipv6 environment setting
 	// and when set IPv6 addresses for connecting to the API/state
 	// servers will be preferred over IPv4 ones.
@@ -164,6 +177,70 @@ type MachineConfig struct {
 	// machines. If enabled, the OS will perform any upgrades
 	// available as part of its provisioning.
 	EnableOSUpgrade bool
+
+	// Hostname, if non-empty, is the hostname to set on the machine,
+	// optionally fully qualified. If empty, the hostname assigned by
+	// the provider is left untouched.
+	Hostname string
+
+	// EgressRules, if non-empty, restricts the outgoing traffic of
+	// the machine to the destinations described by the rules. This
+	// is only honoured when provisioning a machine over SSH.
+	EgressRules []EgressRule
+
+	// HostEntries, if non-empty, holds static entries to add to the
+	// machine's /etc/hosts before any packages are installed. This
+	// is only honoured when provisioning a machine over SSH.
+	HostEntries []HostEntry
+
+	// DiskLayouts, if non-empty, describes filesystems to create and
+	// mount before anything is installed on the machine. This is only
+	// honoured when provisioning a machine over SSH.
+	DiskLayouts []DiskLayout
+
+	// ExtraCloudConfig, if not nil, is called with the cloud-init
+	// configuration once all of Juju's directives have been added to
+	// it, so that site-specific directives, such as extra packages,
+	// files or commands, may be added. Directives added augment
+	// Juju's rather than replacing them: boot commands run after
+	// Juju's boot commands, packages are installed along with Juju's,
+	// and run commands run after Juju's, once the machine agent has
+	// been started. This is only honoured when provisioning a machine
+	// over SSH.
+	ExtraCloudConfig func(*cloudinit.Config) error
+
+	// BootstrapHostKeys, if non-empty, maps addresses or the instance
+	// id of the bootstrap machine to the SSH host key, in
+	// authorized_keys format, that it is expected to present, or for
+	// a Windows machine to the PEM-encoded certificate of its WinRM
+	// listener. It is only honoured when bootstrapping.
+	BootstrapHostKeys map[string]string
+
+	// BootstrapSSHUser, if not empty, is the user to log in to the
+	// bootstrap machine as, in place of "ubuntu". It is only honoured
+	// when bootstrapping.
+	BootstrapSSHUser string
+
+	// BootstrapWinRMUser and BootstrapWinRMPassword are the
+	// credentials with which to log in to a Windows bootstrap machine
+	// over WinRM. They are taken from the environment configuration,
+	// which is stored without the password. They are only honoured
+	// when bootstrapping.
+	BootstrapWinRMUser     string
+	BootstrapWinRMPassword string
+
+	// BootstrapHostVerifyScript, if not nil, returns the script run on
+	// each address of the bootstrap machine to check that it is the
+	// machine described by the MachineConfig, in place of the nonce
+	// check suited to the machine's operating system. It is only
+	// honoured when bootstrapping.
+	BootstrapHostVerifyScript func(*MachineConfig) string
+
+	// CloudInitVersion, if not empty, is the version of cloud-init
+	// installed on the machine, as found when it was checked before
+	// being configured. The directives emitted when provisioning the
+	// machine over SSH are chosen according to its major version.
+	CloudInitVersion string
 }
 
 func base64yaml(m *config.Config) string {
@@ -226,6 +303,26 @@ func AddAptCommands(
 	}
 }
 
+// ubuntuSecurityArchive matches the Ubuntu security archive in
+// /etc/apt/sources.list, as a sed regular expression.
+const ubuntuSecurityArchive = `https\?://security\.ubuntu\.com/ubuntu/\?`
+
+// AddAptSecurityMirrorCommands adds boot commands to the given
+// cloudinit.Config that replace the Ubuntu security archive with the
+// given mirror, so that packages are never fetched from it. The boot
+// commands run before any packages are updated or installed. If the
+// mirror is empty, the config is left unchanged.
+func AddAptSecurityMirrorCommands(c *cloudinit.Config, mirror string) {
+	if mirror == "" {
+		return
+	}
+	c.AddBootCmd(cloudinit.LogProgressCmd("Changing apt security mirror to %s", mirror))
+	c.AddBootCmd(fmt.Sprintf(
+		"sed -i %s /etc/apt/sources.list",
+		shquote("s,"+ubuntuSecurityArchive+","+mirror+",g"),
+	))
+}
+
 func (cfg *MachineConfig) dataFile(name string) string {
 	return path.Join(cfg.DataDir, name)
 }
@@ -411,7 +508,14 @@ func verifyConfig(cfg *MachineConfig) (err error) {
 	if cfg.Tools == nil {
 		return fmt.Errorf("missing tools")
 	}
-	if cfg.Tools.URL == "" {
+	if cfg.PrebakedToolsPath != "" {
+		if !cfg.Bootstrap {
+			return fmt.Errorf("prebaked tools are only supported when bootstrapping")
+		}
+		if err := ValidatePrebakedToolsPath(cfg.PrebakedToolsPath); err != nil {
+			return err
+		}
+	} else if cfg.Tools.URL == "" {
 		return fmt.Errorf("missing tools URL")
 	}
 	if cfg.MongoInfo == nil {
@@ -477,5 +581,49 @@ func verifyConfig(cfg *MachineConfig) (err error) {
 	if cfg.MachineNonce == "" {
 		return fmt.Errorf("missing machine nonce")
 	}
+	if cfg.Hostname != "" && !IsValidHostname(cfg.Hostname) {
+		return fmt.Errorf("invalid hostname %q", cfg.Hostname)
+	}
+	for _, rule := range cfg.EgressRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, entry := range cfg.HostEntries {
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := ValidateDiskLayouts(cfg.DiskLayouts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidatePrebakedToolsPath returns an error if toolsPath cannot be
+// the path of a directory holding prebaked tools.
+func ValidatePrebakedToolsPath(toolsPath string) error {
+	if !path.IsAbs(toolsPath) {
+		return fmt.Errorf("prebaked tools path %q is not absolute", toolsPath)
+	}
+	if path.Clean(toolsPath) != toolsPath {
+		return fmt.Errorf("prebaked tools path %q is not clean", toolsPath)
+	}
 	return nil
 }
+
+var validHostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// IsValidHostname reports whether hostname is a legal, optionally
+// fully qualified, hostname as described in RFC 1123.
+func IsValidHostname(hostname string) bool {
+	if len(hostname) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !validHostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
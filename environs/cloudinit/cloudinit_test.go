@@ -634,6 +634,33 @@ func (*cloudinitSuite) TestCloudInitConfigureUsesGivenConfig(c *gc.C) {
 	c.Check(runCmd[0], gc.Equals, script)
 }
 
+func (*cloudinitSuite) TestCloudInitPrebakedTools(c *gc.C) {
+	cfg := minimalMachineConfig()
+	cfg.Config = minimalConfig(c)
+	cfg.Tools = &tools.Tools{Version: version.MustParseBinary("1.2.3-raring-amd64")}
+	cfg.PrebakedToolsPath = "/opt/juju/tools"
+	cloudcfg := coreCloudinit.New()
+	udata, err := cloudinit.NewUserdataConfig(&cfg, cloudcfg)
+	c.Assert(err, gc.IsNil)
+	err = udata.Configure()
+	c.Assert(err, gc.IsNil)
+
+	var scripts []string
+	for _, cmd := range cloudcfg.RunCmds() {
+		script, ok := cmd.(string)
+		c.Assert(ok, jc.IsTrue)
+		c.Check(script, gc.Not(jc.Contains), "tools.tar.gz")
+		c.Check(script, gc.Not(jc.Contains), "curl")
+		scripts = append(scripts, script)
+	}
+	assertScriptMatch(c, scripts, `bin='/var/lib/juju/tools/1\.2\.3-raring-amd64'
+mkdir -p \$bin
+\[ -x '/opt/juju/tools/jujud' \] \|\| \(echo "No prebaked tools found in "'/opt/juju/tools'; exit 1\)
+ln -sf '/opt/juju/tools'/\* \$bin/
+printf %s '{"version":"1\.2\.3-raring-amd64","url":"","size":0}' > \$bin/downloaded-tools\.txt
+`, false)
+}
+
 func getScripts(configKeyValue map[interface{}]interface{}) []string {
 	var scripts []string
 	if bootcmds, ok := configKeyValue["bootcmd"]; ok {
@@ -856,6 +883,16 @@ var verifyTests = []struct {
 	{"missing tools URL", func(cfg *cloudinit.MachineConfig) {
 		cfg.Tools = &tools.Tools{}
 	}},
+	{`prebaked tools path "opt/juju/tools" is not absolute`, func(cfg *cloudinit.MachineConfig) {
+		cfg.PrebakedToolsPath = "opt/juju/tools"
+	}},
+	{`prebaked tools path "/opt/juju/tools/" is not clean`, func(cfg *cloudinit.MachineConfig) {
+		cfg.PrebakedToolsPath = "/opt/juju/tools/"
+	}},
+	{"prebaked tools are only supported when bootstrapping", func(cfg *cloudinit.MachineConfig) {
+		cfg.Bootstrap = false
+		cfg.PrebakedToolsPath = "/opt/juju/tools"
+	}},
 	{"entity tag must match started machine", func(cfg *cloudinit.MachineConfig) {
 		cfg.Bootstrap = false
 		info := *cfg.MongoInfo
@@ -899,6 +936,21 @@ var verifyTests = []struct {
 	{"missing instance-id", func(cfg *cloudinit.MachineConfig) {
 		cfg.InstanceId = ""
 	}},
+	{`invalid hostname "-juju-0"`, func(cfg *cloudinit.MachineConfig) {
+		cfg.Hostname = "-juju-0"
+	}},
+	{`invalid hostname "juju_0.example.com"`, func(cfg *cloudinit.MachineConfig) {
+		cfg.Hostname = "juju_0.example.com"
+	}},
+	{`egress rule "icmp:10.0.0.0/8" protocol not valid`, func(cfg *cloudinit.MachineConfig) {
+		cfg.EgressRules = []cloudinit.EgressRule{{Protocol: "icmp", CIDR: "10.0.0.0/8"}}
+	}},
+	{`host entry "10.0.0.5" with no hostnames not valid`, func(cfg *cloudinit.MachineConfig) {
+		cfg.HostEntries = []cloudinit.HostEntry{{Address: "10.0.0.5"}}
+	}},
+	{`disk layout filesystem "vfat" for device "/dev/xvdb" not valid`, func(cfg *cloudinit.MachineConfig) {
+		cfg.DiskLayouts = []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "vfat", MountPoint: "/srv"}}
+	}},
 	{"state serving info unexpectedly present", func(cfg *cloudinit.MachineConfig) {
 		cfg.Bootstrap = false
 		apiInfo := *cfg.APIInfo
@@ -172,58 +172,29 @@ func (w *ubuntuConfigure) ConfigureJuju() error {
 		"mkdir -p $bin",
 	)
 
-	// Make a directory for the tools to live in, then fetch the
-	// tools and unarchive them into it.
-	if strings.HasPrefix(w.mcfg.Tools.URL, fileSchemePrefix) {
-		toolsData, err := ioutil.ReadFile(w.mcfg.Tools.URL[len(fileSchemePrefix):])
-		if err != nil {
-			return err
-		}
-		w.conf.AddBinaryFile(path.Join(w.mcfg.jujuTools(), "tools.tar.gz"), []byte(toolsData), 0644)
-	} else {
-		curlCommand := curlCommand
-		var urls []string
-		if w.mcfg.Bootstrap {
-			curlCommand += " --retry 10"
-			if w.mcfg.DisableSSLHostnameVerification {
-				curlCommand += " --insecure"
-			}
-			urls = append(urls, w.mcfg.Tools.URL)
-		} else {
-			for _, addr := range w.mcfg.apiHostAddrs() {
-				// TODO(axw) encode env UUID in URL when EnvironTag
-				// is guaranteed to be available in APIInfo.
-				url := fmt.Sprintf("https://%s/tools/%s", addr, w.mcfg.Tools.Version)
-				urls = append(urls, url)
-			}
-			// Our API server certificates are unusable by curl (invalid subject name),
-			// so we must disable certificate validation. It doesn't actually
-			// matter, because there is no sensitive information being transmitted
-			// and we verify the tools' hash after.
-			curlCommand += " --insecure"
-		}
-		curlCommand += " -o $bin/tools.tar.gz"
-		w.conf.AddRunCmd(cloudinit.LogProgressCmd("Fetching tools: %s <%s>", curlCommand, urls))
-		w.conf.AddRunCmd(toolsDownloadCommand(curlCommand, urls))
-	}
 	toolsJson, err := json.Marshal(w.mcfg.Tools)
 	if err != nil {
 		return err
 	}
-
-	w.conf.AddScripts(
-		fmt.Sprintf("sha256sum $bin/tools.tar.gz > $bin/juju%s.sha256", w.mcfg.Tools.Version),
-		fmt.Sprintf(`grep '%s' $bin/juju%s.sha256 || (echo "Tools checksum mismatch"; exit 1)`,
-			w.mcfg.Tools.SHA256, w.mcfg.Tools.Version),
-		fmt.Sprintf("tar zxf $bin/tools.tar.gz -C $bin"),
-		fmt.Sprintf("printf %%s %s > $bin/downloaded-tools.txt", shquote(string(toolsJson))),
-	)
-
-	// Don't remove tools tarball until after bootstrap agent
-	// runs, so it has a chance to add it to its catalogue.
-	defer w.conf.AddRunCmd(
-		fmt.Sprintf("rm $bin/tools.tar.gz && rm $bin/juju%s.sha256", w.mcfg.Tools.Version),
-	)
+	if w.mcfg.PrebakedToolsPath != "" {
+		// The tools are already on the machine: link them into
+		// the tools directory rather than fetching them.
+		w.conf.AddScripts(
+			fmt.Sprintf(`[ -x %s ] || (echo "No prebaked tools found in "%s; exit 1)`,
+				shquote(path.Join(w.mcfg.PrebakedToolsPath, "jujud")), shquote(w.mcfg.PrebakedToolsPath)),
+			fmt.Sprintf("ln -sf %s/* $bin/", shquote(w.mcfg.PrebakedToolsPath)),
+			fmt.Sprintf("printf %%s %s > $bin/downloaded-tools.txt", shquote(string(toolsJson))),
+		)
+	} else {
+		if err := w.fetchTools(string(toolsJson)); err != nil {
+			return err
+		}
+		// Don't remove tools tarball until after bootstrap agent
+		// runs, so it has a chance to add it to its catalogue.
+		defer w.conf.AddRunCmd(
+			fmt.Sprintf("rm $bin/tools.tar.gz && rm $bin/juju%s.sha256", w.mcfg.Tools.Version),
+		)
+	}
 
 	// We add the machine agent's configuration info
 	// before running bootstrap-state so that bootstrap-state
@@ -286,6 +257,52 @@ func (w *ubuntuConfigure) ConfigureJuju() error {
 	return w.addMachineAgentToBoot(machineTag.String())
 }
 
+// fetchTools adds commands to fetch the tools into the tools
+// directory, check them and unarchive them.
+func (w *ubuntuConfigure) fetchTools(toolsJson string) error {
+	if strings.HasPrefix(w.mcfg.Tools.URL, fileSchemePrefix) {
+		toolsData, err := ioutil.ReadFile(w.mcfg.Tools.URL[len(fileSchemePrefix):])
+		if err != nil {
+			return err
+		}
+		w.conf.AddBinaryFile(path.Join(w.mcfg.jujuTools(), "tools.tar.gz"), []byte(toolsData), 0644)
+	} else {
+		curlCommand := curlCommand
+		var urls []string
+		if w.mcfg.Bootstrap {
+			curlCommand += " --retry 10"
+			if w.mcfg.DisableSSLHostnameVerification {
+				curlCommand += " --insecure"
+			}
+			urls = append(urls, w.mcfg.Tools.URL)
+		} else {
+			for _, addr := range w.mcfg.apiHostAddrs() {
+				// TODO(axw) encode env UUID in URL when EnvironTag
+				// is guaranteed to be available in APIInfo.
+				url := fmt.Sprintf("https://%s/tools/%s", addr, w.mcfg.Tools.Version)
+				urls = append(urls, url)
+			}
+			// Our API server certificates are unusable by curl (invalid subject name),
+			// so we must disable certificate validation. It doesn't actually
+			// matter, because there is no sensitive information being transmitted
+			// and we verify the tools' hash after.
+			curlCommand += " --insecure"
+		}
+		curlCommand += " -o $bin/tools.tar.gz"
+		w.conf.AddRunCmd(cloudinit.LogProgressCmd("Fetching tools: %s <%s>", curlCommand, urls))
+		w.conf.AddRunCmd(toolsDownloadCommand(curlCommand, urls))
+	}
+
+	w.conf.AddScripts(
+		fmt.Sprintf("sha256sum $bin/tools.tar.gz > $bin/juju%s.sha256", w.mcfg.Tools.Version),
+		fmt.Sprintf(`grep '%s' $bin/juju%s.sha256 || (echo "Tools checksum mismatch"; exit 1)`,
+			w.mcfg.Tools.SHA256, w.mcfg.Tools.Version),
+		fmt.Sprintf("tar zxf $bin/tools.tar.gz -C $bin"),
+		fmt.Sprintf("printf %%s %s > $bin/downloaded-tools.txt", shquote(toolsJson)),
+	)
+	return nil
+}
+
 // toolsDownloadCommand takes a curl command minus the source URL,
 // and generates a command that will cycle through the URLs until
 // one succeeds.
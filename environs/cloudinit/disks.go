@@ -0,0 +1,133 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils"
+
+	"github.com/juju/juju/cloudinit"
+)
+
+// supportedFilesystems holds the filesystem types that a DiskLayout
+// may create.
+var supportedFilesystems = map[string]bool{
+	"ext3":  true,
+	"ext4":  true,
+	"xfs":   true,
+	"btrfs": true,
+}
+
+// DiskLayout describes a filesystem to be created on a block device
+// and mounted on a machine, equivalent to cloud-init's fs_setup and
+// mounts directives. It is typically used to place the state server's
+// database, which lives in the "db" directory of the data directory,
+// on a dedicated volume.
+type DiskLayout struct {
+	// Device is the path of the block device, such as /dev/xvdb.
+	// The whole device is used; it is not partitioned.
+	Device string
+
+	// Filesystem is the type of filesystem to create on the device,
+	// unless the device already holds one.
+	Filesystem string
+
+	// Label, if non-empty, is the label given to a created filesystem.
+	Label string
+
+	// MountPoint is the absolute path at which the filesystem is
+	// mounted. It must not be the root directory.
+	MountPoint string
+
+	// MinSizeMB, if non-zero, is the size in megabytes that the
+	// device must have, or configuration fails.
+	MinSizeMB uint64
+}
+
+// Validate returns an error if the layout is malformed.
+func (l DiskLayout) Validate() error {
+	if !path.IsAbs(l.Device) || !strings.HasPrefix(path.Clean(l.Device), "/dev/") {
+		return errors.NotValidf("disk layout device %q", l.Device)
+	}
+	if !supportedFilesystems[l.Filesystem] {
+		return errors.NotValidf("disk layout filesystem %q for device %q", l.Filesystem, l.Device)
+	}
+	if strings.ContainsAny(l.Device+l.MountPoint, " \t\n") {
+		return errors.NotValidf("disk layout for device %q containing whitespace", l.Device)
+	}
+	if !path.IsAbs(l.MountPoint) || path.Clean(l.MountPoint) == "/" {
+		return errors.NotValidf("disk layout mount point %q for device %q", l.MountPoint, l.Device)
+	}
+	return nil
+}
+
+// ValidateDiskLayouts returns an error if any of the given layouts is
+// malformed, or if two layouts use the same device or mount point.
+func ValidateDiskLayouts(layouts []DiskLayout) error {
+	devices := make(map[string]bool)
+	mountPoints := make(map[string]bool)
+	for _, layout := range layouts {
+		if err := layout.Validate(); err != nil {
+			return err
+		}
+		device, mountPoint := path.Clean(layout.Device), path.Clean(layout.MountPoint)
+		if devices[device] {
+			return errors.Errorf("device %q used by more than one disk layout", device)
+		}
+		if mountPoints[mountPoint] {
+			return errors.Errorf("mount point %q used by more than one disk layout", mountPoint)
+		}
+		devices[device] = true
+		mountPoints[mountPoint] = true
+	}
+	return nil
+}
+
+// AddDiskLayoutCommands adds commands to c that create and mount the
+// filesystems described by the given layouts. A device that already
+// holds a filesystem is not reformatted, and a filesystem that is
+// already mounted is left alone, so the commands may safely be run
+// more than once. Each filesystem is added to /etc/fstab so that it
+// is mounted again when the machine reboots.
+//
+// The commands are added as boot commands, so that the filesystems
+// are mounted before anything is installed onto them.
+func AddDiskLayoutCommands(c *cloudinit.Config, layouts []DiskLayout) error {
+	if len(layouts) == 0 {
+		return nil
+	}
+	if err := ValidateDiskLayouts(layouts); err != nil {
+		return err
+	}
+	for _, layout := range layouts {
+		device := utils.ShQuote(path.Clean(layout.Device))
+		mountPoint := path.Clean(layout.MountPoint)
+		c.AddBootCmd(cloudinit.LogProgressCmd(
+			"Mounting %s filesystem on %s at %s", layout.Filesystem, layout.Device, mountPoint,
+		))
+		if layout.MinSizeMB > 0 {
+			c.AddBootCmd(fmt.Sprintf(
+				"[ $(blockdev --getsize64 %s) -ge %d ] || (echo %s >&2; exit 1)",
+				device, layout.MinSizeMB*1024*1024,
+				utils.ShQuote(fmt.Sprintf("%s is smaller than %dMB", layout.Device, layout.MinSizeMB)),
+			))
+		}
+		mkfs := "mkfs -t " + layout.Filesystem
+		if layout.Label != "" {
+			mkfs += " -L " + utils.ShQuote(layout.Label)
+		}
+		c.AddBootCmd(fmt.Sprintf("blkid %s >/dev/null || %s %s", device, mkfs, device))
+		c.AddBootCmd("mkdir -p " + utils.ShQuote(mountPoint))
+		fstab := utils.ShQuote(fmt.Sprintf(
+			"%s %s %s defaults,nobootwait 0 2", path.Clean(layout.Device), mountPoint, layout.Filesystem,
+		))
+		c.AddBootCmd(fmt.Sprintf("grep -qxF %s /etc/fstab || printf '%%s\\n' %s >> /etc/fstab", fstab, fstab))
+		c.AddBootCmd(fmt.Sprintf("mountpoint -q %s || mount %s", utils.ShQuote(mountPoint), utils.ShQuote(mountPoint)))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit_test
+
+import (
+	gc "gopkg.in/check.v1"
+
+	coreCloudinit "github.com/juju/juju/cloudinit"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/testing"
+)
+
+type disksSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&disksSuite{})
+
+func (*disksSuite) TestAddDiskLayoutCommands(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddDiskLayoutCommands(cfg, []cloudinit.DiskLayout{{
+		Device:     "/dev/xvdb",
+		Filesystem: "xfs",
+		Label:      "juju-db",
+		MountPoint: "/var/lib/juju/db/",
+		MinSizeMB:  20480,
+	}, {
+		Device:     "/dev/xvdc",
+		Filesystem: "ext4",
+		MountPoint: "/var/log/juju",
+	}})
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.DeepEquals, []interface{}{
+		coreCloudinit.LogProgressCmd("Mounting xfs filesystem on /dev/xvdb at /var/lib/juju/db"),
+		`[ $(blockdev --getsize64 '/dev/xvdb') -ge 21474836480 ] || (echo '/dev/xvdb is smaller than 20480MB' >&2; exit 1)`,
+		`blkid '/dev/xvdb' >/dev/null || mkfs -t xfs -L 'juju-db' '/dev/xvdb'`,
+		`mkdir -p '/var/lib/juju/db'`,
+		`grep -qxF '/dev/xvdb /var/lib/juju/db xfs defaults,nobootwait 0 2' /etc/fstab || printf '%s\n' '/dev/xvdb /var/lib/juju/db xfs defaults,nobootwait 0 2' >> /etc/fstab`,
+		`mountpoint -q '/var/lib/juju/db' || mount '/var/lib/juju/db'`,
+		coreCloudinit.LogProgressCmd("Mounting ext4 filesystem on /dev/xvdc at /var/log/juju"),
+		`blkid '/dev/xvdc' >/dev/null || mkfs -t ext4 '/dev/xvdc'`,
+		`mkdir -p '/var/log/juju'`,
+		`grep -qxF '/dev/xvdc /var/log/juju ext4 defaults,nobootwait 0 2' /etc/fstab || printf '%s\n' '/dev/xvdc /var/log/juju ext4 defaults,nobootwait 0 2' >> /etc/fstab`,
+		`mountpoint -q '/var/log/juju' || mount '/var/log/juju'`,
+	})
+	c.Assert(cfg.RunCmds(), gc.HasLen, 0)
+}
+
+func (*disksSuite) TestAddDiskLayoutCommandsNoLayouts(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddDiskLayoutCommands(cfg, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.HasLen, 0)
+}
+
+var invalidDiskLayouts = []struct {
+	layouts []cloudinit.DiskLayout
+	err     string
+}{{
+	layouts: []cloudinit.DiskLayout{{Device: "xvdb", Filesystem: "ext4", MountPoint: "/srv"}},
+	err:     `disk layout device "xvdb" not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{{Device: "/tmp/disk", Filesystem: "ext4", MountPoint: "/srv"}},
+	err:     `disk layout device "/tmp/disk" not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "vfat", MountPoint: "/srv"}},
+	err:     `disk layout filesystem "vfat" for device "/dev/xvdb" not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/"}},
+	err:     `disk layout mount point "/" for device "/dev/xvdb" not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "srv"}},
+	err:     `disk layout mount point "srv" for device "/dev/xvdb" not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/srv/my data"}},
+	err:     `disk layout for device "/dev/xvdb" containing whitespace not valid`,
+}, {
+	layouts: []cloudinit.DiskLayout{
+		{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/srv"},
+		{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/data"},
+	},
+	err: `device "/dev/xvdb" used by more than one disk layout`,
+}, {
+	layouts: []cloudinit.DiskLayout{
+		{Device: "/dev/xvdb", Filesystem: "ext4", MountPoint: "/srv"},
+		{Device: "/dev/xvdc", Filesystem: "ext4", MountPoint: "/srv/"},
+	},
+	err: `mount point "/srv" used by more than one disk layout`,
+}}
+
+func (*disksSuite) TestAddDiskLayoutCommandsInvalidLayout(c *gc.C) {
+	for i, t := range invalidDiskLayouts {
+		c.Logf("test %d: %v", i, t.layouts)
+		cfg := coreCloudinit.New()
+		err := cloudinit.AddDiskLayoutCommands(cfg, t.layouts)
+		c.Check(err, gc.ErrorMatches, t.err)
+		c.Check(cfg.BootCmds(), gc.HasLen, 0)
+	}
+}
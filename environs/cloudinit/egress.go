@@ -0,0 +1,104 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/cloudinit"
+)
+
+// EgressRule describes outgoing traffic that is permitted from a
+// machine whose egress has been restricted.
+type EgressRule struct {
+	// Protocol is the transport protocol, either "tcp" or "udp".
+	Protocol string
+
+	// CIDR is the destination network, e.g. "10.0.0.0/8" or
+	// "2001:db8::/32". A single address may be given as a
+	// /32 (or /128) network.
+	CIDR string
+
+	// Port is the destination port. If zero, all ports
+	// of the destination network are permitted.
+	Port int
+}
+
+// String returns the rule in the form protocol:cidr[:port].
+func (r EgressRule) String() string {
+	if r.Port == 0 {
+		return fmt.Sprintf("%s:%s", r.Protocol, r.CIDR)
+	}
+	return fmt.Sprintf("%s:%s:%d", r.Protocol, r.CIDR, r.Port)
+}
+
+// Validate returns an error if the rule is malformed.
+func (r EgressRule) Validate() error {
+	switch r.Protocol {
+	case "tcp", "udp":
+	default:
+		return errors.NotValidf("egress rule %q protocol", r)
+	}
+	if _, _, err := net.ParseCIDR(r.CIDR); err != nil {
+		return errors.NotValidf("egress rule %q destination", r)
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return errors.NotValidf("egress rule %q port", r)
+	}
+	return nil
+}
+
+// iptables returns the name of the iptables command that
+// manages the address family of the rule's destination.
+func (r EgressRule) iptables() string {
+	ip, _, _ := net.ParseCIDR(r.CIDR)
+	if ip.To4() == nil {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// AddEgressFirewallCommands adds commands to c that restrict the
+// outgoing traffic of the machine to loopback, replies on existing
+// connections (e.g. the SSH session being used to provision the
+// machine), and the destinations allowed by rules. If rules is
+// empty, egress is left unrestricted.
+//
+// The commands are added as boot commands rather than run commands:
+// when the configuration is applied over SSH, as for the bootstrap
+// machine, boot commands are run before packages are updated and
+// installed, but run commands only afterwards, and the rules must be
+// in place before anything is downloaded. The commands are run once,
+// while the machine is configured; the rules are not saved, and do
+// not survive a reboot.
+func AddEgressFirewallCommands(c *cloudinit.Config, rules []EgressRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	c.AddBootCmd(cloudinit.LogProgressCmd("Restricting outgoing traffic"))
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		c.AddBootCmd(cmd + " -F OUTPUT")
+		c.AddBootCmd(cmd + " -A OUTPUT -o lo -j ACCEPT")
+		c.AddBootCmd(cmd + " -A OUTPUT -m state --state ESTABLISHED,RELATED -j ACCEPT")
+	}
+	for _, rule := range rules {
+		cmd := fmt.Sprintf("%s -A OUTPUT -p %s -d %s", rule.iptables(), rule.Protocol, rule.CIDR)
+		if rule.Port != 0 {
+			cmd += fmt.Sprintf(" --dport %d", rule.Port)
+		}
+		c.AddBootCmd(cmd + " -j ACCEPT")
+	}
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		c.AddBootCmd(cmd + " -P OUTPUT DROP")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit_test
+
+import (
+	gc "gopkg.in/check.v1"
+
+	coreCloudinit "github.com/juju/juju/cloudinit"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/testing"
+)
+
+type egressSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&egressSuite{})
+
+func (*egressSuite) TestAddEgressFirewallCommands(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddEgressFirewallCommands(cfg, []cloudinit.EgressRule{
+		{Protocol: "tcp", CIDR: "91.189.88.0/21", Port: 80},
+		{Protocol: "udp", CIDR: "10.0.0.2/32", Port: 53},
+		{Protocol: "tcp", CIDR: "2001:db8::/32"},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.DeepEquals, []interface{}{
+		coreCloudinit.LogProgressCmd("Restricting outgoing traffic"),
+		"iptables -F OUTPUT",
+		"iptables -A OUTPUT -o lo -j ACCEPT",
+		"iptables -A OUTPUT -m state --state ESTABLISHED,RELATED -j ACCEPT",
+		"ip6tables -F OUTPUT",
+		"ip6tables -A OUTPUT -o lo -j ACCEPT",
+		"ip6tables -A OUTPUT -m state --state ESTABLISHED,RELATED -j ACCEPT",
+		"iptables -A OUTPUT -p tcp -d 91.189.88.0/21 --dport 80 -j ACCEPT",
+		"iptables -A OUTPUT -p udp -d 10.0.0.2/32 --dport 53 -j ACCEPT",
+		"ip6tables -A OUTPUT -p tcp -d 2001:db8::/32 -j ACCEPT",
+		"iptables -P OUTPUT DROP",
+		"ip6tables -P OUTPUT DROP",
+	})
+	c.Assert(cfg.RunCmds(), gc.HasLen, 0)
+}
+
+func (*egressSuite) TestAddEgressFirewallCommandsNoRules(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddEgressFirewallCommands(cfg, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.HasLen, 0)
+}
+
+var invalidEgressRules = []struct {
+	rule cloudinit.EgressRule
+	err  string
+}{{
+	rule: cloudinit.EgressRule{Protocol: "icmp", CIDR: "10.0.0.0/8"},
+	err:  `egress rule "icmp:10.0.0.0/8" protocol not valid`,
+}, {
+	rule: cloudinit.EgressRule{Protocol: "tcp", CIDR: "10.0.0.1", Port: 22},
+	err:  `egress rule "tcp:10.0.0.1:22" destination not valid`,
+}, {
+	rule: cloudinit.EgressRule{Protocol: "udp", CIDR: "10.0.0.0/8", Port: 65536},
+	err:  `egress rule "udp:10.0.0.0/8:65536" port not valid`,
+}}
+
+func (*egressSuite) TestAddEgressFirewallCommandsInvalidRule(c *gc.C) {
+	for i, t := range invalidEgressRules {
+		c.Logf("test %d: %v", i, t.rule)
+		cfg := coreCloudinit.New()
+		err := cloudinit.AddEgressFirewallCommands(cfg, []cloudinit.EgressRule{t.rule})
+		c.Check(err, gc.ErrorMatches, t.err)
+		c.Check(cfg.BootCmds(), gc.HasLen, 0)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils"
+
+	"github.com/juju/juju/cloudinit"
+)
+
+// HostEntry describes a static entry to be added to a machine's
+// /etc/hosts, for use where DNS cannot be relied upon.
+type HostEntry struct {
+	// Address is the IPv4 or IPv6 address the hostnames resolve to.
+	Address string
+
+	// Hostnames holds the names that resolve to Address; the first
+	// is the canonical name, and any others are aliases.
+	Hostnames []string
+}
+
+// String returns the entry as a line of /etc/hosts.
+func (e HostEntry) String() string {
+	return strings.Join(append([]string{e.Address}, e.Hostnames...), " ")
+}
+
+// Validate returns an error if the entry is malformed.
+func (e HostEntry) Validate() error {
+	if net.ParseIP(e.Address) == nil {
+		return errors.NotValidf("host entry %q address", e)
+	}
+	if len(e.Hostnames) == 0 {
+		return errors.NotValidf("host entry %q with no hostnames", e)
+	}
+	for _, hostname := range e.Hostnames {
+		if !IsValidHostname(hostname) {
+			return errors.NotValidf("host entry %q hostname %q", e, hostname)
+		}
+	}
+	return nil
+}
+
+// AddHostEntriesCommands adds commands to c that add the given
+// entries to /etc/hosts, unless they are already present.
+//
+// The commands are added as boot commands, so that the entries are
+// available before any packages are downloaded. cloud-init is also
+// told not to manage /etc/hosts, as it would otherwise discard the
+// entries when regenerating the file on boot.
+func AddHostEntriesCommands(c *cloudinit.Config, entries []HostEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+	}
+	c.SetAttr("manage_etc_hosts", false)
+	c.AddBootCmd(cloudinit.LogProgressCmd("Adding static entries to /etc/hosts"))
+	for _, entry := range entries {
+		line := utils.ShQuote(entry.String())
+		c.AddBootCmd(fmt.Sprintf(
+			"grep -qxF %s /etc/hosts || printf '%%s\\n' %s >> /etc/hosts", line, line,
+		))
+	}
+	return nil
+}
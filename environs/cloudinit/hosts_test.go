@@ -0,0 +1,75 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package cloudinit_test
+
+import (
+	gc "gopkg.in/check.v1"
+
+	coreCloudinit "github.com/juju/juju/cloudinit"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/testing"
+)
+
+type hostsSuite struct {
+	testing.BaseSuite
+}
+
+var _ = gc.Suite(&hostsSuite{})
+
+func (*hostsSuite) TestAddHostEntriesCommands(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddHostEntriesCommands(cfg, []cloudinit.HostEntry{
+		{Address: "10.0.0.5", Hostnames: []string{"archive.internal", "archive"}},
+		{Address: "2001:db8::1", Hostnames: []string{"ca.internal"}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.DeepEquals, []interface{}{
+		coreCloudinit.LogProgressCmd("Adding static entries to /etc/hosts"),
+		`grep -qxF '10.0.0.5 archive.internal archive' /etc/hosts || printf '%s\n' '10.0.0.5 archive.internal archive' >> /etc/hosts`,
+		`grep -qxF '2001:db8::1 ca.internal' /etc/hosts || printf '%s\n' '2001:db8::1 ca.internal' >> /etc/hosts`,
+	})
+	c.Assert(cfg.RunCmds(), gc.HasLen, 0)
+
+	c.Assert(renderConfig(c, cfg), gc.Matches, `(?s).*\nmanage_etc_hosts: false\n.*`)
+}
+
+func (*hostsSuite) TestAddHostEntriesCommandsNoEntries(c *gc.C) {
+	cfg := coreCloudinit.New()
+	err := cloudinit.AddHostEntriesCommands(cfg, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(cfg.BootCmds(), gc.HasLen, 0)
+	c.Assert(renderConfig(c, cfg), gc.Not(gc.Matches), `(?s).*manage_etc_hosts.*`)
+}
+
+func renderConfig(c *gc.C, cfg *coreCloudinit.Config) string {
+	renderer, err := coreCloudinit.NewRenderer("quantal")
+	c.Assert(err, gc.IsNil)
+	data, err := renderer.Render(cfg)
+	c.Assert(err, gc.IsNil)
+	return string(data)
+}
+
+var invalidHostEntries = []struct {
+	entry cloudinit.HostEntry
+	err   string
+}{{
+	entry: cloudinit.HostEntry{Address: "10.0.0", Hostnames: []string{"archive"}},
+	err:   `host entry "10.0.0 archive" address not valid`,
+}, {
+	entry: cloudinit.HostEntry{Address: "10.0.0.5"},
+	err:   `host entry "10.0.0.5" with no hostnames not valid`,
+}, {
+	entry: cloudinit.HostEntry{Address: "10.0.0.5", Hostnames: []string{"archive", "bad_name"}},
+	err:   `host entry "10.0.0.5 archive bad_name" hostname "bad_name" not valid`,
+}}
+
+func (*hostsSuite) TestAddHostEntriesCommandsInvalidEntry(c *gc.C) {
+	for i, t := range invalidHostEntries {
+		c.Logf("test %d: %v", i, t.entry)
+		cfg := coreCloudinit.New()
+		err := cloudinit.AddHostEntriesCommands(cfg, []cloudinit.HostEntry{t.entry})
+		c.Check(err, gc.ErrorMatches, t.err)
+		c.Check(cfg.BootCmds(), gc.HasLen, 0)
+	}
+}
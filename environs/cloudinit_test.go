@@ -173,6 +173,27 @@ func (s *CloudInitSuite) TestFinishBootstrapConfig(c *gc.C) {
 	c.Assert(err, gc.NotNil)
 }
 
+func (s *CloudInitSuite) TestFinishBootstrapConfigWinRMCredentials(c *gc.C) {
+	attrs := dummySampleConfig().Merge(testing.Attrs{
+		"admin-secret":             "lisboan-pork",
+		"agent-version":            "1.2.3",
+		"bootstrap-winrm-user":     "juju",
+		"bootstrap-winrm-password": "s3cret",
+	})
+	cfg, err := config.New(config.NoDefaults, attrs)
+	c.Assert(err, gc.IsNil)
+	mcfg := &cloudinit.MachineConfig{
+		Bootstrap: true,
+	}
+	err = environs.FinishMachineConfig(mcfg, cfg)
+	c.Assert(err, gc.IsNil)
+	c.Check(mcfg.BootstrapWinRMUser, gc.Equals, "juju")
+	c.Check(mcfg.BootstrapWinRMPassword, gc.Equals, "s3cret")
+	// The password is not pushed to the bootstrap machine.
+	_, password := mcfg.Config.BootstrapWinRMCredentials()
+	c.Check(password, gc.Equals, "")
+}
+
 func (s *CloudInitSuite) TestUserData(c *gc.C) {
 	s.testUserData(c, false)
 }
@@ -262,14 +262,17 @@ func WriteEnvirons(path string, fileContents string) (string, error) {
 }
 
 // BootstrapConfig returns a copy of the supplied configuration with the
-// admin-secret and ca-private-key attributes removed. If the resulting
-// config is not suitable for bootstrapping an environment, an error is
-// returned.
+// admin-secret, ca-private-key and bootstrap-winrm-password attributes
+// removed. If the resulting config is not suitable for bootstrapping an
+// environment, an error is returned.
 func BootstrapConfig(cfg *config.Config) (*config.Config, error) {
 	m := cfg.AllAttrs()
 	// We never want to push admin-secret or the root CA private key to the cloud.
 	delete(m, "admin-secret")
 	delete(m, "ca-private-key")
+	// Nor the password with which the client logs in to a Windows
+	// bootstrap instance.
+	delete(m, "bootstrap-winrm-password")
 	cfg, err := config.New(config.NoDefaults, m)
 	if err != nil {
 		return nil, err
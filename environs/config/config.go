@@ -6,6 +6,8 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -65,6 +67,18 @@ const (
 	// refresh addresses from the provider each time.
 	DefaultBootstrapSSHAddressesDelay int = 10
 
+	// DefaultBootstrapMirrorCheckURL is the URL that the bootstrap
+	// instance must be able to reach, if no apt mirror is configured.
+	DefaultBootstrapMirrorCheckURL string = "http://archive.ubuntu.com/ubuntu/"
+
+	// DefaultBootstrapSSHUser is the user that bootstrap logs in to
+	// the bootstrap instance as.
+	DefaultBootstrapSSHUser string = "ubuntu"
+
+	// DefaultBootstrapWinRMUser is the user that bootstrap logs in to
+	// a Windows bootstrap instance as.
+	DefaultBootstrapWinRMUser string = "Administrator"
+
 	// fallbackLtsSeries is the latest LTS series we'll use, if we fail to
 	// obtain this information from the system.
 	fallbackLtsSeries string = "trusty"
@@ -460,6 +474,52 @@ func Validate(cfg, old *Config) error {
 		}
 	}
 
+	if v, ok := cfg.defined["bootstrap-min-addresses"].(int); ok && v < 0 {
+		return fmt.Errorf("bootstrap-min-addresses must not be negative, got %d", v)
+	}
+	if v, ok := cfg.defined["bootstrap-ssh-concurrency"].(int); ok && v < 0 {
+		return fmt.Errorf("bootstrap-ssh-concurrency must not be negative, got %d", v)
+	}
+	if v, ok := cfg.defined["default-action-timeout"].(int); ok && v < 0 {
+		return fmt.Errorf("default-action-timeout must not be negative, got %d", v)
+	}
+	if v, ok := cfg.defined["bootstrap-preferred-cidr"].(string); ok && v != "" {
+		if _, _, err := net.ParseCIDR(v); err != nil {
+			return fmt.Errorf("invalid bootstrap-preferred-cidr in environment configuration: %q", v)
+		}
+	}
+
+	if v, ok := cfg.defined["bootstrap-cloudinit-version"].(string); ok && v != "" {
+		if !validCloudInitVersion.MatchString(v) {
+			return fmt.Errorf("invalid bootstrap-cloudinit-version in environment configuration: %q", v)
+		}
+	}
+
+	if v, ok := cfg.defined["bootstrap-mirror-check-url"].(string); ok && v != "" {
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid bootstrap-mirror-check-url in environment configuration: %q", v)
+		}
+	}
+
+	if v, ok := cfg.defined["bootstrap-ssh-user"].(string); ok && v != "" {
+		if !validSSHUser.MatchString(v) {
+			return fmt.Errorf("invalid bootstrap-ssh-user in environment configuration: %q", v)
+		}
+	}
+
+	if v, ok := cfg.defined["bootstrap-ssh-jump-host"].(string); ok && v != "" {
+		if strings.ContainsAny(v, "@ \t") {
+			return fmt.Errorf("invalid bootstrap-ssh-jump-host in environment configuration: %q", v)
+		}
+	} else if cfg.asString("bootstrap-ssh-jump-user") != "" || cfg.asString("bootstrap-ssh-jump-key") != "" {
+		return fmt.Errorf("bootstrap-ssh-jump-user and bootstrap-ssh-jump-key require bootstrap-ssh-jump-host")
+	}
+	if v, ok := cfg.defined["bootstrap-ssh-jump-user"].(string); ok && v != "" {
+		if !validSSHUser.MatchString(v) {
+			return fmt.Errorf("invalid bootstrap-ssh-jump-user in environment configuration: %q", v)
+		}
+	}
+
 	// Check the immutable config values.  These can't change
 	if old != nil {
 		for _, attr := range immutableAttributes {
@@ -493,6 +553,12 @@ func Validate(cfg, old *Config) error {
 	return nil
 }
 
+// validCloudInitVersion matches a cloud-init major version.
+var validCloudInitVersion = regexp.MustCompile(`^[0-9]+$`)
+
+// validSSHUser matches a user name that may be given to ssh.
+var validSSHUser = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
 func isEmpty(val interface{}) bool {
 	switch val := val.(type) {
 	case nil:
@@ -723,6 +789,73 @@ func (c *Config) AptMirror() string {
 	return c.asString("apt-mirror")
 }
 
+// AptSecurityMirror returns the apt mirror to use in place of the
+// Ubuntu security archive when bootstrapping the environment.
+func (c *Config) AptSecurityMirror() string {
+	return c.asString("apt-security-mirror")
+}
+
+// BootstrapCloudInitVersion returns the major version of cloud-init,
+// such as "0" or "17", that the bootstrap instance's image is expected
+// to have, and whether it has been specified.
+func (c *Config) BootstrapCloudInitVersion() (string, bool) {
+	v, ok := c.defined["bootstrap-cloudinit-version"].(string)
+	return v, ok && v != ""
+}
+
+// BootstrapMirrorCheckURL returns the URL that the bootstrap instance
+// must be able to reach before it is configured, and whether that
+// check should be made. The URL defaults to the apt mirror, if one is
+// set, or the Ubuntu archive otherwise.
+func (c *Config) BootstrapMirrorCheckURL() (string, bool) {
+	if check, ok := c.defined["bootstrap-mirror-check"].(bool); ok && !check {
+		return "", false
+	}
+	if v := c.asString("bootstrap-mirror-check-url"); v != "" {
+		return v, true
+	}
+	if v := c.AptMirror(); v != "" {
+		return v, true
+	}
+	return DefaultBootstrapMirrorCheckURL, true
+}
+
+// BootstrapStreamLog reports whether the cloud-init output log of the
+// bootstrap instance should be shown as the instance is configured,
+// rather than only if configuration fails.
+func (c *Config) BootstrapStreamLog() bool {
+	v, _ := c.defined["bootstrap-stream-log"].(bool)
+	return v
+}
+
+// BootstrapSSHUser returns the user that bootstrap logs in to the
+// bootstrap instance as.
+func (c *Config) BootstrapSSHUser() string {
+	if v := c.asString("bootstrap-ssh-user"); v != "" {
+		return v
+	}
+	return DefaultBootstrapSSHUser
+}
+
+// BootstrapSSHJumpHost returns the jump host, in the form host[:port],
+// through which bootstrap connects to the bootstrap instance over SSH,
+// with the user to log in to it as and the private key file to log in
+// with. The host is empty if bootstrap connects directly; the user and
+// key file are empty if ssh's defaults are to be used.
+func (c *Config) BootstrapSSHJumpHost() (host, user, keyFile string) {
+	return c.asString("bootstrap-ssh-jump-host"), c.asString("bootstrap-ssh-jump-user"), c.asString("bootstrap-ssh-jump-key")
+}
+
+// BootstrapWinRMCredentials returns the user name and password with
+// which bootstrap logs in to a Windows bootstrap instance.
+func (c *Config) BootstrapWinRMCredentials() (user, password string) {
+	user = c.asString("bootstrap-winrm-user")
+	if user == "" {
+		user = DefaultBootstrapWinRMUser
+	}
+	return user, c.asString("bootstrap-winrm-password")
+}
+
 // BootstrapSSHOpts returns the SSH timeout and retry delays used
 // during bootstrap.
 func (c *Config) BootstrapSSHOpts() SSHTimeoutOpts {
@@ -730,6 +863,7 @@ func (c *Config) BootstrapSSHOpts() SSHTimeoutOpts {
 		Timeout:        time.Duration(DefaultBootstrapSSHTimeout) * time.Second,
 		RetryDelay:     time.Duration(DefaultBootstrapSSHRetryDelay) * time.Second,
 		AddressesDelay: time.Duration(DefaultBootstrapSSHAddressesDelay) * time.Second,
+		RetryJitter:    true,
 	}
 	if v, ok := c.defined["bootstrap-timeout"].(int); ok && v != 0 {
 		opts.Timeout = time.Duration(v) * time.Second
@@ -740,6 +874,15 @@ func (c *Config) BootstrapSSHOpts() SSHTimeoutOpts {
 	if v, ok := c.defined["bootstrap-addresses-delay"].(int); ok && v != 0 {
 		opts.AddressesDelay = time.Duration(v) * time.Second
 	}
+	if v, ok := c.defined["bootstrap-min-addresses"].(int); ok {
+		opts.MinAddresses = v
+	}
+	if v, ok := c.defined["bootstrap-preferred-cidr"].(string); ok {
+		opts.PreferredCIDR = v
+	}
+	if v, ok := c.defined["bootstrap-ssh-concurrency"].(int); ok {
+		opts.MaxConcurrentDials = v
+	}
 	return opts
 }
 
@@ -852,6 +995,14 @@ func (c *Config) LoggingConfig() string {
 	return c.asString("logging-config")
 }
 
+// DefaultActionTimeout returns how long an action may run before it is
+// stopped, if it was not enqueued with a timeout of its own. It is
+// zero if actions may run for as long as they need.
+func (c *Config) DefaultActionTimeout() time.Duration {
+	v, _ := c.defined["default-action-timeout"].(int)
+	return time.Duration(v) * time.Second
+}
+
 // Auth token sent to charm store
 func (c *Config) CharmStoreAuth() (string, bool) {
 	auth := c.asString("charm-store-auth")
@@ -964,51 +1115,66 @@ func (c *Config) Apply(attrs map[string]interface{}) (*Config, error) {
 }
 
 var fields = schema.Fields{
-	"type":                       schema.String(),
-	"name":                       schema.String(),
-	"uuid":                       schema.UUID(),
-	"default-series":             schema.String(),
-	"tools-metadata-url":         schema.String(),
-	"image-metadata-url":         schema.String(),
-	"image-stream":               schema.String(),
-	"tools-stream":               schema.String(),
-	"authorized-keys":            schema.String(),
-	"authorized-keys-path":       schema.String(),
-	"firewall-mode":              schema.String(),
-	"agent-version":              schema.String(),
-	"development":                schema.Bool(),
-	"admin-secret":               schema.String(),
-	"ca-cert":                    schema.String(),
-	"ca-cert-path":               schema.String(),
-	"ca-private-key":             schema.String(),
-	"ca-private-key-path":        schema.String(),
-	"ssl-hostname-verification":  schema.Bool(),
-	"state-port":                 schema.ForceInt(),
-	"api-port":                   schema.ForceInt(),
-	"syslog-port":                schema.ForceInt(),
-	"rsyslog-ca-cert":            schema.String(),
-	"logging-config":             schema.String(),
-	"charm-store-auth":           schema.String(),
-	ProvisionerHarvestModeKey:    schema.String(),
-	"http-proxy":                 schema.String(),
-	"https-proxy":                schema.String(),
-	"ftp-proxy":                  schema.String(),
-	"no-proxy":                   schema.String(),
-	"apt-http-proxy":             schema.String(),
-	"apt-https-proxy":            schema.String(),
-	"apt-ftp-proxy":              schema.String(),
-	"apt-mirror":                 schema.String(),
-	"bootstrap-timeout":          schema.ForceInt(),
-	"bootstrap-retry-delay":      schema.ForceInt(),
-	"bootstrap-addresses-delay":  schema.ForceInt(),
-	"test-mode":                  schema.Bool(),
-	"proxy-ssh":                  schema.Bool(),
-	"lxc-clone":                  schema.Bool(),
-	"lxc-clone-aufs":             schema.Bool(),
-	"prefer-ipv6":                schema.Bool(),
-	"enable-os-refresh-update":   schema.Bool(),
-	"enable-os-upgrade":          schema.Bool(),
-	"disable-network-management": schema.Bool(),
+	"type":                        schema.String(),
+	"name":                        schema.String(),
+	"uuid":                        schema.UUID(),
+	"default-series":              schema.String(),
+	"tools-metadata-url":          schema.String(),
+	"image-metadata-url":          schema.String(),
+	"image-stream":                schema.String(),
+	"tools-stream":                schema.String(),
+	"authorized-keys":             schema.String(),
+	"authorized-keys-path":        schema.String(),
+	"firewall-mode":               schema.String(),
+	"agent-version":               schema.String(),
+	"development":                 schema.Bool(),
+	"admin-secret":                schema.String(),
+	"ca-cert":                     schema.String(),
+	"ca-cert-path":                schema.String(),
+	"ca-private-key":              schema.String(),
+	"ca-private-key-path":         schema.String(),
+	"ssl-hostname-verification":   schema.Bool(),
+	"state-port":                  schema.ForceInt(),
+	"api-port":                    schema.ForceInt(),
+	"syslog-port":                 schema.ForceInt(),
+	"rsyslog-ca-cert":             schema.String(),
+	"logging-config":              schema.String(),
+	"charm-store-auth":            schema.String(),
+	ProvisionerHarvestModeKey:     schema.String(),
+	"http-proxy":                  schema.String(),
+	"https-proxy":                 schema.String(),
+	"ftp-proxy":                   schema.String(),
+	"no-proxy":                    schema.String(),
+	"apt-http-proxy":              schema.String(),
+	"apt-https-proxy":             schema.String(),
+	"apt-ftp-proxy":               schema.String(),
+	"apt-mirror":                  schema.String(),
+	"apt-security-mirror":         schema.String(),
+	"bootstrap-timeout":           schema.ForceInt(),
+	"bootstrap-retry-delay":       schema.ForceInt(),
+	"bootstrap-addresses-delay":   schema.ForceInt(),
+	"bootstrap-min-addresses":     schema.ForceInt(),
+	"bootstrap-preferred-cidr":    schema.String(),
+	"bootstrap-ssh-concurrency":   schema.ForceInt(),
+	"bootstrap-ssh-user":          schema.String(),
+	"bootstrap-ssh-jump-host":     schema.String(),
+	"bootstrap-ssh-jump-user":     schema.String(),
+	"bootstrap-ssh-jump-key":      schema.String(),
+	"bootstrap-winrm-user":        schema.String(),
+	"bootstrap-winrm-password":    schema.String(),
+	"bootstrap-cloudinit-version": schema.String(),
+	"bootstrap-mirror-check":      schema.Bool(),
+	"bootstrap-mirror-check-url":  schema.String(),
+	"bootstrap-stream-log":        schema.Bool(),
+	"default-action-timeout":      schema.ForceInt(),
+	"test-mode":                   schema.Bool(),
+	"proxy-ssh":                   schema.Bool(),
+	"lxc-clone":                   schema.Bool(),
+	"lxc-clone-aufs":              schema.Bool(),
+	"prefer-ipv6":                 schema.Bool(),
+	"enable-os-refresh-update":    schema.Bool(),
+	"enable-os-upgrade":           schema.Bool(),
+	"disable-network-management":  schema.Bool(),
 
 	// Deprecated fields, retain for backwards compatibility.
 	"tools-url":            schema.String(),
@@ -1025,29 +1191,44 @@ var fields = schema.Fields{
 // but some fields listed as optional here are actually mandatory
 // with NoDefaults and are checked at the later Validate stage.
 var alwaysOptional = schema.Defaults{
-	"agent-version":              schema.Omit,
-	"ca-cert":                    schema.Omit,
-	"authorized-keys":            schema.Omit,
-	"authorized-keys-path":       schema.Omit,
-	"ca-cert-path":               schema.Omit,
-	"ca-private-key-path":        schema.Omit,
-	"logging-config":             schema.Omit,
-	ProvisionerHarvestModeKey:    schema.Omit,
-	"bootstrap-timeout":          schema.Omit,
-	"bootstrap-retry-delay":      schema.Omit,
-	"bootstrap-addresses-delay":  schema.Omit,
-	"rsyslog-ca-cert":            schema.Omit,
-	"http-proxy":                 schema.Omit,
-	"https-proxy":                schema.Omit,
-	"ftp-proxy":                  schema.Omit,
-	"no-proxy":                   schema.Omit,
-	"apt-http-proxy":             schema.Omit,
-	"apt-https-proxy":            schema.Omit,
-	"apt-ftp-proxy":              schema.Omit,
-	"apt-mirror":                 schema.Omit,
-	"lxc-clone":                  schema.Omit,
-	"disable-network-management": schema.Omit,
-	"tools-stream":               schema.Omit,
+	"agent-version":               schema.Omit,
+	"ca-cert":                     schema.Omit,
+	"authorized-keys":             schema.Omit,
+	"authorized-keys-path":        schema.Omit,
+	"ca-cert-path":                schema.Omit,
+	"ca-private-key-path":         schema.Omit,
+	"logging-config":              schema.Omit,
+	ProvisionerHarvestModeKey:     schema.Omit,
+	"bootstrap-timeout":           schema.Omit,
+	"bootstrap-retry-delay":       schema.Omit,
+	"bootstrap-addresses-delay":   schema.Omit,
+	"bootstrap-min-addresses":     schema.Omit,
+	"bootstrap-preferred-cidr":    schema.Omit,
+	"bootstrap-ssh-concurrency":   schema.Omit,
+	"bootstrap-ssh-user":          schema.Omit,
+	"bootstrap-ssh-jump-host":     schema.Omit,
+	"bootstrap-ssh-jump-user":     schema.Omit,
+	"bootstrap-ssh-jump-key":      schema.Omit,
+	"bootstrap-winrm-user":        schema.Omit,
+	"bootstrap-winrm-password":    schema.Omit,
+	"bootstrap-cloudinit-version": schema.Omit,
+	"bootstrap-mirror-check":      schema.Omit,
+	"bootstrap-mirror-check-url":  schema.Omit,
+	"bootstrap-stream-log":        schema.Omit,
+	"default-action-timeout":      schema.Omit,
+	"rsyslog-ca-cert":             schema.Omit,
+	"http-proxy":                  schema.Omit,
+	"https-proxy":                 schema.Omit,
+	"ftp-proxy":                   schema.Omit,
+	"no-proxy":                    schema.Omit,
+	"apt-http-proxy":              schema.Omit,
+	"apt-https-proxy":             schema.Omit,
+	"apt-ftp-proxy":               schema.Omit,
+	"apt-mirror":                  schema.Omit,
+	"apt-security-mirror":         schema.Omit,
+	"lxc-clone":                   schema.Omit,
+	"disable-network-management":  schema.Omit,
+	"tools-stream":                schema.Omit,
 
 	// Deprecated fields, retain for backwards compatibility.
 	"tools-url":            "",
@@ -1231,9 +1412,30 @@ type SSHTimeoutOpts struct {
 	// an address.
 	RetryDelay time.Duration
 
+	// RetryJitter, if true, makes each wait between attempts a random
+	// duration from half to one and a half times RetryDelay, so that
+	// attempts on many addresses at once do not fall into step.
+	RetryJitter bool
+
 	// AddressesDelay is the amount of time between refreshing the
 	// addresses.
 	AddressesDelay time.Duration
+
+	// MinAddresses is the number of distinct addresses that must be
+	// reachable before one of them is chosen. If zero or one, the
+	// first reachable address is chosen.
+	MinAddresses int
+
+	// PreferredCIDR, if not empty, is a network whose addresses are
+	// chosen in preference to any others. An address in this network
+	// being reachable is sufficient, regardless of MinAddresses; if
+	// MinAddresses is zero, it is also necessary.
+	PreferredCIDR string
+
+	// MaxConcurrentDials, if positive, is the most addresses that
+	// are dialed at once. Further addresses are queued, and dialed
+	// in turn as others succeed or fail an attempt.
+	MaxConcurrentDials int
 }
 
 func addIfNotEmpty(settings map[string]interface{}, key, value string) {
@@ -649,6 +649,190 @@ var configTests = []configTest{
 			"bootstrap-addresses-delay": "illegal",
 		},
 		err: `bootstrap-addresses-delay: expected number, got string\("illegal"\)`,
+	}, {
+		about:       "Explicit bootstrap address requirements",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-min-addresses":  2,
+			"bootstrap-preferred-cidr": "10.0.0.0/8",
+		},
+	}, {
+		about:       "Negative bootstrap min addresses",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-min-addresses": -1,
+		},
+		err: `bootstrap-min-addresses must not be negative, got -1`,
+	}, {
+		about:       "Explicit bootstrap SSH concurrency",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-ssh-concurrency": 4,
+		},
+	}, {
+		about:       "Negative bootstrap SSH concurrency",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-ssh-concurrency": -1,
+		},
+		err: `bootstrap-ssh-concurrency must not be negative, got -1`,
+	}, {
+		about:       "Explicit default action timeout",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"default-action-timeout": 1800,
+		},
+	}, {
+		about:       "Negative default action timeout",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"default-action-timeout": -1,
+		},
+		err: `default-action-timeout must not be negative, got -1`,
+	}, {
+		about:       "Invalid bootstrap preferred CIDR",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-preferred-cidr": "10.0.0.1",
+		},
+		err: `invalid bootstrap-preferred-cidr in environment configuration: "10.0.0.1"`,
+	}, {
+		about:       "Explicit bootstrap cloud-init version",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-cloudinit-version": "17",
+		},
+	}, {
+		about:       "Invalid bootstrap cloud-init version",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-cloudinit-version": "0.7",
+		},
+		err: `invalid bootstrap-cloudinit-version in environment configuration: "0.7"`,
+	}, {
+		about:       "Explicit bootstrap mirror check URL",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-mirror-check-url": "http://mirror.internal/ubuntu/",
+		},
+	}, {
+		about:       "Bootstrap mirror check URL defaults to apt mirror",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":       "my-type",
+			"name":       "my-name",
+			"apt-mirror": "http://my.archive.ubuntu.com",
+		},
+	}, {
+		about:       "Bootstrap mirror check disabled",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-mirror-check":     false,
+			"bootstrap-mirror-check-url": "http://mirror.internal/ubuntu/",
+		},
+	}, {
+		about:       "Invalid bootstrap mirror check URL",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type": "my-type",
+			"name": "my-name",
+			"bootstrap-mirror-check-url": "mirror.internal",
+		},
+		err: `invalid bootstrap-mirror-check-url in environment configuration: "mirror.internal"`,
+	}, {
+		about:       "Bootstrap log streamed",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                 "my-type",
+			"name":                 "my-name",
+			"bootstrap-stream-log": true,
+		},
+	}, {
+		about:       "Explicit bootstrap SSH user",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":               "my-type",
+			"name":               "my-name",
+			"bootstrap-ssh-user": "ec2-user",
+		},
+	}, {
+		about:       "Explicit bootstrap WinRM credentials",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                     "my-type",
+			"name":                     "my-name",
+			"bootstrap-winrm-user":     "juju",
+			"bootstrap-winrm-password": "s3cret",
+		},
+	}, {
+		about:       "Invalid bootstrap SSH user",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":               "my-type",
+			"name":               "my-name",
+			"bootstrap-ssh-user": "root@host",
+		},
+		err: `invalid bootstrap-ssh-user in environment configuration: "root@host"`,
+	}, {
+		about:       "Explicit bootstrap SSH jump host",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                    "my-type",
+			"name":                    "my-name",
+			"bootstrap-ssh-jump-host": "bastion.example.com:2222",
+			"bootstrap-ssh-jump-user": "admin",
+			"bootstrap-ssh-jump-key":  "~/.ssh/bastion",
+		},
+	}, {
+		about:       "Invalid bootstrap SSH jump host",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                    "my-type",
+			"name":                    "my-name",
+			"bootstrap-ssh-jump-host": "admin@bastion.example.com",
+		},
+		err: `invalid bootstrap-ssh-jump-host in environment configuration: "admin@bastion.example.com"`,
+	}, {
+		about:       "Invalid bootstrap SSH jump user",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                    "my-type",
+			"name":                    "my-name",
+			"bootstrap-ssh-jump-host": "bastion.example.com",
+			"bootstrap-ssh-jump-user": "admin@bastion",
+		},
+		err: `invalid bootstrap-ssh-jump-user in environment configuration: "admin@bastion"`,
+	}, {
+		about:       "Bootstrap SSH jump user without jump host",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                    "my-type",
+			"name":                    "my-name",
+			"bootstrap-ssh-jump-user": "admin",
+		},
+		err: `bootstrap-ssh-jump-user and bootstrap-ssh-jump-key require bootstrap-ssh-jump-host`,
 	}, {
 		about:       "Invalid logging configuration",
 		useDefaults: config.UseDefaults,
@@ -799,6 +983,15 @@ var configTests = []configTest{
 			"apt-mirror": "http://my.archive.ubuntu.com",
 		},
 	},
+	{
+		about:       "Explicit apt-security-mirror",
+		useDefaults: config.UseDefaults,
+		attrs: testing.Attrs{
+			"type":                "my-type",
+			"name":                "my-name",
+			"apt-security-mirror": "http://my.security.ubuntu.com/ubuntu",
+		},
+	},
 }
 
 // authTokenConfigTest returns a config test that checks
@@ -1121,6 +1314,79 @@ func (test configTest) check(c *gc.C, home *gitjujutesting.FakeHome) {
 		sshOpts.AddressesDelay,
 		config.DefaultBootstrapSSHAddressesDelay,
 	)
+	c.Assert(sshOpts.RetryJitter, jc.IsTrue)
+	if v, ok := test.attrs["bootstrap-min-addresses"]; ok {
+		c.Assert(sshOpts.MinAddresses, gc.Equals, v)
+	} else {
+		c.Assert(sshOpts.MinAddresses, gc.Equals, 0)
+	}
+	if v, ok := test.attrs["bootstrap-preferred-cidr"]; ok {
+		c.Assert(sshOpts.PreferredCIDR, gc.Equals, v)
+	} else {
+		c.Assert(sshOpts.PreferredCIDR, gc.Equals, "")
+	}
+	if v, ok := test.attrs["bootstrap-ssh-concurrency"]; ok {
+		c.Assert(sshOpts.MaxConcurrentDials, gc.Equals, v)
+	} else {
+		c.Assert(sshOpts.MaxConcurrentDials, gc.Equals, 0)
+	}
+	if v, ok := test.attrs["default-action-timeout"]; ok {
+		c.Assert(cfg.DefaultActionTimeout(), gc.Equals, time.Duration(v.(int))*time.Second)
+	} else {
+		c.Assert(cfg.DefaultActionTimeout(), gc.Equals, time.Duration(0))
+	}
+	if v, ok := test.attrs["apt-security-mirror"]; ok {
+		c.Assert(cfg.AptSecurityMirror(), gc.Equals, v)
+	} else {
+		c.Assert(cfg.AptSecurityMirror(), gc.Equals, "")
+	}
+	cloudInitVersion, ok := cfg.BootstrapCloudInitVersion()
+	if v, ok := test.attrs["bootstrap-cloudinit-version"]; ok {
+		c.Assert(cloudInitVersion, gc.Equals, v)
+	} else {
+		c.Assert(cloudInitVersion, gc.Equals, "")
+	}
+	c.Assert(ok, gc.Equals, cloudInitVersion != "")
+
+	if v, ok := test.attrs["bootstrap-ssh-user"]; ok {
+		c.Assert(cfg.BootstrapSSHUser(), gc.Equals, v)
+	} else {
+		c.Assert(cfg.BootstrapSSHUser(), gc.Equals, config.DefaultBootstrapSSHUser)
+	}
+
+	jumpHost, jumpUser, jumpKey := cfg.BootstrapSSHJumpHost()
+	expectJumpHost, _ := test.attrs["bootstrap-ssh-jump-host"].(string)
+	expectJumpUser, _ := test.attrs["bootstrap-ssh-jump-user"].(string)
+	expectJumpKey, _ := test.attrs["bootstrap-ssh-jump-key"].(string)
+	c.Assert(jumpHost, gc.Equals, expectJumpHost)
+	c.Assert(jumpUser, gc.Equals, expectJumpUser)
+	c.Assert(jumpKey, gc.Equals, expectJumpKey)
+
+	winrmUser, winrmPassword := cfg.BootstrapWinRMCredentials()
+	if v, ok := test.attrs["bootstrap-winrm-user"]; ok {
+		c.Assert(winrmUser, gc.Equals, v)
+	} else {
+		c.Assert(winrmUser, gc.Equals, config.DefaultBootstrapWinRMUser)
+	}
+	if v, ok := test.attrs["bootstrap-winrm-password"]; ok {
+		c.Assert(winrmPassword, gc.Equals, v)
+	} else {
+		c.Assert(winrmPassword, gc.Equals, "")
+	}
+
+	mirrorCheckURL, mirrorCheck := cfg.BootstrapMirrorCheckURL()
+	c.Assert(mirrorCheck, gc.Equals, test.attrs["bootstrap-mirror-check"] != false)
+	if !mirrorCheck {
+		c.Assert(mirrorCheckURL, gc.Equals, "")
+	} else if v, ok := test.attrs["bootstrap-mirror-check-url"]; ok {
+		c.Assert(mirrorCheckURL, gc.Equals, v)
+	} else if v, ok := test.attrs["apt-mirror"]; ok {
+		c.Assert(mirrorCheckURL, gc.Equals, v)
+	} else {
+		c.Assert(mirrorCheckURL, gc.Equals, config.DefaultBootstrapMirrorCheckURL)
+	}
+
+	c.Assert(cfg.BootstrapStreamLog(), gc.Equals, test.attrs["bootstrap-stream-log"] == true)
 
 	if v, ok := test.attrs["image-stream"]; ok {
 		c.Assert(cfg.ImageStream(), gc.Equals, v)
@@ -300,6 +300,23 @@ func (*suite) TestBootstrapConfig(c *gc.C) {
 	c.Assert(cfg1.AllAttrs(), gc.DeepEquals, expect)
 }
 
+func (*suite) TestBootstrapConfigWithoutWinRMPassword(c *gc.C) {
+	attrs := dummySampleConfig().Merge(testing.Attrs{
+		"agent-version":            "1.2.3",
+		"bootstrap-winrm-user":     "juju",
+		"bootstrap-winrm-password": "s3cret",
+	})
+	cfg, err := config.New(config.NoDefaults, attrs)
+	c.Assert(err, gc.IsNil)
+
+	cfg1, err := environs.BootstrapConfig(cfg)
+	c.Assert(err, gc.IsNil)
+	c.Assert(inMap(cfg1.AllAttrs(), "bootstrap-winrm-password"), jc.IsFalse)
+	user, password := cfg1.BootstrapWinRMCredentials()
+	c.Assert(user, gc.Equals, "juju")
+	c.Assert(password, gc.Equals, "")
+}
+
 type dummyProvider struct {
 	environs.EnvironProvider
 }
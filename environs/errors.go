@@ -12,4 +12,5 @@ var (
 	ErrAlreadyBootstrapped = errors.New("environment is already bootstrapped")
 	ErrNoInstances         = errors.New("no instances found")
 	ErrPartialInstances    = errors.New("only some instances were found")
+	ErrBootstrapCancelled  = errors.New("bootstrap cancelled")
 )
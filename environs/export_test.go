@@ -13,3 +13,10 @@ func UpdateEnvironAttrs(envs *Environs, name string, newAttrs map[string]interfa
 		envs.rawEnvirons[name][k] = v
 	}
 }
+
+var (
+	WebhookRetryDelay   = &webhookRetryDelay
+	WebhookTimeout      = &webhookTimeout
+	WebhookFlushTimeout = &webhookFlushTimeout
+	WebhookQueueSize    = &webhookQueueSize
+)
@@ -6,6 +6,7 @@ package environs
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs/cloudinit"
@@ -15,6 +16,7 @@ import (
 	"github.com/juju/juju/network"
 	"github.com/juju/juju/state"
 	"github.com/juju/juju/tools"
+	"github.com/juju/juju/version"
 )
 
 // A EnvironProvider represents a computing and storage provider.
@@ -77,9 +79,26 @@ type BootstrapParams struct {
 	// directive used to choose the initial instance.
 	Placement string
 
+	// AvailabilityZone, if non-empty, names the availability zone in
+	// which to start the initial instance. It may not be given with
+	// Placement.
+	AvailabilityZone string
+
 	// AvailableTools is a collection of tools which the Bootstrap method
 	// may use to decide which architecture/series to instantiate.
 	AvailableTools tools.List
+
+	// OSRefreshUpdate and OSUpgrade, if not nil, override the
+	// enable-os-refresh-update and enable-os-upgrade settings for
+	// the bootstrap instance only.
+	OSRefreshUpdate *bool
+	OSUpgrade       *bool
+
+	// Timeout, if non-zero, is the time within which the bootstrap
+	// instance must be started and configured, including the call to
+	// the returned BootstrapFinalizer. If it passes first, bootstrap
+	// is aborted and the instance stopped.
+	Timeout time.Duration
 }
 
 // BootstrapFinalizer is a function returned from Environ.Bootstrap.
@@ -208,3 +227,139 @@ type BootstrapContext interface {
 	// delivered to the channel.
 	StopInterruptNotify(chan<- os.Signal)
 }
+
+// BootstrapMachine describes a bootstrap machine that has been
+// successfully configured.
+type BootstrapMachine struct {
+	// InstanceId is the provider-specific id of the instance.
+	InstanceId instance.Id
+
+	// Address is the address via which the machine was reached
+	// and configured.
+	Address string
+
+	// AddressScope is the scope of Address, such as public or
+	// cloud-local, if known.
+	AddressScope network.Scope
+
+	// Tools is the version of the tools installed on the machine.
+	Tools version.Binary
+
+	// Hardware holds the hardware characteristics of the machine,
+	// if known.
+	Hardware *instance.HardwareCharacteristics
+
+	// CloudInitStatus holds the status reported by cloud-init once
+	// the machine was configured, with any failing modules, such as
+	// "degraded: module apt-configure failed". It is empty if
+	// cloud-init cannot report its status.
+	CloudInitStatus string
+}
+
+// PostBootstrapContext may be implemented by a BootstrapContext
+// that wishes to be told about the bootstrap machine once it has
+// been configured, for example to register it with an external
+// inventory.
+type PostBootstrapContext interface {
+	BootstrapContext
+
+	// PostBootstrap is called with the details of the bootstrap
+	// machine after it has been successfully configured.
+	PostBootstrap(BootstrapMachine) error
+
+	// StrictPostBootstrap reports whether an error returned by
+	// PostBootstrap should cause the bootstrap to fail. If not,
+	// the error is logged and the bootstrap proceeds.
+	StrictPostBootstrap() bool
+}
+
+// WrapperBootstrapContext is implemented by a BootstrapContext that
+// adds to the behaviour of another. The optional interfaces a
+// BootstrapContext may implement, such as CancelBootstrapContext and
+// ProgressBootstrapContext, are looked for on each context in turn,
+// so that wrapping a context never hides them.
+type WrapperBootstrapContext interface {
+	BootstrapContext
+
+	// WrappedBootstrapContext returns the wrapped context.
+	WrappedBootstrapContext() BootstrapContext
+}
+
+// CancelBootstrapContext may be implemented by a BootstrapContext
+// whose bootstrap may be abandoned by its caller, as well as by the
+// user interrupting it. A bootstrap abandoned in this way fails with
+// ErrBootstrapCancelled.
+type CancelBootstrapContext interface {
+	BootstrapContext
+
+	// Cancelled returns a channel that is closed when the
+	// bootstrap should be abandoned.
+	Cancelled() <-chan struct{}
+}
+
+// BootstrapEventKind identifies a milestone reached during bootstrap.
+type BootstrapEventKind string
+
+const (
+	// BootstrapInstanceStarted is reported once the bootstrap
+	// instance has been started.
+	BootstrapInstanceStarted BootstrapEventKind = "instance-started"
+
+	// BootstrapAddressFound is reported once the bootstrap instance
+	// has first been assigned an address.
+	BootstrapAddressFound BootstrapEventKind = "address-found"
+
+	// BootstrapConnectFailed is reported each time an attempt to
+	// connect to an address of the bootstrap instance fails. The
+	// attempt is retried until the bootstrap times out.
+	BootstrapConnectFailed BootstrapEventKind = "connect-failed"
+
+	// BootstrapSSHConnected is reported once the bootstrap instance
+	// has been reached via SSH.
+	BootstrapSSHConnected BootstrapEventKind = "ssh-connected"
+
+	// BootstrapConfigured is reported once the bootstrap instance
+	// has been configured.
+	BootstrapConfigured BootstrapEventKind = "configured"
+
+	// BootstrapFailed is reported if starting or configuring the
+	// bootstrap instance fails.
+	BootstrapFailed BootstrapEventKind = "failed"
+)
+
+// BootstrapEvent describes a milestone reached during bootstrap.
+type BootstrapEvent struct {
+	Kind BootstrapEventKind `json:"kind"`
+	Time time.Time          `json:"time"`
+
+	// InstanceId is the id of the bootstrap instance, if known.
+	InstanceId instance.Id `json:"instance-id,omitempty"`
+
+	// AvailabilityZone is the availability zone of the bootstrap
+	// instance, if known, for a BootstrapInstanceStarted event.
+	AvailabilityZone string `json:"availability-zone,omitempty"`
+
+	// Address is the address of the bootstrap instance that the
+	// event concerns, if any.
+	Address string `json:"address,omitempty"`
+
+	// Attempt holds the number of attempts made on Address, for a
+	// BootstrapConnectFailed event.
+	Attempt int `json:"attempt,omitempty"`
+
+	// Error holds the reason for a BootstrapFailed or
+	// BootstrapConnectFailed event.
+	Error string `json:"error,omitempty"`
+}
+
+// ProgressBootstrapContext may be implemented by a BootstrapContext
+// that wishes to be told of each milestone reached during bootstrap,
+// in addition to the messages written to its standard error.
+type ProgressBootstrapContext interface {
+	BootstrapContext
+
+	// BootstrapProgress is called as each milestone is reached.
+	// It must not block, and may be called from more than one
+	// goroutine at once.
+	BootstrapProgress(BootstrapEvent)
+}
@@ -0,0 +1,32 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+// WithPostBootstrapHook returns a PostBootstrapContext that wraps ctx,
+// calling hook once the bootstrap machine has been configured. If
+// strict is true, an error returned by hook causes the bootstrap to
+// fail.
+func WithPostBootstrapHook(ctx BootstrapContext, hook func(BootstrapMachine) error, strict bool) PostBootstrapContext {
+	return &postBootstrapContext{
+		BootstrapContext: ctx,
+		hook:             hook,
+		strict:           strict,
+	}
+}
+
+type postBootstrapContext struct {
+	BootstrapContext
+	hook   func(BootstrapMachine) error
+	strict bool
+}
+
+// PostBootstrap is part of the PostBootstrapContext interface.
+func (ctx *postBootstrapContext) PostBootstrap(machine BootstrapMachine) error {
+	return ctx.hook(machine)
+}
+
+// StrictPostBootstrap is part of the PostBootstrapContext interface.
+func (ctx *postBootstrapContext) StrictPostBootstrap() bool {
+	return ctx.strict
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// webhookQueueSize is the number of events that may be waiting
+	// for delivery to a progress webhook; further events are dropped,
+	// unless they end the bootstrap.
+	webhookQueueSize = 64
+
+	// webhookAttempts is the number of times delivery of each event
+	// to a progress webhook is attempted.
+	webhookAttempts = 3
+
+	// webhookRetryDelay is the time waited between attempts.
+	webhookRetryDelay = 2 * time.Second
+
+	// webhookTimeout bounds each attempt.
+	webhookTimeout = 10 * time.Second
+
+	// webhookFlushTimeout bounds the time waited for queued events
+	// to be delivered once bootstrap is done.
+	webhookFlushTimeout = 30 * time.Second
+)
+
+// WithProgressWebhook returns a ProgressBootstrapContext that wraps
+// ctx, POSTing each bootstrap event as JSON to the given URL. Events
+// are delivered in order from a queue, with bounded retries and
+// timeouts, so that a slow or failing webhook never holds up the
+// bootstrap; failures are only logged. A BootstrapConnectFailed event
+// replaces any such event still waiting to be delivered, and events
+// that end the bootstrap are never dropped.
+//
+// The returned function must be called once bootstrap is done. It
+// waits a bounded time for queued events to be delivered, after
+// which any further events are discarded.
+func WithProgressWebhook(ctx BootstrapContext, url string) (ProgressBootstrapContext, func()) {
+	w := &webhookContext{
+		BootstrapContext: ctx,
+		url:              url,
+		client:           &http.Client{Timeout: webhookTimeout},
+		wake:             make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	go w.loop()
+	return w, w.stop
+}
+
+type webhookContext struct {
+	BootstrapContext
+	url    string
+	client *http.Client
+	wake   chan struct{}
+	done   chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+	queue   []BootstrapEvent
+}
+
+// BootstrapProgress is part of the ProgressBootstrapContext interface.
+func (w *webhookContext) BootstrapProgress(event BootstrapEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	if event.Kind == BootstrapConnectFailed {
+		// Connection attempts are retried until bootstrap times
+		// out, so only the latest failure is worth delivering.
+		for i, queued := range w.queue {
+			if queued.Kind == BootstrapConnectFailed {
+				w.queue = append(w.queue[:i], w.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(w.queue) >= webhookQueueSize && !isFinalBootstrapEvent(event.Kind) {
+		logger.Warningf("progress webhook queue full; dropping %q event", event.Kind)
+		return
+	}
+	w.queue = append(w.queue, event)
+	w.signal()
+}
+
+// WrappedBootstrapContext is part of the WrapperBootstrapContext
+// interface.
+func (w *webhookContext) WrappedBootstrapContext() BootstrapContext {
+	return w.BootstrapContext
+}
+
+// isFinalBootstrapEvent reports whether an event of the given kind
+// ends the bootstrap.
+func isFinalBootstrapEvent(kind BootstrapEventKind) bool {
+	return kind == BootstrapConfigured || kind == BootstrapFailed
+}
+
+// signal wakes the delivery loop, if it is not already due to wake.
+func (w *webhookContext) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next event to deliver, waiting for one to be
+// queued. It returns false once the queue is empty after stopping.
+func (w *webhookContext) next() (BootstrapEvent, bool) {
+	for {
+		w.mu.Lock()
+		if len(w.queue) > 0 {
+			event := w.queue[0]
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+			return event, true
+		}
+		stopped := w.stopped
+		w.mu.Unlock()
+		if stopped {
+			return BootstrapEvent{}, false
+		}
+		<-w.wake
+	}
+}
+
+func (w *webhookContext) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.signal()
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-time.After(webhookFlushTimeout):
+		logger.Warningf("gave up waiting for progress webhook %s", w.url)
+	}
+}
+
+func (w *webhookContext) loop() {
+	defer close(w.done)
+	for {
+		event, ok := w.next()
+		if !ok {
+			return
+		}
+		if err := w.post(event); err != nil {
+			logger.Warningf("cannot deliver %q event to progress webhook %s: %v", event.Kind, w.url, err)
+		}
+	}
+}
+
+// post delivers event to the webhook, retrying on failure.
+func (w *webhookContext) post(event BootstrapEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err = w.postOnce(data)
+		if err == nil || attempt >= webhookAttempts {
+			return err
+		}
+		time.Sleep(webhookRetryDelay)
+	}
+}
+
+func (w *webhookContext) postOnce(data []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
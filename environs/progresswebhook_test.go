@@ -0,0 +1,167 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package environs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/testing"
+)
+
+type ProgressWebhookSuite struct {
+	testing.FakeJujuHomeSuite
+}
+
+var _ = gc.Suite(&ProgressWebhookSuite{})
+
+func (s *ProgressWebhookSuite) SetUpTest(c *gc.C) {
+	s.FakeJujuHomeSuite.SetUpTest(c)
+	s.PatchValue(environs.WebhookRetryDelay, time.Millisecond)
+}
+
+// eventRecorder is an HTTP handler that records the bootstrap events
+// POSTed to it, failing the first few requests.
+type eventRecorder struct {
+	c        *gc.C
+	mu       sync.Mutex
+	failures int
+	events   []environs.BootstrapEvent
+}
+
+func (r *eventRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.c.Check(req.Method, gc.Equals, "POST")
+	r.c.Check(req.Header.Get("Content-Type"), gc.Equals, "application/json")
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	var event environs.BootstrapEvent
+	err := json.NewDecoder(req.Body).Decode(&event)
+	r.c.Check(err, gc.IsNil)
+	r.events = append(r.events, event)
+}
+
+func (s *ProgressWebhookSuite) TestEventsDelivered(c *gc.C) {
+	recorder := &eventRecorder{c: c, failures: 2}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	ctx, stop := environs.WithProgressWebhook(testing.Context(c), server.URL)
+	now := time.Now().UTC().Round(time.Second)
+	sent := []environs.BootstrapEvent{{
+		Kind:       environs.BootstrapInstanceStarted,
+		Time:       now,
+		InstanceId: "i-bootstrap",
+	}, {
+		Kind:       environs.BootstrapFailed,
+		Time:       now,
+		InstanceId: "i-bootstrap",
+		Error:      "oops",
+	}}
+	for _, event := range sent {
+		ctx.BootstrapProgress(event)
+	}
+	stop()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	c.Assert(recorder.events, gc.HasLen, len(sent))
+	for i, event := range recorder.events {
+		c.Check(event.Time.Equal(sent[i].Time), gc.Equals, true)
+		event.Time = sent[i].Time
+		c.Check(event, gc.DeepEquals, sent[i])
+	}
+
+	// Events reported after stopping are discarded.
+	ctx.BootstrapProgress(sent[0])
+}
+
+func (s *ProgressWebhookSuite) TestSlowWebhookDoesNotBlock(c *gc.C) {
+	s.PatchValue(environs.WebhookTimeout, testing.ShortWait)
+	s.PatchValue(environs.WebhookFlushTimeout, testing.ShortWait)
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	ctx, stop := environs.WithProgressWebhook(testing.Context(c), server.URL)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ctx.BootstrapProgress(environs.BootstrapEvent{Kind: environs.BootstrapAddressFound})
+		}
+		stop()
+	}()
+	select {
+	case <-done:
+	case <-time.After(testing.LongWait):
+		c.Fatalf("progress webhook blocked bootstrap")
+	}
+}
+
+func (s *ProgressWebhookSuite) TestConnectFailuresCoalesced(c *gc.C) {
+	s.PatchValue(environs.WebhookQueueSize, 2)
+	recorder := &eventRecorder{c: c}
+	received := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case received <- struct{}{}:
+			<-unblock
+		default:
+		}
+		recorder.ServeHTTP(w, req)
+	}))
+	defer server.Close()
+
+	ctx, stop := environs.WithProgressWebhook(testing.Context(c), server.URL)
+	ctx.BootstrapProgress(environs.BootstrapEvent{Kind: environs.BootstrapAddressFound})
+	select {
+	case <-received:
+	case <-time.After(testing.LongWait):
+		c.Fatalf("first event not delivered")
+	}
+	// With the first event still being delivered, the connection
+	// failures are coalesced into the latest one, the queue fills,
+	// and the final event is queued anyway.
+	for i := 1; i <= 100; i++ {
+		ctx.BootstrapProgress(environs.BootstrapEvent{
+			Kind:    environs.BootstrapConnectFailed,
+			Attempt: i,
+		})
+	}
+	ctx.BootstrapProgress(environs.BootstrapEvent{Kind: environs.BootstrapSSHConnected})
+	ctx.BootstrapProgress(environs.BootstrapEvent{Kind: environs.BootstrapSSHConnected, Address: "dropped"})
+	ctx.BootstrapProgress(environs.BootstrapEvent{Kind: environs.BootstrapFailed, Error: "oops"})
+	close(unblock)
+	stop()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var kinds []environs.BootstrapEventKind
+	for _, event := range recorder.events {
+		kinds = append(kinds, event.Kind)
+	}
+	c.Assert(kinds, gc.DeepEquals, []environs.BootstrapEventKind{
+		environs.BootstrapAddressFound,
+		environs.BootstrapConnectFailed,
+		environs.BootstrapSSHConnected,
+		environs.BootstrapFailed,
+	})
+	c.Assert(recorder.events[1].Attempt, gc.Equals, 100)
+	c.Assert(recorder.events[2].Address, gc.Equals, "")
+}
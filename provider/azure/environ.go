@@ -292,7 +292,7 @@ func (env *azureEnviron) Bootstrap(ctx environs.BootstrapContext, args environs.
 			env.deleteVirtualNetwork()
 		}
 	}()
-	return common.Bootstrap(ctx, env, args)
+	return common.BootstrapValues(common.Bootstrap(ctx, env, args))
 }
 
 // isLegacyInstance reports whether the instance is a
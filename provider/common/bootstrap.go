@@ -6,15 +6,21 @@ package common
 import (
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/utils"
 	"github.com/juju/utils/parallel"
+	"github.com/juju/utils/proxy"
 	"github.com/juju/utils/shell"
 
 	coreCloudinit "github.com/juju/juju/cloudinit"
@@ -23,6 +29,7 @@ import (
 	"github.com/juju/juju/environs/cloudinit"
 	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/instance"
+	"github.com/juju/juju/juju/osenv"
 	"github.com/juju/juju/network"
 	coretools "github.com/juju/juju/tools"
 	"github.com/juju/juju/utils/ssh"
@@ -30,100 +37,677 @@ import (
 
 var logger = loggo.GetLogger("juju.provider.common")
 
+// BootstrapResult describes the bootstrap instance started by
+// Bootstrap.
+type BootstrapResult struct {
+	// Arch and Series are the architecture and series of the
+	// bootstrap instance.
+	Arch   string
+	Series string
+
+	// Finalizer configures the instance as the bootstrap machine.
+	Finalizer environs.BootstrapFinalizer
+
+	// InstanceId identifies the bootstrap instance.
+	InstanceId instance.Id
+
+	// AvailabilityZone is the availability zone of the bootstrap
+	// instance, if the environment supports them and it is known.
+	AvailabilityZone string
+
+	// Hardware describes the bootstrap instance.
+	Hardware *instance.HardwareCharacteristics
+}
+
+// BootstrapValues returns the values held by the result of Bootstrap
+// in the form returned by the Bootstrap method of environs.Environ, so that providers may write:
+//
+//	return common.BootstrapValues(common.Bootstrap(ctx, env, args))
+func BootstrapValues(result *BootstrapResult, err error) (arch, series string, _ environs.BootstrapFinalizer, _ error) {
+	if err != nil {
+		return "", "", nil, err
+	}
+	return result.Arch, result.Series, result.Finalizer, nil
+}
+
 // Bootstrap is a common implementation of the Bootstrap method defined on
 // environs.Environ; we strongly recommend that this implementation be used
-// when writing a new provider.
-func Bootstrap(ctx environs.BootstrapContext, env environs.Environ, args environs.BootstrapParams) (arch, series string, _ environs.BootstrapFinalizer, err error) {
+// when writing a new provider. Providers may return its result with
+// BootstrapValues.
+func Bootstrap(ctx environs.BootstrapContext, env environs.Environ, args environs.BootstrapParams) (*BootstrapResult, error) {
 	// TODO make safe in the case of racing Bootstraps
 	// If two Bootstraps are called concurrently, there's
 	// no way to make sure that only one succeeds.
 
 	// First thing, ensure we have tools otherwise there's no point.
-	series = config.PreferredSeries(env.Config())
+	series := config.PreferredSeries(env.Config())
 	availableTools, err := args.AvailableTools.Match(coretools.Filter{Series: series})
 	if err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 
-	// Get the bootstrap SSH client. Do this early, so we know
-	// not to bother with any of the below if we can't finish the job.
-	client := ssh.DefaultClient
-	if client == nil {
-		// This should never happen: if we don't have OpenSSH, then
-		// go.crypto/ssh should be used with an auto-generated key.
-		return "", "", nil, fmt.Errorf("no SSH client available")
+	// Check that the instance could be reached before starting it.
+	if err := checkBootstrapConnector(env.Config(), series); err != nil {
+		return nil, err
+	}
+
+	client, err := bootstrapSSHClient()
+	if err != nil {
+		return nil, err
 	}
 
 	machineConfig, err := environs.NewBootstrapMachineConfig(args.Constraints, series)
 	if err != nil {
-		return "", "", nil, err
+		return nil, err
 	}
 	machineConfig.EnableOSRefreshUpdate = env.Config().EnableOSRefreshUpdate()
 	machineConfig.EnableOSUpgrade = env.Config().EnableOSUpgrade()
+	overrideOSUpdates(machineConfig, args)
+
+	placement, err := bootstrapPlacement(env, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var deadline *bootstrapDeadline
+	if args.Timeout > 0 {
+		deadline = newBootstrapDeadline(args.Timeout)
+	}
 
 	fmt.Fprintln(ctx.GetStderr(), "Launching instance")
-	inst, hw, _, err := env.StartInstance(environs.StartInstanceParams{
+	inst, hw, err := startBootstrapInstance(env, environs.StartInstanceParams{
 		Constraints:   args.Constraints,
 		Tools:         availableTools,
 		MachineConfig: machineConfig,
-		Placement:     args.Placement,
-	})
+		Placement:     placement,
+	}, deadline, bootstrapCancelled(ctx))
 	if err != nil {
-		return "", "", nil, fmt.Errorf("cannot start bootstrap instance: %v", err)
+		reportProgress(ctx, environs.BootstrapEvent{
+			Kind:  environs.BootstrapFailed,
+			Error: err.Error(),
+		})
+		return nil, err
 	}
 	fmt.Fprintf(ctx.GetStderr(), " - %s\n", inst.Id())
+	// The tools chosen for the instance must be able to run on it;
+	// if it is not of an architecture for which there are tools,
+	// it is of no use.
+	if err := checkBootstrapArch(inst, hw, availableTools); err != nil {
+		stopBootstrapInstance(env, inst)
+		reportProgress(ctx, environs.BootstrapEvent{
+			Kind:       environs.BootstrapFailed,
+			InstanceId: inst.Id(),
+			Error:      err.Error(),
+		})
+		return nil, err
+	}
+	zone := instanceAvailabilityZone(env, inst.Id())
+	reportProgress(ctx, environs.BootstrapEvent{
+		Kind:             environs.BootstrapInstanceStarted,
+		InstanceId:       inst.Id(),
+		AvailabilityZone: zone,
+	})
+	stop := func() {
+		stopBootstrapInstance(env, inst)
+	}
+	return &BootstrapResult{
+		Arch:             *hw.Arch,
+		Series:           series,
+		Finalizer:        bootstrapFinalizer(env, client, inst, hw, args, deadline, stop),
+		InstanceId:       inst.Id(),
+		AvailabilityZone: zone,
+		Hardware:         hw,
+	}, nil
+}
+
+// checkBootstrapArch returns an error if the architecture of the
+// bootstrap instance, described by hw, is not known or is not one for
+// which there are tools in availableTools.
+func checkBootstrapArch(inst instance.Instance, hw *instance.HardwareCharacteristics, availableTools coretools.List) error {
+	if hw == nil || hw.Arch == nil {
+		return fmt.Errorf("architecture of bootstrap instance %s not known", inst.Id())
+	}
+	arches := availableTools.Arches()
+	for _, arch := range arches {
+		if arch == *hw.Arch {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"bootstrap instance %s has architecture %s, but tools are only available for %s",
+		inst.Id(), *hw.Arch, strings.Join(arches, ", "),
+	)
+}
+
+// bootstrapPlacement returns the placement directive with which to
+// start the bootstrap instance. If an availability zone is requested,
+// it must be one of env's available zones, and is given as a "zone"
+// directive.
+func bootstrapPlacement(env environs.Environ, args environs.BootstrapParams) (string, error) {
+	if args.AvailabilityZone == "" {
+		return args.Placement, nil
+	}
+	if args.Placement != "" {
+		return "", fmt.Errorf("cannot specify both a placement directive and an availability zone")
+	}
+	zonedEnv, ok := env.(ZonedEnviron)
+	if !ok {
+		return "", errors.NotSupportedf("availability zones")
+	}
+	zones, err := zonedEnv.AvailabilityZones()
+	if err != nil {
+		return "", errors.Annotate(err, "cannot get availability zones")
+	}
+	var valid []string
+	for _, zone := range zones {
+		if !zone.Available() {
+			continue
+		}
+		if zone.Name() == args.AvailabilityZone {
+			return "zone=" + args.AvailabilityZone, nil
+		}
+		valid = append(valid, zone.Name())
+	}
+	sort.Strings(valid)
+	return "", fmt.Errorf("invalid availability zone %q: valid zones are %s", args.AvailabilityZone, strings.Join(valid, ", "))
+}
 
-	finalize := func(ctx environs.BootstrapContext, mcfg *cloudinit.MachineConfig) error {
+// instanceAvailabilityZone returns the availability zone of the given
+// instance, or "" if env does not support availability zones or the
+// zone cannot be found.
+func instanceAvailabilityZone(env environs.Environ, id instance.Id) string {
+	zonedEnv, ok := env.(ZonedEnviron)
+	if !ok {
+		return ""
+	}
+	zones, err := zonedEnv.InstanceAvailabilityZoneNames([]instance.Id{id})
+	if err != nil || len(zones) != 1 {
+		logger.Warningf("cannot get availability zone of bootstrap instance %s: %v", id, err)
+		return ""
+	}
+	return zones[0]
+}
+
+// BootstrapToInstance is like Bootstrap, but bootstraps the given
+// instance, which has already been allocated, rather than starting
+// one; hw describes the instance, and must hold its architecture.
+// The instance is configured as the bootstrap machine just as one
+// started by Bootstrap would be, so it must be running the userdata
+// built from the machine config passed to the returned finalizer.
+// The instance is left running if bootstrap does not complete in
+// time.
+//
+// The values returned are those returned by the Bootstrap method of
+// environs.Environ, so that providers need not treat a pre-allocated
+// instance differently.
+func BootstrapToInstance(
+	ctx environs.BootstrapContext, env environs.Environ, args environs.BootstrapParams,
+	inst instance.Instance, hw *instance.HardwareCharacteristics,
+) (arch, series string, _ environs.BootstrapFinalizer, _ error) {
+	return BootstrapValues(bootstrapToInstance(ctx, env, args, inst, hw))
+}
+
+func bootstrapToInstance(
+	ctx environs.BootstrapContext, env environs.Environ, args environs.BootstrapParams,
+	inst instance.Instance, hw *instance.HardwareCharacteristics,
+) (*BootstrapResult, error) {
+	if hw == nil || hw.Arch == nil {
+		return nil, fmt.Errorf("architecture of bootstrap instance %s not known", inst.Id())
+	}
+	series := config.PreferredSeries(env.Config())
+	if _, err := args.AvailableTools.Match(coretools.Filter{Series: series, Arch: *hw.Arch}); err != nil {
+		return nil, err
+	}
+	client, err := bootstrapSSHClient()
+	if err != nil {
+		return nil, err
+	}
+	var deadline *bootstrapDeadline
+	if args.Timeout > 0 {
+		deadline = newBootstrapDeadline(args.Timeout)
+	}
+	fmt.Fprintf(ctx.GetStderr(), "Using instance %s\n", inst.Id())
+	return &BootstrapResult{
+		Arch:       *hw.Arch,
+		Series:     series,
+		Finalizer:  bootstrapFinalizer(env, client, inst, hw, args, deadline, nil),
+		InstanceId: inst.Id(),
+		Hardware:   hw,
+	}, nil
+}
+
+// bootstrapSSHClient returns the client used to connect to the
+// bootstrap machine. It is got before anything else is done, so we
+// know not to bother if we can't finish the job.
+//
+// The client logs in with the keys kept in the juju home, which are
+// generated the first time they are needed and reused thereafter, so
+// that a retried bootstrap presents the same key as the first
+// attempt. They are normally loaded by juju.InitJujuHome, but are
+// loaded here if that has not been done.
+func bootstrapSSHClient() (ssh.Client, error) {
+	client := ssh.DefaultClient
+	if client == nil {
+		// This should never happen: if we don't have OpenSSH, then
+		// go.crypto/ssh should be used with an auto-generated key.
+		return nil, fmt.Errorf("no SSH client available")
+	}
+	if len(ssh.PrivateKeyFiles()) == 0 {
+		if home := osenv.JujuHomeDir(); home != "" {
+			if err := ssh.LoadClientKeys(filepath.Join(home, "ssh")); err != nil {
+				return nil, fmt.Errorf("cannot load ssh client keys: %v", err)
+			}
+		}
+	}
+	return client, nil
+}
+
+// HostVerifier may be implemented by an environs.Environ whose
+// bootstrap instances cannot be told apart from other machines by the
+// nonce check used by default, for example because their images have
+// no POSIX shell.
+type HostVerifier interface {
+	// HostVerifyScript returns a script that fails unless it is run
+	// on the machine described by machineConfig.
+	HostVerifyScript(machineConfig *cloudinit.MachineConfig) string
+}
+
+// overrideOSUpdates overrides the environment's settings for refreshing
+// and upgrading the packages of the bootstrap instance with any given
+// in args.
+func overrideOSUpdates(mcfg *cloudinit.MachineConfig, args environs.BootstrapParams) {
+	if args.OSRefreshUpdate != nil {
+		mcfg.EnableOSRefreshUpdate = *args.OSRefreshUpdate
+	}
+	if args.OSUpgrade != nil {
+		mcfg.EnableOSUpgrade = *args.OSUpgrade
+	}
+}
+
+// bootstrapFinalizer returns the finalizer that configures inst as the
+// bootstrap machine, as args request. If deadline is not nil and passes
+// first, the configuration is abandoned, an error is returned once it
+// has stopped, and stop, if not nil, is called. If env implements
+// HostVerifier, its script is used to verify the machine.
+func bootstrapFinalizer(
+	env environs.Environ, client ssh.Client, inst instance.Instance, hw *instance.HardwareCharacteristics,
+	args environs.BootstrapParams, deadline *bootstrapDeadline, stop func(),
+) environs.BootstrapFinalizer {
+	finish := func(ctx environs.BootstrapContext, mcfg *cloudinit.MachineConfig) error {
 		mcfg.InstanceId = inst.Id()
 		mcfg.HardwareCharacteristics = hw
+		if verifier, ok := env.(HostVerifier); ok {
+			mcfg.BootstrapHostVerifyScript = verifier.HostVerifyScript
+		}
 		if err := environs.FinishMachineConfig(mcfg, env.Config()); err != nil {
 			return err
 		}
-		return FinishBootstrap(ctx, client, inst, mcfg)
+		overrideOSUpdates(mcfg, args)
+		if deadline == nil {
+			return FinishBootstrap(ctx, client, inst, mcfg)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- FinishBootstrap(deadline.context(ctx), client, inst, mcfg)
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-deadline.after():
+			err := deadline.expire()
+			if err == nil {
+				// The deadline passed after the machine was
+				// configured; the bootstrap is allowed to finish.
+				return <-done
+			}
+			// FinishBootstrap is interrupted by the deadline, and
+			// must return before the instance is stopped.
+			<-done
+			if stop != nil {
+				stop()
+			}
+			return err
+		}
+	}
+	return func(ctx environs.BootstrapContext, mcfg *cloudinit.MachineConfig) error {
+		err := finish(ctx, mcfg)
+		if err != nil {
+			reportProgress(ctx, environs.BootstrapEvent{
+				Kind:       environs.BootstrapFailed,
+				InstanceId: inst.Id(),
+				Error:      err.Error(),
+			})
+		}
+		return err
+	}
+}
+
+// startBootstrapInstance starts the bootstrap instance. If deadline is
+// not nil and passes first, an error is returned, and the instance is
+// stopped once it has started. Likewise, if cancelled is closed first,
+// environs.ErrBootstrapCancelled is returned.
+func startBootstrapInstance(
+	env environs.Environ, args environs.StartInstanceParams, deadline *bootstrapDeadline, cancelled <-chan struct{},
+) (instance.Instance, *instance.HardwareCharacteristics, error) {
+	type started struct {
+		inst instance.Instance
+		hw   *instance.HardwareCharacteristics
+		err  error
+	}
+	done := make(chan started, 1)
+	go func() {
+		inst, hw, _, err := env.StartInstance(args)
+		if err != nil {
+			err = fmt.Errorf("cannot start bootstrap instance: %v", err)
+		}
+		done <- started{inst, hw, err}
+	}()
+	var expired <-chan time.Time
+	if deadline != nil {
+		deadline.setPhase("starting the bootstrap instance")
+		expired = deadline.after()
+	}
+	abandon := func() {
+		go func() {
+			if s := <-done; s.err == nil {
+				stopBootstrapInstance(env, s.inst)
+			}
+		}()
+	}
+	select {
+	case s := <-done:
+		return s.inst, s.hw, s.err
+	case <-expired:
+		abandon()
+		return nil, nil, deadline.expire()
+	case <-cancelled:
+		abandon()
+		return nil, nil, environs.ErrBootstrapCancelled
 	}
-	return *hw.Arch, series, finalize, nil
 }
 
 // FinishBootstrap completes the bootstrap process by connecting
-// to the instance via SSH and carrying out the cloud-config.
+// to the instance, via SSH or WinRM according to its series, and
+// carrying out the cloud-config.
 //
 // Note: FinishBootstrap is exposed so it can be replaced for testing.
 var FinishBootstrap = func(ctx environs.BootstrapContext, client ssh.Client, inst instance.Instance, machineConfig *cloudinit.MachineConfig) error {
 	interrupted := make(chan os.Signal, 1)
 	ctx.InterruptNotify(interrupted)
 	defer ctx.StopInterruptNotify(interrupted)
+	// Reach the instance through the jump host, if there is one,
+	// and refuse to talk to it if it does not present any host key
+	// that it is expected to.
+	client = newJumpHostClient(client, machineConfig.Config)
+	client = newHostKeyClient(client, inst.Id(), machineConfig.BootstrapHostKeys)
+	conn, err := newBootstrapConnector(client, machineConfig)
+	if err != nil {
+		return err
+	}
 	// Each attempt to connect to an address must verify the machine is the
-	// bootstrap machine by checking its nonce file exists and contains the
-	// nonce in the MachineConfig. This also blocks sshinit from proceeding
-	// until cloud-init has completed, which is necessary to ensure apt
-	// invocations don't trample each other.
-	nonceFile := utils.ShQuote(path.Join(machineConfig.DataDir, cloudinit.NonceFile))
-	checkNonceCommand := fmt.Sprintf(`
-	noncefile=%s
-	if [ ! -e "$noncefile" ]; then
-		echo "$noncefile does not exist" >&2
-		exit 1
-	fi
-	content=$(cat $noncefile)
-	if [ "$content" != %s ]; then
-		echo "$noncefile contents do not match machine nonce" >&2
-		exit 1
-	fi
-	`, nonceFile, utils.ShQuote(machineConfig.MachineNonce))
+	// bootstrap machine, by default by checking its nonce file exists and
+	// contains the nonce in the MachineConfig.
+	checkHostScript := conn.CheckNonceScript(machineConfig)
+	if machineConfig.BootstrapHostVerifyScript != nil {
+		checkHostScript = machineConfig.BootstrapHostVerifyScript(machineConfig)
+	}
+	setBootstrapPhase(ctx, "waiting for SSH")
 	addr, err := waitSSH(
 		ctx,
 		interrupted,
-		client,
-		checkNonceCommand,
+		conn,
+		checkHostScript,
 		inst,
 		machineConfig.Config.BootstrapSSHOpts(),
+		func(addr network.Address, attempt int, err error) {
+			reportProgress(ctx, environs.BootstrapEvent{
+				Kind:       environs.BootstrapConnectFailed,
+				InstanceId: inst.Id(),
+				Address:    addr.Value,
+				Attempt:    attempt,
+				Error:      err.Error(),
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+	host := addr.Value
+	reportProgress(ctx, environs.BootstrapEvent{
+		Kind:       environs.BootstrapSSHConnected,
+		InstanceId: inst.Id(),
+		Address:    host,
+	})
+	setBootstrapPhase(ctx, "checking the bootstrap instance")
+	if err := conn.CheckMachine(host, machineConfig); err != nil {
+		return err
+	}
+	setBootstrapPhase(ctx, "configuring machine")
+	if err := conn.ConfigureMachine(ctx, host, machineConfig); err != nil {
+		return err
+	}
+	setBootstrapPhase(ctx, "checking cloud-init status")
+	status, err := conn.CloudInitStatus(host)
+	if err != nil {
+		logger.Warningf("cannot determine cloud-init status: %v", err)
+	}
+	if err := checkCloudInitStatus(ctx, status); err != nil {
+		return err
+	}
+	reportProgress(ctx, environs.BootstrapEvent{
+		Kind:       environs.BootstrapConfigured,
+		InstanceId: inst.Id(),
+		Address:    host,
+	})
+	// Once the machine is known to be configured, the bootstrap may
+	// not be abandoned; nor may it go further if it already has been.
+	if err := finishBootstrap(ctx); err != nil {
+		return err
+	}
+	setBootstrapPhase(ctx, "running the post-bootstrap hook")
+	return postBootstrap(ctx, inst, addr, machineConfig, status)
+}
+
+// bootstrapSSHUser returns the user to log in to the machine
+// described by machineConfig as.
+func bootstrapSSHUser(machineConfig *cloudinit.MachineConfig) string {
+	if machineConfig.BootstrapSSHUser != "" {
+		return machineConfig.BootstrapSSHUser
+	}
+	return config.DefaultBootstrapSSHUser
+}
+
+// mirrorCheckTimeout is how long, in seconds, the bootstrap instance
+// is given to reach the package mirror.
+const mirrorCheckTimeout = 30
+
+// checkMirrorReachable checks that the given host can reach the
+// package mirror at mirrorURL, so that bootstrap fails quickly rather
+// than when the configuration script first installs a package. The
+// mirror is reached through the given proxies, as apt would reach it.
+func checkMirrorReachable(client ssh.Client, user, host, mirrorURL string, proxySettings proxy.Settings) error {
+	script := fmt.Sprintf(
+		"curl -sS --head --fail --max-time %d -o /dev/null %s",
+		mirrorCheckTimeout, utils.ShQuote(mirrorURL),
 	)
+	if env := proxySettings.AsScriptEnvironment(); env != "" {
+		script = env + "\n" + script
+	}
+	if err := connectSSH(client, user, host, script); err != nil {
+		return fmt.Errorf("bootstrap node cannot reach package mirror %s: %v", mirrorURL, err)
+	}
+	return nil
+}
+
+// minCloudInitVersion is the oldest version of cloud-init, in the form
+// "major.minor", that Juju supports; it is the version shipped with
+// precise.
+var minCloudInitVersion = "0.6"
+
+// checkCloudInitVersion checks that the version of cloud-init installed
+// on the given host is supported and, if expected is not empty, that
+// its major version is expected. It returns the installed version.
+func checkCloudInitVersion(client ssh.Client, user, host, expected string) (string, error) {
+	installed, err := cloudInitVersion(client, user, host)
+	if err != nil {
+		return "", fmt.Errorf("cannot determine cloud-init version: %v", err)
+	}
+	major, minor, err := parseCloudInitVersion(installed)
+	if err != nil {
+		return "", err
+	}
+	minMajor, minMinor, err := parseCloudInitVersion(minCloudInitVersion)
+	if err != nil {
+		return "", err
+	}
+	if major < minMajor || major == minMajor && minor < minMinor {
+		return "", fmt.Errorf(
+			"cloud-init %s on bootstrap instance is older than the minimum supported version %s",
+			installed, minCloudInitVersion,
+		)
+	}
+	if expected == "" {
+		return installed, nil
+	}
+	expectedMajor, err := strconv.Atoi(expected)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse expected cloud-init major version %q", expected)
+	}
+	if major != expectedMajor {
+		return "", fmt.Errorf(
+			"bootstrap instance has cloud-init %s, but major version %d was expected",
+			installed, expectedMajor,
+		)
+	}
+	return installed, nil
+}
+
+// parseCloudInitVersion returns the major and minor version numbers
+// of the given cloud-init version, which may be a full package version
+// such as "0.7.5-0ubuntu1.3".
+func parseCloudInitVersion(version string) (major, minor int, err error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("cannot parse cloud-init version %q", version)
+	}
+	// Strip any package revision from the minor version, as in "0.7-0ubuntu1".
+	if i := strings.IndexAny(parts[1], "-~+"); i >= 0 {
+		parts[1] = parts[1][:i]
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || major < 0 || minor < 0 {
+		return 0, 0, fmt.Errorf("cannot parse cloud-init version %q", version)
+	}
+	return major, minor, nil
+}
+
+// cloudInitStatusMajorVersion is the first major version of cloud-init,
+// following the switch to date-based versions, that provides
+// "cloud-init status".
+const cloudInitStatusMajorVersion = 17
+
+// cloudInitWaitTimeout bounds how long the configuration script waits
+// for cloud-init to finish before carrying on regardless.
+const cloudInitWaitTimeout = 10 * time.Minute
+
+// addCloudInitWaitCommands adds boot commands that wait for the
+// cloud-init run begun when the machine booted to finish, so that
+// its package operations do not trample Juju's. How to wait depends
+// on the major version of cloud-init: recent versions are asked with
+// "cloud-init status --wait", while older ones are waited on until
+// they write their boot-finished marker. Nothing is added if the
+// version is not known.
+func addCloudInitWaitCommands(cloudcfg *coreCloudinit.Config, version string) error {
+	if version == "" {
+		return nil
+	}
+	major, _, err := parseCloudInitVersion(version)
 	if err != nil {
 		return err
 	}
-	return ConfigureMachine(ctx, client, addr, machineConfig)
+	timeout := int(cloudInitWaitTimeout / time.Second)
+	cloudcfg.AddBootCmd(coreCloudinit.LogProgressCmd("Waiting for cloud-init %s to finish", version))
+	if major >= cloudInitStatusMajorVersion {
+		cloudcfg.AddBootCmd(fmt.Sprintf("timeout %d cloud-init status --wait >/dev/null || true", timeout))
+	} else {
+		cloudcfg.AddBootCmd(fmt.Sprintf(
+			"timeout %d sh -c 'while [ ! -e /var/lib/cloud/instance/boot-finished ]; do sleep 1; done' || true",
+			timeout,
+		))
+	}
+	return nil
+}
+
+// cloudInitVersion is called to determine the version of the
+// cloud-init package installed on the specified host.
+var cloudInitVersion = func(client ssh.Client, user, host string) (string, error) {
+	cmd := client.Command(user+"@"+host, []string{"/bin/bash"}, nil)
+	cmd.Stdin = strings.NewReader(`dpkg-query -W -f='${Version}' cloud-init`)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > 0 {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(output)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// reportProgress tells ctx of the given bootstrap event, if ctx or any
+// context it wraps implements environs.ProgressBootstrapContext.
+func reportProgress(ctx environs.BootstrapContext, event environs.BootstrapEvent) {
+	for _, ctx := range contextChain(ctx) {
+		if progressCtx, ok := ctx.(environs.ProgressBootstrapContext); ok {
+			event.Time = time.Now()
+			progressCtx.BootstrapProgress(event)
+			return
+		}
+	}
+}
+
+// postBootstrap notifies ctx of the configured bootstrap machine, if
+// ctx or any context it wraps implements environs.PostBootstrapContext. Errors are only logged,
+// as the bootstrap has already succeeded, unless the context demands
+// otherwise.
+func postBootstrap(ctx environs.BootstrapContext, inst instance.Instance, addr network.Address, machineConfig *cloudinit.MachineConfig, status *cloudInitStatus) error {
+	var postCtx environs.PostBootstrapContext
+	for _, ctx := range contextChain(ctx) {
+		if ctx, ok := ctx.(environs.PostBootstrapContext); ok {
+			postCtx = ctx
+			break
+		}
+	}
+	if postCtx == nil {
+		return nil
+	}
+	machine := environs.BootstrapMachine{
+		InstanceId:   inst.Id(),
+		Address:      addr.Value,
+		AddressScope: addr.Scope,
+		Hardware:     machineConfig.HardwareCharacteristics,
+	}
+	if machineConfig.Tools != nil {
+		machine.Tools = machineConfig.Tools.Version
+	}
+	if status != nil {
+		machine.CloudInitStatus = status.String()
+	}
+	err := postCtx.PostBootstrap(machine)
+	if err == nil {
+		return nil
+	}
+	if postCtx.StrictPostBootstrap() {
+		return fmt.Errorf("post-bootstrap hook failed: %v", err)
+	}
+	logger.Errorf("post-bootstrap hook failed: %v", err)
+	return nil
 }
 
+// runConfigureScript is called to run the rendered configuration
+// script on the bootstrap machine.
+var runConfigureScript = sshinit.RunConfigureScript
+
 func ConfigureMachine(ctx environs.BootstrapContext, client ssh.Client, host string, machineConfig *cloudinit.MachineConfig) error {
 	// Bootstrap is synchronous, and will spawn a subprocess
 	// to complete the procedure. If the user hits Ctrl-C,
@@ -132,8 +716,27 @@ func ConfigureMachine(ctx environs.BootstrapContext, client ssh.Client, host str
 	// point. For that reason, we do not call StopInterruptNotify
 	// until this function completes.
 	cloudcfg := coreCloudinit.New()
+	// Nothing may be done until cloud-init has finished with the machine.
+	if err := addCloudInitWaitCommands(cloudcfg, machineConfig.CloudInitVersion); err != nil {
+		return err
+	}
 	cloudcfg.SetAptUpdate(machineConfig.EnableOSRefreshUpdate)
 	cloudcfg.SetAptUpgrade(machineConfig.EnableOSUpgrade)
+	// The mirrors must be in place before any packages are updated.
+	cloudcfg.SetAptMirror(machineConfig.AptMirror)
+	cloudinit.AddAptSecurityMirrorCommands(cloudcfg, machineConfig.AptSecurityMirror)
+	if machineConfig.Hostname != "" {
+		cloudcfg.SetHostname(machineConfig.Hostname)
+	}
+	if err := cloudinit.AddHostEntriesCommands(cloudcfg, machineConfig.HostEntries); err != nil {
+		return err
+	}
+	if err := cloudinit.AddDiskLayoutCommands(cloudcfg, machineConfig.DiskLayouts); err != nil {
+		return err
+	}
+	if err := cloudinit.AddEgressFirewallCommands(cloudcfg, machineConfig.EgressRules); err != nil {
+		return err
+	}
 
 	udata, err := cloudinit.NewUserdataConfig(machineConfig, cloudcfg)
 	if err != nil {
@@ -142,17 +745,42 @@ func ConfigureMachine(ctx environs.BootstrapContext, client ssh.Client, host str
 	if err := udata.ConfigureJuju(); err != nil {
 		return err
 	}
+	// Site-specific directives are added last, so that they can
+	// augment Juju's but not be overridden by them.
+	if machineConfig.ExtraCloudConfig != nil {
+		if err := machineConfig.ExtraCloudConfig(cloudcfg); err != nil {
+			return fmt.Errorf("cannot add extra cloud-init configuration: %v", err)
+		}
+	}
 	configScript, err := sshinit.ConfigureScript(cloudcfg)
 	if err != nil {
 		return err
 	}
 	script := shell.DumpFileOnErrorScript(machineConfig.CloudInitOutputLog) + configScript
-	return sshinit.RunConfigureScript(script, sshinit.ConfigureParams{
-		Host:           "ubuntu@" + host,
+	params := sshinit.ConfigureParams{
+		Host:           bootstrapSSHUser(machineConfig) + "@" + host,
 		Client:         client,
 		Config:         cloudcfg,
 		ProgressWriter: ctx.GetStderr(),
-	})
+	}
+	// The configuration is abandoned if the bootstrap deadline passes
+	// or the bootstrap is cancelled while it runs.
+	cancelled := bootstrapCancelled(ctx)
+	cancel, stop := anyClosed(bootstrapExpired(ctx), cancelled)
+	defer stop()
+	params.Cancel = cancel
+	if machineConfig.Config.BootstrapStreamLog() {
+		params.TailLog = machineConfig.CloudInitOutputLog
+	}
+	err = runConfigureScript(script, params)
+	if err == sshinit.ErrConfigureCancelled {
+		select {
+		case <-cancelled:
+			return environs.ErrBootstrapCancelled
+		default:
+		}
+	}
+	return err
 }
 
 type addresser interface {
@@ -166,14 +794,19 @@ type addresser interface {
 }
 
 type hostChecker struct {
-	addr   network.Address
-	client ssh.Client
-	wg     *sync.WaitGroup
+	addr  network.Address
+	conn  bootstrapConnector
+	clock clock
+	wg    *sync.WaitGroup
 
 	// checkDelay is the amount of time to wait between retries.
 	checkDelay time.Duration
 
-	// checkHostScript is executed on the host via SSH.
+	// jitter, if true, randomizes each wait between retries; see
+	// jitteredDelay.
+	jitter bool
+
+	// checkHostScript is executed on the host by conn.
 	// hostChecker.loop will return once the script
 	// runs without error.
 	checkHostScript string
@@ -182,6 +815,48 @@ type hostChecker struct {
 	// return, without waiting for the result of any ongoing
 	// attempts.
 	closed <-chan struct{}
+
+	// reachable is sent the address once the script has run
+	// without error.
+	reachable chan<- network.Address
+
+	// yield, if not nil, is sent the address after each failed
+	// attempt, and the checker returns, so that the address's turn
+	// to be dialed passes to the next address waiting for one.
+	yield chan<- network.Address
+
+	// attempts counts the failed attempts made on the address,
+	// including those made by earlier checkers of the same address.
+	attempts *int
+
+	// attempted, if not nil, is called after each failed attempt.
+	attempted connectAttemptFunc
+
+	// errors records the error from each failed attempt.
+	errors *addressErrors
+}
+
+// connectAttemptFunc is called after each failed attempt to connect to
+// addr, with the number of attempts made on it so far and the error
+// from the latest. It may be called from several goroutines at once.
+type connectAttemptFunc func(addr network.Address, attempt int, err error)
+
+// retryDelay returns how long to wait before the next attempt.
+func (hc *hostChecker) retryDelay() time.Duration {
+	if hc.jitter {
+		return jitteredDelay(hc.checkDelay)
+	}
+	return hc.checkDelay
+}
+
+// jitteredDelay returns a random duration from half to one and a half
+// times delay, so that checkers started together spread their retries
+// out rather than retrying in bursts.
+func jitteredDelay(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)+1))
 }
 
 // Close implements io.Closer, as required by parallel.Try.
@@ -191,14 +866,24 @@ func (*hostChecker) Close() error {
 
 func (hc *hostChecker) loop(dying <-chan struct{}) (io.Closer, error) {
 	defer hc.wg.Done()
-	// The value of connectSSH is taken outside the goroutine that may outlive
-	// hostChecker.loop, or we evoke the wrath of the race detector.
-	connectSSH := connectSSH
+	// The value of lookupHost is taken outside the goroutine that
+	// may outlive hostChecker.loop, or we evoke the wrath of the
+	// race detector.
+	lookupHost := lookupHost
 	done := make(chan error, 1)
 	var lastErr error
 	for {
 		go func() {
-			done <- connectSSH(hc.client, hc.addr.Value, hc.checkHostScript)
+			// Hostnames are resolved afresh on each attempt, as
+			// the name may not become resolvable until some time
+			// after the instance has been launched.
+			if hc.addr.Type == network.HostName {
+				if _, err := lookupHost(hc.addr.Value); err != nil {
+					done <- fmt.Errorf("cannot resolve %q: %v", hc.addr.Value, err)
+					return
+				}
+			}
+			done <- hc.conn.Run(hc.addr.Value, hc.checkHostScript)
 		}()
 		select {
 		case <-hc.closed:
@@ -207,20 +892,51 @@ func (hc *hostChecker) loop(dying <-chan struct{}) (io.Closer, error) {
 			return hc, lastErr
 		case lastErr = <-done:
 			if lastErr == nil {
+				// Report the address, but don't return until
+				// told to; a successful return would stop the
+				// other checkers, and more than one address
+				// may be required.
+				select {
+				case hc.reachable <- hc.addr:
+				case <-hc.closed:
+					return hc, nil
+				case <-dying:
+					return hc, nil
+				}
+				select {
+				case <-hc.closed:
+				case <-dying:
+				}
 				return hc, nil
 			}
+			*hc.attempts++
+			hc.errors.record(hc.addr, lastErr)
+			if hc.attempted != nil {
+				hc.attempted(hc.addr, *hc.attempts, lastErr)
+			}
 		}
 		select {
 		case <-hc.closed:
+			return hc, lastErr
 		case <-dying:
-		case <-time.After(hc.checkDelay):
+			return hc, lastErr
+		case <-hc.clock.After(hc.retryDelay()):
+		}
+		if hc.yield != nil {
+			select {
+			case hc.yield <- hc.addr:
+			case <-hc.closed:
+			case <-dying:
+			}
+			return hc, lastErr
 		}
 	}
 }
 
 type parallelHostChecker struct {
 	*parallel.Try
-	client ssh.Client
+	conn   bootstrapConnector
+	clock  clock
 	stderr io.Writer
 	wg     sync.WaitGroup
 
@@ -230,33 +946,132 @@ type parallelHostChecker struct {
 	// is killed, or the corresponding channel in this map is closed.
 	active map[network.Address]chan struct{}
 
-	// checkDelay is how long each hostChecker waits between attempts.
+	// checkDelay is how long each hostChecker waits between attempts,
+	// and jitter whether that wait is randomized.
 	checkDelay time.Duration
+	jitter     bool
 
 	// checkHostScript is the script to run on each host to check that
 	// it is the host we expect.
 	checkHostScript string
+
+	// reachable receives each address once it has been verified.
+	reachable chan network.Address
+
+	// maxDialing, if positive, is the most addresses that are
+	// dialed at once. Addresses beyond that are queued.
+	//
+	// The Try itself is not bounded, as its Start blocks while it
+	// is full, which would hold up the caller's timeout.
+	maxDialing int
+
+	// dialing is the number of active addresses not yet verified.
+	dialing int
+
+	// queued holds the addresses waiting for their turn to be
+	// dialed, in order.
+	queued []network.Address
+
+	// yielded receives each address whose checker has given up its
+	// turn after a failed attempt, when dialing is bounded.
+	yielded chan network.Address
+
+	// attempts holds the number of failed attempts made on each
+	// address. Only the address's active checker updates its count.
+	attempts map[network.Address]*int
+
+	// attempted, if not nil, is passed to each hostChecker.
+	attempted connectAttemptFunc
+
+	// errors records the latest error from each address.
+	errors addressErrors
 }
 
+// UpdateAddresses starts checking each of the given addresses not
+// already known, or queues it if too many are being dialed.
 func (p *parallelHostChecker) UpdateAddresses(addrs []network.Address) {
 	for _, addr := range addrs {
-		if _, ok := p.active[addr]; ok {
+		if p.known(addr) {
 			continue
 		}
-		fmt.Fprintf(p.stderr, "Attempting to connect to %s:22\n", addr.Value)
-		closed := make(chan struct{})
-		hc := &hostChecker{
-			addr:            addr,
-			client:          p.client,
-			checkDelay:      p.checkDelay,
-			checkHostScript: p.checkHostScript,
-			closed:          closed,
-			wg:              &p.wg,
+		p.queued = append(p.queued, addr)
+	}
+	p.startQueued()
+}
+
+// known reports whether addr is being checked or is queued.
+func (p *parallelHostChecker) known(addr network.Address) bool {
+	if _, ok := p.active[addr]; ok {
+		return true
+	}
+	for _, queued := range p.queued {
+		if queued == addr {
+			return true
 		}
-		p.wg.Add(1)
-		p.active[addr] = closed
-		p.Start(hc.loop)
 	}
+	return false
+}
+
+// empty reports whether no address has been checked or queued.
+func (p *parallelHostChecker) empty() bool {
+	return len(p.active) == 0 && len(p.queued) == 0
+}
+
+// startQueued starts checking queued addresses, in order, while
+// fewer than maxDialing are being dialed.
+func (p *parallelHostChecker) startQueued() {
+	for len(p.queued) > 0 && (p.maxDialing <= 0 || p.dialing < p.maxDialing) {
+		addr := p.queued[0]
+		p.queued = p.queued[1:]
+		p.start(addr)
+	}
+}
+
+func (p *parallelHostChecker) start(addr network.Address) {
+	fmt.Fprintf(p.stderr, "Attempting to connect to %s\n", net.JoinHostPort(addr.Value, strconv.Itoa(p.conn.Port())))
+	closed := make(chan struct{})
+	hc := &hostChecker{
+		addr:            addr,
+		conn:            p.conn,
+		clock:           p.clock,
+		checkDelay:      p.checkDelay,
+		jitter:          p.jitter,
+		checkHostScript: p.checkHostScript,
+		closed:          closed,
+		reachable:       p.reachable,
+		wg:              &p.wg,
+		attempts:        p.attempts[addr],
+		attempted:       p.attempted,
+		errors:          &p.errors,
+	}
+	if hc.attempts == nil {
+		hc.attempts = new(int)
+		p.attempts[addr] = hc.attempts
+	}
+	if p.maxDialing > 0 {
+		hc.yield = p.yielded
+	}
+	p.wg.Add(1)
+	p.active[addr] = closed
+	p.dialing++
+	p.Start(hc.loop)
+}
+
+// Reached records that an address being dialed has been verified,
+// so that another address may take its turn.
+func (p *parallelHostChecker) Reached() {
+	p.dialing--
+	p.startQueued()
+}
+
+// Yielded records that the checker for addr has given up its turn
+// after a failed attempt; addr is queued to be dialed again after
+// any addresses already waiting.
+func (p *parallelHostChecker) Yielded(addr network.Address) {
+	delete(p.active, addr)
+	p.dialing--
+	p.queued = append(p.queued, addr)
+	p.startQueued()
 }
 
 // Close prevents additional functions from being added to
@@ -273,10 +1088,105 @@ func (p *parallelHostChecker) Close() error {
 	return nil
 }
 
-// connectSSH is called to connect to the specified host and
-// execute the "checkHostScript" bash script on it.
-var connectSSH = func(client ssh.Client, host, checkHostScript string) error {
-	cmd := client.Command("ubuntu@"+host, []string{"/bin/bash"}, nil)
+// addressErrors records the error from the latest failed attempt on
+// each address, so that the failures can be summarized if no address
+// can be reached.
+type addressErrors struct {
+	mu   sync.Mutex
+	errs map[network.Address]error
+}
+
+// record records err as the latest error from addr.
+func (e *addressErrors) record(addr network.Address, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.errs == nil {
+		e.errs = make(map[network.Address]error)
+	}
+	e.errs[addr] = err
+}
+
+// summary returns the recorded errors, grouping the addresses that
+// failed in the same way, most common first; for example "3
+// addresses: Connection refused; 1 address: nonce file does not
+// exist". If only one address has failed, its error is returned as
+// is. If none has, summary returns "".
+func (e *addressErrors) summary() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) == 1 {
+		for _, err := range e.errs {
+			return err.Error()
+		}
+	}
+	counts := make(errorCounts)
+	for addr, err := range e.errs {
+		counts[withoutAddress(addr.Value, err)]++
+	}
+	messages := make([]string, 0, len(counts))
+	for message := range counts {
+		messages = append(messages, message)
+	}
+	sort.Sort(byCount{messages, counts})
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		noun := "addresses"
+		if counts[message] == 1 {
+			noun = "address"
+		}
+		parts[i] = fmt.Sprintf("%d %s: %s", counts[message], noun, message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withoutAddress returns the message of err, which was returned when
+// connecting to addr, without the address, so that the addresses that
+// failed in the same way may be grouped. A message that gives the
+// address before the reason for the failure, as in "ssh: connect to
+// host 10.0.0.5 port 22: Connection refused", is reduced to the
+// reason.
+func withoutAddress(addr string, err error) string {
+	message := err.Error()
+	i := strings.Index(message, addr)
+	if i < 0 {
+		return message
+	}
+	rest := message[i+len(addr):]
+	if j := strings.Index(rest, ": "); j >= 0 {
+		return rest[j+len(": "):]
+	}
+	return strings.Replace(message, addr, "<address>", -1)
+}
+
+// errorCounts holds the number of addresses that failed with each
+// error message.
+type errorCounts map[string]int
+
+// byCount sorts error messages by the number of addresses that failed
+// with them, most first, and then by message.
+type byCount struct {
+	messages []string
+	counts   errorCounts
+}
+
+func (s byCount) Len() int      { return len(s.messages) }
+func (s byCount) Swap(i, j int) { s.messages[i], s.messages[j] = s.messages[j], s.messages[i] }
+func (s byCount) Less(i, j int) bool {
+	ci, cj := s.counts[s.messages[i]], s.counts[s.messages[j]]
+	if ci != cj {
+		return ci > cj
+	}
+	return s.messages[i] < s.messages[j]
+}
+
+// lookupHost is called to resolve hostname addresses before
+// attempting to connect to them.
+var lookupHost = net.LookupHost
+
+// connectSSH is called to connect to the specified host as the
+// specified user and execute the "checkHostScript" bash script on it.
+var connectSSH = func(client ssh.Client, user, host, checkHostScript string) error {
+	cmd := client.Command(user+"@"+host, []string{"/bin/bash"}, nil)
 	cmd.Stdin = strings.NewReader(checkHostScript)
 	output, err := cmd.CombinedOutput()
 	if err != nil && len(output) > 0 {
@@ -286,43 +1196,76 @@ var connectSSH = func(client ssh.Client, host, checkHostScript string) error {
 }
 
 // waitSSH waits for the instance to be assigned a routable
-// address, then waits until we can connect to it via SSH.
+// address, then waits until we can connect to it using conn, via SSH
+// or WinRM. The address connected to is returned with its scope, so
+// that callers can tell how the machine was reached.
 //
 // waitSSH attempts on all addresses returned by the instance
-// in parallel; the first succeeding one wins. We ensure that
-// private addresses are for the correct machine by checking
-// the presence of a file on the machine that contains the
-// machine's nonce. The "checkHostScript" is a bash script
-// that performs this file check.
-func waitSSH(ctx environs.BootstrapContext, interrupted <-chan os.Signal, client ssh.Client, checkHostScript string, inst addresser, timeout config.SSHTimeoutOpts) (addr string, err error) {
-	globalTimeout := time.After(timeout.Timeout)
-	pollAddresses := time.NewTimer(0)
+// in parallel. By default the first succeeding one wins; if
+// timeout.MinAddresses is greater than one, that many distinct
+// addresses must succeed before the first of them is chosen,
+// unless an address in timeout.PreferredCIDR succeeds, in which
+// case it is chosen immediately (see chooseAddress). We ensure that private addresses
+// are for the correct machine by checking the presence of a file
+// on the machine that contains the machine's nonce. The
+// "checkHostScript" is a script, run by conn, that performs this file
+// check. If attempted is not nil, it is called after each failed
+// attempt on an address, so that progress can be reported while
+// waitSSH continues to retry. waitSSH gives up if interrupted, or if
+// ctx implements environs.CancelBootstrapContext and is cancelled, in
+// which case environs.ErrBootstrapCancelled is returned.
+func waitSSH(ctx environs.BootstrapContext, interrupted <-chan os.Signal, conn bootstrapConnector, checkHostScript string, inst addresser, timeout config.SSHTimeoutOpts, attempted connectAttemptFunc) (addr network.Address, err error) {
+	var preferred *net.IPNet
+	if timeout.PreferredCIDR != "" {
+		_, preferred, err = net.ParseCIDR(timeout.PreferredCIDR)
+		if err != nil {
+			return network.Address{}, fmt.Errorf("invalid preferred CIDR: %v", err)
+		}
+	}
+	clock := bootstrapClock
+	globalTimeout := clock.After(timeout.Timeout)
+	pollAddresses := clock.After(0)
+	cancelled := bootstrapCancelled(ctx)
 
 	// checker checks each address in a loop, in parallel,
-	// until one succeeds, the global timeout is reached,
+	// until enough succeed, the global timeout is reached,
 	// or the tomb is killed.
 	checker := parallelHostChecker{
 		Try:             parallel.NewTry(0, nil),
-		client:          client,
+		conn:            conn,
+		clock:           clock,
 		stderr:          ctx.GetStderr(),
 		active:          make(map[network.Address]chan struct{}),
 		checkDelay:      timeout.RetryDelay,
+		jitter:          timeout.RetryJitter,
 		checkHostScript: checkHostScript,
+		reachable:       make(chan network.Address),
+		maxDialing:      timeout.MaxConcurrentDials,
+		yielded:         make(chan network.Address),
+		attempts:        make(map[network.Address]*int),
+		attempted:       attempted,
 	}
 	defer checker.wg.Wait()
 	defer checker.Kill()
 
+	var reachable []network.Address
 	fmt.Fprintln(ctx.GetStderr(), "Waiting for address")
 	for {
 		select {
-		case <-pollAddresses.C:
-			pollAddresses.Reset(timeout.AddressesDelay)
+		case <-pollAddresses:
+			pollAddresses = clock.After(timeout.AddressesDelay)
 			if err := inst.Refresh(); err != nil {
-				return "", fmt.Errorf("refreshing addresses: %v", err)
+				return network.Address{}, fmt.Errorf("refreshing addresses: %v", err)
 			}
 			addresses, err := inst.Addresses()
 			if err != nil {
-				return "", fmt.Errorf("getting addresses: %v", err)
+				return network.Address{}, fmt.Errorf("getting addresses: %v", err)
+			}
+			if len(addresses) > 0 && checker.empty() {
+				reportProgress(ctx, environs.BootstrapEvent{
+					Kind:    environs.BootstrapAddressFound,
+					Address: addresses[0].Value,
+				})
 			}
 			checker.UpdateAddresses(addresses)
 		case <-globalTimeout:
@@ -330,24 +1273,69 @@ func waitSSH(ctx environs.BootstrapContext, interrupted <-chan os.Signal, client
 			lastErr := checker.Wait()
 			format := "waited for %v "
 			args := []interface{}{timeout.Timeout}
-			if len(checker.active) == 0 {
+			switch {
+			case checker.empty():
 				format += "without getting any addresses"
-			} else {
+			case len(reachable) == 0:
 				format += "without being able to connect"
+			case len(reachable) < timeout.MinAddresses:
+				format += "with only %d of %d required addresses reachable"
+				args = append(args, len(reachable), timeout.MinAddresses)
+			default:
+				format += "without being able to connect to an address in %s"
+				args = append(args, timeout.PreferredCIDR)
 			}
 			if lastErr != nil && lastErr != parallel.ErrStopped {
 				format += ": %v"
-				args = append(args, lastErr)
+				if summary := checker.errors.summary(); summary != "" {
+					args = append(args, summary)
+				} else {
+					args = append(args, lastErr)
+				}
 			}
-			return "", fmt.Errorf(format, args...)
+			return network.Address{}, fmt.Errorf(format, args...)
 		case <-interrupted:
-			return "", fmt.Errorf("interrupted")
+			return network.Address{}, fmt.Errorf("interrupted")
+		case <-cancelled:
+			return network.Address{}, environs.ErrBootstrapCancelled
+		case addr := <-checker.reachable:
+			checker.Reached()
+			reachable = append(reachable, addr)
+			if addr, ok := chooseAddress(reachable, timeout.MinAddresses, preferred); ok {
+				return addr, nil
+			}
+		case addr := <-checker.yielded:
+			checker.Yielded(addr)
 		case <-checker.Dead():
 			result, err := checker.Result()
 			if err != nil {
-				return "", err
+				return network.Address{}, err
+			}
+			return result.(*hostChecker).addr, nil
+		}
+	}
+}
+
+// chooseAddress returns the address to use from those that have been
+// found to be reachable, in the order they were found, if the
+// requirements are satisfied. An address in the preferred network is
+// chosen as soon as it is reachable; otherwise the first reachable
+// address is chosen once at least minAddresses are reachable. If a
+// preferred network is given but minAddresses is not, only an address
+// in the preferred network will do.
+func chooseAddress(reachable []network.Address, minAddresses int, preferred *net.IPNet) (network.Address, bool) {
+	if preferred != nil {
+		for _, addr := range reachable {
+			if ip := net.ParseIP(addr.Value); ip != nil && preferred.Contains(ip) {
+				return addr, true
 			}
-			return result.(*hostChecker).addr.Value, nil
 		}
+		if minAddresses == 0 {
+			return network.Address{}, false
+		}
+	}
+	if len(reachable) == 0 || len(reachable) < minAddresses {
+		return network.Address{}, false
 	}
+	return reachable[0], true
 }
@@ -5,12 +5,23 @@ package common_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/proxy"
+	"github.com/juju/utils/shell"
 	gc "gopkg.in/check.v1"
 
+	coreCloudinit "github.com/juju/juju/cloudinit"
+	"github.com/juju/juju/cloudinit/sshinit"
 	"github.com/juju/juju/constraints"
 	"github.com/juju/juju/environs"
 	"github.com/juju/juju/environs/cloudinit"
@@ -18,6 +29,7 @@ import (
 	"github.com/juju/juju/environs/storage"
 	envtesting "github.com/juju/juju/environs/testing"
 	"github.com/juju/juju/instance"
+	"github.com/juju/juju/juju/osenv"
 	"github.com/juju/juju/network"
 	"github.com/juju/juju/provider/common"
 	coretesting "github.com/juju/juju/testing"
@@ -40,9 +52,10 @@ type cleaner interface {
 func (s *BootstrapSuite) SetUpTest(c *gc.C) {
 	s.FakeJujuHomeSuite.SetUpTest(c)
 	s.ToolsFixture.SetUpTest(c)
-	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, host, checkHostScript string) error {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
 		return fmt.Errorf("mock connection failure to %s", host)
 	})
+	s.patchCloudInitStatus("status: done\n")
 }
 
 func (s *BootstrapSuite) TearDownTest(c *gc.C) {
@@ -108,7 +121,7 @@ func (s *BootstrapSuite) TestCannotStartInstance(c *gc.C) {
 	env.startInstance = startInstance
 
 	ctx := coretesting.Context(c)
-	_, _, _, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+	_, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
 		Constraints:    checkCons,
 		Placement:      checkPlacement,
 		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
@@ -146,12 +159,720 @@ func (s *BootstrapSuite) TestSuccess(c *gc.C) {
 		setConfig:     setConfig,
 	}
 	ctx := coretesting.Context(c)
-	arch, series, _, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+	vers := version.Current
+	vers.Arch = "ppc64el"
+	result, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: vers}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Arch, gc.Equals, "ppc64el") // based on hardware characteristics
+	c.Assert(result.Series, gc.Equals, config.PreferredSeries(mocksConfig))
+	c.Assert(result.InstanceId, gc.Equals, instance.Id(checkInstanceId))
+	c.Assert(result.Hardware, gc.DeepEquals, &checkHardware)
+	c.Assert(result.Finalizer, gc.NotNil)
+
+	arch, series, finalize, err := common.BootstrapValues(result, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(arch, gc.Equals, "ppc64el")
+	c.Assert(series, gc.Equals, result.Series)
+	c.Assert(finalize, gc.NotNil)
+}
+
+func (s *BootstrapSuite) newZonedEnviron(c *gc.C, placement *string) *mockZonedEnviron {
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+	return &mockZonedEnviron{
+		mockEnviron: mockEnviron{
+			storage: newStorage(s, c),
+			config:  configGetter(c),
+			startInstance: func(
+				p string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+			) (
+				instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+			) {
+				*placement = p
+				return &mockInstance{id: "i-zoned"}, &hw, nil, nil
+			},
+		},
+		availabilityZones: func() ([]common.AvailabilityZone, error) {
+			return []common.AvailabilityZone{
+				&mockAvailabilityZone{name: "zone-b", available: true},
+				&mockAvailabilityZone{name: "zone-a", available: true},
+				&mockAvailabilityZone{name: "zone-down", available: false},
+			}, nil
+		},
+		instanceAvailabilityZoneNames: func(ids []instance.Id) ([]string, error) {
+			c.Assert(ids, gc.DeepEquals, []instance.Id{"i-zoned"})
+			return []string{"zone-b"}, nil
+		},
+	}
+}
+
+func (s *BootstrapSuite) TestBootstrapAvailabilityZone(c *gc.C) {
+	var placement string
+	env := s.newZonedEnviron(c, &placement)
+	result, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailabilityZone: "zone-b",
+		AvailableTools:   tools.List{&tools.Tools{Version: version.Current}},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(placement, gc.Equals, "zone=zone-b")
+	c.Assert(result.AvailabilityZone, gc.Equals, "zone-b")
+}
+
+func (s *BootstrapSuite) TestBootstrapInvalidAvailabilityZone(c *gc.C) {
+	var placement string
+	env := s.newZonedEnviron(c, &placement)
+	for _, zone := range []string{"zone-c", "zone-down"} {
+		_, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+			AvailabilityZone: zone,
+			AvailableTools:   tools.List{&tools.Tools{Version: version.Current}},
+		})
+		c.Check(err, gc.ErrorMatches, `invalid availability zone "`+zone+`": valid zones are zone-a, zone-b`)
+	}
+	c.Assert(placement, gc.Equals, "")
+}
+
+func (s *BootstrapSuite) TestBootstrapAvailabilityZoneWithPlacement(c *gc.C) {
+	var placement string
+	env := s.newZonedEnviron(c, &placement)
+	_, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		Placement:        "zone=zone-a",
+		AvailabilityZone: "zone-b",
+		AvailableTools:   tools.List{&tools.Tools{Version: version.Current}},
+	})
+	c.Assert(err, gc.ErrorMatches, "cannot specify both a placement directive and an availability zone")
+}
+
+func (s *BootstrapSuite) TestBootstrapAvailabilityZoneNotSupported(c *gc.C) {
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+	}
+	_, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailabilityZone: "zone-a",
+		AvailableTools:   tools.List{&tools.Tools{Version: version.Current}},
+	})
+	c.Assert(err, gc.ErrorMatches, "availability zones not supported")
+}
+
+func (s *BootstrapSuite) TestBootstrapValuesError(c *gc.C) {
+	arch, series, finalize, err := common.BootstrapValues(nil, fmt.Errorf("no tools"))
+	c.Assert(err, gc.ErrorMatches, "no tools")
+	c.Assert(arch, gc.Equals, "")
+	c.Assert(series, gc.Equals, "")
+	c.Assert(finalize, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestTimeoutStartingInstance(c *gc.C) {
+	started := make(chan struct{})
+	stopped := make(chan []instance.Id, 1)
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			<-started
+			return &mockInstance{id: "i-late"}, nil, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			stopped <- ids
+			return nil
+		},
+	}
+	_, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+		Timeout:        coretesting.ShortWait,
+	})
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within .*: deadline passed while starting the bootstrap instance")
+
+	// The instance is stopped once it has started.
+	close(started)
+	select {
+	case ids := <-stopped:
+		c.Assert(ids, gc.DeepEquals, []instance.Id{"i-late"})
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("bootstrap instance not stopped")
+	}
+}
+
+func (s *BootstrapSuite) TestCancelStartingInstance(c *gc.C) {
+	started := make(chan struct{})
+	stopped := make(chan []instance.Id, 1)
+	cancel := make(chan struct{})
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			close(cancel)
+			<-started
+			return &mockInstance{id: "i-late"}, nil, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			stopped <- ids
+			return nil
+		},
+	}
+	ctx := environs.WithCancel(coretesting.Context(c), cancel)
+	_, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+	})
+	c.Assert(err, gc.Equals, environs.ErrBootstrapCancelled)
+
+	// The instance is stopped once it has started.
+	close(started)
+	select {
+	case ids := <-stopped:
+		c.Assert(ids, gc.DeepEquals, []instance.Id{"i-late"})
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("bootstrap instance not stopped")
+	}
+}
+
+func (s *BootstrapSuite) TestTimeoutConfiguringMachine(c *gc.C) {
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{"admin-secret": "sekrit"})
+	c.Assert(err, gc.IsNil)
+	var stopped []instance.Id
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  func() *config.Config { return cfg },
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			inst := &refreshingInstance{
+				mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+			}
+			return inst, &hw, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			stopped = append(stopped, ids...)
+			return nil
+		},
+	}
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	// The configuration script stalls until it is cancelled.
+	s.PatchValue(common.RunConfigureScript, func(_ string, params sshinit.ConfigureParams) error {
+		c.Check(params.Cancel, gc.NotNil)
+		<-params.Cancel
+		return sshinit.ErrConfigureCancelled
+	})
+
+	ctx := coretesting.Context(c)
+	// Allow long enough for the machine to be reached before the
+	// deadline passes.
+	result, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+		Timeout:        time.Second,
+	})
+	c.Assert(err, gc.IsNil)
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	err = result.Finalizer(ctx, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within .*: deadline passed while configuring machine")
+	c.Assert(stopped, gc.DeepEquals, []instance.Id{"i-bootstrap"})
+}
+
+func (s *BootstrapSuite) TestTimeoutSkipsPostBootstrap(c *gc.C) {
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{"admin-secret": "sekrit"})
+	c.Assert(err, gc.IsNil)
+	var stopped []instance.Id
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  func() *config.Config { return cfg },
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			inst := &refreshingInstance{
+				mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+			}
+			return inst, &hw, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			stopped = append(stopped, ids...)
+			return nil
+		},
+	}
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.patchCloudInitStatus("")
+	// The configuration script completes only as the deadline passes.
+	s.PatchValue(common.RunConfigureScript, func(_ string, params sshinit.ConfigureParams) error {
+		<-params.Cancel
+		return nil
+	})
+
+	ctx := environs.WithPostBootstrapHook(coretesting.Context(c), func(environs.BootstrapMachine) error {
+		c.Errorf("post-bootstrap hook run after the deadline passed")
+		return nil
+	})
+	result, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+		Timeout:        time.Second,
+	})
+	c.Assert(err, gc.IsNil)
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	err = result.Finalizer(ctx, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within .*: deadline passed while configuring machine")
+	c.Assert(stopped, gc.DeepEquals, []instance.Id{"i-bootstrap"})
+}
+
+func (s *BootstrapSuite) TestBootstrapArchMismatch(c *gc.C) {
+	hw := instance.MustParseHardware("arch=arm64")
+	var stopped []instance.Id
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			return &mockInstance{id: "i-bootstrap"}, &hw, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			stopped = append(stopped, ids...)
+			return nil
+		},
+	}
+	vers := version.Current
+	vers.Arch = "amd64"
+	availableTools := tools.List{&tools.Tools{Version: vers}}
+	vers.Arch = "i386"
+	availableTools = append(availableTools, &tools.Tools{Version: vers})
+
+	_, err := common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailableTools: availableTools,
+	})
+	c.Assert(err, gc.ErrorMatches, "bootstrap instance i-bootstrap has architecture arm64, but tools are only available for amd64, i386")
+	c.Assert(stopped, gc.DeepEquals, []instance.Id{"i-bootstrap"})
+}
+
+func (s *BootstrapSuite) TestBootstrapWindowsNeedsPassword(c *gc.C) {
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{"default-series": "win2012r2"})
+	c.Assert(err, gc.IsNil)
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  func() *config.Config { return cfg },
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			c.Fatalf("bootstrap instance started without a WinRM password")
+			return nil, nil, nil, nil
+		},
+	}
+	_, err = common.Bootstrap(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.MustParseBinary("1.2.3-win2012r2-amd64")}},
+	})
+	c.Assert(err, gc.ErrorMatches, "cannot bootstrap win2012r2: bootstrap-winrm-password is not set")
+}
+
+func (s *BootstrapSuite) TestBootstrapOSUpdateOverrides(c *gc.C) {
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{
+		"admin-secret":             "sekrit",
+		"enable-os-refresh-update": true,
+		"enable-os-upgrade":        true,
+	})
+	c.Assert(err, gc.IsNil)
+	var started *cloudinit.MachineConfig
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  func() *config.Config { return cfg },
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, mcfg *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			started = mcfg
+			inst := &refreshingInstance{
+				mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+			}
+			return inst, &hw, nil, nil
+		},
+	}
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.patchCloudInitStatus("")
+	var script string
+	s.PatchValue(common.RunConfigureScript, func(rendered string, _ sshinit.ConfigureParams) error {
+		script = rendered
+		return nil
+	})
+
+	// Packages are refreshed, but not upgraded, on the bootstrap
+	// instance only.
+	refresh, upgrade := true, false
+	ctx := coretesting.Context(c)
+	result, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools:  tools.List{&tools.Tools{Version: version.Current}},
+		OSRefreshUpdate: &refresh,
+		OSUpgrade:       &upgrade,
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(started.EnableOSRefreshUpdate, jc.IsTrue)
+	c.Assert(started.EnableOSUpgrade, jc.IsFalse)
+
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	err = result.Finalizer(ctx, machineConfig)
+	c.Assert(err, gc.IsNil)
+	c.Assert(machineConfig.EnableOSRefreshUpdate, jc.IsTrue)
+	c.Assert(machineConfig.EnableOSUpgrade, jc.IsFalse)
+	c.Assert(strings.Contains(script, "Running apt-get update"), jc.IsTrue)
+	c.Assert(strings.Contains(script, "Running apt-get upgrade"), jc.IsFalse)
+}
+
+func (s *BootstrapSuite) TestBootstrapSSHClientReusesKey(c *gc.C) {
+	ssh.ClearClientKeys()
+	s.AddCleanup(func(*gc.C) { ssh.ClearClientKeys() })
+	_, err := common.BootstrapSSHClient()
+	c.Assert(err, gc.IsNil)
+	keyFiles := ssh.PublicKeyFiles()
+	c.Assert(keyFiles, gc.HasLen, 1)
+	c.Assert(filepath.Dir(keyFiles[0]), gc.Equals, osenv.JujuHomePath("ssh"))
+	key, err := ioutil.ReadFile(keyFiles[0])
+	c.Assert(err, gc.IsNil)
+
+	// A later attempt, as by a new process, uses the same key.
+	ssh.ClearClientKeys()
+	_, err = common.BootstrapSSHClient()
+	c.Assert(err, gc.IsNil)
+	c.Assert(ssh.PublicKeyFiles(), gc.DeepEquals, keyFiles)
+	reused, err := ioutil.ReadFile(keyFiles[0])
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(reused), gc.Equals, string(key))
+}
+
+// preallocatedEnviron returns an environ that must not be asked to
+// start or stop instances.
+func (s *BootstrapSuite) preallocatedEnviron(c *gc.C) *mockEnviron {
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{"admin-secret": "sekrit"})
+	c.Assert(err, gc.IsNil)
+	return &mockEnviron{
+		storage: newStorage(s, c),
+		config:  func() *config.Config { return cfg },
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			c.Fatalf("instance started")
+			return nil, nil, nil, nil
+		},
+		stopInstances: func(ids []instance.Id) error {
+			c.Fatalf("instances %v stopped", ids)
+			return nil
+		},
+	}
+}
+
+func (s *BootstrapSuite) TestBootstrapToInstance(c *gc.C) {
+	env := s.preallocatedEnviron(c)
+	var scripts []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		scripts = append(scripts, checkHostScript)
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	var configured bool
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		configured = true
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-existing", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+
+	ctx := coretesting.Context(c)
+	arch, series, finalize, err := common.BootstrapToInstance(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+	}, inst, &hw)
+	c.Assert(err, gc.IsNil)
+	c.Assert(arch, gc.Equals, version.Current.Arch)
+	c.Assert(series, gc.Equals, config.PreferredSeries(env.Config()))
+
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	err = finalize(ctx, machineConfig)
+	c.Assert(err, gc.IsNil)
+	c.Assert(machineConfig.InstanceId, gc.Equals, instance.Id("i-existing"))
+	c.Assert(machineConfig.HardwareCharacteristics, gc.Equals, &hw)
+	// The instance's nonce is checked before it is configured.
+	c.Assert(scripts, gc.Not(gc.HasLen), 0)
+	c.Assert(scripts[0], jc.Contains, "noncefile")
+	c.Assert(configured, jc.IsTrue)
+	c.Assert(coretesting.Stderr(ctx), jc.HasPrefix, "Using instance i-existing\n")
+}
+
+// verifyingEnviron is a mockEnviron that supplies its own script to
+// verify the bootstrap machine.
+type verifyingEnviron struct {
+	*mockEnviron
+}
+
+func (env *verifyingEnviron) HostVerifyScript(machineConfig *cloudinit.MachineConfig) string {
+	return "verify " + string(machineConfig.InstanceId)
+}
+
+func (s *BootstrapSuite) TestBootstrapHostVerifier(c *gc.C) {
+	env := &verifyingEnviron{s.preallocatedEnviron(c)}
+	var scripts []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		scripts = append(scripts, checkHostScript)
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-existing", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+
+	ctx := coretesting.Context(c)
+	_, _, finalize, err := common.BootstrapToInstance(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+	}, inst, &hw)
+	c.Assert(err, gc.IsNil)
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	err = finalize(ctx, machineConfig)
+	c.Assert(err, gc.IsNil)
+	// The environ's script is used in place of the nonce check.
+	c.Assert(scripts, gc.Not(gc.HasLen), 0)
+	c.Assert(scripts[0], gc.Equals, "verify i-existing")
+}
+
+func (s *BootstrapSuite) TestBootstrapToInstanceUnknownArch(c *gc.C) {
+	env := s.preallocatedEnviron(c)
+	inst := &mockInstance{id: "i-existing"}
+	params := environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+	}
+	_, _, _, err := common.BootstrapToInstance(coretesting.Context(c), env, params, inst, nil)
+	c.Assert(err, gc.ErrorMatches, "architecture of bootstrap instance i-existing not known")
+	_, _, _, err = common.BootstrapToInstance(coretesting.Context(c), env, params, inst, &instance.HardwareCharacteristics{})
+	c.Assert(err, gc.ErrorMatches, "architecture of bootstrap instance i-existing not known")
+}
+
+func (s *BootstrapSuite) TestBootstrapToInstanceNoTools(c *gc.C) {
+	env := s.preallocatedEnviron(c)
+	hw := instance.MustParseHardware("arch=ppc64el")
+	current := version.Current
+	current.Arch = "amd64"
+	_, _, _, err := common.BootstrapToInstance(coretesting.Context(c), env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: current}},
+	}, &mockInstance{id: "i-existing"}, &hw)
+	c.Assert(err, gc.Equals, tools.ErrNoMatches)
+}
+
+func (s *BootstrapSuite) TestBootstrapToInstanceTimeoutLeavesInstance(c *gc.C) {
+	env := s.preallocatedEnviron(c)
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.PatchValue(common.RunConfigureScript, func(_ string, params sshinit.ConfigureParams) error {
+		<-params.Cancel
+		return sshinit.ErrConfigureCancelled
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-existing", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	hw := instance.MustParseHardware("arch=" + version.Current.Arch)
+
+	ctx := coretesting.Context(c)
+	_, _, finalize, err := common.BootstrapToInstance(ctx, env, environs.BootstrapParams{
 		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+		Timeout:        time.Second,
+	}, inst, &hw)
+	c.Assert(err, gc.IsNil)
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	// The instance was allocated by the operator, so it is not
+	// stopped; the environ fails the test if it is.
+	err = finalize(ctx, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within .*: deadline passed while configuring machine")
+}
+
+// progressContext is a BootstrapContext that records the bootstrap
+// events it is told of.
+type progressContext struct {
+	environs.BootstrapContext
+	mu     sync.Mutex
+	events []environs.BootstrapEvent
+}
+
+func (ctx *progressContext) BootstrapProgress(event environs.BootstrapEvent) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.events = append(ctx.events, event)
+}
+
+func (ctx *progressContext) kinds() []environs.BootstrapEventKind {
+	var kinds []environs.BootstrapEventKind
+	for _, event := range ctx.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapReportsProgress(c *gc.C) {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	ctx := &progressContext{BootstrapContext: coretesting.Context(c)}
+	err := common.FinishBootstrap(ctx, ssh.DefaultClient, inst, bootstrapMachineConfig(c))
+	c.Assert(err, gc.IsNil)
+	c.Assert(ctx.kinds(), gc.DeepEquals, []environs.BootstrapEventKind{
+		environs.BootstrapAddressFound,
+		environs.BootstrapSSHConnected,
+		environs.BootstrapConfigured,
+	})
+	for _, event := range ctx.events {
+		c.Check(event.Address, gc.Equals, "0.1.2.3")
+		c.Check(event.Time.IsZero(), jc.IsFalse)
+	}
+	c.Check(ctx.events[2].InstanceId, gc.Equals, instance.Id("i-bootstrap"))
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapReportsFailedAttempts(c *gc.C) {
+	var dials int
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		if strings.Contains(checkHostScript, "noncefile") {
+			if dials++; dials <= 2 {
+				return fmt.Errorf("connection refused")
+			}
+		}
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	ctx := &progressContext{BootstrapContext: coretesting.Context(c)}
+	err := common.FinishBootstrap(ctx, ssh.DefaultClient, inst, bootstrapMachineConfig(c))
+	c.Assert(err, gc.IsNil)
+	c.Assert(ctx.kinds(), gc.DeepEquals, []environs.BootstrapEventKind{
+		environs.BootstrapAddressFound,
+		environs.BootstrapConnectFailed,
+		environs.BootstrapConnectFailed,
+		environs.BootstrapSSHConnected,
+		environs.BootstrapConfigured,
+	})
+	for i, event := range ctx.events[1:3] {
+		c.Check(event.InstanceId, gc.Equals, instance.Id("i-bootstrap"))
+		c.Check(event.Address, gc.Equals, "0.1.2.3")
+		c.Check(event.Attempt, gc.Equals, i+1)
+		c.Check(event.Error, gc.Equals, "connection refused")
+	}
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapSSHUser(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.BootstrapSSHUser = "ec2-user"
+
+	var users []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		users = append(users, user)
+		return nil
+	})
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		users = append(users, user)
+		return "0.7.5", nil
 	})
+	var target string
+	s.PatchValue(common.RunConfigureScript, func(_ string, params sshinit.ConfigureParams) error {
+		target = params.Host
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	err := common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, machineConfig)
 	c.Assert(err, gc.IsNil)
-	c.Assert(arch, gc.Equals, "ppc64el") // based on hardware characteristics
-	c.Assert(series, gc.Equals, config.PreferredSeries(mocksConfig))
+	// The nonce, mirror and cloud-init checks all log in as the user.
+	c.Assert(users, gc.DeepEquals, []string{"ec2-user", "ec2-user", "ec2-user"})
+	c.Assert(target, gc.Equals, "ec2-user@0.1.2.3")
+}
+
+func (s *BootstrapSuite) TestBootstrapReportsFailure(c *gc.C) {
+	env := &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			return nil, nil, nil, fmt.Errorf("no capacity")
+		},
+	}
+	ctx := &progressContext{BootstrapContext: coretesting.Context(c)}
+	_, err := common.Bootstrap(ctx, env, environs.BootstrapParams{
+		AvailableTools: tools.List{&tools.Tools{Version: version.Current}},
+	})
+	c.Assert(err, gc.ErrorMatches, "cannot start bootstrap instance: no capacity")
+	c.Assert(ctx.kinds(), gc.DeepEquals, []environs.BootstrapEventKind{environs.BootstrapFailed})
+	c.Assert(ctx.events[0].Error, gc.Equals, "cannot start bootstrap instance: no capacity")
 }
 
 type neverRefreshes struct {
@@ -177,7 +898,7 @@ var testSSHTimeout = config.SSHTimeoutOpts{
 
 func (s *BootstrapSuite) TestWaitSSHTimesOutWaitingForAddresses(c *gc.C) {
 	ctx := coretesting.Context(c)
-	_, err := common.WaitSSH(ctx, nil, ssh.DefaultClient, "/bin/true", neverAddresses{}, testSSHTimeout)
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", neverAddresses{}, testSSHTimeout, nil)
 	c.Check(err, gc.ErrorMatches, `waited for `+testSSHTimeout.Timeout.String()+` without getting any addresses`)
 	c.Check(coretesting.Stderr(ctx), gc.Matches, "Waiting for address\n")
 }
@@ -186,7 +907,7 @@ func (s *BootstrapSuite) TestWaitSSHKilledWaitingForAddresses(c *gc.C) {
 	ctx := coretesting.Context(c)
 	interrupted := make(chan os.Signal, 1)
 	interrupted <- os.Interrupt
-	_, err := common.WaitSSH(ctx, interrupted, ssh.DefaultClient, "/bin/true", neverAddresses{}, testSSHTimeout)
+	_, err := common.WaitSSH(ctx, interrupted, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", neverAddresses{}, testSSHTimeout, nil)
 	c.Check(err, gc.ErrorMatches, "interrupted")
 	c.Check(coretesting.Stderr(ctx), gc.Matches, "Waiting for address\n")
 }
@@ -201,7 +922,7 @@ func (brokenAddresses) Addresses() ([]network.Address, error) {
 
 func (s *BootstrapSuite) TestWaitSSHStopsOnBadError(c *gc.C) {
 	ctx := coretesting.Context(c)
-	_, err := common.WaitSSH(ctx, nil, ssh.DefaultClient, "/bin/true", brokenAddresses{}, testSSHTimeout)
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", brokenAddresses{}, testSSHTimeout, nil)
 	c.Check(err, gc.ErrorMatches, "getting addresses: Addresses will never work")
 	c.Check(coretesting.Stderr(ctx), gc.Equals, "Waiting for address\n")
 }
@@ -218,7 +939,7 @@ func (n *neverOpensPort) Addresses() ([]network.Address, error) {
 func (s *BootstrapSuite) TestWaitSSHTimesOutWaitingForDial(c *gc.C) {
 	ctx := coretesting.Context(c)
 	// 0.x.y.z addresses are always invalid
-	_, err := common.WaitSSH(ctx, nil, ssh.DefaultClient, "/bin/true", &neverOpensPort{addr: "0.1.2.3"}, testSSHTimeout)
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", &neverOpensPort{addr: "0.1.2.3"}, testSSHTimeout, nil)
 	c.Check(err, gc.ErrorMatches,
 		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: mock connection failure to 0.1.2.3`)
 	c.Check(coretesting.Stderr(ctx), gc.Matches,
@@ -248,7 +969,7 @@ func (s *BootstrapSuite) TestWaitSSHKilledWaitingForDial(c *gc.C) {
 	timeout := testSSHTimeout
 	timeout.Timeout = 1 * time.Minute
 	interrupted := make(chan os.Signal, 1)
-	_, err := common.WaitSSH(ctx, interrupted, ssh.DefaultClient, "", &interruptOnDial{name: "0.1.2.3", interrupted: interrupted}, timeout)
+	_, err := common.WaitSSH(ctx, interrupted, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", &interruptOnDial{name: "0.1.2.3", interrupted: interrupted}, timeout, nil)
 	c.Check(err, gc.ErrorMatches, "interrupted")
 	// Exact timing is imprecise but it should have tried a few times before being killed
 	c.Check(coretesting.Stderr(ctx), gc.Matches,
@@ -256,6 +977,83 @@ func (s *BootstrapSuite) TestWaitSSHKilledWaitingForDial(c *gc.C) {
 			"(Attempting to connect to 0.1.2.3:22\n)+")
 }
 
+func (s *BootstrapSuite) TestWaitSSHCancelledWaitingForAddresses(c *gc.C) {
+	ctx := coretesting.Context(c)
+	cancel := make(chan struct{})
+	close(cancel)
+	_, err := common.WaitSSH(environs.WithCancel(ctx, cancel), nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", neverAddresses{}, testSSHTimeout, nil)
+	c.Check(err, gc.Equals, environs.ErrBootstrapCancelled)
+	c.Check(coretesting.Stderr(ctx), gc.Matches, "Waiting for address\n")
+}
+
+func (s *BootstrapSuite) TestWaitSSHCancelledThroughWrapper(c *gc.C) {
+	ctx := coretesting.Context(c)
+	cancel := make(chan struct{})
+	close(cancel)
+	// The cancellation is seen even when the cancellable context is
+	// wrapped by another.
+	progressCtx, stop := environs.WithProgressWebhook(environs.WithCancel(ctx, cancel), "http://0.1.2.3/")
+	defer stop()
+	_, err := common.WaitSSH(progressCtx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", neverAddresses{}, testSSHTimeout, nil)
+	c.Check(err, gc.Equals, environs.ErrBootstrapCancelled)
+}
+
+type cancelOnDial struct {
+	neverRefreshes
+	name      string
+	cancel    chan struct{}
+	returned  bool
+	cancelled bool
+}
+
+func (i *cancelOnDial) Addresses() ([]network.Address, error) {
+	// cancel the bootstrap the second time Addresses is called
+	if !i.returned {
+		i.returned = true
+	} else if !i.cancelled {
+		i.cancelled = true
+		close(i.cancel)
+	}
+	return []network.Address{network.NewAddress(i.name, network.ScopeUnknown)}, nil
+}
+
+func (s *BootstrapSuite) TestWaitSSHCancelledWaitingForDial(c *gc.C) {
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = 1 * time.Minute
+	cancel := make(chan struct{})
+	interrupted := make(chan os.Signal, 1)
+	_, err := common.WaitSSH(environs.WithCancel(ctx, cancel), interrupted, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", &cancelOnDial{name: "0.1.2.3", cancel: cancel}, timeout, nil)
+	c.Check(err, gc.Equals, environs.ErrBootstrapCancelled)
+	c.Check(coretesting.Stderr(ctx), gc.Matches,
+		"Waiting for address\n"+
+			"(Attempting to connect to 0.1.2.3:22\n)+")
+}
+
+func (s *BootstrapSuite) TestJitteredDelay(c *gc.C) {
+	delay := 10 * time.Second
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := common.JitteredDelay(delay)
+		c.Assert(d >= delay/2, jc.IsTrue, gc.Commentf("delay %v", d))
+		c.Assert(d <= delay*3/2, jc.IsTrue, gc.Commentf("delay %v", d))
+		seen[d] = true
+	}
+	// The delays are spread out rather than all alike.
+	c.Assert(len(seen) > 1, jc.IsTrue)
+	c.Assert(common.JitteredDelay(0), gc.Equals, time.Duration(0))
+}
+
+func (s *BootstrapSuite) TestWaitSSHRetryJitter(c *gc.C) {
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.RetryJitter = true
+	// 0.x.y.z addresses are always invalid
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "/bin/true", &neverOpensPort{addr: "0.1.2.3"}, timeout, nil)
+	c.Check(err, gc.ErrorMatches,
+		`waited for `+timeout.Timeout.String()+` without being able to connect: mock connection failure to 0.1.2.3`)
+}
+
 type addressesChange struct {
 	addrs [][]string
 }
@@ -277,17 +1075,19 @@ func (ac *addressesChange) Addresses() ([]network.Address, error) {
 
 func (s *BootstrapSuite) TestWaitSSHRefreshAddresses(c *gc.C) {
 	ctx := coretesting.Context(c)
-	_, err := common.WaitSSH(ctx, nil, ssh.DefaultClient, "", &addressesChange{addrs: [][]string{
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", &addressesChange{addrs: [][]string{
 		nil,
 		nil,
 		[]string{"0.1.2.3"},
 		[]string{"0.1.2.3"},
 		nil,
 		[]string{"0.1.2.4"},
-	}}, testSSHTimeout)
-	// Not necessarily the last one in the list, due to scheduling.
+	}}, testSSHTimeout, nil)
+	// The later address may not have been tried, due to scheduling.
 	c.Check(err, gc.ErrorMatches,
-		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: mock connection failure to 0.1.2.[34]`)
+		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: `+
+			`(mock connection failure to 0.1.2.3|`+
+			`1 address: mock connection failure to 0.1.2.3; 1 address: mock connection failure to 0.1.2.4)`)
 	stderr := coretesting.Stderr(ctx)
 	c.Check(stderr, gc.Matches,
 		"Waiting for address\n"+
@@ -296,3 +1096,910 @@ func (s *BootstrapSuite) TestWaitSSHRefreshAddresses(c *gc.C) {
 		"Waiting for address\n"+
 			"(.|\n)*(Attempting to connect to 0.1.2.4:22\n)+(.|\n)*")
 }
+
+type hostnameAddress struct {
+	neverRefreshes
+	name string
+}
+
+func (h *hostnameAddress) Addresses() ([]network.Address, error) {
+	return []network.Address{network.NewAddress(h.name, network.ScopeUnknown)}, nil
+}
+
+func (s *BootstrapSuite) TestWaitSSHHostnameAddress(c *gc.C) {
+	// The hostname does not resolve for the first few attempts,
+	// to simulate DNS propagation delays after launch.
+	var lookups int
+	var mu sync.Mutex
+	s.PatchValue(common.LookupHost, func(host string) ([]string, error) {
+		c.Check(host, gc.Equals, "bootstrap.example.com")
+		mu.Lock()
+		defer mu.Unlock()
+		lookups++
+		if lookups < 3 {
+			return nil, fmt.Errorf("no such host")
+		}
+		return []string{"10.0.0.1"}, nil
+	})
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		c.Check(lookups, gc.Equals, 3)
+		if host != "bootstrap.example.com" {
+			return fmt.Errorf("mock connection failure to %s", host)
+		}
+		return nil
+	})
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", &hostnameAddress{name: "bootstrap.example.com"}, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr, gc.Equals, network.NewAddress("bootstrap.example.com", network.ScopeUnknown))
+	c.Check(coretesting.Stderr(ctx), gc.Equals,
+		"Waiting for address\n"+
+			"Attempting to connect to bootstrap.example.com:22\n")
+}
+
+func (s *BootstrapSuite) TestWaitSSHUnresolvableHostname(c *gc.C) {
+	s.PatchValue(common.LookupHost, func(host string) ([]string, error) {
+		return nil, fmt.Errorf("no such host")
+	})
+	ctx := coretesting.Context(c)
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", &hostnameAddress{name: "bootstrap.example.com"}, testSSHTimeout, nil)
+	c.Check(err, gc.ErrorMatches,
+		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: cannot resolve "bootstrap.example.com": no such host`)
+}
+
+// recordingSSHClient records the hosts given to Command.
+type recordingSSHClient struct {
+	ssh.Client
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (r *recordingSSHClient) Command(host string, command []string, options *ssh.Options) *ssh.Cmd {
+	r.mu.Lock()
+	r.hosts = append(r.hosts, host)
+	r.mu.Unlock()
+	return r.Client.Command(host, command, options)
+}
+
+func (s *BootstrapSuite) TestWaitSSHIPv6Address(c *gc.C) {
+	testing.PatchExecutable(c, s, "ssh", "#!/bin/sh\n")
+	testing.PatchExecutable(c, s, "scp", "#!/bin/sh\n")
+	openssh, err := ssh.NewOpenSSHClient()
+	c.Assert(err, gc.IsNil)
+	client := &recordingSSHClient{Client: openssh}
+	s.PatchValue(common.ConnectSSH, realConnectSSH)
+
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	inst := &multipleAddresses{addrs: []string{"2001:db8::1"}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(client, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr.Value, gc.Equals, "2001:db8::1")
+	// OpenSSH does not accept bracketed IPv6 literals in user@host.
+	c.Assert(client.hosts, gc.DeepEquals, []string{"ubuntu@2001:db8::1"})
+	c.Check(coretesting.Stderr(ctx), gc.Equals,
+		"Waiting for address\n"+
+			"Attempting to connect to [2001:db8::1]:22\n")
+}
+
+func (s *BootstrapSuite) TestWaitSSHSummarizesErrors(c *gc.C) {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		switch host {
+		case "0.1.2.3":
+			return fmt.Errorf("dial tcp %s:22: connection refused", host)
+		case "0.1.2.4":
+			return fmt.Errorf("nonce file does not exist")
+		}
+		return fmt.Errorf("ssh: connect to host %s port 22: Connection refused", host)
+	})
+	ctx := coretesting.Context(c)
+	inst := &multipleAddresses{addrs: []string{"0.1.2.1", "0.1.2.2", "0.1.2.3", "0.1.2.4", "0.1.2.5"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, testSSHTimeout, nil)
+	c.Assert(err, gc.ErrorMatches,
+		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: `+
+			`3 addresses: Connection refused; 1 address: connection refused; 1 address: nonce file does not exist`)
+}
+
+func (s *BootstrapSuite) TestWaitSSHSummarizesSingleError(c *gc.C) {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return fmt.Errorf("ssh: connect to host %s port 22: Connection refused", host)
+	})
+	ctx := coretesting.Context(c)
+	inst := &multipleAddresses{addrs: []string{"0.1.2.1"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, testSSHTimeout, nil)
+	// The error from a single address is given as is.
+	c.Assert(err, gc.ErrorMatches,
+		`waited for `+testSSHTimeout.Timeout.String()+` without being able to connect: `+
+			`ssh: connect to host 0.1.2.1 port 22: Connection refused`)
+}
+
+type multipleAddresses struct {
+	neverRefreshes
+	addrs []string
+}
+
+func (m *multipleAddresses) Addresses() ([]network.Address, error) {
+	return network.NewAddresses(m.addrs...), nil
+}
+
+// patchReachable arranges for only the given hosts to be reachable.
+func (s *BootstrapSuite) patchReachable(hosts ...string) {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		for _, reachable := range hosts {
+			if host == reachable {
+				return nil
+			}
+		}
+		return fmt.Errorf("mock connection failure to %s", host)
+	})
+}
+
+func (s *BootstrapSuite) TestWaitSSHMinAddresses(c *gc.C) {
+	s.patchReachable("10.0.0.1", "10.0.0.2")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	timeout.MinAddresses = 2
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	// Either reachable address may have been found first.
+	c.Assert(addr.Value, gc.Matches, `10\.0\.0\.[12]`)
+}
+
+func (s *BootstrapSuite) TestWaitSSHTooFewAddresses(c *gc.C) {
+	s.patchReachable("10.0.0.1", "10.0.0.2")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.MinAddresses = 3
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.ErrorMatches,
+		`waited for `+timeout.Timeout.String()+` with only 2 of 3 required addresses reachable`)
+}
+
+func (s *BootstrapSuite) TestWaitSSHPreferredCIDR(c *gc.C) {
+	s.patchReachable("10.0.0.1", "192.168.1.1")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	timeout.PreferredCIDR = "192.168.0.0/16"
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "192.168.1.1"}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr, gc.Equals, network.NewAddress("192.168.1.1", network.ScopeUnknown))
+}
+
+func (s *BootstrapSuite) TestWaitSSHPreferredCIDRUnreachable(c *gc.C) {
+	s.patchReachable("10.0.0.1")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.PreferredCIDR = "192.168.0.0/16"
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "192.168.1.1"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.ErrorMatches,
+		`waited for `+timeout.Timeout.String()+` without being able to connect to an address in 192.168.0.0/16`)
+
+	// If a minimum number of addresses is also required, any
+	// address will do once enough are reachable.
+	timeout.Timeout = coretesting.LongWait
+	timeout.MinAddresses = 1
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr.Value, gc.Equals, "10.0.0.1")
+}
+
+// dialRecorder records the hosts dialed by connectSSH, and the most
+// dials in progress at once.
+type dialRecorder struct {
+	mu      sync.Mutex
+	dialed  map[string]bool
+	dialing int
+	max     int
+}
+
+// patch arranges for connectSSH to record its dials, each taking a
+// little while, with only the given host reachable.
+func (r *dialRecorder) patch(s *BootstrapSuite, reachable string) {
+	r.dialed = make(map[string]bool)
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		r.mu.Lock()
+		r.dialed[host] = true
+		r.dialing++
+		if r.dialing > r.max {
+			r.max = r.dialing
+		}
+		r.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		r.mu.Lock()
+		r.dialing--
+		r.mu.Unlock()
+		if host != reachable {
+			return fmt.Errorf("mock connection failure to %s", host)
+		}
+		return nil
+	})
+}
+
+func (s *BootstrapSuite) TestWaitSSHMaxConcurrentDials(c *gc.C) {
+	var r dialRecorder
+	r.patch(s, "10.0.0.5")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	timeout.MaxConcurrentDials = 2
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr.Value, gc.Equals, "10.0.0.5")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Assert(r.max, gc.Equals, 2)
+	c.Assert(r.dialed, gc.HasLen, 5)
+}
+
+func (s *BootstrapSuite) TestWaitSSHMaxConcurrentDialsAddressesAdded(c *gc.C) {
+	var r dialRecorder
+	r.patch(s, "10.0.0.6")
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	timeout.MaxConcurrentDials = 1
+	// More addresses arrive while the first are queued than may be
+	// dialed at once; none of them may be lost.
+	inst := &addressesChange{addrs: [][]string{
+		{"10.0.0.1", "10.0.0.2"},
+		{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"},
+		{"10.0.0.3"},
+	}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr.Value, gc.Equals, "10.0.0.6")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Assert(r.max, gc.Equals, 1)
+	c.Assert(r.dialed, gc.HasLen, 6)
+}
+
+func (s *BootstrapSuite) TestWaitSSHMaxConcurrentDialsTimesOut(c *gc.C) {
+	// The first address never answers, so the second stays queued
+	// until the timeout.
+	unblock := make(chan struct{})
+	defer close(unblock)
+	var mu sync.Mutex
+	var dialed []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		mu.Lock()
+		dialed = append(dialed, host)
+		mu.Unlock()
+		<-unblock
+		return fmt.Errorf("mock connection failure to %s", host)
+	})
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.MaxConcurrentDials = 1
+	inst := &multipleAddresses{addrs: []string{"0.1.2.3", "0.1.2.4"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, nil)
+	c.Assert(err, gc.ErrorMatches, `waited for `+timeout.Timeout.String()+` without being able to connect`)
+	mu.Lock()
+	defer mu.Unlock()
+	c.Assert(dialed, jc.DeepEquals, []string{"0.1.2.3"})
+	c.Check(coretesting.Stderr(ctx), gc.Equals,
+		"Waiting for address\n"+
+			"Attempting to connect to 0.1.2.3:22\n")
+}
+
+// attemptRecorder records the failed attempts reported by waitSSH.
+type attemptRecorder struct {
+	mu       sync.Mutex
+	attempts map[string][]int
+	errors   []string
+}
+
+func (r *attemptRecorder) attempted(addr network.Address, attempt int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.attempts == nil {
+		r.attempts = make(map[string][]int)
+	}
+	r.attempts[addr.Value] = append(r.attempts[addr.Value], attempt)
+	r.errors = append(r.errors, err.Error())
+}
+
+func (s *BootstrapSuite) TestWaitSSHReportsAttempts(c *gc.C) {
+	var dials int
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		if dials++; dials <= 3 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	})
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.Timeout = coretesting.LongWait
+	var r attemptRecorder
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1"}}
+	addr, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, r.attempted)
+	c.Assert(err, gc.IsNil)
+	c.Assert(addr.Value, gc.Equals, "10.0.0.1")
+	c.Assert(r.attempts, jc.DeepEquals, map[string][]int{"10.0.0.1": {1, 2, 3}})
+	c.Assert(r.errors, jc.DeepEquals, []string{"connection refused", "connection refused", "connection refused"})
+}
+
+func (s *BootstrapSuite) TestWaitSSHReportsAttemptsWhileQueued(c *gc.C) {
+	s.patchReachable()
+	ctx := coretesting.Context(c)
+	timeout := testSSHTimeout
+	timeout.MaxConcurrentDials = 1
+	var r attemptRecorder
+	inst := &multipleAddresses{addrs: []string{"10.0.0.1", "10.0.0.2"}}
+	_, err := common.WaitSSH(ctx, nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, r.attempted)
+	c.Assert(err, gc.ErrorMatches, `waited for .* without being able to connect(: .*)?`)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Each address's attempts are counted across its turns.
+	c.Assert(r.attempts, gc.HasLen, 2)
+	for addr, attempts := range r.attempts {
+		for i, attempt := range attempts {
+			c.Assert(attempt, gc.Equals, i+1, gc.Commentf("address %s", addr))
+		}
+	}
+}
+
+func (s *BootstrapSuite) TestPostBootstrap(c *gc.C) {
+	hw := instance.MustParseHardware("arch=amd64 mem=2G")
+	machineConfig := &cloudinit.MachineConfig{
+		Tools:                   &tools.Tools{Version: version.MustParseBinary("1.2.3-trusty-amd64")},
+		HardwareCharacteristics: &hw,
+	}
+	var got []environs.BootstrapMachine
+	ctx := environs.WithPostBootstrapHook(coretesting.Context(c), func(m environs.BootstrapMachine) error {
+		got = append(got, m)
+		return nil
+	}, false)
+	addr := network.NewAddress("10.0.0.1", network.ScopeCloudLocal)
+	err := common.PostBootstrap(ctx, &mockInstance{id: "i-bootstrap"}, addr, machineConfig, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(got, gc.DeepEquals, []environs.BootstrapMachine{{
+		InstanceId:   "i-bootstrap",
+		Address:      "10.0.0.1",
+		AddressScope: network.ScopeCloudLocal,
+		Tools:        version.MustParseBinary("1.2.3-trusty-amd64"),
+		Hardware:     &hw,
+	}})
+}
+
+func (s *BootstrapSuite) TestPostBootstrapNoHook(c *gc.C) {
+	err := common.PostBootstrap(coretesting.Context(c), &mockInstance{id: "i-bootstrap"}, network.NewAddress("10.0.0.1", network.ScopeUnknown), &cloudinit.MachineConfig{}, nil)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestPostBootstrapErrorLogged(c *gc.C) {
+	ctx := environs.WithPostBootstrapHook(coretesting.Context(c), func(environs.BootstrapMachine) error {
+		return fmt.Errorf("inventory unavailable")
+	}, false)
+	err := common.PostBootstrap(ctx, &mockInstance{id: "i-bootstrap"}, network.NewAddress("10.0.0.1", network.ScopeUnknown), &cloudinit.MachineConfig{}, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(c.GetTestLog(), jc.Contains, "post-bootstrap hook failed: inventory unavailable")
+}
+
+func (s *BootstrapSuite) TestPostBootstrapErrorStrict(c *gc.C) {
+	ctx := environs.WithPostBootstrapHook(coretesting.Context(c), func(environs.BootstrapMachine) error {
+		return fmt.Errorf("inventory unavailable")
+	}, true)
+	err := common.PostBootstrap(ctx, &mockInstance{id: "i-bootstrap"}, network.NewAddress("10.0.0.1", network.ScopeUnknown), &cloudinit.MachineConfig{}, nil)
+	c.Assert(err, gc.ErrorMatches, "post-bootstrap hook failed: inventory unavailable")
+}
+
+// bootstrapMachineConfig returns a finished machine config for a
+// bootstrap machine.
+func bootstrapMachineConfig(c *gc.C) *cloudinit.MachineConfig {
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "trusty")
+	c.Assert(err, gc.IsNil)
+	hw := instance.MustParseHardware("arch=amd64")
+	machineConfig.InstanceId = "i-bootstrap"
+	machineConfig.HardwareCharacteristics = &hw
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-trusty-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{"admin-secret": "sekrit"})
+	c.Assert(err, gc.IsNil)
+	err = environs.FinishMachineConfig(machineConfig, cfg)
+	c.Assert(err, gc.IsNil)
+	return machineConfig
+}
+
+// configureMachine calls ConfigureMachine with the given machine
+// config, and returns the script that would have been run.
+func (s *BootstrapSuite) configureMachine(c *gc.C, machineConfig *cloudinit.MachineConfig) string {
+	script, _ := s.configureMachineParams(c, machineConfig)
+	return script
+}
+
+// configureMachineParams is like configureMachine, but also returns
+// the parameters the script would have been run with.
+func (s *BootstrapSuite) configureMachineParams(c *gc.C, machineConfig *cloudinit.MachineConfig) (string, sshinit.ConfigureParams) {
+	var script string
+	var params sshinit.ConfigureParams
+	s.PatchValue(common.RunConfigureScript, func(rendered string, p sshinit.ConfigureParams) error {
+		script, params = rendered, p
+		c.Check(p.Host, gc.Equals, "ubuntu@10.0.0.1")
+		return nil
+	})
+	err := common.ConfigureMachine(coretesting.Context(c), ssh.DefaultClient, "10.0.0.1", machineConfig)
+	c.Assert(err, gc.IsNil)
+	return script, params
+}
+
+func (s *BootstrapSuite) TestConfigureMachineStreamLog(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	_, params := s.configureMachineParams(c, machineConfig)
+	c.Assert(params.TailLog, gc.Equals, "")
+
+	cfg, err := machineConfig.Config.Apply(map[string]interface{}{"bootstrap-stream-log": true})
+	c.Assert(err, gc.IsNil)
+	machineConfig.Config = cfg
+	machineConfig.CloudInitOutputLog = "/mnt/logs/cloud-init-output.log"
+	_, params = s.configureMachineParams(c, machineConfig)
+	c.Assert(params.TailLog, gc.Equals, "/mnt/logs/cloud-init-output.log")
+}
+
+func (s *BootstrapSuite) TestConfigureMachineIPv6(c *gc.C) {
+	var host string
+	s.PatchValue(common.RunConfigureScript, func(_ string, p sshinit.ConfigureParams) error {
+		host = p.Host
+		return nil
+	})
+	err := common.ConfigureMachine(coretesting.Context(c), ssh.DefaultClient, "2001:db8::1", bootstrapMachineConfig(c))
+	c.Assert(err, gc.IsNil)
+	c.Assert(host, gc.Equals, "ubuntu@2001:db8::1")
+}
+
+func (s *BootstrapSuite) TestConfigureMachineCancelled(c *gc.C) {
+	s.PatchValue(common.RunConfigureScript, func(_ string, params sshinit.ConfigureParams) error {
+		<-params.Cancel
+		return sshinit.ErrConfigureCancelled
+	})
+	cancel := make(chan struct{})
+	close(cancel)
+	ctx := environs.WithCancel(coretesting.Context(c), cancel)
+	err := common.ConfigureMachine(ctx, ssh.DefaultClient, "10.0.0.1", bootstrapMachineConfig(c))
+	c.Assert(err, gc.Equals, environs.ErrBootstrapCancelled)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineCloudInitOutputLog(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+
+	// Override the log path, as bootstrap.Bootstrap does.
+	logPath := "/mnt/logs/cloud-init-output.log"
+	machineConfig.CloudInitOutputLog = logPath
+
+	script := s.configureMachine(c, machineConfig)
+	c.Assert(script, jc.HasPrefix, shell.DumpFileOnErrorScript(logPath))
+}
+
+func (s *BootstrapSuite) TestConfigureMachineAptMirrors(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.AptMirror = "http://mirror.internal/ubuntu"
+	machineConfig.AptSecurityMirror = "http://security-mirror.internal/ubuntu"
+
+	script := s.configureMachine(c, machineConfig)
+	mirrorCmd := "new_mirror=http://mirror.internal/ubuntu"
+	securityCmd := `sed -i 's,https\?://security\.ubuntu\.com/ubuntu/\?,http://security-mirror.internal/ubuntu,g' /etc/apt/sources.list`
+	c.Assert(script, jc.Contains, mirrorCmd)
+	c.Assert(script, jc.Contains, securityCmd)
+	// The mirrors must be in place before any packages are fetched.
+	update := strings.Index(script, "apt-get --option Dpkg::Options::=--force-confold --assume-yes update")
+	c.Assert(update, jc.GreaterThan, -1)
+	c.Assert(strings.Index(script, mirrorCmd) < update, jc.IsTrue)
+	c.Assert(strings.Index(script, securityCmd) < update, jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineDiskLayouts(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.DiskLayouts = []cloudinit.DiskLayout{{
+		Device:     "/dev/xvdb",
+		Filesystem: "ext4",
+		MountPoint: path.Join(machineConfig.DataDir, "db"),
+	}}
+
+	script := s.configureMachine(c, machineConfig)
+	mountCmd := "mountpoint -q '/var/lib/juju/db' || mount '/var/lib/juju/db'"
+	c.Assert(script, jc.Contains, "blkid '/dev/xvdb' >/dev/null || mkfs -t ext4 '/dev/xvdb'")
+	c.Assert(script, jc.Contains, mountCmd)
+	// The database volume must be mounted before anything is installed.
+	c.Assert(strings.Index(script, mountCmd) < strings.Index(script, "apt-get"), jc.IsTrue)
+}
+
+type refreshingInstance struct {
+	neverRefreshes
+	mockInstance
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapDataDir(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	// Override the data dir, as bootstrap.Bootstrap does.
+	machineConfig.DataDir = "/mnt/juju"
+
+	var checkScripts []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		checkScripts = append(checkScripts, checkHostScript)
+		return nil
+	})
+	// Stop once the instance has been verified.
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		return "", fmt.Errorf("stop")
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	err := common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "cannot determine cloud-init version: stop")
+	c.Assert(checkScripts[0], jc.Contains, "noncefile='/mnt/juju/nonce.txt'")
+
+	script := s.configureMachine(c, machineConfig)
+	c.Assert(script, jc.Contains, "/mnt/juju/agents/machine-0/agent.conf")
+}
+
+func (s *BootstrapSuite) TestConfigureMachineHostEntries(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.HostEntries = []cloudinit.HostEntry{
+		{Address: "10.0.0.5", Hostnames: []string{"archive.internal"}},
+	}
+
+	script := s.configureMachine(c, machineConfig)
+	hostsCmd := `grep -qxF '10.0.0.5 archive.internal' /etc/hosts || printf '%s\n' '10.0.0.5 archive.internal' >> /etc/hosts`
+	c.Assert(script, jc.Contains, hostsCmd)
+	// The entries must be in place before any packages are fetched.
+	c.Assert(strings.Index(script, hostsCmd) < strings.Index(script, "apt-get"), jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineEgressRules(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.EgressRules = []cloudinit.EgressRule{
+		{Protocol: "tcp", CIDR: "91.189.88.0/21", Port: 80},
+	}
+
+	script := s.configureMachine(c, machineConfig)
+	ruleCmd := "iptables -A OUTPUT -p tcp -d 91.189.88.0/21 --dport 80 -j ACCEPT"
+	c.Assert(script, jc.Contains, ruleCmd)
+	c.Assert(script, jc.Contains, "iptables -P OUTPUT DROP")
+	// The rules must be in place before any packages are fetched.
+	c.Assert(strings.Index(script, "iptables -P OUTPUT DROP") < strings.Index(script, "apt-get"), jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineExtraCloudConfig(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.ExtraCloudConfig = func(cfg *coreCloudinit.Config) error {
+		cfg.AddPackage("site-monitoring-agent")
+		cfg.AddRunCmd("echo site-specific")
+		return nil
+	}
+
+	script := s.configureMachine(c, machineConfig)
+	c.Assert(script, jc.Contains, "site-monitoring-agent")
+	c.Assert(script, jc.Contains, "echo site-specific")
+	// The added commands run after Juju has started its agent.
+	c.Assert(strings.Index(script, "Starting Juju machine agent") < strings.Index(script, "echo site-specific"), jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineExtraCloudConfigError(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.ExtraCloudConfig = func(*coreCloudinit.Config) error {
+		return fmt.Errorf("no site config")
+	}
+	err := common.ConfigureMachine(coretesting.Context(c), ssh.DefaultClient, "10.0.0.1", machineConfig)
+	c.Assert(err, gc.ErrorMatches, "cannot add extra cloud-init configuration: no site config")
+}
+
+func (s *BootstrapSuite) patchCloudInitVersion(version string) {
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		return version, nil
+	})
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersion(c *gc.C) {
+	s.patchCloudInitVersion("0.7.5-0ubuntu1.3")
+	installed, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "")
+	c.Assert(err, gc.IsNil)
+	c.Assert(installed, gc.Equals, "0.7.5-0ubuntu1.3")
+	installed, err = common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "0")
+	c.Assert(err, gc.IsNil)
+	c.Assert(installed, gc.Equals, "0.7.5-0ubuntu1.3")
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersionComparesMajorVersion(c *gc.C) {
+	// Any minor version of the expected major version is accepted.
+	s.patchCloudInitVersion("0.6.3-0ubuntu1.13")
+	_, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "0")
+	c.Assert(err, gc.IsNil)
+	s.patchCloudInitVersion("17.2-35-gf576b2a2-0ubuntu1~16.04.2")
+	_, err = common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "17")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersionTooOld(c *gc.C) {
+	s.patchCloudInitVersion("0.5.15-0ubuntu1")
+	_, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "")
+	c.Assert(err, gc.ErrorMatches, `cloud-init 0.5.15-0ubuntu1 on bootstrap instance is older than the minimum supported version 0.6`)
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersionMismatch(c *gc.C) {
+	s.patchCloudInitVersion("0.7.5-0ubuntu1.3")
+	_, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "17")
+	c.Assert(err, gc.ErrorMatches, `bootstrap instance has cloud-init 0.7.5-0ubuntu1.3, but major version 17 was expected`)
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersionUnparseable(c *gc.C) {
+	s.patchCloudInitVersion("dunno")
+	_, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "")
+	c.Assert(err, gc.ErrorMatches, `cannot parse cloud-init version "dunno"`)
+}
+
+func (s *BootstrapSuite) TestCheckCloudInitVersionError(c *gc.C) {
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		return "", fmt.Errorf("dpkg-query: no packages found matching cloud-init")
+	})
+	_, err := common.CheckCloudInitVersion(ssh.DefaultClient, "ubuntu", "0.1.2.3", "")
+	c.Assert(err, gc.ErrorMatches, `cannot determine cloud-init version: dpkg-query: no packages found matching cloud-init`)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineWaitsForCloudInitStatus(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.CloudInitVersion = "17.2-35-gf576b2a2-0ubuntu1~16.04.2"
+
+	script := s.configureMachine(c, machineConfig)
+	waitCmd := "timeout 600 cloud-init status --wait >/dev/null || true"
+	c.Assert(script, jc.Contains, waitCmd)
+	c.Assert(script, gc.Not(jc.Contains), "boot-finished")
+	c.Assert(strings.Index(script, waitCmd) < strings.Index(script, "apt-get"), jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineWaitsForCloudInitBootFinished(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.CloudInitVersion = "0.7.5-0ubuntu1.3"
+
+	script := s.configureMachine(c, machineConfig)
+	waitCmd := "timeout 600 sh -c 'while [ ! -e /var/lib/cloud/instance/boot-finished ]; do sleep 1; done' || true"
+	c.Assert(script, jc.Contains, waitCmd)
+	c.Assert(script, gc.Not(jc.Contains), "cloud-init status")
+	c.Assert(strings.Index(script, waitCmd) < strings.Index(script, "apt-get"), jc.IsTrue)
+}
+
+func (s *BootstrapSuite) TestConfigureMachineUnknownCloudInitVersion(c *gc.C) {
+	script := s.configureMachine(c, bootstrapMachineConfig(c))
+	c.Assert(script, gc.Not(jc.Contains), "Waiting for cloud-init")
+}
+
+func (s *BootstrapSuite) patchCloudInitStatus(output string) {
+	s.PatchValue(common.CloudInitStatusOutput, func(_ ssh.Client, user, host string) (string, error) {
+		return output, nil
+	})
+}
+
+// finishBootstrapWithStatus runs FinishBootstrap against a machine on
+// which cloud-init reports the given status, returning the error and
+// the machine passed to the post-bootstrap hook.
+func (s *BootstrapSuite) finishBootstrapWithStatus(c *gc.C, ctx environs.BootstrapContext, output string) (environs.BootstrapMachine, error) {
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.patchCloudInitStatus(output)
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{id: "i-bootstrap", addresses: network.NewAddresses("0.1.2.3")},
+	}
+	var machine environs.BootstrapMachine
+	ctx = environs.WithPostBootstrapHook(ctx, func(m environs.BootstrapMachine) error {
+		machine = m
+		return nil
+	}, false)
+	err := common.FinishBootstrap(ctx, ssh.DefaultClient, inst, bootstrapMachineConfig(c))
+	return machine, err
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapCloudInitDone(c *gc.C) {
+	ctx := coretesting.Context(c)
+	machine, err := s.finishBootstrapWithStatus(c, ctx, "\nstatus: done\nextended_status: done\nboot_status_code: enabled-by-generator\n")
+	c.Assert(err, gc.IsNil)
+	c.Assert(machine.CloudInitStatus, gc.Equals, "done")
+	c.Assert(coretesting.Stderr(ctx), gc.Not(jc.Contains), "WARNING")
+	// The hook is told how the machine was reached.
+	addr := network.NewAddress("0.1.2.3", network.ScopeUnknown)
+	c.Assert(machine.Address, gc.Equals, addr.Value)
+	c.Assert(machine.AddressScope, gc.Equals, addr.Scope)
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapCloudInitDegraded(c *gc.C) {
+	ctx := coretesting.Context(c)
+	machine, err := s.finishBootstrapWithStatus(c, ctx, `
+status: done
+extended_status: degraded done
+boot_status_code: enabled-by-generator
+last_update: Thu, 01 Jan 1970 00:00:25 +0000
+detail: DataSourceEc2Local
+errors: []
+recoverable_errors:
+WARNING:
+	- module apt-configure failed
+	- module package-update-upgrade-install failed
+`)
+	// A degraded run does not fail the bootstrap, but is warned about.
+	c.Assert(err, gc.IsNil)
+	const problems = "module apt-configure failed; module package-update-upgrade-install failed"
+	c.Assert(machine.CloudInitStatus, gc.Equals, "degraded: "+problems)
+	c.Assert(coretesting.Stderr(ctx), jc.Contains,
+		"WARNING: cloud-init on bootstrap instance finished degraded: "+problems+"\n")
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapCloudInitError(c *gc.C) {
+	_, err := s.finishBootstrapWithStatus(c, coretesting.Context(c), `
+status: error
+extended_status: error - done
+detail: DataSourceNoCloud
+errors:
+	- Failed to run module scripts-user
+recoverable_errors: {}
+`)
+	c.Assert(err, gc.ErrorMatches, "cloud-init on bootstrap instance failed: Failed to run module scripts-user")
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapCloudInitStatusUnsupported(c *gc.C) {
+	// Older cloud-init cannot report its status, and prints nothing.
+	machine, err := s.finishBootstrapWithStatus(c, coretesting.Context(c), "")
+	c.Assert(err, gc.IsNil)
+	c.Assert(machine.CloudInitStatus, gc.Equals, "")
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapCloudInitStatusFails(c *gc.C) {
+	s.PatchValue(common.CloudInitStatusOutput, func(_ ssh.Client, user, host string) (string, error) {
+		return "", fmt.Errorf("connection reset")
+	})
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return nil
+	})
+	s.patchCloudInitVersion("0.7.5")
+	s.PatchValue(common.RunConfigureScript, func(string, sshinit.ConfigureParams) error {
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	// The machine has been configured, so the bootstrap succeeds.
+	err := common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, bootstrapMachineConfig(c))
+	c.Assert(err, gc.IsNil)
+}
+
+// runScriptLocally runs a script passed to connectSSH on the local
+// machine, in place of the given host.
+func runScriptLocally(_ ssh.Client, _, host, script string) error {
+	cmd := exec.Command("/bin/bash")
+	cmd.Stdin = strings.NewReader(script)
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		err = fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// patchCurl replaces curl with a script that records its arguments in
+// the returned file, and then runs the given commands.
+func (s *BootstrapSuite) patchCurl(c *gc.C, commands string) string {
+	argsFile := path.Join(c.MkDir(), "args")
+	testing.PatchExecutable(c, s, "curl", fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n%s", argsFile, commands))
+	return argsFile
+}
+
+func (s *BootstrapSuite) TestCheckMirrorReachable(c *gc.C) {
+	argsFile := s.patchCurl(c, "exit 0")
+	s.PatchValue(common.ConnectSSH, runScriptLocally)
+	err := common.CheckMirrorReachable(ssh.DefaultClient, "ubuntu", "0.1.2.3", "http://mirror.internal/ubuntu/", proxy.Settings{})
+	c.Assert(err, gc.IsNil)
+	args, err := ioutil.ReadFile(argsFile)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(args), gc.Equals, "-sS --head --fail --max-time 30 -o /dev/null http://mirror.internal/ubuntu/\n")
+}
+
+func (s *BootstrapSuite) TestCheckMirrorReachableThroughProxy(c *gc.C) {
+	envFile := path.Join(c.MkDir(), "env")
+	s.patchCurl(c, fmt.Sprintf(`echo "$http_proxy $https_proxy $no_proxy" > %s`, envFile))
+	s.PatchValue(common.ConnectSSH, runScriptLocally)
+	err := common.CheckMirrorReachable(ssh.DefaultClient, "ubuntu", "0.1.2.3", "http://mirror.internal/ubuntu/", proxy.Settings{
+		Http:    "http://proxy.internal:3128",
+		Https:   "https://proxy.internal:3129",
+		NoProxy: "localhost",
+	})
+	c.Assert(err, gc.IsNil)
+	env, err := ioutil.ReadFile(envFile)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(env), gc.Equals, "http://proxy.internal:3128 https://proxy.internal:3129 localhost\n")
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapMirrorCheckUsesAptProxy(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	var err error
+	machineConfig.Config, err = machineConfig.Config.Apply(map[string]interface{}{
+		"http-proxy":     "http://proxy.internal:3128",
+		"apt-http-proxy": "http://apt-proxy.internal:3142",
+		"no-proxy":       "localhost",
+	})
+	c.Assert(err, gc.IsNil)
+	var checkScripts []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		checkScripts = append(checkScripts, checkHostScript)
+		return nil
+	})
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		return "", fmt.Errorf("stop")
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	err = common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "cannot determine cloud-init version: stop")
+	c.Assert(checkScripts, gc.HasLen, 2)
+	c.Assert(checkScripts[1], jc.Contains, "http_proxy=http://apt-proxy.internal:3142")
+	c.Assert(checkScripts[1], jc.Contains, "no_proxy=localhost")
+	c.Assert(checkScripts[1], gc.Not(jc.Contains), "proxy.internal:3128")
+}
+
+func (s *BootstrapSuite) TestCheckMirrorUnreachable(c *gc.C) {
+	s.patchCurl(c, "echo 'curl: (6) Could not resolve host: mirror.internal' >&2; exit 6")
+	s.PatchValue(common.ConnectSSH, runScriptLocally)
+	err := common.CheckMirrorReachable(ssh.DefaultClient, "ubuntu", "0.1.2.3", "http://mirror.internal/ubuntu/", proxy.Settings{})
+	c.Assert(err, gc.ErrorMatches, `bootstrap node cannot reach package mirror http://mirror.internal/ubuntu/: curl: \(6\) Could not resolve host: mirror.internal`)
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapMirrorUnreachable(c *gc.C) {
+	s.patchCurl(c, "exit 7")
+	s.PatchValue(common.ConnectSSH, func(client ssh.Client, user, host, checkHostScript string) error {
+		if strings.Contains(checkHostScript, "noncefile") {
+			return nil
+		}
+		return runScriptLocally(client, user, host, checkHostScript)
+	})
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		c.Fatalf("bootstrap continued after mirror check failed")
+		return "", nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	err := common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, bootstrapMachineConfig(c))
+	c.Assert(err, gc.ErrorMatches, "bootstrap node cannot reach package mirror "+config.DefaultBootstrapMirrorCheckURL+": exit status 7")
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapMirrorCheckSkipped(c *gc.C) {
+	machineConfig := bootstrapMachineConfig(c)
+	var err error
+	machineConfig.Config, err = machineConfig.Config.Apply(map[string]interface{}{
+		"bootstrap-mirror-check": false,
+	})
+	c.Assert(err, gc.IsNil)
+
+	var checkScripts []string
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		checkScripts = append(checkScripts, checkHostScript)
+		return nil
+	})
+	s.PatchValue(common.CloudInitVersion, func(_ ssh.Client, user, host string) (string, error) {
+		return "", fmt.Errorf("stop")
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	err = common.FinishBootstrap(coretesting.Context(c), ssh.DefaultClient, inst, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "cannot determine cloud-init version: stop")
+	// Only the nonce was checked.
+	c.Assert(checkScripts, gc.HasLen, 1)
+	c.Assert(checkScripts[0], jc.Contains, "noncefile")
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"time"
+)
+
+// clock provides the time to the timers that bound a bootstrap, so
+// that tests may control it.
+type clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d
+	// has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// wallClock is the clock used outside of tests.
+type wallClock struct{}
+
+// Now is part of the clock interface.
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// After is part of the clock interface.
+func (wallClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// bootstrapClock is the clock used while waiting for the bootstrap
+// instance. It is taken when each wait starts, so that goroutines
+// outliving a test that patches it do not race with the test.
+var bootstrapClock clock = wallClock{}
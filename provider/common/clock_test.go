@@ -0,0 +1,161 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/provider/common"
+	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/utils/ssh"
+)
+
+// fakeClock is a clock whose time only passes when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.waiters = append(f.waiters, fakeWaiter{f.now.Add(d), c})
+	return c
+}
+
+// Advance moves the time on by d, firing every waiter whose time has
+// come.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	waiters := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- f.now
+	}
+	f.waiters = waiters
+}
+
+// waitWaiters waits until at least n calls to After are waiting for
+// the time to be advanced.
+func (f *fakeClock) waitWaiters(c *gc.C, n int) {
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		f.mu.Lock()
+		waiting := len(f.waiters)
+		f.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+	}
+	c.Fatalf("timed out waiting for %d waiters", n)
+}
+
+func (s *BootstrapSuite) TestWaitSSHFakeClockTimeout(c *gc.C) {
+	clock := newFakeClock()
+	s.PatchValue(common.BootstrapClock, clock)
+	timeout := testSSHTimeout
+	timeout.Timeout = time.Hour
+	timeout.AddressesDelay = time.Minute
+	done := make(chan error, 1)
+	go func() {
+		_, err := common.WaitSSH(coretesting.Context(c), nil, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", neverAddresses{}, timeout, nil)
+		done <- err
+	}()
+	// The global timeout and the next address poll are waiting.
+	clock.waitWaiters(c, 2)
+	select {
+	case err := <-done:
+		c.Fatalf("returned before the timeout: %v", err)
+	default:
+	}
+	clock.Advance(time.Hour)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.ErrorMatches, "waited for 1h0m0s without getting any addresses")
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for WaitSSH")
+	}
+}
+
+func (s *BootstrapSuite) TestWaitSSHFakeClockRetryDelay(c *gc.C) {
+	clock := newFakeClock()
+	s.PatchValue(common.BootstrapClock, clock)
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		return fmt.Errorf("mock connection failure to %s", host)
+	})
+	timeout := testSSHTimeout
+	timeout.Timeout = time.Hour
+	timeout.AddressesDelay = time.Hour
+	timeout.RetryDelay = time.Minute
+	attempts := make(chan int, 10)
+	attempted := func(addr network.Address, attempt int, err error) {
+		attempts <- attempt
+	}
+	interrupted := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		inst := &multipleAddresses{addrs: []string{"0.1.2.3"}}
+		_, err := common.WaitSSH(coretesting.Context(c), interrupted, common.NewSSHConnector(ssh.DefaultClient, "ubuntu"), "", inst, timeout, attempted)
+		done <- err
+	}()
+	assertAttempt := func(expect int) {
+		select {
+		case attempt := <-attempts:
+			c.Assert(attempt, gc.Equals, expect)
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("timed out waiting for attempt %d", expect)
+		}
+	}
+	assertAttempt(1)
+	// The global timeout, the next address poll and the retry are
+	// waiting; no attempt is made until the retry delay has passed.
+	clock.waitWaiters(c, 3)
+	clock.Advance(30 * time.Second)
+	select {
+	case attempt := <-attempts:
+		c.Fatalf("attempt %d made before the retry delay passed", attempt)
+	case <-time.After(coretesting.ShortWait):
+	}
+	clock.Advance(30 * time.Second)
+	assertAttempt(2)
+
+	interrupted <- os.Interrupt
+	select {
+	case err := <-done:
+		c.Assert(err, gc.ErrorMatches, "interrupted")
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for WaitSSH")
+	}
+}
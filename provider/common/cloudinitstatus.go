@@ -0,0 +1,131 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/utils/ssh"
+)
+
+// cloudInitStatus describes how cloud-init finished on a machine, as
+// reported by "cloud-init status --long".
+type cloudInitStatus struct {
+	// Status is "done", "degraded" or "error", or any other status
+	// cloud-init reports, such as "running".
+	Status string
+
+	// Problems holds the failing modules or errors reported by
+	// cloud-init, if the status is not "done".
+	Problems []string
+}
+
+func (s *cloudInitStatus) String() string {
+	if len(s.Problems) == 0 {
+		return s.Status
+	}
+	return fmt.Sprintf("%s: %s", s.Status, strings.Join(s.Problems, "; "))
+}
+
+// cloudInitStatusScript reports the status of cloud-init, if the
+// installed cloud-init is new enough to do so. The exit status of
+// "cloud-init status" is ignored, as it is non-zero whenever a problem
+// is reported.
+const cloudInitStatusScript = `
+if cloud-init status --help >/dev/null 2>&1; then
+	cloud-init status --long || true
+fi
+`
+
+// cloudInitStatusOutput is called to get the output of
+// cloudInitStatusScript from the specified host.
+var cloudInitStatusOutput = func(client ssh.Client, user, host string) (string, error) {
+	cmd := client.Command(user+"@"+host, []string{"/bin/bash"}, nil)
+	cmd.Stdin = strings.NewReader(cloudInitStatusScript)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > 0 {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(output)))
+		}
+		return "", err
+	}
+	return string(output), nil
+}
+
+// cloudInitStatusKey matches the start of each section in the output
+// of "cloud-init status --long".
+var cloudInitStatusKey = regexp.MustCompile(`^([a-z_]+):\s*(.*)$`)
+
+// parseCloudInitStatus parses the output of "cloud-init status --long".
+// It returns nil if the output is empty, as it is when cloud-init is
+// too old to report its status.
+func parseCloudInitStatus(output string) *cloudInitStatus {
+	if strings.TrimSpace(output) == "" {
+		return nil
+	}
+	var status, extendedStatus, section string
+	var detail, problems []string
+	for _, line := range strings.Split(output, "\n") {
+		if m := cloudInitStatusKey.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			switch section {
+			case "status":
+				status = m[2]
+			case "extended_status":
+				extendedStatus = m[2]
+			}
+			continue
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case section == "detail":
+			detail = append(detail, line)
+		case section == "errors" || section == "recoverable_errors":
+			if strings.HasPrefix(line, "- ") {
+				problems = append(problems, strings.TrimPrefix(line, "- "))
+			}
+		}
+	}
+	if status == "" {
+		status = "unknown"
+	}
+	// cloud-init reports a degraded run as done, with the
+	// degradation recorded in its extended status.
+	if status == "done" && strings.HasPrefix(extendedStatus, "degraded") {
+		status = "degraded"
+	}
+	if status == "done" {
+		return &cloudInitStatus{Status: status}
+	}
+	if len(problems) == 0 {
+		problems = detail
+	}
+	return &cloudInitStatus{Status: status, Problems: problems}
+}
+
+// checkCloudInitStatus reports the given cloud-init status of the
+// bootstrap machine. An error is returned if cloud-init failed; a
+// degraded run is only warned about, as the machine has been
+// configured nonetheless.
+func checkCloudInitStatus(ctx environs.BootstrapContext, status *cloudInitStatus) error {
+	if status == nil {
+		return nil
+	}
+	switch status.Status {
+	case "error":
+		return fmt.Errorf("cloud-init on bootstrap instance failed: %s", strings.Join(status.Problems, "; "))
+	case "degraded":
+		msg := "cloud-init on bootstrap instance finished degraded"
+		if len(status.Problems) > 0 {
+			msg += ": " + strings.Join(status.Problems, "; ")
+		}
+		logger.Warningf("%s", msg)
+		fmt.Fprintf(ctx.GetStderr(), "WARNING: %s\n", msg)
+	}
+	return nil
+}
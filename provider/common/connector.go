@@ -0,0 +1,281 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/juju/utils"
+
+	coreCloudinit "github.com/juju/juju/cloudinit"
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/utils/ssh"
+	"github.com/juju/juju/utils/winrm"
+	"github.com/juju/juju/version"
+)
+
+// bootstrapConnector connects to a bootstrap machine to check and
+// configure it, in the way suited to the machine's operating system.
+type bootstrapConnector interface {
+	// Port returns the port connected to.
+	Port() int
+
+	// Run runs the given script on the machine at host.
+	Run(host, script string) error
+
+	// CheckNonceScript returns a script, to be passed to Run, that
+	// fails unless the machine is the one described by
+	// machineConfig.
+	CheckNonceScript(machineConfig *cloudinit.MachineConfig) string
+
+	// CheckMachine checks that the machine at host is fit to be
+	// configured.
+	CheckMachine(host string, machineConfig *cloudinit.MachineConfig) error
+
+	// ConfigureMachine configures the machine at host as described
+	// by machineConfig.
+	ConfigureMachine(ctx environs.BootstrapContext, host string, machineConfig *cloudinit.MachineConfig) error
+
+	// CloudInitStatus returns how cloud-init finished on the machine
+	// at host, or nil if that cannot be told.
+	CloudInitStatus(host string) (*cloudInitStatus, error)
+}
+
+// checkBootstrapConnector returns an error if the bootstrap machine,
+// of the given series, could not be reached with the environment
+// configuration cfg, so that this is found before the bootstrap
+// instance is started. Windows machines need a WinRM password.
+func checkBootstrapConnector(cfg *config.Config, series string) error {
+	operatingSystem, err := version.GetOSFromSeries(series)
+	if err != nil || operatingSystem != version.Windows {
+		// newBootstrapConnector reports unknown series.
+		return nil
+	}
+	if _, password := cfg.BootstrapWinRMCredentials(); password == "" {
+		return fmt.Errorf("cannot bootstrap %s: bootstrap-winrm-password is not set", series)
+	}
+	return nil
+}
+
+// newBootstrapConnector returns the connector to use for the machine
+// described by machineConfig, according to its series. Ubuntu machines
+// are reached over SSH using client, Windows machines over WinRM,
+// presenting the certificates held for them in BootstrapHostKeys.
+func newBootstrapConnector(client ssh.Client, machineConfig *cloudinit.MachineConfig) (bootstrapConnector, error) {
+	operatingSystem, err := version.GetOSFromSeries(machineConfig.Series)
+	if err != nil {
+		return nil, err
+	}
+	switch operatingSystem {
+	case version.Ubuntu:
+		return newSSHConnector(client, bootstrapSSHUser(machineConfig)), nil
+	case version.Windows:
+		user, password := machineConfig.BootstrapWinRMUser, machineConfig.BootstrapWinRMPassword
+		if password == "" {
+			return nil, fmt.Errorf("cannot bootstrap %s: bootstrap-winrm-password is not set", machineConfig.Series)
+		}
+		return &winrmConnector{
+			client: &winrm.Client{User: user, Password: password},
+			certs:  newExpectedHostKeys(machineConfig.InstanceId, machineConfig.BootstrapHostKeys),
+			run:    runPowerShell,
+		}, nil
+	}
+	return nil, fmt.Errorf("cannot bootstrap %s: unsupported operating system", machineConfig.Series)
+}
+
+// sshConnector connects to Ubuntu machines over SSH.
+type sshConnector struct {
+	client ssh.Client
+	user   string
+
+	// connect is taken from connectSSH when the connector is made,
+	// as Run may be called from goroutines that outlive a test that
+	// patches it.
+	connect func(client ssh.Client, user, host, script string) error
+}
+
+func newSSHConnector(client ssh.Client, user string) *sshConnector {
+	return &sshConnector{client, user, connectSSH}
+}
+
+// Port is part of the bootstrapConnector interface.
+func (c *sshConnector) Port() int {
+	return 22
+}
+
+// Run is part of the bootstrapConnector interface.
+func (c *sshConnector) Run(host, script string) error {
+	return c.connect(c.client, c.user, host, script)
+}
+
+// CheckNonceScript is part of the bootstrapConnector interface.
+//
+// The script also blocks sshinit from proceeding until cloud-init has
+// completed, which is necessary to ensure apt invocations don't
+// trample each other.
+func (c *sshConnector) CheckNonceScript(machineConfig *cloudinit.MachineConfig) string {
+	nonceFile := utils.ShQuote(path.Join(machineConfig.DataDir, cloudinit.NonceFile))
+	return fmt.Sprintf(`
+	noncefile=%s
+	if [ ! -e "$noncefile" ]; then
+		echo "$noncefile does not exist" >&2
+		exit 1
+	fi
+	content=$(cat $noncefile)
+	if [ "$content" != %s ]; then
+		echo "$noncefile contents do not match machine nonce" >&2
+		exit 1
+	fi
+	`, nonceFile, utils.ShQuote(machineConfig.MachineNonce))
+}
+
+// CheckMachine is part of the bootstrapConnector interface.
+func (c *sshConnector) CheckMachine(host string, machineConfig *cloudinit.MachineConfig) error {
+	if mirrorURL, ok := machineConfig.Config.BootstrapMirrorCheckURL(); ok {
+		proxySettings := machineConfig.Config.AptProxySettings()
+		proxySettings.NoProxy = machineConfig.Config.NoProxy()
+		if err := checkMirrorReachable(c.client, c.user, host, mirrorURL, proxySettings); err != nil {
+			return err
+		}
+	}
+	expected, _ := machineConfig.Config.BootstrapCloudInitVersion()
+	installed, err := checkCloudInitVersion(c.client, c.user, host, expected)
+	if err != nil {
+		return err
+	}
+	// ConfigureMachine chooses its directives to suit this version.
+	machineConfig.CloudInitVersion = installed
+	return nil
+}
+
+// ConfigureMachine is part of the bootstrapConnector interface.
+func (c *sshConnector) ConfigureMachine(ctx environs.BootstrapContext, host string, machineConfig *cloudinit.MachineConfig) error {
+	return ConfigureMachine(ctx, c.client, host, machineConfig)
+}
+
+// CloudInitStatus is part of the bootstrapConnector interface.
+func (c *sshConnector) CloudInitStatus(host string) (*cloudInitStatus, error) {
+	output, err := cloudInitStatusOutput(c.client, c.user, host)
+	if err != nil {
+		return nil, err
+	}
+	return parseCloudInitStatus(output), nil
+}
+
+// runPowerShell is called to run a PowerShell script on a Windows
+// machine over WinRM.
+var runPowerShell = func(client *winrm.Client, host, script string) error {
+	_, err := client.RunPowerShell(host, script)
+	return err
+}
+
+// winrmConnector connects to Windows machines over WinRM.
+type winrmConnector struct {
+	client *winrm.Client
+
+	// certs holds the PEM-encoded certificates that the machine's
+	// WinRM listener is expected to present.
+	certs expectedHostKeys
+
+	// run is taken from runPowerShell when the connector is made,
+	// for the same reason as sshConnector.connect.
+	run func(client *winrm.Client, host, script string) error
+}
+
+// Port is part of the bootstrapConnector interface.
+func (c *winrmConnector) Port() int {
+	return winrm.DefaultPort
+}
+
+// Run is part of the bootstrapConnector interface. If no certificate
+// is held for host, the machine's certificate must be signed by a
+// trusted authority.
+func (c *winrmConnector) Run(host, script string) error {
+	return c.runCancelable(host, script, nil)
+}
+
+// runCancelable is like Run, but abandons the script once cancel is
+// closed, returning winrm.ErrCancelled.
+func (c *winrmConnector) runCancelable(host, script string, cancel <-chan struct{}) error {
+	client := *c.client
+	client.Cancel = cancel
+	if cert := c.certs.lookup(host); cert != "" {
+		serverCert, err := winrm.ParseCertificate(cert)
+		if err != nil {
+			return fmt.Errorf("invalid certificate for %s: %v", host, err)
+		}
+		client.ServerCert = serverCert
+	}
+	return c.run(&client, host, script)
+}
+
+// CheckNonceScript is part of the bootstrapConnector interface.
+func (c *winrmConnector) CheckNonceScript(machineConfig *cloudinit.MachineConfig) string {
+	nonceFile := psQuote(strings.Replace(path.Join(machineConfig.DataDir, cloudinit.NonceFile), "/", `\`, -1))
+	// The nonce is written to the file as a quoted string; see
+	// windowsConfigure.ConfigureBasic.
+	return fmt.Sprintf(`
+$noncefile = %s
+if (!(Test-Path $noncefile)) {
+	[Console]::Error.WriteLine("$noncefile does not exist")
+	exit 1
+}
+if ((Get-Content $noncefile | Out-String).Trim() -ne %s) {
+	[Console]::Error.WriteLine("$noncefile contents do not match machine nonce")
+	exit 1
+}
+`, nonceFile, psQuote(utils.ShQuote(machineConfig.MachineNonce)))
+}
+
+// CheckMachine is part of the bootstrapConnector interface. Windows
+// machines have no package mirror or cloud-init to check.
+func (c *winrmConnector) CheckMachine(host string, machineConfig *cloudinit.MachineConfig) error {
+	return nil
+}
+
+// ConfigureMachine is part of the bootstrapConnector interface.
+func (c *winrmConnector) ConfigureMachine(ctx environs.BootstrapContext, host string, machineConfig *cloudinit.MachineConfig) error {
+	udata, err := cloudinit.NewUserdataConfig(machineConfig, coreCloudinit.New())
+	if err != nil {
+		return err
+	}
+	if err := udata.ConfigureJuju(); err != nil {
+		return err
+	}
+	script, err := udata.Render()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.GetStderr(), "Running configuration script on %s over WinRM\n", host)
+	// As over SSH, the configuration is abandoned if the bootstrap
+	// deadline passes or the bootstrap is cancelled while it runs.
+	cancelled := bootstrapCancelled(ctx)
+	cancel, stop := anyClosed(bootstrapExpired(ctx), cancelled)
+	defer stop()
+	err = c.runCancelable(host, string(script), cancel)
+	if err == winrm.ErrCancelled {
+		select {
+		case <-cancelled:
+			return environs.ErrBootstrapCancelled
+		default:
+		}
+	}
+	return err
+}
+
+// CloudInitStatus is part of the bootstrapConnector interface. Windows
+// machines are initialised by cloudbase-init, which does not report
+// its status.
+func (c *winrmConnector) CloudInitStatus(host string) (*cloudInitStatus, error) {
+	return nil, nil
+}
+
+// psQuote quotes s as a literal PowerShell string.
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"fmt"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/constraints"
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/provider/common"
+	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/tools"
+	"github.com/juju/juju/utils/ssh"
+	"github.com/juju/juju/utils/winrm"
+	"github.com/juju/juju/version"
+)
+
+type ConnectorSuite struct {
+	coretesting.FakeJujuHomeSuite
+}
+
+var _ = gc.Suite(&ConnectorSuite{})
+
+func windowsMachineConfig(c *gc.C, attrs map[string]interface{}) *cloudinit.MachineConfig {
+	machineConfig, err := environs.NewBootstrapMachineConfig(constraints.Value{}, "win2012r2")
+	c.Assert(err, gc.IsNil)
+	machineConfig.InstanceId = "i-bootstrap"
+	machineConfig.Tools = &tools.Tools{
+		Version: version.MustParseBinary("1.2.3-win2012r2-amd64"),
+		URL:     "http://example.com/tools.tar.gz",
+	}
+	attrs["admin-secret"] = "sekrit"
+	cfg, err := minimalConfig(c).Apply(attrs)
+	c.Assert(err, gc.IsNil)
+	err = environs.FinishMachineConfig(machineConfig, cfg)
+	c.Assert(err, gc.IsNil)
+	return machineConfig
+}
+
+func (s *ConnectorSuite) TestWindowsNeedsPassword(c *gc.C) {
+	machineConfig := windowsMachineConfig(c, map[string]interface{}{})
+	_, err := common.NewBootstrapConnector(ssh.DefaultClient, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "cannot bootstrap win2012r2: bootstrap-winrm-password is not set")
+}
+
+func (s *ConnectorSuite) TestFinishBootstrapWindows(c *gc.C) {
+	machineConfig := windowsMachineConfig(c, map[string]interface{}{
+		"bootstrap-winrm-user":     "juju",
+		"bootstrap-winrm-password": "s3cret",
+	})
+	s.PatchValue(common.ConnectSSH, func(_ ssh.Client, user, host, checkHostScript string) error {
+		c.Fatalf("Windows machine reached over SSH")
+		return nil
+	})
+	var scripts []string
+	s.PatchValue(common.RunPowerShell, func(client *winrm.Client, host, script string) error {
+		c.Check(client.User, gc.Equals, "juju")
+		c.Check(client.Password, gc.Equals, "s3cret")
+		c.Check(host, gc.Equals, "0.1.2.3")
+		scripts = append(scripts, script)
+		return nil
+	})
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{addresses: network.NewAddresses("0.1.2.3")},
+	}
+	ctx := coretesting.Context(c)
+	err := common.FinishBootstrap(ctx, ssh.DefaultClient, inst, machineConfig)
+	// The nonce is checked over WinRM, but Windows machines cannot
+	// yet be configured as state servers.
+	c.Assert(err, gc.ErrorMatches, "Bootstrap node is not supported on Windows.")
+	c.Assert(scripts, gc.HasLen, 1)
+	c.Assert(scripts[0], jc.Contains, `$noncefile = 'C:\Juju\lib\juju\nonce.txt'`)
+	c.Assert(scripts[0], jc.Contains, fmt.Sprintf(`-ne '''%s'''`, machineConfig.MachineNonce))
+	c.Assert(coretesting.Stderr(ctx), gc.Equals,
+		"Waiting for address\n"+
+			"Attempting to connect to 0.1.2.3:5986\n")
+}
+
+func (s *ConnectorSuite) TestWindowsNonceCheckFails(c *gc.C) {
+	machineConfig := windowsMachineConfig(c, map[string]interface{}{
+		"bootstrap-winrm-password": "s3cret",
+	})
+	s.PatchValue(common.RunPowerShell, func(client *winrm.Client, host, script string) error {
+		c.Check(client.User, gc.Equals, "Administrator")
+		return fmt.Errorf("nonce mismatch")
+	})
+	conn, err := common.NewBootstrapConnector(ssh.DefaultClient, machineConfig)
+	c.Assert(err, gc.IsNil)
+	err = conn.Run("0.1.2.3", conn.CheckNonceScript(machineConfig))
+	c.Assert(err, gc.ErrorMatches, "nonce mismatch")
+}
+
+func (s *ConnectorSuite) TestWindowsPinsServerCertificate(c *gc.C) {
+	machineConfig := windowsMachineConfig(c, map[string]interface{}{
+		"bootstrap-winrm-password": "s3cret",
+	})
+	machineConfig.BootstrapHostKeys = map[string]string{"i-bootstrap": coretesting.ServerCert}
+	expect, err := winrm.ParseCertificate(coretesting.ServerCert)
+	c.Assert(err, gc.IsNil)
+	s.PatchValue(common.RunPowerShell, func(client *winrm.Client, host, script string) error {
+		c.Check(client.ServerCert, gc.NotNil)
+		c.Check(client.ServerCert.Raw, gc.DeepEquals, expect.Raw)
+		return nil
+	})
+	conn, err := common.NewBootstrapConnector(ssh.DefaultClient, machineConfig)
+	c.Assert(err, gc.IsNil)
+	err = conn.Run("0.1.2.3", conn.CheckNonceScript(machineConfig))
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *ConnectorSuite) TestWindowsInvalidServerCertificate(c *gc.C) {
+	machineConfig := windowsMachineConfig(c, map[string]interface{}{
+		"bootstrap-winrm-password": "s3cret",
+	})
+	machineConfig.BootstrapHostKeys = map[string]string{"0.1.2.3": "not a certificate"}
+	s.PatchValue(common.RunPowerShell, func(client *winrm.Client, host, script string) error {
+		c.Fatalf("WinRM reached without a valid certificate")
+		return nil
+	})
+	conn, err := common.NewBootstrapConnector(ssh.DefaultClient, machineConfig)
+	c.Assert(err, gc.IsNil)
+	err = conn.Run("0.1.2.3", conn.CheckNonceScript(machineConfig))
+	c.Assert(err, gc.ErrorMatches, "invalid certificate for 0.1.2.3: no PEM-encoded certificate found")
+}
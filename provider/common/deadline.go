@@ -0,0 +1,242 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/instance"
+)
+
+// bootstrapDeadline bounds the whole of a bootstrap. It records the
+// phase of bootstrap in progress, so that it can be reported if the
+// deadline passes, and interrupts anything waiting for an interrupt
+// when it does.
+type bootstrapDeadline struct {
+	clock    clock
+	timeout  time.Duration
+	deadline time.Time
+
+	// done is closed when the deadline passes.
+	done chan struct{}
+
+	mu         sync.Mutex
+	phase      string
+	expired    bool
+	finished   bool
+	interrupts map[chan<- os.Signal]bool
+}
+
+func newBootstrapDeadline(timeout time.Duration) *bootstrapDeadline {
+	clock := bootstrapClock
+	return &bootstrapDeadline{
+		clock:      clock,
+		timeout:    timeout,
+		deadline:   clock.Now().Add(timeout),
+		done:       make(chan struct{}),
+		interrupts: make(map[chan<- os.Signal]bool),
+	}
+}
+
+// setPhase records the phase of bootstrap now in progress.
+func (d *bootstrapDeadline) setPhase(phase string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.phase = phase
+}
+
+// after returns a channel that receives when the deadline passes.
+func (d *bootstrapDeadline) after() <-chan time.Time {
+	return d.clock.After(d.deadline.Sub(d.clock.Now()))
+}
+
+// expire interrupts everything waiting for an interrupt, and returns
+// an error naming the phase in progress. If the bootstrap has already
+// finished, nothing is interrupted and nil is returned.
+func (d *bootstrapDeadline) expire() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.finished {
+		return nil
+	}
+	if !d.expired {
+		d.expired = true
+		close(d.done)
+	}
+	for c := range d.interrupts {
+		interrupt(c)
+	}
+	return d.err()
+}
+
+// finish records that the bootstrap has got past the point at which
+// the deadline can abandon it. An error is returned if the deadline
+// has already passed.
+func (d *bootstrapDeadline) finish() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.expired {
+		return d.err()
+	}
+	d.finished = true
+	return nil
+}
+
+// err returns the error reported when the deadline passes. It must be
+// called with d.mu held.
+func (d *bootstrapDeadline) err() error {
+	return fmt.Errorf("bootstrap did not complete within %v: deadline passed while %s", d.timeout, d.phase)
+}
+
+// context returns a BootstrapContext that delegates to ctx, but
+// through which the deadline may interrupt the bootstrap and its phase
+// be recorded.
+func (d *bootstrapDeadline) context(ctx environs.BootstrapContext) environs.BootstrapContext {
+	return &deadlineContext{ctx, d}
+}
+
+// interrupt delivers an interrupt on c if it is ready to receive one.
+func interrupt(c chan<- os.Signal) {
+	select {
+	case c <- os.Interrupt:
+	default:
+	}
+}
+
+// deadlineContext is the BootstrapContext returned by
+// bootstrapDeadline.context.
+type deadlineContext struct {
+	environs.BootstrapContext
+	deadline *bootstrapDeadline
+}
+
+// InterruptNotify implements environs.BootstrapContext.InterruptNotify.
+func (ctx *deadlineContext) InterruptNotify(c chan<- os.Signal) {
+	ctx.BootstrapContext.InterruptNotify(c)
+	d := ctx.deadline
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.interrupts[c] = true
+	if d.expired {
+		interrupt(c)
+	}
+}
+
+// StopInterruptNotify implements environs.BootstrapContext.StopInterruptNotify.
+func (ctx *deadlineContext) StopInterruptNotify(c chan<- os.Signal) {
+	ctx.BootstrapContext.StopInterruptNotify(c)
+	d := ctx.deadline
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.interrupts, c)
+}
+
+// WrappedBootstrapContext implements
+// environs.WrapperBootstrapContext.WrappedBootstrapContext.
+func (ctx *deadlineContext) WrappedBootstrapContext() environs.BootstrapContext {
+	return ctx.BootstrapContext
+}
+
+// bootstrapDeadlineOf returns the deadline that ctx, or any context
+// it wraps, is subject to, or nil if there is none.
+func bootstrapDeadlineOf(ctx environs.BootstrapContext) *bootstrapDeadline {
+	for _, ctx := range contextChain(ctx) {
+		if ctx, ok := ctx.(*deadlineContext); ok {
+			return ctx.deadline
+		}
+	}
+	return nil
+}
+
+// setBootstrapPhase records the phase of bootstrap now in progress,
+// if ctx is subject to a deadline.
+func setBootstrapPhase(ctx environs.BootstrapContext, phase string) {
+	if d := bootstrapDeadlineOf(ctx); d != nil {
+		d.setPhase(phase)
+	}
+}
+
+// bootstrapExpired returns the channel that is closed when the deadline
+// ctx is subject to passes, or nil if it is not subject to a deadline.
+func bootstrapExpired(ctx environs.BootstrapContext) <-chan struct{} {
+	if d := bootstrapDeadlineOf(ctx); d != nil {
+		return d.done
+	}
+	return nil
+}
+
+// finishBootstrap records that the bootstrap ctx is subject to has got
+// past the point at which its deadline, if any, can abandon it. An
+// error is returned if the deadline has already passed, in which case
+// the bootstrap must go no further.
+func finishBootstrap(ctx environs.BootstrapContext) error {
+	if d := bootstrapDeadlineOf(ctx); d != nil {
+		return d.finish()
+	}
+	return nil
+}
+
+// bootstrapCancelled returns the channel that is closed when the
+// bootstrap should be abandoned, if ctx or any context it wraps
+// implements environs.CancelBootstrapContext, or nil otherwise.
+func bootstrapCancelled(ctx environs.BootstrapContext) <-chan struct{} {
+	for _, ctx := range contextChain(ctx) {
+		if ctx, ok := ctx.(environs.CancelBootstrapContext); ok {
+			return ctx.Cancelled()
+		}
+	}
+	return nil
+}
+
+// contextChain returns ctx followed by each context it wraps in turn,
+// as revealed by environs.WrapperBootstrapContext.
+func contextChain(ctx environs.BootstrapContext) []environs.BootstrapContext {
+	var chain []environs.BootstrapContext
+	for ctx != nil {
+		chain = append(chain, ctx)
+		wrapper, ok := ctx.(environs.WrapperBootstrapContext)
+		if !ok {
+			break
+		}
+		ctx = wrapper.WrappedBootstrapContext()
+	}
+	return chain
+}
+
+// anyClosed returns a channel that is closed once either a or b is
+// closed. Either may be nil, in which case the other is returned. The
+// returned function must be called once the channel is no longer
+// needed.
+func anyClosed(a, b <-chan struct{}) (<-chan struct{}, func()) {
+	switch {
+	case a == nil:
+		return b, func() {}
+	case b == nil:
+		return a, func() {}
+	}
+	closed := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-a:
+		case <-b:
+		case <-stop:
+			return
+		}
+		close(closed)
+	}()
+	return closed, func() { close(stop) }
+}
+
+// stopBootstrapInstance stops the given bootstrap instance after
+// bootstrap has been abandoned, logging any failure.
+func stopBootstrapInstance(env environs.Environ, inst instance.Instance) {
+	if err := env.StopInstances(inst.Id()); err != nil {
+		logger.Errorf("cannot stop abandoned bootstrap instance %s: %v", inst.Id(), err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"time"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/provider/common"
+	coretesting "github.com/juju/juju/testing"
+)
+
+type DeadlineSuite struct {
+	coretesting.BaseSuite
+}
+
+var _ = gc.Suite(&DeadlineSuite{})
+
+func (s *DeadlineSuite) TestWrappedDeadlineContext(c *gc.C) {
+	ctx, expire := common.NewDeadlineContext(coretesting.Context(c), time.Minute)
+	wrapped := environs.WithCancel(ctx, make(chan struct{}))
+
+	common.SetBootstrapPhase(wrapped, "doing things")
+	expired := common.BootstrapExpired(wrapped)
+	c.Assert(expired, gc.NotNil)
+	err := expire()
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within 1m0s: deadline passed while doing things")
+	select {
+	case <-expired:
+	default:
+		c.Fatalf("deadline passing not seen through the wrapping context")
+	}
+	err = common.FinishBootstrapDeadline(wrapped)
+	c.Assert(err, gc.ErrorMatches, "bootstrap did not complete within 1m0s: deadline passed while doing things")
+}
+
+func (s *DeadlineSuite) TestNoDeadline(c *gc.C) {
+	ctx := environs.WithCancel(coretesting.Context(c), make(chan struct{}))
+	common.SetBootstrapPhase(ctx, "doing things")
+	c.Assert(common.BootstrapExpired(ctx), gc.IsNil)
+	c.Assert(common.FinishBootstrapDeadline(ctx), gc.IsNil)
+}
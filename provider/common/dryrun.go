@@ -0,0 +1,114 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/juju/juju/constraints"
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/config"
+	coretools "github.com/juju/juju/tools"
+)
+
+// BootstrapPlan describes the instance Bootstrap would provision, as
+// worked out by BootstrapDryRun.
+type BootstrapPlan struct {
+	// Series is the series of the bootstrap instance.
+	Series string
+
+	// Arch is the architecture of the bootstrap instance, if it is
+	// already determined; otherwise it is empty, and the provider
+	// chooses one of Arches when the instance is started.
+	Arch string
+
+	// Arches holds every architecture for which there are tools.
+	Arches []string
+
+	// Tools holds the tools the instance could be started with.
+	Tools coretools.List
+
+	// Constraints and Placement are those the instance would be
+	// started with.
+	Constraints constraints.Value
+	Placement   string
+
+	// Prechecked records whether the environ checked that an instance
+	// with the plan's series, constraints and placement could be
+	// started. If it is false, the environ cannot check.
+	Prechecked bool
+}
+
+// String returns a description of the plan suited to showing the user.
+func (p *BootstrapPlan) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Bootstrap would launch a %s instance\n", p.Series)
+	if p.Arch != "" {
+		fmt.Fprintf(&buf, " - architecture: %s\n", p.Arch)
+	} else {
+		fmt.Fprintf(&buf, " - architecture: one of %s, chosen by the provider\n", strings.Join(p.Arches, ", "))
+	}
+	if cons := p.Constraints.String(); cons != "" {
+		fmt.Fprintf(&buf, " - constraints: %s\n", cons)
+	}
+	if p.Placement != "" {
+		fmt.Fprintf(&buf, " - placement: %s\n", p.Placement)
+	}
+	fmt.Fprintf(&buf, " - tools: %s\n", p.Tools)
+	if p.Prechecked {
+		fmt.Fprintf(&buf, " - the provider accepts the series, constraints and placement\n")
+	} else {
+		fmt.Fprintf(&buf, " - the provider cannot check the series, constraints and placement\n")
+	}
+	return buf.String()
+}
+
+// instancePrechecker is implemented by environs that can check, before
+// starting an instance, that it could be started; see state.Prechecker.
+type instancePrechecker interface {
+	PrecheckInstance(series string, cons constraints.Value, placement string) error
+}
+
+// BootstrapDryRun checks that Bootstrap would be able to start the
+// bootstrap instance, without starting it: that there are tools for the
+// environment's series and any architecture constraint, and that the
+// environ, if it is able to check, accepts the constraints and
+// placement. It returns a plan describing the instance that would be
+// started.
+func BootstrapDryRun(ctx environs.BootstrapContext, env environs.Environ, args environs.BootstrapParams) (*BootstrapPlan, error) {
+	series := config.PreferredSeries(env.Config())
+	filter := coretools.Filter{Series: series}
+	if args.Constraints.Arch != nil {
+		filter.Arch = *args.Constraints.Arch
+	}
+	availableTools, err := args.AvailableTools.Match(filter)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bootstrapSSHClient(); err != nil {
+		return nil, err
+	}
+	if _, err := environs.NewBootstrapMachineConfig(args.Constraints, series); err != nil {
+		return nil, err
+	}
+	plan := &BootstrapPlan{
+		Series:      series,
+		Arches:      availableTools.Arches(),
+		Tools:       availableTools,
+		Constraints: args.Constraints,
+		Placement:   args.Placement,
+	}
+	if len(plan.Arches) == 1 {
+		plan.Arch = plan.Arches[0]
+	}
+	if prechecker, ok := env.(instancePrechecker); ok {
+		if err := prechecker.PrecheckInstance(series, args.Constraints, args.Placement); err != nil {
+			return nil, fmt.Errorf("cannot bootstrap: %v", err)
+		}
+		plan.Prechecked = true
+	}
+	return plan, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"fmt"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/constraints"
+	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/cloudinit"
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/provider/common"
+	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/tools"
+	"github.com/juju/juju/version"
+)
+
+// precheckEnviron is a mockEnviron that can check instances before
+// they are started.
+type precheckEnviron struct {
+	*mockEnviron
+	precheck func(series string, cons constraints.Value, placement string) error
+}
+
+func (env *precheckEnviron) PrecheckInstance(series string, cons constraints.Value, placement string) error {
+	return env.precheck(series, cons, placement)
+}
+
+// dryRunEnviron returns an environ that fails the test if it is asked
+// to start an instance.
+func (s *BootstrapSuite) dryRunEnviron(c *gc.C) *mockEnviron {
+	return &mockEnviron{
+		storage: newStorage(s, c),
+		config:  configGetter(c),
+		startInstance: func(
+			_ string, _ constraints.Value, _ []string, _ tools.List, _ *cloudinit.MachineConfig,
+		) (
+			instance.Instance, *instance.HardwareCharacteristics, []network.Info, error,
+		) {
+			c.Fatalf("instance started during dry run")
+			return nil, nil, nil, nil
+		},
+	}
+}
+
+// seriesTools returns tools for the given architectures, of the
+// series the environment would be bootstrapped with.
+func seriesTools(c *gc.C, arches ...string) tools.List {
+	series := config.PreferredSeries(minimalConfig(c))
+	var list tools.List
+	for _, arch := range arches {
+		list = append(list, &tools.Tools{
+			Version: version.MustParseBinary(fmt.Sprintf("1.2.3-%s-%s", series, arch)),
+		})
+	}
+	return list
+}
+
+func (s *BootstrapSuite) TestBootstrapDryRun(c *gc.C) {
+	env := s.dryRunEnviron(c)
+	plan, err := common.BootstrapDryRun(coretesting.Context(c), env, environs.BootstrapParams{
+		Constraints:    constraints.MustParse("mem=4G"),
+		AvailableTools: seriesTools(c, "amd64", "arm64"),
+	})
+	c.Assert(err, gc.IsNil)
+	series := config.PreferredSeries(env.Config())
+	c.Assert(plan.Series, gc.Equals, series)
+	c.Assert(plan.Arch, gc.Equals, "")
+	c.Assert(plan.Arches, jc.SameContents, []string{"amd64", "arm64"})
+	c.Assert(plan.Tools, gc.HasLen, 2)
+	c.Assert(plan.Prechecked, jc.IsFalse)
+	c.Assert(plan.String(), gc.Matches, fmt.Sprintf(`Bootstrap would launch a %s instance
+ - architecture: one of (amd64, arm64|arm64, amd64), chosen by the provider
+ - constraints: mem=4096M
+ - tools: .*
+ - the provider cannot check the series, constraints and placement
+`, series))
+}
+
+func (s *BootstrapSuite) TestBootstrapDryRunArchConstraint(c *gc.C) {
+	env := s.dryRunEnviron(c)
+	plan, err := common.BootstrapDryRun(coretesting.Context(c), env, environs.BootstrapParams{
+		Constraints:    constraints.MustParse("arch=arm64"),
+		AvailableTools: seriesTools(c, "amd64", "arm64"),
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(plan.Arch, gc.Equals, "arm64")
+	c.Assert(plan.Tools, gc.HasLen, 1)
+	c.Assert(plan.String(), jc.Contains, " - architecture: arm64\n")
+}
+
+func (s *BootstrapSuite) TestBootstrapDryRunNoTools(c *gc.C) {
+	env := s.dryRunEnviron(c)
+	_, err := common.BootstrapDryRun(coretesting.Context(c), env, environs.BootstrapParams{
+		Constraints:    constraints.MustParse("arch=ppc64el"),
+		AvailableTools: seriesTools(c, "amd64"),
+	})
+	c.Assert(err, gc.Equals, tools.ErrNoMatches)
+}
+
+func (s *BootstrapSuite) TestBootstrapDryRunPrechecked(c *gc.C) {
+	cons := constraints.MustParse("mem=4G")
+	var checked bool
+	env := &precheckEnviron{
+		mockEnviron: s.dryRunEnviron(c),
+		precheck: func(series string, gotCons constraints.Value, placement string) error {
+			c.Check(series, gc.Equals, config.PreferredSeries(minimalConfig(c)))
+			c.Check(gotCons, gc.DeepEquals, cons)
+			c.Check(placement, gc.Equals, "zone=a")
+			checked = true
+			return nil
+		},
+	}
+	plan, err := common.BootstrapDryRun(coretesting.Context(c), env, environs.BootstrapParams{
+		Constraints:    cons,
+		Placement:      "zone=a",
+		AvailableTools: seriesTools(c, "amd64"),
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(checked, jc.IsTrue)
+	c.Assert(plan.Prechecked, jc.IsTrue)
+	c.Assert(plan.Arch, gc.Equals, "amd64")
+	c.Assert(plan.String(), jc.Contains, " - placement: zone=a\n")
+	c.Assert(plan.String(), jc.Contains, " - the provider accepts the series, constraints and placement\n")
+}
+
+func (s *BootstrapSuite) TestBootstrapDryRunPrecheckFails(c *gc.C) {
+	env := &precheckEnviron{
+		mockEnviron: s.dryRunEnviron(c),
+		precheck: func(series string, cons constraints.Value, placement string) error {
+			return fmt.Errorf("unknown availability zone %q", placement)
+		},
+	}
+	_, err := common.BootstrapDryRun(coretesting.Context(c), env, environs.BootstrapParams{
+		Placement:      "zone=z",
+		AvailableTools: seriesTools(c, "amd64"),
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot bootstrap: unknown availability zone "zone=z"`)
+}
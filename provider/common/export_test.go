@@ -3,8 +3,41 @@
 
 package common
 
+import (
+	"time"
+
+	"github.com/juju/juju/environs"
+)
+
 var (
 	ConnectSSH                          = &connectSSH
+	LookupHost                          = &lookupHost
+	RunConfigureScript                  = &runConfigureScript
 	WaitSSH                             = waitSSH
+	PostBootstrap                       = postBootstrap
 	InternalAvailabilityZoneAllocations = &internalAvailabilityZoneAllocations
+	CloudInitVersion                    = &cloudInitVersion
+	CheckCloudInitVersion               = checkCloudInitVersion
+	CheckMirrorReachable                = checkMirrorReachable
+	NewHostKeyClient                    = newHostKeyClient
+	NewSSHConnector                     = newSSHConnector
+	NewBootstrapConnector               = newBootstrapConnector
+	RunPowerShell                       = &runPowerShell
+	CloudInitStatusOutput               = &cloudInitStatusOutput
+	JitteredDelay                       = jitteredDelay
+	BootstrapClock                      = &bootstrapClock
+	NewJumpHostClient                   = newJumpHostClient
+	JumpHostProxyCommand                = jumpHostProxyCommand
+	BootstrapSSHClient                  = bootstrapSSHClient
+	SetBootstrapPhase                   = setBootstrapPhase
+	BootstrapExpired                    = bootstrapExpired
+	FinishBootstrapDeadline             = finishBootstrap
 )
+
+// NewDeadlineContext returns a context that delegates to ctx and is
+// subject to a bootstrap deadline of the given timeout, and a function
+// that makes the deadline pass.
+func NewDeadlineContext(ctx environs.BootstrapContext, timeout time.Duration) (environs.BootstrapContext, func() error) {
+	deadline := newBootstrapDeadline(timeout)
+	return deadline.context(ctx), deadline.expire
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"strings"
+
+	"github.com/juju/juju/instance"
+	"github.com/juju/juju/utils/ssh"
+)
+
+// expectedHostKeys holds the keys that the bootstrap instance is
+// expected to present at each of its addresses: SSH host keys, or
+// for Windows instances the certificates of their WinRM listeners.
+type expectedHostKeys struct {
+	// instanceKey holds the key expected of the instance
+	// at any address for which no key is known.
+	instanceKey string
+
+	// keys maps addresses to their expected keys.
+	keys map[string]string
+}
+
+// newExpectedHostKeys returns the keys expected of the instance with
+// the given id, taken from keys, which maps addresses or instance ids
+// to keys. A key held for an address takes precedence over one held
+// for the instance.
+func newExpectedHostKeys(instId instance.Id, keys map[string]string) expectedHostKeys {
+	return expectedHostKeys{
+		instanceKey: keys[string(instId)],
+		keys:        keys,
+	}
+}
+
+// lookup returns the key expected of the given [user@]host, or ""
+// if any key will do.
+func (k expectedHostKeys) lookup(host string) string {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if key, ok := k.keys[host]; ok {
+		return key
+	}
+	return k.instanceKey
+}
+
+// hostKeyClient is an ssh.Client that requires hosts to present the
+// host keys it has been given, so that the bootstrap instance is not
+// trusted on first use.
+type hostKeyClient struct {
+	ssh.Client
+	keys expectedHostKeys
+}
+
+// newHostKeyClient returns a client that requires the instance with
+// the given id to present the host key held for it in keys, which
+// maps addresses or instance ids to host keys in authorized_keys
+// format, as described for newExpectedHostKeys. If no key is held
+// for an address, any host key is accepted there, as by the client
+// itself.
+func newHostKeyClient(client ssh.Client, instId instance.Id, keys map[string]string) ssh.Client {
+	if len(keys) == 0 {
+		return client
+	}
+	return &hostKeyClient{
+		Client: client,
+		keys:   newExpectedHostKeys(instId, keys),
+	}
+}
+
+// withHostKey returns a copy of options that requires the given host
+// key, if it is not empty.
+func withHostKey(options *ssh.Options, key string) *ssh.Options {
+	if key == "" {
+		return options
+	}
+	var opts ssh.Options
+	if options != nil {
+		opts = *options
+	}
+	opts.SetHostKey(key)
+	return &opts
+}
+
+// Command implements ssh.Client.Command.
+func (c *hostKeyClient) Command(host string, command []string, options *ssh.Options) *ssh.Cmd {
+	return c.Client.Command(host, command, withHostKey(options, c.keys.lookup(host)))
+}
+
+// Copy implements ssh.Client.Copy. The host key required is that of
+// the first remote path in args.
+func (c *hostKeyClient) Copy(args []string, options *ssh.Options) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if i := strings.Index(arg, ":"); i > 0 {
+			return c.Client.Copy(args, withHostKey(options, c.keys.lookup(arg[:i])))
+		}
+	}
+	return c.Client.Copy(args, options)
+}
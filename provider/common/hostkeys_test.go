@@ -0,0 +1,118 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"fmt"
+
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/network"
+	"github.com/juju/juju/provider/common"
+	coretesting "github.com/juju/juju/testing"
+	"github.com/juju/juju/utils/ssh"
+	sshtesting "github.com/juju/juju/utils/ssh/testing"
+)
+
+// realConnectSSH holds connectSSH as it was before any test patched it.
+var realConnectSSH = *common.ConnectSSH
+
+// patchSSHHostKey replaces ssh with a script that behaves as a host
+// presenting the given key would: it fails host key verification if
+// it is given a known hosts file that holds any other key, and
+// otherwise succeeds. It returns a client that runs the script.
+func (s *BootstrapSuite) patchSSHHostKey(c *gc.C, hostKey string) ssh.Client {
+	testing.PatchExecutable(c, s, "ssh", fmt.Sprintf(`#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in "UserKnownHostsFile "*) known="${arg#UserKnownHostsFile }";; esac
+done
+if [ -n "$known" ] && [ "$(cat "$known")" != "* %s" ]; then
+	echo "Host key verification failed." >&2
+	exit 255
+fi
+`, hostKey))
+	testing.PatchExecutable(c, s, "scp", "#!/bin/sh\n")
+	client, err := ssh.NewOpenSSHClient()
+	c.Assert(err, gc.IsNil)
+	return client
+}
+
+func (s *BootstrapSuite) TestHostKeyClientMatch(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	client = common.NewHostKeyClient(client, "i-bootstrap", map[string]string{
+		"0.1.2.3": sshtesting.ValidKeyOne.Key,
+	})
+	err := realConnectSSH(client, "ubuntu", "0.1.2.3", "true")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestHostKeyClientMismatch(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	client = common.NewHostKeyClient(client, "i-bootstrap", map[string]string{
+		"0.1.2.3": sshtesting.ValidKeyTwo.Key,
+	})
+	err := realConnectSSH(client, "ubuntu", "0.1.2.3", "true")
+	c.Assert(err, gc.ErrorMatches, "Host key verification failed.")
+}
+
+func (s *BootstrapSuite) TestHostKeyClientInstanceKey(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	client = common.NewHostKeyClient(client, "i-bootstrap", map[string]string{
+		"0.1.2.3":     sshtesting.ValidKeyOne.Key,
+		"i-bootstrap": sshtesting.ValidKeyTwo.Key,
+	})
+	// The key for the address takes precedence over the instance's.
+	err := realConnectSSH(client, "ubuntu", "0.1.2.3", "true")
+	c.Assert(err, gc.IsNil)
+	// The instance's key is required at any other address.
+	err = realConnectSSH(client, "ubuntu", "0.1.2.4", "true")
+	c.Assert(err, gc.ErrorMatches, "Host key verification failed.")
+}
+
+func (s *BootstrapSuite) TestHostKeyClientNoKey(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	client = common.NewHostKeyClient(client, "i-bootstrap", map[string]string{
+		"0.1.2.4": sshtesting.ValidKeyTwo.Key,
+	})
+	// With no key for the address or instance, any key is accepted.
+	err := realConnectSSH(client, "ubuntu", "0.1.2.3", "true")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestHostKeyClientIPv6(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	client = common.NewHostKeyClient(client, "i-bootstrap", map[string]string{
+		"2001:db8::1": sshtesting.ValidKeyOne.Key,
+		"i-bootstrap": sshtesting.ValidKeyTwo.Key,
+	})
+	// The key is held for the address, which is given to the client
+	// unbracketed.
+	err := realConnectSSH(client, "ubuntu", "2001:db8::1", "true")
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *BootstrapSuite) TestFinishBootstrapHostKeyMismatch(c *gc.C) {
+	client := s.patchSSHHostKey(c, sshtesting.ValidKeyOne.Key)
+	s.PatchValue(common.ConnectSSH, realConnectSSH)
+	machineConfig := bootstrapMachineConfig(c)
+	machineConfig.BootstrapHostKeys = map[string]string{
+		"i-bootstrap": sshtesting.ValidKeyTwo.Key,
+	}
+	var err error
+	machineConfig.Config, err = machineConfig.Config.Apply(map[string]interface{}{
+		"bootstrap-timeout":         1,
+		"bootstrap-retry-delay":     1,
+		"bootstrap-addresses-delay": 1,
+	})
+	c.Assert(err, gc.IsNil)
+	inst := &refreshingInstance{
+		mockInstance: mockInstance{
+			id:        "i-bootstrap",
+			addresses: network.NewAddresses("0.1.2.3"),
+		},
+	}
+	err = common.FinishBootstrap(coretesting.Context(c), client, inst, machineConfig)
+	c.Assert(err, gc.ErrorMatches, "waited for .* without being able to connect: Host key verification failed.")
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common
+
+import (
+	"net"
+
+	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/utils/ssh"
+)
+
+// jumpHostClient is an ssh.Client that reaches every host through a
+// jump host, for networks in which the bootstrap instance cannot be
+// reached directly. Each connection is tunnelled by a proxy command
+// that runs ssh on the jump host, so the commands run on the far side
+// are exactly those that would be run over a direct connection.
+type jumpHostClient struct {
+	ssh.Client
+
+	// proxyCommand is the command that connects to the jump host
+	// and forwards the connection to the target host.
+	proxyCommand []string
+}
+
+// newJumpHostClient returns a client that connects through the jump
+// host given in cfg, or client itself if none is configured.
+func newJumpHostClient(client ssh.Client, cfg *config.Config) ssh.Client {
+	host, user, keyFile := cfg.BootstrapSSHJumpHost()
+	if host == "" {
+		return client
+	}
+	return &jumpHostClient{
+		Client:       client,
+		proxyCommand: jumpHostProxyCommand(host, user, keyFile),
+	}
+}
+
+// jumpHostProxyCommand returns the command that connects to the jump
+// host, given as host[:port], as user with the private key in keyFile,
+// and forwards the connection to the target host. %h and %p are
+// replaced with the target host and port by the client.
+func jumpHostProxyCommand(host, user, keyFile string) []string {
+	command := []string{"ssh", "-o", "BatchMode yes"}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		host = h
+		command = append(command, "-p", port)
+	}
+	if keyFile != "" {
+		command = append(command, "-i", keyFile)
+	}
+	if user != "" {
+		host = user + "@" + host
+	}
+	return append(command, "-W", "%h:%p", host)
+}
+
+// withProxyCommand returns a copy of options that proxies through the
+// given command.
+func withProxyCommand(options *ssh.Options, command []string) *ssh.Options {
+	var opts ssh.Options
+	if options != nil {
+		opts = *options
+	}
+	opts.SetProxyCommand(command...)
+	return &opts
+}
+
+// Command implements ssh.Client.Command.
+func (c *jumpHostClient) Command(host string, command []string, options *ssh.Options) *ssh.Cmd {
+	return c.Client.Command(host, command, withProxyCommand(options, c.proxyCommand))
+}
+
+// Copy implements ssh.Client.Copy.
+func (c *jumpHostClient) Copy(args []string, options *ssh.Options) error {
+	return c.Client.Copy(args, withProxyCommand(options, c.proxyCommand))
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package common_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/provider/common"
+	"github.com/juju/juju/utils/ssh"
+)
+
+var jumpHostProxyCommandTests = []struct {
+	host, user, keyFile string
+	expect              []string
+}{{
+	host:   "bastion.example.com",
+	expect: []string{"ssh", "-o", "BatchMode yes", "-W", "%h:%p", "bastion.example.com"},
+}, {
+	host:    "bastion.example.com:2222",
+	user:    "admin",
+	keyFile: "/home/ubuntu/.ssh/bastion",
+	expect: []string{
+		"ssh", "-o", "BatchMode yes", "-p", "2222", "-i", "/home/ubuntu/.ssh/bastion",
+		"-W", "%h:%p", "admin@bastion.example.com",
+	},
+}, {
+	host:   "[2001:db8::1]:2222",
+	user:   "admin",
+	expect: []string{"ssh", "-o", "BatchMode yes", "-p", "2222", "-W", "%h:%p", "admin@2001:db8::1"},
+}}
+
+func (s *BootstrapSuite) TestJumpHostProxyCommand(c *gc.C) {
+	for i, test := range jumpHostProxyCommandTests {
+		c.Logf("test %d: %s", i, test.host)
+		command := common.JumpHostProxyCommand(test.host, test.user, test.keyFile)
+		c.Check(command, gc.DeepEquals, test.expect)
+	}
+}
+
+func (s *BootstrapSuite) TestNewJumpHostClientNone(c *gc.C) {
+	client := common.NewJumpHostClient(ssh.DefaultClient, minimalConfig(c))
+	c.Assert(client, gc.Equals, ssh.DefaultClient)
+}
+
+func (s *BootstrapSuite) TestJumpHostClientCommand(c *gc.C) {
+	// The fake ssh records its arguments, one per line.
+	argsFile := filepath.Join(c.MkDir(), "args")
+	testing.PatchExecutable(c, s, "ssh", fmt.Sprintf("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done > %s\n", argsFile))
+	testing.PatchExecutable(c, s, "scp", "#!/bin/sh\n")
+	openssh, err := ssh.NewOpenSSHClient()
+	c.Assert(err, gc.IsNil)
+	cfg, err := minimalConfig(c).Apply(map[string]interface{}{
+		"bootstrap-ssh-jump-host": "bastion.example.com:2222",
+		"bootstrap-ssh-jump-user": "admin",
+	})
+	c.Assert(err, gc.IsNil)
+	client := common.NewJumpHostClient(openssh, cfg)
+
+	err = realConnectSSH(client, "ubuntu", "10.0.0.1", "true")
+	c.Assert(err, gc.IsNil)
+	data, err := ioutil.ReadFile(argsFile)
+	c.Assert(err, gc.IsNil)
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var proxyCommand string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "ProxyCommand ") {
+			proxyCommand = arg
+		}
+	}
+	c.Assert(proxyCommand, gc.Matches, `ProxyCommand ssh -o .?BatchMode yes.? -p 2222 -W .?%h:%p.? admin@bastion\.example\.com`)
+	// The target is unchanged; only the route to it differs.
+	c.Assert(args[len(args)-2:], gc.DeepEquals, []string{"ubuntu@10.0.0.1", "/bin/bash"})
+}
@@ -330,7 +330,7 @@ func (e *environ) Storage() storage.Storage {
 }
 
 func (e *environ) Bootstrap(ctx environs.BootstrapContext, args environs.BootstrapParams) (arch, series string, _ environs.BootstrapFinalizer, _ error) {
-	return common.Bootstrap(ctx, e, args)
+	return common.BootstrapValues(common.Bootstrap(ctx, e, args))
 }
 
 func (e *environ) StateServerInstances() ([]instance.Id, error) {
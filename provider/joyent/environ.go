@@ -149,7 +149,7 @@ func (env *joyentEnviron) Storage() storage.Storage {
 }
 
 func (env *joyentEnviron) Bootstrap(ctx environs.BootstrapContext, args environs.BootstrapParams) (arch, series string, _ environs.BootstrapFinalizer, _ error) {
-	return common.Bootstrap(ctx, env, args)
+	return common.BootstrapValues(common.Bootstrap(ctx, env, args))
 }
 
 func (env *joyentEnviron) StateServerInstances() ([]instance.Id, error) {
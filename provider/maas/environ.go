@@ -86,7 +86,7 @@ func NewEnviron(cfg *config.Config) (*maasEnviron, error) {
 
 // Bootstrap is specified in the Environ interface.
 func (env *maasEnviron) Bootstrap(ctx environs.BootstrapContext, args environs.BootstrapParams) (arch, series string, _ environs.BootstrapFinalizer, _ error) {
-	return common.Bootstrap(ctx, env, args)
+	return common.BootstrapValues(common.Bootstrap(ctx, env, args))
 }
 
 // StateServerInstances is specified in the Environ interface.
@@ -720,7 +720,7 @@ func (e *environ) Bootstrap(ctx environs.BootstrapContext, args environs.Bootstr
 	if err := authenticateClient(e); err != nil {
 		return "", "", nil, err
 	}
-	return common.Bootstrap(ctx, e, args)
+	return common.BootstrapValues(common.Bootstrap(ctx, e, args))
 }
 
 func (e *environ) StateServerInstances() ([]instance.Id, error) {
@@ -1,12 +1,21 @@
 package jsoncodec
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"io/ioutil"
 	"net"
+	"sync/atomic"
 
 	"code.google.com/p/go.net/websocket"
 )
 
+// CompressionHeader is the HTTP header with which a client asks for
+// the messages on an API websocket connection to be compressed. Its
+// value names the compression, of which only "gzip" is supported.
+const CompressionHeader = "X-Juju-Rpc-Compression"
+
 // NewWebsocket returns an rpc codec that uses the given websocket
 // connection to send and receive messages.
 func NewWebsocket(conn *websocket.Conn) *Codec {
@@ -29,6 +38,75 @@ func (conn wsJSONConn) Close() error {
 	return conn.conn.Close()
 }
 
+// gzipMagic holds the bytes that start every gzip stream. A JSON
+// message can never start with them.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewCompressedWebsocket returns an rpc codec that uses the given
+// websocket connection to send and receive gzip-compressed messages.
+// Uncompressed messages are accepted too. If compress is false,
+// messages are sent uncompressed until a compressed message has
+// been received, so a client that has asked for compression can
+// still talk to a server that does not support it.
+func NewCompressedWebsocket(conn *websocket.Conn, compress bool) *Codec {
+	wsConn := &wsGzipJSONConn{conn: conn}
+	if compress {
+		wsConn.compress = 1
+	}
+	return New(wsConn)
+}
+
+type wsGzipJSONConn struct {
+	conn *websocket.Conn
+	// compress is non-zero when sent messages should be
+	// compressed. It is accessed atomically as messages are
+	// sent and received concurrently.
+	compress int32
+}
+
+func (conn *wsGzipJSONConn) Send(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&conn.compress) == 0 {
+		return websocket.Message.Send(conn.conn, string(data))
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return websocket.Message.Send(conn.conn, buf.Bytes())
+}
+
+func (conn *wsGzipJSONConn) Receive(msg interface{}) error {
+	var data []byte
+	if err := websocket.Message.Receive(conn.conn, &data); err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return err
+		}
+		// The other side compresses, so it can read
+		// compressed messages too.
+		atomic.StoreInt32(&conn.compress, 1)
+	}
+	return json.Unmarshal(data, msg)
+}
+
+func (conn *wsGzipJSONConn) Close() error {
+	return conn.conn.Close()
+}
+
 // NewNet returns an rpc codec that uses the given net
 // connection to send and receive messages.
 func NewNet(conn net.Conn) *Codec {
@@ -0,0 +1,107 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package jsoncodec_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+
+	"code.google.com/p/go.net/websocket"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/rpc"
+	"github.com/juju/juju/rpc/jsoncodec"
+)
+
+// serveEcho starts a websocket server that replies to each request
+// sent to it with the request's parameter, using the codec returned
+// by newCodec, and returns a connection to it.
+func serveEcho(c *gc.C, newCodec func(*websocket.Conn) *jsoncodec.Codec) (*websocket.Conn, func()) {
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		codec := newCodec(conn)
+		defer codec.Close()
+		for {
+			var hdr rpc.Header
+			if err := codec.ReadHeader(&hdr); err != nil {
+				return
+			}
+			var body value
+			if err := codec.ReadBody(&body, true); err != nil {
+				return
+			}
+			if err := codec.WriteMessage(&rpc.Header{RequestId: hdr.RequestId}, &body); err != nil {
+				return
+			}
+		}
+	}))
+	conn, err := websocket.Dial(strings.Replace(server.URL, "http:", "ws:", 1), "", "http://localhost/")
+	c.Assert(err, gc.IsNil)
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func newCompressedServerCodec(conn *websocket.Conn) *jsoncodec.Codec {
+	return jsoncodec.NewCompressedWebsocket(conn, true)
+}
+
+func assertRoundTrip(c *gc.C, codec *jsoncodec.Codec, requestId uint64, x string) {
+	err := codec.WriteMessage(&rpc.Header{
+		RequestId: requestId,
+		Request:   rpc.Request{Type: "foo", Action: "frob"},
+	}, &value{X: x})
+	c.Assert(err, gc.IsNil)
+	var hdr rpc.Header
+	err = codec.ReadHeader(&hdr)
+	c.Assert(err, gc.IsNil)
+	c.Assert(hdr.RequestId, gc.Equals, requestId)
+	var body value
+	err = codec.ReadBody(&body, false)
+	c.Assert(err, gc.IsNil)
+	c.Assert(body.X, gc.Equals, x)
+}
+
+func (*suite) TestCompressedWebsocketSendsGzip(c *gc.C) {
+	conn, cleanup := serveEcho(c, newCompressedServerCodec)
+	defer cleanup()
+
+	// An uncompressed request is accepted, and the reply is compressed.
+	err := websocket.Message.Send(conn, `{"RequestId": 1, "Type": "foo", "Request": "frob", "Params": {"X": "param"}}`)
+	c.Assert(err, gc.IsNil)
+	var data []byte
+	err = websocket.Message.Receive(conn, &data)
+	c.Assert(err, gc.IsNil)
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	c.Assert(err, gc.IsNil)
+	data, err = ioutil.ReadAll(r)
+	c.Assert(err, gc.IsNil)
+	assertJSONEqual(c, string(data), `{"RequestId": 1, "Response": {"X": "param"}}`)
+}
+
+func (*suite) TestCompressedWebsocketRoundTrip(c *gc.C) {
+	conn, cleanup := serveEcho(c, newCompressedServerCodec)
+	defer cleanup()
+
+	codec := jsoncodec.NewCompressedWebsocket(conn, false)
+	// The first request is sent uncompressed; later ones are
+	// compressed once the server has replied with compression.
+	assertRoundTrip(c, codec, 1, "first")
+	assertRoundTrip(c, codec, 2, strings.Repeat("second", 1000))
+	assertRoundTrip(c, codec, 3, "third")
+}
+
+func (*suite) TestCompressedWebsocketUncompressedServer(c *gc.C) {
+	// A server that knows nothing of compression cannot read
+	// compressed messages, so the client must never send any.
+	conn, cleanup := serveEcho(c, jsoncodec.NewWebsocket)
+	defer cleanup()
+
+	codec := jsoncodec.NewCompressedWebsocket(conn, false)
+	assertRoundTrip(c, codec, 1, "first")
+	assertRoundTrip(c, codec, 2, "second")
+}
@@ -5,8 +5,12 @@ package state
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/names"
+	"gopkg.in/juju/charm.v4"
+	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/mgo.v2/txn"
 )
 
@@ -22,6 +26,11 @@ type ActionReceiver interface {
 	// ActionReceiver.
 	AddAction(name string, payload map[string]interface{}) (*Action, error)
 
+	// AddActionWithOptions queues an action with the given name and
+	// payload for this ActionReceiver, with the given optional
+	// settings.
+	AddActionWithOptions(name string, payload map[string]interface{}, opts ActionOptions) (*Action, error)
+
 	// CancelAction removes a pending Action from the queue for this
 	// ActionReceiver and marks it as cancelled.
 	CancelAction(action *Action) (*ActionResult, error)
@@ -78,6 +87,129 @@ type actionDoc struct {
 	// Parameters holds the action's parameters, if any; it should validate
 	// against the schema defined by the named action in the unit's charm.
 	Parameters map[string]interface{} `bson:"parameters"`
+
+	// EffectiveParameters holds the parameters the action is run
+	// with: Parameters, with any defaults declared by the charm's
+	// action spec filled in. It is empty for actions added before
+	// effective parameters were recorded.
+	EffectiveParameters map[string]interface{} `bson:"effective-parameters,omitempty"`
+
+	// Enqueued is the time the action was added.
+	Enqueued time.Time `bson:"enqueued"`
+
+	// Started is the time the action began running, or the zero
+	// time if it has not yet started.
+	Started time.Time `bson:"started"`
+
+	// Retry, if not nil, holds the policy under which the action is
+	// run again when it fails.
+	Retry *ActionRetryPolicy `bson:"retry,omitempty"`
+
+	// Attempts records each earlier, failed, attempt to run the
+	// action, in order.
+	Attempts []ActionAttempt `bson:"attempts,omitempty"`
+
+	// NotBefore is the time before which the action should not be
+	// run again after a failed attempt, or the zero time.
+	NotBefore time.Time `bson:"not-before,omitempty"`
+
+	// Slot, if not nil, limits how many actions queued with the same
+	// slot may run at once; the action takes the slot when it begins
+	// and gives it up when it finishes.
+	Slot *ActionSlot `bson:"slot,omitempty"`
+
+	// Timeout, if not zero, is how long the action may run before
+	// the unit agent stops it.
+	Timeout time.Duration `bson:"timeout,omitempty"`
+}
+
+// ActionOptions holds the optional settings of an action.
+type ActionOptions struct {
+	// Retry, if not nil, is the policy under which the action is run
+	// again when it fails.
+	Retry *ActionRetryPolicy
+
+	// Slot, if not nil, limits how many actions queued with the same
+	// slot may run at once.
+	Slot *ActionSlot
+
+	// Timeout, if not zero, is how long the action may run before it
+	// is stopped.
+	Timeout time.Duration
+}
+
+// Validate returns an error if the options are not valid.
+func (o ActionOptions) Validate() error {
+	if o.Retry != nil {
+		if err := o.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Slot != nil {
+		if err := o.Slot.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Timeout < 0 {
+		return errors.Errorf("action timeout must not be negative, got %v", o.Timeout)
+	}
+	return nil
+}
+
+// ActionRetryPolicy describes how an action that fails is run again.
+type ActionRetryPolicy struct {
+	// MaxAttempts is the most times the action is run, including
+	// the first.
+	MaxAttempts int `bson:"max-attempts"`
+
+	// Backoff is the time waited before the first retry; it doubles
+	// for each retry after that, up to MaxActionRetryBackoff.
+	Backoff time.Duration `bson:"backoff"`
+}
+
+// MaxActionRetryBackoff is the longest time waited before a failed
+// action is run again, however many times it has failed.
+const MaxActionRetryBackoff = 24 * time.Hour
+
+// retryBackoff returns the time to wait before running an action
+// again after it has failed the given number of times. The backoff
+// is doubled rather than shifted so that it cannot overflow.
+func (p ActionRetryPolicy) retryBackoff(failures int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < failures && backoff < MaxActionRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxActionRetryBackoff {
+		backoff = MaxActionRetryBackoff
+	}
+	return backoff
+}
+
+// Validate returns an error if the policy is not valid.
+func (p ActionRetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.Errorf("retry policy must allow at least one attempt, got %d", p.MaxAttempts)
+	}
+	if p.Backoff < 0 {
+		return errors.Errorf("retry policy backoff must not be negative, got %v", p.Backoff)
+	}
+	return nil
+}
+
+// ActionAttempt records a failed attempt to run an action.
+type ActionAttempt struct {
+	// Started is the time the attempt began, or the zero time if
+	// that was not recorded.
+	Started time.Time `bson:"started"`
+
+	// Completed is the time the attempt failed.
+	Completed time.Time `bson:"completed"`
+
+	// Message captures any error returned by the attempt.
+	Message string `bson:"message"`
+
+	// Results are the structured results of the attempt.
+	Results map[string]interface{} `bson:"results"`
 }
 
 // Action represents an instruction to do some "action" and is expected
@@ -115,6 +247,96 @@ func (a *Action) Parameters() map[string]interface{} {
 	return a.doc.Parameters
 }
 
+// EffectiveParameters returns the parameters the action is run with:
+// those it was given, with any defaults declared by the charm's action
+// spec filled in.
+func (a *Action) EffectiveParameters() map[string]interface{} {
+	if a.doc.EffectiveParameters == nil {
+		return a.doc.Parameters
+	}
+	return a.doc.EffectiveParameters
+}
+
+// Enqueued returns the time the action was added.
+func (a *Action) Enqueued() time.Time {
+	return a.doc.Enqueued
+}
+
+// Started returns the time the action began running, or the zero
+// time if it has not yet started.
+func (a *Action) Started() time.Time {
+	return a.doc.Started
+}
+
+// RetryPolicy returns the policy under which the action is run again
+// when it fails, and whether it has one.
+func (a *Action) RetryPolicy() (ActionRetryPolicy, bool) {
+	if a.doc.Retry == nil {
+		return ActionRetryPolicy{}, false
+	}
+	return *a.doc.Retry, true
+}
+
+// Timeout returns how long the action may run before it is stopped, or
+// zero if it may run for as long as it needs.
+func (a *Action) Timeout() time.Duration {
+	return a.doc.Timeout
+}
+
+// Attempt returns the number of the current attempt to run the
+// action, starting at 1.
+func (a *Action) Attempt() int {
+	return len(a.doc.Attempts) + 1
+}
+
+// Attempts returns the earlier, failed, attempts to run the action.
+func (a *Action) Attempts() []ActionAttempt {
+	return a.doc.Attempts
+}
+
+// NotBefore returns the time before which the action should not be
+// run again after a failed attempt, or the zero time.
+func (a *Action) NotBefore() time.Time {
+	return a.doc.NotBefore
+}
+
+// Begin marks the action as having started running, and returns the
+// updated Action. If the action has a slot, it takes the slot;
+// ErrActionSlotFull is returned if the slot is full, in which case
+// the action should be begun again later.
+func (a *Action) Begin() (*Action, error) {
+	buildTxn := func(attempt int) ([]txn.Op, error) {
+		if attempt > 0 {
+			if _, err := a.st.ActionByTag(a.ActionTag()); errors.IsNotFound(err) {
+				return nil, errors.NotFoundf("pending action %q", a.Id())
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		ops := []txn.Op{{
+			C:      actionsC,
+			Id:     a.doc.DocId,
+			Assert: txn.DocExists,
+			Update: bson.D{{"$set", bson.D{{"started", nowToTheMillisecond()}}}},
+		}}
+		if a.doc.Slot != nil {
+			slotOps, err := a.acquireSlotOps()
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, slotOps...)
+		}
+		return ops, nil
+	}
+	err := a.st.run(buildTxn)
+	if err == ErrActionSlotFull || errors.IsNotFound(err) {
+		return nil, err
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot begin action %q", a.Id())
+	}
+	return a.st.ActionByTag(a.ActionTag())
+}
+
 // Tag implements the Entity interface and returns a names.Tag that
 // is a names.ActionTag.
 func (a *Action) Tag() names.Tag {
@@ -133,34 +355,125 @@ type ActionResults struct {
 	Status  ActionStatus           `json:"status"`
 	Results map[string]interface{} `json:"results"`
 	Message string                 `json:"message"`
+
+	// Usage, if not nil, records the resources the action consumed
+	// while it ran.
+	Usage *ActionUsage `json:"usage,omitempty"`
 }
 
 // Finish removes action from the pending queue and creates an
 // ActionResult to capture the output and end state of the action.
+// If the action failed and its retry policy allows another attempt,
+// the attempt is instead recorded and the action left queued to be
+// run again, and Finish returns a nil ActionResult.
 func (a *Action) Finish(results ActionResults) (*ActionResult, error) {
-	return a.removeAndLog(results.Status, results.Results, results.Message)
+	if results.Status == ActionFailed && a.doc.Retry != nil && a.Attempt() < a.doc.Retry.MaxAttempts {
+		return nil, a.retry(results)
+	}
+	return a.removeAndLog(results, nil)
+}
+
+// retry records a failed attempt to run the action, and makes it
+// ready to be run again once the backoff for the attempt has passed.
+func (a *Action) retry(results ActionResults) error {
+	now := nowToTheMillisecond()
+	attempt := ActionAttempt{
+		Started:   a.doc.Started,
+		Completed: now,
+		Message:   results.Message,
+		Results:   results.Results,
+	}
+	backoff := a.doc.Retry.retryBackoff(len(a.doc.Attempts) + 1)
+	// The slot, if any, is given up until the next attempt begins.
+	ops := []txn.Op{{
+		C:      actionsC,
+		Id:     a.doc.DocId,
+		Assert: txn.DocExists,
+		Update: bson.D{
+			{"$push", bson.D{{"attempts", attempt}}},
+			{"$set", bson.D{
+				{"started", time.Time{}},
+				{"not-before", now.Add(backoff)},
+			}},
+		},
+	}}
+	err := a.st.runTransaction(append(ops, a.releaseSlotOps()...))
+	if err == txn.ErrAborted {
+		return errors.NotFoundf("pending action %q", a.Id())
+	} else if err != nil {
+		return errors.Annotatef(err, "cannot retry action %q", a.Id())
+	}
+	return nil
+}
+
+// ErrActionRunning is returned by CancelPending when the action has
+// begun running.
+var ErrActionRunning = errors.New("action is running")
+
+// CancelPending removes the action from the pending queue as
+// cancelled, provided it is not running. The check is made in the same
+// transaction as the removal, so an action that begins in the meantime
+// is never cancelled; ErrActionRunning is returned instead.
+func (a *Action) CancelPending() (*ActionResult, error) {
+	notStarted := bson.D{{"started", time.Time{}}}
+	result, err := a.removeAndLog(ActionResults{Status: ActionCancelled}, notStarted)
+	if !errors.IsNotFound(err) {
+		return result, err
+	}
+	if _, err := a.st.ActionByTag(a.ActionTag()); err == nil {
+		return nil, ErrActionRunning
+	}
+	return nil, err
 }
 
 // removeAndLog takes the action off of the pending queue, and creates
-// an actionresult to capture the outcome of the action.
-func (a *Action) removeAndLog(finalStatus ActionStatus, results map[string]interface{}, message string) (*ActionResult, error) {
-	doc := newActionResultDoc(a, finalStatus, results, message)
-	err := a.st.runTransaction([]txn.Op{
+// an actionresult to capture the outcome of the action. It returns a
+// NotFound error if the action has already been finished, as when it
+// is aborted while running, or if assert, when not nil, does not hold
+// for the action document.
+func (a *Action) removeAndLog(results ActionResults, assert interface{}) (*ActionResult, error) {
+	doc := newActionResultDoc(a, results.Status, results.Results, results.Message)
+	doc.Usage = results.Usage
+	doc.Attempts = a.doc.Attempts
+	if assert == nil {
+		assert = txn.DocExists
+	}
+	ops := []txn.Op{
 		addActionResultOp(a.st, &doc),
 		{
 			C:      actionsC,
 			Id:     a.doc.DocId,
+			Assert: assert,
 			Remove: true,
 		},
-	})
-	if err != nil {
+	}
+	err := a.st.runTransaction(append(ops, a.releaseSlotOps()...))
+	if err == txn.ErrAborted {
+		return nil, errors.NotFoundf("pending action %q", a.Id())
+	} else if err != nil {
 		return nil, err
 	}
 	return a.st.ActionResultByTag(a.ActionTag())
 }
 
+// nowToTheMillisecond returns the current time in UTC to the nearest
+// millisecond, the finest resolution mongo stores. Actions record their
+// times this precisely so that short runs have meaningful durations.
+func nowToTheMillisecond() time.Time {
+	return time.Now().Round(time.Millisecond).UTC()
+}
+
 // newAction builds an Action for the given State and actionDoc.
 func newAction(st *State, adoc actionDoc) *Action {
+	// Times are loaded from mongo in the local time zone;
+	// normalise to UTC so actions compare consistently.
+	adoc.Enqueued = adoc.Enqueued.UTC()
+	adoc.Started = adoc.Started.UTC()
+	adoc.NotBefore = adoc.NotBefore.UTC()
+	for i := range adoc.Attempts {
+		adoc.Attempts[i].Started = adoc.Attempts[i].Started.UTC()
+		adoc.Attempts[i].Completed = adoc.Attempts[i].Completed.UTC()
+	}
 	return &Action{
 		st:  st,
 		doc: adoc,
@@ -183,9 +496,37 @@ func newActionDoc(st *State, ar ActionReceiver, actionName string, parameters ma
 		Sequence:   sequence,
 		Name:       actionName,
 		Parameters: parameters,
+		Enqueued:   nowToTheMillisecond(),
 	}, nil
 }
 
+// withActionDefaults returns the given parameters with the default
+// value of each parameter declared by spec, but not given, filled in.
+// It returns nil if there are no parameters at all.
+func withActionDefaults(spec charm.ActionSpec, parameters map[string]interface{}) map[string]interface{} {
+	// Parameters are declared under "properties", as in a JSON
+	// schema, but older charms declare them at the top level.
+	declared, ok := spec.Params["properties"].(map[string]interface{})
+	if !ok {
+		declared = spec.Params
+	}
+	effective := make(map[string]interface{})
+	for name, schema := range declared {
+		if schema, ok := schema.(map[string]interface{}); ok {
+			if value, ok := schema["default"]; ok {
+				effective[name] = value
+			}
+		}
+	}
+	for name, value := range parameters {
+		effective[name] = value
+	}
+	if len(effective) == 0 {
+		return nil
+	}
+	return effective
+}
+
 var ensureActionMarker = ensureSuffixFn(actionMarker)
 
 // actionIdFromTag converts an ActionTag to an actionId.
@@ -6,15 +6,20 @@ package state_test
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/names"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/txn"
 	"github.com/juju/utils/set"
 	gc "gopkg.in/check.v1"
+	"gopkg.in/juju/charm.v4"
 
+	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/state"
 	statetesting "github.com/juju/juju/state/testing"
+	coretesting "github.com/juju/juju/testing"
 )
 
 type ActionSuite struct {
@@ -215,8 +220,11 @@ func (s *ActionSuite) TestComplete(c *gc.C) {
 
 	// complete the action, and verify that it succeeds
 	output := map[string]interface{}{"output": "action ran successfully"}
+	before := time.Now().Add(-time.Second)
 	result, err := action.Finish(state.ActionResults{Status: state.ActionCompleted, Results: output})
 	c.Assert(err, gc.IsNil)
+	c.Assert(result.Completed().Before(before), jc.IsFalse)
+	c.Assert(result.Completed().After(time.Now().Add(time.Second)), jc.IsFalse)
 
 	// ensure we now have a result for this action
 	results, err = unit.ActionResults()
@@ -236,6 +244,315 @@ func (s *ActionSuite) TestComplete(c *gc.C) {
 	c.Assert(len(actions), gc.Equals, 0)
 }
 
+func (s *ActionSuite) TestFinishAlreadyFinished(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	action, err := s.State.Action(a.Id())
+	c.Assert(err, gc.IsNil)
+
+	// An action aborted while it runs is finished again by its unit.
+	_, err = a.Finish(state.ActionResults{Status: state.ActionAborted, Message: "aborted"})
+	c.Assert(err, gc.IsNil)
+	_, err = action.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	// The first outcome is the one kept.
+	result, err := s.State.ActionResultByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Status(), gc.Equals, state.ActionAborted)
+}
+
+func (s *ActionSuite) TestBegin(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Enqueued().IsZero(), jc.IsFalse)
+	c.Assert(a.Started().IsZero(), jc.IsTrue)
+
+	before := time.Now().Add(-time.Second)
+	a, err = a.Begin()
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Started().Before(before), jc.IsFalse)
+	c.Assert(a.Started().Before(a.Enqueued()), jc.IsFalse)
+
+	result, err := a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Enqueued(), gc.DeepEquals, a.Enqueued())
+	c.Assert(result.Started(), gc.DeepEquals, a.Started())
+	_, ok := result.Duration()
+	c.Assert(ok, jc.IsTrue)
+
+	// Once finished, the action can no longer be begun.
+	_, err = a.Begin()
+	c.Assert(err, gc.ErrorMatches, `pending action ".*" not found`)
+}
+
+func (s *ActionSuite) TestCancelPending(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	result, err := a.CancelPending()
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Status(), gc.Equals, state.ActionCancelled)
+
+	// Once cancelled, the action cannot be cancelled again.
+	_, err = a.CancelPending()
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *ActionSuite) TestCancelPendingRunning(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	// The action begins after it was read for cancellation.
+	_, err = a.Begin()
+	c.Assert(err, gc.IsNil)
+
+	_, err = a.CancelPending()
+	c.Assert(err, gc.Equals, state.ErrActionRunning)
+	_, err = s.State.ActionByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *ActionSuite) TestActionResultDuration(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	result, err := a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+
+	// The action was never begun, so its duration is unknown.
+	_, ok := result.Duration()
+	c.Assert(ok, jc.IsFalse)
+
+	started := time.Date(2014, 11, 5, 10, 0, 0, 0, time.UTC)
+	state.SetActionResultTimes(result, started, started.Add(90*time.Second))
+	duration, ok := result.Duration()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(duration, gc.Equals, 90*time.Second)
+}
+
+func (s *ActionSuite) TestActionTimesToTheMillisecond(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	a, err = a.Begin()
+	c.Assert(err, gc.IsNil)
+	result, err := a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+
+	// Times are kept to the millisecond, so a short run does not
+	// appear to take whole seconds.
+	for _, t := range []time.Time{result.Enqueued(), result.Started(), result.Completed()} {
+		c.Assert(t.Equal(t.Round(time.Millisecond)), jc.IsTrue)
+	}
+	duration, ok := result.Duration()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(duration < time.Second, jc.IsTrue)
+}
+
+func (s *ActionSuite) TestActionResultUsage(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	result, err := a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	_, ok := result.Usage()
+	c.Assert(ok, jc.IsFalse)
+
+	a, err = s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	usage := state.ActionUsage{CPUTime: 1500 * time.Millisecond, PeakMemory: 64 << 20}
+	_, err = a.Finish(state.ActionResults{Status: state.ActionCompleted, Usage: &usage})
+	c.Assert(err, gc.IsNil)
+	result, err = s.State.ActionResultByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	got, ok := result.Usage()
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(got, gc.Equals, usage)
+}
+
+func (s *ActionSuite) TestRetry(c *gc.C) {
+	a, err := s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{
+		Retry: &state.ActionRetryPolicy{MaxAttempts: 2, Backoff: time.Minute},
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Attempt(), gc.Equals, 1)
+
+	// The first failure is recorded, and the action queued again.
+	result, err := a.Finish(state.ActionResults{Status: state.ActionFailed, Message: "first"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.IsNil)
+	a, err = s.State.ActionByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Attempt(), gc.Equals, 2)
+	c.Assert(a.Attempts(), gc.HasLen, 1)
+	c.Assert(a.Attempts()[0].Message, gc.Equals, "first")
+	c.Assert(a.Started().IsZero(), jc.IsTrue)
+	c.Assert(a.NotBefore().Sub(a.Attempts()[0].Completed), gc.Equals, time.Minute)
+
+	// The last allowed attempt fails for good.
+	result, err = a.Finish(state.ActionResults{Status: state.ActionFailed, Message: "second"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.NotNil)
+	c.Assert(result.Status(), gc.Equals, state.ActionFailed)
+	c.Assert(result.Attempt(), gc.Equals, 2)
+	c.Assert(result.Attempts(), gc.HasLen, 1)
+	_, err = s.State.ActionByTag(a.ActionTag())
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *ActionSuite) TestRetryBackoffIsCapped(c *gc.C) {
+	a, err := s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{
+		Retry: &state.ActionRetryPolicy{MaxAttempts: 100, Backoff: time.Hour},
+	})
+	c.Assert(err, gc.IsNil)
+
+	// The backoff doubles with each failure until it reaches the cap.
+	expect := []time.Duration{
+		time.Hour, 2 * time.Hour, 4 * time.Hour, 8 * time.Hour, 16 * time.Hour,
+		state.MaxActionRetryBackoff, state.MaxActionRetryBackoff,
+	}
+	for i, backoff := range expect {
+		c.Logf("failure %d", i+1)
+		_, err := a.Finish(state.ActionResults{Status: state.ActionFailed})
+		c.Assert(err, gc.IsNil)
+		a, err = s.State.ActionByTag(a.ActionTag())
+		c.Assert(err, gc.IsNil)
+		completed := a.Attempts()[len(a.Attempts())-1].Completed
+		c.Assert(a.NotBefore().Sub(completed), gc.Equals, backoff)
+	}
+}
+
+func (s *ActionSuite) TestAddActionWithInvalidRetry(c *gc.C) {
+	_, err := s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{Retry: &state.ActionRetryPolicy{}})
+	c.Assert(err, gc.ErrorMatches, "cannot add action; retry policy must allow at least one attempt, got 0")
+}
+
+func (s *ActionSuite) TestSlot(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 2}
+	var actions []*state.Action
+	for _, unit := range []*state.Unit{s.unit, s.unit2, s.unit} {
+		a, err := unit.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+		c.Assert(err, gc.IsNil)
+		got, ok := a.Slot()
+		c.Assert(ok, jc.IsTrue)
+		c.Assert(got, gc.Equals, slot)
+		actions = append(actions, a)
+	}
+
+	// The first two actions take the slot; the third must wait.
+	for _, a := range actions[:2] {
+		_, err := a.Begin()
+		c.Assert(err, gc.IsNil)
+	}
+	waiting, err := actions[2].WaitingForSlot()
+	c.Assert(err, gc.IsNil)
+	c.Assert(waiting, jc.IsTrue)
+	_, err = actions[2].Begin()
+	c.Assert(err, gc.Equals, state.ErrActionSlotFull)
+	a, err := s.State.ActionByTag(actions[2].ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Started().IsZero(), jc.IsTrue)
+
+	// Beginning an action that holds the slot again is allowed.
+	_, err = actions[1].Begin()
+	c.Assert(err, gc.IsNil)
+
+	// Once an action finishes, the waiting one can begin.
+	_, err = actions[0].Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	waiting, err = actions[2].WaitingForSlot()
+	c.Assert(err, gc.IsNil)
+	c.Assert(waiting, jc.IsFalse)
+	_, err = actions[2].Begin()
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *ActionSuite) TestWatchSlot(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	first, err := s.unit.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	second, err := s.unit2.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+
+	w, err := second.WatchSlot()
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewNotifyWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	first, err = first.Begin()
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+
+	// The waiting action is told when the slot is released.
+	_, err = first.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+	statetesting.AssertStop(c, w)
+	wc.AssertClosed()
+}
+
+func (s *ActionSuite) TestWatchSlotWithoutSlot(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = a.WatchSlot()
+	c.Assert(err, gc.ErrorMatches, `action ".*" has no slot`)
+}
+
+func (s *ActionSuite) TestSlotReleasedOnRetry(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	retry := &state.ActionRetryPolicy{MaxAttempts: 2}
+	first, err := s.unit.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot, Retry: retry})
+	c.Assert(err, gc.IsNil)
+	second, err := s.unit2.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+
+	first, err = first.Begin()
+	c.Assert(err, gc.IsNil)
+	_, err = second.Begin()
+	c.Assert(err, gc.Equals, state.ErrActionSlotFull)
+
+	// The failed action gives up the slot until it is run again.
+	result, err := first.Finish(state.ActionResults{Status: state.ActionFailed})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result, gc.IsNil)
+	_, err = second.Begin()
+	c.Assert(err, gc.IsNil)
+	first, err = s.State.ActionByTag(first.ActionTag())
+	c.Assert(err, gc.IsNil)
+	_, err = first.Begin()
+	c.Assert(err, gc.Equals, state.ErrActionSlotFull)
+}
+
+func (s *ActionSuite) TestSlotReleasedOnCancel(c *gc.C) {
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	first, err := s.unit.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	second, err := s.unit2.AddActionWithOptions("restore", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	_, err = first.Begin()
+	c.Assert(err, gc.IsNil)
+	_, err = s.unit.CancelAction(first)
+	c.Assert(err, gc.IsNil)
+	_, err = second.Begin()
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *ActionSuite) TestTimeout(c *gc.C) {
+	a, err := s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{Timeout: time.Minute})
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Timeout(), gc.Equals, time.Minute)
+	a, err = s.State.ActionByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Timeout(), gc.Equals, time.Minute)
+
+	_, err = s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{Timeout: -time.Second})
+	c.Assert(err, gc.ErrorMatches, "cannot add action; action timeout must not be negative, got -1s")
+}
+
+func (s *ActionSuite) TestAddActionWithInvalidSlot(c *gc.C) {
+	_, err := s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{Slot: &state.ActionSlot{MaxConcurrent: 1}})
+	c.Assert(err, gc.ErrorMatches, "cannot add action; action slot must have a name")
+	_, err = s.unit.AddActionWithOptions("action1", nil, state.ActionOptions{Slot: &state.ActionSlot{Name: "restore"}})
+	c.Assert(err, gc.ErrorMatches, `cannot add action; action slot "restore" must allow at least one action, got 0`)
+}
+
 func (s *ActionSuite) TestUnitWatchActions(c *gc.C) {
 	// get units
 	unit1, err := s.State.Unit(s.unit.Name())
@@ -488,6 +805,184 @@ func (s *ActionSuite) TestWatchActions(c *gc.C) {
 	wc.AssertNoChange()
 }
 
+func (s *ActionSuite) TestWatchAllActions(c *gc.C) {
+	done, err := s.unit.AddAction("done", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = done.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	queued, err := s.unit.AddAction("queued", nil)
+	c.Assert(err, gc.IsNil)
+
+	w := s.State.WatchAllActions()
+	defer statetesting.AssertStop(c, w)
+	assertChange := func(expect ...params.ActionEvent) {
+		s.State.StartSync()
+		select {
+		case events, ok := <-w.Changes():
+			c.Assert(ok, jc.IsTrue)
+			c.Assert(events, jc.SameContents, expect)
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("watcher did not send change")
+		}
+	}
+	assertNoChange := func() {
+		s.State.StartSync()
+		select {
+		case events, ok := <-w.Changes():
+			c.Fatalf("watcher sent unexpected change: (%v, %v)", events, ok)
+		case <-time.After(coretesting.ShortWait):
+		}
+	}
+	event := func(tag names.ActionTag, name string, status state.ActionStatus) params.ActionEvent {
+		return params.ActionEvent{
+			Tag:      tag,
+			Receiver: tag.PrefixTag(),
+			Name:     name,
+			Status:   string(status),
+		}
+	}
+
+	// The initial event holds every known action.
+	assertChange(
+		event(done.ActionTag(), "done", state.ActionCompleted),
+		event(queued.ActionTag(), "queued", state.ActionPending),
+	)
+	assertNoChange()
+
+	// Actions queued on any receiver are reported.
+	other, err := s.unit2.AddAction("other", nil)
+	c.Assert(err, gc.IsNil)
+	assertChange(event(other.ActionTag(), "other", state.ActionPending))
+	assertNoChange()
+
+	// As are their results.
+	_, err = other.Finish(state.ActionResults{Status: state.ActionFailed})
+	c.Assert(err, gc.IsNil)
+	assertChange(event(other.ActionTag(), "other", state.ActionFailed))
+	assertNoChange()
+}
+
+func (s *ActionSuite) TestEffectiveParameters(c *gc.C) {
+	dummy := s.AddTestingService(c, "dummy", s.AddTestingCharm(c, "dummy"))
+	unit, err := dummy.AddUnit()
+	c.Assert(err, gc.IsNil)
+
+	// The snapshot action's outfile param defaults to "foo.bz2".
+	submitted := map[string]interface{}{"quality": 5}
+	a, err := unit.AddAction("snapshot", submitted)
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.Parameters(), jc.DeepEquals, submitted)
+	expected := map[string]interface{}{"outfile": "foo.bz2", "quality": 5}
+	c.Assert(a.EffectiveParameters(), jc.DeepEquals, expected)
+
+	a, err = s.State.ActionByTag(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.EffectiveParameters(), jc.DeepEquals, expected)
+
+	result, err := a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Parameters(), jc.DeepEquals, submitted)
+	c.Assert(result.EffectiveParameters(), jc.DeepEquals, expected)
+
+	// A submitted param takes precedence over the default.
+	a, err = unit.AddAction("snapshot", map[string]interface{}{"outfile": "bar.gz"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.EffectiveParameters(), jc.DeepEquals, map[string]interface{}{"outfile": "bar.gz"})
+}
+
+func (s *ActionSuite) TestEffectiveParametersNoSpec(c *gc.C) {
+	// wordpress declares no actions, so there are no defaults.
+	a, err := s.unit.AddAction("fakeaction", map[string]interface{}{"outfile": "foo.txt"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.EffectiveParameters(), jc.DeepEquals, a.Parameters())
+
+	a, err = s.unit.AddAction("fakeaction", nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(a.EffectiveParameters(), gc.IsNil)
+}
+
+func (s *ActionSuite) TestWithActionDefaults(c *gc.C) {
+	spec := charm.ActionSpec{
+		Params: map[string]interface{}{
+			"title": "Snapshot",
+			"type":  "object",
+			"properties": map[string]interface{}{
+				"outfile": map[string]interface{}{
+					"type":    "string",
+					"default": "foo.bz2",
+				},
+				"quality": map[string]interface{}{
+					"type": "integer",
+				},
+			},
+		},
+	}
+	c.Assert(state.WithActionDefaults(spec, nil), jc.DeepEquals, map[string]interface{}{
+		"outfile": "foo.bz2",
+	})
+	c.Assert(state.WithActionDefaults(spec, map[string]interface{}{"quality": 3}), jc.DeepEquals, map[string]interface{}{
+		"outfile": "foo.bz2",
+		"quality": 3,
+	})
+	c.Assert(state.WithActionDefaults(charm.ActionSpec{}, nil), gc.IsNil)
+}
+
+func (s *ActionSuite) TestWatchAction(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+
+	w, err := s.State.WatchAction(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+	wc := statetesting.NewNotifyWatcherC(c, s.State, w)
+	wc.AssertOneChange()
+
+	a, err = a.Begin()
+	c.Assert(err, gc.IsNil)
+	wc.AssertOneChange()
+
+	// The watcher stops itself after reporting that the action
+	// has finished.
+	_, err = a.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	s.State.StartSync()
+	assertWatcherFinished(c, w)
+	c.Assert(w.Err(), gc.IsNil)
+}
+
+func (s *ActionSuite) TestWatchActionFinished(c *gc.C) {
+	a, err := s.unit.AddAction("action1", nil)
+	c.Assert(err, gc.IsNil)
+	_, err = a.Finish(state.ActionResults{Status: state.ActionFailed})
+	c.Assert(err, gc.IsNil)
+
+	w, err := s.State.WatchAction(a.ActionTag())
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, w)
+	s.State.StartSync()
+	assertWatcherFinished(c, w)
+}
+
+func (s *ActionSuite) TestWatchActionNotFound(c *gc.C) {
+	tag := names.JoinActionTag(s.unit.Name(), 99)
+	_, err := s.State.WatchAction(tag)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err, gc.ErrorMatches, `action ".*" not found`)
+}
+
+// assertWatcherFinished asserts that w sends one more change and
+// then closes its Changes channel.
+func assertWatcherFinished(c *gc.C, w state.NotifyWatcher) {
+	for _, expectOpen := range []bool{true, false} {
+		select {
+		case _, ok := <-w.Changes():
+			c.Assert(ok, gc.Equals, expectOpen)
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("watcher did not send change")
+		}
+	}
+}
+
 func expectActionIds(u *state.Unit, suffixes ...string) []string {
 	ids := make([]string, len(suffixes))
 	prefix := state.EnsureActionMarker(u.Name())
@@ -545,6 +1040,10 @@ func (r mockAR) AddAction(name string, payload map[string]interface{}) (*state.A
 	return nil, nil
 }
 
+func (r mockAR) AddActionWithOptions(name string, payload map[string]interface{}, opts state.ActionOptions) (*state.Action, error) {
+	return nil, nil
+}
+
 func (r mockAR) CancelAction(*state.Action) (*state.ActionResult, error) { return nil, nil }
 func (r mockAR) WatchActions() state.StringsWatcher                      { return nil }
 func (r mockAR) WatchActionResults() state.StringsWatcher                { return nil }
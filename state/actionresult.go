@@ -6,6 +6,7 @@ package state
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/juju/names"
 	"gopkg.in/mgo.v2/txn"
@@ -24,6 +25,10 @@ const (
 	// ActionCancelled means that the Action was cancelled before being run.
 	ActionCancelled ActionStatus = "cancelled"
 
+	// ActionAborted means that the Action was stopped while it was
+	// running; it is a failure caused by the abort, not by the action.
+	ActionAborted ActionStatus = "aborted"
+
 	// ActionPending is the default status when an Action is first queued.
 	ActionPending ActionStatus = "pending"
 )
@@ -55,6 +60,12 @@ type actionResultDoc struct {
 	// when it was run.
 	Parameters map[string]interface{} `bson:"parameters"`
 
+	// EffectiveParameters holds the parameters the action was run
+	// with, including any defaults filled in from the charm's action
+	// spec. It is empty for actions added before effective parameters
+	// were recorded.
+	EffectiveParameters map[string]interface{} `bson:"effective-parameters,omitempty"`
+
 	// Status represents the end state of the Action; ActionFailed for an
 	// action that was removed prematurely, or that failed, and
 	// ActionCompleted for an action that successfully completed.
@@ -65,6 +76,35 @@ type actionResultDoc struct {
 
 	// Results are the structured results from the action.
 	Results map[string]interface{} `bson:"results"`
+
+	// Enqueued is the time the action was added.
+	Enqueued time.Time `bson:"enqueued"`
+
+	// Started is the time the action began running, or the zero
+	// time if it never started.
+	Started time.Time `bson:"started"`
+
+	// Completed is the time at which the action finished or was
+	// cancelled.
+	Completed time.Time `bson:"completed"`
+
+	// Usage holds the resources the action consumed while it ran,
+	// if the unit agent captured them.
+	Usage *ActionUsage `bson:"usage,omitempty"`
+
+	// Attempts records each earlier, failed, attempt to run the
+	// action, in order, for an action with a retry policy.
+	Attempts []ActionAttempt `bson:"attempts,omitempty"`
+}
+
+// ActionUsage records the resources consumed by an Action while it
+// ran.
+type ActionUsage struct {
+	// CPUTime is the processor time, user and system, consumed.
+	CPUTime time.Duration `bson:"cputime"`
+
+	// PeakMemory is the largest resident set size reached, in bytes.
+	PeakMemory uint64 `bson:"peakmemory"`
 }
 
 // ActionResult represents an instruction to do some "action" and is
@@ -101,16 +141,73 @@ func (a *ActionResult) Parameters() map[string]interface{} {
 	return a.doc.Parameters
 }
 
+// EffectiveParameters returns the parameters the action was run with,
+// including any defaults filled in from the charm's action spec.
+func (a *ActionResult) EffectiveParameters() map[string]interface{} {
+	if a.doc.EffectiveParameters == nil {
+		return a.doc.Parameters
+	}
+	return a.doc.EffectiveParameters
+}
+
 // Status returns the final state of the action.
 func (a *ActionResult) Status() ActionStatus {
 	return a.doc.Status
 }
 
+// Usage returns the resources the action consumed while it ran, and
+// whether they were captured.
+func (a *ActionResult) Usage() (ActionUsage, bool) {
+	if a.doc.Usage == nil {
+		return ActionUsage{}, false
+	}
+	return *a.doc.Usage, true
+}
+
+// Attempt returns the number of the attempt to run the action that
+// produced this result, starting at 1.
+func (a *ActionResult) Attempt() int {
+	return len(a.doc.Attempts) + 1
+}
+
+// Attempts returns the earlier, failed, attempts to run the action.
+func (a *ActionResult) Attempts() []ActionAttempt {
+	return a.doc.Attempts
+}
+
 // Results returns the structured output of the action and any error.
 func (a *ActionResult) Results() (map[string]interface{}, string) {
 	return a.doc.Results, a.doc.Message
 }
 
+// Enqueued returns the time the action was added.
+func (a *ActionResult) Enqueued() time.Time {
+	return a.doc.Enqueued
+}
+
+// Started returns the time the action began running, or the zero
+// time if it never started.
+func (a *ActionResult) Started() time.Time {
+	return a.doc.Started
+}
+
+// Duration returns how long the action took to run, from when it
+// started to when it completed. The boolean result is false if the
+// start or completion time of the action was not recorded.
+func (a *ActionResult) Duration() (time.Duration, bool) {
+	if a.doc.Started.IsZero() || a.doc.Completed.IsZero() {
+		return 0, false
+	}
+	return a.doc.Completed.Sub(a.doc.Started), true
+}
+
+// Completed returns the time at which the action finished or was
+// cancelled. It is the zero time for results recorded before this
+// was tracked.
+func (a *ActionResult) Completed() time.Time {
+	return a.doc.Completed
+}
+
 // Tag implements the Entity interface and returns a names.Tag that
 // is a names.ActionResultTag.
 func (a *ActionResult) Tag() names.Tag {
@@ -133,6 +230,15 @@ func (a *ActionResult) ActionTag() names.ActionTag {
 // newActionResult builds an ActionResult from the supplied state and
 // actionResultDoc.
 func newActionResult(st *State, adoc actionResultDoc) *ActionResult {
+	// Times are loaded from mongo in the local time zone;
+	// normalise to UTC so results compare consistently.
+	adoc.Enqueued = adoc.Enqueued.UTC()
+	adoc.Started = adoc.Started.UTC()
+	adoc.Completed = adoc.Completed.UTC()
+	for i := range adoc.Attempts {
+		adoc.Attempts[i].Started = adoc.Attempts[i].Started.UTC()
+		adoc.Attempts[i].Completed = adoc.Attempts[i].Completed.UTC()
+	}
 	return &ActionResult{
 		st:  st,
 		doc: adoc,
@@ -148,15 +254,19 @@ func newActionResultDoc(a *Action, finalStatus ActionStatus, results map[string]
 		panic(fmt.Sprintf("cannot convert actionId to actionResultId: %v", actionId))
 	}
 	return actionResultDoc{
-		DocId:      a.st.docID(id),
-		EnvUUID:    a.doc.EnvUUID,
-		Receiver:   a.doc.Receiver,
-		Sequence:   a.doc.Sequence,
-		Name:       a.doc.Name,
-		Parameters: a.doc.Parameters,
-		Status:     finalStatus,
-		Results:    results,
-		Message:    message,
+		DocId:               a.st.docID(id),
+		EnvUUID:             a.doc.EnvUUID,
+		Receiver:            a.doc.Receiver,
+		Sequence:            a.doc.Sequence,
+		Name:                a.doc.Name,
+		Parameters:          a.doc.Parameters,
+		EffectiveParameters: a.doc.EffectiveParameters,
+		Status:              finalStatus,
+		Results:             results,
+		Message:             message,
+		Enqueued:            a.doc.Enqueued,
+		Started:             a.doc.Started,
+		Completed:           nowToTheMillisecond(),
 	}
 }
 
@@ -170,6 +280,16 @@ func convertActionIdToActionResultId(actionId string) (string, bool) {
 	return actionResultId, true
 }
 
+// convertActionResultIdToActionId builds the id of the Action whose
+// result has the given actionResultId.
+func convertActionResultIdToActionId(actionResultId string) (string, bool) {
+	parts := strings.Split(actionResultId, actionResultMarker)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return strings.Join(parts, actionMarker), true
+}
+
 // actionResultPrefix returns a string prefix for matching action results for
 // the given ActionReceiver.
 func actionResultPrefix(ar ActionReceiver) string {
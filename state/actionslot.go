@@ -0,0 +1,162 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"strconv"
+
+	"github.com/juju/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+)
+
+// ErrActionSlotFull is returned when an Action cannot begin because the
+// slot it is limited by is already held by as many running actions as
+// the slot allows.
+var ErrActionSlotFull = errors.New("action slot full")
+
+// ActionSlot names a limit on how many actions may run at once across
+// the environment. Every action queued with a slot of the same name
+// counts against the limit.
+type ActionSlot struct {
+	// Name identifies the slot.
+	Name string `bson:"name"`
+
+	// MaxConcurrent is the most actions that may hold the slot at
+	// once.
+	MaxConcurrent int `bson:"max-concurrent"`
+}
+
+// Validate returns an error if the slot is not valid.
+func (s ActionSlot) Validate() error {
+	if s.Name == "" {
+		return errors.Errorf("action slot must have a name")
+	}
+	if s.MaxConcurrent < 1 {
+		return errors.Errorf("action slot %q must allow at least one action, got %d", s.Name, s.MaxConcurrent)
+	}
+	return nil
+}
+
+// actionSlotDoc records the actions holding a slot.
+type actionSlotDoc struct {
+	DocId   string `bson:"_id"`
+	EnvUUID string `bson:"env-uuid"`
+	Name    string `bson:"name"`
+
+	// Holders holds the ids of the actions holding the slot.
+	Holders []string `bson:"holders"`
+}
+
+// actionSlot returns the document recording the holders of the named
+// slot, or nil if no action has yet held it.
+func (st *State) actionSlot(name string) (*actionSlotDoc, error) {
+	slots, closer := st.getCollection(actionSlotsC)
+	defer closer()
+	var doc actionSlotDoc
+	err := slots.FindId(st.docID(name)).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "cannot get action slot %q", name)
+	}
+	return &doc, nil
+}
+
+// Slot returns the slot limiting how many actions like this one may
+// run at once, and whether it has one.
+func (a *Action) Slot() (ActionSlot, bool) {
+	if a.doc.Slot == nil {
+		return ActionSlot{}, false
+	}
+	return *a.doc.Slot, true
+}
+
+// WaitingForSlot reports whether the action has not begun and cannot
+// yet, because its slot is held by as many other actions as it allows.
+func (a *Action) WaitingForSlot() (bool, error) {
+	if a.doc.Slot == nil || !a.doc.Started.IsZero() {
+		return false, nil
+	}
+	doc, err := a.st.actionSlot(a.doc.Slot.Name)
+	if err != nil || doc == nil {
+		return false, err
+	}
+	if holdsSlot(doc, a.Id()) {
+		return false, nil
+	}
+	return len(doc.Holders) >= a.doc.Slot.MaxConcurrent, nil
+}
+
+// WatchSlot returns a watcher that notifies when the actions holding
+// the action's slot change, so that an action waiting for its slot can
+// try again to begin. An error is returned if the action has no slot.
+func (a *Action) WatchSlot() (NotifyWatcher, error) {
+	if a.doc.Slot == nil {
+		return nil, errors.Errorf("action %q has no slot", a.Id())
+	}
+	return newEntityWatcher(a.st, actionSlotsC, a.st.docID(a.doc.Slot.Name)), nil
+}
+
+// acquireSlotOps returns the operations needed for the action to take
+// its slot, which are none if it already holds it. ErrActionSlotFull is
+// returned if the slot is full.
+func (a *Action) acquireSlotOps() ([]txn.Op, error) {
+	slot := a.doc.Slot
+	doc, err := a.st.actionSlot(slot.Name)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []txn.Op{{
+			C:      actionSlotsC,
+			Id:     a.st.docID(slot.Name),
+			Assert: txn.DocMissing,
+			Insert: actionSlotDoc{
+				DocId:   a.st.docID(slot.Name),
+				EnvUUID: a.st.EnvironTag().Id(),
+				Name:    slot.Name,
+				Holders: []string{a.Id()},
+			},
+		}}, nil
+	}
+	if holdsSlot(doc, a.Id()) {
+		return nil, nil
+	}
+	if len(doc.Holders) >= slot.MaxConcurrent {
+		return nil, ErrActionSlotFull
+	}
+	// The slot is only taken if it still has room when the
+	// transaction runs.
+	lastHolder := "holders." + strconv.Itoa(slot.MaxConcurrent-1)
+	return []txn.Op{{
+		C:      actionSlotsC,
+		Id:     doc.DocId,
+		Assert: bson.D{{lastHolder, bson.D{{"$exists", false}}}},
+		Update: bson.D{{"$addToSet", bson.D{{"holders", a.Id()}}}},
+	}}, nil
+}
+
+// releaseSlotOps returns the operations needed for the action to give
+// up its slot, if it has one.
+func (a *Action) releaseSlotOps() []txn.Op {
+	if a.doc.Slot == nil {
+		return nil
+	}
+	return []txn.Op{{
+		C:      actionSlotsC,
+		Id:     a.st.docID(a.doc.Slot.Name),
+		Update: bson.D{{"$pull", bson.D{{"holders", a.Id()}}}},
+	}}
+}
+
+func holdsSlot(doc *actionSlotDoc, actionId string) bool {
+	for _, holder := range doc.Holders {
+		if holder == actionId {
+			return true
+		}
+	}
+	return false
+}
@@ -29,6 +29,11 @@ type environmentDoc struct {
 	Life       Life
 	Owner      string `bson:"owner"`
 	ServerUUID string `bson:"server-uuid"`
+
+	// ConfigError holds the reason the environment's configuration
+	// was last found unusable by the workers that need an Environ,
+	// or is empty if they last found it usable.
+	ConfigError string `bson:"config-error,omitempty"`
 }
 
 // InitialEnvironment returns the environment that was bootstrapped.
@@ -167,6 +172,29 @@ func (e *Environment) Owner() names.UserTag {
 	return names.NewUserTag(e.doc.Owner)
 }
 
+// ConfigError returns the reason the environment's configuration was
+// last found unusable by the workers that need an Environ, or "" if
+// they last found it usable.
+func (e *Environment) ConfigError() string {
+	return e.doc.ConfigError
+}
+
+// SetConfigError records the reason the environment's configuration
+// was found unusable. An empty reason records that it was usable.
+func (e *Environment) SetConfigError(reason string) error {
+	ops := []txn.Op{{
+		C:      environmentsC,
+		Id:     e.doc.UUID,
+		Assert: txn.DocExists,
+		Update: bson.D{{"$set", bson.D{{"config-error", reason}}}},
+	}}
+	if err := e.st.runTransaction(ops); err != nil {
+		return errors.Annotate(err, "cannot set environment config error")
+	}
+	e.doc.ConfigError = reason
+	return nil
+}
+
 // globalKey returns the global database key for the environment.
 func (e *Environment) globalKey() string {
 	return environGlobalKey
@@ -35,6 +35,25 @@ func (s *EnvironSuite) TestEnvironment(c *gc.C) {
 	})
 }
 
+func (s *EnvironSuite) TestConfigError(c *gc.C) {
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, "")
+
+	err = env.SetConfigError(`unknown provider type "foo"`)
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, `unknown provider type "foo"`)
+	env, err = s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, `unknown provider type "foo"`)
+
+	err = env.SetConfigError("")
+	c.Assert(err, gc.IsNil)
+	err = env.Refresh()
+	c.Assert(err, gc.IsNil)
+	c.Assert(env.ConfigError(), gc.Equals, "")
+}
+
 func (s *EnvironSuite) TestNewEnvironment(c *gc.C) {
 	owner := names.NewUserTag("test@remote")
 	uuid, err := utils.NewUUID()
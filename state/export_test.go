@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 
 	"github.com/juju/names"
 	jujutxn "github.com/juju/txn"
@@ -30,6 +31,7 @@ var (
 	SetBackupStored       = setBackupStored
 	GetManagedStorage     = (*State).getManagedStorage
 	ToolstorageNewStorage = &toolstorageNewStorage
+	PresenceHistoryAge    = &presenceHistoryAge
 )
 
 func SetTestHooks(c *gc.C, st *State, hooks ...jujutxn.TestHook) txntesting.TransactionChecker {
@@ -246,6 +248,8 @@ func EnsureActionMarker(prefix string) string {
 
 var EnsureActionResultMarker = ensureSuffixFn(actionResultMarker)
 
+var WithActionDefaults = withActionDefaults
+
 func GetActionResultId(actionId string) (string, bool) {
 	return convertActionIdToActionResultId(actionId)
 }
@@ -299,3 +303,10 @@ func LocalID(st *State, id string) string {
 func GetUnitEnvUUID(unit *Unit) string {
 	return unit.doc.EnvUUID
 }
+
+// SetActionResultTimes sets the recorded start and completion times
+// of the given ActionResult.
+func SetActionResultTimes(r *ActionResult, started, completed time.Time) {
+	r.doc.Started = started
+	r.doc.Completed = completed
+}
@@ -145,6 +145,8 @@ var indexes = []struct {
 	{networkInterfacesC, []string{"macaddress", "networkname"}, true},
 	{networkInterfacesC, []string{"networkname"}, false},
 	{networkInterfacesC, []string{"machineid"}, false},
+	{presenceHistoryC, []string{"env-uuid", "agent", "time"}, false},
+	{presenceHistoryC, []string{"env-uuid", "time"}, false},
 }
 
 // The capped collection used for transaction logs defaults to 10MB.
@@ -0,0 +1,107 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/names"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+)
+
+// presenceHistoryAge holds how long presence transitions are kept.
+// Older transitions are removed as new ones are recorded.
+var presenceHistoryAge = 7 * 24 * time.Hour
+
+// PresenceEvent records an agent connecting to, or disconnecting
+// from, the API server.
+type PresenceEvent struct {
+	// Time is when the transition occurred.
+	Time time.Time
+
+	// Connected is true if the agent connected, and false
+	// if it disconnected.
+	Connected bool
+}
+
+type presenceEventDoc struct {
+	Id        bson.ObjectId `bson:"_id"`
+	EnvUUID   string        `bson:"env-uuid"`
+	Agent     string        `bson:"agent"`
+	Time      time.Time     `bson:"time"`
+	Connected bool          `bson:"connected"`
+}
+
+// RecordAgentPresence records that the agent of the entity identified
+// by tag has connected to (or disconnected from) the API server.
+// Transitions older than the history is kept for are removed at the
+// same time.
+func (st *State) RecordAgentPresence(tag names.Tag, connected bool) error {
+	now := time.Now().UTC()
+	if err := st.prunePresenceHistory(now.Add(-presenceHistoryAge)); err != nil {
+		logger.Warningf("%v", err)
+	}
+	doc := &presenceEventDoc{
+		Id:        bson.NewObjectId(),
+		EnvUUID:   st.EnvironTag().Id(),
+		Agent:     tag.String(),
+		Time:      now,
+		Connected: connected,
+	}
+	ops := []txn.Op{{
+		C:      presenceHistoryC,
+		Id:     doc.Id,
+		Assert: txn.DocMissing,
+		Insert: doc,
+	}}
+	if err := st.runTransaction(ops); err != nil {
+		return errors.Annotatef(err, "cannot record presence of %s", tag)
+	}
+	return nil
+}
+
+// AgentPresenceHistory returns the presence transitions recorded for
+// the agent of the entity identified by tag at or after the given
+// time, oldest first.
+func (st *State) AgentPresenceHistory(tag names.Tag, since time.Time) ([]PresenceEvent, error) {
+	history, closer := st.getCollection(presenceHistoryC)
+	defer closer()
+	var docs []presenceEventDoc
+	err := history.Find(bson.D{
+		{"env-uuid", st.EnvironTag().Id()},
+		{"agent", tag.String()},
+		{"time", bson.D{{"$gte", since}}},
+	}).Sort("time", "_id").All(&docs)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot get presence history of %s", tag)
+	}
+	events := make([]PresenceEvent, len(docs))
+	for i, doc := range docs {
+		events[i] = PresenceEvent{
+			Time:      doc.Time.UTC(),
+			Connected: doc.Connected,
+		}
+	}
+	return events, nil
+}
+
+// prunePresenceHistory removes the presence transitions of every agent
+// in the environment that were recorded before the given time.
+func (st *State) prunePresenceHistory(before time.Time) error {
+	history, closer := st.getCollection(presenceHistoryC)
+	defer closer()
+	// Presence transitions are only ever inserted, and nothing watches
+	// them, so it is safe to remove them without using mgo/txn; see
+	// State.CleanupOldMetrics for a similar situation.
+	_, err := history.RemoveAll(bson.D{
+		{"env-uuid", st.EnvironTag().Id()},
+		{"time", bson.D{{"$lt", before}}},
+	})
+	if err != nil {
+		return errors.Annotate(err, "cannot prune presence history")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package state_test
+
+import (
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/state"
+)
+
+type PresenceHistorySuite struct {
+	ConnSuite
+}
+
+var _ = gc.Suite(&PresenceHistorySuite{})
+
+func (s *PresenceHistorySuite) TestAgentPresenceHistory(c *gc.C) {
+	machine := s.factory.MakeMachine(c, nil)
+	other := s.factory.MakeMachine(c, nil)
+
+	history, err := s.State.AgentPresenceHistory(machine.Tag(), time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(history, gc.HasLen, 0)
+
+	err = s.State.RecordAgentPresence(machine.Tag(), true)
+	c.Assert(err, gc.IsNil)
+	err = s.State.RecordAgentPresence(other.Tag(), true)
+	c.Assert(err, gc.IsNil)
+	err = s.State.RecordAgentPresence(machine.Tag(), false)
+	c.Assert(err, gc.IsNil)
+
+	history, err = s.State.AgentPresenceHistory(machine.Tag(), time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(history, gc.HasLen, 2)
+	c.Assert(history[0].Connected, jc.IsTrue)
+	c.Assert(history[1].Connected, jc.IsFalse)
+	c.Assert(history[1].Time.Before(history[0].Time), jc.IsFalse)
+
+	history, err = s.State.AgentPresenceHistory(machine.Tag(), time.Now().Add(time.Hour))
+	c.Assert(err, gc.IsNil)
+	c.Assert(history, gc.HasLen, 0)
+}
+
+func (s *PresenceHistorySuite) TestOldPresenceHistoryRemoved(c *gc.C) {
+	machine := s.factory.MakeMachine(c, nil)
+	other := s.factory.MakeMachine(c, nil)
+	err := s.State.RecordAgentPresence(machine.Tag(), true)
+	c.Assert(err, gc.IsNil)
+	err = s.State.RecordAgentPresence(other.Tag(), true)
+	c.Assert(err, gc.IsNil)
+
+	// Keeping no history at all, recording a transition removes
+	// every earlier transition, whichever agent it is for.
+	s.PatchValue(state.PresenceHistoryAge, -time.Hour)
+	err = s.State.RecordAgentPresence(machine.Tag(), false)
+	c.Assert(err, gc.IsNil)
+
+	history, err := s.State.AgentPresenceHistory(machine.Tag(), time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(history, gc.HasLen, 1)
+	c.Assert(history[0].Connected, jc.IsFalse)
+	history, err = s.State.AgentPresenceHistory(other.Tag(), time.Time{})
+	c.Assert(err, gc.IsNil)
+	c.Assert(history, gc.HasLen, 0)
+}
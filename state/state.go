@@ -56,6 +56,7 @@ const (
 	unitsC             = "units"
 	actionsC           = "actions"
 	actionresultsC     = "actionresults"
+	actionSlotsC       = "actionslots"
 	usersC             = "users"
 	envUsersC          = "envusers"
 	presenceC          = "presence"
@@ -68,6 +69,10 @@ const (
 	upgradeInfoC       = "upgradeInfo"
 	rebootC            = "reboot"
 
+	// presenceHistoryC is the collection used to record agents
+	// connecting to and disconnecting from the API server.
+	presenceHistoryC = "presencehistory"
+
 	// meterStatusC is the collection used to store meter status information.
 	meterStatusC = "meterStatus"
 
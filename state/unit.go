@@ -1537,10 +1537,30 @@ func (u *Unit) UnassignFromMachine() (err error) {
 // AddAction adds a new Action of type name and using arguments payload to
 // this Unit, and returns its ID
 func (u *Unit) AddAction(name string, payload map[string]interface{}) (*Action, error) {
+	return u.addAction(name, payload, ActionOptions{})
+}
+
+// AddActionWithOptions adds a new Action of type name and using
+// arguments payload to this Unit, with the given optional settings,
+// and returns its ID.
+func (u *Unit) AddActionWithOptions(name string, payload map[string]interface{}, opts ActionOptions) (*Action, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot add action; %v", err)
+	}
+	return u.addAction(name, payload, opts)
+}
+
+func (u *Unit) addAction(name string, payload map[string]interface{}, opts ActionOptions) (*Action, error) {
 	doc, err := newActionDoc(u.st, u, name, payload)
 	if err != nil {
 		return nil, fmt.Errorf("cannot add action; %v", err)
 	}
+	doc.Retry = opts.Retry
+	doc.Slot = opts.Slot
+	doc.Timeout = opts.Timeout
+	if doc.EffectiveParameters, err = u.effectiveActionParams(name, payload); err != nil {
+		return nil, fmt.Errorf("cannot add action; %v", err)
+	}
 	ops := []txn.Op{{
 		C:      unitsC,
 		Id:     u.doc.DocID,
@@ -1566,6 +1586,31 @@ func (u *Unit) AddAction(name string, payload map[string]interface{}) (*Action,
 	return nil, err
 }
 
+// effectiveActionParams returns the parameters the named action will be
+// run with, given the payload it was queued with: the payload, with any
+// defaults declared by the unit's charm filled in. The service's charm
+// is used if the unit has not yet set its own.
+func (u *Unit) effectiveActionParams(name string, payload map[string]interface{}) (map[string]interface{}, error) {
+	curl, ok := u.CharmURL()
+	if !ok {
+		svc, err := u.Service()
+		if err != nil {
+			return nil, err
+		}
+		curl, _ = svc.CharmURL()
+	}
+	ch, err := u.st.Charm(curl)
+	if err != nil {
+		return nil, err
+	}
+	if actions := ch.Actions(); actions != nil {
+		if spec, ok := actions.ActionSpecs[name]; ok {
+			return withActionDefaults(spec, payload), nil
+		}
+	}
+	return payload, nil
+}
+
 // CancelAction removes a pending Action from the queue for this
 // ActionReceiver and marks it as cancelled.
 func (u *Unit) CancelAction(action *Action) (*ActionResult, error) {
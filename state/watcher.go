@@ -64,6 +64,13 @@ type RelationUnitsWatcher interface {
 	Changes() <-chan params.RelationUnitsChange
 }
 
+// ActionsWatcher generates signals when Actions are queued or finish,
+// returning the changes as a list of params.ActionEvent.
+type ActionsWatcher interface {
+	Watcher
+	Changes() <-chan []params.ActionEvent
+}
+
 // commonWatcher is part of all client watchers.
 type commonWatcher struct {
 	st   *State
@@ -1780,6 +1787,311 @@ func (st *State) WatchActionResultsFilteredBy(receivers ...ActionReceiver) Strin
 	return newIdPrefixWatcher(st, actionresultsC, makeIdFilter(st, actionResultMarker, receivers...))
 }
 
+// actionEventsWatcher notifies about Actions being queued, and about
+// the results of Actions being recorded, for all ActionReceivers in
+// the environment.
+type actionEventsWatcher struct {
+	commonWatcher
+	out chan []params.ActionEvent
+}
+
+var _ ActionsWatcher = (*actionEventsWatcher)(nil)
+
+// WatchAllActions starts and returns an ActionsWatcher that notifies
+// on the lifecycle of all Actions in the environment. The first event
+// holds the current state of every known Action.
+func (st *State) WatchAllActions() ActionsWatcher {
+	w := &actionEventsWatcher{
+		commonWatcher: commonWatcher{st: st},
+		out:           make(chan []params.ActionEvent),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop())
+	}()
+	return w
+}
+
+// Changes returns the event channel for w.
+func (w *actionEventsWatcher) Changes() <-chan []params.ActionEvent {
+	return w.out
+}
+
+func (w *actionEventsWatcher) loop() error {
+	actionsw := w.st.WatchActions()
+	defer watcher.Stop(actionsw, &w.tomb)
+	resultsw := w.st.WatchActionResults()
+	defer watcher.Stop(resultsw, &w.tomb)
+
+	// Wait for the initial events of both watchers, so the first
+	// event we send describes every Action already known.
+	var events []params.ActionEvent
+	var gotActions, gotResults bool
+	for !gotActions || !gotResults {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case ids, ok := <-actionsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(actionsw)
+			}
+			more, err := w.actionEvents(ids)
+			if err != nil {
+				return err
+			}
+			events = append(events, more...)
+			gotActions = true
+		case ids, ok := <-resultsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(resultsw)
+			}
+			more, err := w.actionResultEvents(ids)
+			if err != nil {
+				return err
+			}
+			events = append(events, more...)
+			gotResults = true
+		}
+	}
+
+	out := w.out
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case ids, ok := <-actionsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(actionsw)
+			}
+			more, err := w.actionEvents(ids)
+			if err != nil {
+				return err
+			}
+			events = append(events, more...)
+			if len(events) > 0 {
+				out = w.out
+			}
+		case ids, ok := <-resultsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(resultsw)
+			}
+			more, err := w.actionResultEvents(ids)
+			if err != nil {
+				return err
+			}
+			events = append(events, more...)
+			if len(events) > 0 {
+				out = w.out
+			}
+		case out <- events:
+			events = nil
+			out = nil
+		}
+	}
+}
+
+// actionEvents converts the ids of queued Actions into events. Actions
+// that have already finished by the time they are looked up are skipped;
+// their results will be reported separately.
+func (w *actionEventsWatcher) actionEvents(ids []string) ([]params.ActionEvent, error) {
+	var events []params.ActionEvent
+	for _, id := range ids {
+		action, err := w.st.Action(id)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		tag := action.ActionTag()
+		events = append(events, params.ActionEvent{
+			Tag:      tag,
+			Receiver: tag.PrefixTag(),
+			Name:     action.Name(),
+			Status:   string(ActionPending),
+		})
+	}
+	return events, nil
+}
+
+// actionResultEvents converts the ids of recorded ActionResults into
+// events carrying the final status of each Action.
+func (w *actionEventsWatcher) actionResultEvents(ids []string) ([]params.ActionEvent, error) {
+	var events []params.ActionEvent
+	for _, id := range ids {
+		result, err := w.st.ActionResult(id)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		tag := result.ActionTag()
+		events = append(events, params.ActionEvent{
+			Tag:      tag,
+			Receiver: tag.PrefixTag(),
+			Name:     result.Name(),
+			Status:   string(result.Status()),
+		})
+	}
+	return events, nil
+}
+
+// receiverActionsWatcher notifies about changes to the Actions queued
+// for an ActionReceiver, and about their results being recorded.
+type receiverActionsWatcher struct {
+	commonWatcher
+	receiver ActionReceiver
+	out      chan []string
+}
+
+var _ StringsWatcher = (*receiverActionsWatcher)(nil)
+
+// WatchReceiverActions starts and returns a StringsWatcher that
+// notifies with the ids of the Actions of the given ActionReceiver
+// as they are queued, begin running, and finish or are cancelled.
+// The first event holds the ids of every known Action of the receiver.
+func (st *State) WatchReceiverActions(receiver ActionReceiver) StringsWatcher {
+	w := &receiverActionsWatcher{
+		commonWatcher: commonWatcher{st: st},
+		receiver:      receiver,
+		out:           make(chan []string),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop())
+	}()
+	return w
+}
+
+// Changes returns the event channel for w.
+func (w *receiverActionsWatcher) Changes() <-chan []string {
+	return w.out
+}
+
+func (w *receiverActionsWatcher) loop() error {
+	actionsw := w.receiver.WatchActions()
+	defer watcher.Stop(actionsw, &w.tomb)
+	resultsw := w.receiver.WatchActionResults()
+	defer watcher.Stop(resultsw, &w.tomb)
+
+	// Wait for the initial events of both watchers, so the first
+	// event we send holds the ids of every Action already known,
+	// even if there are none.
+	changes := set.NewStrings()
+	var gotActions, gotResults, sentInitial bool
+	var out chan []string
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case ids, ok := <-actionsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(actionsw)
+			}
+			for _, id := range ids {
+				changes.Add(id)
+			}
+			gotActions = true
+		case ids, ok := <-resultsw.Changes():
+			if !ok {
+				return watcher.EnsureErr(resultsw)
+			}
+			for _, id := range ids {
+				if actionId, ok := convertActionResultIdToActionId(id); ok {
+					changes.Add(actionId)
+				}
+			}
+			gotResults = true
+		case out <- changes.SortedValues():
+			changes = set.NewStrings()
+			sentInitial = true
+			out = nil
+			continue
+		}
+		if gotActions && gotResults && (!sentInitial || !changes.IsEmpty()) {
+			out = w.out
+		}
+	}
+}
+
+// actionWatcher notifies about the status transitions of a single
+// Action.
+type actionWatcher struct {
+	commonWatcher
+	out chan struct{}
+}
+
+var _ NotifyWatcher = (*actionWatcher)(nil)
+
+// WatchAction returns a NotifyWatcher that notifies when the Action
+// with the given tag begins running, and when it finishes or is
+// cancelled. Once it has notified that the Action is no longer
+// queued, the watcher stops itself and its Changes channel is closed.
+// An error satisfying errors.IsNotFound is returned if the Action was
+// never queued.
+func (st *State) WatchAction(tag names.ActionTag) (NotifyWatcher, error) {
+	if _, err := st.ActionByTag(tag); errors.IsNotFound(err) {
+		if _, err := st.ActionResultByTag(tag); errors.IsNotFound(err) {
+			return nil, errors.NotFoundf("action %q", tag.Id())
+		} else if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	w := &actionWatcher{
+		commonWatcher: commonWatcher{st: st},
+		out:           make(chan struct{}),
+	}
+	go func() {
+		defer w.tomb.Done()
+		defer close(w.out)
+		w.tomb.Kill(w.loop(st.docID(actionIdFromTag(tag))))
+	}()
+	return w, nil
+}
+
+// Changes returns the event channel for the actionWatcher.
+func (w *actionWatcher) Changes() <-chan struct{} {
+	return w.out
+}
+
+func (w *actionWatcher) loop(key string) error {
+	coll, closer := w.st.getCollection(actionsC)
+	txnRevno, err := getTxnRevno(coll, key)
+	closer()
+	if err != nil {
+		return err
+	}
+	// The Action's document is removed when it finishes.
+	finished := txnRevno == -1
+	in := make(chan watcher.Change)
+	w.st.watcher.Watch(coll.Name, key, txnRevno, in)
+	defer w.st.watcher.Unwatch(coll.Name, key, in)
+	out := w.out
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return tomb.ErrDying
+		case <-w.st.watcher.Dead():
+			return stateWatcherDeadError(w.st.watcher.Err())
+		case ch := <-in:
+			exists, ok := collect(ch, in, w.tomb.Dying())
+			if !ok {
+				return tomb.ErrDying
+			}
+			finished = !exists[key]
+			out = w.out
+		case out <- struct{}{}:
+			if finished {
+				return nil
+			}
+			out = nil
+		}
+	}
+}
+
 // machineInterfacesWatcher notifies about changes to all network interfaces
 // of a machine. Changes include adding, removing enabling or disabling interfaces.
 type machineInterfacesWatcher struct {
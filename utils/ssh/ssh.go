@@ -35,6 +35,9 @@ type Options struct {
 	// knownHostsFile is a path to a file in which to save the host's
 	// fingerprint.
 	knownHostsFile string
+	// hostKey is the public key, in authorized_keys format, that
+	// the host must present; if empty, any host key is accepted.
+	hostKey string
 }
 
 // SetProxyCommand sets a command to execute to proxy traffic through.
@@ -62,6 +65,15 @@ func (o *Options) SetKnownHostsFile(file string) {
 	o.knownHostsFile = file
 }
 
+// SetHostKey requires the host to present the given public key, in
+// authorized_keys format; the connection is refused if it presents
+// any other.
+//
+// By default, any host key is accepted.
+func (o *Options) SetHostKey(key string) {
+	o.hostKey = key
+}
+
 // AllowPasswordAuthentication allows the SSH
 // client to prompt the user for a password.
 //
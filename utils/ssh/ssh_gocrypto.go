@@ -4,6 +4,7 @@
 package ssh
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"strconv"
 	"strings"
 
 	"code.google.com/p/go.crypto/ssh"
@@ -48,19 +50,22 @@ func (c *GoCryptoClient) Command(host string, command []string, options *Options
 	user, host := splitUserHost(host)
 	port := sshDefaultPort
 	var proxyCommand []string
+	var hostKey string
 	if options != nil {
 		if options.port != 0 {
 			port = options.port
 		}
 		proxyCommand = options.proxyCommand
+		hostKey = options.hostKey
 	}
 	logger.Tracef(`running (equivalent of): ssh "%s@%s" -p %d '%s'`, user, host, port, shellCommand)
 	return &Cmd{impl: &goCryptoCommand{
 		signers:      signers,
 		user:         user,
-		addr:         fmt.Sprintf("%s:%d", host, port),
+		addr:         net.JoinHostPort(host, strconv.Itoa(port)),
 		command:      shellCommand,
 		proxyCommand: proxyCommand,
+		hostKey:      hostKey,
 	}}
 }
 
@@ -77,6 +82,7 @@ type goCryptoCommand struct {
 	addr         string
 	command      string
 	proxyCommand []string
+	hostKey      string
 	stdin        io.Reader
 	stdout       io.Writer
 	stderr       io.Writer
@@ -142,6 +148,18 @@ func (c *goCryptoCommand) ensureSession() (*ssh.Session, error) {
 			}),
 		},
 	}
+	if c.hostKey != "" {
+		expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host key: %v", err)
+		}
+		config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+				return fmt.Errorf("host key mismatch for %s", hostname)
+			}
+			return nil
+		}
+	}
 	client, err := sshDialWithProxy(c.addr, c.proxyCommand, config)
 	if err != nil {
 		return nil, err
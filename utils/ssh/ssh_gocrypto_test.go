@@ -30,15 +30,19 @@ type sshServer struct {
 	cfg      *cryptossh.ServerConfig
 	listener net.Listener
 	client   *cryptossh.Client
+	// hostKey holds the server's public host key,
+	// in authorized_keys format.
+	hostKey string
 }
 
 func newServer(c *gc.C) *sshServer {
-	private, _, err := ssh.GenerateKey("test-server")
+	private, public, err := ssh.GenerateKey("test-server")
 	c.Assert(err, gc.IsNil)
 	key, err := cryptossh.ParsePrivateKey([]byte(private))
 	c.Assert(err, gc.IsNil)
 	server := &sshServer{
-		cfg: &cryptossh.ServerConfig{},
+		cfg:     &cryptossh.ServerConfig{},
+		hostKey: public,
 	}
 	server.cfg.AddHostKey(key)
 	server.listener, err = net.Listen("tcp", "127.0.0.1:0")
@@ -134,6 +138,24 @@ func (s *SSHGoCryptoCommandSuite) TestClientNoKeys(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, "ssh.Dial failed")
 }
 
+func (s *SSHGoCryptoCommandSuite) TestCommandIPv6(c *gc.C) {
+	private, _, err := ssh.GenerateKey("test-server")
+	c.Assert(err, gc.IsNil)
+	key, err := cryptossh.ParsePrivateKey([]byte(private))
+	c.Assert(err, gc.IsNil)
+	client, err := ssh.NewGoCryptoClient(key)
+	c.Assert(err, gc.IsNil)
+	var dialed string
+	s.PatchValue(ssh.SSHDial, func(network, address string, cfg *cryptossh.ClientConfig) (*cryptossh.Client, error) {
+		dialed = address
+		return nil, errors.New("ssh.Dial failed")
+	})
+	cmd := client.Command("ubuntu@2001:db8::1", []string{"echo", "123"}, nil)
+	_, err = cmd.Output()
+	c.Assert(err, gc.ErrorMatches, "ssh.Dial failed")
+	c.Assert(dialed, gc.Equals, "[2001:db8::1]:22")
+}
+
 func (s *SSHGoCryptoCommandSuite) TestCommand(c *gc.C) {
 	private, _, err := ssh.GenerateKey("test-server")
 	c.Assert(err, gc.IsNil)
@@ -157,6 +179,53 @@ func (s *SSHGoCryptoCommandSuite) TestCommand(c *gc.C) {
 	c.Assert(checkedKey, jc.IsTrue)
 }
 
+func (s *SSHGoCryptoCommandSuite) TestCommandHostKey(c *gc.C) {
+	private, _, err := ssh.GenerateKey("test-client")
+	c.Assert(err, gc.IsNil)
+	key, err := cryptossh.ParsePrivateKey([]byte(private))
+	c.Assert(err, gc.IsNil)
+	client, err := ssh.NewGoCryptoClient(key)
+	c.Assert(err, gc.IsNil)
+	server := newServer(c)
+	server.cfg.PublicKeyCallback = func(_ cryptossh.ConnMetadata, _ cryptossh.PublicKey) (*cryptossh.Permissions, error) {
+		return nil, nil
+	}
+	var opts ssh.Options
+	opts.SetPort(server.listener.Addr().(*net.TCPAddr).Port)
+	opts.SetHostKey(server.hostKey)
+	cmd := client.Command("127.0.0.1", testCommand, &opts)
+	go server.run(c)
+	out, err := cmd.Output()
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(out), gc.Equals, "abc value\n")
+}
+
+func (s *SSHGoCryptoCommandSuite) TestCommandHostKeyMismatch(c *gc.C) {
+	private, _, err := ssh.GenerateKey("test-client")
+	c.Assert(err, gc.IsNil)
+	key, err := cryptossh.ParsePrivateKey([]byte(private))
+	c.Assert(err, gc.IsNil)
+	client, err := ssh.NewGoCryptoClient(key)
+	c.Assert(err, gc.IsNil)
+	server := newServer(c)
+	_, otherHostKey, err := ssh.GenerateKey("other-server")
+	c.Assert(err, gc.IsNil)
+	var opts ssh.Options
+	opts.SetPort(server.listener.Addr().(*net.TCPAddr).Port)
+	opts.SetHostKey(otherHostKey)
+	cmd := client.Command("127.0.0.1", testCommand, &opts)
+	go func() {
+		// The client hangs up during the handshake.
+		netconn, err := server.listener.Accept()
+		c.Check(err, gc.IsNil)
+		defer netconn.Close()
+		_, _, _, err = cryptossh.NewServerConn(netconn, server.cfg)
+		c.Check(err, gc.NotNil)
+	}()
+	_, err = cmd.Output()
+	c.Assert(err, gc.ErrorMatches, ".*host key mismatch for 127.0.0.1.*")
+}
+
 func (s *SSHGoCryptoCommandSuite) TestCopy(c *gc.C) {
 	client, err := ssh.NewGoCryptoClient()
 	c.Assert(err, gc.IsNil)
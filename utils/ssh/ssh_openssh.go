@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
@@ -63,11 +64,61 @@ func NewOpenSSHClient() (*OpenSSHClient, error) {
 	return &c, nil
 }
 
+// hostKeyOptions returns options that make ssh accept only the host
+// key required by the given options, by writing it to a temporary
+// known hosts file, and the path of that file, which must be removed
+// once the command completes. If no host key is required, the options
+// are returned unchanged.
+func hostKeyOptions(options *Options) (*Options, string) {
+	if options == nil || options.hostKey == "" {
+		return options, ""
+	}
+	opts := *options
+	file, err := writeKnownHostsFile(options.hostKey)
+	if err != nil {
+		// With no known hosts, ssh refuses to connect at all.
+		logger.Warningf("cannot write known hosts file: %v", err)
+		opts.knownHostsFile = os.DevNull
+		return &opts, ""
+	}
+	opts.knownHostsFile = file
+	return &opts, file
+}
+
+// writeKnownHostsFile writes a temporary known hosts file that
+// matches any host with the given key, and returns its path.
+func writeKnownHostsFile(hostKey string) (string, error) {
+	f, err := ioutil.TempFile("", "juju-known-hosts")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "* %s\n", strings.TrimSpace(hostKey)); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeKnownHostsFile removes a file written by writeKnownHostsFile,
+// if any.
+func removeKnownHostsFile(file string) {
+	if file == "" {
+		return
+	}
+	if err := os.Remove(file); err != nil {
+		logger.Warningf("cannot remove known hosts file: %v", err)
+	}
+}
+
 func opensshOptions(options *Options, commandKind opensshCommandKind) []string {
-	args := append([]string{}, opensshCommonOptions...)
 	if options == nil {
 		options = &Options{}
 	}
+	args := append([]string{}, opensshCommonOptions...)
+	if options.hostKey != "" {
+		args = []string{"-o", "StrictHostKeyChecking yes"}
+	}
 	if len(options.proxyCommand) > 0 {
 		args = append(args, "-o", "ProxyCommand "+utils.CommandString(options.proxyCommand...))
 	}
@@ -122,6 +173,7 @@ func opensshOptions(options *Options, commandKind opensshCommandKind) []string {
 
 // Command implements Client.Command.
 func (c *OpenSSHClient) Command(host string, command []string, options *Options) *Cmd {
+	options, knownHostsFile := hostKeyOptions(options)
 	args := opensshOptions(options, sshKind)
 	args = append(args, host)
 	if len(command) > 0 {
@@ -129,7 +181,7 @@ func (c *OpenSSHClient) Command(host string, command []string, options *Options)
 	}
 	bin, args := sshpassWrap("ssh", args)
 	logger.Tracef("running: %s %s", bin, utils.CommandString(args...))
-	return &Cmd{impl: &opensshCmd{exec.Command(bin, args...)}}
+	return &Cmd{impl: &opensshCmd{exec.Command(bin, args...), knownHostsFile}}
 }
 
 // Copy implements Client.Copy.
@@ -139,7 +191,9 @@ func (c *OpenSSHClient) Copy(args []string, userOptions *Options) error {
 		options = *userOptions
 		options.allocatePTY = false // doesn't make sense for scp
 	}
-	allArgs := opensshOptions(&options, scpKind)
+	opts, knownHostsFile := hostKeyOptions(&options)
+	defer removeKnownHostsFile(knownHostsFile)
+	allArgs := opensshOptions(opts, scpKind)
 	allArgs = append(allArgs, args...)
 	bin, allArgs := sshpassWrap("scp", allArgs)
 	cmd := exec.Command(bin, allArgs...)
@@ -158,6 +212,23 @@ func (c *OpenSSHClient) Copy(args []string, userOptions *Options) error {
 
 type opensshCmd struct {
 	*exec.Cmd
+
+	// knownHostsFile holds the path of the temporary known hosts
+	// file written for the command, if any.
+	knownHostsFile string
+}
+
+func (c *opensshCmd) Start() error {
+	err := c.Cmd.Start()
+	if err != nil {
+		removeKnownHostsFile(c.knownHostsFile)
+	}
+	return err
+}
+
+func (c *opensshCmd) Wait() error {
+	defer removeKnownHostsFile(c.knownHostsFile)
+	return c.Cmd.Wait()
 }
 
 func (c *opensshCmd) SetStdio(stdin io.Reader, stdout, stderr io.Writer) {
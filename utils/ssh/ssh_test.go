@@ -12,6 +12,7 @@ import (
 
 	"github.com/juju/cmd"
 	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"github.com/juju/juju/utils/ssh"
@@ -118,6 +119,34 @@ func (s *SSHCommandSuite) TestCommandSetKnownHostsFile(c *gc.C) {
 	)
 }
 
+func (s *SSHCommandSuite) TestCommandSetHostKey(c *gc.C) {
+	// The fake ssh prints the known hosts file it is given.
+	script := "#!/bin/sh\n" +
+		"for arg in \"$@\"; do\n" +
+		"  case \"$arg\" in \"UserKnownHostsFile \"*) cat \"${arg#UserKnownHostsFile }\";; esac\n" +
+		"done\n" +
+		echoCommand + " $0 \"$@\"\n"
+	err := ioutil.WriteFile(s.fakessh, []byte(script), 0755)
+	c.Assert(err, gc.IsNil)
+
+	var opts ssh.Options
+	opts.SetHostKey("ssh-rsa AAAAB3Nza host-key\n")
+	out, err := s.commandOptions([]string{echoCommand, "123"}, &opts).Output()
+	c.Assert(err, gc.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	c.Assert(lines, gc.HasLen, 2)
+	c.Assert(lines[0], gc.Equals, "* ssh-rsa AAAAB3Nza host-key")
+	prefix := fmt.Sprintf("%s -o StrictHostKeyChecking yes -o PasswordAuthentication no -o ServerAliveInterval 30 -o UserKnownHostsFile ", s.fakessh)
+	suffix := fmt.Sprintf(" localhost %s 123", echoCommand)
+	c.Assert(strings.HasPrefix(lines[1], prefix), jc.IsTrue, gc.Commentf("%s", lines[1]))
+	c.Assert(strings.HasSuffix(lines[1], suffix), jc.IsTrue, gc.Commentf("%s", lines[1]))
+
+	// The known hosts file is removed once the command completes.
+	knownHostsFile := strings.TrimSuffix(strings.TrimPrefix(lines[1], prefix), suffix)
+	_, err = os.Stat(knownHostsFile)
+	c.Assert(err, jc.Satisfies, os.IsNotExist)
+}
+
 func (s *SSHCommandSuite) TestCommandAllowPasswordAuthentication(c *gc.C) {
 	var opts ssh.Options
 	opts.AllowPasswordAuthentication()
@@ -0,0 +1,403 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+// Package winrm runs commands on Windows machines using the
+// WS-Management protocol (WinRM).
+package winrm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+
+	"github.com/juju/loggo"
+	"github.com/juju/utils"
+)
+
+var logger = loggo.GetLogger("juju.utils.winrm")
+
+// DefaultPort is the port on which WinRM listens for HTTPS requests.
+const DefaultPort = 5986
+
+// scriptChunkSize is the number of bytes of base64-encoded script
+// written to the remote machine by each command, keeping each command
+// line well within the limit imposed by cmd.exe.
+const scriptChunkSize = 2000
+
+const (
+	actionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	actionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	actionSignal  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+
+	commandStateDone = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+	signalTerminate  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/signal/terminate"
+)
+
+// ErrCancelled is returned by RunPowerShell when the script is
+// abandoned through Client.Cancel.
+var ErrCancelled = errors.New("command cancelled")
+
+// Client runs commands on Windows machines over HTTPS, authenticating
+// with a user name and password.
+type Client struct {
+	User     string
+	Password string
+
+	// Port is the port to connect to; if zero, DefaultPort is used.
+	Port int
+
+	// ServerCert, if not nil, is the certificate that the machine
+	// must present, as WinRM listeners usually present a self-signed
+	// one. If it is nil, the machine's certificate must be signed by
+	// a trusted authority and name the host connected to.
+	ServerCert *x509.Certificate
+
+	// HTTPClient is used to make requests; if nil, one that verifies
+	// the machine's certificate as described for ServerCert is used.
+	HTTPClient *http.Client
+
+	// Cancel, if not nil, is closed to abandon the script being run.
+	// The command running it is terminated, and ErrCancelled
+	// returned.
+	Cancel <-chan struct{}
+}
+
+// cancelled reports whether c.Cancel has been closed.
+func (c *Client) cancelled() bool {
+	select {
+	case <-c.Cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseCertificate parses a PEM-encoded certificate, such as may be
+// given as a Client's ServerCert.
+func ParseCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM-encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// httpClient returns the HTTP client with which requests are made.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.ServerCert == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialTLS: pinnedCertDialer(c.ServerCert),
+		},
+	}
+}
+
+// pinnedCertDialer returns a function that makes TLS connections only
+// to servers that present the given certificate.
+func pinnedCertDialer(cert *x509.Certificate) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		// The certificate is checked below in place of the usual
+		// verification, which a self-signed certificate cannot pass.
+		conn, err := tls.Dial(network, addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return nil, err
+		}
+		certs := conn.ConnectionState().PeerCertificates
+		if len(certs) == 0 || !bytes.Equal(certs[0].Raw, cert.Raw) {
+			conn.Close()
+			return nil, fmt.Errorf("%s did not present the expected certificate", addr)
+		}
+		return conn, nil
+	}
+}
+
+// RunPowerShell runs the given PowerShell script on host, returning
+// its combined output. The script is copied to the machine before it
+// is run, so it may be longer than a single command line allows. An
+// error is returned if the script exits with a non-zero status.
+func (c *Client) RunPowerShell(host, script string) ([]byte, error) {
+	output, err := c.runPowerShell(host, script)
+	if err != nil && c.cancelled() {
+		return nil, ErrCancelled
+	}
+	return output, err
+}
+
+func (c *Client) runPowerShell(host, script string) ([]byte, error) {
+	s, err := c.openShell(host)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	file := `%TEMP%\juju-` + s.id + ".ps1"
+	encoded := base64.StdEncoding.EncodeToString([]byte(script))
+	for len(encoded) > 0 {
+		n := scriptChunkSize
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		if _, err := s.run(fmt.Sprintf(`echo %s>> "%s.b64"`, encoded[:n], file)); err != nil {
+			return nil, fmt.Errorf("cannot copy script: %v", err)
+		}
+		encoded = encoded[n:]
+	}
+	decode := fmt.Sprintf(
+		`$b64 = [Environment]::ExpandEnvironmentVariables('%s.b64'); `+
+			`$ps1 = [Environment]::ExpandEnvironmentVariables('%s'); `+
+			`$text = [Text.Encoding]::UTF8.GetString([Convert]::FromBase64String((Get-Content $b64) -join '')); `+
+			`Set-Content -Path $ps1 -Value $text; Remove-Item $b64`,
+		file, file,
+	)
+	if _, err := s.run(powerShellCommand(decode)); err != nil {
+		return nil, fmt.Errorf("cannot copy script: %v", err)
+	}
+	output, err := s.run(fmt.Sprintf(`powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File "%s"`, file))
+	if _, err := s.run(fmt.Sprintf(`del "%s"`, file)); err != nil {
+		logger.Debugf("cannot remove script: %v", err)
+	}
+	return output, err
+}
+
+// powerShellCommand returns a command line that runs the given
+// PowerShell commands.
+func powerShellCommand(commands string) string {
+	var encoded []byte
+	for _, r := range utf16.Encode([]rune(commands)) {
+		encoded = append(encoded, byte(r), byte(r>>8))
+	}
+	return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(encoded)
+}
+
+// shell is a remote shell in which commands are run.
+type shell struct {
+	client *Client
+	url    string
+	id     string
+}
+
+func (c *Client) openShell(host string) (*shell, error) {
+	port := c.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	s := &shell{
+		client: c,
+		url:    "https://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/wsman",
+	}
+	var resp response
+	if err := s.call(actionCreate, createBody, nil, &resp); err != nil {
+		return nil, fmt.Errorf("cannot open shell: %v", err)
+	}
+	s.id = resp.Body.ShellId
+	if s.id == "" {
+		for _, selector := range resp.Body.Selectors {
+			if selector.Name == "ShellId" {
+				s.id = selector.Value
+			}
+		}
+	}
+	if s.id == "" {
+		return nil, fmt.Errorf("cannot open shell: no shell id returned")
+	}
+	return s, nil
+}
+
+// run runs the given command line and returns its combined output. An
+// error is returned if the command exits with a non-zero status.
+func (s *shell) run(command string) ([]byte, error) {
+	var resp response
+	if err := s.call(actionCommand, commandBody, command, &resp); err != nil {
+		return nil, err
+	}
+	commandId := resp.Body.CommandId
+	defer func() {
+		if err := s.call(actionSignal, signalBody, commandId, nil); err != nil {
+			logger.Debugf("cannot terminate command: %v", err)
+		}
+	}()
+	var output bytes.Buffer
+	for {
+		if s.client.cancelled() {
+			return nil, ErrCancelled
+		}
+		var resp response
+		if err := s.call(actionReceive, receiveBody, commandId, &resp); err != nil {
+			return nil, err
+		}
+		for _, stream := range resp.Body.Streams {
+			data, err := base64.StdEncoding.DecodeString(stream.Data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode %s: %v", stream.Name, err)
+			}
+			output.Write(data)
+		}
+		state := resp.Body.CommandState
+		if state.State != commandStateDone {
+			continue
+		}
+		if state.ExitCode != 0 {
+			err := fmt.Errorf("exit status %d", state.ExitCode)
+			if out := strings.TrimSpace(output.String()); out != "" {
+				err = fmt.Errorf("%s", out)
+			}
+			return output.Bytes(), err
+		}
+		return output.Bytes(), nil
+	}
+}
+
+func (s *shell) close() {
+	if err := s.call(actionDelete, nil, nil, nil); err != nil {
+		logger.Debugf("cannot close shell: %v", err)
+	}
+}
+
+// call sends a request with the given action, with a body rendered
+// from the given template and data, and decodes the response into
+// resp if it is not nil.
+func (s *shell) call(action string, body *template.Template, data interface{}, resp *response) error {
+	messageId, err := utils.NewUUID()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = envelope.Execute(&buf, envelopeParams{
+		To:        s.url,
+		MessageId: messageId.String(),
+		Action:    action,
+		ShellId:   s.id,
+		Create:    action == actionCreate,
+		Command:   action == actionCommand,
+	})
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		if err := body.Execute(&buf, data); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(envelopeEnd)
+	req, err := http.NewRequest("POST", s.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(s.client.User, s.client.Password)
+	if action != actionSignal && action != actionDelete {
+		// Cleaning up after a cancelled command must still be
+		// possible.
+		req.Cancel = s.client.Cancel
+	}
+	httpResp, err := s.client.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	content, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		var fault response
+		if xml.Unmarshal(content, &fault) == nil && fault.Body.Fault.Reason != "" {
+			return fmt.Errorf("%s", strings.TrimSpace(fault.Body.Fault.Reason))
+		}
+		return fmt.Errorf("WinRM request failed: %s", httpResp.Status)
+	}
+	if resp == nil {
+		return nil
+	}
+	return xml.Unmarshal(content, resp)
+}
+
+// response holds the parts of WinRM responses that are used.
+type response struct {
+	Body struct {
+		ShellId   string `xml:"Shell>ShellId"`
+		Selectors []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"ResourceCreated>ReferenceParameters>SelectorSet>Selector"`
+		CommandId string `xml:"CommandResponse>CommandId"`
+		Streams   []struct {
+			Name string `xml:"Name,attr"`
+			Data string `xml:",chardata"`
+		} `xml:"ReceiveResponse>Stream"`
+		CommandState struct {
+			State    string `xml:"State,attr"`
+			ExitCode int    `xml:"ExitCode"`
+		} `xml:"ReceiveResponse>CommandState"`
+		Fault struct {
+			Reason string `xml:"Reason>Text"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+type envelopeParams struct {
+	To        string
+	MessageId string
+	Action    string
+	ShellId   string
+	Create    bool
+	Command   bool
+}
+
+var envelope = template.Must(template.New("envelope").Parse(`<env:Envelope
+ xmlns:env="http://www.w3.org/2003/05/soap-envelope"
+ xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing"
+ xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"
+ xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+<env:Header>
+<a:To>{{html .To}}</a:To>
+<a:ReplyTo><a:Address env:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2004/08/addressing/role/anonymous</a:Address></a:ReplyTo>
+<w:MaxEnvelopeSize env:mustUnderstand="true">153600</w:MaxEnvelopeSize>
+<a:MessageID>uuid:{{.MessageId}}</a:MessageID>
+<w:Locale env:mustUnderstand="false" xml:lang="en-US"/>
+<w:OperationTimeout>PT60S</w:OperationTimeout>
+<w:ResourceURI env:mustUnderstand="true">http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd</w:ResourceURI>
+<a:Action env:mustUnderstand="true">{{.Action}}</a:Action>
+{{if .ShellId}}<w:SelectorSet><w:Selector Name="ShellId">{{.ShellId}}</w:Selector></w:SelectorSet>
+{{end}}{{if .Create}}<w:OptionSet><w:Option Name="WINRS_NOPROFILE">FALSE</w:Option><w:Option Name="WINRS_CODEPAGE">65001</w:Option></w:OptionSet>
+{{end}}{{if .Command}}<w:OptionSet><w:Option Name="WINRS_CONSOLEMODE_STDIN">TRUE</w:Option><w:Option Name="WINRS_SKIP_CMD_SHELL">FALSE</w:Option></w:OptionSet>
+{{end}}</env:Header>
+<env:Body>
+`))
+
+const envelopeEnd = `</env:Body>
+</env:Envelope>
+`
+
+var (
+	createBody = template.Must(template.New("create").Parse(
+		`<rsp:Shell><rsp:InputStreams>stdin</rsp:InputStreams><rsp:OutputStreams>stdout stderr</rsp:OutputStreams></rsp:Shell>
+`))
+	commandBody = template.Must(template.New("command").Parse(
+		`<rsp:CommandLine><rsp:Command>{{html .}}</rsp:Command></rsp:CommandLine>
+`))
+	receiveBody = template.Must(template.New("receive").Parse(
+		`<rsp:Receive><rsp:DesiredStream CommandId="{{.}}">stdout stderr</rsp:DesiredStream></rsp:Receive>
+`))
+	signalBody = template.Must(template.New("signal").Parse(
+		`<rsp:Signal CommandId="{{.}}"><rsp:Code>` + signalTerminate + `</rsp:Code></rsp:Signal>
+`))
+)
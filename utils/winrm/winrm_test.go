@@ -0,0 +1,279 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package winrm_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/juju/utils/winrm"
+)
+
+type winrmSuite struct {
+	server *fakeServer
+	client *winrm.Client
+	host   string
+	cert   *x509.Certificate
+}
+
+var _ = gc.Suite(&winrmSuite{})
+
+func (s *winrmSuite) SetUpTest(c *gc.C) {
+	s.server = &fakeServer{exitCode: 0, output: "done"}
+	httpServer := httptest.NewTLSServer(s.server)
+	s.server.close = httpServer.Close
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(httpServer.URL, "https://"))
+	c.Assert(err, gc.IsNil)
+	s.host = host
+	portNum, err := strconv.Atoi(port)
+	c.Assert(err, gc.IsNil)
+	// The fake server presents a self-signed certificate, as WinRM
+	// listeners usually do.
+	s.cert, err = x509.ParseCertificate(httpServer.TLS.Certificates[0].Certificate[0])
+	c.Assert(err, gc.IsNil)
+	s.client = &winrm.Client{User: "Administrator", Password: "secret", Port: portNum, ServerCert: s.cert}
+}
+
+func (s *winrmSuite) TearDownTest(c *gc.C) {
+	s.server.close()
+}
+
+// longWait is how long to wait for something that should happen.
+const longWait = 10 * time.Second
+
+var echoCommand = regexp.MustCompile(`^echo ([A-Za-z0-9+/=]+)>> "%TEMP%\\juju-shell-1\.ps1\.b64"$`)
+
+func (s *winrmSuite) TestRunPowerShell(c *gc.C) {
+	// The script is long enough to be copied in several parts.
+	script := "Write-Output done\n" + strings.Repeat("# padding\n", 500)
+	output, err := s.client.RunPowerShell(s.host, script)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(output), gc.Equals, "done")
+
+	var encoded string
+	var copies int
+	commands := s.server.commands
+	for len(commands) > 0 {
+		m := echoCommand.FindStringSubmatch(commands[0])
+		if m == nil {
+			break
+		}
+		encoded += m[1]
+		copies++
+		commands = commands[1:]
+	}
+	c.Assert(copies > 1, gc.Equals, true)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(decoded), gc.Equals, script)
+
+	c.Assert(commands, gc.HasLen, 3)
+	c.Assert(commands[0], gc.Matches, `powershell -NoProfile -NonInteractive -EncodedCommand .*`)
+	c.Assert(commands[1], gc.Equals, `powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File "%TEMP%\juju-shell-1.ps1"`)
+	c.Assert(commands[2], gc.Equals, `del "%TEMP%\juju-shell-1.ps1"`)
+	c.Assert(s.server.shellDeleted, gc.Equals, true)
+}
+
+func (s *winrmSuite) TestRunPowerShellCopyFails(c *gc.C) {
+	s.server.exitCode = 1
+	s.server.output = "Access is denied.\r\n"
+	output, err := s.client.RunPowerShell(s.host, "exit 0")
+	c.Assert(err, gc.ErrorMatches, `cannot copy script: Access is denied\.`)
+	c.Assert(output, gc.IsNil)
+	c.Assert(s.server.shellDeleted, gc.Equals, true)
+}
+
+func (s *winrmSuite) TestRunPowerShellScriptFails(c *gc.C) {
+	s.server.failFile = true
+	s.server.output = "nonce mismatch\r\n"
+	output, err := s.client.RunPowerShell(s.host, "exit 1")
+	c.Assert(err, gc.ErrorMatches, "nonce mismatch")
+	c.Assert(string(output), gc.Equals, "nonce mismatch\r\n")
+	// The script is removed even so.
+	commands := s.server.commands
+	c.Assert(commands[len(commands)-1], gc.Equals, `del "%TEMP%\juju-shell-1.ps1"`)
+}
+
+func (s *winrmSuite) TestRunPowerShellUnauthorized(c *gc.C) {
+	s.client.Password = "wrong"
+	_, err := s.client.RunPowerShell(s.host, "exit 0")
+	c.Assert(err, gc.ErrorMatches, "cannot open shell: WinRM request failed: 401 Unauthorized")
+}
+
+func (s *winrmSuite) TestRunPowerShellUnverifiedCertificate(c *gc.C) {
+	s.client.ServerCert = nil
+	_, err := s.client.RunPowerShell(s.host, "exit 0")
+	c.Assert(err, gc.ErrorMatches, "cannot open shell: .*certificate.*")
+	c.Assert(s.server.commands, gc.HasLen, 0)
+}
+
+func (s *winrmSuite) TestRunPowerShellUnexpectedCertificate(c *gc.C) {
+	other := *s.cert
+	other.Raw = append([]byte(nil), s.cert.Raw...)
+	other.Raw[len(other.Raw)-1] ^= 0xff
+	s.client.ServerCert = &other
+	_, err := s.client.RunPowerShell(s.host, "exit 0")
+	c.Assert(err, gc.ErrorMatches, `cannot open shell: .* did not present the expected certificate`)
+	c.Assert(s.server.commands, gc.HasLen, 0)
+}
+
+func (s *winrmSuite) TestRunPowerShellCancelled(c *gc.C) {
+	// Commands never finish, as if the script were still running.
+	hang := make(chan struct{})
+	defer close(hang)
+	s.server.hang = hang
+	s.server.receiving = make(chan struct{}, 1)
+	cancel := make(chan struct{})
+	s.client.Cancel = cancel
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.client.RunPowerShell(s.host, "exit 0")
+		done <- err
+	}()
+	select {
+	case <-s.server.receiving:
+	case <-time.After(longWait):
+		c.Fatalf("command not run")
+	}
+	close(cancel)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, winrm.ErrCancelled)
+	case <-time.After(longWait):
+		c.Fatalf("command not cancelled")
+	}
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	c.Assert(s.server.signalled, gc.Equals, true)
+	c.Assert(s.server.shellDeleted, gc.Equals, true)
+}
+
+func (s *winrmSuite) TestParseCertificate(c *gc.C) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.cert.Raw})
+	cert, err := winrm.ParseCertificate(string(data))
+	c.Assert(err, gc.IsNil)
+	c.Assert(cert.Raw, gc.DeepEquals, s.cert.Raw)
+
+	_, err = winrm.ParseCertificate("ssh-rsa AAAA")
+	c.Assert(err, gc.ErrorMatches, "no PEM-encoded certificate found")
+}
+
+// fakeServer is a minimal WinRM server, which records the commands it
+// is asked to run.
+type fakeServer struct {
+	close func()
+
+	// exitCode and output are the results of every command, unless
+	// failFile is set, in which case only the command running a
+	// script file fails.
+	exitCode int
+	output   string
+	failFile bool
+
+	// hang, if not nil, holds every Receive request until it is
+	// closed; receiving, if not nil, is sent a value as each is held.
+	hang      chan struct{}
+	receiving chan struct{}
+
+	mu           sync.Mutex
+	commands     []string
+	signalled    bool
+	shellDeleted bool
+}
+
+type fakeRequest struct {
+	Action        string `xml:"Header>Action"`
+	Command       string `xml:"Body>CommandLine>Command"`
+	DesiredStream struct {
+		CommandId string `xml:"CommandId,attr"`
+	} `xml:"Body>Receive>DesiredStream"`
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, _ := basicAuth(r); user != "Administrator" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		panic(err)
+	}
+	var req fakeRequest
+	if err := xml.Unmarshal(data, &req); err != nil {
+		panic(err)
+	}
+	if f.hang != nil && strings.HasSuffix(req.Action, "/Receive") {
+		select {
+		case f.receiving <- struct{}{}:
+		default:
+		}
+		<-f.hang
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body string
+	switch path := req.Action[strings.LastIndex(req.Action, "/")+1:]; path {
+	case "Create":
+		body = `<rsp:Shell><rsp:ShellId>shell-1</rsp:ShellId></rsp:Shell>`
+	case "Command":
+		f.commands = append(f.commands, req.Command)
+		body = fmt.Sprintf(`<rsp:CommandResponse><rsp:CommandId>%d</rsp:CommandId></rsp:CommandResponse>`, len(f.commands)-1)
+	case "Receive":
+		n, err := strconv.Atoi(req.DesiredStream.CommandId)
+		if err != nil {
+			panic(err)
+		}
+		exitCode := f.exitCode
+		if f.failFile {
+			exitCode = 0
+			if strings.Contains(f.commands[n], "-File") {
+				exitCode = 1
+			}
+		}
+		body = fmt.Sprintf(`<rsp:ReceiveResponse>`+
+			`<rsp:Stream Name="stdout" CommandId="%d">%s</rsp:Stream>`+
+			`<rsp:CommandState CommandId="%d" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">`+
+			`<rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			n, base64.StdEncoding.EncodeToString([]byte(f.output)), n, exitCode)
+	case "Signal":
+		f.signalled = true
+	case "Delete":
+		f.shellDeleted = true
+	default:
+		panic("unexpected action " + req.Action)
+	}
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" `+
+		`xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">`+
+		`<s:Body>%s</s:Body></s:Envelope>`, body)
+}
+
+// basicAuth returns the user name and password sent with r.
+func basicAuth(r *http.Request) (user, password string, ok bool) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Basic ")
+	decoded, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/juju/loggo"
 	"launchpad.net/tomb"
@@ -20,6 +21,10 @@ import (
 
 var ErrTerminateAgent = errors.New("agent should be terminated")
 
+// ErrWaitForEnvironTimeout is returned by WaitForEnvironTimeout when
+// no valid environment configuration arrives in time.
+var ErrWaitForEnvironTimeout = errors.New("timed out waiting for a valid environment configuration")
+
 var loadedInvalid = func() {}
 
 var logger = loggo.GetLogger("juju.worker")
@@ -37,10 +42,41 @@ type EnvironConfigGetter interface {
 // the given watcher. It terminates with tomb.ErrDying if
 // it receives a value on dying.
 func WaitForEnviron(w apiwatcher.NotifyWatcher, st EnvironConfigGetter, dying <-chan struct{}) (environs.Environ, error) {
+	return WaitForEnvironReporting(w, st, dying, nil)
+}
+
+// WaitForEnvironReporting is like WaitForEnviron, but calls invalid,
+// if it is not nil, with the reason each invalid environment
+// configuration that arrives cannot be used, so that the caller can
+// explain what it is waiting for.
+func WaitForEnvironReporting(w apiwatcher.NotifyWatcher, st EnvironConfigGetter, dying <-chan struct{}, invalid func(error)) (environs.Environ, error) {
+	return waitForEnviron(w, st, dying, invalid, 0)
+}
+
+// WaitForEnvironTimeout is like WaitForEnvironReporting, but gives up
+// with ErrWaitForEnvironTimeout if no valid environment has arrived
+// within the given timeout. The watcher is left running when it gives
+// up, as it is whenever these functions return; the caller remains
+// responsible for stopping it.
+func WaitForEnvironTimeout(w apiwatcher.NotifyWatcher, st EnvironConfigGetter, dying <-chan struct{}, invalid func(error), timeout time.Duration) (environs.Environ, error) {
+	return waitForEnviron(w, st, dying, invalid, timeout)
+}
+
+// waitForEnviron implements the WaitForEnviron functions. If timeout
+// is not positive, it waits indefinitely.
+func waitForEnviron(w apiwatcher.NotifyWatcher, st EnvironConfigGetter, dying <-chan struct{}, invalid func(error), timeout time.Duration) (environs.Environ, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
 	for {
 		select {
 		case <-dying:
 			return nil, tomb.ErrDying
+		case <-expired:
+			return nil, ErrWaitForEnvironTimeout
 		case _, ok := <-w.Changes():
 			if !ok {
 				return nil, watcher.EnsureErr(w)
@@ -53,7 +89,11 @@ func WaitForEnviron(w apiwatcher.NotifyWatcher, st EnvironConfigGetter, dying <-
 			if err == nil {
 				return environ, nil
 			}
-			logger.Errorf("loaded invalid environment configuration: %v", err)
+			if invalid != nil {
+				invalid(err)
+			} else {
+				logger.Errorf("loaded invalid environment configuration: %v", err)
+			}
 			loadedInvalid()
 		}
 	}
@@ -66,8 +106,29 @@ type EnvironObserver struct {
 	tomb           tomb.Tomb
 	environWatcher state.NotifyWatcher
 	st             *state.State
+	checks         EnvironChecks
 	mu             sync.Mutex
 	environ        environs.Environ
+	config         *config.Config
+}
+
+// EnvironChecks holds checks that an EnvironObserver applies to
+// each candidate Environ before it replaces the current one. Any
+// of the fields may be nil.
+type EnvironChecks struct {
+	// Validate is called first, to check that the candidate
+	// Environ is acceptable.
+	Validate func(environs.Environ) error
+
+	// SmokeTest is called once the candidate Environ has been
+	// validated, to check that it is usable; for example, that
+	// the provider can be reached with its credentials.
+	SmokeTest func(environs.Environ) error
+
+	// Rejected is called with the configuration and the reason
+	// whenever a candidate Environ is rejected, after which the
+	// current Environ remains in use.
+	Rejected func(*config.Config, error)
 }
 
 // NewEnvironObserver waits for the state to have a valid environment
@@ -75,20 +136,27 @@ type EnvironObserver struct {
 // for the first environment configuration, it will return with
 // tomb.ErrDying if it receives a value on dying.
 func NewEnvironObserver(st *state.State) (*EnvironObserver, error) {
+	return NewCheckedEnvironObserver(st, EnvironChecks{})
+}
+
+// NewCheckedEnvironObserver returns a new environment observer that
+// only replaces its current Environ if the Environ made from a changed
+// configuration passes the given checks. The initial Environ must
+// also pass them.
+func NewCheckedEnvironObserver(st *state.State, checks EnvironChecks) (*EnvironObserver, error) {
 	config, err := st.EnvironConfig()
 	if err != nil {
 		return nil, err
 	}
-	environ, err := environs.New(config)
-	if err != nil {
+	obs := &EnvironObserver{
+		st:     st,
+		checks: checks,
+	}
+	if err := obs.apply(config); err != nil {
 		return nil, fmt.Errorf("cannot make Environ: %v", err)
 	}
 	environWatcher := st.WatchForEnvironConfigChanges()
-	obs := &EnvironObserver{
-		st:             st,
-		environ:        environ,
-		environWatcher: environWatcher,
-	}
+	obs.environWatcher = environWatcher
 	go func() {
 		defer obs.tomb.Done()
 		defer watcher.Stop(environWatcher, &obs.tomb)
@@ -112,15 +180,40 @@ func (obs *EnvironObserver) loop() error {
 			logger.Warningf("error reading environment config: %v", err)
 			continue
 		}
-		environ, err := environs.New(config)
-		if err != nil {
+		if err := obs.apply(config); err != nil {
 			logger.Warningf("error creating Environ: %v", err)
-			continue
+			if obs.checks.Rejected != nil {
+				obs.checks.Rejected(config, err)
+			}
+		}
+	}
+}
+
+// apply makes a candidate Environ from config, and replaces the
+// current Environ and configuration with it if it passes each of
+// the observer's checks in turn. If any step fails, the current
+// Environ and configuration are left untouched and the error is
+// returned.
+func (obs *EnvironObserver) apply(config *config.Config) error {
+	environ, err := environs.New(config)
+	if err != nil {
+		return err
+	}
+	if obs.checks.Validate != nil {
+		if err := obs.checks.Validate(environ); err != nil {
+			return fmt.Errorf("validation failed: %v", err)
 		}
-		obs.mu.Lock()
-		obs.environ = environ
-		obs.mu.Unlock()
 	}
+	if obs.checks.SmokeTest != nil {
+		if err := obs.checks.SmokeTest(environ); err != nil {
+			return fmt.Errorf("smoke test failed: %v", err)
+		}
+	}
+	obs.mu.Lock()
+	obs.environ = environ
+	obs.config = config
+	obs.mu.Unlock()
+	return nil
 }
 
 // Environ returns the most recent valid Environ.
@@ -130,6 +223,13 @@ func (obs *EnvironObserver) Environ() environs.Environ {
 	return obs.environ
 }
 
+// Config returns the configuration of the most recent valid Environ.
+func (obs *EnvironObserver) Config() *config.Config {
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	return obs.config
+}
+
 func (obs *EnvironObserver) Kill() {
 	obs.tomb.Kill(nil)
 }
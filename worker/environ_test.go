@@ -4,7 +4,9 @@
 package worker_test
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 	stdtesting "testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"launchpad.net/tomb"
 
 	"github.com/juju/juju/environs"
+	"github.com/juju/juju/environs/config"
 	"github.com/juju/juju/juju/testing"
 	"github.com/juju/juju/mongo"
 	"github.com/juju/juju/state"
@@ -84,6 +87,71 @@ func (s *environSuite) TestInvalidConfig(c *gc.C) {
 	c.Assert(env.Config().AllAttrs()["secret"], gc.Equals, "environ_test")
 }
 
+func (s *environSuite) TestInvalidConfigReported(c *gc.C) {
+	oldType := s.Environ.Config().AllAttrs()["type"].(string)
+	info := s.MongoInfo(c)
+	st2, err := state.Open(info, mongo.DefaultDialOpts(), state.Policy(nil))
+	c.Assert(err, gc.IsNil)
+	defer st2.Close()
+	err = st2.UpdateEnvironConfig(map[string]interface{}{"type": "unknown"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+
+	w := st2.WatchForEnvironConfigChanges()
+	defer stopWatcher(c, w)
+	reported := make(chan error, 1)
+	done := make(chan environs.Environ)
+	go func() {
+		env, err := worker.WaitForEnvironReporting(w, st2, nil, func(err error) {
+			reported <- err
+		})
+		c.Check(err, gc.IsNil)
+		done <- env
+	}()
+	<-worker.LoadedInvalid
+	c.Assert(<-reported, gc.ErrorMatches, `no registered provider for "unknown"`)
+
+	err = st2.UpdateEnvironConfig(map[string]interface{}{"type": oldType}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	st2.StartSync()
+	c.Assert(<-done, gc.NotNil)
+}
+
+func (s *environSuite) TestWaitForEnvironTimeout(c *gc.C) {
+	st2, err := state.Open(s.MongoInfo(c), mongo.DefaultDialOpts(), state.Policy(nil))
+	c.Assert(err, gc.IsNil)
+	defer st2.Close()
+	err = st2.UpdateEnvironConfig(map[string]interface{}{"type": "unknown"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+
+	w := st2.WatchForEnvironConfigChanges()
+	defer stopWatcher(c, w)
+	reported := make(chan error, 1)
+	done := make(chan error)
+	go func() {
+		env, err := worker.WaitForEnvironTimeout(w, st2, nil, func(err error) {
+			reported <- err
+		}, coretesting.ShortWait)
+		c.Check(env, gc.IsNil)
+		done <- err
+	}()
+	<-worker.LoadedInvalid
+	c.Assert(<-reported, gc.ErrorMatches, `no registered provider for "unknown"`)
+	select {
+	case err := <-done:
+		c.Assert(err, gc.Equals, worker.ErrWaitForEnvironTimeout)
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for WaitForEnvironTimeout to give up")
+	}
+}
+
+func (s *environSuite) TestWaitForEnvironTimeoutValid(c *gc.C) {
+	w := s.State.WatchForEnvironConfigChanges()
+	defer stopWatcher(c, w)
+	env, err := worker.WaitForEnvironTimeout(w, s.State, nil, nil, coretesting.LongWait)
+	c.Assert(err, gc.IsNil)
+	c.Assert(env, gc.NotNil)
+}
+
 func (s *environSuite) TestErrorWhenEnvironIsInvalid(c *gc.C) {
 	// reopen the state so that we can wangle a dodgy environ config in there.
 	st, err := state.Open(s.MongoInfo(c), mongo.DefaultDialOpts(), state.Policy(nil))
@@ -154,6 +222,112 @@ loop:
 	}
 }
 
+func (s *environSuite) TestCheckedEnvironmentChanges(c *gc.C) {
+	originalConfig, err := s.State.EnvironConfig()
+	c.Assert(err, gc.IsNil)
+
+	// The environment's name cannot be changed, so the checks are
+	// made on the dummy provider's secret attribute.
+	secret := func(cfg *config.Config) string {
+		return cfg.AllAttrs()["secret"].(string)
+	}
+
+	// The checks are run by the observer's goroutine.
+	var mu sync.Mutex
+	var checked []string
+	record := func(check string) {
+		mu.Lock()
+		defer mu.Unlock()
+		checked = append(checked, check)
+	}
+	assertChecked := func(expect ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		c.Assert(checked, gc.DeepEquals, expect)
+		checked = nil
+	}
+	rejected := make(chan error, 1)
+	checks := worker.EnvironChecks{
+		Validate: func(env environs.Environ) error {
+			record("validate " + secret(env.Config()))
+			if secret(env.Config()) == "invalid-secret" {
+				return fmt.Errorf("secret not allowed")
+			}
+			return nil
+		},
+		SmokeTest: func(env environs.Environ) error {
+			record("smoke-test " + secret(env.Config()))
+			if secret(env.Config()) == "unreachable-secret" {
+				return fmt.Errorf("cannot connect")
+			}
+			return nil
+		},
+		Rejected: func(cfg *config.Config, err error) {
+			rejected <- err
+		},
+	}
+	obs, err := worker.NewCheckedEnvironObserver(s.State, checks)
+	c.Assert(err, gc.IsNil)
+	defer func() {
+		obs.Kill()
+		c.Assert(obs.Wait(), gc.IsNil)
+	}()
+	c.Assert(obs.Config().AllAttrs(), gc.DeepEquals, originalConfig.AllAttrs())
+	original := secret(originalConfig)
+	assertChecked("validate "+original, "smoke-test "+original)
+
+	assertRejected := func(value, expectErr string) {
+		err := s.State.UpdateEnvironConfig(map[string]interface{}{"secret": value}, nil, nil)
+		c.Assert(err, gc.IsNil)
+		s.State.StartSync()
+		select {
+		case err := <-rejected:
+			c.Assert(err, gc.ErrorMatches, expectErr)
+		case <-time.After(coretesting.LongWait):
+			c.Fatalf("timed out waiting for %q to be rejected", value)
+		}
+		// The previous Environ and configuration remain current.
+		c.Assert(obs.Environ().Config().AllAttrs(), gc.DeepEquals, originalConfig.AllAttrs())
+		c.Assert(obs.Config().AllAttrs(), gc.DeepEquals, originalConfig.AllAttrs())
+	}
+
+	// A configuration that fails validation is never smoke tested.
+	assertRejected("invalid-secret", "validation failed: secret not allowed")
+	assertChecked("validate invalid-secret")
+
+	assertRejected("unreachable-secret", "smoke test failed: cannot connect")
+	assertChecked("validate unreachable-secret", "smoke-test unreachable-secret")
+
+	// A configuration that passes all the checks is applied.
+	err = s.State.UpdateEnvironConfig(map[string]interface{}{"secret": "a-new-secret"}, nil, nil)
+	c.Assert(err, gc.IsNil)
+	s.State.StartSync()
+	for a := coretesting.LongAttempt.Start(); a.Next(); {
+		if secret(obs.Config()) == "a-new-secret" {
+			break
+		}
+		if !a.HasNext() {
+			c.Fatalf("timed out waiting for new environ")
+		}
+	}
+	c.Assert(secret(obs.Environ().Config()), gc.Equals, "a-new-secret")
+	select {
+	case err := <-rejected:
+		c.Fatalf("unexpected rejection: %v", err)
+	default:
+	}
+}
+
+func (s *environSuite) TestCheckedEnvironmentInitialConfigRejected(c *gc.C) {
+	obs, err := worker.NewCheckedEnvironObserver(s.State, worker.EnvironChecks{
+		SmokeTest: func(environs.Environ) error {
+			return fmt.Errorf("cannot connect")
+		},
+	})
+	c.Assert(err, gc.ErrorMatches, "cannot make Environ: smoke test failed: cannot connect")
+	c.Assert(obs, gc.IsNil)
+}
+
 type logChan chan string
 
 func (logc logChan) Write(level loggo.Level, name, filename string, line int, timestamp time.Time, message string) {
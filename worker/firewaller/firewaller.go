@@ -80,7 +80,9 @@ func NewFirewaller(st *apifirewaller.State) (_ worker.Worker, err error) {
 	// We won't "wait" actually, because the environ is already
 	// available and has a guaranteed valid config, but until
 	// WaitForEnviron goes away, this code needs to stay.
-	fw.environ, err = worker.WaitForEnviron(fw.environWatcher, fw.st, fw.tomb.Dying())
+	fw.environ, err = worker.WaitForEnvironReporting(fw.environWatcher, fw.st, fw.tomb.Dying(), func(err error) {
+		logger.Errorf("waiting for valid environ config: %v", err)
+	})
 	if err != nil {
 		return nil, err
 	}
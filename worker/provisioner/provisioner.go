@@ -175,10 +175,14 @@ func (p *environProvisioner) loop() error {
 	environConfigChanges = environWatcher.Changes()
 	defer watcher.Stop(environWatcher, &p.tomb)
 
-	p.environ, err = worker.WaitForEnviron(environWatcher, p.st, p.tomb.Dying())
+	p.environ, err = worker.WaitForEnvironReporting(environWatcher, p.st, p.tomb.Dying(), func(err error) {
+		logger.Errorf("waiting for valid environ config: %v", err)
+		p.reportConfigError(err)
+	})
 	if err != nil {
 		return err
 	}
+	p.reportConfigError(nil)
 	p.broker = p.environ
 
 	harvestMode := p.environ.Config().ProvisionerHarvestMode()
@@ -205,9 +209,11 @@ func (p *environProvisioner) loop() error {
 				logger.Errorf("cannot load environment configuration: %v", err)
 				return err
 			}
-			if err := p.setConfig(environConfig); err != nil {
+			err = p.setConfig(environConfig)
+			if err != nil {
 				logger.Errorf("loaded invalid environment configuration: %v", err)
 			}
+			p.reportConfigError(err)
 			task.SetHarvestMode(environConfig.ProvisionerHarvestMode())
 		}
 	}
@@ -231,6 +237,19 @@ func (p *environProvisioner) setConfig(environConfig *config.Config) error {
 	return nil
 }
 
+// reportConfigError records err as the reason the environment
+// configuration was last found unusable, or clears the recorded
+// reason if err is nil. Failing to record it is not fatal.
+func (p *environProvisioner) reportConfigError(err error) {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	if err := p.st.SetEnvironConfigError(reason); err != nil {
+		logger.Warningf("cannot record environment configuration error: %v", err)
+	}
+}
+
 // NewContainerProvisioner returns a new Provisioner. When new machines
 // are added to the state, it allocates instances from the environment
 // and allocates them to the new machines.
@@ -721,6 +721,33 @@ func (s *ProvisionerSuite) TestProvisioningOccursWithFixedEnvironment(c *gc.C) {
 	s.checkStartInstance(c, m)
 }
 
+// waitConfigError waits until the environment's recorded config
+// error is the expected one.
+func (s *ProvisionerSuite) waitConfigError(c *gc.C, expect string) {
+	env, err := s.State.Environment()
+	c.Assert(err, gc.IsNil)
+	for t0 := time.Now(); time.Since(t0) < coretesting.LongWait; time.Sleep(coretesting.ShortWait) {
+		err := env.Refresh()
+		c.Assert(err, gc.IsNil)
+		if env.ConfigError() == expect {
+			return
+		}
+	}
+	c.Fatalf("timed out waiting for config error %q; last saw %q", expect, env.ConfigError())
+}
+
+func (s *ProvisionerSuite) TestProvisionerRecordsEnvironConfigError(c *gc.C) {
+	s.invalidateEnvironment(c)
+
+	p := s.newEnvironProvisioner(c)
+	defer stop(c, p)
+	s.waitConfigError(c, `no registered provider for "unknown"`)
+
+	err := s.fixEnvironment(c)
+	c.Assert(err, gc.IsNil)
+	s.waitConfigError(c, "")
+}
+
 func (s *ProvisionerSuite) TestProvisioningDoesOccurAfterInvalidEnvironmentPublished(c *gc.C) {
 	s.PatchValue(provisioner.GetToolsFinder, func(*apiprovisioner.State) provisioner.ToolsFinder {
 		return mockToolsFinder{}
@@ -57,8 +57,9 @@ func (d *BundlesDir) download(info BundleInfo, abort <-chan struct{}) (err error
 	// present cannot be verified due to the certificates
 	// being inadequate. We always verify the SHA-256 hash,
 	// and the data transferred is not sensitive, so this
-	// does not pose a problem.
-	dl := downloader.New(aurl, dir, utils.NoVerifySSLHostnames)
+	// does not pose a problem. The API server requires the
+	// unit's credentials, which the archive header holds.
+	dl := downloader.NewWithHeader(aurl, info.ArchiveHeader(), dir, utils.NoVerifySSLHostnames)
 	defer dl.Stop()
 	for {
 		select {
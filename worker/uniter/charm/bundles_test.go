@@ -32,8 +32,10 @@ type BundlesDirSuite struct {
 	gitjujutesting.HTTPSuite
 	testing.JujuConnSuite
 
-	st     *api.State
-	uniter *uniter.State
+	st       *api.State
+	uniter   *uniter.State
+	unit     *state.Unit
+	password string
 }
 
 var _ = gc.Suite(&BundlesDirSuite{})
@@ -61,6 +63,7 @@ func (s *BundlesDirSuite) SetUpTest(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	err = unit.SetPassword(password)
 	c.Assert(err, gc.IsNil)
+	s.unit, s.password = unit, password
 
 	s.st = s.OpenAPIAs(c, unit.Tag(), password)
 	c.Assert(s.st, gc.NotNil)
@@ -157,6 +160,20 @@ func (s *BundlesDirSuite) TestGet(c *gc.C) {
 	}
 }
 
+func (s *BundlesDirSuite) TestReadSendsUnitCredentials(c *gc.C) {
+	d := charm.NewBundlesDir(c.MkDir())
+	apiCharm, sch, bundata := s.AddCharm(c)
+
+	gitjujutesting.Server.Response(200, nil, bundata)
+	ch, err := d.Read(apiCharm, nil)
+	c.Assert(err, gc.IsNil)
+	assertCharm(c, ch, sch)
+
+	req := gitjujutesting.Server.WaitRequest()
+	expected := utils.BasicAuthHeader(s.unit.Tag().String(), s.password)
+	c.Assert(req.Header.Get("Authorization"), gc.Equals, expected.Get("Authorization"))
+}
+
 func readHash(c *gc.C, path string) ([]byte, string) {
 	data, err := ioutil.ReadFile(path)
 	c.Assert(err, gc.IsNil)
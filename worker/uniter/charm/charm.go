@@ -5,6 +5,7 @@ package charm
 
 import (
 	"errors"
+	"net/http"
 	"net/url"
 
 	"github.com/juju/loggo"
@@ -42,6 +43,10 @@ type BundleInfo interface {
 	// Archive URL returns the location of the bundle data.
 	ArchiveURL() *url.URL
 
+	// ArchiveHeader returns the HTTP header, such as one holding
+	// credentials, to send when fetching the bundle data.
+	ArchiveHeader() http.Header
+
 	// ArchiveSha256 returns the hex-encoded SHA-256 digest of the bundle data.
 	ArchiveSha256() (string, error)
 }
@@ -549,7 +549,14 @@ func (ctx *HookContext) finalizeAction(err, unhandledErr error) error {
 		status = params.ActionFailed
 	}
 
-	callErr := ctx.state.ActionFinish(tag, status, results, message)
+	callErr := ctx.state.ActionFinishWithUsage(tag, status, results, message, ctx.actionData.Usage)
+	if params.IsCodeNotFound(callErr) {
+		// The action was finished while it ran, as happens when it is
+		// aborted by an administrator; its result has been recorded
+		// already, and the outcome of this run is discarded.
+		logger.Infof("action %q was finished elsewhere; discarding its outcome", tag.Id())
+		callErr = nil
+	}
 	if callErr != nil {
 		unhandledErr = errors.Wrap(unhandledErr, callErr)
 	}
@@ -694,7 +701,29 @@ func (ctx *HookContext) runCharmHook(hookName, charmDir string, env []string, ch
 	err = ps.Start()
 	outWriter.Close()
 	if err == nil {
+		var timer *time.Timer
+		var timedOut bool
+		fired := make(chan struct{})
+		if ctx.actionData != nil && ctx.actionData.Timeout > 0 {
+			timer = time.AfterFunc(ctx.actionData.Timeout, func() {
+				defer close(fired)
+				// The action only timed out if it was still
+				// running to be killed.
+				timedOut = ps.Process.Kill() == nil
+			})
+		}
 		err = ps.Wait()
+		if timer != nil {
+			if !timer.Stop() {
+				<-fired
+			}
+			if timedOut {
+				err = fmt.Errorf("action timed out after %v", ctx.actionData.Timeout)
+			}
+		}
+		if ctx.actionData != nil {
+			ctx.actionData.Usage = processUsage(ps.ProcessState)
+		}
 	}
 	hookLogger.stop()
 	return err
@@ -865,6 +894,14 @@ type actionData struct {
 	ActionFailed   bool
 	ResultsMessage string
 	ResultsMap     map[string]interface{}
+
+	// Usage holds the resources consumed by the action's process,
+	// if they were captured.
+	Usage *params.ActionResourceUsage
+
+	// Timeout, if not zero, is how long the action's process may run
+	// before it is killed.
+	Timeout time.Duration
 }
 
 // newActionData builds a suitable actionData struct with no nil members.
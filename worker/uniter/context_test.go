@@ -287,6 +287,28 @@ func (s *RunHookSuite) TestRunHook(c *gc.C) {
 	}
 }
 
+func (s *RunHookSuite) TestRunActionAbortedWhileRunning(c *gc.C) {
+	action, err := s.unit.AddAction("snapshot", nil)
+	c.Assert(err, gc.IsNil)
+	tag := action.ActionTag()
+	uuid, err := utils.NewUUID()
+	c.Assert(err, gc.IsNil)
+	context, err := uniter.NewHookContext(s.apiUnit, s.uniter, "TestCtx", uuid.String(),
+		"test-env-name", -1, "", s.relctxs, apiAddrs, names.NewUserTag("owner"),
+		proxy.Settings{}, false, uniter.NewActionData(&tag, nil), s.machine.Tag().(names.MachineTag))
+	c.Assert(err, gc.IsNil)
+
+	// The action is aborted before the unit finishes it.
+	_, err = action.Finish(state.ActionResults{Status: state.ActionAborted, Message: "aborted by administrator"})
+	c.Assert(err, gc.IsNil)
+	err = context.RunAction("snapshot", c.MkDir(), c.MkDir(), "/path/to/socket")
+	c.Assert(err, gc.IsNil)
+
+	result, err := s.State.ActionResultByTag(tag)
+	c.Assert(err, gc.IsNil)
+	c.Assert(result.Status(), gc.Equals, state.ActionAborted)
+}
+
 // split the line into buffer-sized lengths.
 func splitLine(s string) []string {
 	var ss []string
@@ -74,6 +74,7 @@ var (
 	TryOpenPorts        = tryOpenPorts
 	TryClosePorts       = tryClosePorts
 	CollectMetricsTimer = collectMetricsTimer
+	NewActionData       = newActionData
 )
 
 // manualTicker will be used to generate collect-metrics events
@@ -5,6 +5,7 @@ package uniter
 
 import (
 	"sort"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -82,6 +83,12 @@ type filter struct {
 	// flag.
 	didClearResolved chan struct{}
 
+	// deferAction is used to request that an action event be sent
+	// again once the action may be run. The actions watcher cannot be
+	// relied upon for this: it does not report changes to actions that
+	// were already queued when it started.
+	deferAction chan deferredAction
+
 	// The following fields hold state that is collected while running,
 	// and used to detect interesting changes to express as events.
 	unit             *uniter.Unit
@@ -93,6 +100,7 @@ type filter struct {
 	upgrade          *charm.URL
 	relations        []int
 	actionsPending   []string
+	actionsDeferred  map[string]time.Time
 	nextAction       *hook.Info
 
 	// meterStatusCode and meterStatusInfo reflect the meter status values of the unit.
@@ -125,6 +133,8 @@ func newFilter(st *uniter.State, unitTag names.UnitTag) (*filter, error) {
 		didSetCharm:       make(chan struct{}),
 		clearResolved:     make(chan struct{}),
 		didClearResolved:  make(chan struct{}),
+		deferAction:       make(chan deferredAction),
+		actionsDeferred:   make(map[string]time.Time),
 	}
 	go func() {
 		defer f.tomb.Done()
@@ -264,6 +274,45 @@ func (f *filter) DiscardConfigEvent() {
 	}
 }
 
+// deferredAction identifies an action that may not be run before a
+// given time.
+type deferredAction struct {
+	id        string
+	notBefore time.Time
+}
+
+// DeferActionEvent asks the filter to send an event for the action with
+// the given id once notBefore has passed, as when an action that failed
+// is to be run again after a backoff. DeferActionEvent does not block
+// while the action waits.
+func (f *filter) DeferActionEvent(id string, notBefore time.Time) {
+	select {
+	case <-f.tomb.Dying():
+	case f.deferAction <- deferredAction{id, notBefore}:
+	}
+}
+
+// WaitForActionSlot asks the filter to send an event for the action
+// with the given id when the slot the action is waiting for changes, as
+// reported by w, whose initial event must already have been received.
+// The filter takes responsibility for stopping w.
+func (f *filter) WaitForActionSlot(id string, w apiwatcher.NotifyWatcher) {
+	go func() {
+		defer f.maybeStopWatcher(w)
+		select {
+		case <-f.tomb.Dying():
+			return
+		case _, ok := <-w.Changes():
+			if !ok {
+				f.tomb.Kill(watcher.EnsureErr(w))
+				return
+			}
+		}
+		filterLogger.Debugf("slot changed for action %q", id)
+		f.DeferActionEvent(id, time.Time{})
+	}()
+}
+
 func (f *filter) maybeStopWatcher(w watcher.Stopper) {
 	if w != nil {
 		watcher.Stop(w, &f.tomb)
@@ -347,6 +396,9 @@ func (f *filter) loop(unitTag names.UnitTag) (err error) {
 	// once we receive the initial change, we unblock discard requests by
 	// setting this channel to its namesake on f.
 	var discardConfig chan struct{}
+	// deferredActionsDue fires when the earliest deferred action may be
+	// run.
+	var deferredActionsDue <-chan time.Time
 	for {
 		var ok bool
 		select {
@@ -410,6 +462,10 @@ func (f *filter) loop(unitTag names.UnitTag) (err error) {
 			}
 			f.actionsPending = append(f.actionsPending, ids...)
 			f.nextAction = f.getNextAction()
+		case <-deferredActionsDue:
+			filterLogger.Debugf("deferred actions due")
+			f.actionsDue(time.Now())
+			deferredActionsDue = f.deferredActionsDue()
 		case keys, ok := <-relationsw.Changes():
 			filterLogger.Debugf("got relations change")
 			if !ok {
@@ -516,6 +572,10 @@ func (f *filter) loop(unitTag names.UnitTag) (err error) {
 		case <-discardConfig:
 			filterLogger.Debugf("discarded config event")
 			f.outConfig = nil
+		case deferred := <-f.deferAction:
+			filterLogger.Debugf("action %q deferred until %v", deferred.id, deferred.notBefore)
+			f.actionsDeferred[deferred.id] = deferred.notBefore
+			deferredActionsDue = f.deferredActionsDue()
 		}
 	}
 }
@@ -651,6 +711,54 @@ func (f *filter) getNextAction() *hook.Info {
 	return nil
 }
 
+// actionsDue queues the deferred actions that may be run at the given
+// time, unless they are already queued.
+func (f *filter) actionsDue(now time.Time) {
+	for id, notBefore := range f.actionsDeferred {
+		if notBefore.After(now) {
+			continue
+		}
+		delete(f.actionsDeferred, id)
+		if f.actionQueued(id) {
+			continue
+		}
+		f.actionsPending = append(f.actionsPending, id)
+	}
+	if f.nextAction == nil {
+		f.nextAction = f.getNextAction()
+	}
+}
+
+// actionQueued returns whether an event for the action with the given
+// id is waiting to be sent.
+func (f *filter) actionQueued(id string) bool {
+	if f.nextAction != nil && f.nextAction.ActionId == id {
+		return true
+	}
+	for _, pending := range f.actionsPending {
+		if pending == id {
+			return true
+		}
+	}
+	return false
+}
+
+// deferredActionsDue returns a channel that fires when the earliest
+// deferred action may be run, or nil if no actions are deferred.
+func (f *filter) deferredActionsDue() <-chan time.Time {
+	if len(f.actionsDeferred) == 0 {
+		return nil
+	}
+	var earliest time.Time
+	first := true
+	for _, notBefore := range f.actionsDeferred {
+		if first || notBefore.Before(earliest) {
+			earliest, first = notBefore, false
+		}
+	}
+	return time.After(earliest.Sub(time.Now()))
+}
+
 // serviceCharm holds information about a charm.
 type serviceCharm struct {
 	url   *charm.URL
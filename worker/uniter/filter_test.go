@@ -707,3 +707,55 @@ func (s *FilterSuite) TestMeterStatusEvents(c *gc.C) {
 	}
 	assertChange()
 }
+
+func (s *FilterSuite) TestWaitForActionSlot(c *gc.C) {
+	f, err := newFilter(s.uniter, s.unit.Tag().(names.UnitTag))
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, f)
+	assertNoChange := getAssertNoActionChange(s, f, c)
+	assertChange := getAssertActionChange(s, f, c)
+
+	// The slot is held by an action on another unit.
+	slot := state.ActionSlot{Name: "restore", MaxConcurrent: 1}
+	other, err := s.wordpress.AddUnit()
+	c.Assert(err, gc.IsNil)
+	holder, err := other.AddActionWithOptions("snapshot", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	holder, err = holder.Begin()
+	c.Assert(err, gc.IsNil)
+	waiting, err := s.unit.AddActionWithOptions("snapshot", nil, state.ActionOptions{Slot: &slot})
+	c.Assert(err, gc.IsNil)
+	assertChange([]string{waiting.Id()})
+
+	w, err := s.uniter.WatchActionSlot(waiting.ActionTag())
+	c.Assert(err, gc.IsNil)
+	select {
+	case <-w.Changes():
+	case <-time.After(coretesting.LongWait):
+		c.Fatalf("timed out waiting for initial slot event")
+	}
+	f.WaitForActionSlot(waiting.Id(), w)
+	assertNoChange()
+
+	// The waiting action is sent again once the slot is released.
+	_, err = holder.Finish(state.ActionResults{Status: state.ActionCompleted})
+	c.Assert(err, gc.IsNil)
+	assertChange([]string{waiting.Id()})
+}
+
+func (s *FilterSuite) TestDeferActionEvent(c *gc.C) {
+	f, err := newFilter(s.uniter, s.unit.Tag().(names.UnitTag))
+	c.Assert(err, gc.IsNil)
+	defer statetesting.AssertStop(c, f)
+	assertNoChange := getAssertNoActionChange(s, f, c)
+	assertChange := getAssertActionChange(s, f, c)
+
+	testId := getAddAction(s, c)("snapshot")
+	assertChange([]string{testId})
+
+	// The action is sent again once it is due, and only once.
+	f.DeferActionEvent(testId, time.Now().Add(coretesting.ShortWait))
+	f.DeferActionEvent(testId, time.Now().Add(coretesting.ShortWait))
+	assertChange([]string{testId})
+	assertNoChange()
+}
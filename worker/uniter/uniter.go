@@ -526,10 +526,33 @@ func (u *Uniter) runAction(hi hook.Info) (err error) {
 
 	tag := names.NewActionTag(hi.ActionId)
 	action, err := u.st.Action(tag)
-	if err != nil {
+	if params.IsCodeNotFound(err) {
+		// The action was finished or cancelled after it was queued.
+		logger.Infof("action %q is no longer pending", hi.ActionId)
+		return nil
+	} else if err != nil {
 		return err
 	}
 
+	// An action being retried after failing is put back until its
+	// backoff has passed, leaving the uniter free to do other work.
+	if notBefore := action.NotBefore(); notBefore.After(time.Now()) {
+		logger.Infof("deferring attempt %d of action %q until %v", action.Attempt(), action.Name(), notBefore)
+		u.f.DeferActionEvent(hi.ActionId, notBefore)
+		return nil
+	}
+
+	// An action limited by a slot may only run once it has taken the
+	// slot, which it does as it begins.
+	begun := false
+	if action.Slot() != "" {
+		if begun, err = u.beginActionWithSlot(hi.ActionId, action); err != nil {
+			return err
+		} else if !begun {
+			return nil
+		}
+	}
+
 	actionParams := action.Params()
 	actionName := action.Name()
 	_, actionParamsErr := u.validateAction(actionName, actionParams)
@@ -549,6 +572,7 @@ func (u *Uniter) runAction(hi hook.Info) (err error) {
 	if err != nil {
 		return err
 	}
+	hctx.actionData.Timeout = action.Timeout()
 
 	srv, socketPath, err := u.startJujucServer(hctx)
 	if err != nil {
@@ -571,6 +595,14 @@ func (u *Uniter) runAction(hi hook.Info) (err error) {
 		}
 	}
 
+	// Record when the action started, so its run duration is known.
+	// Failure to do so must not prevent the action from running.
+	if !begun {
+		if err := u.st.ActionBegin(tag); err != nil {
+			logger.Warningf("cannot record start of action %q: %v", actionName, err)
+		}
+	}
+
 	// err will be any unhandled error from finalizeContext.
 	err = hctx.RunAction(actionName, u.charmPath, u.toolsDir, socketPath)
 
@@ -585,7 +617,59 @@ func (u *Uniter) runAction(hi hook.Info) (err error) {
 	}
 	logger.Infof(hctx.actionData.ResultsMessage)
 	u.notifyHookCompleted(actionName, hctx)
-	return u.commitHook(hi)
+	if err := u.commitHook(hi); err != nil {
+		return err
+	}
+	return u.deferRetriedAction(hi.ActionId)
+}
+
+// deferRetriedAction arranges for the action with the given id to be
+// run again if it failed and its retry policy left it queued. The
+// actions watcher does not report such an action again if it was
+// already queued when the watcher started, so the uniter must.
+func (u *Uniter) deferRetriedAction(id string) error {
+	action, err := u.st.Action(names.NewActionTag(id))
+	if params.IsCodeNotFound(err) {
+		// The action is finished for good.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	logger.Infof("attempt %d of action %q will run after %v", action.Attempt(), action.Name(), action.NotBefore())
+	u.f.DeferActionEvent(id, action.NotBefore())
+	return nil
+}
+
+// beginActionWithSlot begins the action with the given id, which is
+// limited by a slot, and reports whether it did so. If the slot is
+// held by as many other actions as it allows, the action is left
+// queued, and the filter sends it again when the slot's holders
+// change; the uniter is free to do other work meanwhile.
+func (u *Uniter) beginActionWithSlot(id string, action *uniter.Action) (bool, error) {
+	tag := names.NewActionTag(id)
+	// The slot is watched before trying to take it, so that it cannot
+	// be released unnoticed in between.
+	w, err := u.st.WatchActionSlot(tag)
+	if err != nil {
+		return false, err
+	}
+	select {
+	case <-u.tomb.Dying():
+		watcher.Stop(w, &u.tomb)
+		return false, tomb.ErrDying
+	case _, ok := <-w.Changes():
+		if !ok {
+			return false, watcher.EnsureErr(w)
+		}
+	}
+	err = u.st.ActionBegin(tag)
+	if !params.IsCodeActionSlotFull(err) {
+		watcher.Stop(w, &u.tomb)
+		return err == nil, err
+	}
+	logger.Infof("action %q waiting for slot %q", action.Name(), action.Slot())
+	u.f.WaitForActionSlot(id, w)
+	return false, nil
 }
 
 // runHook executes the supplied hook.Info in an appropriate hook context. If
@@ -1605,6 +1605,62 @@ var actionEventTests = []uniterTest{
 			status:  params.ActionCompleted,
 		}}},
 		waitUnit{status: params.StatusStarted},
+	), ut(
+		"a failed action is run again according to its retry policy",
+		createCharm{
+			customize: func(c *gc.C, ctx *context, path string) {
+				ctx.writeAction(c, path, "action-log-fail")
+				ctx.writeActionsYaml(c, path, "action-log-fail")
+			},
+		},
+		serveCharm{},
+		ensureStateWorker{},
+		createServiceAndUnit{},
+		startUniter{},
+		waitAddresses{},
+		waitUnit{status: params.StatusStarted},
+		waitHooks{"install", "config-changed", "start"},
+		verifyCharm{},
+		addActionWithOptions{"action-log-fail", state.ActionOptions{
+			Retry: &state.ActionRetryPolicy{MaxAttempts: 3, Backoff: time.Second},
+		}},
+		waitHooks{"action-log-fail", "action-log-fail", "action-log-fail"},
+		verifyActionResults{[]actionResult{{
+			name: "action-log-fail",
+			results: map[string]interface{}{
+				"foo": "still works",
+			},
+			message: "I'm afraid I can't let you do that, Dave.",
+			status:  params.ActionFailed,
+		}}},
+		waitUnit{status: params.StatusStarted},
+	), ut(
+		"a failed action queued before the uniter starts is run again",
+		createCharm{
+			customize: func(c *gc.C, ctx *context, path string) {
+				ctx.writeAction(c, path, "action-log-fail")
+				ctx.writeActionsYaml(c, path, "action-log-fail")
+			},
+		},
+		serveCharm{},
+		ensureStateWorker{},
+		createServiceAndUnit{},
+		addActionWithOptions{"action-log-fail", state.ActionOptions{
+			Retry: &state.ActionRetryPolicy{MaxAttempts: 2},
+		}},
+		startUniter{},
+		waitAddresses{},
+		waitUnit{status: params.StatusStarted},
+		waitHooks{"install", "config-changed", "start", "action-log-fail", "action-log-fail"},
+		verifyActionResults{[]actionResult{{
+			name: "action-log-fail",
+			results: map[string]interface{}{
+				"foo": "still works",
+			},
+			message: "I'm afraid I can't let you do that, Dave.",
+			status:  params.ActionFailed,
+		}}},
+		waitUnit{status: params.StatusStarted},
 	),
 }
 
@@ -2323,6 +2379,16 @@ func (s addAction) step(c *gc.C, ctx *context) {
 	c.Assert(err, gc.IsNil)
 }
 
+type addActionWithOptions struct {
+	name string
+	opts state.ActionOptions
+}
+
+func (s addActionWithOptions) step(c *gc.C, ctx *context) {
+	_, err := ctx.unit.AddActionWithOptions(s.name, nil, s.opts)
+	c.Assert(err, gc.IsNil)
+}
+
 type upgradeCharm struct {
 	revision int
 	forced   bool
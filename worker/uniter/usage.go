@@ -0,0 +1,30 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+// +build !windows
+
+package uniter
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+// processUsage returns the resources consumed by the exited process
+// described by ps, or nil if they are not known.
+func processUsage(ps *os.ProcessState) *params.ActionResourceUsage {
+	if ps == nil {
+		return nil
+	}
+	rusage, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return nil
+	}
+	return &params.ActionResourceUsage{
+		CPUTime: time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano()),
+		// Maxrss is reported in kilobytes.
+		PeakMemory: uint64(rusage.Maxrss) * 1024,
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2014 Canonical Ltd.
+// Licensed under the AGPLv3, see LICENCE file for details.
+
+package uniter
+
+import (
+	"os"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+// processUsage returns nil: the resources consumed by processes are
+// not yet captured on windows.
+func processUsage(ps *os.ProcessState) *params.ActionResourceUsage {
+	return nil
+}